@@ -3,15 +3,15 @@ package aws_api
 import (
 	"context"
 	"encoding/json"
-	"flag"
+	"errors"
 	"fmt"
 	"net"
 	"os"
 	"os/signal"
 	"path/filepath"
-	"slices"
-	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -33,173 +33,155 @@ type AWSTCPDumpConfig struct {
 	IamDataDirPath           string   `json:"IamDataDirPath"`
 	LiveRecording            bool     `json:"LiveRecording"`
 	AddrFilters              []string `json:"AddrFilters"`
+	OfflinePaths             []string `json:"OfflinePaths"`
+	// Custom flow log format, e.g. "${vpc-id} ${srcaddr} ${flow-direction}", DefaultFlowLogFormat when empty.
+	LogFormat string `json:"LogFormat"`
+	// Traffic aggregation: top N rows per table, time bucket size, JSON report and chart outputs.
+	TopN                  int    `json:"TopN"`
+	BucketSeconds         int    `json:"BucketSeconds"`
+	TrafficReportFilePath string `json:"TrafficReportFilePath"`
+	TrafficChartFilePath  string `json:"TrafficChartFilePath"`
+	// tcpdump like filter expression, see FlowLogFilter.
+	Filter string `json:"Filter"`
+	// Annotate the events with the owning resources, EnrichDNS adds Route53 private zones names.
+	Enrich    bool `json:"Enrich"`
+	EnrichDNS bool `json:"EnrichDNS"`
+	// Security groups analysis: JSON plan output, flows window and the rules under review.
+	SecurityGroupsPlanFilePath string                       `json:"SecurityGroupsPlanFilePath"`
+	SecurityGroupsWindowHours  int                          `json:"SecurityGroupsWindowHours"`
+	ProposedRules              []*ProposedSecurityGroupRule `json:"ProposedRules"`
+	// Session manifests of the created resources, the log groups are kept on cleanup unless DeleteLogGroups.
+	SessionsDirPath string `json:"SessionsDirPath"`
+	DeleteLogGroups bool   `json:"DeleteLogGroups"`
+	// Zeek conn.log or pcap-ng export of the events, the format is picked by the file extension when not set.
+	ExportFilePath string `json:"ExportFilePath"`
+	ExportFormat   string `json:"ExportFormat"`
 }
 
 type AWSTCPDump struct {
 	Config         *AWSTCPDumpConfig
 	KnownIntefaces []*ec2.NetworkInterface
-	Done           bool
+	// Set by Shutdown, or by a recorder that failed, read by the recorders goroutines.
+	Done           atomic.Bool
 	JsonLogger     *logger.Logger
 	EventsFilter   func(*FlowLogEvent) (*FlowLogEvent, error)
 	EventProcessor func(*FlowLogEvent) error
+	// Annotates the filtered events, nil when Config.Enrich is not set.
+	Enricher *FlowLogEnricher
+	// Builds the API clients, SDK backed clients for Config.AWSProfile when not set.
+	Clients *clients.Factory
+
+	// Counts the recorded events for the traffic report, nil unless StartAggregation was called.
+	Aggregator *FlowLogAggregator
+
+	// Resources created by Start, cleaned up by Shutdown.
+	Session *TCPDumpSession
+
+	formatOnce sync.Once
+	format     *FlowLogFormat
+	formatErr  error
+	// Formats of the existing subnet flow logs that differ from Config.LogFormat, set before the recorders start.
+	subnetFormats map[string]*FlowLogFormat
+
+	recordingOnce sync.Once
+	recording     context.Context
+	stopRecording context.CancelFunc
+	recorders     sync.WaitGroup
 }
 
-func AWSTCPDumpNew() (*AWSTCPDump, error) {
-	new := &AWSTCPDump{}
-	new.initConfig()
-
-	new.Done = false
-
-	new.JsonLogger = &(logger.Logger{FileDst: new.Config.InterfacesOutputFilePath, AddDateTime: true})
-
-	return new, nil
-}
-func (AwsTCPDump *AWSTCPDump) LoadConfig(filePath string) (*AWSTCPDumpConfig, error) {
-	fileString, err := os.ReadFile(filePath)
-	_ = fileString
-	// todo:
-	if err != nil {
-		return nil, err
+func (awsTCPDump *AWSTCPDump) getClients() *clients.Factory {
+	if awsTCPDump.Clients == nil {
+		awsTCPDump.Clients = clients.FactoryNew(&awsTCPDump.Config.AWSProfile)
 	}
-	config := &AWSTCPDumpConfig{}
-	return config, nil
+	return awsTCPDump.Clients
 }
-func (AwsTCPDump *AWSTCPDump) ParseArgs(flagset *flag.FlagSet, args []string) (*AWSTCPDumpConfig, error) {
-	var region *string
-	if slices.Contains(args, "-region") {
-		region = flagset.String("region", "", "AWS Region")
-	} else {
-		region = new(string)
-	}
-
-	var subnetsString *string
-	if slices.Contains(args, "-subnets") {
-		subnetsString = flagset.String("subnets", "", "AWS Subnets to listen too")
-	} else {
-		subnetsString = new(string)
-	}
 
-	var profile *string
-	if slices.Contains(args, "-profile") {
-		profile = flagset.String("profile", "default", "AWS profile")
-	} else {
-		profile = new(string)
+// Generator, a nil config is an empty one. Unset paths are set to the /opt/aws_api/tcpdump defaults.
+func AWSTCPDumpNew(config *AWSTCPDumpConfig) (*AWSTCPDump, error) {
+	if config == nil {
+		config = &AWSTCPDumpConfig{}
 	}
-
-	var live *bool
-	if slices.Contains(args, "-live") {
-		live = flagset.Bool("live", false, "Live traffic recording")
-	} else {
-		live = new(bool)
-	}
-
-	var filterAddrs *string
-	if slices.Contains(args, "-addr") {
-		filterAddrs = flagset.String("addr", "", "Filter addresses")
-	} else {
-		filterAddrs = new(string)
-	}
-
-	var configPath *string
-	if slices.Contains(args, "-config") {
-		configPath = flagset.String("confg", "", "Configuration file path")
-	} else {
-		configPath = clients.StrPtr("/opt/aws_api/tcpdump/data/tcpdump.conf")
-	}
-
-	err := flagset.Parse(args)
-	if err != nil {
-		return nil, err
-	}
-
-	config, err := AwsTCPDump.LoadConfig(*configPath)
+	new := &AWSTCPDump{Config: config}
+	err := new.initConfig()
 	if err != nil {
 		return nil, err
 	}
 
-	if *subnetsString == "" {
-		config.Subnets = []string{}
-	} else {
-		config.Subnets = strings.Split(*subnetsString, ",")
-	}
-
-	if *region != "" {
-		config.Region = *region
-	}
-
-	if *filterAddrs == "" {
-		config.AddrFilters = []string{}
-	} else {
-		config.AddrFilters = strings.Split(*filterAddrs, ",")
-	}
-
-	if *profile != "" {
-		config.AWSProfile = *profile
-	}
-
-	if *live {
-		config.LiveRecording = *live
-	}
+	new.JsonLogger = &(logger.Logger{FileDst: new.Config.InterfacesOutputFilePath, AddDateTime: true})
 
-	return config, nil
+	return new, nil
 }
 
 func (AwsTCPDump *AWSTCPDump) initConfig() error {
-
-	//InterfacesOutputFilePath
-	//LogOutputFilePath
-	//IamDataDirPath
-	//ProcessedOutputFilePath
-	//
-
-	flagset := &flag.FlagSet{}
-	config, err := AwsTCPDump.ParseArgs(flagset, os.Args)
-	if err != nil {
-		return err
+	if AwsTCPDump.Config == nil {
+		AwsTCPDump.Config = &AWSTCPDumpConfig{}
 	}
-	AwsTCPDump.Config = config
 
 	if AwsTCPDump.Config.ProcessedOutputFilePath == "" {
 		AwsTCPDump.Config.ProcessedOutputFilePath = "/opt/aws_api/tcpdump/output/data.log"
-
-	}
-
-	if _, err := os.Stat(AwsTCPDump.Config.ProcessedOutputFilePath); err == nil {
-		os.Truncate(AwsTCPDump.Config.ProcessedOutputFilePath, 0)
 	}
 
 	if AwsTCPDump.Config.InterfacesOutputFilePath == "" {
 		AwsTCPDump.Config.InterfacesOutputFilePath = "/opt/aws_api/tcpdump/output/interfaces.json"
 	}
 
-	if _, err := os.Stat(config.InterfacesOutputFilePath); err == nil {
-		os.Truncate(config.InterfacesOutputFilePath, 0)
-	} else if !os.IsNotExist(err) {
-		fmt.Printf("Error checking file  '%s': %v\n", config.InterfacesOutputFilePath, err)
+	if AwsTCPDump.Config.IamDataDirPath == "" {
+		AwsTCPDump.Config.IamDataDirPath = "/opt/aws_api/tcpdump/data/IamDataDir"
+	}
+
+	if AwsTCPDump.Config.SessionsDirPath == "" {
+		AwsTCPDump.Config.SessionsDirPath = "/opt/aws_api/tcpdump/sessions"
 	}
 
 	if AwsTCPDump.Config.LogOutputFilePath == "" {
 		AwsTCPDump.Config.LogOutputFilePath = "/opt/aws_api/tcpdump/output/tcpdump.log"
-
 	}
 
-	if _, err := os.Stat(config.LogOutputFilePath); err == nil {
-		os.Truncate(config.LogOutputFilePath, 0)
-	}
-	lg.FileDst = config.LogOutputFilePath
+	lg.FileDst = AwsTCPDump.Config.LogOutputFilePath
 	return nil
 }
 
+// truncateOutputFiles starts a recording or an analysis with empty outputs, the cleanup of a crashed
+// session keeps them.
+func (awsTCPDump *AWSTCPDump) truncateOutputFiles() {
+	for _, filePath := range []string{awsTCPDump.Config.ProcessedOutputFilePath, awsTCPDump.Config.InterfacesOutputFilePath, awsTCPDump.Config.LogOutputFilePath} {
+		if filePath == "" {
+			continue
+		}
+		if _, err := os.Stat(filePath); err == nil {
+			os.Truncate(filePath, 0)
+		} else if !os.IsNotExist(err) {
+			fmt.Printf("Error checking file  '%s': %v\n", filePath, err)
+		}
+	}
+}
+
 func (awsTCPDump *AWSTCPDump) Start() error {
+	awsTCPDump.truncateOutputFiles()
 	workPool := make(chan bool, 5)
+	if err := awsTCPDump.CompileEventsFilter(); err != nil {
+		return err
+	}
+	if err := awsTCPDump.LoadEnricher(); err != nil {
+		return err
+	}
 
-	subnetLogGroupNames, err := provisionSubnetsFlowLogGroups(awsTCPDump.Config)
+	session, err := TCPDumpSessionNew(awsTCPDump.Config.SessionsDirPath, awsTCPDump.Config.Region)
 	if err != nil {
 		return err
 	}
+	awsTCPDump.Session = session
+	lg.InfoF("Started session %s, run 'aws_tcpdump cleanup %s' if the process crashes", session.ID, session.ID)
+
+	subnetLogGroupNames, err := awsTCPDump.provisionSubnetsFlowLogGroups()
+	if err != nil {
+		return errors.Join(err, awsTCPDump.Shutdown())
+	}
 
 	for _, subnetId := range awsTCPDump.Config.Subnets {
-		go awsTCPDump.StartSubnetInterfacesRecording(&workPool, subnetId, subnetLogGroupNames[subnetId])
+		awsTCPDump.startRecorder(func() error {
+			return awsTCPDump.StartSubnetInterfacesRecording(&workPool, subnetId, subnetLogGroupNames[subnetId])
+		})
 	}
 
 	sigChan := make(chan os.Signal, 1)
@@ -208,22 +190,23 @@ func (awsTCPDump *AWSTCPDump) Start() error {
 	sig := <-sigChan
 	fmt.Printf("\nReceived OS signal: %s. Initiating graceful shutdown...\n", sig)
 
-	// Signal the main logic goroutine to stop.
-	awsTCPDump.Done = true
-
-	// Give some time for cleanup or final tasks.
-	fmt.Println("Performing final cleanup (e.g., closing connections, saving state)...")
-	time.Sleep(2 * time.Second) // Simulate cleanup time
-
+	err = awsTCPDump.Shutdown()
+	if err != nil {
+		return err
+	}
 	fmt.Println("Program gracefully stopped.")
 	return nil
 
 }
 
-func provisionSubnetsFlowLogGroups(config *AWSTCPDumpConfig) (map[string]string, error) {
-	iamAPI := clients.IAMAPINew(&config.AWSProfile, &config.IamDataDirPath)
+// provisionSubnetsFlowLogGroups returns the log group of each subnet flow log, the missing ones are created and
+// recorded in the session, so on error Shutdown cleans up what was created so far.
+func (awsTCPDump *AWSTCPDump) provisionSubnetsFlowLogGroups() (map[string]string, error) {
+	errorPrefix := "[aws_tcpdump:provisionSubnetsFlowLogGroups]"
+	config := awsTCPDump.Config
+	iamAPI := awsTCPDump.getClients().IAM(&config.IamDataDirPath)
 
-	stsAPI := clients.STSAPINew(&config.AWSProfile)
+	stsAPI := awsTCPDump.getClients().STS()
 	accountID, err := stsAPI.GetAccount()
 	if err != nil {
 		return nil, err
@@ -247,9 +230,19 @@ func provisionSubnetsFlowLogGroups(config *AWSTCPDumpConfig) (map[string]string,
 
 	roleName := "role-aws-tcpdump"
 	path := "/test/"
+	existingRole := &clients.Role{Name: &roleName}
+	roleExists, err := iamAPI.UpdateRoleInfo(existingRole)
+	if err != nil {
+		return nil, err
+	}
 	role, err := iamAPI.ProvisionIamCloudwatchWriterRole(&config.Region, &roleName, &strAssumeDocument, &path)
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("%s failed to provision role %s\n%w", errorPrefix, roleName, err)
+	}
+	if !roleExists {
+		if err := awsTCPDump.Session.AddRole(role); err != nil {
+			return nil, err
+		}
 	}
 	ret := make(map[string]string)
 
@@ -259,35 +252,50 @@ func provisionSubnetsFlowLogGroups(config *AWSTCPDumpConfig) (map[string]string,
 		subnetValues = append(subnetValues, &subnetString)
 	}
 
-	client := clients.GetEC2Client(&config.Region)
+	format, err := awsTCPDump.getFlowLogFormat()
+	if err != nil {
+		return nil, err
+	}
+	logFormat := format.String()
+
+	ec2API := awsTCPDump.getClients().EC2(&config.Region)
 	Filters := []*ec2.Filter{{
 		Name:   aws.String("resource-id"),
 		Values: subnetValues,
 	}}
 
-	flowLogObjects := make([]any, 0)
-	err = clients.DescribeFlowLogsPages(client, Filters, clients.AggregatorInitializer(&flowLogObjects))
-	if err != nil {
-		panic(err)
-	}
-
-	for _, obj := range flowLogObjects {
-		flowLog, ok := obj.(*ec2.FlowLog)
-		if !ok {
-			panic(obj)
+	for flowLog, err := range ec2API.IterFlowLogs(context.Background(), &ec2.DescribeFlowLogsInput{Filter: Filters}) {
+		if err != nil {
+			return nil, fmt.Errorf("%s failed to describe flow logs\n%w", errorPrefix, err)
 		}
 		ret[*flowLog.ResourceId] = *flowLog.LogGroupName
+		if flowLog.LogFormat != nil && *flowLog.LogFormat != format.String() {
+			subnetFormat, err := FlowLogFormatNew(*flowLog.LogFormat)
+			if err != nil {
+				return nil, fmt.Errorf("%s flow log %s of %s\n%w", errorPrefix, aws.StringValue(flowLog.FlowLogId), *flowLog.ResourceId, err)
+			}
+			if awsTCPDump.subnetFormats == nil {
+				awsTCPDump.subnetFormats = map[string]*FlowLogFormat{}
+			}
+			awsTCPDump.subnetFormats[*flowLog.ResourceId] = subnetFormat
+			lg.InfoF("Flow log %s of %s has format '%s', parsing its records with it", aws.StringValue(flowLog.FlowLogId), *flowLog.ResourceId, subnetFormat)
+		}
 	}
 	resourceType := "Subnet"
 	trafficType := "ALL"
 	for _, subnetId := range config.Subnets {
-		api := clients.EC2APINew(&config.Region, &config.AWSProfile)
 		_, ok := ret[subnetId]
 		if !ok {
-			logGroupName := provisionSubnetLogGroup(config, &subnetId)
-			_, err := api.ProvisionFlowLog(&logGroupName, &resourceType, &trafficType, []*string{&subnetId}, role.Arn)
+			logGroupName, err := awsTCPDump.provisionSubnetLogGroup(&subnetId)
 			if err != nil {
-				panic(err)
+				return nil, err
+			}
+			output, err := ec2API.ProvisionFlowLog(&logGroupName, &resourceType, &trafficType, []*string{&subnetId}, role.Arn, &logFormat)
+			if err != nil {
+				return nil, fmt.Errorf("%s failed to provision flow log of %s\n%w", errorPrefix, subnetId, err)
+			}
+			if err := awsTCPDump.Session.AddFlowLogs(output.FlowLogIds); err != nil {
+				return nil, err
 			}
 			ret[subnetId] = logGroupName
 		}
@@ -295,24 +303,27 @@ func provisionSubnetsFlowLogGroups(config *AWSTCPDumpConfig) (map[string]string,
 	return ret, nil
 }
 
-func provisionSubnetLogGroup(config *AWSTCPDumpConfig, subnetId *string) (logGroupName string) {
-	api := clients.CloudwatchLogsAPINew(&config.Region, &config.AWSProfile)
-	logGroupName = "tcpdump-" + *subnetId
+func (awsTCPDump *AWSTCPDump) provisionSubnetLogGroup(subnetId *string) (string, error) {
+	errorPrefix := "[aws_tcpdump:provisionSubnetLogGroup]"
+	api := awsTCPDump.getClients().CloudwatchLogs(&awsTCPDump.Config.Region)
+	logGroupName := "tcpdump-" + *subnetId
 	existingLogGroup, err := api.GetLogGroup(&logGroupName)
-
 	if err != nil {
-		panic(err)
+		return "", fmt.Errorf("%s failed to get log group %s\n%w", errorPrefix, logGroupName, err)
 	}
 
 	if existingLogGroup == nil {
 		output, err := api.ProvisionLogGroup(logGroupName)
 		if err != nil {
-			panic(err)
+			return "", fmt.Errorf("%s failed to provision log group %s\n%w", errorPrefix, logGroupName, err)
 		}
 		lg.InfoF("Provision Log group response: %v", output)
+		if err := awsTCPDump.Session.AddLogGroup(logGroupName); err != nil {
+			return "", fmt.Errorf("%s failed to record log group %s in the session\n%w", errorPrefix, logGroupName, err)
+		}
 	}
 
-	return logGroupName
+	return logGroupName, nil
 }
 
 func (awsTCPDump *AWSTCPDump) GetInterfaceChanges(config *AWSTCPDumpConfig, KnownNetworkInterfaces *map[string]context.CancelFunc, subnetId string) ([]string, []string, error) {
@@ -327,6 +338,8 @@ func (awsTCPDump *AWSTCPDump) GetInterfaceChanges(config *AWSTCPDumpConfig, Know
 
 		if _, ok := (*KnownNetworkInterfaces)[*ec2Interface.NetworkInterfaceId]; !ok {
 			retAdd = append(retAdd, *ec2Interface.NetworkInterfaceId)
+			awsTCPDump.Enricher.AddNetworkInterface(ec2Interface)
+			awsTCPDump.Aggregator.AddNetworkInterface(ec2Interface)
 			// 1. Marshal the struct to JSON bytes
 			jsonBytes, err := json.Marshal(ec2Interface)
 			if err != nil {
@@ -353,8 +366,19 @@ func (awsTCPDump *AWSTCPDump) GetInterfaceChanges(config *AWSTCPDumpConfig, Know
 	return retAdd, retDel, nil
 }
 
+// StartSubnetInterfacesRecording follows the subnet interfaces until Shutdown, the interface recorders
+// are stopped through their cancel funcs on return.
 func (awsTCPDump *AWSTCPDump) StartSubnetInterfacesRecording(workPool *chan bool, subnetId string, subnetLogGroupName string) error {
+	recording := awsTCPDump.recordingContext()
 	networkInterfaces := make(map[string]context.CancelFunc)
+	defer func() {
+		for ec2InterfaceId, cancel := range networkInterfaces {
+			if cancel != nil {
+				lg.InfoF("stopping interface recording using context: %s", ec2InterfaceId)
+				cancel()
+			}
+		}
+	}()
 	for {
 		interfacesAdded, InterfacesDeprecated, err := awsTCPDump.GetInterfaceChanges(awsTCPDump.Config, &networkInterfaces, subnetId)
 		lg.InfoF("Subnet %s Added intefaces: %d Deprecated interfaces: %d ", subnetId, len(interfacesAdded), len(InterfacesDeprecated))
@@ -365,9 +389,11 @@ func (awsTCPDump *AWSTCPDump) StartSubnetInterfacesRecording(workPool *chan bool
 		if awsTCPDump.Config.LiveRecording {
 			for _, ec2InterfaceId := range interfacesAdded {
 
-				ctx, cancel := context.WithCancel(context.Background())
+				ctx, cancel := context.WithCancel(recording)
 				networkInterfaces[ec2InterfaceId] = cancel
-				go awsTCPDump.StartInterfaceRecording(workPool, ec2InterfaceId, subnetId, subnetLogGroupName, &ctx)
+				awsTCPDump.startRecorder(func() error {
+					return awsTCPDump.StartInterfaceRecording(workPool, ec2InterfaceId, subnetId, subnetLogGroupName, &ctx)
+				})
 
 			}
 
@@ -385,36 +411,30 @@ func (awsTCPDump *AWSTCPDump) StartSubnetInterfacesRecording(workPool *chan bool
 
 		lg.InfoF("Subnet %s Current: %d interfaces", subnetId, len(networkInterfaces))
 
-		if awsTCPDump.Done {
+		if awsTCPDump.Done.Load() {
+			return nil
+		}
+		select {
+		case <-recording.Done():
 			return nil
+		case <-time.After(30 * time.Second):
 		}
-		time.Sleep(30 * time.Second)
 	}
 }
 
 func (awsTCPDump *AWSTCPDump) GetSubnetInterfacesFromAPI(subnetId string) []*ec2.NetworkInterface {
 
-	api := clients.EC2APINew(&awsTCPDump.Config.Region, &awsTCPDump.Config.AWSProfile)
+	api := awsTCPDump.getClients().EC2(&awsTCPDump.Config.Region)
 	Filters := []*ec2.Filter{{
 		Name:   aws.String("subnet-id"), // Filter by resource ID
 		Values: []*string{&subnetId},
 	}}
 	describeNetworkInterfacesInput := ec2.DescribeNetworkInterfacesInput{Filters: Filters}
 
-	objects := make([]any, 0)
-	err := api.GetNetworkInterfaces(clients.AggregatorInitializer(&objects), &describeNetworkInterfacesInput)
+	ret, err := clients.Collect(api.IterNetworkInterfaces(context.Background(), &describeNetworkInterfacesInput))
 	if err != nil {
 		lg.InfoF("call GetSubnetInterfaceIds(%s, %s)->DescribeNetworkInterfaces %v", awsTCPDump.Config.Region, subnetId, err)
 	}
-	ret := []*ec2.NetworkInterface{}
-	for _, anyObject := range objects {
-		nInt, ok := anyObject.(*ec2.NetworkInterface)
-		if !ok {
-			panic(anyObject)
-		}
-		ret = append(ret, nInt)
-
-	}
 	return ret
 }
 
@@ -424,11 +444,14 @@ func PrintInterfaceDescription(config *AWSTCPDumpConfig, interfaceId *string) er
 
 	describeNetworkInterfacesInput := ec2.DescribeNetworkInterfacesInput{NetworkInterfaceIds: values}
 
-	objects := make([]any, 0)
-	err := api.GetNetworkInterfaces(clients.AggregatorInitializer(&objects), &describeNetworkInterfacesInput)
+	networkInterfaces, err := clients.Collect(api.IterNetworkInterfaces(context.Background(), &describeNetworkInterfacesInput))
 	if err != nil {
 		return err
 	}
+	for _, networkInterface := range networkInterfaces {
+		resource := ResourceFromNetworkInterface(networkInterface, nil)
+		lg.InfoF("%s: %s %s/%s", *interfaceId, resource, resource.Kind, resource.ID)
+	}
 	return nil
 }
 
@@ -436,12 +459,11 @@ func PrintInterfaceDescription(config *AWSTCPDumpConfig, interfaceId *string) er
 // ${version} ${account-id} ${interface-id} ${srcaddr} ${dstaddr} ${srcport} ${dstport} ${protocol} ${packets} ${bytes} ${start} ${end} ${action} ${log-status}
 func (awsTCPDump *AWSTCPDump) StartInterfaceRecording(workPool *chan bool, interId, subnetId, subnetLogGroupName string, ctx *context.Context) error {
 
-	api := clients.CloudwatchLogsAPINew(&awsTCPDump.Config.Region, nil)
-	objects := make([]any, 0)
-	err := api.YieldCloudwatchLogStreams(&cloudwatchlogs.DescribeLogStreamsInput{
+	api := awsTCPDump.getClients().CloudwatchLogs(&awsTCPDump.Config.Region)
+	objects, err := clients.Collect(api.IterLogStreams(context.Background(), &cloudwatchlogs.DescribeLogStreamsInput{
 		LogGroupName:        &subnetLogGroupName,
 		LogStreamNamePrefix: &interId,
-	}, clients.AggregatorInitializerNG(&objects))
+	}))
 	if err != nil {
 		return err
 	}
@@ -450,10 +472,7 @@ func (awsTCPDump *AWSTCPDump) StartInterfaceRecording(workPool *chan bool, inter
 		return fmt.Errorf("expected to find single stream by interface prefix '%s' but found %d", interId, len(objects))
 	}
 
-	stream, ok := objects[0].(*cloudwatchlogs.LogStream)
-	if !ok {
-		return fmt.Errorf("expected  '%v' ", objects[0])
-	}
+	stream := objects[0]
 
 	var nextToken *string
 
@@ -467,7 +486,7 @@ func (awsTCPDump *AWSTCPDump) StartInterfaceRecording(workPool *chan bool, inter
 			pEpochStartMiliSeconds = nil
 		}
 		*workPool <- true
-		lastResp, err := clients.YieldCloudwatchLogStream(&awsTCPDump.Config.Region, &subnetLogGroupName, stream.LogStreamName, nextToken, pEpochStartMiliSeconds, nil, awsTCPDump.FlowLogEventsProcessRoutine)
+		lastResp, err := clients.YieldCloudwatchLogStream(&awsTCPDump.Config.Region, &subnetLogGroupName, stream.LogStreamName, nextToken, pEpochStartMiliSeconds, nil, awsTCPDump.SubnetFlowLogEventsProcessRoutine(subnetId))
 		<-*workPool
 
 		if err != nil {
@@ -475,7 +494,7 @@ func (awsTCPDump *AWSTCPDump) StartInterfaceRecording(workPool *chan bool, inter
 			time.Sleep(5 * time.Second)
 
 			//todo: check this logic:
-			awsTCPDump.Done = true
+			awsTCPDump.Done.Store(true)
 		}
 
 		if lastResp != nil {
@@ -486,8 +505,7 @@ func (awsTCPDump *AWSTCPDump) StartInterfaceRecording(workPool *chan bool, inter
 		case <-(*ctx).Done():
 			lg.InfoF("stopped interface recording: %s", interId)
 			return nil
-		default:
-			time.Sleep(5 * time.Second)
+		case <-time.After(5 * time.Second):
 		}
 	}
 }
@@ -560,53 +578,42 @@ type interfaceDataCollector struct {
 	MinMinuteBytesOut       uint64
 	TotalBytesOut           uint64
 	TotaldurationSecondsOut uint64
+
+	// Public to public flows: elastic ip traffic of the interface, or traffic routed through it.
+	TotalBytesExternal uint64
 }
 
 // Summarize the sent and received traffic into data collector.
 func (awsTCPDump *AWSTCPDump) FlowLogEventsBytesSummarizerHandler(dataCollector *interfaceDataCollector) func(*cloudwatchlogs.OutputLogEvent) error {
 	return func(event *cloudwatchlogs.OutputLogEvent) error {
 		dataCollectorPrev := *dataCollector
-		if strings.Contains(*event.Message, "NODATA") {
-			return nil
+		flowLogEvent, err := awsTCPDump.ParseEvent(event)
+		if err != nil {
+			return err
 		}
-		//fmt.Println("  ", *event.Message)
-		stringSplit := strings.Split(*event.Message, " ")
-		srcaddr := stringSplit[3]
-		dstaddr := stringSplit[4]
-		ipSrc := net.ParseIP(srcaddr)
-		ipDst := net.ParseIP(dstaddr)
+		ipSrc := flowLogEvent.SrcAddr
+		ipDst := flowLogEvent.DstAddr
+		// NODATA and SKIPDATA records have no addresses, as the custom formats without them.
 		if ipSrc == nil || ipDst == nil {
-			return fmt.Errorf("srcaddr: %v, dstaddr: %v ", srcaddr, dstaddr)
+			return nil
 		}
 
 		if ipSrc.IsPrivate() && ipDst.IsPrivate() {
 			return nil
 		}
-		if !ipSrc.IsPrivate() && !ipDst.IsPrivate() {
-			return fmt.Errorf("public to public not supported yet: %s", *event.Message)
-		}
-
-		bytes, err := strconv.Atoi(stringSplit[9])
-		if err != nil {
-			return err
-		}
-
-		secondsStart, err := strconv.Atoi(stringSplit[10])
-		if err != nil {
-			return err
-		}
 
-		secondsEnd, err := strconv.Atoi(stringSplit[11])
-		if err != nil {
-			return err
-		}
+		bytes := flowLogEvent.Bytes
+		secondsStart := flowLogEvent.Start
+		secondsEnd := flowLogEvent.End
 		secondsDuration := uint64(secondsEnd) - uint64(secondsStart)
 		if secondsDuration == 0 {
 			secondsDuration = 1
 		}
 		minuteBytes := uint64(bytes*60) / secondsDuration
 
-		if ipSrc.IsPrivate() {
+		if !ipSrc.IsPrivate() && !ipDst.IsPrivate() {
+			dataCollector.TotalBytesExternal += uint64(bytes)
+		} else if ipSrc.IsPrivate() {
 			dataCollector.MaxMinuteBytesOut = max(uint64(minuteBytes), dataCollector.MaxMinuteBytesOut)
 			dataCollector.MinMinuteBytesOut = min(uint64(minuteBytes), dataCollector.MinMinuteBytesOut)
 			dataCollector.TotalBytesOut += uint64(bytes)
@@ -630,6 +637,7 @@ func (awsTCPDump *AWSTCPDump) FlowLogEventsBytesSummarizerHandler(dataCollector
 				"MaxMinuteBytesOut":       dataCollector.MaxMinuteBytesOut,
 				"TotalBytesOut":           dataCollector.TotalBytesOut,
 				"TotaldurationSecondsOut": dataCollector.TotaldurationSecondsOut,
+				"TotalBytesExternal":      dataCollector.TotalBytesExternal,
 			}
 			awsTCPDump.JsonLogger.InfoM(output)
 		}
@@ -653,6 +661,30 @@ type FlowLogEvent struct {
 	End         int
 	Action      string
 	LogStatus   string
+
+	// v3
+	VpcID      string `json:",omitempty"`
+	SubnetID   string `json:",omitempty"`
+	InstanceID string `json:",omitempty"`
+	TCPFlags   int    `json:",omitempty"`
+	Type       string `json:",omitempty"`
+	PktSrcAddr net.IP `json:",omitempty"`
+	PktDstAddr net.IP `json:",omitempty"`
+	// v4
+	Region          string `json:",omitempty"`
+	AzID            string `json:",omitempty"`
+	SublocationType string `json:",omitempty"`
+	SublocationID   string `json:",omitempty"`
+	// v5
+	PktSrcAWSService string `json:",omitempty"`
+	PktDstAWSService string `json:",omitempty"`
+	FlowDirection    string `json:",omitempty"`
+	TrafficPath      int    `json:",omitempty"`
+
+	// Set by FlowLogEnricher: owners of the interface and of the addresses.
+	Resource    *FlowLogResource `json:",omitempty"`
+	SrcResource *FlowLogResource `json:",omitempty"`
+	DstResource *FlowLogResource `json:",omitempty"`
 }
 
 func (awsTCPDump *AWSTCPDump) EventsEchoFilter(event *FlowLogEvent) (*FlowLogEvent, error) {
@@ -665,84 +697,55 @@ func (awsTCPDump *AWSTCPDump) FlowLogEventsProcessRoutine(CloudwatchEvent *cloud
 	if err != nil {
 		return err
 	}
+	return awsTCPDump.processFlowLogEvent(event)
+}
+
+// SubnetFlowLogEventsProcessRoutine parses the subnet records with the format of its flow log.
+func (awsTCPDump *AWSTCPDump) SubnetFlowLogEventsProcessRoutine(subnetId string) func(*cloudwatchlogs.OutputLogEvent) error {
+	format, found := awsTCPDump.subnetFormats[subnetId]
+	if !found {
+		return awsTCPDump.FlowLogEventsProcessRoutine
+	}
+	return func(CloudwatchEvent *cloudwatchlogs.OutputLogEvent) error {
+		event, err := format.Parse(*CloudwatchEvent.Message)
+		if err != nil {
+			return err
+		}
+		return awsTCPDump.processFlowLogEvent(event)
+	}
+}
 
-	event, err = awsTCPDump.EventsFilter(event)
+func (awsTCPDump *AWSTCPDump) processFlowLogEvent(event *FlowLogEvent) error {
+	event, err := awsTCPDump.EventsFilter(event)
 	if err != nil {
 		return err
 	}
 
 	if event != nil {
+		awsTCPDump.Enricher.Enrich(event)
 		return awsTCPDump.EventProcessor(event)
 	}
 	return nil
 }
 
-// Summarize the sent and received traffic into data collector.
+// ParseEvent parses the record by Config.LogFormat, the default v2 format when not set.
 func (awsTCPDump *AWSTCPDump) ParseEvent(event *cloudwatchlogs.OutputLogEvent) (*FlowLogEvent, error) {
-	// ${version} ${account-id} ${interface-id} ${srcaddr} ${dstaddr} ${srcport} ${dstport} ${protocol} ${packets} ${bytes} ${start} ${end} ${action} ${log-status}
-
-	retFlowEvent := &FlowLogEvent{}
-	stringSplit := strings.Split(*event.Message, " ")
-	retFlowEvent.Version = stringSplit[0]
-	retFlowEvent.AccoundID = stringSplit[1]
-	retFlowEvent.InterfaceID = stringSplit[2]
-	secondsStart, err := strconv.Atoi(stringSplit[10])
-	if err != nil {
-		return nil, err
-	}
-	retFlowEvent.Start = secondsStart
-
-	secondsEnd, err := strconv.Atoi(stringSplit[11])
-	if err != nil {
-		return nil, err
-	}
-	retFlowEvent.End = secondsEnd
-	retFlowEvent.LogStatus = stringSplit[13]
-
-	if strings.Contains(*event.Message, "NODATA") {
-		return retFlowEvent, nil
-	}
-
-	//fmt.Println("  ", *event.Message)
-
-	ipSrc := net.ParseIP(stringSplit[3])
-	ipDst := net.ParseIP(stringSplit[4])
-	if ipSrc == nil || ipDst == nil {
-		return nil, fmt.Errorf("srcaddr: %v, dstaddr: %v ", stringSplit[3], stringSplit[4])
-	}
-
-	retFlowEvent.SrcAddr = ipSrc
-	retFlowEvent.DstAddr = ipDst
-
-	srcPort, err := strconv.Atoi(stringSplit[5])
-	if err != nil {
-		return nil, err
-	}
-	retFlowEvent.SrcPort = srcPort
-
-	dstPort, err := strconv.Atoi(stringSplit[6])
-	if err != nil {
-		return nil, err
-	}
-	retFlowEvent.DstPort = dstPort
-
-	retFlowEvent.Protocol = stringSplit[7]
-
-	packets, err := strconv.Atoi(stringSplit[8])
-	if err != nil {
-		return nil, err
-	}
-	retFlowEvent.Packets = packets
-
-	bytes, err := strconv.Atoi(stringSplit[9])
+	format, err := awsTCPDump.getFlowLogFormat()
 	if err != nil {
 		return nil, err
 	}
-	retFlowEvent.Bytes = bytes
-
-	retFlowEvent.Action = stringSplit[12]
+	return format.Parse(*event.Message)
+}
 
-	return retFlowEvent, nil
+func (awsTCPDump *AWSTCPDump) getFlowLogFormat() (*FlowLogFormat, error) {
+	awsTCPDump.formatOnce.Do(func() {
+		logFormat := DefaultFlowLogFormat
+		if awsTCPDump.Config != nil && awsTCPDump.Config.LogFormat != "" {
+			logFormat = awsTCPDump.Config.LogFormat
+		}
+		awsTCPDump.format, awsTCPDump.formatErr = FlowLogFormatNew(logFormat)
+	})
+	return awsTCPDump.format, awsTCPDump.formatErr
 }
 
 func (awsTCPDump *AWSTCPDump) EventsEchoWriter(event *FlowLogEvent) error {
@@ -796,24 +799,54 @@ func (awsTCPDump *AWSTCPDump) EventsEchoWriterUTCTime(event *FlowLogEvent) error
 	return nil
 }
 
+// GenerateSubnetFilter keeps the events sent to the subnets, invalid CIDRs fail every event.
+// No subnets keep all the events.
 func (awsTCPDump *AWSTCPDump) GenerateSubnetFilter(subnetStrings []string) func(*FlowLogEvent) (*FlowLogEvent, error) {
-	return func(event *FlowLogEvent) (*FlowLogEvent, error) {
-		CIDRSubnets := []*net.IPNet{}
-		for _, subnetString := range subnetStrings {
-			_, netSrc, err := net.ParseCIDR(subnetString)
-
-			CIDRSubnets = append(CIDRSubnets, netSrc)
-			if err != nil {
-				return nil, nil
-			}
+	primitives := []string{}
+	for _, subnetString := range subnetStrings {
+		if subnetString == "" {
+			continue
 		}
+		primitives = append(primitives, "dst net "+subnetString)
+	}
+	filter, err := FlowLogFilterNew(strings.Join(primitives, " or "))
+	if err != nil {
+		return func(event *FlowLogEvent) (*FlowLogEvent, error) {
+			return nil, err
+		}
+	}
+	return filter.EventsFilter
+}
 
-		for _, CIDRSubnet := range CIDRSubnets {
-
-			if CIDRSubnet.Contains(event.DstAddr) {
-				return event, nil
+// FilterExpression combines Config.AddrFilters and Config.Filter: any of the addresses and the filter.
+func (awsTCPDump *AWSTCPDump) FilterExpression() string {
+	expressions := []string{}
+	if len(awsTCPDump.Config.AddrFilters) > 0 {
+		hosts := []string{}
+		for _, addr := range awsTCPDump.Config.AddrFilters {
+			if strings.Contains(addr, "/") {
+				hosts = append(hosts, "net "+addr)
+			} else {
+				hosts = append(hosts, "host "+addr)
 			}
 		}
-		return event, nil
+		expressions = append(expressions, "("+strings.Join(hosts, " or ")+")")
+	}
+	if strings.TrimSpace(awsTCPDump.Config.Filter) != "" {
+		expressions = append(expressions, "("+awsTCPDump.Config.Filter+")")
 	}
+	return strings.Join(expressions, " and ")
+}
+
+// CompileEventsFilter sets EventsFilter to the compiled FilterExpression, unless already set.
+func (awsTCPDump *AWSTCPDump) CompileEventsFilter() error {
+	if awsTCPDump.EventsFilter != nil {
+		return nil
+	}
+	filter, err := FlowLogFilterNew(awsTCPDump.FilterExpression())
+	if err != nil {
+		return err
+	}
+	awsTCPDump.EventsFilter = filter.EventsFilter
+	return nil
 }
@@ -0,0 +1,306 @@
+package aws_api
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Read when neither -config nor AWS_TCPDUMP_CONFIG is set, a missing default file is not an error.
+const DefaultTCPDumpConfigFilePath = "/opt/aws_api/tcpdump/data/tcpdump.conf"
+
+// Environment variables are named by the config keys, e.g. AWS_TCPDUMP_IAM_DATA_DIR_PATH for IamDataDirPath.
+const tcpdumpEnvPrefix = "AWS_TCPDUMP_"
+
+// configFlag maps a command line flag to the AWSTCPDumpConfig key it overrides.
+type configFlag struct {
+	Name  string
+	Key   string
+	Usage string
+}
+
+var configFlags = []*configFlag{
+	{"region", "Region", "AWS region"},
+	{"profile", "AWSProfile", "AWS profile"},
+	{"subnets", "Subnets", "Comma separated subnets to record"},
+	{"live", "LiveRecording", "Live traffic recording"},
+	{"addr", "AddrFilters", "Comma separated addresses to filter"},
+	{"files", "OfflinePaths", "Comma separated flow log files or directories to analyze"},
+	{"format", "LogFormat", "Flow log format, e.g. '${vpc-id} ${srcaddr} ${flow-direction}'"},
+	{"filter", "Filter", "Filter expression, e.g. 'src net 10.0.0.0/8 and dst port 443'"},
+	{"enrich", "Enrich", "Annotate events with the owning instances, tasks, functions and load balancers"},
+	{"dns", "EnrichDNS", "Name the addresses by Route53 private zones records"},
+	{"top", "TopN", "Top conversations per table of the traffic report, no report when 0"},
+	{"bucket", "BucketSeconds", "Traffic time bucket size in seconds"},
+	{"report", "TrafficReportFilePath", "Traffic JSON report file path"},
+	{"chart", "TrafficChartFilePath", "Traffic chart file path"},
+	{"sg-plan", "SecurityGroupsPlanFilePath", "Security groups analysis JSON plan file path"},
+	{"sg-window", "SecurityGroupsWindowHours", "Security groups analysis of the last hours flows"},
+	{"sessions-dir", "SessionsDirPath", "Recording sessions manifests directory"},
+	{"delete-log-groups", "DeleteLogGroups", "Delete the session log groups on cleanup"},
+	{"iam-data-dir", "IamDataDirPath", "IAM role templates directory"},
+	{"log-file", "LogOutputFilePath", "Log file path"},
+	{"export", "ExportFilePath", "Export the events to a Zeek conn.log, .json for Zeek JSON, or to a .pcapng file"},
+	{"export-format", "ExportFormat", "Export format: zeek, zeek-json or pcapng, by the export file extension when not set"},
+}
+
+// tcpdumpCommand is a CLI subcommand with the flags it accepts, -config is accepted by all.
+type tcpdumpCommand struct {
+	Usage string
+	Flags []string
+}
+
+var tcpdumpCommands = map[string]*tcpdumpCommand{
+	"record": {Usage: "record [flags]: provision the subnets flow logs and record the traffic",
+		Flags: []string{"region", "profile", "subnets", "live", "addr", "format", "filter", "enrich", "dns", "top", "bucket", "report", "chart",
+			"sessions-dir", "delete-log-groups", "iam-data-dir", "log-file", "export", "export-format"}},
+	"analyze": {Usage: "analyze [flags] [files...]: analyze flow log files offline",
+		Flags: []string{"region", "profile", "files", "format", "filter", "enrich", "dns", "top", "bucket", "report", "chart", "sg-plan", "sg-window", "log-file",
+			"export", "export-format"}},
+	"sessions": {Usage: "sessions [flags]: list the recording sessions left to clean up",
+		Flags: []string{"sessions-dir"}},
+	"cleanup": {Usage: "cleanup [flags] <session>...: delete the resources of crashed recording sessions",
+		Flags: []string{"profile", "sessions-dir", "delete-log-groups", "iam-data-dir", "log-file"}},
+}
+
+// AWSTCPDumpCommand is a parsed command line: the subcommand, the merged configuration and the positional arguments.
+type AWSTCPDumpCommand struct {
+	Name   string
+	Config *AWSTCPDumpConfig
+	Args   []string
+}
+
+// WriteCommandsUsage lists the subcommands.
+func WriteCommandsUsage(writer io.Writer) {
+	fmt.Fprintln(writer, "Usage: aws_tcpdump <command> [flags]")
+	names := []string{}
+	for name := range tcpdumpCommands {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		fmt.Fprintf(writer, "  %s\n", tcpdumpCommands[name].Usage)
+	}
+}
+
+// ParseCommand parses "<command> [flags] [args...]", the configuration is merged from the file,
+// the environment read by getenv and the flags, later sources take precedence.
+func ParseCommand(args []string, getenv func(string) string) (*AWSTCPDumpCommand, error) {
+	errorPrefix := "[aws_tcpdump_config:ParseCommand]"
+	if len(args) == 0 {
+		return nil, fmt.Errorf("%s command is missing", errorPrefix)
+	}
+	command, found := tcpdumpCommands[args[0]]
+	if !found {
+		return nil, fmt.Errorf("%s unknown command: %s", errorPrefix, args[0])
+	}
+
+	flagset := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	flagset.Usage = func() {
+		fmt.Fprintf(flagset.Output(), "Usage: aws_tcpdump %s\n", command.Usage)
+		flagset.PrintDefaults()
+	}
+	config, err := parseConfig(flagset, command.Flags, args[1:], getenv)
+	if err != nil {
+		return nil, err
+	}
+	ret := &AWSTCPDumpCommand{Name: args[0], Config: config, Args: flagset.Args()}
+
+	switch ret.Name {
+	case "record":
+		if config.Region == "" || len(config.Subnets) == 0 {
+			return nil, fmt.Errorf("%s record requires the region and the subnets", errorPrefix)
+		}
+	case "analyze":
+		config.OfflinePaths = append(config.OfflinePaths, ret.Args...)
+		if len(config.OfflinePaths) == 0 {
+			return nil, fmt.Errorf("%s analyze requires flow log files", errorPrefix)
+		}
+		if config.SecurityGroupsPlanFilePath != "" && config.TopN > 0 {
+			return nil, fmt.Errorf("%s analyze writes either the security groups plan or the traffic report, not both", errorPrefix)
+		}
+	case "cleanup":
+		if len(ret.Args) == 0 {
+			return nil, fmt.Errorf("%s cleanup requires session IDs", errorPrefix)
+		}
+	}
+	return ret, nil
+}
+
+// ParseArgs merges the configuration file, the environment and all the config flags.
+func (AwsTCPDump *AWSTCPDump) ParseArgs(flagset *flag.FlagSet, args []string) (*AWSTCPDumpConfig, error) {
+	flagNames := []string{}
+	for _, current := range configFlags {
+		flagNames = append(flagNames, current.Name)
+	}
+	return parseConfig(flagset, flagNames, args, os.Getenv)
+}
+
+func parseConfig(flagset *flag.FlagSet, flagNames []string, args []string, getenv func(string) string) (*AWSTCPDumpConfig, error) {
+	errorPrefix := "[aws_tcpdump_config:parseConfig]"
+	configPath := flagset.String("config", "", "Configuration file path, "+DefaultTCPDumpConfigFilePath+" by default")
+	keys := map[string]string{}
+	for _, current := range configFlags {
+		if !slices.Contains(flagNames, current.Name) {
+			continue
+		}
+		keys[current.Name] = current.Key
+		switch reflect.ValueOf(AWSTCPDumpConfig{}).FieldByName(current.Key).Kind() {
+		case reflect.Bool:
+			flagset.Bool(current.Name, false, current.Usage)
+		case reflect.Int:
+			flagset.Int(current.Name, 0, current.Usage)
+		default:
+			flagset.String(current.Name, "", current.Usage)
+		}
+	}
+
+	if err := flagset.Parse(args); err != nil {
+		return nil, err
+	}
+
+	config := &AWSTCPDumpConfig{}
+	filePath := *configPath
+	if filePath == "" {
+		filePath = getenv(tcpdumpEnvPrefix + "CONFIG")
+	}
+	if filePath != "" {
+		if err := loadConfigFile(config, filePath); err != nil {
+			return nil, err
+		}
+	} else if err := loadConfigFile(config, DefaultTCPDumpConfigFilePath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	for _, field := range reflect.VisibleFields(reflect.TypeOf(*config)) {
+		value := getenv(configEnvName(field.Name))
+		if value == "" {
+			continue
+		}
+		if err := setConfigValue(config, field.Name, value); err != nil {
+			return nil, fmt.Errorf("%s environment variable %s\n%w", errorPrefix, configEnvName(field.Name), err)
+		}
+	}
+
+	errs := []error{}
+	flagset.Visit(func(current *flag.Flag) {
+		key, found := keys[current.Name]
+		if !found {
+			return
+		}
+		if err := setConfigValue(config, key, current.Value.String()); err != nil {
+			errs = append(errs, fmt.Errorf("%s flag -%s\n%w", errorPrefix, current.Name, err))
+		}
+	})
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return config, nil
+}
+
+// LoadConfig reads a JSON configuration file, or a "Key = value" lines file with '#' comments.
+func (AwsTCPDump *AWSTCPDump) LoadConfig(filePath string) (*AWSTCPDumpConfig, error) {
+	config := &AWSTCPDumpConfig{}
+	if err := loadConfigFile(config, filePath); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// loadConfigFile sets the keys present in the file, the rest of the config is left as is.
+func loadConfigFile(config *AWSTCPDumpConfig, filePath string) error {
+	errorPrefix := "[aws_tcpdump_config:loadConfigFile]"
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(config); err != nil {
+			return fmt.Errorf("%s failed to parse %s\n%w", errorPrefix, filePath, err)
+		}
+		return nil
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, found := strings.Cut(line, "=")
+		if !found {
+			return fmt.Errorf("%s %s:%d: expected 'Key = value': %s", errorPrefix, filePath, lineNumber, line)
+		}
+		if err := setConfigValue(config, strings.TrimSpace(key), strings.TrimSpace(value)); err != nil {
+			return fmt.Errorf("%s %s:%d\n%w", errorPrefix, filePath, lineNumber, err)
+		}
+	}
+	return scanner.Err()
+}
+
+// setConfigValue sets the key from its text: lists are comma separated, other non scalar keys are JSON.
+func setConfigValue(config *AWSTCPDumpConfig, key, value string) error {
+	field := reflect.ValueOf(config).Elem().FieldByName(key)
+	if !field.IsValid() {
+		return fmt.Errorf("unknown configuration key: %s", key)
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		field.SetBool(parsed)
+	case reflect.Int:
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		field.SetInt(int64(parsed))
+	default:
+		if field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.String {
+			items := []string{}
+			for _, item := range strings.Split(value, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					items = append(items, item)
+				}
+			}
+			field.Set(reflect.ValueOf(items))
+			return nil
+		}
+		if err := json.Unmarshal([]byte(value), field.Addr().Interface()); err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// configEnvName converts the key to upper snake case: EnrichDNS is AWS_TCPDUMP_ENRICH_DNS.
+func configEnvName(key string) string {
+	runes := []rune(key)
+	name := []rune{}
+	for index, current := range runes {
+		if index > 0 && unicode.IsUpper(current) &&
+			(unicode.IsLower(runes[index-1]) || index+1 < len(runes) && unicode.IsLower(runes[index+1])) {
+			name = append(name, '_')
+		}
+		name = append(name, unicode.ToUpper(current))
+	}
+	return tcpdumpEnvPrefix + string(name)
+}
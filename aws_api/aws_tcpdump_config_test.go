@@ -0,0 +1,189 @@
+package aws_api
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseArgs(t *testing.T) {
+	newAWSTcpDump := &AWSTCPDump{}
+	configDir := t.TempDir()
+	confFilePath := filepath.Join(configDir, "tcpdump.conf")
+	err := os.WriteFile(confFilePath, []byte("# comment\nRegion = us-east-1\nSubnets = subnet-1, subnet-2\nLiveRecording = true\nTopN = 10\n"), 0644)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	jsonFilePath := filepath.Join(configDir, "tcpdump.json")
+	err = os.WriteFile(jsonFilePath, []byte(`{"Region": "us-west-2", "ProposedRules": [{"GroupID": "sg-1", "Direction": "ingress"}]}`), 0644)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	// Keeps a tcpdump.conf installed in the default path out of the tests.
+	emptyFilePath := filepath.Join(configDir, "empty.conf")
+	err = os.WriteFile(emptyFilePath, []byte{}, 0644)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	invalidFilePath := filepath.Join(configDir, "invalid.conf")
+	err = os.WriteFile(invalidFilePath, []byte("OutputFilePath = /tmp/out.log\n"), 0644)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	tests := []struct {
+		name     string
+		args     []string
+		env      map[string]string
+		expected *AWSTCPDumpConfig
+		wantErr  bool
+		errStr   string // Expected error substring
+	}{
+		{
+			name:     "Default values",
+			args:     []string{},
+			expected: &AWSTCPDumpConfig{},
+			wantErr:  false,
+		}, {
+			name:     "subnets",
+			args:     []string{"--subnets", "sb-12345"},
+			expected: &AWSTCPDumpConfig{Subnets: []string{"sb-12345"}},
+			wantErr:  false,
+		}, {
+			name:     "profile",
+			args:     []string{"--subnets", "sb-12345", "--profile", "non-default"},
+			expected: &AWSTCPDumpConfig{Subnets: []string{"sb-12345"}, AWSProfile: "non-default"},
+			wantErr:  false,
+		}, {
+			name:     "live",
+			args:     []string{"--subnets", "sb-12345", "--live"},
+			expected: &AWSTCPDumpConfig{Subnets: []string{"sb-12345"}, LiveRecording: true},
+			wantErr:  false,
+		}, {
+			name:     "config file",
+			args:     []string{"-config", confFilePath},
+			expected: &AWSTCPDumpConfig{Region: "us-east-1", Subnets: []string{"subnet-1", "subnet-2"}, LiveRecording: true, TopN: 10},
+			wantErr:  false,
+		}, {
+			name: "JSON config file",
+			args: []string{"-config", jsonFilePath},
+			expected: &AWSTCPDumpConfig{Region: "us-west-2",
+				ProposedRules: []*ProposedSecurityGroupRule{{GroupID: "sg-1", SecurityGroupRule: SecurityGroupRule{Direction: DirectionIngress}}}},
+			wantErr: false,
+		}, {
+			name: "precedence",
+			args: []string{"-region", "eu-west-1", "-live=false"},
+			env: map[string]string{"AWS_TCPDUMP_CONFIG": confFilePath, "AWS_TCPDUMP_REGION": "us-east-2", "AWS_TCPDUMP_TOP_N": "3",
+				"AWS_TCPDUMP_ENRICH_DNS": "true"},
+			expected: &AWSTCPDumpConfig{Region: "eu-west-1", Subnets: []string{"subnet-1", "subnet-2"}, TopN: 3, EnrichDNS: true},
+			wantErr:  false,
+		}, {
+			name:    "missing config file",
+			args:    []string{"-config", filepath.Join(configDir, "missing.conf")},
+			wantErr: true,
+			errStr:  "no such file",
+		}, {
+			name:    "unknown key",
+			args:    []string{"-config", invalidFilePath},
+			wantErr: true,
+			errStr:  "unknown configuration key: OutputFilePath",
+		}, {
+			name:    "invalid environment",
+			args:    []string{},
+			env:     map[string]string{"AWS_TCPDUMP_LIVE_RECORDING": "sometimes"},
+			wantErr: true,
+			errStr:  "AWS_TCPDUMP_LIVE_RECORDING",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("AWS_TCPDUMP_CONFIG", emptyFilePath)
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			// Create a new FlagSet for each test to ensure isolation
+			fs := flag.NewFlagSet("test-"+tt.name, flag.ContinueOnError) // flag.ContinueOnError prevents os.Exit(2)
+
+			// Suppress flag package output during tests, as it prints to stderr by default
+			// This makes test output cleaner.
+			fs.SetOutput(new(strings.Builder))
+
+			cfg, err := newAWSTcpDump.ParseArgs(fs, tt.args)
+
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseFlags() error = %v, wantErr %t", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), tt.errStr) {
+					t.Errorf("ParseFlags() error = %q, want error containing %q", err.Error(), tt.errStr)
+				}
+				return
+			}
+
+			if !reflect.DeepEqual(cfg, tt.expected) {
+				t.Errorf("ParseFlags() got = %+v, want %+v", cfg, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseCommand(t *testing.T) {
+	getenv := func(env map[string]string) func(string) string {
+		return func(key string) string {
+			if key == "AWS_TCPDUMP_CONFIG" {
+				return os.DevNull
+			}
+			return env[key]
+		}
+	}
+
+	t.Run("Valid run", func(t *testing.T) {
+		command, err := ParseCommand([]string{"analyze", "-top", "5", "a.log", "b.log"}, getenv(map[string]string{"AWS_TCPDUMP_OFFLINE_PATHS": "c.log"}))
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if command.Name != "analyze" || command.Config.TopN != 5 || !reflect.DeepEqual(command.Config.OfflinePaths, []string{"c.log", "a.log", "b.log"}) {
+			t.Errorf("unexpected command: %+v %+v", command, command.Config)
+		}
+
+		command, err = ParseCommand([]string{"cleanup", "-delete-log-groups", "tcpdump-1", "tcpdump-2"}, getenv(nil))
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if !command.Config.DeleteLogGroups || !reflect.DeepEqual(command.Args, []string{"tcpdump-1", "tcpdump-2"}) {
+			t.Errorf("unexpected command: %+v %+v", command, command.Config)
+		}
+
+		command, err = ParseCommand([]string{"record"}, getenv(map[string]string{"AWS_TCPDUMP_REGION": "us-east-1", "AWS_TCPDUMP_SUBNETS": "subnet-1"}))
+		if err != nil || command.Config.Region != "us-east-1" {
+			t.Errorf("unexpected command: %v %+v", err, command)
+		}
+
+		defer func(fileDst string) { lg.FileDst = fileDst }(lg.FileDst)
+		awsTCPDump, err := AWSTCPDumpNew(command.Config)
+		if err != nil || awsTCPDump.Config != command.Config || awsTCPDump.Config.IamDataDirPath == "" {
+			t.Errorf("unexpected tcpdump: %v %+v", err, awsTCPDump)
+		}
+	})
+
+	t.Run("Invalid commands", func(t *testing.T) {
+		for _, args := range [][]string{
+			{},
+			{"start"},
+			{"record", "-region", "us-east-1"},
+			{"analyze"},
+			{"analyze", "-sg-plan", "plan.json", "-top", "5", "flows.log"},
+			{"cleanup"},
+			{"sessions", "-subnets", "subnet-1"},
+		} {
+			if _, err := ParseCommand(args, getenv(nil)); err == nil {
+				t.Errorf("expected an error: %v", args)
+			}
+		}
+	})
+}
@@ -0,0 +1,212 @@
+package aws_api
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+)
+
+// Flow log lines are short, the buffer only protects against corrupted files.
+const offlineMaxLineSize = 1024 * 1024
+
+// OfflineReport summarizes an offline flow log analysis.
+type OfflineReport struct {
+	Files    []string
+	Lines    int
+	Headers  int
+	Events   int
+	Filtered int
+	Errors   []error
+}
+
+func (report *OfflineReport) String() string {
+	return fmt.Sprintf("files: %d, lines: %d, headers: %d, events: %d, filtered out: %d, errors: %d",
+		len(report.Files), report.Lines, report.Headers, report.Events, report.Filtered, len(report.Errors))
+}
+
+// OfflineFlowLogFiles lists the flow log files under the paths.
+// Directories are walked recursively, e.g. an S3 export: AWSLogs/<account>/vpcflowlogs/<region>/<yyyy>/<mm>/<dd>/*.log.gz.
+// Files of a directory are sorted by path, S3 export keys sort by time.
+func OfflineFlowLogFiles(paths []string) ([]string, error) {
+	errorPrefix := "[aws_tcpdump_offline:OfflineFlowLogFiles]"
+	ret := []string{}
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s failed to stat %s\n%w", errorPrefix, path, err)
+		}
+		if !info.IsDir() {
+			ret = append(ret, path)
+			continue
+		}
+
+		dirFiles := []string{}
+		err = filepath.WalkDir(path, func(filePath string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+				return nil
+			}
+			dirFiles = append(dirFiles, filePath)
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("%s failed to walk %s\n%w", errorPrefix, path, err)
+		}
+		sort.Strings(dirFiles)
+		ret = append(ret, dirFiles...)
+	}
+	return ret, nil
+}
+
+// openFlowLogFile opens plain or gzip compressed files, gzip is detected by its magic bytes.
+func openFlowLogFile(filePath string) (io.ReadCloser, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := bufio.NewReader(file)
+	magic, err := reader.Peek(2)
+	if err != nil && err != io.EOF {
+		file.Close()
+		return nil, err
+	}
+	if len(magic) < 2 || magic[0] != 0x1f || magic[1] != 0x8b {
+		return struct {
+			io.Reader
+			io.Closer
+		}{reader, file}, nil
+	}
+
+	gzipReader, err := gzip.NewReader(reader)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{gzipReader, closerFunc(func() error {
+		return errors.Join(gzipReader.Close(), file.Close())
+	})}, nil
+}
+
+type closerFunc func() error
+
+func (fn closerFunc) Close() error {
+	return fn()
+}
+
+// isFlowLogHeader detects the field names line S3 exports start with, no record value is a field name.
+func isFlowLogHeader(line string) bool {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return false
+	}
+	_, found := flowLogFields[fields[0]]
+	return found
+}
+
+// AnalyzeFlowLogFiles runs the flow log records from local files through ParseEvent -> EventsFilter -> Enricher -> EventProcessor.
+// Files with a header line, as S3 exports, are parsed by the header fields instead of Config.LogFormat.
+// Malformed records are reported with their file and line and do not stop the analysis.
+func (awsTCPDump *AWSTCPDump) AnalyzeFlowLogFiles(paths []string) (*OfflineReport, error) {
+	errorPrefix := "[aws_tcpdump_offline:AnalyzeFlowLogFiles]"
+	if awsTCPDump.EventProcessor == nil {
+		return nil, fmt.Errorf("%s event processor is not set", errorPrefix)
+	}
+	if err := awsTCPDump.CompileEventsFilter(); err != nil {
+		return nil, err
+	}
+
+	files, err := OfflineFlowLogFiles(paths)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &OfflineReport{Files: files}
+	for _, filePath := range files {
+		err := awsTCPDump.analyzeFlowLogFile(filePath, report)
+		if err != nil {
+			return report, fmt.Errorf("%s failed to read %s\n%w", errorPrefix, filePath, err)
+		}
+	}
+	return report, errors.Join(report.Errors...)
+}
+
+func (awsTCPDump *AWSTCPDump) analyzeFlowLogFile(filePath string, report *OfflineReport) error {
+	reader, err := openFlowLogFile(filePath)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), offlineMaxLineSize)
+	var format *FlowLogFormat
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		report.Lines++
+		if isFlowLogHeader(line) {
+			report.Headers++
+			format, err = FlowLogFormatFromHeader(line)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		var event *FlowLogEvent
+		if format != nil {
+			event, err = format.Parse(line)
+		} else {
+			event, err = awsTCPDump.ParseEvent(&cloudwatchlogs.OutputLogEvent{Message: &line})
+		}
+		if err == nil {
+			event, err = awsTCPDump.EventsFilter(event)
+		}
+		if err != nil {
+			report.Errors = append(report.Errors, fmt.Errorf("%s:%d: %w", filePath, lineNumber, err))
+			continue
+		}
+		if event == nil {
+			report.Filtered++
+			continue
+		}
+		report.Events++
+		awsTCPDump.Enricher.Enrich(event)
+		if err := awsTCPDump.EventProcessor(event); err != nil {
+			report.Errors = append(report.Errors, fmt.Errorf("%s:%d: %w", filePath, lineNumber, err))
+		}
+	}
+	return scanner.Err()
+}
+
+// StartOffline analyzes the Config.OfflinePaths files, AWS is accessed only to enrich the events by Config.Enrich.
+func (awsTCPDump *AWSTCPDump) StartOffline() error {
+	awsTCPDump.truncateOutputFiles()
+	if err := awsTCPDump.LoadEnricher(); err != nil {
+		return err
+	}
+	report, err := awsTCPDump.AnalyzeFlowLogFiles(awsTCPDump.Config.OfflinePaths)
+	if report != nil {
+		lg.InfoF("Offline flow logs analysis: %s", report)
+	}
+	return err
+}
@@ -0,0 +1,84 @@
+package aws_api
+
+import (
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFlowLogFile(t *testing.T, filePath string, compress bool, lines ...string) {
+	err := os.MkdirAll(filepath.Dir(filePath), 0755)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	file, err := os.Create(filePath)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	defer file.Close()
+
+	data := []byte(strings.Join(lines, "\n") + "\n")
+	if !compress {
+		_, err = file.Write(data)
+	} else {
+		writer := gzip.NewWriter(file)
+		_, err = writer.Write(data)
+		if err == nil {
+			err = writer.Close()
+		}
+	}
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+}
+
+func TestAnalyzeFlowLogFiles(t *testing.T) {
+	header := "version account-id interface-id srcaddr dstaddr srcport dstport protocol packets bytes start end action log-status"
+	dataDir := t.TempDir()
+	exportDir := filepath.Join(dataDir, "AWSLogs", "123456789012", "vpcflowlogs", "us-east-1", "2025", "01")
+	writeFlowLogFile(t, filepath.Join(exportDir, "02", "123456789012_vpcflowlogs_us-east-1_fl-1_20250102T0000Z_a1.log.gz"), true, header,
+		"2 123456789012 eni-1 10.0.0.5 203.0.113.7 443 51000 6 10 8000 1735776000 1735776060 ACCEPT OK",
+		"2 123456789012 eni-1 198.51.100.9 10.0.0.5 51001 22 6 1 40 1735776000 1735776060 REJECT OK")
+	writeFlowLogFile(t, filepath.Join(exportDir, "01", "123456789012_vpcflowlogs_us-east-1_fl-1_20250101T0000Z_b2.log.gz"), true, header,
+		"2 123456789012 eni-2 - - - - - - - 1735689600 1735689660 - NODATA")
+	plainFile := filepath.Join(dataDir, "saved.log")
+	writeFlowLogFile(t, plainFile, false,
+		"2 123456789012 eni-3 10.0.0.6 10.0.0.7 5432 40000 6 3 300 1735776000 1735776060 ACCEPT OK",
+		"2 123456789012 eni-3 truncated",
+		"")
+
+	t.Run("Valid run", func(t *testing.T) {
+		events := []*FlowLogEvent{}
+		awsTCPDump := &AWSTCPDump{Config: &AWSTCPDumpConfig{}}
+		awsTCPDump.EventsFilter = func(event *FlowLogEvent) (*FlowLogEvent, error) {
+			if event.Action == "REJECT" {
+				return nil, nil
+			}
+			return event, nil
+		}
+		awsTCPDump.EventProcessor = func(event *FlowLogEvent) error {
+			events = append(events, event)
+			return nil
+		}
+
+		report, err := awsTCPDump.AnalyzeFlowLogFiles([]string{filepath.Join(dataDir, "AWSLogs"), plainFile})
+		if err == nil || !strings.Contains(err.Error(), "saved.log:2: expected 14 flow log fields, found 4") {
+			t.Errorf("expected the truncated record error: %v", err)
+		}
+		if report.String() != "files: 3, lines: 7, headers: 2, events: 3, filtered out: 1, errors: 1" {
+			t.Errorf("unexpected report: %s", report)
+		}
+		if len(events) != 3 || events[0].LogStatus != "NODATA" || events[1].Bytes != 8000 || events[2].InterfaceID != "eni-3" {
+			t.Errorf("events must be processed in files order: %+v", events)
+		}
+	})
+
+	t.Run("Missing processor", func(t *testing.T) {
+		awsTCPDump := &AWSTCPDump{Config: &AWSTCPDumpConfig{}}
+		if _, err := awsTCPDump.AnalyzeFlowLogFiles([]string{plainFile}); err == nil {
+			t.Errorf("expected an error")
+		}
+	})
+}
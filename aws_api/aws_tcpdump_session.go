@@ -0,0 +1,325 @@
+package aws_api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	clients "github.com/AlexeyBeley/go_misc/aws_api/clients"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+// How long Shutdown waits for the recorders to stop before cleaning up.
+const recordersStopTimeout = 30 * time.Second
+
+// SessionRole is an IAM role created by a session, with its inline policies names.
+type SessionRole struct {
+	Name           string
+	Arn            string
+	InlinePolicies []string
+}
+
+// TCPDumpSession is the manifest of the resources a recording session created.
+// The manifest is saved on every change, so a crashed run can be cleaned up by its ID.
+// Methods of a nil session do nothing: provisioning outside a session is not tracked.
+type TCPDumpSession struct {
+	ID            string
+	Region        string
+	StartedAt     time.Time
+	FlowLogIds    []string
+	LogGroupNames []string
+	Roles         []*SessionRole
+
+	filePath string
+	lock     sync.Mutex
+}
+
+// Generator
+func TCPDumpSessionNew(sessionsDirPath, region string) (*TCPDumpSession, error) {
+	startedAt := time.Now().UTC()
+	session := &TCPDumpSession{ID: "tcpdump-" + startedAt.Format("20060102T150405Z"), Region: region, StartedAt: startedAt,
+		FlowLogIds: []string{}, LogGroupNames: []string{}, Roles: []*SessionRole{}}
+	session.filePath = filepath.Join(sessionsDirPath, session.ID+".json")
+	if _, err := os.Stat(session.filePath); err == nil {
+		return nil, fmt.Errorf("[aws_tcpdump_session:TCPDumpSessionNew] session manifest already exists: %s", session.filePath)
+	}
+	return session, session.save()
+}
+
+// TCPDumpSessionLoad reads the manifest of the session ID.
+func TCPDumpSessionLoad(sessionsDirPath, id string) (*TCPDumpSession, error) {
+	errorPrefix := "[aws_tcpdump_session:TCPDumpSessionLoad]"
+	filePath := filepath.Join(sessionsDirPath, id+".json")
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("%s failed to read the session manifest\n%w", errorPrefix, err)
+	}
+	session := &TCPDumpSession{}
+	if err := json.Unmarshal(data, session); err != nil {
+		return nil, fmt.Errorf("%s failed to parse %s\n%w", errorPrefix, filePath, err)
+	}
+	session.filePath = filePath
+	return session, nil
+}
+
+// TCPDumpSessionsList loads the manifests left in the directory, the oldest first.
+func TCPDumpSessionsList(sessionsDirPath string) ([]*TCPDumpSession, error) {
+	filePaths, err := filepath.Glob(filepath.Join(sessionsDirPath, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	ret := []*TCPDumpSession{}
+	for _, filePath := range filePaths {
+		session, err := TCPDumpSessionLoad(sessionsDirPath, strings.TrimSuffix(filepath.Base(filePath), ".json"))
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, session)
+	}
+	slices.SortFunc(ret, func(first, second *TCPDumpSession) int { return first.StartedAt.Compare(second.StartedAt) })
+	return ret, nil
+}
+
+// Empty when every resource of the session was cleaned up.
+func (session *TCPDumpSession) Empty() bool {
+	session.lock.Lock()
+	defer session.lock.Unlock()
+	return len(session.FlowLogIds) == 0 && len(session.LogGroupNames) == 0 && len(session.Roles) == 0
+}
+
+func (session *TCPDumpSession) String() string {
+	session.lock.Lock()
+	defer session.lock.Unlock()
+	return fmt.Sprintf("%s (%s): %d flow logs, %d log groups, %d roles", session.ID, session.Region,
+		len(session.FlowLogIds), len(session.LogGroupNames), len(session.Roles))
+}
+
+// save writes the manifest, the caller holds the lock unless the session is not shared yet.
+func (session *TCPDumpSession) save() error {
+	errorPrefix := "[aws_tcpdump_session:save]"
+	if err := os.MkdirAll(filepath.Dir(session.filePath), 0755); err != nil {
+		return fmt.Errorf("%s failed to create the sessions directory\n%w", errorPrefix, err)
+	}
+	data, err := json.MarshalIndent(session, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Written aside and renamed, an interrupted write does not lose the manifest.
+	tmpFilePath := session.filePath + ".tmp"
+	if err := os.WriteFile(tmpFilePath, data, 0644); err != nil {
+		return fmt.Errorf("%s failed to write %s\n%w", errorPrefix, tmpFilePath, err)
+	}
+	return os.Rename(tmpFilePath, session.filePath)
+}
+
+func (session *TCPDumpSession) update(change func()) error {
+	if session == nil {
+		return nil
+	}
+	session.lock.Lock()
+	defer session.lock.Unlock()
+	change()
+	return session.save()
+}
+
+func (session *TCPDumpSession) AddFlowLogs(flowLogIds []*string) error {
+	return session.update(func() {
+		session.FlowLogIds = append(session.FlowLogIds, aws.StringValueSlice(flowLogIds)...)
+	})
+}
+
+func (session *TCPDumpSession) AddLogGroup(logGroupName string) error {
+	return session.update(func() {
+		session.LogGroupNames = append(session.LogGroupNames, logGroupName)
+	})
+}
+
+func (session *TCPDumpSession) AddRole(role *clients.Role) error {
+	return session.update(func() {
+		sessionRole := &SessionRole{Name: aws.StringValue(role.Name), Arn: aws.StringValue(role.Arn), InlinePolicies: []string{}}
+		for _, policy := range role.InlinePolicies {
+			sessionRole.InlinePolicies = append(sessionRole.InlinePolicies, aws.StringValue(policy.Name))
+		}
+		session.Roles = append(session.Roles, sessionRole)
+	})
+}
+
+// Remove deletes the manifest, after the session was cleaned up.
+func (session *TCPDumpSession) Remove() error {
+	session.lock.Lock()
+	defer session.lock.Unlock()
+	err := os.Remove(session.filePath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// CleanupSession deletes the session flow logs and roles, the log groups by Config.DeleteLogGroups.
+// A role is kept while flow logs created outside the session deliver with it, in any of the sessions regions.
+// Deleted resources are removed from the manifest, the manifest is removed once empty.
+func (awsTCPDump *AWSTCPDump) CleanupSession(session *TCPDumpSession) error {
+	errorPrefix := "[aws_tcpdump_session:CleanupSession]"
+	errs := []error{}
+	ec2API := awsTCPDump.getClients().EC2(&session.Region)
+
+	for _, flowLogId := range slices.Clone(session.FlowLogIds) {
+		if err := ec2API.DisposeFlowLog(&flowLogId); err != nil {
+			errs = append(errs, fmt.Errorf("%s flow log %s\n%w", errorPrefix, flowLogId, err))
+			continue
+		}
+		err := session.update(func() {
+			session.FlowLogIds = slices.DeleteFunc(session.FlowLogIds, func(id string) bool { return id == flowLogId })
+		})
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if awsTCPDump.Config.DeleteLogGroups {
+		logsAPI := awsTCPDump.getClients().CloudwatchLogs(&session.Region)
+		for _, logGroupName := range slices.Clone(session.LogGroupNames) {
+			if err := logsAPI.DisposeLogGroup(&logGroupName); err != nil {
+				errs = append(errs, fmt.Errorf("%s log group %s\n%w", errorPrefix, logGroupName, err))
+				continue
+			}
+			err := session.update(func() {
+				session.LogGroupNames = slices.DeleteFunc(session.LogGroupNames, func(name string) bool { return name == logGroupName })
+			})
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	if len(session.Roles) > 0 {
+		if err := awsTCPDump.cleanupSessionRoles(session); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if session.Empty() {
+		if err := session.Remove(); err != nil {
+			errs = append(errs, fmt.Errorf("%s failed to remove the manifest\n%w", errorPrefix, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// sessionsRegions are the region of the session and the regions of the other manifests in Config.SessionsDirPath.
+func (awsTCPDump *AWSTCPDump) sessionsRegions(session *TCPDumpSession) ([]string, error) {
+	ret := []string{session.Region}
+	sessions, err := TCPDumpSessionsList(awsTCPDump.Config.SessionsDirPath)
+	if err != nil {
+		return nil, err
+	}
+	for _, other := range sessions {
+		if !slices.Contains(ret, other.Region) {
+			ret = append(ret, other.Region)
+		}
+	}
+	return ret, nil
+}
+
+func (awsTCPDump *AWSTCPDump) cleanupSessionRoles(session *TCPDumpSession) error {
+	errorPrefix := "[aws_tcpdump_session:cleanupSessionRoles]"
+	// IAM roles are global, flow logs of the other sessions regions may deliver with the role.
+	regions, err := awsTCPDump.sessionsRegions(session)
+	if err != nil {
+		return fmt.Errorf("%s failed to list the sessions\n%w", errorPrefix, err)
+	}
+	inUse := map[string]bool{}
+	for _, region := range regions {
+		for flowLog, err := range awsTCPDump.getClients().EC2(&region).IterFlowLogs(context.Background(), &ec2.DescribeFlowLogsInput{}) {
+			if err != nil {
+				return fmt.Errorf("%s failed to describe flow logs in %s\n%w", errorPrefix, region, err)
+			}
+			inUse[aws.StringValue(flowLog.DeliverLogsPermissionArn)] = true
+		}
+	}
+
+	errs := []error{}
+	iamAPI := awsTCPDump.getClients().IAM(&awsTCPDump.Config.IamDataDirPath)
+	for _, sessionRole := range slices.Clone(session.Roles) {
+		if inUse[sessionRole.Arn] {
+			lg.InfoF("Keeping role %s, other flow logs deliver with it", sessionRole.Name)
+			continue
+		}
+		role := &clients.Role{Name: aws.String(sessionRole.Name)}
+		for _, policyName := range sessionRole.InlinePolicies {
+			role.InlinePolicies = append(role.InlinePolicies, &clients.Policy{Name: aws.String(policyName)})
+		}
+		if err := iamAPI.DisposeIamRole(role); err != nil {
+			errs = append(errs, fmt.Errorf("%s role %s\n%w", errorPrefix, sessionRole.Name, err))
+			continue
+		}
+		err := session.update(func() {
+			session.Roles = slices.DeleteFunc(session.Roles, func(current *SessionRole) bool { return current == sessionRole })
+		})
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// CleanupSessionByID cleans up the leftovers of a crashed run by its manifest in Config.SessionsDirPath.
+func (awsTCPDump *AWSTCPDump) CleanupSessionByID(id string) error {
+	session, err := TCPDumpSessionLoad(awsTCPDump.Config.SessionsDirPath, id)
+	if err != nil {
+		return err
+	}
+	lg.InfoF("Cleaning up session %s", session)
+	return awsTCPDump.CleanupSession(session)
+}
+
+// startRecorder runs the recorder until it returns, Shutdown waits for it.
+func (awsTCPDump *AWSTCPDump) startRecorder(recorder func() error) {
+	awsTCPDump.recorders.Add(1)
+	go func() {
+		defer awsTCPDump.recorders.Done()
+		if err := recorder(); err != nil {
+			lg.InfoF("recorder stopped: %v", err)
+		}
+	}()
+}
+
+// recordingContext is canceled by Shutdown.
+func (awsTCPDump *AWSTCPDump) recordingContext() context.Context {
+	awsTCPDump.recordingOnce.Do(func() {
+		awsTCPDump.recording, awsTCPDump.stopRecording = context.WithCancel(context.Background())
+	})
+	return awsTCPDump.recording
+}
+
+// Shutdown stops the subnet and interface recorders and cleans up the session resources.
+func (awsTCPDump *AWSTCPDump) Shutdown() error {
+	awsTCPDump.Done.Store(true)
+	awsTCPDump.recordingContext()
+	awsTCPDump.stopRecording()
+
+	stopped := make(chan struct{})
+	go func() {
+		awsTCPDump.recorders.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(recordersStopTimeout):
+		lg.WarningF("Recorders did not stop in %s, cleaning up anyway", recordersStopTimeout)
+	}
+
+	if awsTCPDump.Session == nil {
+		return nil
+	}
+	lg.InfoF("Cleaning up session %s", awsTCPDump.Session)
+	return awsTCPDump.CleanupSession(awsTCPDump.Session)
+}
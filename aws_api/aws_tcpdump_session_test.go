@@ -0,0 +1,134 @@
+package aws_api
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	clients "github.com/AlexeyBeley/go_misc/aws_api/clients"
+	"github.com/AlexeyBeley/go_misc/aws_api/clients/fakes"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+func TestTCPDumpSession(t *testing.T) {
+	provision := func(t *testing.T) (*AWSTCPDump, *fakes.Services) {
+		services := fakes.ServicesNew()
+		services.EC2.FlowLogs = []*ec2.FlowLog{{FlowLogId: strPtr("fl-existing"), ResourceId: strPtr("subnet-1"), LogGroupName: strPtr("existing-group")}}
+		awsTCPDump := &AWSTCPDump{Config: &AWSTCPDumpConfig{Region: "us-east-1", Subnets: []string{"subnet-1", "subnet-2"}, IamDataDirPath: iamDataDir(t),
+			SessionsDirPath: t.TempDir()}, Clients: services.Factory()}
+		session, err := TCPDumpSessionNew(awsTCPDump.Config.SessionsDirPath, awsTCPDump.Config.Region)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		awsTCPDump.Session = session
+		if _, err := awsTCPDump.provisionSubnetsFlowLogGroups(); err != nil {
+			t.Fatalf("%v", err)
+		}
+		return awsTCPDump, services
+	}
+
+	t.Run("Valid run", func(t *testing.T) {
+		awsTCPDump, services := provision(t)
+		session, err := TCPDumpSessionLoad(awsTCPDump.Config.SessionsDirPath, awsTCPDump.Session.ID)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if len(session.FlowLogIds) != 1 || len(session.LogGroupNames) != 1 || session.LogGroupNames[0] != "tcpdump-subnet-2" ||
+			len(session.Roles) != 1 || session.Roles[0].Name != "role-aws-tcpdump" || len(session.Roles[0].InlinePolicies) != 1 {
+			t.Fatalf("unexpected manifest: %+v", session)
+		}
+
+		if err := awsTCPDump.Shutdown(); err != nil {
+			t.Fatalf("%v", err)
+		}
+		if len(services.EC2.FlowLogs) != 1 || *services.EC2.FlowLogs[0].FlowLogId != "fl-existing" {
+			t.Errorf("session flow log was not deleted: %v", services.EC2.FlowLogs)
+		}
+		if len(services.IAM.Roles) != 0 {
+			t.Errorf("session role was not deleted: %v", services.IAM.Roles)
+		}
+		if len(services.CloudwatchLogs.LogGroups) != 1 {
+			t.Errorf("log groups are kept by default: %v", services.CloudwatchLogs.LogGroups)
+		}
+
+		// The log group left in the manifest is cleaned up by the session ID.
+		awsTCPDump.Config.DeleteLogGroups = true
+		if err := awsTCPDump.CleanupSessionByID(session.ID); err != nil {
+			t.Fatalf("%v", err)
+		}
+		if len(services.CloudwatchLogs.LogGroups) != 0 {
+			t.Errorf("log group was not deleted: %v", services.CloudwatchLogs.LogGroups)
+		}
+		if _, err := os.Stat(filepath.Join(awsTCPDump.Config.SessionsDirPath, session.ID+".json")); !os.IsNotExist(err) {
+			t.Errorf("manifest of a cleaned up session must be removed: %v", err)
+		}
+		if _, err := TCPDumpSessionLoad(awsTCPDump.Config.SessionsDirPath, session.ID); err == nil {
+			t.Errorf("expected an error loading a removed session")
+		}
+	})
+
+	t.Run("Recorders stop", func(t *testing.T) {
+		awsTCPDump := &AWSTCPDump{Config: &AWSTCPDumpConfig{}}
+		stopped := make(chan struct{})
+		awsTCPDump.startRecorder(func() error {
+			<-awsTCPDump.recordingContext().Done()
+			close(stopped)
+			return nil
+		})
+		start := time.Now()
+		if err := awsTCPDump.Shutdown(); err != nil {
+			t.Fatalf("%v", err)
+		}
+		select {
+		case <-stopped:
+		default:
+			t.Errorf("Shutdown returned before the recorder stopped")
+		}
+		if time.Since(start) > time.Second || !awsTCPDump.Done.Load() {
+			t.Errorf("Shutdown took %s", time.Since(start))
+		}
+	})
+
+	t.Run("Role in use", func(t *testing.T) {
+		awsTCPDump, services := provision(t)
+		role := services.IAM.Roles["role-aws-tcpdump"]
+		services.EC2.FlowLogs = append(services.EC2.FlowLogs, &ec2.FlowLog{FlowLogId: strPtr("fl-other"), ResourceId: strPtr("subnet-3"),
+			DeliverLogsPermissionArn: role.Arn})
+
+		if err := awsTCPDump.Shutdown(); err != nil {
+			t.Fatalf("%v", err)
+		}
+		if _, found := services.IAM.Roles["role-aws-tcpdump"]; !found {
+			t.Errorf("role used by other flow logs must be kept")
+		}
+		session, err := TCPDumpSessionLoad(awsTCPDump.Config.SessionsDirPath, awsTCPDump.Session.ID)
+		if err != nil || len(session.FlowLogIds) != 0 || len(session.Roles) != 1 {
+			t.Errorf("unexpected manifest: %v %+v", err, session)
+		}
+	})
+
+	t.Run("Role in use in another region", func(t *testing.T) {
+		awsTCPDump, services := provision(t)
+		role := services.IAM.Roles["role-aws-tcpdump"]
+		other := fakes.ServicesNew()
+		other.EC2.FlowLogs = []*ec2.FlowLog{{FlowLogId: strPtr("fl-other"), ResourceId: strPtr("subnet-3"), DeliverLogsPermissionArn: role.Arn}}
+		awsTCPDump.Clients.EC2 = func(region *string) *clients.EC2API {
+			if *region == "eu-west-1" {
+				return clients.EC2APINewWithService(other.EC2)
+			}
+			return clients.EC2APINewWithService(services.EC2)
+		}
+		manifest := []byte(`{"ID": "tcpdump-other", "Region": "eu-west-1", "FlowLogIds": ["fl-other"], "LogGroupNames": [], "Roles": []}`)
+		if err := os.WriteFile(filepath.Join(awsTCPDump.Config.SessionsDirPath, "tcpdump-other.json"), manifest, 0644); err != nil {
+			t.Fatalf("%v", err)
+		}
+
+		if err := awsTCPDump.Shutdown(); err != nil {
+			t.Fatalf("%v", err)
+		}
+		if _, found := services.IAM.Roles["role-aws-tcpdump"]; !found {
+			t.Errorf("role used by flow logs of another region must be kept")
+		}
+	})
+}
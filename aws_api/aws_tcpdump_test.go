@@ -1,19 +1,27 @@
 package aws_api
 
 import (
-	"flag"
 	"os"
+	"path/filepath"
 	"reflect"
-	"strings"
 	"testing"
+
+	"github.com/AlexeyBeley/go_misc/aws_api/clients/fakes"
+	"github.com/AlexeyBeley/go_misc/logger"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/ec2"
 )
 
 func TestStartEcho(t *testing.T) {
 	t.Run("Valid run", func(t *testing.T) {
 		config_file_path := "/opt/aws_api_go/AWSTCPDumpConfig.json"
 		lg.InfoF("Initializing: %s", config_file_path)
-		os.Args = []string{"program_name", "-config", config_file_path}
-		awsTCPDumpNew, err := AWSTCPDumpNew()
+		config, err := (&AWSTCPDump{}).LoadConfig(config_file_path)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		awsTCPDumpNew, err := AWSTCPDumpNew(config)
 		awsTCPDumpNew.EventsFilter = awsTCPDumpNew.EventsEchoFilter
 		awsTCPDumpNew.EventProcessor = awsTCPDumpNew.EventsEchoWriter
 		if err != nil {
@@ -32,8 +40,11 @@ func TestStartSubnetFilter(t *testing.T) {
 	t.Run("Valid run", func(t *testing.T) {
 		config_file_path := "/opt/aws_api_go/AWSTCPDumpConfig.json"
 		lg.InfoF("Initializing: %s", config_file_path)
-		os.Args = []string{"--config", config_file_path}
-		awsTCPDumpNew, err := AWSTCPDumpNew()
+		config, err := (&AWSTCPDump{}).LoadConfig(config_file_path)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		awsTCPDumpNew, err := AWSTCPDumpNew(config)
 		awsTCPDumpNew.EventsFilter = awsTCPDumpNew.GenerateSubnetFilter([]string{""})
 		awsTCPDumpNew.EventProcessor = awsTCPDumpNew.EventsEchoWriterUTCTime
 		if err != nil {
@@ -50,74 +61,136 @@ func TestStartSubnetFilter(t *testing.T) {
 
 func TestInitConfig(t *testing.T) {
 	t.Run("Valid run", func(t *testing.T) {
-
-		newAWSTcpDump := &AWSTCPDump{}
+		defer func(fileDst string) { lg.FileDst = fileDst }(lg.FileDst)
+		logFilePath := filepath.Join(t.TempDir(), "tcpdump.log")
+		newAWSTcpDump := &AWSTCPDump{Config: &AWSTCPDumpConfig{LogOutputFilePath: logFilePath}}
 		err := newAWSTcpDump.initConfig()
 		if err != nil {
 			t.Errorf("%v", err)
 		}
+		if lg.FileDst != logFilePath || newAWSTcpDump.Config.SessionsDirPath != "/opt/aws_api/tcpdump/sessions" {
+			t.Errorf("unexpected config: %+v", newAWSTcpDump.Config)
+		}
 
 	})
 }
 
-func TestParseArgs(t *testing.T) {
-	newAWSTcpDump := &AWSTCPDump{}
-
-	tests := []struct {
-		name     string
-		args     []string
-		expected *AWSTCPDumpConfig
-		wantErr  bool
-		errStr   string // Expected error substring
-	}{
-		{
-			name:     "Default values",
-			args:     []string{},
-			expected: &AWSTCPDumpConfig{Region: "", Subnets: []string{}, AWSProfile: "default", LiveRecording: false},
-			wantErr:  false,
-		}, {
-			name:     "subnets",
-			args:     []string{"--subnets", "sb-12345"},
-			expected: &AWSTCPDumpConfig{Region: "", Subnets: []string{"sb-12345"}, AWSProfile: "default", LiveRecording: false},
-			wantErr:  false,
-		}, {
-			name:     "profile",
-			args:     []string{"--subnets", "sb-12345", "--profile", "non-default"},
-			expected: &AWSTCPDumpConfig{Region: "", Subnets: []string{"sb-12345"}, AWSProfile: "non-default", LiveRecording: false},
-			wantErr:  false,
-		}, {
-			name:     "live",
-			args:     []string{"--subnets", "sb-12345", "--live", "true"},
-			expected: &AWSTCPDumpConfig{Region: "", Subnets: []string{"sb-12345"}, AWSProfile: "default", LiveRecording: true},
-			wantErr:  false,
-		},
+// iamDataDir copies the role templates to a temporary IamDataDirPath.
+func iamDataDir(t *testing.T) string {
+	dataDir := t.TempDir()
+	err := os.Mkdir(filepath.Join(dataDir, "tmp"), 0755)
+	if err != nil {
+		t.Fatalf("%v", err)
 	}
+	for _, name := range []string{"template_cloudwatch_writer_service_assume_role.json", "template_cloudwatch_writer_policy.json"} {
+		data, err := os.ReadFile(filepath.Join("data", name))
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		err = os.WriteFile(filepath.Join(dataDir, name), data, 0644)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+	}
+	return dataDir
+}
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			// Create a new FlagSet for each test to ensure isolation
-			fs := flag.NewFlagSet("test-"+tt.name, flag.ContinueOnError) // flag.ContinueOnError prevents os.Exit(2)
+func TestProvisionSubnetsFlowLogGroups(t *testing.T) {
+	t.Run("Valid run", func(t *testing.T) {
+		dataDir := iamDataDir(t)
 
-			// Suppress flag package output during tests, as it prints to stderr by default
-			// This makes test output cleaner.
-			fs.SetOutput(new(strings.Builder))
+		services := fakes.ServicesNew()
+		services.EC2.FlowLogs = []*ec2.FlowLog{{FlowLogId: strPtr("fl-existing"), ResourceId: strPtr("subnet-1"), LogGroupName: strPtr("existing-group")}}
+		awsTCPDump := &AWSTCPDump{Config: &AWSTCPDumpConfig{Region: "us-east-1", Subnets: []string{"subnet-1", "subnet-2"}, IamDataDirPath: dataDir},
+			Clients: services.Factory()}
 
-			cfg, err := newAWSTcpDump.ParseArgs(fs, tt.args)
+		logGroupNames, err := awsTCPDump.provisionSubnetsFlowLogGroups()
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
 
-			if (err != nil) != tt.wantErr {
-				t.Errorf("ParseFlags() error = %v, wantErr %t", err, tt.wantErr)
-				return
-			}
-			if tt.wantErr {
-				if err == nil || !strings.Contains(err.Error(), tt.errStr) {
-					t.Errorf("ParseFlags() error = %q, want error containing %q", err.Error(), tt.errStr)
-				}
-				return
-			}
+		expected := map[string]string{"subnet-1": "existing-group", "subnet-2": "tcpdump-subnet-2"}
+		if !reflect.DeepEqual(logGroupNames, expected) {
+			t.Errorf("got %v, expected %v", logGroupNames, expected)
+		}
+		if len(services.EC2.FlowLogs) != 2 || *services.EC2.FlowLogs[1].ResourceId != "subnet-2" || *services.EC2.FlowLogs[1].LogFormat != DefaultFlowLogFormat {
+			t.Errorf("unexpected flow logs: %v", services.EC2.FlowLogs)
+		}
+		role, found := services.IAM.Roles["role-aws-tcpdump"]
+		if !found || *services.EC2.FlowLogs[1].DeliverLogsPermissionArn != *role.Arn {
+			t.Errorf("flow log must be delivered by the provisioned role: %v", services.IAM.Roles)
+		}
+		if _, found := services.IAM.RolePolicies["role-aws-tcpdump"]["InlineCloudwatchWriter"]; !found {
+			t.Errorf("inline policy was not attached: %v", services.IAM.RolePolicies)
+		}
+		if len(services.CloudwatchLogs.LogGroups) != 1 || *services.CloudwatchLogs.LogGroups[0].LogGroupName != "tcpdump-subnet-2" {
+			t.Errorf("unexpected log groups: %v", services.CloudwatchLogs.LogGroups)
+		}
 
-			if !reflect.DeepEqual(cfg, tt.expected) {
-				t.Errorf("ParseFlags() got = %+v, want %+v", cfg, tt.expected)
+		// Second run finds everything provisioned.
+		_, err = awsTCPDump.provisionSubnetsFlowLogGroups()
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if len(services.EC2.FlowLogs) != 2 || len(services.CloudwatchLogs.LogGroups) != 1 {
+			t.Errorf("second run must not provision again: %d flow logs, %d log groups", len(services.EC2.FlowLogs), len(services.CloudwatchLogs.LogGroups))
+		}
+	})
+
+	t.Run("Existing flow log format", func(t *testing.T) {
+		services := fakes.ServicesNew()
+		services.EC2.FlowLogs = []*ec2.FlowLog{{FlowLogId: strPtr("fl-existing"), ResourceId: strPtr("subnet-1"), LogGroupName: strPtr("existing-group"),
+			LogFormat: strPtr("${interface-id} ${flow-direction} ${srcaddr} ${dstaddr} ${bytes}")}}
+		events := []*FlowLogEvent{}
+		awsTCPDump := &AWSTCPDump{Config: &AWSTCPDumpConfig{Region: "us-east-1", Subnets: []string{"subnet-1", "subnet-2"}, IamDataDirPath: iamDataDir(t)},
+			Clients: services.Factory(), EventProcessor: func(event *FlowLogEvent) error {
+				events = append(events, event)
+				return nil
+			}}
+		awsTCPDump.EventsFilter = awsTCPDump.EventsEchoFilter
+
+		if _, err := awsTCPDump.provisionSubnetsFlowLogGroups(); err != nil {
+			t.Fatalf("%v", err)
+		}
+		if err := awsTCPDump.SubnetFlowLogEventsProcessRoutine("subnet-1")(&cloudwatchlogs.OutputLogEvent{Message: aws.String("eni-1 ingress 203.0.113.7 10.0.0.5 1200")}); err != nil {
+			t.Fatalf("%v", err)
+		}
+		if err := awsTCPDump.SubnetFlowLogEventsProcessRoutine("subnet-2")(&cloudwatchlogs.OutputLogEvent{Message: aws.String("2 123456789012 eni-2 10.0.0.5 203.0.113.7 443 51000 6 10 9000 1735776000 1735776060 ACCEPT OK")}); err != nil {
+			t.Fatalf("%v", err)
+		}
+		if len(events) != 2 || events[0].InterfaceID != "eni-1" || events[0].FlowDirection != "ingress" || events[0].Bytes != 1200 ||
+			events[1].InterfaceID != "eni-2" || events[1].Bytes != 9000 {
+			t.Errorf("each subnet must be parsed with its flow log format: %+v", events)
+		}
+
+		services.EC2.FlowLogs[0].LogFormat = strPtr("${interface-id} ${no-such-field}")
+		if _, err := awsTCPDump.provisionSubnetsFlowLogGroups(); err == nil {
+			t.Errorf("expected an error for an unsupported flow log format")
+		}
+	})
+}
+
+func TestFlowLogEventsBytesSummarizerHandler(t *testing.T) {
+	t.Run("Valid run", func(t *testing.T) {
+		awsTCPDump := &AWSTCPDump{Config: &AWSTCPDumpConfig{},
+			JsonLogger: &logger.Logger{FileDst: filepath.Join(t.TempDir(), "interfaces.log")}}
+		dataCollector := &interfaceDataCollector{NetworkInterfaceId: aws.String("eni-1")}
+		handler := awsTCPDump.FlowLogEventsBytesSummarizerHandler(dataCollector)
+		for _, message := range []string{
+			"2 123456789012 eni-1 203.0.113.7 10.0.0.5 51000 443 6 10 6000 1735776000 1735776060 ACCEPT OK",
+			"2 123456789012 eni-1 10.0.0.5 203.0.113.7 443 51000 6 10 9000 1735776000 1735776060 ACCEPT OK",
+			"2 123456789012 eni-1 10.0.0.5 10.0.1.9 40000 5432 6 4 900 1735776000 1735776060 ACCEPT OK",
+			"2 123456789012 eni-1 198.51.100.9 203.0.113.7 40001 443 6 2 300 1735776000 1735776060 ACCEPT OK",
+			"2 123456789012 eni-1 - - - - - - - 1735776000 1735776060 - NODATA",
+			"2 123456789012 eni-1 - - - - - - - 1735776000 1735776060 - SKIPDATA",
+		} {
+			if err := handler(&cloudwatchlogs.OutputLogEvent{Message: aws.String(message)}); err != nil {
+				t.Fatalf("%s: %v", message, err)
 			}
-		})
-	}
+		}
+		if dataCollector.TotalBytesIn != 6000 || dataCollector.TotalBytesOut != 9000 || dataCollector.MaxMinuteBytesOut != 9000 ||
+			dataCollector.TotalBytesExternal != 300 {
+			t.Errorf("unexpected totals: %+v", dataCollector)
+		}
+	})
 }
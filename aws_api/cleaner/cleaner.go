@@ -3,11 +3,14 @@ package aws_api
 import (
 	"context"
 	"fmt"
+	"regexp"
 	"sync"
 	"time"
 
-	"github.com/AlexeyBeley/go_misc/logger"
 	clients "github.com/AlexeyBeley/go_misc/aws_api/clients"
+	"github.com/AlexeyBeley/go_misc/logger"
+	"github.com/AlexeyBeley/go_misc/task_scheduler"
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
 )
 
@@ -16,264 +19,342 @@ var lg = &(logger.Logger{AddDateTime: true})
 type CleanerConfig struct {
 	Region  *string `json:"Region"`
 	Profile *string `json:"Profile"`
+	// Lists the streams to delete and the bytes freed, nothing is deleted.
+	DryRun bool `json:"DryRun"`
+	// Log group name regexes. All the log groups are cleaned when IncludeLogGroups is empty.
+	IncludeLogGroups []string `json:"IncludeLogGroups"`
+	ExcludeLogGroups []string `json:"ExcludeLogGroups"`
+	// Streams deleted per run, 0 is unlimited.
+	MaxDeletions int `json:"MaxDeletions"`
+	// Days an empty stream is kept past the log group retention.
+	MinAgeMarginDays int64 `json:"MinAgeMarginDays"`
+	// JSONL file every deleted stream and resource is appended to.
+	JournalPath string `json:"JournalPath"`
+	// Resource types cleaned by CleanWaste, all the WasteTypes when empty.
+	WasteTypes []string `json:"WasteTypes"`
+	// Days since creation before an unused volume, snapshot or image is deleted, DefaultWasteMinAgeDays when 0.
+	// Elastic ips and network interfaces are deleted the same days after they were first found idle.
+	WasteMinAgeDays int64 `json:"WasteMinAgeDays"`
+	// Monthly prices of the cost estimates, DefaultWastePrices when not set.
+	WastePrices *WastePrices `json:"WastePrices"`
+	// Latest task definition revisions kept per family, DefaultTaskDefinitionsKeep when 0.
+	TaskDefinitionsKeep int `json:"TaskDefinitionsKeep"`
+	// Task definition family regexes. All the families are pruned when empty.
+	TaskDefinitionFamilies []string `json:"TaskDefinitionFamilies"`
+	// Deletes the deregistered revisions, they are only deregistered otherwise.
+	DeleteTaskDefinitions bool `json:"DeleteTaskDefinitions"`
 }
 
 type Cleaner struct {
-	Config *CleanerConfig
+	Config  *CleanerConfig
+	Clients *clients.Factory
+	// Streams deleted, or to be deleted in a dry run.
+	Deletions []*StreamDeletion
+	// Unused resources deleted, or to be deleted in a dry run.
+	WasteDeletions []*WasteDeletion
+	// Task definition revisions deregistered or deleted, or to be in a dry run.
+	TaskDefinitionDeletions []*TaskDefinitionDeletion
+	include                 []*regexp.Regexp
+	exclude                 []*regexp.Regexp
+	families                []*regexp.Regexp
+	journal                 *DeletionJournal
+	// Deletions started in the current run, the failed ones are released.
+	reserved int
+	lock     sync.Mutex
 }
 
 func CleanerNew(config *CleanerConfig) (*Cleaner, error) {
-	new := &Cleaner{Config: config}
+	return CleanerNewWithClients(config, clients.FactoryNew(config.Profile))
+}
+
+func CleanerNewWithClients(config *CleanerConfig, factory *clients.Factory) (*Cleaner, error) {
+	errorPrefix := "[cleaner:CleanerNewWithClients]"
+	if config.MaxDeletions < 0 || config.MinAgeMarginDays < 0 || config.WasteMinAgeDays < 0 || config.TaskDefinitionsKeep < 0 {
+		return nil, fmt.Errorf("%s MaxDeletions, MinAgeMarginDays, WasteMinAgeDays and TaskDefinitionsKeep can not be negative", errorPrefix)
+	}
+	for _, wasteType := range config.WasteTypes {
+		if _, found := wasteListers[wasteType]; !found {
+			return nil, fmt.Errorf("%s unknown waste type %s, supported: %v", errorPrefix, wasteType, WasteTypes)
+		}
+	}
+	new := &Cleaner{Config: config, Clients: factory}
+	for _, patterns := range []struct {
+		source []string
+		target *[]*regexp.Regexp
+	}{{config.IncludeLogGroups, &new.include}, {config.ExcludeLogGroups, &new.exclude}, {config.TaskDefinitionFamilies, &new.families}} {
+		for _, pattern := range patterns.source {
+			compiled, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("%s invalid pattern %s\n%w", errorPrefix, pattern, err)
+			}
+			*patterns.target = append(*patterns.target, compiled)
+		}
+	}
 	return new, nil
 }
 
-func (cleaner *Cleaner) StartLogGroupStreamCleanerTask(asyncOrchestrator *AsyncOrchestrator, logs_api *clients.CloudwatchLogsAPI, logGroup *cloudwatchlogs.LogGroup, stream *cloudwatchlogs.LogStream) error {
-	objects := []any{}
-	err := logs_api.YieldStreamEvents(&cloudwatchlogs.GetLogEventsInput{
+// Selected checks the log group name against the include and exclude patterns, exclude wins.
+func (cleaner *Cleaner) Selected(logGroupName string) bool {
+	for _, pattern := range cleaner.exclude {
+		if pattern.MatchString(logGroupName) {
+			return false
+		}
+	}
+	if len(cleaner.include) == 0 {
+		return true
+	}
+	for _, pattern := range cleaner.include {
+		if pattern.MatchString(logGroupName) {
+			return true
+		}
+	}
+	return false
+}
+
+// BytesFreed by the deleted streams, as reported by the service.
+func (cleaner *Cleaner) BytesFreed() int64 {
+	cleaner.lock.Lock()
+	defer cleaner.lock.Unlock()
+
+	var ret int64
+	for _, deletion := range cleaner.Deletions {
+		ret += deletion.StoredBytes
+	}
+	return ret
+}
+
+func (cleaner *Cleaner) limitReached() bool {
+	cleaner.lock.Lock()
+	defer cleaner.lock.Unlock()
+
+	return cleaner.Config.MaxDeletions > 0 && cleaner.reserved >= cleaner.Config.MaxDeletions
+}
+
+// Streams are deleted concurrently: the slot is taken before the deletion starts.
+func (cleaner *Cleaner) reserveDeletion() bool {
+	cleaner.lock.Lock()
+	defer cleaner.lock.Unlock()
+
+	if cleaner.Config.MaxDeletions > 0 && cleaner.reserved >= cleaner.Config.MaxDeletions {
+		return false
+	}
+	cleaner.reserved++
+	return true
+}
+
+func (cleaner *Cleaner) releaseDeletion() {
+	cleaner.lock.Lock()
+	defer cleaner.lock.Unlock()
+
+	cleaner.reserved--
+}
+
+// MaxDeletions is per run, every run starts with all the slots.
+func (cleaner *Cleaner) resetDeletions() {
+	cleaner.lock.Lock()
+	defer cleaner.lock.Unlock()
+
+	cleaner.reserved = 0
+}
+
+func (cleaner *Cleaner) recordDeletion(deletion *StreamDeletion) error {
+	cleaner.lock.Lock()
+	cleaner.Deletions = append(cleaner.Deletions, deletion)
+	cleaner.lock.Unlock()
+
+	if cleaner.journal == nil {
+		return nil
+	}
+	return cleaner.journal.Write(deletion)
+}
+
+// Streams that never had events have no last event timestamp.
+func streamTimestamp(stream *cloudwatchlogs.LogStream) int64 {
+	if stream.LastEventTimestamp != nil {
+		return *stream.LastEventTimestamp
+	}
+	if stream.CreationTime != nil {
+		return *stream.CreationTime
+	}
+	return 0
+}
+
+func (cleaner *Cleaner) StartLogGroupStreamCleanerTask(ctx context.Context, scheduler *task_scheduler.Scheduler, logs_api *clients.CloudwatchLogsAPI, logGroup *cloudwatchlogs.LogGroup, stream *cloudwatchlogs.LogStream) error {
+	if logGroup.RetentionInDays == nil {
+		return nil
+	}
+
+	// A single event is enough to keep the stream.
+	objects, err := clients.Collect(clients.Take(logs_api.IterStreamEvents(ctx, &cloudwatchlogs.GetLogEventsInput{
 		StartFromHead: clients.BoolPtr(false),
 		LogGroupName:  logGroup.LogGroupName,
 		LogStreamName: stream.LogStreamName,
-	}, clients.AggregatorInitializerNG(&objects))
+	}), 1))
 
 	if err != nil {
 		return err
 	}
-	if len(objects) == 0 {
+	if len(objects) != 0 {
+		return nil
+	}
 
-		epochLimitRetention := (time.Now().UTC().Unix() - *logGroup.RetentionInDays*24*60*60) * 1000
-		if *stream.LastEventTimestamp > epochLimitRetention {
-			return fmt.Errorf("was not able to fetch events from stream inside retention range: %s", *stream.LogStreamName)
-		}
+	dayMilliseconds := int64(24 * 60 * 60 * 1000)
+	timestamp := streamTimestamp(stream)
+	epochLimitRetention := time.Now().UTC().UnixMilli() - *logGroup.RetentionInDays*dayMilliseconds
+	if timestamp > epochLimitRetention {
+		return fmt.Errorf("was not able to fetch events from stream inside retention range: %s", *stream.LogStreamName)
+	}
+	if timestamp > epochLimitRetention-cleaner.Config.MinAgeMarginDays*dayMilliseconds {
+		lg.InfoF("Keeping log stream inside the age margin: %s -> %s", *logGroup.LogGroupName, *stream.LogStreamName)
+		return nil
+	}
+	if !cleaner.reserveDeletion() {
+		lg.InfoF("Max deletions reached, keeping log stream: %s -> %s", *logGroup.LogGroupName, *stream.LogStreamName)
+		return nil
+	}
 
+	deletion := &StreamDeletion{Time: time.Now().UTC(),
+		Region:          aws.StringValue(cleaner.Config.Region),
+		LogGroupName:    *logGroup.LogGroupName,
+		LogStreamName:   *stream.LogStreamName,
+		RetentionInDays: *logGroup.RetentionInDays,
+		StoredBytes:     aws.Int64Value(stream.StoredBytes),
+		DryRun:          cleaner.Config.DryRun}
+	if stream.LastEventTimestamp != nil {
+		lastEvent := time.UnixMilli(*stream.LastEventTimestamp).UTC()
+		deletion.LastEventTimestamp = &lastEvent
+	}
+
+	if cleaner.Config.DryRun {
+		lg.InfoF("Dry run, log stream to dispose: %s -> %s, %d bytes", deletion.LogGroupName, deletion.LogStreamName, deletion.StoredBytes)
+	} else {
 		out, err := logs_api.DisposeStream(&cloudwatchlogs.DeleteLogStreamInput{LogGroupName: logGroup.LogGroupName, LogStreamName: stream.LogStreamName})
 		if err != nil {
+			cleaner.releaseDeletion()
 			lg.WarningF("Disposing log stream failed: %v, %v", out, err)
 			return err
 		}
 	}
-	return nil
+	return cleaner.recordDeletion(deletion)
 }
 
-func (cleaner *Cleaner) StartLogGroupCleanerTask(asyncOrchestrator *AsyncOrchestrator, logs_api *clients.CloudwatchLogsAPI, logGroup *cloudwatchlogs.LogGroup) error {
-	err := logs_api.YieldCloudwatchLogStreams(&cloudwatchlogs.DescribeLogStreamsInput{
+func (cleaner *Cleaner) StartLogGroupCleanerTask(ctx context.Context, scheduler *task_scheduler.Scheduler, logs_api *clients.CloudwatchLogsAPI, logGroup *cloudwatchlogs.LogGroup) error {
+	// Oldest streams first, the limit keeps the newest ones.
+	for stream, err := range logs_api.IterLogStreams(ctx, &cloudwatchlogs.DescribeLogStreamsInput{
 		LogGroupName: logGroup.LogGroupName,
 		OrderBy:      clients.StrPtr("LastEventTime"),
 		Descending:   clients.BoolPtr(false),
-	}, func(streamAny any) (bool, error) {
-
-		stream, ok := streamAny.(*cloudwatchlogs.LogStream)
-		if !ok {
-			return false, fmt.Errorf("cast error: %v", streamAny)
+	}) {
+		if err != nil {
+			return err
+		}
+		if cleaner.limitReached() {
+			return nil
 		}
 
-		work := func() (any, error) {
-			err := cleaner.StartLogGroupStreamCleanerTask(asyncOrchestrator, logs_api, logGroup, stream)
+		work := func(ctx context.Context) (any, error) {
+			err := cleaner.StartLogGroupStreamCleanerTask(ctx, scheduler, logs_api, logGroup, stream)
 			return nil, err
 		}
-		task := &Task{Work: work}
-		asyncOrchestrator.AddTask(task)
-
-		return true, nil
-	})
-	if err != nil {
-		return err
+		if err := scheduler.Add(&task_scheduler.Task{Name: *stream.LogStreamName, Work: work}); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
-func (cleaner *Cleaner) WorkLogGroupCleanerGenerator(asyncOrchestrator *AsyncOrchestrator) func() (any, error) {
-	return func() (any, error) {
+func (cleaner *Cleaner) WorkLogGroupCleanerGenerator(scheduler *task_scheduler.Scheduler) func(ctx context.Context) (any, error) {
+	return func(ctx context.Context) (any, error) {
 
-		logs_api := clients.CloudwatchLogsAPINew(cleaner.Config.Region, cleaner.Config.Profile)
+		logs_api := cleaner.Clients.CloudwatchLogs(cleaner.Config.Region)
 		logGroups, err := logs_api.GetLogGroups(nil)
 		if err != nil {
 			return nil, err
 		}
 		for _, logGroup := range logGroups {
-			//if *logGroup.LogGroupName != "" {
-			//		continue
-			//	}
-			var RetentionInDays string
+			if !cleaner.Selected(*logGroup.LogGroupName) {
+				continue
+			}
+			// Never expiring log groups keep all their streams.
 			if logGroup.RetentionInDays == nil {
-				RetentionInDays = "Nil"
-			} else {
-				RetentionInDays = fmt.Sprintf("%d", *logGroup.RetentionInDays)
+				lg.InfoF("Log group %s, retention Nil", *logGroup.LogGroupName)
+				continue
 			}
-			lg.InfoF("Log group %s, retention %s", *logGroup.LogGroupName, RetentionInDays)
+			lg.InfoF("Log group %s, retention %d", *logGroup.LogGroupName, *logGroup.RetentionInDays)
 
-			work := func() (any, error) {
-				err := cleaner.StartLogGroupCleanerTask(asyncOrchestrator, logs_api, logGroup)
+			work := func(ctx context.Context) (any, error) {
+				err := cleaner.StartLogGroupCleanerTask(ctx, scheduler, logs_api, logGroup)
+				return nil, err
+			}
+			if err := scheduler.Add(&task_scheduler.Task{Name: *logGroup.LogGroupName, Work: work}); err != nil {
 				return nil, err
 			}
-			task := &Task{Work: work}
-			asyncOrchestrator.AddTask(task)
-
 		}
 		return nil, nil
 	}
 }
 
-func (cleaner *Cleaner) CleanLogGroupsExpired() error {
-	asyncOrchestrator, err := AsyncOrchestratorNew(5)
-	if err != nil {
-		return err
+// openJournal opens the configured journal for a run, the returned function closes it.
+func (cleaner *Cleaner) openJournal() (func(), error) {
+	if cleaner.Config.JournalPath == "" {
+		return func() {}, nil
 	}
-	task := &Task{Work: cleaner.WorkLogGroupCleanerGenerator(asyncOrchestrator)}
-	asyncOrchestrator.AddTask(task)
-	asyncOrchestrator.Wait()
-
-	return nil
-}
-
-type AsyncOrchestrator struct {
-	TaskId        *int
-	WorkerPool    *WorkerPool
-	Tasks         map[int]*Task
-	SyncSemaphore chan bool
-}
-
-type Task struct {
-	ID       int
-	Work     func() (any, error)
-	Result   any
-	Error    error
-	Started  bool
-	Finished bool
-}
-
-type WorkerPool struct {
-	NumWorkers    int
-	WG            *sync.WaitGroup
-	Tasks         chan *Task
-	WorkerContext context.Context
-	CancelFunc    context.CancelFunc
-}
-
-// Generator
-func AsyncOrchestratorNew(NumWorkers int) (*AsyncOrchestrator, error) {
-	asyncOrchestrator := &AsyncOrchestrator{}
-	pool, err := WorkerPoolNew(NumWorkers)
+	journal, err := DeletionJournalNew(cleaner.Config.JournalPath)
 	if err != nil {
 		return nil, err
 	}
-	asyncOrchestrator.WorkerPool = pool
-	asyncOrchestrator.Tasks = map[int]*Task{}
-	asyncOrchestrator.TaskId = clients.IntPtr(0)
-	asyncOrchestrator.SyncSemaphore = make(chan bool, 1)
-	go asyncOrchestrator.TaskStarter()
-
-	return asyncOrchestrator, nil
+	cleaner.journal = journal
+	return func() {
+		journal.Close()
+		cleaner.journal = nil
+	}, nil
 }
-func (asyncOrchestrator *AsyncOrchestrator) AddTask(task *Task) error {
-	asyncOrchestrator.SyncSemaphore <- true
-
-	task.ID = *asyncOrchestrator.TaskId
-	asyncOrchestrator.Tasks[task.ID] = task
-	*asyncOrchestrator.TaskId = *asyncOrchestrator.TaskId + 1
-	lg.InfoF("Added task. Tasks count: %d", len(asyncOrchestrator.Tasks))
-
-	<-asyncOrchestrator.SyncSemaphore
-
-	return nil
-}
-
-func (asyncOrchestrator *AsyncOrchestrator) Wait() error {
-	for {
-		finishedTasks := 0
-		asyncOrchestrator.SyncSemaphore <- true
-		allTasksLen := len(asyncOrchestrator.Tasks)
-		for _, task := range asyncOrchestrator.Tasks {
-			if task.Finished {
-				finishedTasks++
-			}
-
-		}
-
-		<-asyncOrchestrator.SyncSemaphore
-
-		if finishedTasks == allTasksLen {
-			return nil
-		}
-
-		select {
-		case <-asyncOrchestrator.WorkerPool.WorkerContext.Done():
-			return nil
-		case <-time.After(time.Second * 5):
-			lg.InfoF("Waiting for all tasks to finish: %d/%d", finishedTasks, allTasksLen)
-		}
 
+func (cleaner *Cleaner) CleanLogGroupsExpired(ctx context.Context) error {
+	closeJournal, err := cleaner.openJournal()
+	if err != nil {
+		return err
 	}
-}
-
-func (asyncOrchestrator *AsyncOrchestrator) TaskStarter() error {
-	for {
-
-		var taskToStart *Task
-
-		asyncOrchestrator.SyncSemaphore <- true
-
-		for _, task := range asyncOrchestrator.Tasks {
-			if task.Started {
-				continue
-			}
-			taskToStart = task
-			break
-		}
-
-		<-asyncOrchestrator.SyncSemaphore
-
-		//blocked until the WorkerPool can not receive task
-		if taskToStart != nil {
-			asyncOrchestrator.WorkerPool.AddTask(taskToStart)
-		}
+	defer closeJournal()
+	cleaner.resetDeletions()
 
-		select {
-		case <-asyncOrchestrator.WorkerPool.WorkerContext.Done():
-			return nil
-		case <-time.After(time.Millisecond * 10):
-			continue
+	scheduler, err := task_scheduler.SchedulerNew(ctx, 5)
+	if err != nil {
+		return err
+	}
+	scheduler.OnProgress = func(progress task_scheduler.Progress) {
+		if progress.Finished%1000 == 0 {
+			lg.InfoF("Finished log cleaner tasks: %d/%d, failed: %d", progress.Finished, progress.Total, progress.Failed)
 		}
 	}
-}
-
-// Generator
-func WorkerPoolNew(NumWorkers int) (*WorkerPool, error) {
-	workerCtx, cancelFunc := context.WithCancel(context.Background())
-	WG := &sync.WaitGroup{}
-	WP := &WorkerPool{NumWorkers: NumWorkers,
-		WG:            WG,
-		WorkerContext: workerCtx,
-		CancelFunc:    cancelFunc}
-
-	for i := range NumWorkers {
-		WG.Add(1)
-		go WP.Work(i + 1)
+	err = scheduler.Add(&task_scheduler.Task{Name: "log groups", Work: cleaner.WorkLogGroupCleanerGenerator(scheduler)})
+	if err != nil {
+		return err
 	}
+	err = scheduler.Wait()
 
-	WP.Tasks = make(chan *Task, NumWorkers)
-	return WP, nil
-}
-
-func (workerPool *WorkerPool) AddTask(task *Task) error {
-	task.Started = true
-	workerPool.Tasks <- task
-	return nil
+	if cleaner.Config.DryRun {
+		lg.InfoF("Dry run, log streams to dispose: %d, bytes freed: %d", len(cleaner.Deletions), cleaner.BytesFreed())
+	} else {
+		lg.InfoF("Disposed log streams: %d, bytes freed: %d", len(cleaner.Deletions), cleaner.BytesFreed())
+	}
+	return err
 }
 
-func (workerPool *WorkerPool) Work(wid int) error {
-	for {
-		select {
-		case task, ok := <-workerPool.Tasks:
-			if !ok {
-				lg.ErrorF("channel closed")
-			}
-			lg.InfoF("Async worker %d starting doing work %v", wid, task.ID)
-			task.Result, task.Error = task.Work()
-			task.Finished = true
-		case <-workerPool.WorkerContext.Done():
-			return nil
+// CleanLogGroupsExpiredAllTargets cleans the expired log streams in every executor target.
+// The config is copied per target, Region and Profile are taken from the target.
+func CleanLogGroupsExpiredAllTargets(ctx context.Context, executor *clients.Executor, config *CleanerConfig) *clients.Report[[]*StreamDeletion] {
+	report := clients.Execute(ctx, executor, func(ctx context.Context, target clients.Target, factory *clients.Factory) ([]*StreamDeletion, error) {
+		targetConfig := *config
+		targetConfig.Region = &target.Region
+		targetConfig.Profile = &target.Profile
+		cleaner, err := CleanerNewWithClients(&targetConfig, factory)
+		if err != nil {
+			return nil, err
 		}
-
-	}
-
+		err = cleaner.CleanLogGroupsExpired(ctx)
+		return cleaner.Deletions, err
+	})
+	lg.InfoF("Cleaning report: %s", report)
+	return report
 }
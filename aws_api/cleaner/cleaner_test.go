@@ -1,9 +1,17 @@
 package aws_api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"os"
+	"strings"
 	"testing"
+	"time"
+
+	clients "github.com/AlexeyBeley/go_misc/aws_api/clients"
+	"github.com/AlexeyBeley/go_misc/aws_api/clients/fakes"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
 )
 
 func LoadGetLambdaLogsTestConfig() (*CleanerConfig, error) {
@@ -34,10 +42,180 @@ func TestCleanLogGroupExpired(t *testing.T) {
 			t.Errorf("%v", err)
 		}
 
-		err = awsCleaner.CleanLogGroupsExpired()
+		err = awsCleaner.CleanLogGroupsExpired(context.Background())
 		if err != nil {
 			t.Errorf("%v", err)
 		}
 
 	})
 }
+
+func TestStartLogGroupStreamCleanerTask(t *testing.T) {
+	t.Run("Valid run", func(t *testing.T) {
+		services := fakes.ServicesNew()
+		now := time.Now().UTC().UnixMilli()
+		dayMilliseconds := int64(24 * 60 * 60 * 1000)
+		logGroup := &cloudwatchlogs.LogGroup{LogGroupName: clients.StrPtr("group"), RetentionInDays: clients.Int64Ptr(7)}
+		streams := []*cloudwatchlogs.LogStream{
+			{LogStreamName: clients.StrPtr("expired"), LastEventTimestamp: clients.Int64Ptr(now - 30*dayMilliseconds)},
+			{LogStreamName: clients.StrPtr("active"), LastEventTimestamp: clients.Int64Ptr(now - dayMilliseconds)},
+		}
+		services.CloudwatchLogs.LogGroups = []*cloudwatchlogs.LogGroup{logGroup}
+		services.CloudwatchLogs.LogStreams["group"] = streams
+		services.CloudwatchLogs.Events[fakes.EventsKey("group", "active")] = []*cloudwatchlogs.OutputLogEvent{
+			{Timestamp: clients.Int64Ptr(now - dayMilliseconds), Message: clients.StrPtr("event")},
+		}
+
+		cleaner, err := CleanerNewWithClients(&CleanerConfig{Region: clients.StrPtr("us-east-1")}, services.Factory())
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		logsAPI := cleaner.Clients.CloudwatchLogs(cleaner.Config.Region)
+
+		for _, stream := range streams {
+			err = cleaner.StartLogGroupStreamCleanerTask(context.Background(), nil, logsAPI, logGroup, stream)
+			if err != nil {
+				t.Errorf("%s: %v", *stream.LogStreamName, err)
+			}
+		}
+		if len(services.CloudwatchLogs.DeletedLogStreams) != 1 || services.CloudwatchLogs.DeletedLogStreams[0] != "group/expired" {
+			t.Errorf("unexpected deleted streams: %v", services.CloudwatchLogs.DeletedLogStreams)
+		}
+	})
+
+	t.Run("Empty stream inside retention", func(t *testing.T) {
+		services := fakes.ServicesNew()
+		logGroup := &cloudwatchlogs.LogGroup{LogGroupName: clients.StrPtr("group"), RetentionInDays: clients.Int64Ptr(7)}
+		stream := &cloudwatchlogs.LogStream{LogStreamName: clients.StrPtr("recent"), LastEventTimestamp: clients.Int64Ptr(time.Now().UTC().UnixMilli())}
+		services.CloudwatchLogs.LogStreams["group"] = []*cloudwatchlogs.LogStream{stream}
+
+		cleaner, _ := CleanerNewWithClients(&CleanerConfig{Region: clients.StrPtr("us-east-1")}, services.Factory())
+		err := cleaner.StartLogGroupStreamCleanerTask(context.Background(), nil, cleaner.Clients.CloudwatchLogs(cleaner.Config.Region), logGroup, stream)
+		if err == nil {
+			t.Errorf("expected an error for an empty stream inside the retention range")
+		}
+		if len(services.CloudwatchLogs.DeletedLogStreams) != 0 {
+			t.Errorf("stream inside retention must not be deleted: %v", services.CloudwatchLogs.DeletedLogStreams)
+		}
+	})
+
+	t.Run("Cancelled", func(t *testing.T) {
+		services := fakes.ServicesNew()
+		logGroup := &cloudwatchlogs.LogGroup{LogGroupName: clients.StrPtr("group"), RetentionInDays: clients.Int64Ptr(7)}
+		stream := &cloudwatchlogs.LogStream{LogStreamName: clients.StrPtr("expired"), LastEventTimestamp: clients.Int64Ptr(time.Now().UTC().AddDate(0, 0, -30).UnixMilli())}
+		services.CloudwatchLogs.LogStreams["group"] = []*cloudwatchlogs.LogStream{stream}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		cleaner, _ := CleanerNewWithClients(&CleanerConfig{Region: clients.StrPtr("us-east-1")}, services.Factory())
+		err := cleaner.StartLogGroupStreamCleanerTask(ctx, nil, cleaner.Clients.CloudwatchLogs(cleaner.Config.Region), logGroup, stream)
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected the context error, got: %v", err)
+		}
+		if len(services.CloudwatchLogs.DeletedLogStreams) != 0 {
+			t.Errorf("stream must not be deleted after the cancellation: %v", services.CloudwatchLogs.DeletedLogStreams)
+		}
+	})
+}
+
+func TestCleanLogGroupsExpiredFake(t *testing.T) {
+	t.Run("Dry run", func(t *testing.T) {
+		services := fakes.ServicesNew()
+		old := clients.Int64Ptr(time.Now().UTC().UnixMilli() - 30*24*60*60*1000)
+		for _, logGroup := range []*cloudwatchlogs.LogGroup{
+			{LogGroupName: clients.StrPtr("/aws/lambda/api"), RetentionInDays: clients.Int64Ptr(7)},
+			{LogGroupName: clients.StrPtr("/aws/lambda/api-test"), RetentionInDays: clients.Int64Ptr(7)},
+			{LogGroupName: clients.StrPtr("/aws/lambda/forever")},
+			{LogGroupName: clients.StrPtr("/ecs/web"), RetentionInDays: clients.Int64Ptr(7)},
+		} {
+			services.CloudwatchLogs.LogGroups = append(services.CloudwatchLogs.LogGroups, logGroup)
+			services.CloudwatchLogs.LogStreams[*logGroup.LogGroupName] = []*cloudwatchlogs.LogStream{
+				{LogStreamName: clients.StrPtr("first"), LastEventTimestamp: old, StoredBytes: clients.Int64Ptr(100)},
+				{LogStreamName: clients.StrPtr("second"), LastEventTimestamp: old, StoredBytes: clients.Int64Ptr(50)},
+			}
+		}
+		journalPath := t.TempDir() + "/journal.jsonl"
+
+		cleaner, err := CleanerNewWithClients(&CleanerConfig{Region: clients.StrPtr("us-east-1"), DryRun: true, JournalPath: journalPath,
+			IncludeLogGroups: []string{"^/aws/lambda/"}, ExcludeLogGroups: []string{"-test$"}}, services.Factory())
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		err = cleaner.CleanLogGroupsExpired(context.Background())
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if len(services.CloudwatchLogs.DeletedLogStreams) != 0 {
+			t.Errorf("dry run must not delete: %v", services.CloudwatchLogs.DeletedLogStreams)
+		}
+		if len(cleaner.Deletions) != 2 || cleaner.BytesFreed() != 150 {
+			t.Errorf("unexpected deletions: %d, bytes freed: %d", len(cleaner.Deletions), cleaner.BytesFreed())
+		}
+
+		data, err := os.ReadFile(journalPath)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+		if len(lines) != 2 {
+			t.Fatalf("unexpected journal:\n%s", data)
+		}
+		for _, line := range lines {
+			deletion := StreamDeletion{}
+			if err := json.Unmarshal([]byte(line), &deletion); err != nil {
+				t.Fatalf("%v", err)
+			}
+			if !deletion.DryRun || deletion.LogGroupName != "/aws/lambda/api" || deletion.Region != "us-east-1" || deletion.LastEventTimestamp == nil {
+				t.Errorf("unexpected journal entry: %s", line)
+			}
+		}
+	})
+
+	t.Run("Max deletions and age margin", func(t *testing.T) {
+		services := fakes.ServicesNew()
+		now := time.Now().UTC().UnixMilli()
+		dayMilliseconds := int64(24 * 60 * 60 * 1000)
+		logGroup := &cloudwatchlogs.LogGroup{LogGroupName: clients.StrPtr("group"), RetentionInDays: clients.Int64Ptr(7)}
+		streams := []*cloudwatchlogs.LogStream{
+			{LogStreamName: clients.StrPtr("oldest"), LastEventTimestamp: clients.Int64Ptr(now - 40*dayMilliseconds)},
+			{LogStreamName: clients.StrPtr("margin"), LastEventTimestamp: clients.Int64Ptr(now - 10*dayMilliseconds)},
+			{LogStreamName: clients.StrPtr("old"), LastEventTimestamp: clients.Int64Ptr(now - 30*dayMilliseconds)},
+			{LogStreamName: clients.StrPtr("never-written"), CreationTime: clients.Int64Ptr(now - 20*dayMilliseconds)},
+		}
+		services.CloudwatchLogs.LogStreams["group"] = streams
+
+		cleaner, err := CleanerNewWithClients(&CleanerConfig{Region: clients.StrPtr("us-east-1"), MaxDeletions: 2, MinAgeMarginDays: 5}, services.Factory())
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		logsAPI := cleaner.Clients.CloudwatchLogs(cleaner.Config.Region)
+		for _, stream := range streams {
+			err = cleaner.StartLogGroupStreamCleanerTask(context.Background(), nil, logsAPI, logGroup, stream)
+			if err != nil {
+				t.Errorf("%s: %v", *stream.LogStreamName, err)
+			}
+		}
+		deleted := services.CloudwatchLogs.DeletedLogStreams
+		if len(deleted) != 2 || deleted[0] != "group/oldest" || deleted[1] != "group/old" || len(cleaner.Deletions) != 2 {
+			t.Errorf("unexpected deleted streams: %v", deleted)
+		}
+
+		// The limit is per run.
+		services.CloudwatchLogs.LogGroups = []*cloudwatchlogs.LogGroup{logGroup}
+		if err := cleaner.CleanLogGroupsExpired(context.Background()); err != nil {
+			t.Fatalf("%v", err)
+		}
+		deleted = services.CloudwatchLogs.DeletedLogStreams
+		if len(deleted) != 3 || deleted[2] != "group/never-written" {
+			t.Errorf("the next run must delete up to MaxDeletions again: %v", deleted)
+		}
+	})
+
+	t.Run("Invalid config", func(t *testing.T) {
+		for _, config := range []*CleanerConfig{{IncludeLogGroups: []string{"("}}, {ExcludeLogGroups: []string{"["}}, {MaxDeletions: -1}} {
+			if _, err := CleanerNewWithClients(config, fakes.ServicesNew().Factory()); err == nil {
+				t.Errorf("expected an error: %+v", config)
+			}
+		}
+	})
+}
@@ -0,0 +1,50 @@
+package aws_api
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// StreamDeletion is a log stream deleted by the cleaner, or one it would delete in a dry run.
+type StreamDeletion struct {
+	Time               time.Time
+	Region             string
+	LogGroupName       string
+	LogStreamName      string
+	RetentionInDays    int64
+	LastEventTimestamp *time.Time `json:",omitempty"`
+	StoredBytes        int64
+	DryRun             bool
+}
+
+// DeletionJournal appends a JSON line per deleted stream or resource, the file is never truncated.
+type DeletionJournal struct {
+	file    *os.File
+	encoder *json.Encoder
+	lock    sync.Mutex
+}
+
+func DeletionJournalNew(filePath string) (*DeletionJournal, error) {
+	file, err := os.OpenFile(filePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("[journal:DeletionJournalNew] failed to open journal %s\n%w", filePath, err)
+	}
+	return &DeletionJournal{file: file, encoder: json.NewEncoder(file)}, nil
+}
+
+func (journal *DeletionJournal) Write(record any) error {
+	journal.lock.Lock()
+	defer journal.lock.Unlock()
+
+	if err := journal.encoder.Encode(record); err != nil {
+		return fmt.Errorf("[journal:Write] failed to journal %+v\n%w", record, err)
+	}
+	return nil
+}
+
+func (journal *DeletionJournal) Close() error {
+	return journal.file.Close()
+}
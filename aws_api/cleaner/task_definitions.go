@@ -0,0 +1,401 @@
+package aws_api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	clients "github.com/AlexeyBeley/go_misc/aws_api/clients"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/eventbridge"
+	"github.com/aws/aws-sdk-go/service/scheduler"
+)
+
+const DefaultTaskDefinitionsKeep = 5
+
+// TaskDefinitionRevision is a single revision of a task definition family.
+type TaskDefinitionRevision struct {
+	Arn      string
+	Family   string
+	Revision int64
+	Status   string
+	// Services, tasks and schedules running the revision, it is kept when not empty.
+	UsedBy []string `json:",omitempty"`
+}
+
+func (revision *TaskDefinitionRevision) String() string {
+	ret := fmt.Sprintf("%s:%d", revision.Family, revision.Revision)
+	if len(revision.UsedBy) > 0 {
+		ret += ", used by " + strings.Join(revision.UsedBy, ", ")
+	}
+	return ret
+}
+
+// TaskDefinitionFamilyPlan lists the revisions of a family, latest first.
+type TaskDefinitionFamilyPlan struct {
+	Family     string
+	Keep       []*TaskDefinitionRevision
+	Deregister []*TaskDefinitionRevision
+	// The deregistered revisions and the INACTIVE ones, when DeleteTaskDefinitions is set.
+	Delete []*TaskDefinitionRevision
+}
+
+// TaskDefinitionsPlan of a single region, by family name.
+type TaskDefinitionsPlan struct {
+	Region   string
+	Families []*TaskDefinitionFamilyPlan
+}
+
+// String is the dry run report, grouped by family.
+func (plan *TaskDefinitionsPlan) String() string {
+	lines := []string{}
+	deregister, remove := 0, 0
+	for _, family := range plan.Families {
+		lines = append(lines, fmt.Sprintf("%s: keep %d, deregister %d, delete %d", family.Family, len(family.Keep), len(family.Deregister), len(family.Delete)))
+		for _, action := range []struct {
+			name      string
+			revisions []*TaskDefinitionRevision
+		}{{"keep", family.Keep}, {"deregister", family.Deregister}, {"delete", family.Delete}} {
+			for _, revision := range action.revisions {
+				lines = append(lines, fmt.Sprintf("  %s %s", action.name, revision))
+			}
+		}
+		deregister += len(family.Deregister)
+		remove += len(family.Delete)
+	}
+	lines = append(lines, fmt.Sprintf("%s: %d families, %d revisions to deregister, %d to delete", plan.Region, len(plan.Families), deregister, remove))
+	return strings.Join(lines, "\n")
+}
+
+// TaskDefinitionDeletion is a journal record of a deregistered or deleted revision.
+type TaskDefinitionDeletion struct {
+	Time   time.Time
+	DryRun bool
+	// "deregister" or "delete".
+	Action string
+	Region string
+	*TaskDefinitionRevision
+}
+
+// The resource name or id: the last part of the arn.
+func arnName(arn string) string {
+	return arn[strings.LastIndex(arn, "/")+1:]
+}
+
+// parseTaskDefinition splits an arn, family:revision or family into the family and the revision, 0 when missing.
+func parseTaskDefinition(taskDefinition string) (string, int64) {
+	name := arnName(taskDefinition)
+	separator := strings.LastIndex(name, ":")
+	if separator == -1 {
+		return name, 0
+	}
+	revision, err := strconv.ParseInt(name[separator+1:], 10, 64)
+	if err != nil {
+		return name, 0
+	}
+	return name[:separator], revision
+}
+
+func (cleaner *Cleaner) familySelected(family string) bool {
+	if len(cleaner.families) == 0 {
+		return true
+	}
+	for _, pattern := range cleaner.families {
+		if pattern.MatchString(family) {
+			return true
+		}
+	}
+	return false
+}
+
+// taskDefinitionReferences maps family:revision to the services, running tasks, scheduled rules and schedules using it.
+// A reference without a revision runs the latest ACTIVE one, it is always kept.
+func (cleaner *Cleaner) taskDefinitionReferences(ctx context.Context) (map[string][]string, error) {
+	references := map[string][]string{}
+	add := func(taskDefinition *string, by string) {
+		family, revision := parseTaskDefinition(aws.StringValue(taskDefinition))
+		if revision == 0 {
+			return
+		}
+		key := fmt.Sprintf("%s:%d", family, revision)
+		references[key] = append(references[key], by)
+	}
+
+	ecsAPI := cleaner.Clients.ECS(cleaner.Config.Region)
+	for clusterArn, err := range ecsAPI.IterClusterArns(ctx, &ecs.ListClustersInput{}) {
+		if err != nil {
+			return nil, err
+		}
+		clusterName := arnName(aws.StringValue(clusterArn))
+		for service, err := range ecsAPI.IterServices(ctx, &ecs.ListServicesInput{Cluster: clusterArn}) {
+			if err != nil {
+				return nil, err
+			}
+			by := fmt.Sprintf("service %s/%s", clusterName, aws.StringValue(service.ServiceName))
+			add(service.TaskDefinition, by)
+			// A rolling deployment runs both the old and the new revisions.
+			for _, deployment := range service.Deployments {
+				if deployment.TaskDefinition != nil && aws.StringValue(deployment.TaskDefinition) != aws.StringValue(service.TaskDefinition) {
+					add(deployment.TaskDefinition, by)
+				}
+			}
+		}
+		for task, err := range ecsAPI.IterTasks(ctx, &ecs.ListTasksInput{Cluster: clusterArn}) {
+			if err != nil {
+				return nil, err
+			}
+			add(task.TaskDefinitionArn, fmt.Sprintf("task %s/%s", clusterName, arnName(aws.StringValue(task.TaskArn))))
+		}
+	}
+
+	eventBridgeAPI := cleaner.Clients.EventBridge(cleaner.Config.Region)
+	for rule, err := range eventBridgeAPI.IterRules(ctx, &eventbridge.ListRulesInput{}) {
+		if err != nil {
+			return nil, err
+		}
+		for target, err := range eventBridgeAPI.IterTargetsByRule(ctx, &eventbridge.ListTargetsByRuleInput{Rule: rule.Name}) {
+			if err != nil {
+				return nil, err
+			}
+			if target.EcsParameters != nil {
+				add(target.EcsParameters.TaskDefinitionArn, "rule "+aws.StringValue(rule.Name))
+			}
+		}
+	}
+
+	for schedule, err := range cleaner.Clients.Scheduler(cleaner.Config.Region).IterSchedules(ctx, &scheduler.ListSchedulesInput{}) {
+		if err != nil {
+			return nil, err
+		}
+		if schedule.Target != nil && schedule.Target.EcsParameters != nil {
+			add(schedule.Target.EcsParameters.TaskDefinitionArn, fmt.Sprintf("schedule %s/%s", aws.StringValue(schedule.GroupName), aws.StringValue(schedule.Name)))
+		}
+	}
+	return references, nil
+}
+
+// Revisions by family, latest first.
+func (cleaner *Cleaner) listTaskDefinitionRevisions(ctx context.Context, status string) (map[string][]*TaskDefinitionRevision, error) {
+	ret := map[string][]*TaskDefinitionRevision{}
+	api := cleaner.Clients.ECS(cleaner.Config.Region)
+	for arn, err := range api.IterTaskDefinitionArns(ctx, &ecs.ListTaskDefinitionsInput{Status: aws.String(status), Sort: aws.String(ecs.SortOrderDesc)}) {
+		if err != nil {
+			return nil, err
+		}
+		family, revision := parseTaskDefinition(*arn)
+		if !cleaner.familySelected(family) {
+			continue
+		}
+		ret[family] = append(ret[family], &TaskDefinitionRevision{Arn: *arn, Family: family, Revision: revision, Status: status})
+	}
+	for _, revisions := range ret {
+		sort.SliceStable(revisions, func(i, j int) bool { return revisions[i].Revision > revisions[j].Revision })
+	}
+	return ret, nil
+}
+
+// PlanTaskDefinitions keeps the latest TaskDefinitionsKeep revisions of every family and the used ones, nothing is changed.
+func (cleaner *Cleaner) PlanTaskDefinitions(ctx context.Context) (*TaskDefinitionsPlan, error) {
+	errorPrefix := "[task_definitions:PlanTaskDefinitions]"
+	references, err := cleaner.taskDefinitionReferences(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s failed to collect the task definitions references\n%w", errorPrefix, err)
+	}
+	active, err := cleaner.listTaskDefinitionRevisions(ctx, ecs.TaskDefinitionStatusActive)
+	if err != nil {
+		return nil, fmt.Errorf("%s failed to list the active task definitions\n%w", errorPrefix, err)
+	}
+	inactive := map[string][]*TaskDefinitionRevision{}
+	if cleaner.Config.DeleteTaskDefinitions {
+		if inactive, err = cleaner.listTaskDefinitionRevisions(ctx, ecs.TaskDefinitionStatusInactive); err != nil {
+			return nil, fmt.Errorf("%s failed to list the inactive task definitions\n%w", errorPrefix, err)
+		}
+	}
+
+	keep := cleaner.Config.TaskDefinitionsKeep
+	if keep == 0 {
+		keep = DefaultTaskDefinitionsKeep
+	}
+	families := map[string]*TaskDefinitionFamilyPlan{}
+	familyPlan := func(family string) *TaskDefinitionFamilyPlan {
+		if families[family] == nil {
+			families[family] = &TaskDefinitionFamilyPlan{Family: family}
+		}
+		return families[family]
+	}
+
+	for family, revisions := range active {
+		plan := familyPlan(family)
+		for index, revision := range revisions {
+			revision.UsedBy = references[fmt.Sprintf("%s:%d", family, revision.Revision)]
+			switch {
+			case index < keep || len(revision.UsedBy) > 0:
+				plan.Keep = append(plan.Keep, revision)
+			case cleaner.Config.DeleteTaskDefinitions:
+				plan.Deregister = append(plan.Deregister, revision)
+				plan.Delete = append(plan.Delete, revision)
+			default:
+				plan.Deregister = append(plan.Deregister, revision)
+			}
+		}
+	}
+	for family, revisions := range inactive {
+		plan := familyPlan(family)
+		for _, revision := range revisions {
+			revision.UsedBy = references[fmt.Sprintf("%s:%d", family, revision.Revision)]
+			if len(revision.UsedBy) > 0 {
+				plan.Keep = append(plan.Keep, revision)
+			} else {
+				plan.Delete = append(plan.Delete, revision)
+			}
+		}
+	}
+
+	ret := &TaskDefinitionsPlan{Region: aws.StringValue(cleaner.Config.Region)}
+	for _, plan := range families {
+		for _, revisions := range [][]*TaskDefinitionRevision{plan.Keep, plan.Delete} {
+			sort.SliceStable(revisions, func(i, j int) bool { return revisions[i].Revision > revisions[j].Revision })
+		}
+		ret.Families = append(ret.Families, plan)
+	}
+	sort.Slice(ret.Families, func(i, j int) bool { return ret.Families[i].Family < ret.Families[j].Family })
+	return ret, nil
+}
+
+func (cleaner *Cleaner) recordTaskDefinitionDeletion(deletion *TaskDefinitionDeletion) error {
+	cleaner.lock.Lock()
+	cleaner.TaskDefinitionDeletions = append(cleaner.TaskDefinitionDeletions, deletion)
+	cleaner.lock.Unlock()
+
+	if cleaner.journal == nil {
+		return nil
+	}
+	return cleaner.journal.Write(deletion)
+}
+
+// DeleteTaskDefinitions accepts up to 10 revisions per request.
+const taskDefinitionsDeleteBulkSize = 10
+
+// ApplyTaskDefinitionsPlan deregisters and deletes the plan revisions, in a dry run they are only logged and journaled.
+// A revision counts once against MaxDeletions, even when it is both deregistered and deleted.
+func (cleaner *Cleaner) ApplyTaskDefinitionsPlan(ctx context.Context, plan *TaskDefinitionsPlan) error {
+	errorPrefix := "[task_definitions:ApplyTaskDefinitionsPlan]"
+	cleaner.resetDeletions()
+	api := cleaner.Clients.ECS(&plan.Region)
+	record := func(action string, revision *TaskDefinitionRevision) error {
+		return cleaner.recordTaskDefinitionDeletion(&TaskDefinitionDeletion{Time: time.Now().UTC(),
+			DryRun:                 cleaner.Config.DryRun,
+			Action:                 action,
+			Region:                 plan.Region,
+			TaskDefinitionRevision: revision})
+	}
+
+	errs := []error{}
+	for _, family := range plan.Families {
+		if err := ctx.Err(); err != nil {
+			return errors.Join(append(errs, err)...)
+		}
+
+		deregistered := map[string]bool{}
+		for _, revision := range family.Deregister {
+			if !cleaner.reserveDeletion() {
+				lg.InfoF("Max deletions reached, keeping %s", revision)
+				break
+			}
+			if cleaner.Config.DryRun {
+				lg.InfoF("Dry run, task definition to deregister: %s", revision.Arn)
+			} else if err := api.DisposeTaskDefinition(&revision.Arn); err != nil {
+				cleaner.releaseDeletion()
+				errs = append(errs, fmt.Errorf("%s failed to deregister %s\n%w", errorPrefix, revision.Arn, err))
+				continue
+			}
+			deregistered[revision.Arn] = true
+			if err := record("deregister", revision); err != nil {
+				errs = append(errs, err)
+			}
+		}
+
+		toDelete := []*TaskDefinitionRevision{}
+		for _, revision := range family.Delete {
+			if revision.Status == ecs.TaskDefinitionStatusActive {
+				if deregistered[revision.Arn] {
+					toDelete = append(toDelete, revision)
+				}
+				continue
+			}
+			// The revisions deregistered in this run are still deleted.
+			if !cleaner.reserveDeletion() {
+				lg.InfoF("Max deletions reached, keeping %s", revision)
+				continue
+			}
+			toDelete = append(toDelete, revision)
+		}
+		if len(toDelete) == 0 {
+			continue
+		}
+
+		// A failed bulk keeps the bulks deleted before it in the journal.
+		for start := 0; start < len(toDelete); start += taskDefinitionsDeleteBulkSize {
+			bulk := toDelete[start:min(start+taskDefinitionsDeleteBulkSize, len(toDelete))]
+			arns := []*string{}
+			for _, revision := range bulk {
+				arns = append(arns, aws.String(revision.Arn))
+			}
+			if cleaner.Config.DryRun {
+				lg.InfoF("Dry run, task definitions to delete: %v", aws.StringValueSlice(arns))
+			} else if err := api.DeleteTaskDefinitions(arns); err != nil {
+				for _, revision := range bulk {
+					if !deregistered[revision.Arn] {
+						cleaner.releaseDeletion()
+					}
+				}
+				errs = append(errs, fmt.Errorf("%s failed to delete %s revisions\n%w", errorPrefix, family.Family, err))
+				continue
+			}
+			for _, revision := range bulk {
+				if err := record("delete", revision); err != nil {
+					errs = append(errs, err)
+				}
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// PruneTaskDefinitions plans and applies in the cleaner region.
+func (cleaner *Cleaner) PruneTaskDefinitions(ctx context.Context) (*TaskDefinitionsPlan, error) {
+	plan, err := cleaner.PlanTaskDefinitions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	lg.InfoF("Task definitions plan:\n%s", plan)
+
+	closeJournal, err := cleaner.openJournal()
+	if err != nil {
+		return plan, err
+	}
+	defer closeJournal()
+	return plan, cleaner.ApplyTaskDefinitionsPlan(ctx, plan)
+}
+
+// PruneTaskDefinitionsAllTargets prunes the task definitions in every executor target.
+// The config is copied per target, Region and Profile are taken from the target.
+func PruneTaskDefinitionsAllTargets(ctx context.Context, executor *clients.Executor, config *CleanerConfig) *clients.Report[*TaskDefinitionsPlan] {
+	report := clients.Execute(ctx, executor, func(ctx context.Context, target clients.Target, factory *clients.Factory) (*TaskDefinitionsPlan, error) {
+		targetConfig := *config
+		targetConfig.Region = &target.Region
+		targetConfig.Profile = &target.Profile
+		cleaner, err := CleanerNewWithClients(&targetConfig, factory)
+		if err != nil {
+			return nil, err
+		}
+		return cleaner.PruneTaskDefinitions(ctx)
+	})
+	lg.InfoF("Task definitions pruning report: %s", report)
+	return report
+}
@@ -0,0 +1,216 @@
+package aws_api
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	clients "github.com/AlexeyBeley/go_misc/aws_api/clients"
+	"github.com/AlexeyBeley/go_misc/aws_api/clients/fakes"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/eventbridge"
+	"github.com/aws/aws-sdk-go/service/scheduler"
+)
+
+func taskDefinitionArn(family string, revision int64) string {
+	return fmt.Sprintf("arn:aws:ecs:us-east-1:123456789012:task-definition/%s:%d", family, revision)
+}
+
+func taskDefinitionServices() *fakes.Services {
+	services := fakes.ServicesNew()
+	addRevision := func(family string, revision int64, status string) {
+		services.ECS.TaskDefinitions = append(services.ECS.TaskDefinitions, &ecs.TaskDefinition{Family: aws.String(family), Revision: aws.Int64(revision),
+			TaskDefinitionArn: aws.String(taskDefinitionArn(family, revision)), Status: aws.String(status)})
+	}
+	for revision := int64(1); revision <= 10; revision++ {
+		addRevision("web", revision, ecs.TaskDefinitionStatusActive)
+	}
+	addRevision("batch", 1, ecs.TaskDefinitionStatusInactive)
+	addRevision("batch", 2, ecs.TaskDefinitionStatusActive)
+	addRevision("legacy", 1, ecs.TaskDefinitionStatusActive)
+	addRevision("legacy", 2, ecs.TaskDefinitionStatusActive)
+
+	services.ECS.Clusters = []*ecs.Cluster{{ClusterName: aws.String("prod"), ClusterArn: aws.String("arn:aws:ecs:us-east-1:123456789012:cluster/prod")}}
+	services.ECS.Services["prod"] = []*ecs.Service{
+		{ServiceName: aws.String("web"), ServiceArn: aws.String("arn:aws:ecs:us-east-1:123456789012:service/prod/web"),
+			TaskDefinition: aws.String(taskDefinitionArn("web", 10)),
+			Deployments:    []*ecs.Deployment{{TaskDefinition: aws.String(taskDefinitionArn("web", 10))}, {TaskDefinition: aws.String(taskDefinitionArn("web", 2))}}},
+	}
+	services.ECS.Tasks["prod"] = []*ecs.Task{
+		{TaskArn: aws.String("arn:aws:ecs:us-east-1:123456789012:task/prod/0123"), TaskDefinitionArn: aws.String(taskDefinitionArn("web", 4))},
+	}
+	services.EventBridge.Rules = []*eventbridge.Rule{{Name: aws.String("nightly")}, {Name: aws.String("notify")}}
+	services.EventBridge.Targets["nightly"] = []*eventbridge.Target{
+		{Id: aws.String("1"), EcsParameters: &eventbridge.EcsParameters{TaskDefinitionArn: aws.String(taskDefinitionArn("web", 5))}},
+		{Id: aws.String("2"), EcsParameters: &eventbridge.EcsParameters{TaskDefinitionArn: aws.String("arn:aws:ecs:us-east-1:123456789012:task-definition/batch")}},
+	}
+	services.EventBridge.Targets["notify"] = []*eventbridge.Target{{Id: aws.String("1")}}
+	services.Scheduler.Schedules = []*scheduler.GetScheduleOutput{
+		{Name: aws.String("hourly"), GroupName: aws.String("default"),
+			Target: &scheduler.Target{EcsParameters: &scheduler.EcsParameters{TaskDefinitionArn: aws.String(taskDefinitionArn("web", 6))}}},
+	}
+	return services
+}
+
+func taskDefinitionStatuses(services *fakes.Services) string {
+	ret := []string{}
+	for _, taskDefinition := range services.ECS.TaskDefinitions {
+		if *taskDefinition.Status == ecs.TaskDefinitionStatusInactive {
+			ret = append(ret, fmt.Sprintf("%s:%d", *taskDefinition.Family, *taskDefinition.Revision))
+		}
+	}
+	return fmt.Sprintf("%d revisions, inactive: %s", len(services.ECS.TaskDefinitions), strings.Join(ret, ","))
+}
+
+func TestPruneTaskDefinitionsFake(t *testing.T) {
+	config := func() *CleanerConfig {
+		return &CleanerConfig{Region: clients.StrPtr("us-east-1"), TaskDefinitionsKeep: 3, TaskDefinitionFamilies: []string{"^(web|batch)$"}}
+	}
+
+	t.Run("Dry run", func(t *testing.T) {
+		services := taskDefinitionServices()
+		dryRunConfig := config()
+		dryRunConfig.DryRun = true
+		dryRunConfig.DeleteTaskDefinitions = true
+		cleaner, err := CleanerNewWithClients(dryRunConfig, services.Factory())
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		plan, err := cleaner.PruneTaskDefinitions(context.Background())
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+
+		expected := strings.Join([]string{
+			"batch: keep 1, deregister 0, delete 1",
+			"  keep batch:2",
+			"  delete batch:1",
+			"web: keep 7, deregister 3, delete 3",
+			"  keep web:10, used by service prod/web",
+			"  keep web:9",
+			"  keep web:8",
+			"  keep web:6, used by schedule default/hourly",
+			"  keep web:5, used by rule nightly",
+			"  keep web:4, used by task prod/0123",
+			"  keep web:2, used by service prod/web",
+			"  deregister web:7",
+			"  deregister web:3",
+			"  deregister web:1",
+			"  delete web:7",
+			"  delete web:3",
+			"  delete web:1",
+			"us-east-1: 2 families, 3 revisions to deregister, 4 to delete",
+		}, "\n")
+		if plan.String() != expected {
+			t.Errorf("unexpected plan:\n%s\nexpected:\n%s", plan, expected)
+		}
+		if statuses := taskDefinitionStatuses(services); statuses != "14 revisions, inactive: batch:1" {
+			t.Errorf("dry run must not change the task definitions: %s", statuses)
+		}
+		if len(cleaner.TaskDefinitionDeletions) != 7 || !cleaner.TaskDefinitionDeletions[0].DryRun {
+			t.Errorf("unexpected deletions: %d", len(cleaner.TaskDefinitionDeletions))
+		}
+	})
+
+	t.Run("Valid run", func(t *testing.T) {
+		services := taskDefinitionServices()
+		cleaner, err := CleanerNewWithClients(config(), services.Factory())
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		_, err = cleaner.PruneTaskDefinitions(context.Background())
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if statuses := taskDefinitionStatuses(services); statuses != "14 revisions, inactive: web:1,web:3,web:7,batch:1" {
+			t.Errorf("unexpected task definitions: %s", statuses)
+		}
+	})
+
+	t.Run("Delete with max deletions", func(t *testing.T) {
+		services := taskDefinitionServices()
+		deleteConfig := config()
+		deleteConfig.DeleteTaskDefinitions = true
+		deleteConfig.MaxDeletions = 2
+		cleaner, err := CleanerNewWithClients(deleteConfig, services.Factory())
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		_, err = cleaner.PruneTaskDefinitions(context.Background())
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if statuses := taskDefinitionStatuses(services); statuses != "12 revisions, inactive: " {
+			t.Errorf("unexpected task definitions: %s", statuses)
+		}
+		for _, taskDefinition := range services.ECS.TaskDefinitions {
+			if arn := *taskDefinition.TaskDefinitionArn; arn == taskDefinitionArn("batch", 1) || arn == taskDefinitionArn("web", 7) {
+				t.Errorf("%s must be deleted", arn)
+			}
+		}
+	})
+
+	t.Run("Delete deregistered at the limit", func(t *testing.T) {
+		services := fakes.ServicesNew()
+		family := &TaskDefinitionFamilyPlan{Family: "web"}
+		for revision, status := range []string{ecs.TaskDefinitionStatusInactive, ecs.TaskDefinitionStatusActive, ecs.TaskDefinitionStatusActive} {
+			taskDefinition := &TaskDefinitionRevision{Arn: taskDefinitionArn("web", int64(revision)), Family: "web", Revision: int64(revision), Status: status}
+			services.ECS.TaskDefinitions = append(services.ECS.TaskDefinitions, &ecs.TaskDefinition{Family: aws.String("web"), Revision: aws.Int64(int64(revision)),
+				TaskDefinitionArn: aws.String(taskDefinition.Arn), Status: aws.String(status)})
+			if status == ecs.TaskDefinitionStatusActive {
+				family.Deregister = append(family.Deregister, taskDefinition)
+			}
+			family.Delete = append(family.Delete, taskDefinition)
+		}
+		limitConfig := config()
+		limitConfig.MaxDeletions = 2
+		cleaner, err := CleanerNewWithClients(limitConfig, services.Factory())
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		err = cleaner.ApplyTaskDefinitionsPlan(context.Background(), &TaskDefinitionsPlan{Region: "us-east-1", Families: []*TaskDefinitionFamilyPlan{family}})
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if len(services.ECS.TaskDefinitions) != 1 || *services.ECS.TaskDefinitions[0].TaskDefinitionArn != taskDefinitionArn("web", 0) {
+			t.Errorf("the deregistered revisions must be deleted, the INACTIVE one kept: %s", taskDefinitionStatuses(services))
+		}
+		if len(cleaner.TaskDefinitionDeletions) != 4 {
+			t.Errorf("unexpected deletions: %d", len(cleaner.TaskDefinitionDeletions))
+		}
+	})
+
+	t.Run("Partial delete", func(t *testing.T) {
+		services := fakes.ServicesNew()
+		family := &TaskDefinitionFamilyPlan{Family: "batch"}
+		for revision := int64(1); revision <= 12; revision++ {
+			family.Delete = append(family.Delete, &TaskDefinitionRevision{Arn: taskDefinitionArn("batch", revision), Family: "batch", Revision: revision, Status: ecs.TaskDefinitionStatusInactive})
+			// The last revision of the second bulk is missing.
+			if revision < 12 {
+				services.ECS.TaskDefinitions = append(services.ECS.TaskDefinitions, &ecs.TaskDefinition{Family: aws.String("batch"), Revision: aws.Int64(revision),
+					TaskDefinitionArn: aws.String(taskDefinitionArn("batch", revision)), Status: aws.String(ecs.TaskDefinitionStatusInactive)})
+			}
+		}
+		cleaner, err := CleanerNewWithClients(config(), services.Factory())
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		err = cleaner.ApplyTaskDefinitionsPlan(context.Background(), &TaskDefinitionsPlan{Region: "us-east-1", Families: []*TaskDefinitionFamilyPlan{family}})
+		if err == nil {
+			t.Fatalf("expected an error")
+		}
+		if len(cleaner.TaskDefinitionDeletions) != 10 || cleaner.TaskDefinitionDeletions[9].Revision != 10 {
+			t.Errorf("the first bulk must be journaled: %d deletions", len(cleaner.TaskDefinitionDeletions))
+		}
+	})
+
+	t.Run("Invalid config", func(t *testing.T) {
+		for _, config := range []*CleanerConfig{{TaskDefinitionsKeep: -1}, {TaskDefinitionFamilies: []string{"web("}}} {
+			if _, err := CleanerNewWithClients(config, fakes.ServicesNew().Factory()); err == nil {
+				t.Errorf("expected an error: %+v", config)
+			}
+		}
+	})
+}
@@ -0,0 +1,554 @@
+package aws_api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	clients "github.com/AlexeyBeley/go_misc/aws_api/clients"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+// WasteTypes cleaned by CleanWaste, named as the inventory resource types.
+var WasteTypes = []string{"ec2:volume", "ec2:snapshot", "ec2:image", "ec2:elastic-ip", "ec2:network-interface"}
+
+const hoursPerMonth = 730
+
+// DefaultWasteMinAgeDays is the age of the resources deleted when WasteMinAgeDays is not set.
+const DefaultWasteMinAgeDays = 7
+
+// WasteIdleSinceTag is set on the idle elastic ips and network interfaces, they have no creation time.
+// Their age counts from the run that first found them idle.
+const WasteIdleSinceTag = "cleaner:idle-since"
+
+// WastePrices are the monthly prices in USD the cost estimates are based on.
+type WastePrices struct {
+	// By volume type: gp2, gp3, io1...
+	VolumeGiBMonth map[string]float64 `json:"VolumeGiBMonth"`
+	// Charged on the source volume size: an upper bound, the snapshots are incremental.
+	SnapshotGiBMonth float64 `json:"SnapshotGiBMonth"`
+	AddressHour      float64 `json:"AddressHour"`
+}
+
+// DefaultWastePrices are the us-east-1 on-demand prices. Provisioned IOPS and throughput are not estimated.
+func DefaultWastePrices() *WastePrices {
+	return &WastePrices{
+		VolumeGiBMonth:   map[string]float64{"gp2": 0.10, "gp3": 0.08, "io1": 0.125, "io2": 0.125, "st1": 0.045, "sc1": 0.015, "standard": 0.05},
+		SnapshotGiBMonth: 0.05,
+		AddressHour:      0.005}
+}
+
+// WasteCandidate is a resource unused according to its own state: an available volume,
+// an unassociated address... It is deleted only when no other resource references it.
+type WasteCandidate struct {
+	Type        string
+	Id          string
+	Region      string
+	Name        string     `json:",omitempty"`
+	CreatedAt   *time.Time `json:",omitempty"`
+	SizeGiB     int64
+	MonthlyCost float64
+	// Resources referencing the candidate, it is kept when not empty.
+	UsedBy []string `json:",omitempty"`
+	// Snapshots of an image, deleted after it is deregistered.
+	Snapshots []string `json:",omitempty"`
+}
+
+func (candidate *WasteCandidate) String() string {
+	ret := candidate.Type + " " + candidate.Id
+	if candidate.Name != "" {
+		ret += fmt.Sprintf(" (%s)", candidate.Name)
+	}
+	if candidate.SizeGiB > 0 {
+		ret += fmt.Sprintf(" %dGiB", candidate.SizeGiB)
+	}
+	ret += fmt.Sprintf(" $%.2f/month", candidate.MonthlyCost)
+	if len(candidate.UsedBy) > 0 {
+		ret += ", used by " + strings.Join(candidate.UsedBy, ", ")
+	}
+	return ret
+}
+
+// WastePlan of a single region.
+type WastePlan struct {
+	Region string
+	// Most expensive first.
+	Candidates []*WasteCandidate
+	Kept       []*WasteCandidate
+	// Idle for the first time, tagged with WasteIdleSinceTag by ApplyWastePlan.
+	Marked []*WasteCandidate
+}
+
+// MonthlyCost saved by deleting all the candidates.
+func (plan *WastePlan) MonthlyCost() float64 {
+	var ret float64
+	for _, candidate := range plan.Candidates {
+		ret += candidate.MonthlyCost
+	}
+	return ret
+}
+
+func (plan *WastePlan) String() string {
+	lines := []string{}
+	for _, candidate := range plan.Candidates {
+		lines = append(lines, "delete "+candidate.String())
+	}
+	for _, candidate := range plan.Kept {
+		lines = append(lines, "keep "+candidate.String())
+	}
+	for _, candidate := range plan.Marked {
+		lines = append(lines, "mark idle "+candidate.String())
+	}
+	lines = append(lines, fmt.Sprintf("%s: %d resources to delete, $%.2f/month", plan.Region, len(plan.Candidates), plan.MonthlyCost()))
+	return strings.Join(lines, "\n")
+}
+
+// WasteDeletion is a journal record of a deleted resource, or one to delete in a dry run.
+type WasteDeletion struct {
+	Time   time.Time
+	DryRun bool
+	*WasteCandidate
+}
+
+// Lists the candidates of one type, the references are checked by PlanWaste.
+type wasteLister func(ctx context.Context, cleaner *Cleaner, prices *WastePrices, add func(*WasteCandidate)) error
+
+var wasteListers = map[string]wasteLister{
+	"ec2:volume":            listUnattachedVolumes,
+	"ec2:snapshot":          listSnapshots,
+	"ec2:image":             listImages,
+	"ec2:elastic-ip":        listIdleAddresses,
+	"ec2:network-interface": listAvailableNetworkInterfaces,
+}
+
+var wasteDisposers = map[string]func(api *clients.EC2API, candidate *WasteCandidate) error{
+	"ec2:volume": func(api *clients.EC2API, candidate *WasteCandidate) error {
+		return api.DisposeVolume(&candidate.Id)
+	},
+	"ec2:snapshot": func(api *clients.EC2API, candidate *WasteCandidate) error {
+		return api.DisposeSnapshot(&candidate.Id)
+	},
+	"ec2:image": disposeImage,
+	"ec2:elastic-ip": func(api *clients.EC2API, candidate *WasteCandidate) error {
+		return api.DisposeAddress(&candidate.Id)
+	},
+	"ec2:network-interface": func(api *clients.EC2API, candidate *WasteCandidate) error {
+		return api.DisposeNetworkInterface(&candidate.Id)
+	},
+}
+
+// disposeImage deregisters the image and deletes its snapshots, the estimated cost is theirs.
+func disposeImage(api *clients.EC2API, candidate *WasteCandidate) error {
+	if err := api.DisposeImage(&candidate.Id); err != nil {
+		return err
+	}
+	errs := []error{}
+	for _, snapshotId := range candidate.Snapshots {
+		if err := api.DisposeSnapshot(&snapshotId); err != nil {
+			errs = append(errs, fmt.Errorf("snapshot %s of %s\n%w", snapshotId, candidate.Id, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (cleaner *Cleaner) wasteTypes() []string {
+	if len(cleaner.Config.WasteTypes) == 0 {
+		return WasteTypes
+	}
+	return cleaner.Config.WasteTypes
+}
+
+func (cleaner *Cleaner) wasteMinAge() time.Duration {
+	days := cleaner.Config.WasteMinAgeDays
+	if days == 0 {
+		days = DefaultWasteMinAgeDays
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// Resources without a creation time are kept.
+func (cleaner *Cleaner) oldEnough(createdAt *time.Time) bool {
+	return createdAt != nil && time.Since(*createdAt) >= cleaner.wasteMinAge()
+}
+
+// idleSince is the WasteIdleSinceTag time, nil when the resource was not found idle before.
+func idleSince(tags []*ec2.Tag) *time.Time {
+	for _, tag := range tags {
+		if aws.StringValue(tag.Key) != WasteIdleSinceTag {
+			continue
+		}
+		ret, err := time.Parse(time.RFC3339, aws.StringValue(tag.Value))
+		if err != nil {
+			return nil
+		}
+		return &ret
+	}
+	return nil
+}
+
+func ec2Name(tags []*ec2.Tag) string {
+	for _, tag := range tags {
+		if aws.StringValue(tag.Key) == "Name" {
+			return aws.StringValue(tag.Value)
+		}
+	}
+	return ""
+}
+
+func listUnattachedVolumes(ctx context.Context, cleaner *Cleaner, prices *WastePrices, add func(*WasteCandidate)) error {
+	api := cleaner.Clients.EC2(cleaner.Config.Region)
+	for volume, err := range api.IterVolumes(ctx, &ec2.DescribeVolumesInput{
+		Filters: []*ec2.Filter{{Name: aws.String("status"), Values: aws.StringSlice([]string{ec2.VolumeStateAvailable})}},
+	}) {
+		if err != nil {
+			return err
+		}
+		if aws.StringValue(volume.State) != ec2.VolumeStateAvailable || !cleaner.oldEnough(volume.CreateTime) {
+			continue
+		}
+		size := aws.Int64Value(volume.Size)
+		add(&WasteCandidate{Id: *volume.VolumeId, Name: ec2Name(volume.Tags), CreatedAt: volume.CreateTime, SizeGiB: size,
+			MonthlyCost: float64(size) * prices.VolumeGiBMonth[aws.StringValue(volume.VolumeType)]})
+	}
+	return nil
+}
+
+// AWS Backup tags its recovery points, their lifecycle belongs to the backup plan.
+func backupManaged(snapshot *ec2.Snapshot) bool {
+	for _, tag := range snapshot.Tags {
+		if strings.HasPrefix(aws.StringValue(tag.Key), "aws:backup:") {
+			return true
+		}
+	}
+	return strings.HasPrefix(aws.StringValue(snapshot.Description), "This snapshot is created by the AWS Backup service")
+}
+
+func listSnapshots(ctx context.Context, cleaner *Cleaner, prices *WastePrices, add func(*WasteCandidate)) error {
+	api := cleaner.Clients.EC2(cleaner.Config.Region)
+	for snapshot, err := range api.IterSnapshots(ctx, &ec2.DescribeSnapshotsInput{OwnerIds: aws.StringSlice([]string{"self"})}) {
+		if err != nil {
+			return err
+		}
+		if aws.StringValue(snapshot.State) != ec2.SnapshotStateCompleted || !cleaner.oldEnough(snapshot.StartTime) {
+			continue
+		}
+		size := aws.Int64Value(snapshot.VolumeSize)
+		candidate := &WasteCandidate{Id: *snapshot.SnapshotId, Name: ec2Name(snapshot.Tags), CreatedAt: snapshot.StartTime, SizeGiB: size,
+			MonthlyCost: float64(size) * prices.SnapshotGiBMonth}
+		if backupManaged(snapshot) {
+			candidate.UsedBy = []string{"backup plan"}
+		}
+		add(candidate)
+	}
+	return nil
+}
+
+// The image snapshots are billed, the image itself is free. They are deleted with the image.
+func listImages(ctx context.Context, cleaner *Cleaner, prices *WastePrices, add func(*WasteCandidate)) error {
+	api := cleaner.Clients.EC2(cleaner.Config.Region)
+	for image, err := range api.IterImages(ctx, &ec2.DescribeImagesInput{Owners: aws.StringSlice([]string{"self"})}) {
+		if err != nil {
+			return err
+		}
+		createdAt, err := time.Parse(time.RFC3339, aws.StringValue(image.CreationDate))
+		if aws.StringValue(image.State) != ec2.ImageStateAvailable || err != nil || !cleaner.oldEnough(&createdAt) {
+			continue
+		}
+		candidate := &WasteCandidate{Id: *image.ImageId, Name: aws.StringValue(image.Name), CreatedAt: &createdAt}
+		for _, mapping := range image.BlockDeviceMappings {
+			if mapping.Ebs != nil {
+				candidate.SizeGiB += aws.Int64Value(mapping.Ebs.VolumeSize)
+				if mapping.Ebs.SnapshotId != nil {
+					candidate.Snapshots = append(candidate.Snapshots, *mapping.Ebs.SnapshotId)
+				}
+			}
+		}
+		candidate.MonthlyCost = float64(candidate.SizeGiB) * prices.SnapshotGiBMonth
+		add(candidate)
+	}
+	return nil
+}
+
+func listIdleAddresses(ctx context.Context, cleaner *Cleaner, prices *WastePrices, add func(*WasteCandidate)) error {
+	api := cleaner.Clients.EC2(cleaner.Config.Region)
+	for address, err := range api.IterAddresses(ctx, &ec2.DescribeAddressesInput{}) {
+		if err != nil {
+			return err
+		}
+		if address.AllocationId == nil || address.AssociationId != nil || address.NetworkInterfaceId != nil || address.InstanceId != nil {
+			continue
+		}
+		since := idleSince(address.Tags)
+		if since != nil && !cleaner.oldEnough(since) {
+			continue
+		}
+		name := ec2Name(address.Tags)
+		if name == "" {
+			name = aws.StringValue(address.PublicIp)
+		}
+		add(&WasteCandidate{Id: *address.AllocationId, Name: name, CreatedAt: since, MonthlyCost: prices.AddressHour * hoursPerMonth})
+	}
+	return nil
+}
+
+// Network interfaces are free, they are cleaned because they hold subnet addresses.
+func listAvailableNetworkInterfaces(ctx context.Context, cleaner *Cleaner, prices *WastePrices, add func(*WasteCandidate)) error {
+	api := cleaner.Clients.EC2(cleaner.Config.Region)
+	for networkInterface, err := range api.IterNetworkInterfaces(ctx, &ec2.DescribeNetworkInterfacesInput{
+		Filters: []*ec2.Filter{{Name: aws.String("status"), Values: aws.StringSlice([]string{ec2.NetworkInterfaceStatusAvailable})}},
+	}) {
+		if err != nil {
+			return err
+		}
+		since := idleSince(networkInterface.TagSet)
+		if aws.StringValue(networkInterface.Status) != ec2.NetworkInterfaceStatusAvailable || since != nil && !cleaner.oldEnough(since) {
+			continue
+		}
+		candidate := &WasteCandidate{Id: *networkInterface.NetworkInterfaceId, Name: ec2Name(networkInterface.TagSet), CreatedAt: since}
+		if aws.BoolValue(networkInterface.RequesterManaged) {
+			candidate.UsedBy = []string{"requester " + aws.StringValue(networkInterface.RequesterId)}
+		}
+		add(candidate)
+	}
+	return nil
+}
+
+// wasteReferences is the references graph: resource id to the resources using it.
+type wasteReferences map[string][]string
+
+func (references wasteReferences) add(id *string, by string) {
+	if aws.StringValue(id) == "" || slices.Contains(references[*id], by) {
+		return
+	}
+	references[*id] = append(references[*id], by)
+}
+
+// wasteReferences collects every resource that can keep a candidate in use:
+// instances, launch templates versions, auto scaling launch configurations, images, NAT gateways,
+// VPC endpoints and the elastic ips associations.
+func (cleaner *Cleaner) wasteReferences(ctx context.Context) (wasteReferences, error) {
+	references := wasteReferences{}
+	ec2API := cleaner.Clients.EC2(cleaner.Config.Region)
+
+	for instance, err := range ec2API.IterInstances(ctx, &ec2.DescribeInstancesInput{}) {
+		if err != nil {
+			return nil, err
+		}
+		by := "instance " + aws.StringValue(instance.InstanceId)
+		references.add(instance.ImageId, by)
+		for _, mapping := range instance.BlockDeviceMappings {
+			if mapping.Ebs != nil {
+				references.add(mapping.Ebs.VolumeId, by)
+			}
+		}
+		for _, networkInterface := range instance.NetworkInterfaces {
+			references.add(networkInterface.NetworkInterfaceId, by)
+		}
+	}
+
+	// Any version can be pinned by an auto scaling group or launched by hand.
+	for template, err := range ec2API.IterLaunchTemplates(ctx, &ec2.DescribeLaunchTemplatesInput{}) {
+		if err != nil {
+			return nil, err
+		}
+		for version, err := range ec2API.IterLaunchTemplateVersions(ctx, &ec2.DescribeLaunchTemplateVersionsInput{LaunchTemplateId: template.LaunchTemplateId}) {
+			if err != nil {
+				return nil, err
+			}
+			if version.LaunchTemplateData != nil {
+				references.add(version.LaunchTemplateData.ImageId, fmt.Sprintf("launch-template %s:%d", aws.StringValue(template.LaunchTemplateId), aws.Int64Value(version.VersionNumber)))
+			}
+		}
+	}
+
+	autoscalingAPI := cleaner.Clients.Autoscaling(cleaner.Config.Region)
+	launchConfigurationImages := map[string]*string{}
+	for launchConfiguration, err := range autoscalingAPI.IterLaunchConfigurations(ctx, &autoscaling.DescribeLaunchConfigurationsInput{}) {
+		if err != nil {
+			return nil, err
+		}
+		launchConfigurationImages[aws.StringValue(launchConfiguration.LaunchConfigurationName)] = launchConfiguration.ImageId
+	}
+	for group, err := range autoscalingAPI.IterAutoScalingGroups(ctx, &autoscaling.DescribeAutoScalingGroupsInput{}) {
+		if err != nil {
+			return nil, err
+		}
+		if group.LaunchConfigurationName != nil {
+			references.add(launchConfigurationImages[*group.LaunchConfigurationName], "autoscaling-group "+aws.StringValue(group.AutoScalingGroupName))
+		}
+	}
+
+	for image, err := range ec2API.IterImages(ctx, &ec2.DescribeImagesInput{Owners: aws.StringSlice([]string{"self"})}) {
+		if err != nil {
+			return nil, err
+		}
+		for _, mapping := range image.BlockDeviceMappings {
+			if mapping.Ebs != nil {
+				references.add(mapping.Ebs.SnapshotId, "image "+aws.StringValue(image.ImageId))
+			}
+		}
+	}
+
+	for natGateway, err := range ec2API.IterNatGateways(ctx, &ec2.DescribeNatGatewaysInput{}) {
+		if err != nil {
+			return nil, err
+		}
+		if aws.StringValue(natGateway.State) == ec2.NatGatewayStateDeleted {
+			continue
+		}
+		by := "natgateway " + aws.StringValue(natGateway.NatGatewayId)
+		for _, address := range natGateway.NatGatewayAddresses {
+			references.add(address.AllocationId, by)
+			references.add(address.NetworkInterfaceId, by)
+		}
+	}
+
+	for endpoint, err := range ec2API.IterVpcEndpoints(ctx, &ec2.DescribeVpcEndpointsInput{}) {
+		if err != nil {
+			return nil, err
+		}
+		for _, networkInterfaceId := range endpoint.NetworkInterfaceIds {
+			references.add(networkInterfaceId, "vpc-endpoint "+aws.StringValue(endpoint.VpcEndpointId))
+		}
+	}
+
+	for address, err := range ec2API.IterAddresses(ctx, &ec2.DescribeAddressesInput{}) {
+		if err != nil {
+			return nil, err
+		}
+		references.add(address.NetworkInterfaceId, "elastic-ip "+aws.StringValue(address.AllocationId))
+	}
+	return references, nil
+}
+
+// PlanWaste lists the unused resources of the cleaner region, nothing is deleted.
+func (cleaner *Cleaner) PlanWaste(ctx context.Context) (*WastePlan, error) {
+	errorPrefix := "[waste:PlanWaste]"
+	references, err := cleaner.wasteReferences(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s failed to build the references graph\n%w", errorPrefix, err)
+	}
+	prices := cleaner.Config.WastePrices
+	if prices == nil {
+		prices = DefaultWastePrices()
+	}
+
+	plan := &WastePlan{Region: aws.StringValue(cleaner.Config.Region)}
+	for _, wasteType := range cleaner.wasteTypes() {
+		err := wasteListers[wasteType](ctx, cleaner, prices, func(candidate *WasteCandidate) {
+			candidate.Type = wasteType
+			candidate.Region = plan.Region
+			candidate.UsedBy = append(candidate.UsedBy, references[candidate.Id]...)
+			switch {
+			case len(candidate.UsedBy) > 0:
+				plan.Kept = append(plan.Kept, candidate)
+			case candidate.CreatedAt == nil:
+				// An elastic ip or network interface found idle for the first time.
+				plan.Marked = append(plan.Marked, candidate)
+			default:
+				plan.Candidates = append(plan.Candidates, candidate)
+			}
+		})
+		if err != nil {
+			return nil, fmt.Errorf("%s failed to list %s\n%w", errorPrefix, wasteType, err)
+		}
+	}
+	// MaxDeletions keeps the cheapest ones.
+	sort.SliceStable(plan.Candidates, func(i, j int) bool {
+		return plan.Candidates[i].MonthlyCost > plan.Candidates[j].MonthlyCost
+	})
+	return plan, nil
+}
+
+func (cleaner *Cleaner) recordWasteDeletion(deletion *WasteDeletion) error {
+	cleaner.lock.Lock()
+	cleaner.WasteDeletions = append(cleaner.WasteDeletions, deletion)
+	cleaner.lock.Unlock()
+
+	if cleaner.journal == nil {
+		return nil
+	}
+	return cleaner.journal.Write(deletion)
+}
+
+// ApplyWastePlan deletes the plan candidates and marks the first time idle resources,
+// in a dry run they are only logged and the candidates journaled.
+// The service refuses to delete the resources that got used since the plan was made.
+func (cleaner *Cleaner) ApplyWastePlan(ctx context.Context, plan *WastePlan) error {
+	errorPrefix := "[waste:ApplyWastePlan]"
+	cleaner.resetDeletions()
+	api := cleaner.Clients.EC2(&plan.Region)
+	errs := []error{}
+	for index, candidate := range plan.Candidates {
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, err)
+			break
+		}
+		if !cleaner.reserveDeletion() {
+			lg.InfoF("Max deletions reached, keeping %d resources", len(plan.Candidates)-index)
+			break
+		}
+
+		if cleaner.Config.DryRun {
+			lg.InfoF("Dry run, resource to dispose: %s", candidate)
+		} else if err := wasteDisposers[candidate.Type](api, candidate); err != nil {
+			cleaner.releaseDeletion()
+			errs = append(errs, fmt.Errorf("%s failed to dispose %s\n%w", errorPrefix, candidate, err))
+			continue
+		}
+		if err := cleaner.recordWasteDeletion(&WasteDeletion{Time: time.Now().UTC(), DryRun: cleaner.Config.DryRun, WasteCandidate: candidate}); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	idleSince := time.Now().UTC().Format(time.RFC3339)
+	for _, candidate := range plan.Marked {
+		if cleaner.Config.DryRun {
+			lg.InfoF("Dry run, resource to mark idle: %s", candidate)
+			continue
+		}
+		if _, err := api.CreateTags(nil, map[string]string{WasteIdleSinceTag: idleSince}, &candidate.Id, false); err != nil {
+			errs = append(errs, fmt.Errorf("%s failed to mark %s idle\n%w", errorPrefix, candidate, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// CleanWaste plans and applies in the cleaner region.
+func (cleaner *Cleaner) CleanWaste(ctx context.Context) (*WastePlan, error) {
+	plan, err := cleaner.PlanWaste(ctx)
+	if err != nil {
+		return nil, err
+	}
+	lg.InfoF("Waste plan:\n%s", plan)
+
+	closeJournal, err := cleaner.openJournal()
+	if err != nil {
+		return plan, err
+	}
+	defer closeJournal()
+	return plan, cleaner.ApplyWastePlan(ctx, plan)
+}
+
+// CleanWasteAllTargets cleans the unused resources in every executor target.
+// The config is copied per target, Region and Profile are taken from the target.
+func CleanWasteAllTargets(ctx context.Context, executor *clients.Executor, config *CleanerConfig) *clients.Report[*WastePlan] {
+	report := clients.Execute(ctx, executor, func(ctx context.Context, target clients.Target, factory *clients.Factory) (*WastePlan, error) {
+		targetConfig := *config
+		targetConfig.Region = &target.Region
+		targetConfig.Profile = &target.Profile
+		cleaner, err := CleanerNewWithClients(&targetConfig, factory)
+		if err != nil {
+			return nil, err
+		}
+		return cleaner.CleanWaste(ctx)
+	})
+	lg.InfoF("Waste cleaning report: %s", report)
+	return report
+}
@@ -0,0 +1,225 @@
+package aws_api
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	clients "github.com/AlexeyBeley/go_misc/aws_api/clients"
+	"github.com/AlexeyBeley/go_misc/aws_api/clients/fakes"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+func wasteServices() *fakes.Services {
+	old := time.Now().UTC().Add(-60 * 24 * time.Hour)
+	recent := time.Now().UTC().Add(-10 * 24 * time.Hour)
+	oldDate := old.Format(time.RFC3339)
+
+	services := fakes.ServicesNew()
+	services.EC2.Instances = []*ec2.Instance{
+		{InstanceId: aws.String("i-1"), ImageId: aws.String("ami-used"),
+			BlockDeviceMappings: []*ec2.InstanceBlockDeviceMapping{{Ebs: &ec2.EbsInstanceBlockDevice{VolumeId: aws.String("vol-referenced")}}}},
+	}
+	services.EC2.Volumes = []*ec2.Volume{
+		{VolumeId: aws.String("vol-old"), State: aws.String("available"), VolumeType: aws.String("gp3"), Size: aws.Int64(100), CreateTime: &old},
+		{VolumeId: aws.String("vol-recent"), State: aws.String("available"), VolumeType: aws.String("gp3"), Size: aws.Int64(100), CreateTime: &recent},
+		{VolumeId: aws.String("vol-in-use"), State: aws.String("in-use"), VolumeType: aws.String("gp3"), Size: aws.Int64(100), CreateTime: &old},
+		{VolumeId: aws.String("vol-referenced"), State: aws.String("available"), VolumeType: aws.String("gp2"), Size: aws.Int64(10), CreateTime: &old},
+	}
+	services.EC2.Snapshots = []*ec2.Snapshot{
+		{SnapshotId: aws.String("snap-orphan"), State: aws.String("completed"), VolumeSize: aws.Int64(50), StartTime: &old},
+		{SnapshotId: aws.String("snap-image"), State: aws.String("completed"), VolumeSize: aws.Int64(8), StartTime: &old},
+		{SnapshotId: aws.String("snap-backup"), State: aws.String("completed"), VolumeSize: aws.Int64(8), StartTime: &old,
+			Tags: []*ec2.Tag{{Key: aws.String("aws:backup:source-resource"), Value: aws.String("vol-old")}}},
+	}
+	services.EC2.Images = []*ec2.Image{
+		{ImageId: aws.String("ami-unused"), State: aws.String("available"), CreationDate: &oldDate,
+			BlockDeviceMappings: []*ec2.BlockDeviceMapping{{Ebs: &ec2.EbsBlockDevice{SnapshotId: aws.String("snap-image"), VolumeSize: aws.Int64(8)}}}},
+		{ImageId: aws.String("ami-used"), State: aws.String("available"), CreationDate: &oldDate},
+		{ImageId: aws.String("ami-template"), State: aws.String("available"), CreationDate: &oldDate},
+		{ImageId: aws.String("ami-launch-configuration"), State: aws.String("available"), CreationDate: &oldDate},
+	}
+	services.EC2.LaunchTemplates = []*ec2.LaunchTemplate{{LaunchTemplateId: aws.String("lt-1")}}
+	services.EC2.LaunchTemplateVersions = []*ec2.LaunchTemplateVersion{
+		{LaunchTemplateId: aws.String("lt-1"), VersionNumber: aws.Int64(1), LaunchTemplateData: &ec2.ResponseLaunchTemplateData{ImageId: aws.String("ami-template")}},
+		{LaunchTemplateId: aws.String("lt-1"), VersionNumber: aws.Int64(2), LaunchTemplateData: &ec2.ResponseLaunchTemplateData{ImageId: aws.String("ami-used")}},
+	}
+	services.Autoscaling.LaunchConfigurations = []*autoscaling.LaunchConfiguration{
+		{LaunchConfigurationName: aws.String("web"), ImageId: aws.String("ami-launch-configuration")},
+	}
+	services.Autoscaling.AutoScalingGroups = []*autoscaling.Group{{AutoScalingGroupName: aws.String("web"), LaunchConfigurationName: aws.String("web")}}
+	idleSince := []*ec2.Tag{{Key: aws.String(WasteIdleSinceTag), Value: aws.String(oldDate)}}
+	services.EC2.Addresses = []*ec2.Address{
+		{AllocationId: aws.String("eipalloc-idle"), PublicIp: aws.String("198.51.100.1"), Tags: idleSince},
+		{AllocationId: aws.String("eipalloc-new"), PublicIp: aws.String("198.51.100.2")},
+		{AllocationId: aws.String("eipalloc-associated"), AssociationId: aws.String("eipassoc-1"), NetworkInterfaceId: aws.String("eni-in-use")},
+		{AllocationId: aws.String("eipalloc-nat")},
+	}
+	services.EC2.NatGateways = []*ec2.NatGateway{
+		{NatGatewayId: aws.String("nat-1"), State: aws.String("available"), NatGatewayAddresses: []*ec2.NatGatewayAddress{{AllocationId: aws.String("eipalloc-nat")}}},
+	}
+	services.EC2.NetworkInterfaces = []*ec2.NetworkInterface{
+		{NetworkInterfaceId: aws.String("eni-available"), Status: aws.String("available"), TagSet: idleSince},
+		{NetworkInterfaceId: aws.String("eni-new"), Status: aws.String("available")},
+		{NetworkInterfaceId: aws.String("eni-in-use"), Status: aws.String("in-use")},
+		{NetworkInterfaceId: aws.String("eni-lambda"), Status: aws.String("available"), RequesterManaged: aws.Bool(true), RequesterId: aws.String("lambda")},
+		{NetworkInterfaceId: aws.String("eni-endpoint"), Status: aws.String("available")},
+	}
+	services.EC2.VpcEndpoints = []*ec2.VpcEndpoint{{VpcEndpointId: aws.String("vpce-1"), NetworkInterfaceIds: aws.StringSlice([]string{"eni-endpoint"})}}
+	return services
+}
+
+func candidateIds(candidates []*WasteCandidate) string {
+	ids := []string{}
+	for _, candidate := range candidates {
+		ids = append(ids, candidate.Id)
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestPlanWasteFake(t *testing.T) {
+	t.Run("Valid run", func(t *testing.T) {
+		services := wasteServices()
+		cleaner, err := CleanerNewWithClients(&CleanerConfig{Region: clients.StrPtr("us-east-1"), WasteMinAgeDays: 30}, services.Factory())
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		plan, err := cleaner.PlanWaste(context.Background())
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+
+		if ids := candidateIds(plan.Candidates); ids != "vol-old,eipalloc-idle,snap-orphan,ami-unused,eni-available" {
+			t.Errorf("unexpected candidates: %s", ids)
+		}
+		if ids := candidateIds(plan.Kept); ids != "vol-referenced,snap-image,snap-backup,ami-used,ami-template,ami-launch-configuration,eipalloc-nat,eni-lambda,eni-endpoint" {
+			t.Errorf("unexpected kept: %s", ids)
+		}
+		if ids := candidateIds(plan.Marked); ids != "eipalloc-new,eni-new" {
+			t.Errorf("unexpected marked: %s", ids)
+		}
+		if math.Abs(plan.MonthlyCost()-14.55) > 0.001 {
+			t.Errorf("unexpected monthly cost: %f", plan.MonthlyCost())
+		}
+		if plan.Kept[3].String() != "ec2:image ami-used $0.00/month, used by instance i-1, launch-template lt-1:2" {
+			t.Errorf("unexpected kept image: %s", plan.Kept[3])
+		}
+	})
+
+	t.Run("Invalid config", func(t *testing.T) {
+		for _, config := range []*CleanerConfig{{WasteTypes: []string{"ec2:instance"}}, {WasteMinAgeDays: -1}} {
+			if _, err := CleanerNewWithClients(config, fakes.ServicesNew().Factory()); err == nil {
+				t.Errorf("expected an error: %+v", config)
+			}
+		}
+	})
+
+	t.Run("Default min age", func(t *testing.T) {
+		services := wasteServices()
+		now := time.Now().UTC()
+		services.EC2.Volumes = append(services.EC2.Volumes, &ec2.Volume{VolumeId: aws.String("vol-new"), State: aws.String("available"),
+			VolumeType: aws.String("gp3"), Size: aws.Int64(100), CreateTime: &now})
+		cleaner, err := CleanerNewWithClients(&CleanerConfig{Region: clients.StrPtr("us-east-1"), WasteTypes: []string{"ec2:volume"}}, services.Factory())
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		plan, err := cleaner.PlanWaste(context.Background())
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if ids := candidateIds(plan.Candidates); ids != "vol-old,vol-recent" {
+			t.Errorf("unexpected candidates: %s", ids)
+		}
+	})
+}
+
+func TestCleanWasteFake(t *testing.T) {
+	t.Run("Dry run", func(t *testing.T) {
+		services := wasteServices()
+		journalPath := t.TempDir() + "/journal.jsonl"
+		cleaner, err := CleanerNewWithClients(&CleanerConfig{Region: clients.StrPtr("us-east-1"), WasteMinAgeDays: 30, DryRun: true, JournalPath: journalPath}, services.Factory())
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		_, err = cleaner.CleanWaste(context.Background())
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if len(services.EC2.Volumes) != 4 || len(services.EC2.Snapshots) != 3 || len(services.EC2.Images) != 4 ||
+			len(services.EC2.Addresses) != 4 || len(services.EC2.NetworkInterfaces) != 5 {
+			t.Errorf("dry run must not delete")
+		}
+
+		data, err := os.ReadFile(journalPath)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+		deletion := WasteDeletion{}
+		if err := json.Unmarshal([]byte(lines[0]), &deletion); err != nil {
+			t.Fatalf("%v", err)
+		}
+		if len(lines) != 5 || !deletion.DryRun || deletion.Id != "vol-old" || deletion.MonthlyCost != 8 {
+			t.Errorf("unexpected journal:\n%s", data)
+		}
+	})
+
+	t.Run("Max deletions", func(t *testing.T) {
+		services := wasteServices()
+		cleaner, err := CleanerNewWithClients(&CleanerConfig{Region: clients.StrPtr("us-east-1"), WasteMinAgeDays: 30, MaxDeletions: 2}, services.Factory())
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		_, err = cleaner.CleanWaste(context.Background())
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if len(services.EC2.Volumes) != 3 || len(services.EC2.Addresses) != 3 || len(services.EC2.Snapshots) != 3 || len(cleaner.WasteDeletions) != 2 {
+			t.Errorf("only the two most expensive resources must be deleted: %d", len(cleaner.WasteDeletions))
+		}
+	})
+
+	t.Run("Valid run", func(t *testing.T) {
+		services := wasteServices()
+		executor := clients.ExecutorNewWithTargets([]clients.Target{{Region: "us-east-1"}}, 1)
+		executor.FactoryNew = func(clients.Target) (*clients.Factory, error) { return services.Factory(), nil }
+
+		report := CleanWasteAllTargets(context.Background(), executor, &CleanerConfig{WasteMinAgeDays: 30})
+		if err := report.Err(); err != nil {
+			t.Fatalf("%v", err)
+		}
+		remaining := []string{}
+		for _, volume := range services.EC2.Volumes {
+			remaining = append(remaining, *volume.VolumeId)
+		}
+		for _, networkInterface := range services.EC2.NetworkInterfaces {
+			remaining = append(remaining, *networkInterface.NetworkInterfaceId)
+		}
+		if strings.Join(remaining, ",") != "vol-recent,vol-in-use,vol-referenced,eni-new,eni-in-use,eni-lambda,eni-endpoint" ||
+			len(services.EC2.Images) != 3 || len(services.EC2.Addresses) != 3 {
+			t.Errorf("unexpected remaining resources: %v", remaining)
+		}
+		// The image snapshot is deleted with the image.
+		if len(services.EC2.Snapshots) != 1 || *services.EC2.Snapshots[0].SnapshotId != "snap-backup" {
+			t.Errorf("unexpected remaining snapshots: %v", services.EC2.Snapshots)
+		}
+
+		// The first time idle resources are marked, they are deleted once old enough.
+		addresses, err := clients.Collect(clients.EC2APINewWithService(services.EC2).IterAddresses(context.Background(), &ec2.DescribeAddressesInput{}))
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		for _, address := range addresses {
+			if *address.AllocationId == "eipalloc-new" && idleSince(address.Tags) == nil {
+				t.Errorf("idle address was not marked: %v", address.Tags)
+			}
+		}
+
+	})
+}
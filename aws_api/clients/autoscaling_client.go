@@ -1,13 +1,15 @@
 package aws_api
 
 import (
+	"context"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"iter"
 )
 
 type AutoscalingAPI struct {
-	svc *autoscaling.AutoScaling
+	svc AutoscalingService
 }
 
 func GetAutoscalingAPI(region *string, profileName *string) *AutoscalingAPI {
@@ -26,24 +28,29 @@ func GetAutoscalingAPI(region *string, profileName *string) *AutoscalingAPI {
 	return &ret
 }
 
+func AutoscalingAPINewWithService(svc AutoscalingService) *AutoscalingAPI {
+	return &AutoscalingAPI{svc: svc}
+}
+
+func (api *AutoscalingAPI) IterAutoScalingGroups(ctx context.Context, Input *autoscaling.DescribeAutoScalingGroupsInput) iter.Seq2[*autoscaling.Group, error] {
+	return Paginate(ctx, func(callback func(*autoscaling.DescribeAutoScalingGroupsOutput, bool) bool) error {
+		return api.svc.DescribeAutoScalingGroupsPages(Input, callback)
+	}, Items(func(page *autoscaling.DescribeAutoScalingGroupsOutput) []*autoscaling.Group {
+		return page.AutoScalingGroups
+	}))
+}
+
+// Deprecated: use IterAutoScalingGroups.
 func (api *AutoscalingAPI) DescribeAutoScalingGroups(callback GenericCallback, Input *autoscaling.DescribeAutoScalingGroupsInput) error {
-	var callbackErr error
-	pageNum := 0
-	err := api.svc.DescribeAutoScalingGroupsPages(Input, func(page *autoscaling.DescribeAutoScalingGroupsOutput, notHasNextPage bool) bool {
-		pageNum++
-		for _, obj := range page.AutoScalingGroups {
-			err := callback(obj)
-			if err != nil {
-				callbackErr = err
-				return false
-			}
-		}
-		return !notHasNextPage
-	})
-	if callbackErr != nil {
-		return callbackErr
-	}
-	return err
+	return yieldToCallback(api.IterAutoScalingGroups(context.Background(), Input), callback)
+}
+
+func (api *AutoscalingAPI) IterLaunchConfigurations(ctx context.Context, Input *autoscaling.DescribeLaunchConfigurationsInput) iter.Seq2[*autoscaling.LaunchConfiguration, error] {
+	return Paginate(ctx, func(callback func(*autoscaling.DescribeLaunchConfigurationsOutput, bool) bool) error {
+		return api.svc.DescribeLaunchConfigurationsPages(Input, callback)
+	}, Items(func(page *autoscaling.DescribeLaunchConfigurationsOutput) []*autoscaling.LaunchConfiguration {
+		return page.LaunchConfigurations
+	}))
 }
 
 func (api *AutoscalingAPI) CreateOrUpdateTags(existingTags []*autoscaling.Tag, AddTags map[string]string, resource, resourceType *string, declarative bool) error {
@@ -63,6 +70,10 @@ func (api *AutoscalingAPI) CreateOrUpdateTags(existingTags []*autoscaling.Tag, A
 		}
 	}
 
+	if len(Tags) == 0 {
+		return nil
+	}
+
 	req := autoscaling.CreateOrUpdateTagsInput{Tags: Tags}
 	lg.InfoF("Adding tags: resource: %s, tags: %v, Current tags: %v", *resource, Tags, existingTags)
 	_, err := api.svc.CreateOrUpdateTags(&req)
@@ -1,13 +1,15 @@
 package aws_api
 
 import (
+	"context"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"iter"
 )
 
 type CloudwatchAPI struct {
-	svc *cloudwatch.CloudWatch
+	svc CloudwatchService
 }
 
 func CloudwatchAPINew(region, profileName *string) *CloudwatchAPI {
@@ -23,23 +25,19 @@ func CloudwatchAPINew(region, profileName *string) *CloudwatchAPI {
 	return &ret
 }
 
-func (api *CloudwatchAPI) GetMetricAlarms(callback GenericCallback, Input *cloudwatch.DescribeAlarmsInput) error {
-	var callbackErr error
-	pageNum := 0
-	err := api.svc.DescribeAlarmsPages(Input, func(page *cloudwatch.DescribeAlarmsOutput, notHasNextPage bool) bool {
+func CloudwatchAPINewWithService(svc CloudwatchService) *CloudwatchAPI {
+	return &CloudwatchAPI{svc: svc}
+}
 
-		pageNum++
-		for _, object := range page.MetricAlarms {
-			if callbackErr = callback(object); callbackErr != nil {
-				return false
-			}
-		}
-		return !notHasNextPage
-	})
-	if callbackErr != nil {
-		return callbackErr
-	}
-	return err
+func (api *CloudwatchAPI) IterMetricAlarms(ctx context.Context, Input *cloudwatch.DescribeAlarmsInput) iter.Seq2[*cloudwatch.MetricAlarm, error] {
+	return Paginate(ctx, func(callback func(*cloudwatch.DescribeAlarmsOutput, bool) bool) error {
+		return api.svc.DescribeAlarmsPages(Input, callback)
+	}, Items(func(page *cloudwatch.DescribeAlarmsOutput) []*cloudwatch.MetricAlarm { return page.MetricAlarms }))
+}
+
+// Deprecated: use IterMetricAlarms.
+func (api *CloudwatchAPI) GetMetricAlarms(callback GenericCallback, Input *cloudwatch.DescribeAlarmsInput) error {
+	return yieldToCallback(api.IterMetricAlarms(context.Background(), Input), callback)
 }
 
 func (api *CloudwatchAPI) GetTags(Input *cloudwatch.ListTagsForResourceInput) (map[string]*string, error) {
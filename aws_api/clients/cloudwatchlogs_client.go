@@ -1,11 +1,14 @@
 package aws_api
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"iter"
 	"os"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
 )
@@ -29,7 +32,7 @@ func LoadConfig(configFilePath string) (config Configuration, err error) {
 }
 
 type CloudwatchLogsAPI struct {
-	svc *cloudwatchlogs.CloudWatchLogs
+	svc CloudwatchLogsService
 }
 
 func CloudwatchLogsAPINew(region *string, profileName *string) *CloudwatchLogsAPI {
@@ -45,12 +48,26 @@ func CloudwatchLogsAPINew(region *string, profileName *string) *CloudwatchLogsAP
 	return &ret
 }
 
+func CloudwatchLogsAPINewWithService(svc CloudwatchLogsService) *CloudwatchLogsAPI {
+	return &CloudwatchLogsAPI{svc: svc}
+}
+
 func (api *CloudwatchLogsAPI) ProvisionLogGroup(logGroupName string) (*cloudwatchlogs.CreateLogGroupOutput, error) {
 	input := cloudwatchlogs.CreateLogGroupInput{LogGroupName: &logGroupName}
 	response, err := api.svc.CreateLogGroup(&input)
 	return response, err
 }
 
+// An already deleted log group is not an error.
+func (api *CloudwatchLogsAPI) DisposeLogGroup(logGroupName *string) error {
+	lg.InfoF("Disposing log group %s", *logGroupName)
+	_, err := api.svc.DeleteLogGroup(&cloudwatchlogs.DeleteLogGroupInput{LogGroupName: logGroupName})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == cloudwatchlogs.ErrCodeResourceNotFoundException {
+		return nil
+	}
+	return err
+}
+
 func GetCloudwatchLogClient(region *string) *cloudwatchlogs.CloudWatchLogs {
 	sess := session.Must(session.NewSessionWithOptions(session.Options{
 		SharedConfigState: session.SharedConfigEnable,
@@ -102,7 +119,7 @@ func FetchCloudwatchLogStream(region, logGroupName, streamName string) error {
 	return nil
 }
 
-func GetLogEventsRaw(svc *cloudwatchlogs.CloudWatchLogs, limit *int64, logGroupName *string, logStreamName *string, NextToken *string, StartTime, EndTime *int64) (*cloudwatchlogs.GetLogEventsOutput, error) {
+func GetLogEventsRaw(svc CloudwatchLogsService, limit *int64, logGroupName *string, logStreamName *string, NextToken *string, StartTime, EndTime *int64) (*cloudwatchlogs.GetLogEventsOutput, error) {
 
 	StartFromHead := true
 	Unmask := true
@@ -169,74 +186,56 @@ func YieldCloudwatchLogStream(region, logGroupName, streamName, nextToken *strin
 
 type StringCallback func(string) error
 
-func (api *CloudwatchLogsAPI) YieldCloudwatchLogStreams(input *cloudwatchlogs.DescribeLogStreamsInput, callback GenericCallbackNG) error {
-
-	if *input.LogGroupName == "" {
-		return fmt.Errorf("you must supply a log group name: '%s'", *input.LogGroupName)
+// Up to 50 streams per page unless the input sets the Limit.
+func (api *CloudwatchLogsAPI) IterLogStreams(ctx context.Context, input *cloudwatchlogs.DescribeLogStreamsInput) iter.Seq2[*cloudwatchlogs.LogStream, error] {
+	if input.LogGroupName == nil || *input.LogGroupName == "" {
+		return Single(ctx, func() ([]*cloudwatchlogs.LogStream, error) {
+			return nil, fmt.Errorf("you must supply a log group name")
+		})
 	}
 	if input.Limit == nil {
 		input.Limit = Int64Ptr(50)
 	}
 
-	var callbackErr error
 	pageNum := 0
-	err := api.svc.DescribeLogStreamsPages(input, func(page *cloudwatchlogs.DescribeLogStreamsOutput, notHasNextPage bool) bool {
-		// stop when returns False
+	return Paginate(ctx, func(callback func(*cloudwatchlogs.DescribeLogStreamsOutput, bool) bool) error {
+		return api.svc.DescribeLogStreamsPages(input, callback)
+	}, Items(func(page *cloudwatchlogs.DescribeLogStreamsOutput) []*cloudwatchlogs.LogStream {
 		lg.DebugF("DescribeLogStreamsPages, page %d", pageNum)
 		pageNum++
-		for _, logStream := range page.LogStreams {
-			if continuePagination, err := callback(logStream); !continuePagination {
-				callbackErr = err
-				return false
-			}
-		}
-		return !notHasNextPage
-	})
-
-	if err != nil {
-		return err
-	}
-
-	if callbackErr != nil {
-		return callbackErr
-	}
-
-	return nil
+		return page.LogStreams
+	}))
 }
 
-func (api *CloudwatchLogsAPI) YieldStreamEvents(input *cloudwatchlogs.GetLogEventsInput, callback GenericCallbackNG) error {
+// Deprecated: use IterLogStreams.
+func (api *CloudwatchLogsAPI) YieldCloudwatchLogStreams(input *cloudwatchlogs.DescribeLogStreamsInput, callback GenericCallbackNG) error {
+	return yieldToCallbackNG(api.IterLogStreams(context.Background(), input), callback)
+}
 
-	if *input.LogGroupName == "" {
-		return fmt.Errorf("you must supply a log group name: '%s'", *input.LogGroupName)
+// Up to 10000 events per page unless the input sets the Limit.
+func (api *CloudwatchLogsAPI) IterStreamEvents(ctx context.Context, input *cloudwatchlogs.GetLogEventsInput) iter.Seq2[*cloudwatchlogs.OutputLogEvent, error] {
+	if input.LogGroupName == nil || *input.LogGroupName == "" {
+		return Single(ctx, func() ([]*cloudwatchlogs.OutputLogEvent, error) {
+			return nil, fmt.Errorf("you must supply a log group name")
+		})
 	}
 	if input.Limit == nil {
 		input.Limit = Int64Ptr(10000)
 	}
 
-	var callbackErr error
 	pageNum := 0
-	err := api.svc.GetLogEventsPages(input, func(page *cloudwatchlogs.GetLogEventsOutput, notHasNextPage bool) bool {
-		// stop when returns False
+	return Paginate(ctx, func(callback func(*cloudwatchlogs.GetLogEventsOutput, bool) bool) error {
+		return api.svc.GetLogEventsPages(input, callback)
+	}, Items(func(page *cloudwatchlogs.GetLogEventsOutput) []*cloudwatchlogs.OutputLogEvent {
 		lg.DebugF("GetLogEventsPages, page %d", pageNum)
 		pageNum++
-		for _, event := range page.Events {
-			if continuePagination, err := callback(event); !continuePagination {
-				callbackErr = err
-				return false
-			}
-		}
-		return !notHasNextPage
-	})
-
-	if err != nil {
-		return err
-	}
-
-	if callbackErr != nil {
-		return callbackErr
-	}
+		return page.Events
+	}))
+}
 
-	return nil
+// Deprecated: use IterStreamEvents.
+func (api *CloudwatchLogsAPI) YieldStreamEvents(input *cloudwatchlogs.GetLogEventsInput, callback GenericCallbackNG) error {
+	return yieldToCallbackNG(api.IterStreamEvents(context.Background(), input), callback)
 }
 
 func (api *CloudwatchLogsAPI) GetLogGroup(name *string) (logGroup *cloudwatchlogs.LogGroup, err error) {
@@ -255,42 +254,19 @@ func (api *CloudwatchLogsAPI) GetLogGroup(name *string) (logGroup *cloudwatchlog
 	return logGroup, err
 }
 
-func (api *CloudwatchLogsAPI) YieldLogGroups(callback GenericCallbackNG, Input *cloudwatchlogs.DescribeLogGroupsInput) error {
-	var callbackErr error
-	pageNum := 0
-	err := api.svc.DescribeLogGroupsPages(Input, func(page *cloudwatchlogs.DescribeLogGroupsOutput, notHasNextPage bool) bool {
+func (api *CloudwatchLogsAPI) IterLogGroups(ctx context.Context, Input *cloudwatchlogs.DescribeLogGroupsInput) iter.Seq2[*cloudwatchlogs.LogGroup, error] {
+	return Paginate(ctx, func(callback func(*cloudwatchlogs.DescribeLogGroupsOutput, bool) bool) error {
+		return api.svc.DescribeLogGroupsPages(Input, callback)
+	}, Items(func(page *cloudwatchlogs.DescribeLogGroupsOutput) []*cloudwatchlogs.LogGroup { return page.LogGroups }))
+}
 
-		pageNum++
-		for _, logGroup := range page.LogGroups {
-			if continuePagination, err := callback(logGroup); !continuePagination {
-				callbackErr = err
-				return false
-			}
-		}
-		return !notHasNextPage
-	})
-	if callbackErr != nil {
-		return callbackErr
-	}
-	return err
+// Deprecated: use IterLogGroups.
+func (api *CloudwatchLogsAPI) YieldLogGroups(callback GenericCallbackNG, Input *cloudwatchlogs.DescribeLogGroupsInput) error {
+	return yieldToCallbackNG(api.IterLogGroups(context.Background(), Input), callback)
 }
 
 func (api *CloudwatchLogsAPI) GetLogGroups(Input *cloudwatchlogs.DescribeLogGroupsInput) ([]*cloudwatchlogs.LogGroup, error) {
-	objects := []any{}
-	err := api.YieldLogGroups(AggregatorInitializerNG(&objects), nil)
-	if err != nil {
-		return nil, err
-	}
-	logGroups := []*cloudwatchlogs.LogGroup{}
-	for _, objAny := range objects {
-		obj, ok := objAny.(*cloudwatchlogs.LogGroup)
-		if !ok {
-			return nil, fmt.Errorf("cast error: %v", objAny)
-		}
-		logGroups = append(logGroups, obj)
-	}
-
-	return logGroups, nil
+	return Collect(api.IterLogGroups(context.Background(), Input))
 }
 
 func (api *CloudwatchLogsAPI) GetTags(Input *cloudwatchlogs.ListTagsForResourceInput) (map[string]*string, error) {
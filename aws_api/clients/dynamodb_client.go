@@ -1,7 +1,8 @@
 package aws_api
 
 import (
-	"fmt"
+	"context"
+	"iter"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
@@ -9,7 +10,7 @@ import (
 )
 
 type DynamoDBAPI struct {
-	svc *dynamodb.DynamoDB
+	svc DynamoDBService
 }
 
 func DynamoDBAPINew(region *string, profileName *string) *DynamoDBAPI {
@@ -25,44 +26,34 @@ func DynamoDBAPINew(region *string, profileName *string) *DynamoDBAPI {
 	return &ret
 }
 
-func (api *DynamoDBAPI) YieldTables(callback GenericCallback, Input *dynamodb.ListTablesInput) error {
-	var callbackErr error
-	pageNum := 0
-	err := api.svc.ListTablesPages(Input, func(page *dynamodb.ListTablesOutput, notHasNextPage bool) bool {
+func DynamoDBAPINewWithService(svc DynamoDBService) *DynamoDBAPI {
+	return &DynamoDBAPI{svc: svc}
+}
 
-		pageNum++
+// Describes every listed table.
+func (api *DynamoDBAPI) IterTables(ctx context.Context, Input *dynamodb.ListTablesInput) iter.Seq2[*dynamodb.TableDescription, error] {
+	return Paginate(ctx, func(callback func(*dynamodb.ListTablesOutput, bool) bool) error {
+		return api.svc.ListTablesPages(Input, callback)
+	}, func(page *dynamodb.ListTablesOutput) ([]*dynamodb.TableDescription, error) {
+		ret := []*dynamodb.TableDescription{}
 		for _, objName := range page.TableNames {
 			obj, err := api.svc.DescribeTable(&dynamodb.DescribeTableInput{TableName: objName})
 			if err != nil {
-				callbackErr = err
-				return false
-			}
-
-			if callbackErr = callback(obj.Table); callbackErr != nil {
-				return false
+				return nil, err
 			}
+			ret = append(ret, obj.Table)
 		}
-		return !notHasNextPage
+		return ret, nil
 	})
-	if callbackErr != nil {
-		return callbackErr
-	}
-	return err
+}
+
+// Deprecated: use IterTables.
+func (api *DynamoDBAPI) YieldTables(callback GenericCallback, Input *dynamodb.ListTablesInput) error {
+	return yieldToCallback(api.IterTables(context.Background(), Input), callback)
 }
 
 func (api *DynamoDBAPI) GetTables(Input *dynamodb.ListTablesInput) ([]*dynamodb.TableDescription, error) {
-	objects := make([]any, 0)
-
-	err := api.YieldTables(AggregatorInitializer(&objects), nil)
-	ret := []*dynamodb.TableDescription{}
-	for _, objAny := range objects {
-		obj, ok := objAny.(*dynamodb.TableDescription)
-		if !ok {
-			return nil, fmt.Errorf("Casting error: %v ", objAny)
-		}
-		ret = append(ret, obj)
-	}
-	return ret, err
+	return Collect(api.IterTables(context.Background(), Input))
 }
 
 func (api *DynamoDBAPI) GetTags(table *dynamodb.TableDescription) (map[string]*string, error) {
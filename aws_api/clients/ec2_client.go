@@ -1,8 +1,10 @@
 package aws_api
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"iter"
 	"log"
 	"os"
 	"time"
@@ -40,66 +42,19 @@ func GetEC2Client(region *string) *ec2.EC2 {
 	return ec2.New(sess)
 }
 
-func DescribeNetworkInterfaces(svc *ec2.EC2, callback GenericCallback, describeNetworkInterfacesInput *ec2.DescribeNetworkInterfacesInput) error {
-	var callbackErr error
-	pageNum := 0
-	err := svc.DescribeNetworkInterfacesPages(describeNetworkInterfacesInput, func(page *ec2.DescribeNetworkInterfacesOutput, notHasNextPage bool) bool {
-		// stop when returns False
-		pageNum++
-		for _, nInt := range page.NetworkInterfaces {
-			if callbackErr = callback(nInt); callbackErr != nil {
-				return false
-			}
-		}
-		return !notHasNextPage
-	})
-	if callbackErr != nil {
-		return callbackErr
-	}
-
-	return err
+// Deprecated: use EC2API.IterNetworkInterfaces.
+func DescribeNetworkInterfaces(svc EC2Service, callback GenericCallback, describeNetworkInterfacesInput *ec2.DescribeNetworkInterfacesInput) error {
+	return EC2APINewWithService(svc).GetNetworkInterfaces(callback, describeNetworkInterfacesInput)
 }
 
-func DescribeNatGateways(svc *ec2.EC2, callback GenericCallback, describeInput *ec2.DescribeNatGatewaysInput) error {
-	var callbackErr error
-	pageNum := 0
-	err := svc.DescribeNatGatewaysPages(describeInput, func(page *ec2.DescribeNatGatewaysOutput, notHasNextPage bool) bool {
-		// stop when returns False
-		pageNum++
-		for _, nInt := range page.NatGateways {
-			if callbackErr = callback(nInt); callbackErr != nil {
-				return false
-			}
-		}
-		return !notHasNextPage
-	})
-	if callbackErr != nil {
-		return callbackErr
-	}
-
-	return err
+// Deprecated: use EC2API.IterNatGateways.
+func DescribeNatGateways(svc EC2Service, callback GenericCallback, describeInput *ec2.DescribeNatGatewaysInput) error {
+	return EC2APINewWithService(svc).DescribeNatGateways(callback, describeInput)
 }
 
-func DescribeInstances(svc *ec2.EC2, callback GenericCallback, describeInput *ec2.DescribeInstancesInput) error {
-	var callbackErr error
-	pageNum := 0
-	err := svc.DescribeInstancesPages(describeInput, func(page *ec2.DescribeInstancesOutput, notHasNextPage bool) bool {
-		// stop when returns False
-		pageNum++
-		for _, reservation := range page.Reservations {
-			for _, instance := range reservation.Instances {
-				if callbackErr = callback(instance); callbackErr != nil {
-					return false
-				}
-			}
-		}
-		return !notHasNextPage
-	})
-	if callbackErr != nil {
-		return callbackErr
-	}
-
-	return err
+// Deprecated: use EC2API.IterInstances.
+func DescribeInstances(svc EC2Service, callback GenericCallback, describeInput *ec2.DescribeInstancesInput) error {
+	return EC2APINewWithService(svc).DescribeInstances(callback, describeInput)
 }
 
 func cacheNetworkInterfacesGenerator(networkInterfaces *map[string]ec2.NetworkInterface) func(nInt any) error {
@@ -145,28 +100,13 @@ func RecordNetworkInterfaces(region *string, networkInterfaces *map[string]ec2.N
 	}
 }
 
-func DescribeFlowLogsPages(svc *ec2.EC2, Filter []*ec2.Filter, callback GenericCallback) error {
-	var callbackErr error
-	pageNum := 0
-	err := svc.DescribeFlowLogsPages(&ec2.DescribeFlowLogsInput{Filter: Filter}, func(page *ec2.DescribeFlowLogsOutput, notHasNextPage bool) bool {
-		// stop when returns False
-		pageNum++
-		for _, fLog := range page.FlowLogs {
-			if callbackErr = callback(fLog); callbackErr != nil {
-				return false
-			}
-		}
-		return !notHasNextPage
-	})
-	if callbackErr != nil {
-		return callbackErr
-	}
-
-	return err
+// Deprecated: use EC2API.IterFlowLogs.
+func DescribeFlowLogsPages(svc EC2Service, Filter []*ec2.Filter, callback GenericCallback) error {
+	return EC2APINewWithService(svc).DescribeFlowLogsPages(Filter, callback)
 }
 
 type EC2API struct {
-	svc *ec2.EC2
+	svc EC2Service
 }
 
 func EC2APINew(region *string, profileName *string) *EC2API {
@@ -185,32 +125,86 @@ func EC2APINew(region *string, profileName *string) *EC2API {
 	return &ret
 }
 
-func (api *EC2API) ProvisionFlowLog(logGroupName, resourceType, trafficType *string, resourceIds []*string, roleArn *string) (*ec2.CreateFlowLogsOutput, error) {
+func EC2APINewWithService(svc EC2Service) *EC2API {
+	return &EC2API{svc: svc}
+}
+
+func (api *EC2API) IterNatGateways(ctx context.Context, describeInput *ec2.DescribeNatGatewaysInput) iter.Seq2[*ec2.NatGateway, error] {
+	return Paginate(ctx, func(callback func(*ec2.DescribeNatGatewaysOutput, bool) bool) error {
+		return api.svc.DescribeNatGatewaysPages(describeInput, callback)
+	}, Items(func(page *ec2.DescribeNatGatewaysOutput) []*ec2.NatGateway { return page.NatGateways }))
+}
+
+// Deprecated: use IterNatGateways.
+func (api *EC2API) DescribeNatGateways(callback GenericCallback, describeInput *ec2.DescribeNatGatewaysInput) error {
+	return yieldToCallback(api.IterNatGateways(context.Background(), describeInput), callback)
+}
+
+// Flattens the reservations.
+func (api *EC2API) IterInstances(ctx context.Context, describeInput *ec2.DescribeInstancesInput) iter.Seq2[*ec2.Instance, error] {
+	return Paginate(ctx, func(callback func(*ec2.DescribeInstancesOutput, bool) bool) error {
+		return api.svc.DescribeInstancesPages(describeInput, callback)
+	}, Items(func(page *ec2.DescribeInstancesOutput) []*ec2.Instance {
+		ret := []*ec2.Instance{}
+		for _, reservation := range page.Reservations {
+			ret = append(ret, reservation.Instances...)
+		}
+		return ret
+	}))
+}
+
+// Deprecated: use IterInstances.
+func (api *EC2API) DescribeInstances(callback GenericCallback, describeInput *ec2.DescribeInstancesInput) error {
+	return yieldToCallback(api.IterInstances(context.Background(), describeInput), callback)
+}
+
+func (api *EC2API) IterFlowLogs(ctx context.Context, describeInput *ec2.DescribeFlowLogsInput) iter.Seq2[*ec2.FlowLog, error] {
+	return Paginate(ctx, func(callback func(*ec2.DescribeFlowLogsOutput, bool) bool) error {
+		return api.svc.DescribeFlowLogsPages(describeInput, callback)
+	}, Items(func(page *ec2.DescribeFlowLogsOutput) []*ec2.FlowLog { return page.FlowLogs }))
+}
+
+// Deprecated: use IterFlowLogs.
+func (api *EC2API) DescribeFlowLogsPages(Filter []*ec2.Filter, callback GenericCallback) error {
+	return yieldToCallback(api.IterFlowLogs(context.Background(), &ec2.DescribeFlowLogsInput{Filter: Filter}), callback)
+}
+
+// A nil logFormat creates the flow log with the AWS default v2 format.
+func (api *EC2API) ProvisionFlowLog(logGroupName, resourceType, trafficType *string, resourceIds []*string, roleArn *string, logFormat *string) (*ec2.CreateFlowLogsOutput, error) {
 	input := ec2.CreateFlowLogsInput{LogGroupName: logGroupName,
 		ResourceIds:              resourceIds,
 		ResourceType:             resourceType,
 		TrafficType:              trafficType,
-		DeliverLogsPermissionArn: roleArn}
+		DeliverLogsPermissionArn: roleArn,
+		LogFormat:                logFormat}
 	reponse, err := api.svc.CreateFlowLogs(&input)
 	return reponse, err
 }
 
-func (api *EC2API) DescribeVpcEndpointsPages(callback GenericCallback, describeNetworkInterfacesInput *ec2.DescribeVpcEndpointsInput) error {
-	var callbackErr error
-	pageNum := 0
-	err := api.svc.DescribeVpcEndpointsPages(describeNetworkInterfacesInput, func(page *ec2.DescribeVpcEndpointsOutput, notHasNextPage bool) bool {
-		pageNum++
-		for _, obj := range page.VpcEndpoints {
-			if callbackErr = callback(obj); callbackErr != nil {
-				return false
-			}
+// An already deleted flow log is not an error.
+func (api *EC2API) DisposeFlowLog(flowLogId *string) error {
+	lg.InfoF("Disposing flow log %s", *flowLogId)
+	response, err := api.svc.DeleteFlowLogs(&ec2.DeleteFlowLogsInput{FlowLogIds: []*string{flowLogId}})
+	if err != nil {
+		return err
+	}
+	for _, item := range response.Unsuccessful {
+		if item.Error != nil && aws.StringValue(item.Error.Code) != "InvalidFlowLogId.NotFound" {
+			return fmt.Errorf("failed to delete flow log %s: %s", *flowLogId, aws.StringValue(item.Error.Message))
 		}
-		return !notHasNextPage
-	})
-	if callbackErr != nil {
-		return callbackErr
 	}
-	return err
+	return nil
+}
+
+func (api *EC2API) IterVpcEndpoints(ctx context.Context, describeInput *ec2.DescribeVpcEndpointsInput) iter.Seq2[*ec2.VpcEndpoint, error] {
+	return Paginate(ctx, func(callback func(*ec2.DescribeVpcEndpointsOutput, bool) bool) error {
+		return api.svc.DescribeVpcEndpointsPages(describeInput, callback)
+	}, Items(func(page *ec2.DescribeVpcEndpointsOutput) []*ec2.VpcEndpoint { return page.VpcEndpoints }))
+}
+
+// Deprecated: use IterVpcEndpoints.
+func (api *EC2API) DescribeVpcEndpointsPages(callback GenericCallback, describeInput *ec2.DescribeVpcEndpointsInput) error {
+	return yieldToCallback(api.IterVpcEndpoints(context.Background(), describeInput), callback)
 }
 
 func (api *EC2API) CreateTags(existingTags []*ec2.Tag, AddTags map[string]string, resource *string, declarative bool) (*ec2.CreateTagsOutput, error) {
@@ -236,144 +230,140 @@ func (api *EC2API) CreateTags(existingTags []*ec2.Tag, AddTags map[string]string
 	return createTagsOutput, err
 }
 
-func (api *EC2API) DescribeAddresses(callback GenericCallback, describeInput *ec2.DescribeAddressesInput) error {
-	output, err := api.svc.DescribeAddresses(describeInput)
-	if err != nil {
-		return err
-	}
-
-	for _, obj := range output.Addresses {
-		if callbackErr := callback(obj); callbackErr != nil {
-			return callbackErr
+// Not paginated by the service.
+func (api *EC2API) IterAddresses(ctx context.Context, describeInput *ec2.DescribeAddressesInput) iter.Seq2[*ec2.Address, error] {
+	return Single(ctx, func() ([]*ec2.Address, error) {
+		output, err := api.svc.DescribeAddresses(describeInput)
+		if err != nil {
+			return nil, err
 		}
-	}
+		return output.Addresses, nil
+	})
+}
 
-	return nil
+// Deprecated: use IterAddresses.
+func (api *EC2API) DescribeAddresses(callback GenericCallback, describeInput *ec2.DescribeAddressesInput) error {
+	return yieldToCallback(api.IterAddresses(context.Background(), describeInput), callback)
 }
 
+func (api *EC2API) IterVolumes(ctx context.Context, Input *ec2.DescribeVolumesInput) iter.Seq2[*ec2.Volume, error] {
+	return Paginate(ctx, func(callback func(*ec2.DescribeVolumesOutput, bool) bool) error {
+		return api.svc.DescribeVolumesPages(Input, callback)
+	}, Items(func(page *ec2.DescribeVolumesOutput) []*ec2.Volume { return page.Volumes }))
+}
+
+// Deprecated: use IterVolumes.
 func (api *EC2API) DescribeVolumes(callback GenericCallback, Input *ec2.DescribeVolumesInput) error {
-	var callbackErr error
-	pageNum := 0
-	err := api.svc.DescribeVolumesPages(Input, func(page *ec2.DescribeVolumesOutput, notHasNextPage bool) bool {
-		pageNum++
-		for _, obj := range page.Volumes {
-			if callbackErr = callback(obj); callbackErr != nil {
-				return false
-			}
-		}
-		return !notHasNextPage
-	})
-	if callbackErr != nil {
-		return callbackErr
-	}
-	return err
+	return yieldToCallback(api.IterVolumes(context.Background(), Input), callback)
+}
+
+func (api *EC2API) IterLaunchTemplates(ctx context.Context, Input *ec2.DescribeLaunchTemplatesInput) iter.Seq2[*ec2.LaunchTemplate, error] {
+	return Paginate(ctx, func(callback func(*ec2.DescribeLaunchTemplatesOutput, bool) bool) error {
+		return api.svc.DescribeLaunchTemplatesPages(Input, callback)
+	}, Items(func(page *ec2.DescribeLaunchTemplatesOutput) []*ec2.LaunchTemplate { return page.LaunchTemplates }))
 }
 
+// Deprecated: use IterLaunchTemplates.
 func (api *EC2API) DescribeLaunchTemplates(callback GenericCallback, Input *ec2.DescribeLaunchTemplatesInput) error {
-	var callbackErr error
-	pageNum := 0
-	err := api.svc.DescribeLaunchTemplatesPages(Input, func(page *ec2.DescribeLaunchTemplatesOutput, notHasNextPage bool) bool {
-		pageNum++
-		for _, obj := range page.LaunchTemplates {
-			if callbackErr = callback(obj); callbackErr != nil {
-				return false
-			}
-		}
-		return !notHasNextPage
-	})
-	if callbackErr != nil {
-		return callbackErr
-	}
-	return err
+	return yieldToCallback(api.IterLaunchTemplates(context.Background(), Input), callback)
+}
+
+func (api *EC2API) IterImages(ctx context.Context, Input *ec2.DescribeImagesInput) iter.Seq2[*ec2.Image, error] {
+	return Paginate(ctx, func(callback func(*ec2.DescribeImagesOutput, bool) bool) error {
+		return api.svc.DescribeImagesPages(Input, callback)
+	}, Items(func(page *ec2.DescribeImagesOutput) []*ec2.Image { return page.Images }))
 }
 
+// Versions of a single launch template: the service requires LaunchTemplateId or LaunchTemplateName to list all of them.
+func (api *EC2API) IterLaunchTemplateVersions(ctx context.Context, Input *ec2.DescribeLaunchTemplateVersionsInput) iter.Seq2[*ec2.LaunchTemplateVersion, error] {
+	return Paginate(ctx, func(callback func(*ec2.DescribeLaunchTemplateVersionsOutput, bool) bool) error {
+		return api.svc.DescribeLaunchTemplateVersionsPages(Input, callback)
+	}, Items(func(page *ec2.DescribeLaunchTemplateVersionsOutput) []*ec2.LaunchTemplateVersion {
+		return page.LaunchTemplateVersions
+	}))
+}
+
+// Deprecated: use IterImages.
 func (api *EC2API) DescribeImages(callback GenericCallback, Input *ec2.DescribeImagesInput) error {
-	var callbackErr error
-	pageNum := 0
-	err := api.svc.DescribeImagesPages(Input, func(page *ec2.DescribeImagesOutput, notHasNextPage bool) bool {
-		pageNum++
-		for _, obj := range page.Images {
-			if callbackErr = callback(obj); callbackErr != nil {
-				return false
-			}
-		}
-		return !notHasNextPage
-	})
-	if callbackErr != nil {
-		return callbackErr
-	}
-	return err
+	return yieldToCallback(api.IterImages(context.Background(), Input), callback)
 }
 
+func (api *EC2API) IterSnapshots(ctx context.Context, Input *ec2.DescribeSnapshotsInput) iter.Seq2[*ec2.Snapshot, error] {
+	return Paginate(ctx, func(callback func(*ec2.DescribeSnapshotsOutput, bool) bool) error {
+		return api.svc.DescribeSnapshotsPages(Input, callback)
+	}, Items(func(page *ec2.DescribeSnapshotsOutput) []*ec2.Snapshot { return page.Snapshots }))
+}
+
+// Deprecated: use IterSnapshots.
 func (api *EC2API) DescribeSnapshots(callback GenericCallback, Input *ec2.DescribeSnapshotsInput) error {
-	var callbackErr error
-	pageNum := 0
-	err := api.svc.DescribeSnapshotsPages(Input, func(page *ec2.DescribeSnapshotsOutput, notHasNextPage bool) bool {
-		pageNum++
-		for _, obj := range page.Snapshots {
-			if callbackErr = callback(obj); callbackErr != nil {
-				return false
-			}
+	return yieldToCallback(api.IterSnapshots(context.Background(), Input), callback)
+}
+
+// Not paginated by the service.
+func (api *EC2API) IterKeyPairs(ctx context.Context, Input *ec2.DescribeKeyPairsInput) iter.Seq2[*ec2.KeyPairInfo, error] {
+	return Single(ctx, func() ([]*ec2.KeyPairInfo, error) {
+		response, err := api.svc.DescribeKeyPairs(Input)
+		if err != nil {
+			return nil, err
 		}
-		return !notHasNextPage
+		return response.KeyPairs, nil
 	})
-	if callbackErr != nil {
-		return callbackErr
-	}
-	return err
 }
 
+// Deprecated: use IterKeyPairs.
 func (api *EC2API) DescribeKeyPairs(callback GenericCallback, Input *ec2.DescribeKeyPairsInput) error {
-	var callbackErr error
+	return yieldToCallback(api.IterKeyPairs(context.Background(), Input), callback)
+}
 
-	response, err := api.svc.DescribeKeyPairs(Input)
-	if err != nil {
-		return err
-	}
+func (api *EC2API) IterSecurityGroups(ctx context.Context, Input *ec2.DescribeSecurityGroupsInput) iter.Seq2[*ec2.SecurityGroup, error] {
+	return Paginate(ctx, func(callback func(*ec2.DescribeSecurityGroupsOutput, bool) bool) error {
+		return api.svc.DescribeSecurityGroupsPages(Input, callback)
+	}, Items(func(page *ec2.DescribeSecurityGroupsOutput) []*ec2.SecurityGroup { return page.SecurityGroups }))
+}
 
-	for _, obj := range response.KeyPairs {
-		if callbackErr = callback(obj); callbackErr != nil {
-			return callbackErr
-		}
-	}
+// Deprecated: use IterSecurityGroups.
+func (api *EC2API) DescribeSecurityGroups(callback GenericCallback, Input *ec2.DescribeSecurityGroupsInput) error {
+	return yieldToCallback(api.IterSecurityGroups(context.Background(), Input), callback)
+}
 
-	return nil
+func (api *EC2API) IterNetworkInterfaces(ctx context.Context, describeNetworkInterfacesInput *ec2.DescribeNetworkInterfacesInput) iter.Seq2[*ec2.NetworkInterface, error] {
+	return Paginate(ctx, func(callback func(*ec2.DescribeNetworkInterfacesOutput, bool) bool) error {
+		return api.svc.DescribeNetworkInterfacesPages(describeNetworkInterfacesInput, callback)
+	}, Items(func(page *ec2.DescribeNetworkInterfacesOutput) []*ec2.NetworkInterface { return page.NetworkInterfaces }))
 }
 
-func (api *EC2API) DescribeSecurityGroups(callback GenericCallback, Input *ec2.DescribeSecurityGroupsInput) error {
-	var callbackErr error
-	pageNum := 0
-	err := api.svc.DescribeSecurityGroupsPages(Input, func(page *ec2.DescribeSecurityGroupsOutput, notHasNextPage bool) bool {
-		pageNum++
-		for _, obj := range page.SecurityGroups {
-			if callbackErr = callback(obj); callbackErr != nil {
-				return false
-			}
-		}
-		return !notHasNextPage
-	})
-	if callbackErr != nil {
-		return callbackErr
-	}
+// Deprecated: use IterNetworkInterfaces.
+func (api *EC2API) GetNetworkInterfaces(callback GenericCallback, describeNetworkInterfacesInput *ec2.DescribeNetworkInterfacesInput) error {
+	return yieldToCallback(api.IterNetworkInterfaces(context.Background(), describeNetworkInterfacesInput), callback)
+}
+
+func (api *EC2API) DisposeVolume(volumeId *string) error {
+	lg.InfoF("Disposing volume %s", *volumeId)
+	_, err := api.svc.DeleteVolume(&ec2.DeleteVolumeInput{VolumeId: volumeId})
 	return err
 }
 
-func (api *EC2API) GetNetworkInterfaces(callback GenericCallback, describeNetworkInterfacesInput *ec2.DescribeNetworkInterfacesInput) error {
-	var callbackErr error
-	pageNum := 0
-	err := api.svc.DescribeNetworkInterfacesPages(describeNetworkInterfacesInput, func(page *ec2.DescribeNetworkInterfacesOutput, notHasNextPage bool) bool {
-		// stop when returns False
-		pageNum++
-		for _, nInt := range page.NetworkInterfaces {
-			if callbackErr = callback(nInt); callbackErr != nil {
-				return false
-			}
-		}
-		return !notHasNextPage
-	})
-	if callbackErr != nil {
-		return callbackErr
-	}
+func (api *EC2API) DisposeSnapshot(snapshotId *string) error {
+	lg.InfoF("Disposing snapshot %s", *snapshotId)
+	_, err := api.svc.DeleteSnapshot(&ec2.DeleteSnapshotInput{SnapshotId: snapshotId})
+	return err
+}
+
+// The image snapshots are kept, they are deleted separately once nothing references them.
+func (api *EC2API) DisposeImage(imageId *string) error {
+	lg.InfoF("Deregistering image %s", *imageId)
+	_, err := api.svc.DeregisterImage(&ec2.DeregisterImageInput{ImageId: imageId})
+	return err
+}
+
+func (api *EC2API) DisposeAddress(allocationId *string) error {
+	lg.InfoF("Releasing elastic ip %s", *allocationId)
+	_, err := api.svc.ReleaseAddress(&ec2.ReleaseAddressInput{AllocationId: allocationId})
+	return err
+}
 
+func (api *EC2API) DisposeNetworkInterface(networkInterfaceId *string) error {
+	lg.InfoF("Disposing network interface %s", *networkInterfaceId)
+	_, err := api.svc.DeleteNetworkInterface(&ec2.DeleteNetworkInterfaceInput{NetworkInterfaceId: networkInterfaceId})
 	return err
 }
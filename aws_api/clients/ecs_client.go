@@ -1,7 +1,10 @@
 package aws_api
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"iter"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
@@ -9,7 +12,7 @@ import (
 )
 
 type ECSAPI struct {
-	svc         *ecs.ECS
+	svc         ECSService
 	region      *string
 	profileName *string
 }
@@ -32,51 +35,48 @@ func ECSAPINew(region *string, profileName *string) *ECSAPI {
 	return &ret
 }
 
-func (api *ECSAPI) GetTaskDefinitions(callback GenericCallback, Input *ecs.ListTaskDefinitionsInput) error {
-	var callbackErr error
-
-	err := api.svc.ListTaskDefinitionsPages(Input, func(page *ecs.ListTaskDefinitionsOutput, notHasNextPage bool) bool {
+func ECSAPINewWithService(svc ECSService) *ECSAPI {
+	return &ECSAPI{svc: svc}
+}
 
+// Describes every listed task definition, combine with Take to stop after the latest revisions.
+func (api *ECSAPI) IterTaskDefinitions(ctx context.Context, Input *ecs.ListTaskDefinitionsInput) iter.Seq2[*ecs.TaskDefinition, error] {
+	return Paginate(ctx, func(callback func(*ecs.ListTaskDefinitionsOutput, bool) bool) error {
+		return api.svc.ListTaskDefinitionsPages(Input, callback)
+	}, func(page *ecs.ListTaskDefinitionsOutput) ([]*ecs.TaskDefinition, error) {
+		ret := []*ecs.TaskDefinition{}
 		for _, arn := range page.TaskDefinitionArns {
 			response, err := api.svc.DescribeTaskDefinition(&ecs.DescribeTaskDefinitionInput{TaskDefinition: arn})
 			if err != nil {
-				return false
-			}
-
-			//tags, err := api.svc.ListTagsForResource(&ecs.ListTagsForResourceInput{ResourceArn: arn})
-			//if err != nil {
-			//	return false
-			//}
-			//fmt.Printf("todo: handle tags in GetTaskDefinitions %s", tags)
-			if callbackErr = callback(response.TaskDefinition); callbackErr != nil {
-				return false
+				return nil, err
 			}
+			ret = append(ret, response.TaskDefinition)
 		}
-		return !notHasNextPage
+		return ret, nil
 	})
-	if callbackErr != nil {
-		return callbackErr
-	}
-	return err
 }
 
+// Yields the listed arns, without describing the task definitions.
+func (api *ECSAPI) IterTaskDefinitionArns(ctx context.Context, Input *ecs.ListTaskDefinitionsInput) iter.Seq2[*string, error] {
+	return Paginate(ctx, func(callback func(*ecs.ListTaskDefinitionsOutput, bool) bool) error {
+		return api.svc.ListTaskDefinitionsPages(Input, callback)
+	}, Items(func(page *ecs.ListTaskDefinitionsOutput) []*string { return page.TaskDefinitionArns }))
+}
+
+// Deprecated: use IterTaskDefinitions.
+func (api *ECSAPI) GetTaskDefinitions(callback GenericCallback, Input *ecs.ListTaskDefinitionsInput) error {
+	return yieldToCallback(api.IterTaskDefinitions(context.Background(), Input), callback)
+}
+
+func (api *ECSAPI) IterTaskDefinitionFamilies(ctx context.Context, Input *ecs.ListTaskDefinitionFamiliesInput) iter.Seq2[*string, error] {
+	return Paginate(ctx, func(callback func(*ecs.ListTaskDefinitionFamiliesOutput, bool) bool) error {
+		return api.svc.ListTaskDefinitionFamiliesPages(Input, callback)
+	}, Items(func(page *ecs.ListTaskDefinitionFamiliesOutput) []*string { return page.Families }))
+}
+
+// Deprecated: use IterTaskDefinitionFamilies.
 func (api *ECSAPI) GetTaskDefinitionFamilies(callback GenericCallback, Input *ecs.ListTaskDefinitionFamiliesInput) error {
-	var callbackErr error
-	pageNum := 0
-	err := api.svc.ListTaskDefinitionFamiliesPages(Input, func(page *ecs.ListTaskDefinitionFamiliesOutput, notHasNextPage bool) bool {
-
-		pageNum++
-		for _, arn := range page.Families {
-			if callbackErr = callback(arn); callbackErr != nil {
-				return false
-			}
-		}
-		return !notHasNextPage
-	})
-	if callbackErr != nil {
-		return callbackErr
-	}
-	return err
+	return yieldToCallback(api.IterTaskDefinitionFamilies(context.Background(), Input), callback)
 }
 
 func (api *ECSAPI) GetTags(resource *string) ([]*ecs.Tag, error) {
@@ -92,47 +92,24 @@ func (api *ECSAPI) GetTags(resource *string) ([]*ecs.Tag, error) {
 	return ret, nil
 }
 
-func (api *ECSAPI) GetTasks(Input *ecs.ListTasksInput) ([]*ecs.Task, error) {
-	var callbackErr error
-	objects := make([]any, 0)
-	callback := AggregatorInitializer(&objects)
-
-	err := api.svc.ListTasksPages(Input, func(page *ecs.ListTasksOutput, notHasNextPage bool) bool {
-		if len(page.TaskArns) == 100 {
-			callbackErr = fmt.Errorf("not implemented len(page.TaskArns)= %d", len(page.TaskArns))
-			return false
+// Describes the listed tasks page by page, a page holds up to 100 tasks - the DescribeTasks limit.
+func (api *ECSAPI) IterTasks(ctx context.Context, Input *ecs.ListTasksInput) iter.Seq2[*ecs.Task, error] {
+	return Paginate(ctx, func(callback func(*ecs.ListTasksOutput, bool) bool) error {
+		return api.svc.ListTasksPages(Input, callback)
+	}, func(page *ecs.ListTasksOutput) ([]*ecs.Task, error) {
+		if len(page.TaskArns) == 0 {
+			return []*ecs.Task{}, nil
 		}
-
 		response, err := api.svc.DescribeTasks(&ecs.DescribeTasksInput{Tasks: page.TaskArns, Cluster: Input.Cluster})
 		if err != nil {
-			return false
-		}
-
-		for _, task := range response.Tasks {
-			if callbackErr = callback(task); callbackErr != nil {
-				return false
-			}
+			return nil, err
 		}
-
-		return !notHasNextPage
+		return response.Tasks, nil
 	})
+}
 
-	if callbackErr != nil {
-		return nil, callbackErr
-	}
-
-	if err != nil {
-		return nil, err
-	}
-	retTasks := []*ecs.Task{}
-	for _, anyTask := range objects {
-		task, ok := anyTask.(*ecs.Task)
-		if !ok {
-			return nil, fmt.Errorf("ecs.Task cast error: %v", anyTask)
-		}
-		retTasks = append(retTasks, task)
-	}
-	return retTasks, nil
+func (api *ECSAPI) GetTasks(Input *ecs.ListTasksInput) ([]*ecs.Task, error) {
+	return Collect(api.IterTasks(context.Background(), Input))
 }
 
 func (api *ECSAPI) ProvisionTags(task *ecs.Task, DesiredTags map[string]*string) error {
@@ -167,70 +144,29 @@ func (api *ECSAPI) ProvisionTags(task *ecs.Task, DesiredTags map[string]*string)
 	return err
 }
 
-func (api *ECSAPI) IterClusters(Input *ecs.ListClustersInput) ([]*ecs.Cluster, error) {
-	var callbackErr error
-	objects := make([]any, 0)
-	callback := AggregatorInitializer(&objects)
-
-	err := api.svc.ListClustersPages(Input, func(page *ecs.ListClustersOutput, notHasNextPage bool) bool {
-		if len(page.ClusterArns) == 100 {
-			callbackErr = fmt.Errorf("not implemented len(page.ClusterArns)= %d", len(page.ClusterArns))
-			return false
-		}
+func (api *ECSAPI) IterClusterArns(ctx context.Context, Input *ecs.ListClustersInput) iter.Seq2[*string, error] {
+	return Paginate(ctx, func(callback func(*ecs.ListClustersOutput, bool) bool) error {
+		return api.svc.ListClustersPages(Input, callback)
+	}, Items(func(page *ecs.ListClustersOutput) []*string { return page.ClusterArns }))
+}
 
-		for _, arn := range page.ClusterArns {
-			cluster := &ecs.Cluster{ClusterArn: arn}
-			if callbackErr = callback(cluster); callbackErr != nil {
-				return false
+// Yields the listed clusters with only the ClusterArn set, GetClusters describes them.
+func (api *ECSAPI) IterClusters(ctx context.Context, Input *ecs.ListClustersInput) iter.Seq2[*ecs.Cluster, error] {
+	return func(yield func(*ecs.Cluster, error) bool) {
+		for arn, err := range api.IterClusterArns(ctx, Input) {
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			if !yield(&ecs.Cluster{ClusterArn: arn}, nil) {
+				return
 			}
 		}
-
-		return !notHasNextPage
-	})
-
-	if callbackErr != nil {
-		return nil, callbackErr
-	}
-
-	if err != nil {
-		return nil, err
-	}
-
-	retClusters := []*ecs.Cluster{}
-	for _, anyCluster := range objects {
-		task, ok := anyCluster.(*ecs.Cluster)
-		if !ok {
-			return nil, fmt.Errorf("ecs.Cluster cast error: %v", anyCluster)
-		}
-		retClusters = append(retClusters, task)
 	}
-	return retClusters, nil
 }
 
 func (api *ECSAPI) ListClusters(Input *ecs.ListClustersInput) ([]*string, error) {
-	var callbackErr error
-	ret := []*string{}
-
-	err := api.svc.ListClustersPages(Input, func(page *ecs.ListClustersOutput, notHasNextPage bool) bool {
-		if len(page.ClusterArns) == 100 {
-			callbackErr = fmt.Errorf("not implemented len(page.ClusterArns)= %d", len(page.ClusterArns))
-			return false
-		}
-
-		ret = append(ret, page.ClusterArns...)
-
-		return !notHasNextPage
-	})
-
-	if callbackErr != nil {
-		return nil, callbackErr
-	}
-
-	if err != nil {
-		return nil, err
-	}
-
-	return ret, nil
+	return Collect(api.IterClusterArns(context.Background(), Input))
 }
 
 func (api *ECSAPI) GetClusters(Input *ecs.ListClustersInput) (ret []*ecs.Cluster, err error) {
@@ -258,6 +194,54 @@ func (api *ECSAPI) GetClusters(Input *ecs.ListClustersInput) (ret []*ecs.Cluster
 	return ret, nil
 }
 
+// Describes the listed services, in bulks of 10 - the DescribeServices limit.
+func (api *ECSAPI) IterServices(ctx context.Context, Input *ecs.ListServicesInput) iter.Seq2[*ecs.Service, error] {
+	return Paginate(ctx, func(callback func(*ecs.ListServicesOutput, bool) bool) error {
+		return api.svc.ListServicesPages(Input, callback)
+	}, func(page *ecs.ListServicesOutput) ([]*ecs.Service, error) {
+		ret := []*ecs.Service{}
+		bulks, err := api.SplitToBulks(page.ServiceArns, 10)
+		if err != nil {
+			return nil, err
+		}
+		for _, bulk := range bulks {
+			response, err := api.svc.DescribeServices(&ecs.DescribeServicesInput{Services: bulk, Cluster: Input.Cluster})
+			if err != nil {
+				return nil, err
+			}
+			ret = append(ret, response.Services...)
+		}
+		return ret, nil
+	})
+}
+
+// Deregistered revisions become INACTIVE: the running tasks and services keep them, new ones can not use them.
+func (api *ECSAPI) DisposeTaskDefinition(taskDefinitionArn *string) error {
+	lg.InfoF("Deregistering task definition %s", *taskDefinitionArn)
+	_, err := api.svc.DeregisterTaskDefinition(&ecs.DeregisterTaskDefinitionInput{TaskDefinition: taskDefinitionArn})
+	return err
+}
+
+// Deletes INACTIVE revisions, in bulks of 10 - the DeleteTaskDefinitions limit.
+func (api *ECSAPI) DeleteTaskDefinitions(taskDefinitionArns []*string) error {
+	bulks, err := api.SplitToBulks(taskDefinitionArns, 10)
+	if err != nil {
+		return err
+	}
+	errs := []error{}
+	for _, bulk := range bulks {
+		lg.InfoF("Deleting task definitions %v", aws.StringValueSlice(bulk))
+		response, err := api.svc.DeleteTaskDefinitions(&ecs.DeleteTaskDefinitionsInput{TaskDefinitions: bulk})
+		if err != nil {
+			return err
+		}
+		for _, failure := range response.Failures {
+			errs = append(errs, fmt.Errorf("failed to delete task definition %s: %s %s", aws.StringValue(failure.Arn), aws.StringValue(failure.Reason), aws.StringValue(failure.Detail)))
+		}
+	}
+	return errors.Join(errs...)
+}
+
 func (api *ECSAPI) SplitToBulks(src []*string, size int) (ret [][]*string, err error) {
 	ret = [][]*string{}
 	var start int
@@ -1,7 +1,8 @@
 package aws_api
 
 import (
-	"fmt"
+	"context"
+	"iter"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
@@ -9,7 +10,7 @@ import (
 )
 
 type ElasticacheAPI struct {
-	svc *elasticache.ElastiCache
+	svc ElasticacheService
 }
 
 func ElasticacheAPINew(region *string, profileName *string) *ElasticacheAPI {
@@ -25,35 +26,25 @@ func ElasticacheAPINew(region *string, profileName *string) *ElasticacheAPI {
 	return &ret
 }
 
+func ElasticacheAPINewWithService(svc ElasticacheService) *ElasticacheAPI {
+	return &ElasticacheAPI{svc: svc}
+}
+
+func (api *ElasticacheAPI) IterCacheClusters(ctx context.Context, Input *elasticache.DescribeCacheClustersInput) iter.Seq2[*elasticache.CacheCluster, error] {
+	return Paginate(ctx, func(callback func(*elasticache.DescribeCacheClustersOutput, bool) bool) error {
+		return api.svc.DescribeCacheClustersPages(Input, callback)
+	}, Items(func(page *elasticache.DescribeCacheClustersOutput) []*elasticache.CacheCluster {
+		return page.CacheClusters
+	}))
+}
+
+// Deprecated: use IterCacheClusters.
 func (api *ElasticacheAPI) YieldCacheClusters(callback GenericCallback, Input *elasticache.DescribeCacheClustersInput) error {
-	var callbackErr error
-	err := api.svc.DescribeCacheClustersPages(Input, func(page *elasticache.DescribeCacheClustersOutput, notHasNextPage bool) bool {
-		for _, cluster := range page.CacheClusters {
-			if callbackErr = callback(cluster); callbackErr != nil {
-				return false
-			}
-		}
-		return !notHasNextPage
-	})
-	if callbackErr != nil {
-		return callbackErr
-	}
-	return err
+	return yieldToCallback(api.IterCacheClusters(context.Background(), Input), callback)
 }
 
 func (api *ElasticacheAPI) GetCacheClusters(Input *elasticache.DescribeCacheClustersInput) ([]*elasticache.CacheCluster, error) {
-	objects := make([]any, 0)
-
-	err := api.YieldCacheClusters(AggregatorInitializer(&objects), nil)
-	ret := []*elasticache.CacheCluster{}
-	for _, objAny := range objects {
-		obj, ok := objAny.(*elasticache.CacheCluster)
-		if !ok {
-			return nil, fmt.Errorf("casting error: %v ", objAny)
-		}
-		ret = append(ret, obj)
-	}
-	return ret, err
+	return Collect(api.IterCacheClusters(context.Background(), Input))
 }
 
 func (api *ElasticacheAPI) GetTags(cluster *elasticache.CacheCluster) (map[string]*string, error) {
@@ -1,13 +1,15 @@
 package aws_api
 
 import (
+	"context"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/elbv2"
+	"iter"
 )
 
 type ELBV2API struct {
-	svc *elbv2.ELBV2
+	svc ELBV2Service
 }
 
 func ELBV2APINew(region *string, profileName *string) *ELBV2API {
@@ -26,40 +28,30 @@ func ELBV2APINew(region *string, profileName *string) *ELBV2API {
 	return &ret
 }
 
+func ELBV2APINewWithService(svc ELBV2Service) *ELBV2API {
+	return &ELBV2API{svc: svc}
+}
+
+func (api *ELBV2API) IterLoadBalancers(ctx context.Context, Input *elbv2.DescribeLoadBalancersInput) iter.Seq2[*elbv2.LoadBalancer, error] {
+	return Paginate(ctx, func(callback func(*elbv2.DescribeLoadBalancersOutput, bool) bool) error {
+		return api.svc.DescribeLoadBalancersPages(Input, callback)
+	}, Items(func(page *elbv2.DescribeLoadBalancersOutput) []*elbv2.LoadBalancer { return page.LoadBalancers }))
+}
+
+// Deprecated: use IterLoadBalancers.
 func (api *ELBV2API) DescribeLoadBalancers(callback GenericCallback, Input *elbv2.DescribeLoadBalancersInput) error {
-	var callbackErr error
-	pageNum := 0
-	err := api.svc.DescribeLoadBalancersPages(Input, func(page *elbv2.DescribeLoadBalancersOutput, notHasNextPage bool) bool {
-		pageNum++
-		for _, obj := range page.LoadBalancers {
-			if callbackErr = callback(obj); callbackErr != nil {
-				return false
-			}
-		}
-		return !notHasNextPage
-	})
-	if callbackErr != nil {
-		return callbackErr
-	}
-	return err
+	return yieldToCallback(api.IterLoadBalancers(context.Background(), Input), callback)
+}
+
+func (api *ELBV2API) IterTargetGroups(ctx context.Context, Input *elbv2.DescribeTargetGroupsInput) iter.Seq2[*elbv2.TargetGroup, error] {
+	return Paginate(ctx, func(callback func(*elbv2.DescribeTargetGroupsOutput, bool) bool) error {
+		return api.svc.DescribeTargetGroupsPages(Input, callback)
+	}, Items(func(page *elbv2.DescribeTargetGroupsOutput) []*elbv2.TargetGroup { return page.TargetGroups }))
 }
 
+// Deprecated: use IterTargetGroups.
 func (api *ELBV2API) DescribeTargetGroups(callback GenericCallback, Input *elbv2.DescribeTargetGroupsInput) error {
-	var callbackErr error
-	pageNum := 0
-	err := api.svc.DescribeTargetGroupsPages(Input, func(page *elbv2.DescribeTargetGroupsOutput, notHasNextPage bool) bool {
-		pageNum++
-		for _, obj := range page.TargetGroups {
-			if callbackErr = callback(obj); callbackErr != nil {
-				return false
-			}
-		}
-		return !notHasNextPage
-	})
-	if callbackErr != nil {
-		return callbackErr
-	}
-	return err
+	return yieldToCallback(api.IterTargetGroups(context.Background(), Input), callback)
 }
 
 func (api *ELBV2API) AddTags(AddTags map[string]string, resource *string, declarative bool) (*elbv2.AddTagsOutput, error) {
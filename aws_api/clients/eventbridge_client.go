@@ -0,0 +1,67 @@
+package aws_api
+
+import (
+	"context"
+	"iter"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/eventbridge"
+)
+
+type EventBridgeAPI struct {
+	svc EventBridgeService
+}
+
+func EventBridgeAPINew(region, profileName *string) *EventBridgeAPI {
+	if profileName == nil {
+		profileNameString := "default"
+		profileName = &profileNameString
+	}
+
+	sess := session.Must(session.NewSessionWithOptions(session.Options{
+		SharedConfigState: session.SharedConfigEnable,
+		Config:            aws.Config{Region: region},
+		Profile:           *profileName,
+	}))
+
+	lg.InfoF("AWS profile: %s\n", *profileName)
+	return &EventBridgeAPI{svc: eventbridge.New(sess)}
+}
+
+func EventBridgeAPINewWithService(svc EventBridgeService) *EventBridgeAPI {
+	return &EventBridgeAPI{svc: svc}
+}
+
+// The SDK has no *Pages calls for the rules, the pages are fetched by NextToken.
+func (api *EventBridgeAPI) IterRules(ctx context.Context, Input *eventbridge.ListRulesInput) iter.Seq2[*eventbridge.Rule, error] {
+	return Paginate(ctx, func(callback func(*eventbridge.ListRulesOutput, bool) bool) error {
+		input := *Input
+		for {
+			page, err := api.svc.ListRules(&input)
+			if err != nil {
+				return err
+			}
+			if !callback(page, page.NextToken == nil) || page.NextToken == nil {
+				return nil
+			}
+			input.NextToken = page.NextToken
+		}
+	}, Items(func(page *eventbridge.ListRulesOutput) []*eventbridge.Rule { return page.Rules }))
+}
+
+func (api *EventBridgeAPI) IterTargetsByRule(ctx context.Context, Input *eventbridge.ListTargetsByRuleInput) iter.Seq2[*eventbridge.Target, error] {
+	return Paginate(ctx, func(callback func(*eventbridge.ListTargetsByRuleOutput, bool) bool) error {
+		input := *Input
+		for {
+			page, err := api.svc.ListTargetsByRule(&input)
+			if err != nil {
+				return err
+			}
+			if !callback(page, page.NextToken == nil) || page.NextToken == nil {
+				return nil
+			}
+			input.NextToken = page.NextToken
+		}
+	}, Items(func(page *eventbridge.ListTargetsByRuleOutput) []*eventbridge.Target { return page.Targets }))
+}
@@ -0,0 +1,234 @@
+package aws_api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const DefaultExecutorConcurrency = 8
+
+// Account is reached either by the profile credentials or by assuming RoleArn from the profile.
+type Account struct {
+	Profile string `json:"Profile"`
+	RoleArn string `json:"RoleArn"`
+}
+
+// ExecutorConfig is the JSON side of the executor: every account is run in every region.
+type ExecutorConfig struct {
+	Accounts    []Account `json:"Accounts"`
+	Regions     []string  `json:"Regions"`
+	Concurrency int       `json:"Concurrency"`
+}
+
+func (config *ExecutorConfig) Targets() []Target {
+	ret := []Target{}
+	for _, account := range config.Accounts {
+		for _, region := range config.Regions {
+			ret = append(ret, Target{Profile: account.Profile, RoleArn: account.RoleArn, Region: region})
+		}
+	}
+	return ret
+}
+
+type Target struct {
+	Profile string `json:"Profile"`
+	RoleArn string `json:"RoleArn"`
+	Region  string `json:"Region"`
+}
+
+func (target Target) String() string {
+	account := target.Profile
+	if account == "" {
+		account = "default"
+	}
+	if target.RoleArn != "" {
+		account += "->" + target.RoleArn
+	}
+	return account + "/" + target.Region
+}
+
+// Targets of the same account share the factory and its session.
+func (target Target) accountKey() string {
+	return target.Profile + "|" + target.RoleArn
+}
+
+// FactoryNewForTarget builds the SDK backed factory of the target account.
+func FactoryNewForTarget(target Target) (*Factory, error) {
+	var profileName *string
+	if target.Profile != "" {
+		profileName = &target.Profile
+	}
+
+	if target.RoleArn == "" {
+		return FactoryNew(profileName), nil
+	}
+	if !strings.HasPrefix(target.RoleArn, "arn:") {
+		return nil, fmt.Errorf("[executor:FactoryNewForTarget] not a role arn: %s", target.RoleArn)
+	}
+	return FactoryNewAssumeRole(profileName, target.RoleArn), nil
+}
+
+// Executor runs an operation on every target with at most Concurrency targets at a time.
+type Executor struct {
+	Targets     []Target
+	Concurrency int
+	// Builds the clients of a target, replaced in tests.
+	FactoryNew func(target Target) (*Factory, error)
+
+	factories     map[string]*Factory
+	factoriesLock sync.Mutex
+}
+
+func ExecutorNew(config *ExecutorConfig) (*Executor, error) {
+	targets := config.Targets()
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("[executor:ExecutorNew] no targets: set both Accounts and Regions")
+	}
+	return ExecutorNewWithTargets(targets, config.Concurrency), nil
+}
+
+func ExecutorNewWithTargets(targets []Target, concurrency int) *Executor {
+	if concurrency <= 0 {
+		concurrency = DefaultExecutorConcurrency
+	}
+	return &Executor{Targets: targets,
+		Concurrency: concurrency,
+		FactoryNew:  FactoryNewForTarget,
+		factories:   map[string]*Factory{}}
+}
+
+func (executor *Executor) factory(target Target) (*Factory, error) {
+	executor.factoriesLock.Lock()
+	defer executor.factoriesLock.Unlock()
+
+	if executor.factories == nil {
+		executor.factories = map[string]*Factory{}
+	}
+	if ret, ok := executor.factories[target.accountKey()]; ok {
+		return ret, nil
+	}
+
+	factoryNew := executor.FactoryNew
+	if factoryNew == nil {
+		factoryNew = FactoryNewForTarget
+	}
+	ret, err := factoryNew(target)
+	if err != nil {
+		return nil, err
+	}
+	executor.factories[target.accountKey()] = ret
+	return ret, nil
+}
+
+type TargetOperation[R any] func(ctx context.Context, target Target, factory *Factory) (R, error)
+
+type TargetResult[R any] struct {
+	Target   Target
+	Value    R
+	Error    error
+	Duration time.Duration
+}
+
+// Report keeps the results in the executor targets order.
+type Report[R any] struct {
+	Results []*TargetResult[R]
+}
+
+func (report *Report[R]) Failed() []*TargetResult[R] {
+	ret := []*TargetResult[R]{}
+	for _, result := range report.Results {
+		if result.Error != nil {
+			ret = append(ret, result)
+		}
+	}
+	return ret
+}
+
+func (report *Report[R]) Succeeded() []*TargetResult[R] {
+	ret := []*TargetResult[R]{}
+	for _, result := range report.Results {
+		if result.Error == nil {
+			ret = append(ret, result)
+		}
+	}
+	return ret
+}
+
+// Err joins the failed targets errors, nil when all succeeded.
+func (report *Report[R]) Err() error {
+	errs := []error{}
+	for _, result := range report.Failed() {
+		errs = append(errs, fmt.Errorf("%s: %w", result.Target, result.Error))
+	}
+	return errors.Join(errs...)
+}
+
+func (report *Report[R]) String() string {
+	lines := []string{fmt.Sprintf("%d targets, %d failed", len(report.Results), len(report.Failed()))}
+	for _, result := range report.Results {
+		status := "OK"
+		if result.Error != nil {
+			status = "FAILED: " + strings.ReplaceAll(result.Error.Error(), "\n", " ")
+		}
+		lines = append(lines, fmt.Sprintf("%s %s (%s)", result.Target, status, result.Duration.Round(time.Millisecond)))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Execute runs the operation on all the executor targets and waits for them.
+// A failing or panicking target does not stop the others, a done context skips the targets not started yet.
+func Execute[R any](ctx context.Context, executor *Executor, operation TargetOperation[R]) *Report[R] {
+	report := &Report[R]{Results: make([]*TargetResult[R], len(executor.Targets))}
+	semaphore := make(chan bool, max(executor.Concurrency, 1))
+	wg := sync.WaitGroup{}
+
+	for index, target := range executor.Targets {
+		report.Results[index] = &TargetResult[R]{Target: target}
+		if ctx.Err() != nil {
+			report.Results[index].Error = ctx.Err()
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			report.Results[index].Error = ctx.Err()
+			continue
+		case semaphore <- true:
+		}
+
+		wg.Add(1)
+		go func(result *TargetResult[R]) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			start := time.Now()
+			result.Value, result.Error = runTarget(ctx, executor, result.Target, operation)
+			result.Duration = time.Since(start)
+			if result.Error != nil {
+				lg.WarningF("Target %s failed: %v", result.Target, result.Error)
+			}
+		}(report.Results[index])
+	}
+
+	wg.Wait()
+	return report
+}
+
+// Panics are recovered, session.Must panics on broken profiles.
+func runTarget[R any](ctx context.Context, executor *Executor, target Target, operation TargetOperation[R]) (ret R, err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("[executor:Execute] panic: %v", recovered)
+		}
+	}()
+
+	factory, err := executor.factory(target)
+	if err != nil {
+		return ret, err
+	}
+	return operation(ctx, target, factory)
+}
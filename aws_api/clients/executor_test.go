@@ -0,0 +1,95 @@
+package aws_api
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestExecute(t *testing.T) {
+	config := ExecutorConfig{Accounts: []Account{{Profile: "prod"}, {Profile: "ops", RoleArn: "arn:aws:iam::123456789012:role/audit"}},
+		Regions:     []string{"us-east-1", "eu-west-1", "ap-south-1"},
+		Concurrency: 2}
+
+	t.Run("Valid run", func(t *testing.T) {
+		executor, err := ExecutorNew(&config)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		factoriesBuilt := []string{}
+		lock := sync.Mutex{}
+		executor.FactoryNew = func(target Target) (*Factory, error) {
+			lock.Lock()
+			defer lock.Unlock()
+			factoriesBuilt = append(factoriesBuilt, target.accountKey())
+			return &Factory{}, nil
+		}
+
+		running, maxRunning := int32(0), int32(0)
+		report := Execute(context.Background(), executor, func(ctx context.Context, target Target, factory *Factory) (string, error) {
+			current := atomic.AddInt32(&running, 1)
+			defer atomic.AddInt32(&running, -1)
+			for {
+				seen := atomic.LoadInt32(&maxRunning)
+				if current <= seen || atomic.CompareAndSwapInt32(&maxRunning, seen, current) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+
+			switch target.Region {
+			case "eu-west-1":
+				return "", errors.New("access denied")
+			case "ap-south-1":
+				if target.RoleArn != "" {
+					panic("broken profile")
+				}
+			}
+			return target.String(), nil
+		})
+
+		if len(report.Results) != 6 || report.Results[0].Value != "prod/us-east-1" {
+			t.Fatalf("unexpected results: %s", report)
+		}
+		if len(report.Failed()) != 3 || len(report.Succeeded()) != 3 {
+			t.Errorf("unexpected report: %s", report)
+		}
+		if err := report.Err(); err == nil || !strings.Contains(err.Error(), "panic: broken profile") {
+			t.Errorf("Err() = %v", err)
+		}
+		if maxRunning > 2 {
+			t.Errorf("%d targets ran at once", maxRunning)
+		}
+		if len(factoriesBuilt) != 2 {
+			t.Errorf("factories built: %v", factoriesBuilt)
+		}
+	})
+
+	t.Run("Context cancellation", func(t *testing.T) {
+		executor, _ := ExecutorNew(&config)
+		executor.FactoryNew = func(target Target) (*Factory, error) { return &Factory{}, nil }
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		report := Execute(ctx, executor, func(ctx context.Context, target Target, factory *Factory) (bool, error) {
+			cancel()
+			return true, nil
+		})
+		for _, result := range report.Results[2:] {
+			if !errors.Is(result.Error, context.Canceled) {
+				t.Errorf("%s was started after the cancellation", result.Target)
+			}
+		}
+	})
+
+	t.Run("No targets", func(t *testing.T) {
+		_, err := ExecutorNew(&ExecutorConfig{Regions: []string{"us-east-1"}})
+		if err == nil {
+			t.Errorf("expected an error")
+		}
+	})
+}
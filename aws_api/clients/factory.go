@@ -0,0 +1,136 @@
+package aws_api
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/elasticache"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/eventbridge"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/scheduler"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+// Factory builds the API wrappers used by the tagging, cleaning and flow log logic.
+// FactoryNew returns SDK backed constructors, tests replace them with ones wrapping the fakes.
+type Factory struct {
+	Autoscaling    func(region *string) *AutoscalingAPI
+	Cloudwatch     func(region *string) *CloudwatchAPI
+	CloudwatchLogs func(region *string) *CloudwatchLogsAPI
+	DynamoDB       func(region *string) *DynamoDBAPI
+	EC2            func(region *string) *EC2API
+	ECS            func(region *string) *ECSAPI
+	Elasticache    func(region *string) *ElasticacheAPI
+	ELBV2          func(region *string) *ELBV2API
+	EventBridge    func(region *string) *EventBridgeAPI
+	IAM            func(dataDirPath *string) *IAMAPI
+	Lambda         func(region *string) *LambdaAPI
+	RDS            func(region *string) *RDSAPI
+	Route53        func() *Route53API
+	S3             func(region *string) *S3API
+	Scheduler      func(region *string) *SchedulerAPI
+	Secretsmanager func(region *string) *SecretsmanagerAPI
+	STS            func() *STSAPI
+}
+
+func FactoryNew(profileName *string) *Factory {
+	return &Factory{
+		Autoscaling:    func(region *string) *AutoscalingAPI { return GetAutoscalingAPI(region, profileName) },
+		Cloudwatch:     func(region *string) *CloudwatchAPI { return CloudwatchAPINew(region, profileName) },
+		CloudwatchLogs: func(region *string) *CloudwatchLogsAPI { return CloudwatchLogsAPINew(region, profileName) },
+		DynamoDB:       func(region *string) *DynamoDBAPI { return DynamoDBAPINew(region, profileName) },
+		EC2:            func(region *string) *EC2API { return EC2APINew(region, profileName) },
+		ECS:            func(region *string) *ECSAPI { return ECSAPINew(region, profileName) },
+		Elasticache:    func(region *string) *ElasticacheAPI { return ElasticacheAPINew(region, profileName) },
+		ELBV2:          func(region *string) *ELBV2API { return ELBV2APINew(region, profileName) },
+		EventBridge:    func(region *string) *EventBridgeAPI { return EventBridgeAPINew(region, profileName) },
+		IAM:            func(dataDirPath *string) *IAMAPI { return IAMAPINew(profileName, dataDirPath) },
+		Lambda:         func(region *string) *LambdaAPI { return LambdaAPINew(region, profileName) },
+		RDS:            func(region *string) *RDSAPI { return RDSAPINew(region, profileName) },
+		Route53:        func() *Route53API { return Route53APINew(profileName) },
+		S3:             func(region *string) *S3API { return S3APINew(region, profileName) },
+		Scheduler:      func(region *string) *SchedulerAPI { return SchedulerAPINew(region, profileName) },
+		Secretsmanager: func(region *string) *SecretsmanagerAPI { return SecretsmanagerAPINew(region, profileName) },
+		STS:            func() *STSAPI { return STSAPINew(profileName) },
+	}
+}
+
+// FactoryNewWithSession builds every client from the same session, the region is set per client.
+func FactoryNewWithSession(sess *session.Session) *Factory {
+	regional := func(region *string) *aws.Config { return &aws.Config{Region: region} }
+	stsAPINew := func() *STSAPI { return STSAPINewWithService(sts.New(sess)) }
+
+	var s3APINew func(region *string) *S3API
+	s3APINew = func(region *string) *S3API {
+		ret := S3APINewWithService(s3.New(sess, regional(region)), region)
+		ret.regionalAPINew = s3APINew
+		return ret
+	}
+
+	return &Factory{
+		Autoscaling: func(region *string) *AutoscalingAPI {
+			return AutoscalingAPINewWithService(autoscaling.New(sess, regional(region)))
+		},
+		Cloudwatch: func(region *string) *CloudwatchAPI {
+			return CloudwatchAPINewWithService(cloudwatch.New(sess, regional(region)))
+		},
+		CloudwatchLogs: func(region *string) *CloudwatchLogsAPI {
+			return CloudwatchLogsAPINewWithService(cloudwatchlogs.New(sess, regional(region)))
+		},
+		DynamoDB: func(region *string) *DynamoDBAPI {
+			return DynamoDBAPINewWithService(dynamodb.New(sess, regional(region)))
+		},
+		EC2: func(region *string) *EC2API { return EC2APINewWithService(ec2.New(sess, regional(region))) },
+		ECS: func(region *string) *ECSAPI { return ECSAPINewWithService(ecs.New(sess, regional(region))) },
+		Elasticache: func(region *string) *ElasticacheAPI {
+			return ElasticacheAPINewWithService(elasticache.New(sess, regional(region)))
+		},
+		ELBV2: func(region *string) *ELBV2API { return ELBV2APINewWithService(elbv2.New(sess, regional(region))) },
+		EventBridge: func(region *string) *EventBridgeAPI {
+			return EventBridgeAPINewWithService(eventbridge.New(sess, regional(region)))
+		},
+		IAM: func(dataDirPath *string) *IAMAPI {
+			return IAMAPINewWithService(iam.New(sess), stsAPINew(), dataDirPath)
+		},
+		Lambda:  func(region *string) *LambdaAPI { return LambdaAPINewWithService(lambda.New(sess, regional(region))) },
+		RDS:     func(region *string) *RDSAPI { return RDSAPINewWithService(rds.New(sess, regional(region))) },
+		Route53: func() *Route53API { return Route53APINewWithService(route53.New(sess)) },
+		S3:      s3APINew,
+		Scheduler: func(region *string) *SchedulerAPI {
+			return SchedulerAPINewWithService(scheduler.New(sess, regional(region)))
+		},
+		Secretsmanager: func(region *string) *SecretsmanagerAPI {
+			return SecretsmanagerAPINewWithService(secretsmanager.New(sess, regional(region)))
+		},
+		STS: stsAPINew,
+	}
+}
+
+// FactoryNewAssumeRole builds the clients with the credentials of roleArn, assumed from profileName.
+// The credentials are refreshed by the SDK when they expire.
+func FactoryNewAssumeRole(profileName *string, roleArn string) *Factory {
+	if profileName == nil {
+		profileNameString := "default"
+		profileName = &profileNameString
+	}
+	sourceSession := session.Must(session.NewSessionWithOptions(session.Options{
+		SharedConfigState: session.SharedConfigEnable,
+		Profile:           *profileName,
+	}))
+	lg.InfoF("AWS profile: %s, assuming role: %s\n", *profileName, roleArn)
+
+	sess := sourceSession.Copy(&aws.Config{Credentials: stscreds.NewCredentials(sourceSession, roleArn)})
+	return FactoryNewWithSession(sess)
+}
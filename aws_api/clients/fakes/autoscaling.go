@@ -0,0 +1,76 @@
+package fakes
+
+import (
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+)
+
+type Autoscaling struct {
+	TagStore
+	AutoScalingGroups    []*autoscaling.Group
+	LaunchConfigurations []*autoscaling.LaunchConfiguration
+}
+
+// The service rejects empty tag lists.
+func (fake *Autoscaling) CreateOrUpdateTags(input *autoscaling.CreateOrUpdateTagsInput) (*autoscaling.CreateOrUpdateTagsOutput, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+
+	if len(input.Tags) == 0 {
+		return nil, awserr.New("ValidationError", "1 validation error detected: Value null at 'tags' failed to satisfy constraint: Member must not be null", nil)
+	}
+
+	fake.TagRequests++
+	for _, tag := range input.Tags {
+		fake.addTags(strValue(tag.ResourceId), map[string]string{strValue(tag.Key): strValue(tag.Value)})
+	}
+	return &autoscaling.CreateOrUpdateTagsOutput{}, nil
+}
+
+func (fake *Autoscaling) DescribeAutoScalingGroupsPages(input *autoscaling.DescribeAutoScalingGroupsInput, callback func(*autoscaling.DescribeAutoScalingGroupsOutput, bool) bool) error {
+	fake.lock.Lock()
+	items := []*autoscaling.Group{}
+	for _, item := range fake.AutoScalingGroups {
+		seeded := map[string]string{}
+		for _, tag := range item.Tags {
+			seeded[strValue(tag.Key)] = strValue(tag.Value)
+		}
+		merged := fake.mergedTags(strValue(item.AutoScalingGroupName), seeded)
+
+		copied := *item
+		copied.Tags = []*autoscaling.TagDescription{}
+		for _, key := range sortedKeys(merged) {
+			copied.Tags = append(copied.Tags, &autoscaling.TagDescription{Key: strPtr(key), Value: strPtr(merged[key]),
+				ResourceId: item.AutoScalingGroupName, ResourceType: strPtr("auto-scaling-group")})
+		}
+		items = append(items, &copied)
+	}
+	fake.lock.Unlock()
+
+	paginate(items, PageSize, func(page []*autoscaling.Group, lastPage bool) bool {
+		return callback(&autoscaling.DescribeAutoScalingGroupsOutput{AutoScalingGroups: page}, lastPage)
+	})
+	return nil
+}
+
+func (fake *Autoscaling) DescribeLaunchConfigurationsPages(input *autoscaling.DescribeLaunchConfigurationsInput, callback func(*autoscaling.DescribeLaunchConfigurationsOutput, bool) bool) error {
+	fake.lock.Lock()
+	items := append([]*autoscaling.LaunchConfiguration{}, fake.LaunchConfigurations...)
+	fake.lock.Unlock()
+
+	paginate(items, PageSize, func(page []*autoscaling.LaunchConfiguration, lastPage bool) bool {
+		return callback(&autoscaling.DescribeLaunchConfigurationsOutput{LaunchConfigurations: page}, lastPage)
+	})
+	return nil
+}
+
+func (fake *Autoscaling) DeleteTags(input *autoscaling.DeleteTagsInput) (*autoscaling.DeleteTagsOutput, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+
+	fake.TagRequests++
+	for _, tag := range input.Tags {
+		fake.removeTags(strValue(tag.ResourceId), []*string{tag.Key})
+	}
+	return &autoscaling.DeleteTagsOutput{}, nil
+}
@@ -0,0 +1,56 @@
+package fakes
+
+import (
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+)
+
+// Tags are seeded through TagStore.Tags, by alarm arn.
+type Cloudwatch struct {
+	TagStore
+	MetricAlarms []*cloudwatch.MetricAlarm
+}
+
+func (fake *Cloudwatch) DescribeAlarmsPages(input *cloudwatch.DescribeAlarmsInput, callback func(*cloudwatch.DescribeAlarmsOutput, bool) bool) error {
+	fake.lock.Lock()
+	items := append([]*cloudwatch.MetricAlarm{}, fake.MetricAlarms...)
+	fake.lock.Unlock()
+
+	paginate(items, PageSize, func(page []*cloudwatch.MetricAlarm, lastPage bool) bool {
+		return callback(&cloudwatch.DescribeAlarmsOutput{MetricAlarms: page}, lastPage)
+	})
+	return nil
+}
+
+func (fake *Cloudwatch) ListTagsForResource(input *cloudwatch.ListTagsForResourceInput) (*cloudwatch.ListTagsForResourceOutput, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+
+	tags := fake.mergedTags(strValue(input.ResourceARN), nil)
+	ret := &cloudwatch.ListTagsForResourceOutput{Tags: []*cloudwatch.Tag{}}
+	for _, key := range sortedKeys(tags) {
+		ret.Tags = append(ret.Tags, &cloudwatch.Tag{Key: strPtr(key), Value: strPtr(tags[key])})
+	}
+	return ret, nil
+}
+
+func (fake *Cloudwatch) TagResource(input *cloudwatch.TagResourceInput) (*cloudwatch.TagResourceOutput, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+
+	fake.TagRequests++
+	tags := map[string]string{}
+	for _, tag := range input.Tags {
+		tags[strValue(tag.Key)] = strValue(tag.Value)
+	}
+	fake.addTags(strValue(input.ResourceARN), tags)
+	return &cloudwatch.TagResourceOutput{}, nil
+}
+
+func (fake *Cloudwatch) UntagResource(input *cloudwatch.UntagResourceInput) (*cloudwatch.UntagResourceOutput, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+
+	fake.TagRequests++
+	fake.removeTags(strValue(input.ResourceARN), input.TagKeys)
+	return &cloudwatch.UntagResourceOutput{}, nil
+}
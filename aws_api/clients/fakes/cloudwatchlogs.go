@@ -0,0 +1,211 @@
+package fakes
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+)
+
+type CloudwatchLogs struct {
+	TagStore
+	LogGroups []*cloudwatchlogs.LogGroup
+	// By log group name.
+	LogStreams map[string][]*cloudwatchlogs.LogStream
+	// By EventsKey(log group name, log stream name).
+	Events map[string][]*cloudwatchlogs.OutputLogEvent
+	// By EventsKey, returned by GetLogEventsPages, e.g. a stream deleted after it was listed.
+	EventsErrors map[string]error
+	// "<log group name>/<log stream name>" of every DeleteLogStream call.
+	DeletedLogStreams []string
+}
+
+func EventsKey(logGroupName, logStreamName string) string {
+	return logGroupName + "/" + logStreamName
+}
+
+func (fake *CloudwatchLogs) CreateLogGroup(input *cloudwatchlogs.CreateLogGroupInput) (*cloudwatchlogs.CreateLogGroupOutput, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+
+	name := strValue(input.LogGroupName)
+	for _, logGroup := range fake.LogGroups {
+		if strValue(logGroup.LogGroupName) == name {
+			return nil, awserr.New(cloudwatchlogs.ErrCodeResourceAlreadyExistsException, "The specified log group already exists", nil)
+		}
+	}
+
+	arn := fmt.Sprintf("arn:aws:logs:us-east-1:123456789012:log-group:%s", name)
+	fake.LogGroups = append(fake.LogGroups, &cloudwatchlogs.LogGroup{LogGroupName: &name, LogGroupArn: &arn, Arn: strPtr(arn + ":*")})
+	for key, value := range input.Tags {
+		fake.addTags(arn, map[string]string{key: strValue(value)})
+	}
+	return &cloudwatchlogs.CreateLogGroupOutput{}, nil
+}
+
+func (fake *CloudwatchLogs) DeleteLogGroup(input *cloudwatchlogs.DeleteLogGroupInput) (*cloudwatchlogs.DeleteLogGroupOutput, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+
+	name := strValue(input.LogGroupName)
+	for index, logGroup := range fake.LogGroups {
+		if strValue(logGroup.LogGroupName) == name {
+			fake.LogGroups = append(fake.LogGroups[:index:index], fake.LogGroups[index+1:]...)
+			delete(fake.LogStreams, name)
+			return &cloudwatchlogs.DeleteLogGroupOutput{}, nil
+		}
+	}
+	return nil, awserr.New(cloudwatchlogs.ErrCodeResourceNotFoundException, "The specified log group does not exist.", nil)
+}
+
+func (fake *CloudwatchLogs) DeleteLogStream(input *cloudwatchlogs.DeleteLogStreamInput) (*cloudwatchlogs.DeleteLogStreamOutput, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+
+	groupName := strValue(input.LogGroupName)
+	streams := fake.LogStreams[groupName]
+	for index, stream := range streams {
+		if strValue(stream.LogStreamName) == strValue(input.LogStreamName) {
+			fake.LogStreams[groupName] = append(streams[:index:index], streams[index+1:]...)
+			fake.DeletedLogStreams = append(fake.DeletedLogStreams, EventsKey(groupName, strValue(input.LogStreamName)))
+			return &cloudwatchlogs.DeleteLogStreamOutput{}, nil
+		}
+	}
+	return nil, awserr.New(cloudwatchlogs.ErrCodeResourceNotFoundException, "The specified log stream does not exist.", nil)
+}
+
+// Pattern is a case insensitive substring, prefix is case sensitive, as in the service.
+func (fake *CloudwatchLogs) filterLogGroups(input *cloudwatchlogs.DescribeLogGroupsInput) []*cloudwatchlogs.LogGroup {
+	ret := []*cloudwatchlogs.LogGroup{}
+	for _, logGroup := range fake.LogGroups {
+		name := strValue(logGroup.LogGroupName)
+		if input != nil && input.LogGroupNamePattern != nil && !strings.Contains(strings.ToLower(name), strings.ToLower(*input.LogGroupNamePattern)) {
+			continue
+		}
+		if input != nil && input.LogGroupNamePrefix != nil && !strings.HasPrefix(name, *input.LogGroupNamePrefix) {
+			continue
+		}
+		ret = append(ret, logGroup)
+	}
+	return ret
+}
+
+func (fake *CloudwatchLogs) DescribeLogGroups(input *cloudwatchlogs.DescribeLogGroupsInput) (*cloudwatchlogs.DescribeLogGroupsOutput, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+
+	return &cloudwatchlogs.DescribeLogGroupsOutput{LogGroups: fake.filterLogGroups(input)}, nil
+}
+
+func (fake *CloudwatchLogs) DescribeLogGroupsPages(input *cloudwatchlogs.DescribeLogGroupsInput, callback func(*cloudwatchlogs.DescribeLogGroupsOutput, bool) bool) error {
+	fake.lock.Lock()
+	items := fake.filterLogGroups(input)
+	fake.lock.Unlock()
+
+	paginate(items, PageSize, func(page []*cloudwatchlogs.LogGroup, lastPage bool) bool {
+		return callback(&cloudwatchlogs.DescribeLogGroupsOutput{LogGroups: page}, lastPage)
+	})
+	return nil
+}
+
+func (fake *CloudwatchLogs) DescribeLogStreamsPages(input *cloudwatchlogs.DescribeLogStreamsInput, callback func(*cloudwatchlogs.DescribeLogStreamsOutput, bool) bool) error {
+	fake.lock.Lock()
+	items := []*cloudwatchlogs.LogStream{}
+	for _, stream := range fake.LogStreams[strValue(input.LogGroupName)] {
+		if input.LogStreamNamePrefix == nil || strings.HasPrefix(strValue(stream.LogStreamName), *input.LogStreamNamePrefix) {
+			items = append(items, stream)
+		}
+	}
+	fake.lock.Unlock()
+
+	if strValue(input.OrderBy) == cloudwatchlogs.OrderByLastEventTime {
+		descending := input.Descending != nil && *input.Descending
+		sort.SliceStable(items, func(i, j int) bool {
+			if descending {
+				return int64Value(items[i].LastEventTimestamp) > int64Value(items[j].LastEventTimestamp)
+			}
+			return int64Value(items[i].LastEventTimestamp) < int64Value(items[j].LastEventTimestamp)
+		})
+	}
+
+	paginate(items, PageSize, func(page []*cloudwatchlogs.LogStream, lastPage bool) bool {
+		return callback(&cloudwatchlogs.DescribeLogStreamsOutput{LogStreams: page}, lastPage)
+	})
+	return nil
+}
+
+func (fake *CloudwatchLogs) filterEvents(input *cloudwatchlogs.GetLogEventsInput) []*cloudwatchlogs.OutputLogEvent {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+
+	ret := []*cloudwatchlogs.OutputLogEvent{}
+	for _, event := range fake.Events[EventsKey(strValue(input.LogGroupName), strValue(input.LogStreamName))] {
+		if input.StartTime != nil && int64Value(event.Timestamp) < *input.StartTime {
+			continue
+		}
+		if input.EndTime != nil && int64Value(event.Timestamp) >= *input.EndTime {
+			continue
+		}
+		ret = append(ret, event)
+	}
+	return ret
+}
+
+// Returns all the events at once, the forward token stays the same so the callers stop polling.
+func (fake *CloudwatchLogs) GetLogEvents(input *cloudwatchlogs.GetLogEventsInput) (*cloudwatchlogs.GetLogEventsOutput, error) {
+	events := fake.filterEvents(input)
+	token := fmt.Sprintf("f/%s/%d", EventsKey(strValue(input.LogGroupName), strValue(input.LogStreamName)), len(events))
+	return &cloudwatchlogs.GetLogEventsOutput{Events: events, NextForwardToken: &token, NextBackwardToken: &token}, nil
+}
+
+func (fake *CloudwatchLogs) GetLogEventsPages(input *cloudwatchlogs.GetLogEventsInput, callback func(*cloudwatchlogs.GetLogEventsOutput, bool) bool) error {
+	if err := fake.EventsErrors[EventsKey(strValue(input.LogGroupName), strValue(input.LogStreamName))]; err != nil {
+		return err
+	}
+	paginate(fake.filterEvents(input), PageSize, func(page []*cloudwatchlogs.OutputLogEvent, lastPage bool) bool {
+		return callback(&cloudwatchlogs.GetLogEventsOutput{Events: page}, lastPage)
+	})
+	return nil
+}
+
+func (fake *CloudwatchLogs) ListTagsForResource(input *cloudwatchlogs.ListTagsForResourceInput) (*cloudwatchlogs.ListTagsForResourceOutput, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+
+	ret := map[string]*string{}
+	for key, value := range fake.mergedTags(strValue(input.ResourceArn), nil) {
+		ret[key] = strPtr(value)
+	}
+	return &cloudwatchlogs.ListTagsForResourceOutput{Tags: ret}, nil
+}
+
+func (fake *CloudwatchLogs) TagResource(input *cloudwatchlogs.TagResourceInput) (*cloudwatchlogs.TagResourceOutput, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+
+	fake.TagRequests++
+	tags := map[string]string{}
+	for key, value := range input.Tags {
+		tags[key] = strValue(value)
+	}
+	fake.addTags(strValue(input.ResourceArn), tags)
+	return &cloudwatchlogs.TagResourceOutput{}, nil
+}
+
+func int64Value(src *int64) int64 {
+	if src == nil {
+		return 0
+	}
+	return *src
+}
+
+func (fake *CloudwatchLogs) UntagResource(input *cloudwatchlogs.UntagResourceInput) (*cloudwatchlogs.UntagResourceOutput, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+
+	fake.TagRequests++
+	fake.removeTags(strValue(input.ResourceArn), input.TagKeys)
+	return &cloudwatchlogs.UntagResourceOutput{}, nil
+}
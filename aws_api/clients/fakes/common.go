@@ -0,0 +1,105 @@
+// Package fakes holds in-memory implementations of the aws_api client interfaces.
+// Resources are seeded through the exported slices, every write is kept in memory
+// so the callers can be tested without credentials or network access.
+package fakes
+
+import (
+	"sort"
+	"sync"
+)
+
+// Small on purpose: multi page responses exercise the pagination callbacks.
+const PageSize = 2
+
+func paginate[T any](items []T, pageSize int, callback func(page []T, lastPage bool) bool) {
+	if len(items) == 0 {
+		callback([]T{}, true)
+		return
+	}
+
+	for start := 0; start < len(items); start += pageSize {
+		end := min(start+pageSize, len(items))
+		if !callback(items[start:end], end == len(items)) {
+			return
+		}
+	}
+}
+
+// Requested page size, PageSize when not set.
+func pageSize(maxResults *int64) int {
+	if maxResults == nil || *maxResults <= 0 {
+		return PageSize
+	}
+	return int(*maxResults)
+}
+
+// TagStore keeps the tags written through a fake, by resource id or arn.
+type TagStore struct {
+	Tags map[string]map[string]string
+	// Number of tagging calls, converged resources must not be tagged again.
+	TagRequests int
+
+	// Removed keys by resource, hide the seeded tags of the same key.
+	removed map[string]map[string]bool
+	lock    sync.Mutex
+}
+
+func (store *TagStore) addTags(resource string, tags map[string]string) {
+	if store.Tags == nil {
+		store.Tags = map[string]map[string]string{}
+	}
+	if store.Tags[resource] == nil {
+		store.Tags[resource] = map[string]string{}
+	}
+	for key, value := range tags {
+		store.Tags[resource][key] = value
+		delete(store.removed[resource], key)
+	}
+}
+
+func (store *TagStore) removeTags(resource string, keys []*string) {
+	if store.removed == nil {
+		store.removed = map[string]map[string]bool{}
+	}
+	if store.removed[resource] == nil {
+		store.removed[resource] = map[string]bool{}
+	}
+	for _, key := range keys {
+		delete(store.Tags[resource], strValue(key))
+		store.removed[resource][strValue(key)] = true
+	}
+}
+
+// Seeded tags overridden by the written ones.
+func (store *TagStore) mergedTags(resource string, seeded map[string]string) map[string]string {
+	ret := map[string]string{}
+	for key, value := range seeded {
+		if !store.removed[resource][key] {
+			ret[key] = value
+		}
+	}
+	for key, value := range store.Tags[resource] {
+		ret[key] = value
+	}
+	return ret
+}
+
+func sortedKeys(tags map[string]string) []string {
+	ret := make([]string, 0, len(tags))
+	for key := range tags {
+		ret = append(ret, key)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func strValue(src *string) string {
+	if src == nil {
+		return ""
+	}
+	return *src
+}
+
+func strPtr(src string) *string {
+	return &src
+}
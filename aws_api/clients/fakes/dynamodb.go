@@ -0,0 +1,78 @@
+package fakes
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// Tags are seeded through TagStore.Tags, by table arn.
+type DynamoDB struct {
+	TagStore
+	Tables []*dynamodb.TableDescription
+}
+
+func (fake *DynamoDB) DescribeTable(input *dynamodb.DescribeTableInput) (*dynamodb.DescribeTableOutput, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+
+	for _, table := range fake.Tables {
+		if strValue(table.TableName) == strValue(input.TableName) {
+			return &dynamodb.DescribeTableOutput{Table: table}, nil
+		}
+	}
+	return nil, awserr.New(dynamodb.ErrCodeResourceNotFoundException, fmt.Sprintf("Requested resource not found: Table: %s not found", strValue(input.TableName)), nil)
+}
+
+func (fake *DynamoDB) ListTablesPages(input *dynamodb.ListTablesInput, callback func(*dynamodb.ListTablesOutput, bool) bool) error {
+	fake.lock.Lock()
+	items := []*string{}
+	for _, table := range fake.Tables {
+		items = append(items, table.TableName)
+	}
+	fake.lock.Unlock()
+
+	size := PageSize
+	if input != nil {
+		size = pageSize(input.Limit)
+	}
+	paginate(items, size, func(page []*string, lastPage bool) bool {
+		return callback(&dynamodb.ListTablesOutput{TableNames: page}, lastPage)
+	})
+	return nil
+}
+
+func (fake *DynamoDB) ListTagsOfResource(input *dynamodb.ListTagsOfResourceInput) (*dynamodb.ListTagsOfResourceOutput, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+
+	tags := fake.mergedTags(strValue(input.ResourceArn), nil)
+	ret := &dynamodb.ListTagsOfResourceOutput{Tags: []*dynamodb.Tag{}}
+	for _, key := range sortedKeys(tags) {
+		ret.Tags = append(ret.Tags, &dynamodb.Tag{Key: strPtr(key), Value: strPtr(tags[key])})
+	}
+	return ret, nil
+}
+
+func (fake *DynamoDB) TagResource(input *dynamodb.TagResourceInput) (*dynamodb.TagResourceOutput, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+
+	fake.TagRequests++
+	tags := map[string]string{}
+	for _, tag := range input.Tags {
+		tags[strValue(tag.Key)] = strValue(tag.Value)
+	}
+	fake.addTags(strValue(input.ResourceArn), tags)
+	return &dynamodb.TagResourceOutput{}, nil
+}
+
+func (fake *DynamoDB) UntagResource(input *dynamodb.UntagResourceInput) (*dynamodb.UntagResourceOutput, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+
+	fake.TagRequests++
+	fake.removeTags(strValue(input.ResourceArn), input.TagKeys)
+	return &dynamodb.UntagResourceOutput{}, nil
+}
@@ -0,0 +1,446 @@
+package fakes
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+type EC2 struct {
+	TagStore
+	Addresses              []*ec2.Address
+	FlowLogs               []*ec2.FlowLog
+	Images                 []*ec2.Image
+	Instances              []*ec2.Instance
+	KeyPairs               []*ec2.KeyPairInfo
+	LaunchTemplates        []*ec2.LaunchTemplate
+	LaunchTemplateVersions []*ec2.LaunchTemplateVersion
+	NatGateways            []*ec2.NatGateway
+	NetworkInterfaces      []*ec2.NetworkInterface
+	SecurityGroups         []*ec2.SecurityGroup
+	Snapshots              []*ec2.Snapshot
+	Volumes                []*ec2.Volume
+	VpcEndpoints           []*ec2.VpcEndpoint
+}
+
+func ec2TagsToMap(tags []*ec2.Tag) map[string]string {
+	ret := map[string]string{}
+	for _, tag := range tags {
+		ret[strValue(tag.Key)] = strValue(tag.Value)
+	}
+	return ret
+}
+
+func (fake *EC2) tags(resource *string, seeded []*ec2.Tag) []*ec2.Tag {
+	merged := fake.mergedTags(strValue(resource), ec2TagsToMap(seeded))
+	ret := []*ec2.Tag{}
+	for _, key := range sortedKeys(merged) {
+		ret = append(ret, &ec2.Tag{Key: strPtr(key), Value: strPtr(merged[key])})
+	}
+	return ret
+}
+
+// Supports the filters used by the callers: exact match on one of the values.
+func matchesFilters(filters []*ec2.Filter, fields map[string]string) bool {
+	for _, filter := range filters {
+		matched := false
+		for _, value := range filter.Values {
+			if fields[strValue(filter.Name)] == strValue(value) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+func (fake *EC2) CreateTags(input *ec2.CreateTagsInput) (*ec2.CreateTagsOutput, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+
+	fake.TagRequests++
+	for _, resource := range input.Resources {
+		fake.addTags(strValue(resource), ec2TagsToMap(input.Tags))
+	}
+	return &ec2.CreateTagsOutput{}, nil
+}
+
+func (fake *EC2) CreateFlowLogs(input *ec2.CreateFlowLogsInput) (*ec2.CreateFlowLogsOutput, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+
+	if strValue(input.LogGroupName) == "" || strValue(input.DeliverLogsPermissionArn) == "" {
+		return nil, awserr.New("InvalidParameter", "log group name and deliver logs permission arn are required", nil)
+	}
+
+	ret := &ec2.CreateFlowLogsOutput{}
+	for _, resourceId := range input.ResourceIds {
+		flowLogId := fmt.Sprintf("fl-%08d", len(fake.FlowLogs)+1)
+		fake.FlowLogs = append(fake.FlowLogs, &ec2.FlowLog{FlowLogId: &flowLogId,
+			ResourceId:               resourceId,
+			LogGroupName:             input.LogGroupName,
+			TrafficType:              input.TrafficType,
+			DeliverLogsPermissionArn: input.DeliverLogsPermissionArn,
+			LogFormat:                input.LogFormat,
+			FlowLogStatus:            strPtr("ACTIVE")})
+		ret.FlowLogIds = append(ret.FlowLogIds, &flowLogId)
+	}
+	return ret, nil
+}
+
+func (fake *EC2) DeleteFlowLogs(input *ec2.DeleteFlowLogsInput) (*ec2.DeleteFlowLogsOutput, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+
+	ret := &ec2.DeleteFlowLogsOutput{}
+	for _, flowLogId := range input.FlowLogIds {
+		index := -1
+		for current, item := range fake.FlowLogs {
+			if strValue(item.FlowLogId) == strValue(flowLogId) {
+				index = current
+			}
+		}
+		if index == -1 {
+			ret.Unsuccessful = append(ret.Unsuccessful, &ec2.UnsuccessfulItem{ResourceId: flowLogId,
+				Error: &ec2.UnsuccessfulItemError{Code: strPtr("InvalidFlowLogId.NotFound"), Message: strPtr("flow log does not exist")}})
+			continue
+		}
+		fake.FlowLogs = append(fake.FlowLogs[:index:index], fake.FlowLogs[index+1:]...)
+	}
+	return ret, nil
+}
+
+func (fake *EC2) DescribeAddresses(input *ec2.DescribeAddressesInput) (*ec2.DescribeAddressesOutput, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+
+	ret := &ec2.DescribeAddressesOutput{}
+	for _, item := range fake.Addresses {
+		copied := *item
+		copied.Tags = fake.tags(item.AllocationId, item.Tags)
+		ret.Addresses = append(ret.Addresses, &copied)
+	}
+	return ret, nil
+}
+
+func (fake *EC2) DescribeFlowLogsPages(input *ec2.DescribeFlowLogsInput, callback func(*ec2.DescribeFlowLogsOutput, bool) bool) error {
+	fake.lock.Lock()
+	items := []*ec2.FlowLog{}
+	for _, item := range fake.FlowLogs {
+		if input == nil || matchesFilters(input.Filter, map[string]string{"resource-id": strValue(item.ResourceId), "log-group-name": strValue(item.LogGroupName)}) {
+			copied := *item
+			copied.Tags = fake.tags(item.FlowLogId, item.Tags)
+			items = append(items, &copied)
+		}
+	}
+	fake.lock.Unlock()
+
+	paginate(items, PageSize, func(page []*ec2.FlowLog, lastPage bool) bool {
+		return callback(&ec2.DescribeFlowLogsOutput{FlowLogs: page}, lastPage)
+	})
+	return nil
+}
+
+func (fake *EC2) DescribeImagesPages(input *ec2.DescribeImagesInput, callback func(*ec2.DescribeImagesOutput, bool) bool) error {
+	fake.lock.Lock()
+	items := []*ec2.Image{}
+	for _, item := range fake.Images {
+		copied := *item
+		copied.Tags = fake.tags(item.ImageId, item.Tags)
+		items = append(items, &copied)
+	}
+	fake.lock.Unlock()
+
+	paginate(items, PageSize, func(page []*ec2.Image, lastPage bool) bool {
+		return callback(&ec2.DescribeImagesOutput{Images: page}, lastPage)
+	})
+	return nil
+}
+
+// Every seeded instance is returned in its own reservation.
+func (fake *EC2) DescribeInstancesPages(input *ec2.DescribeInstancesInput, callback func(*ec2.DescribeInstancesOutput, bool) bool) error {
+	fake.lock.Lock()
+	items := []*ec2.Reservation{}
+	for _, item := range fake.Instances {
+		copied := *item
+		copied.Tags = fake.tags(item.InstanceId, item.Tags)
+		items = append(items, &ec2.Reservation{Instances: []*ec2.Instance{&copied}})
+	}
+	fake.lock.Unlock()
+
+	paginate(items, PageSize, func(page []*ec2.Reservation, lastPage bool) bool {
+		return callback(&ec2.DescribeInstancesOutput{Reservations: page}, lastPage)
+	})
+	return nil
+}
+
+func (fake *EC2) DescribeKeyPairs(input *ec2.DescribeKeyPairsInput) (*ec2.DescribeKeyPairsOutput, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+
+	ret := &ec2.DescribeKeyPairsOutput{}
+	for _, item := range fake.KeyPairs {
+		copied := *item
+		copied.Tags = fake.tags(item.KeyPairId, item.Tags)
+		ret.KeyPairs = append(ret.KeyPairs, &copied)
+	}
+	return ret, nil
+}
+
+func (fake *EC2) DescribeLaunchTemplatesPages(input *ec2.DescribeLaunchTemplatesInput, callback func(*ec2.DescribeLaunchTemplatesOutput, bool) bool) error {
+	fake.lock.Lock()
+	items := []*ec2.LaunchTemplate{}
+	for _, item := range fake.LaunchTemplates {
+		copied := *item
+		copied.Tags = fake.tags(item.LaunchTemplateId, item.Tags)
+		items = append(items, &copied)
+	}
+	fake.lock.Unlock()
+
+	paginate(items, PageSize, func(page []*ec2.LaunchTemplate, lastPage bool) bool {
+		return callback(&ec2.DescribeLaunchTemplatesOutput{LaunchTemplates: page}, lastPage)
+	})
+	return nil
+}
+
+func (fake *EC2) DescribeLaunchTemplateVersionsPages(input *ec2.DescribeLaunchTemplateVersionsInput, callback func(*ec2.DescribeLaunchTemplateVersionsOutput, bool) bool) error {
+	fake.lock.Lock()
+	items := []*ec2.LaunchTemplateVersion{}
+	for _, item := range fake.LaunchTemplateVersions {
+		if strValue(item.LaunchTemplateId) == strValue(input.LaunchTemplateId) {
+			items = append(items, item)
+		}
+	}
+	fake.lock.Unlock()
+
+	paginate(items, PageSize, func(page []*ec2.LaunchTemplateVersion, lastPage bool) bool {
+		return callback(&ec2.DescribeLaunchTemplateVersionsOutput{LaunchTemplateVersions: page}, lastPage)
+	})
+	return nil
+}
+
+func (fake *EC2) DescribeNatGatewaysPages(input *ec2.DescribeNatGatewaysInput, callback func(*ec2.DescribeNatGatewaysOutput, bool) bool) error {
+	fake.lock.Lock()
+	items := []*ec2.NatGateway{}
+	for _, item := range fake.NatGateways {
+		copied := *item
+		copied.Tags = fake.tags(item.NatGatewayId, item.Tags)
+		items = append(items, &copied)
+	}
+	fake.lock.Unlock()
+
+	paginate(items, PageSize, func(page []*ec2.NatGateway, lastPage bool) bool {
+		return callback(&ec2.DescribeNatGatewaysOutput{NatGateways: page}, lastPage)
+	})
+	return nil
+}
+
+func (fake *EC2) DescribeNetworkInterfacesPages(input *ec2.DescribeNetworkInterfacesInput, callback func(*ec2.DescribeNetworkInterfacesOutput, bool) bool) error {
+	fake.lock.Lock()
+	items := []*ec2.NetworkInterface{}
+	for _, item := range fake.NetworkInterfaces {
+		if input != nil && len(input.NetworkInterfaceIds) > 0 && !containsStr(input.NetworkInterfaceIds, strValue(item.NetworkInterfaceId)) {
+			continue
+		}
+		fields := map[string]string{"subnet-id": strValue(item.SubnetId),
+			"vpc-id":               strValue(item.VpcId),
+			"network-interface-id": strValue(item.NetworkInterfaceId),
+			"status":               strValue(item.Status)}
+		if input != nil && !matchesFilters(input.Filters, fields) {
+			continue
+		}
+		copied := *item
+		copied.TagSet = fake.tags(item.NetworkInterfaceId, item.TagSet)
+		items = append(items, &copied)
+	}
+	fake.lock.Unlock()
+
+	paginate(items, PageSize, func(page []*ec2.NetworkInterface, lastPage bool) bool {
+		return callback(&ec2.DescribeNetworkInterfacesOutput{NetworkInterfaces: page}, lastPage)
+	})
+	return nil
+}
+
+func (fake *EC2) DescribeSecurityGroupsPages(input *ec2.DescribeSecurityGroupsInput, callback func(*ec2.DescribeSecurityGroupsOutput, bool) bool) error {
+	fake.lock.Lock()
+	items := []*ec2.SecurityGroup{}
+	for _, item := range fake.SecurityGroups {
+		copied := *item
+		copied.Tags = fake.tags(item.GroupId, item.Tags)
+		items = append(items, &copied)
+	}
+	fake.lock.Unlock()
+
+	paginate(items, PageSize, func(page []*ec2.SecurityGroup, lastPage bool) bool {
+		return callback(&ec2.DescribeSecurityGroupsOutput{SecurityGroups: page}, lastPage)
+	})
+	return nil
+}
+
+func (fake *EC2) DescribeSnapshotsPages(input *ec2.DescribeSnapshotsInput, callback func(*ec2.DescribeSnapshotsOutput, bool) bool) error {
+	fake.lock.Lock()
+	items := []*ec2.Snapshot{}
+	for _, item := range fake.Snapshots {
+		copied := *item
+		copied.Tags = fake.tags(item.SnapshotId, item.Tags)
+		items = append(items, &copied)
+	}
+	fake.lock.Unlock()
+
+	paginate(items, PageSize, func(page []*ec2.Snapshot, lastPage bool) bool {
+		return callback(&ec2.DescribeSnapshotsOutput{Snapshots: page}, lastPage)
+	})
+	return nil
+}
+
+func (fake *EC2) DescribeVolumesPages(input *ec2.DescribeVolumesInput, callback func(*ec2.DescribeVolumesOutput, bool) bool) error {
+	fake.lock.Lock()
+	items := []*ec2.Volume{}
+	for _, item := range fake.Volumes {
+		copied := *item
+		copied.Tags = fake.tags(item.VolumeId, item.Tags)
+		items = append(items, &copied)
+	}
+	fake.lock.Unlock()
+
+	paginate(items, PageSize, func(page []*ec2.Volume, lastPage bool) bool {
+		return callback(&ec2.DescribeVolumesOutput{Volumes: page}, lastPage)
+	})
+	return nil
+}
+
+func (fake *EC2) DescribeVpcEndpointsPages(input *ec2.DescribeVpcEndpointsInput, callback func(*ec2.DescribeVpcEndpointsOutput, bool) bool) error {
+	fake.lock.Lock()
+	items := []*ec2.VpcEndpoint{}
+	for _, item := range fake.VpcEndpoints {
+		copied := *item
+		copied.Tags = fake.tags(item.VpcEndpointId, item.Tags)
+		items = append(items, &copied)
+	}
+	fake.lock.Unlock()
+
+	paginate(items, PageSize, func(page []*ec2.VpcEndpoint, lastPage bool) bool {
+		return callback(&ec2.DescribeVpcEndpointsOutput{VpcEndpoints: page}, lastPage)
+	})
+	return nil
+}
+
+func containsStr(values []*string, value string) bool {
+	for _, candidate := range values {
+		if strValue(candidate) == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (fake *EC2) DeleteTags(input *ec2.DeleteTagsInput) (*ec2.DeleteTagsOutput, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+
+	fake.TagRequests++
+	keys := []*string{}
+	for _, tag := range input.Tags {
+		keys = append(keys, tag.Key)
+	}
+	for _, resource := range input.Resources {
+		fake.removeTags(strValue(resource), keys)
+	}
+	return &ec2.DeleteTagsOutput{}, nil
+}
+
+// removeItem deletes the item with the id from the slice, the service errors are returned by the callers.
+func removeItem[T any](items []*T, id string, itemId func(*T) *string) ([]*T, *T) {
+	for index, item := range items {
+		if strValue(itemId(item)) == id {
+			return append(items[:index:index], items[index+1:]...), item
+		}
+	}
+	return items, nil
+}
+
+func (fake *EC2) DeleteVolume(input *ec2.DeleteVolumeInput) (*ec2.DeleteVolumeOutput, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+
+	for _, volume := range fake.Volumes {
+		if strValue(volume.VolumeId) == strValue(input.VolumeId) && strValue(volume.State) == ec2.VolumeStateInUse {
+			return nil, awserr.New("VolumeInUse", fmt.Sprintf("Volume %s is currently attached", strValue(input.VolumeId)), nil)
+		}
+	}
+	volumes, removed := removeItem(fake.Volumes, strValue(input.VolumeId), func(item *ec2.Volume) *string { return item.VolumeId })
+	if removed == nil {
+		return nil, awserr.New("InvalidVolume.NotFound", fmt.Sprintf("The volume '%s' does not exist.", strValue(input.VolumeId)), nil)
+	}
+	fake.Volumes = volumes
+	return &ec2.DeleteVolumeOutput{}, nil
+}
+
+func (fake *EC2) DeleteSnapshot(input *ec2.DeleteSnapshotInput) (*ec2.DeleteSnapshotOutput, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+
+	for _, image := range fake.Images {
+		for _, mapping := range image.BlockDeviceMappings {
+			if mapping.Ebs != nil && strValue(mapping.Ebs.SnapshotId) == strValue(input.SnapshotId) {
+				return nil, awserr.New("InvalidSnapshot.InUse", fmt.Sprintf("The snapshot %s is currently in use by %s", strValue(input.SnapshotId), strValue(image.ImageId)), nil)
+			}
+		}
+	}
+	snapshots, removed := removeItem(fake.Snapshots, strValue(input.SnapshotId), func(item *ec2.Snapshot) *string { return item.SnapshotId })
+	if removed == nil {
+		return nil, awserr.New("InvalidSnapshot.NotFound", fmt.Sprintf("The snapshot '%s' does not exist.", strValue(input.SnapshotId)), nil)
+	}
+	fake.Snapshots = snapshots
+	return &ec2.DeleteSnapshotOutput{}, nil
+}
+
+func (fake *EC2) DeregisterImage(input *ec2.DeregisterImageInput) (*ec2.DeregisterImageOutput, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+
+	images, removed := removeItem(fake.Images, strValue(input.ImageId), func(item *ec2.Image) *string { return item.ImageId })
+	if removed == nil {
+		return nil, awserr.New("InvalidAMIID.NotFound", fmt.Sprintf("The image id '[%s]' does not exist", strValue(input.ImageId)), nil)
+	}
+	fake.Images = images
+	return &ec2.DeregisterImageOutput{}, nil
+}
+
+func (fake *EC2) ReleaseAddress(input *ec2.ReleaseAddressInput) (*ec2.ReleaseAddressOutput, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+
+	for _, address := range fake.Addresses {
+		if strValue(address.AllocationId) == strValue(input.AllocationId) && address.AssociationId != nil {
+			return nil, awserr.New("InvalidIPAddress.InUse", "Address is in use.", nil)
+		}
+	}
+	addresses, removed := removeItem(fake.Addresses, strValue(input.AllocationId), func(item *ec2.Address) *string { return item.AllocationId })
+	if removed == nil {
+		return nil, awserr.New("InvalidAllocationID.NotFound", fmt.Sprintf("The allocation ID '%s' does not exist", strValue(input.AllocationId)), nil)
+	}
+	fake.Addresses = addresses
+	return &ec2.ReleaseAddressOutput{}, nil
+}
+
+func (fake *EC2) DeleteNetworkInterface(input *ec2.DeleteNetworkInterfaceInput) (*ec2.DeleteNetworkInterfaceOutput, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+
+	for _, networkInterface := range fake.NetworkInterfaces {
+		if strValue(networkInterface.NetworkInterfaceId) == strValue(input.NetworkInterfaceId) && strValue(networkInterface.Status) == ec2.NetworkInterfaceStatusInUse {
+			return nil, awserr.New("InvalidNetworkInterface.InUse", fmt.Sprintf("Interface: [%s] in use.", strValue(input.NetworkInterfaceId)), nil)
+		}
+	}
+	networkInterfaces, removed := removeItem(fake.NetworkInterfaces, strValue(input.NetworkInterfaceId), func(item *ec2.NetworkInterface) *string { return item.NetworkInterfaceId })
+	if removed == nil {
+		return nil, awserr.New("InvalidNetworkInterfaceID.NotFound", fmt.Sprintf("The networkInterface ID '%s' does not exist", strValue(input.NetworkInterfaceId)), nil)
+	}
+	fake.NetworkInterfaces = networkInterfaces
+	return &ec2.DeleteNetworkInterfaceOutput{}, nil
+}
@@ -0,0 +1,300 @@
+package fakes
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+type ECS struct {
+	TagStore
+	Clusters []*ecs.Cluster
+	// By cluster name.
+	Services map[string][]*ecs.Service
+	// By cluster name.
+	Tasks map[string][]*ecs.Task
+	// Without a Status they are ACTIVE.
+	TaskDefinitions []*ecs.TaskDefinition
+}
+
+func ecsTags(tags map[string]string) []*ecs.Tag {
+	ret := []*ecs.Tag{}
+	for _, key := range sortedKeys(tags) {
+		ret = append(ret, &ecs.Tag{Key: strPtr(key), Value: strPtr(tags[key])})
+	}
+	return ret
+}
+
+// The service accepts both names and arns, the fakes key clusters by name.
+func clusterName(cluster *string) string {
+	name := strValue(cluster)
+	if name == "" {
+		return "default"
+	}
+	return name[strings.LastIndex(name, "/")+1:]
+}
+
+func taskDefinitionStatus(taskDefinition *ecs.TaskDefinition) string {
+	if taskDefinition.Status == nil {
+		return ecs.TaskDefinitionStatusActive
+	}
+	return *taskDefinition.Status
+}
+
+// Listing status defaults to ACTIVE, as in the service.
+func statusMatches(requested *string, taskDefinition *ecs.TaskDefinition) bool {
+	if strValue(requested) == ecs.TaskDefinitionFamilyStatusAll {
+		return true
+	}
+	if requested == nil {
+		return taskDefinitionStatus(taskDefinition) == ecs.TaskDefinitionStatusActive
+	}
+	return taskDefinitionStatus(taskDefinition) == *requested
+}
+
+func (fake *ECS) DeregisterTaskDefinition(input *ecs.DeregisterTaskDefinitionInput) (*ecs.DeregisterTaskDefinitionOutput, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+
+	for index, taskDefinition := range fake.TaskDefinitions {
+		if strValue(taskDefinition.TaskDefinitionArn) == strValue(input.TaskDefinition) {
+			copied := *taskDefinition
+			copied.Status = strPtr(ecs.TaskDefinitionStatusInactive)
+			fake.TaskDefinitions[index] = &copied
+			return &ecs.DeregisterTaskDefinitionOutput{TaskDefinition: &copied}, nil
+		}
+	}
+	return nil, awserr.New(ecs.ErrCodeClientException, fmt.Sprintf("Unable to describe task definition %s.", strValue(input.TaskDefinition)), nil)
+}
+
+// Only INACTIVE revisions can be deleted, the others are reported as failures.
+func (fake *ECS) DeleteTaskDefinitions(input *ecs.DeleteTaskDefinitionsInput) (*ecs.DeleteTaskDefinitionsOutput, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+
+	if len(input.TaskDefinitions) > 10 {
+		return nil, awserr.New(ecs.ErrCodeInvalidParameterException, "Too many task definitions", nil)
+	}
+	ret := &ecs.DeleteTaskDefinitionsOutput{}
+	for _, arn := range input.TaskDefinitions {
+		kept := []*ecs.TaskDefinition{}
+		var deleted *ecs.TaskDefinition
+		for _, taskDefinition := range fake.TaskDefinitions {
+			if strValue(taskDefinition.TaskDefinitionArn) == strValue(arn) && taskDefinitionStatus(taskDefinition) == ecs.TaskDefinitionStatusInactive {
+				deleted = taskDefinition
+				continue
+			}
+			kept = append(kept, taskDefinition)
+		}
+		if deleted == nil {
+			ret.Failures = append(ret.Failures, &ecs.Failure{Arn: arn, Reason: strPtr("The specified task definition is not INACTIVE or does not exist.")})
+			continue
+		}
+		fake.TaskDefinitions = kept
+		ret.TaskDefinitions = append(ret.TaskDefinitions, deleted)
+	}
+	return ret, nil
+}
+
+func (fake *ECS) DescribeClusters(input *ecs.DescribeClustersInput) (*ecs.DescribeClustersOutput, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+
+	ret := &ecs.DescribeClustersOutput{}
+	for _, requested := range input.Clusters {
+		found := false
+		for _, cluster := range fake.Clusters {
+			if strValue(cluster.ClusterName) == clusterName(requested) {
+				ret.Clusters = append(ret.Clusters, cluster)
+				found = true
+				break
+			}
+		}
+		if !found {
+			ret.Failures = append(ret.Failures, &ecs.Failure{Arn: requested, Reason: strPtr("MISSING")})
+		}
+	}
+	return ret, nil
+}
+
+func (fake *ECS) DescribeServices(input *ecs.DescribeServicesInput) (*ecs.DescribeServicesOutput, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+
+	if len(input.Services) > 10 {
+		return nil, awserr.New(ecs.ErrCodeInvalidParameterException, "Too many services", nil)
+	}
+	ret := &ecs.DescribeServicesOutput{}
+	for _, service := range fake.Services[clusterName(input.Cluster)] {
+		if containsStr(input.Services, strValue(service.ServiceArn)) {
+			ret.Services = append(ret.Services, service)
+		}
+	}
+	return ret, nil
+}
+
+func (fake *ECS) DescribeTaskDefinition(input *ecs.DescribeTaskDefinitionInput) (*ecs.DescribeTaskDefinitionOutput, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+
+	for _, taskDefinition := range fake.TaskDefinitions {
+		if strValue(taskDefinition.TaskDefinitionArn) == strValue(input.TaskDefinition) {
+			return &ecs.DescribeTaskDefinitionOutput{TaskDefinition: taskDefinition,
+				Tags: ecsTags(fake.mergedTags(strValue(taskDefinition.TaskDefinitionArn), nil))}, nil
+		}
+	}
+	return nil, awserr.New(ecs.ErrCodeClientException, fmt.Sprintf("Unable to describe task definition %s.", strValue(input.TaskDefinition)), nil)
+}
+
+func (fake *ECS) DescribeTasks(input *ecs.DescribeTasksInput) (*ecs.DescribeTasksOutput, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+
+	ret := &ecs.DescribeTasksOutput{}
+	for _, task := range fake.Tasks[clusterName(input.Cluster)] {
+		if containsStr(input.Tasks, strValue(task.TaskArn)) {
+			copied := *task
+			copied.Tags = ecsTags(fake.mergedTags(strValue(task.TaskArn), ecsTagsToMap(task.Tags)))
+			ret.Tasks = append(ret.Tasks, &copied)
+		}
+	}
+	return ret, nil
+}
+
+func (fake *ECS) ListClustersPages(input *ecs.ListClustersInput, callback func(*ecs.ListClustersOutput, bool) bool) error {
+	fake.lock.Lock()
+	items := []*string{}
+	for _, cluster := range fake.Clusters {
+		items = append(items, cluster.ClusterArn)
+	}
+	fake.lock.Unlock()
+
+	paginate(items, pageSize(input.MaxResults), func(page []*string, lastPage bool) bool {
+		return callback(&ecs.ListClustersOutput{ClusterArns: page}, lastPage)
+	})
+	return nil
+}
+
+func (fake *ECS) ListTagsForResource(input *ecs.ListTagsForResourceInput) (*ecs.ListTagsForResourceOutput, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+
+	seeded := map[string]string{}
+	for _, tasks := range fake.Tasks {
+		for _, task := range tasks {
+			if strValue(task.TaskArn) == strValue(input.ResourceArn) {
+				seeded = ecsTagsToMap(task.Tags)
+			}
+		}
+	}
+	return &ecs.ListTagsForResourceOutput{Tags: ecsTags(fake.mergedTags(strValue(input.ResourceArn), seeded))}, nil
+}
+
+func (fake *ECS) ListTaskDefinitionFamiliesPages(input *ecs.ListTaskDefinitionFamiliesInput, callback func(*ecs.ListTaskDefinitionFamiliesOutput, bool) bool) error {
+	fake.lock.Lock()
+	families := map[string]string{}
+	for _, taskDefinition := range fake.TaskDefinitions {
+		family := strValue(taskDefinition.Family)
+		if (input.FamilyPrefix == nil || strings.HasPrefix(family, *input.FamilyPrefix)) && statusMatches(input.Status, taskDefinition) {
+			families[family] = family
+		}
+	}
+	fake.lock.Unlock()
+
+	items := []*string{}
+	for _, family := range sortedKeys(families) {
+		items = append(items, strPtr(family))
+	}
+	paginate(items, pageSize(input.MaxResults), func(page []*string, lastPage bool) bool {
+		return callback(&ecs.ListTaskDefinitionFamiliesOutput{Families: page}, lastPage)
+	})
+	return nil
+}
+
+func (fake *ECS) ListTaskDefinitionsPages(input *ecs.ListTaskDefinitionsInput, callback func(*ecs.ListTaskDefinitionsOutput, bool) bool) error {
+	fake.lock.Lock()
+	items := []*ecs.TaskDefinition{}
+	for _, taskDefinition := range fake.TaskDefinitions {
+		if (input.FamilyPrefix == nil || strings.HasPrefix(strValue(taskDefinition.Family), *input.FamilyPrefix)) && statusMatches(input.Status, taskDefinition) {
+			items = append(items, taskDefinition)
+		}
+	}
+	fake.lock.Unlock()
+
+	descending := strValue(input.Sort) == ecs.SortOrderDesc
+	sort.SliceStable(items, func(i, j int) bool {
+		if strValue(items[i].Family) != strValue(items[j].Family) {
+			return strValue(items[i].Family) < strValue(items[j].Family)
+		}
+		if descending {
+			return int64Value(items[i].Revision) > int64Value(items[j].Revision)
+		}
+		return int64Value(items[i].Revision) < int64Value(items[j].Revision)
+	})
+
+	arns := []*string{}
+	for _, taskDefinition := range items {
+		arns = append(arns, taskDefinition.TaskDefinitionArn)
+	}
+	paginate(arns, pageSize(input.MaxResults), func(page []*string, lastPage bool) bool {
+		return callback(&ecs.ListTaskDefinitionsOutput{TaskDefinitionArns: page}, lastPage)
+	})
+	return nil
+}
+
+func (fake *ECS) ListServicesPages(input *ecs.ListServicesInput, callback func(*ecs.ListServicesOutput, bool) bool) error {
+	fake.lock.Lock()
+	items := []*string{}
+	for _, service := range fake.Services[clusterName(input.Cluster)] {
+		items = append(items, service.ServiceArn)
+	}
+	fake.lock.Unlock()
+
+	paginate(items, pageSize(input.MaxResults), func(page []*string, lastPage bool) bool {
+		return callback(&ecs.ListServicesOutput{ServiceArns: page}, lastPage)
+	})
+	return nil
+}
+
+func (fake *ECS) ListTasksPages(input *ecs.ListTasksInput, callback func(*ecs.ListTasksOutput, bool) bool) error {
+	fake.lock.Lock()
+	items := []*string{}
+	for _, task := range fake.Tasks[clusterName(input.Cluster)] {
+		items = append(items, task.TaskArn)
+	}
+	fake.lock.Unlock()
+
+	paginate(items, pageSize(input.MaxResults), func(page []*string, lastPage bool) bool {
+		return callback(&ecs.ListTasksOutput{TaskArns: page}, lastPage)
+	})
+	return nil
+}
+
+func (fake *ECS) TagResource(input *ecs.TagResourceInput) (*ecs.TagResourceOutput, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+
+	fake.TagRequests++
+	fake.addTags(strValue(input.ResourceArn), ecsTagsToMap(input.Tags))
+	return &ecs.TagResourceOutput{}, nil
+}
+
+func ecsTagsToMap(tags []*ecs.Tag) map[string]string {
+	ret := map[string]string{}
+	for _, tag := range tags {
+		ret[strValue(tag.Key)] = strValue(tag.Value)
+	}
+	return ret
+}
+
+func (fake *ECS) UntagResource(input *ecs.UntagResourceInput) (*ecs.UntagResourceOutput, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+
+	fake.TagRequests++
+	fake.removeTags(strValue(input.ResourceArn), input.TagKeys)
+	return &ecs.UntagResourceOutput{}, nil
+}
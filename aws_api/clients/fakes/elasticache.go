@@ -0,0 +1,60 @@
+package fakes
+
+import (
+	"github.com/aws/aws-sdk-go/service/elasticache"
+)
+
+// Tags are seeded through TagStore.Tags, by cluster arn.
+type Elasticache struct {
+	TagStore
+	CacheClusters []*elasticache.CacheCluster
+}
+
+func (fake *Elasticache) tagList(resource *string) *elasticache.TagListMessage {
+	tags := fake.mergedTags(strValue(resource), nil)
+	ret := &elasticache.TagListMessage{TagList: []*elasticache.Tag{}}
+	for _, key := range sortedKeys(tags) {
+		ret.TagList = append(ret.TagList, &elasticache.Tag{Key: strPtr(key), Value: strPtr(tags[key])})
+	}
+	return ret
+}
+
+func (fake *Elasticache) AddTagsToResource(input *elasticache.AddTagsToResourceInput) (*elasticache.TagListMessage, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+
+	fake.TagRequests++
+	tags := map[string]string{}
+	for _, tag := range input.Tags {
+		tags[strValue(tag.Key)] = strValue(tag.Value)
+	}
+	fake.addTags(strValue(input.ResourceName), tags)
+	return fake.tagList(input.ResourceName), nil
+}
+
+func (fake *Elasticache) DescribeCacheClustersPages(input *elasticache.DescribeCacheClustersInput, callback func(*elasticache.DescribeCacheClustersOutput, bool) bool) error {
+	fake.lock.Lock()
+	items := append([]*elasticache.CacheCluster{}, fake.CacheClusters...)
+	fake.lock.Unlock()
+
+	paginate(items, PageSize, func(page []*elasticache.CacheCluster, lastPage bool) bool {
+		return callback(&elasticache.DescribeCacheClustersOutput{CacheClusters: page}, lastPage)
+	})
+	return nil
+}
+
+func (fake *Elasticache) ListTagsForResource(input *elasticache.ListTagsForResourceInput) (*elasticache.TagListMessage, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+
+	return fake.tagList(input.ResourceName), nil
+}
+
+func (fake *Elasticache) RemoveTagsFromResource(input *elasticache.RemoveTagsFromResourceInput) (*elasticache.TagListMessage, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+
+	fake.TagRequests++
+	fake.removeTags(strValue(input.ResourceName), input.TagKeys)
+	return fake.tagList(input.ResourceName), nil
+}
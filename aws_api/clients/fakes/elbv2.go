@@ -0,0 +1,76 @@
+package fakes
+
+import (
+	"github.com/aws/aws-sdk-go/service/elbv2"
+)
+
+// Tags are seeded through TagStore.Tags, by resource arn.
+type ELBV2 struct {
+	TagStore
+	LoadBalancers []*elbv2.LoadBalancer
+	TargetGroups  []*elbv2.TargetGroup
+}
+
+func (fake *ELBV2) AddTags(input *elbv2.AddTagsInput) (*elbv2.AddTagsOutput, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+
+	fake.TagRequests++
+	tags := map[string]string{}
+	for _, tag := range input.Tags {
+		tags[strValue(tag.Key)] = strValue(tag.Value)
+	}
+	for _, arn := range input.ResourceArns {
+		fake.addTags(strValue(arn), tags)
+	}
+	return &elbv2.AddTagsOutput{}, nil
+}
+
+func (fake *ELBV2) DescribeLoadBalancersPages(input *elbv2.DescribeLoadBalancersInput, callback func(*elbv2.DescribeLoadBalancersOutput, bool) bool) error {
+	fake.lock.Lock()
+	items := append([]*elbv2.LoadBalancer{}, fake.LoadBalancers...)
+	fake.lock.Unlock()
+
+	paginate(items, PageSize, func(page []*elbv2.LoadBalancer, lastPage bool) bool {
+		return callback(&elbv2.DescribeLoadBalancersOutput{LoadBalancers: page}, lastPage)
+	})
+	return nil
+}
+
+func (fake *ELBV2) DescribeTags(input *elbv2.DescribeTagsInput) (*elbv2.DescribeTagsOutput, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+
+	ret := &elbv2.DescribeTagsOutput{}
+	for _, arn := range input.ResourceArns {
+		tags := fake.mergedTags(strValue(arn), nil)
+		description := &elbv2.TagDescription{ResourceArn: arn, Tags: []*elbv2.Tag{}}
+		for _, key := range sortedKeys(tags) {
+			description.Tags = append(description.Tags, &elbv2.Tag{Key: strPtr(key), Value: strPtr(tags[key])})
+		}
+		ret.TagDescriptions = append(ret.TagDescriptions, description)
+	}
+	return ret, nil
+}
+
+func (fake *ELBV2) DescribeTargetGroupsPages(input *elbv2.DescribeTargetGroupsInput, callback func(*elbv2.DescribeTargetGroupsOutput, bool) bool) error {
+	fake.lock.Lock()
+	items := append([]*elbv2.TargetGroup{}, fake.TargetGroups...)
+	fake.lock.Unlock()
+
+	paginate(items, PageSize, func(page []*elbv2.TargetGroup, lastPage bool) bool {
+		return callback(&elbv2.DescribeTargetGroupsOutput{TargetGroups: page}, lastPage)
+	})
+	return nil
+}
+
+func (fake *ELBV2) RemoveTags(input *elbv2.RemoveTagsInput) (*elbv2.RemoveTagsOutput, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+
+	fake.TagRequests++
+	for _, arn := range input.ResourceArns {
+		fake.removeTags(strValue(arn), input.TagKeys)
+	}
+	return &elbv2.RemoveTagsOutput{}, nil
+}
@@ -0,0 +1,58 @@
+package fakes
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/eventbridge"
+)
+
+type EventBridge struct {
+	TagStore
+	Rules []*eventbridge.Rule
+	// By rule name.
+	Targets map[string][]*eventbridge.Target
+}
+
+// tokenPage returns the page starting at the NextToken offset and the token of the next page.
+func tokenPage[T any](items []T, token *string, limit *int64) ([]T, *string, error) {
+	start := 0
+	if token != nil {
+		var err error
+		if start, err = strconv.Atoi(*token); err != nil || start > len(items) {
+			return nil, nil, awserr.New("ValidationException", fmt.Sprintf("Invalid NextToken %s", *token), nil)
+		}
+	}
+	end := min(start+pageSize(limit), len(items))
+	if end == len(items) {
+		return items[start:end], nil, nil
+	}
+	return items[start:end], strPtr(strconv.Itoa(end)), nil
+}
+
+func (fake *EventBridge) ListRules(input *eventbridge.ListRulesInput) (*eventbridge.ListRulesOutput, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+
+	page, token, err := tokenPage(fake.Rules, input.NextToken, input.Limit)
+	if err != nil {
+		return nil, err
+	}
+	return &eventbridge.ListRulesOutput{Rules: page, NextToken: token}, nil
+}
+
+func (fake *EventBridge) ListTargetsByRule(input *eventbridge.ListTargetsByRuleInput) (*eventbridge.ListTargetsByRuleOutput, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+
+	targets, found := fake.Targets[strValue(input.Rule)]
+	if !found {
+		return nil, awserr.New(eventbridge.ErrCodeResourceNotFoundException, fmt.Sprintf("Rule %s does not exist.", strValue(input.Rule)), nil)
+	}
+	page, token, err := tokenPage(targets, input.NextToken, input.Limit)
+	if err != nil {
+		return nil, err
+	}
+	return &eventbridge.ListTargetsByRuleOutput{Targets: page, NextToken: token}, nil
+}
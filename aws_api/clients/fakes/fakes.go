@@ -0,0 +1,108 @@
+package fakes
+
+import (
+	clients "github.com/AlexeyBeley/go_misc/aws_api/clients"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/eventbridge"
+	"github.com/aws/aws-sdk-go/service/route53"
+)
+
+var (
+	_ clients.AutoscalingService    = (*Autoscaling)(nil)
+	_ clients.CloudwatchService     = (*Cloudwatch)(nil)
+	_ clients.CloudwatchLogsService = (*CloudwatchLogs)(nil)
+	_ clients.DynamoDBService       = (*DynamoDB)(nil)
+	_ clients.EC2Service            = (*EC2)(nil)
+	_ clients.ECSService            = (*ECS)(nil)
+	_ clients.ElasticacheService    = (*Elasticache)(nil)
+	_ clients.ELBV2Service          = (*ELBV2)(nil)
+	_ clients.EventBridgeService    = (*EventBridge)(nil)
+	_ clients.IAMService            = (*IAM)(nil)
+	_ clients.LambdaService         = (*Lambda)(nil)
+	_ clients.RDSService            = (*RDS)(nil)
+	_ clients.Route53Service        = (*Route53)(nil)
+	_ clients.S3Service             = (*S3)(nil)
+	_ clients.SchedulerService      = (*Scheduler)(nil)
+	_ clients.SecretsmanagerService = (*Secretsmanager)(nil)
+	_ clients.STSService            = (*STS)(nil)
+)
+
+// Services is a fake account: one fake per service, shared by all the regions.
+type Services struct {
+	Autoscaling    *Autoscaling
+	Cloudwatch     *Cloudwatch
+	CloudwatchLogs *CloudwatchLogs
+	DynamoDB       *DynamoDB
+	EC2            *EC2
+	ECS            *ECS
+	Elasticache    *Elasticache
+	ELBV2          *ELBV2
+	EventBridge    *EventBridge
+	IAM            *IAM
+	Lambda         *Lambda
+	RDS            *RDS
+	Route53        *Route53
+	S3             *S3
+	Scheduler      *Scheduler
+	Secretsmanager *Secretsmanager
+	STS            *STS
+}
+
+func ServicesNew() *Services {
+	return &Services{Autoscaling: &Autoscaling{},
+		Cloudwatch:     &Cloudwatch{},
+		CloudwatchLogs: &CloudwatchLogs{LogStreams: map[string][]*cloudwatchlogs.LogStream{}, Events: map[string][]*cloudwatchlogs.OutputLogEvent{}},
+		DynamoDB:       &DynamoDB{},
+		EC2:            &EC2{},
+		ECS:            &ECS{Services: map[string][]*ecs.Service{}, Tasks: map[string][]*ecs.Task{}},
+		Elasticache:    &Elasticache{},
+		ELBV2:          &ELBV2{},
+		EventBridge:    &EventBridge{Targets: map[string][]*eventbridge.Target{}},
+		IAM:            &IAM{},
+		Lambda:         &Lambda{},
+		RDS:            &RDS{},
+		Route53:        &Route53{ResourceRecordSets: map[string][]*route53.ResourceRecordSet{}},
+		S3:             &S3{},
+		Scheduler:      &Scheduler{},
+		Secretsmanager: &Secretsmanager{},
+		STS:            &STS{},
+	}
+}
+
+// Factory returns API wrappers backed by the fakes, for every region.
+func (services *Services) Factory() *clients.Factory {
+	return &clients.Factory{
+		Autoscaling: func(*string) *clients.AutoscalingAPI {
+			return clients.AutoscalingAPINewWithService(services.Autoscaling)
+		},
+		Cloudwatch: func(*string) *clients.CloudwatchAPI { return clients.CloudwatchAPINewWithService(services.Cloudwatch) },
+		CloudwatchLogs: func(*string) *clients.CloudwatchLogsAPI {
+			return clients.CloudwatchLogsAPINewWithService(services.CloudwatchLogs)
+		},
+		DynamoDB: func(*string) *clients.DynamoDBAPI { return clients.DynamoDBAPINewWithService(services.DynamoDB) },
+		EC2:      func(*string) *clients.EC2API { return clients.EC2APINewWithService(services.EC2) },
+		ECS:      func(*string) *clients.ECSAPI { return clients.ECSAPINewWithService(services.ECS) },
+		Elasticache: func(*string) *clients.ElasticacheAPI {
+			return clients.ElasticacheAPINewWithService(services.Elasticache)
+		},
+		ELBV2: func(*string) *clients.ELBV2API { return clients.ELBV2APINewWithService(services.ELBV2) },
+		EventBridge: func(*string) *clients.EventBridgeAPI {
+			return clients.EventBridgeAPINewWithService(services.EventBridge)
+		},
+		IAM: func(dataDirPath *string) *clients.IAMAPI {
+			return clients.IAMAPINewWithService(services.IAM, clients.STSAPINewWithService(services.STS), dataDirPath)
+		},
+		Lambda:  func(*string) *clients.LambdaAPI { return clients.LambdaAPINewWithService(services.Lambda) },
+		RDS:     func(*string) *clients.RDSAPI { return clients.RDSAPINewWithService(services.RDS) },
+		Route53: func() *clients.Route53API { return clients.Route53APINewWithService(services.Route53) },
+		S3:      func(region *string) *clients.S3API { return clients.S3APINewWithService(services.S3, region) },
+		Scheduler: func(*string) *clients.SchedulerAPI {
+			return clients.SchedulerAPINewWithService(services.Scheduler)
+		},
+		Secretsmanager: func(*string) *clients.SecretsmanagerAPI {
+			return clients.SecretsmanagerAPINewWithService(services.Secretsmanager)
+		},
+		STS: func() *clients.STSAPI { return clients.STSAPINewWithService(services.STS) },
+	}
+}
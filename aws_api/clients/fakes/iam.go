@@ -0,0 +1,112 @@
+package fakes
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+type IAM struct {
+	// By role name.
+	Roles map[string]*iam.Role
+	// Inline policy documents by role name and policy name.
+	RolePolicies map[string]map[string]string
+
+	lock sync.Mutex
+}
+
+func (fake *IAM) CreateRole(input *iam.CreateRoleInput) (*iam.CreateRoleOutput, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+
+	name := strValue(input.RoleName)
+	if _, found := fake.Roles[name]; found {
+		return nil, awserr.New(iam.ErrCodeEntityAlreadyExistsException, fmt.Sprintf("Role with name %s already exists.", name), nil)
+	}
+	if fake.Roles == nil {
+		fake.Roles = map[string]*iam.Role{}
+	}
+
+	path := input.Path
+	if path == nil {
+		path = strPtr("/")
+	}
+	arn := fmt.Sprintf("arn:aws:iam::123456789012:role%s%s", *path, name)
+	role := &iam.Role{RoleName: &name, Path: path, Arn: &arn, AssumeRolePolicyDocument: input.AssumeRolePolicyDocument}
+	fake.Roles[name] = role
+	return &iam.CreateRoleOutput{Role: role}, nil
+}
+
+// Fails as the service while inline policies are attached.
+func (fake *IAM) DeleteRole(input *iam.DeleteRoleInput) (*iam.DeleteRoleOutput, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+
+	name := strValue(input.RoleName)
+	if _, found := fake.Roles[name]; !found {
+		return nil, awserr.New(iam.ErrCodeNoSuchEntityException, fmt.Sprintf("The role with name %s cannot be found.", name), nil)
+	}
+	if len(fake.RolePolicies[name]) > 0 {
+		return nil, awserr.New(iam.ErrCodeDeleteConflictException, "Cannot delete entity, must delete policies first.", nil)
+	}
+	delete(fake.Roles, name)
+	return &iam.DeleteRoleOutput{}, nil
+}
+
+func (fake *IAM) DeleteRolePolicy(input *iam.DeleteRolePolicyInput) (*iam.DeleteRolePolicyOutput, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+
+	name := strValue(input.RoleName)
+	if _, found := fake.RolePolicies[name][strValue(input.PolicyName)]; !found {
+		return nil, awserr.New(iam.ErrCodeNoSuchEntityException, fmt.Sprintf("The role policy with name %s cannot be found.", strValue(input.PolicyName)), nil)
+	}
+	delete(fake.RolePolicies[name], strValue(input.PolicyName))
+	return &iam.DeleteRolePolicyOutput{}, nil
+}
+
+func (fake *IAM) GetRole(input *iam.GetRoleInput) (*iam.GetRoleOutput, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+
+	role, found := fake.Roles[strValue(input.RoleName)]
+	if !found {
+		return nil, awserr.New(iam.ErrCodeNoSuchEntityException, fmt.Sprintf("The role with name %s cannot be found.", strValue(input.RoleName)), nil)
+	}
+	return &iam.GetRoleOutput{Role: role}, nil
+}
+
+func (fake *IAM) PutRolePolicy(input *iam.PutRolePolicyInput) (*iam.PutRolePolicyOutput, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+
+	name := strValue(input.RoleName)
+	if _, found := fake.Roles[name]; !found {
+		return nil, awserr.New(iam.ErrCodeNoSuchEntityException, fmt.Sprintf("The role with name %s cannot be found.", name), nil)
+	}
+	if fake.RolePolicies == nil {
+		fake.RolePolicies = map[string]map[string]string{}
+	}
+	if fake.RolePolicies[name] == nil {
+		fake.RolePolicies[name] = map[string]string{}
+	}
+	fake.RolePolicies[name][strValue(input.PolicyName)] = strValue(input.PolicyDocument)
+	return &iam.PutRolePolicyOutput{}, nil
+}
+
+type STS struct {
+	Account string
+}
+
+func (fake *STS) GetCallerIdentity(input *sts.GetCallerIdentityInput) (*sts.GetCallerIdentityOutput, error) {
+	account := fake.Account
+	if account == "" {
+		account = "123456789012"
+	}
+	return &sts.GetCallerIdentityOutput{Account: &account,
+		Arn:    strPtr(fmt.Sprintf("arn:aws:iam::%s:user/fake", account)),
+		UserId: strPtr("AIDAFAKE")}, nil
+}
@@ -0,0 +1,29 @@
+package fakes
+
+import (
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+// Tags are seeded through TagStore.Tags, by function arn.
+type Lambda struct {
+	TagStore
+	Functions []*lambda.FunctionConfiguration
+}
+
+func (fake *Lambda) ListFunctionsPages(input *lambda.ListFunctionsInput, callback func(*lambda.ListFunctionsOutput, bool) bool) error {
+	paginate(fake.Functions, PageSize, func(page []*lambda.FunctionConfiguration, lastPage bool) bool {
+		return callback(&lambda.ListFunctionsOutput{Functions: page}, lastPage)
+	})
+	return nil
+}
+
+func (fake *Lambda) ListTags(input *lambda.ListTagsInput) (*lambda.ListTagsOutput, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+
+	ret := &lambda.ListTagsOutput{Tags: map[string]*string{}}
+	for key, value := range fake.mergedTags(strValue(input.Resource), nil) {
+		ret.Tags[key] = strPtr(value)
+	}
+	return ret, nil
+}
@@ -0,0 +1,78 @@
+package fakes
+
+import (
+	"github.com/aws/aws-sdk-go/service/rds"
+)
+
+type RDS struct {
+	TagStore
+	DBClusters  []*rds.DBCluster
+	DBInstances []*rds.DBInstance
+}
+
+func (fake *RDS) tags(resource *string, seeded []*rds.Tag) []*rds.Tag {
+	seededMap := map[string]string{}
+	for _, tag := range seeded {
+		seededMap[strValue(tag.Key)] = strValue(tag.Value)
+	}
+	merged := fake.mergedTags(strValue(resource), seededMap)
+	ret := []*rds.Tag{}
+	for _, key := range sortedKeys(merged) {
+		ret = append(ret, &rds.Tag{Key: strPtr(key), Value: strPtr(merged[key])})
+	}
+	return ret
+}
+
+func (fake *RDS) AddTagsToResource(input *rds.AddTagsToResourceInput) (*rds.AddTagsToResourceOutput, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+
+	fake.TagRequests++
+	tags := map[string]string{}
+	for _, tag := range input.Tags {
+		tags[strValue(tag.Key)] = strValue(tag.Value)
+	}
+	fake.addTags(strValue(input.ResourceName), tags)
+	return &rds.AddTagsToResourceOutput{}, nil
+}
+
+func (fake *RDS) DescribeDBClustersPages(input *rds.DescribeDBClustersInput, callback func(*rds.DescribeDBClustersOutput, bool) bool) error {
+	fake.lock.Lock()
+	items := []*rds.DBCluster{}
+	for _, item := range fake.DBClusters {
+		copied := *item
+		copied.TagList = fake.tags(item.DBClusterArn, item.TagList)
+		items = append(items, &copied)
+	}
+	fake.lock.Unlock()
+
+	paginate(items, PageSize, func(page []*rds.DBCluster, lastPage bool) bool {
+		return callback(&rds.DescribeDBClustersOutput{DBClusters: page}, lastPage)
+	})
+	return nil
+}
+
+func (fake *RDS) DescribeDBInstancesPages(input *rds.DescribeDBInstancesInput, callback func(*rds.DescribeDBInstancesOutput, bool) bool) error {
+	fake.lock.Lock()
+	items := []*rds.DBInstance{}
+	for _, item := range fake.DBInstances {
+		copied := *item
+		copied.TagList = fake.tags(item.DBInstanceArn, item.TagList)
+		items = append(items, &copied)
+	}
+	fake.lock.Unlock()
+
+	paginate(items, PageSize, func(page []*rds.DBInstance, lastPage bool) bool {
+		return callback(&rds.DescribeDBInstancesOutput{DBInstances: page}, lastPage)
+	})
+	return nil
+}
+
+func (fake *RDS) RemoveTagsFromResource(input *rds.RemoveTagsFromResourceInput) (*rds.RemoveTagsFromResourceOutput, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+
+	fake.TagRequests++
+	fake.removeTags(strValue(input.ResourceName), input.TagKeys)
+	return &rds.RemoveTagsFromResourceOutput{}, nil
+}
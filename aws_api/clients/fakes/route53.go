@@ -0,0 +1,43 @@
+package fakes
+
+import (
+	"github.com/aws/aws-sdk-go/service/route53"
+)
+
+// Tags are seeded through TagStore.Tags, by hosted zone id without the "/hostedzone/" prefix.
+type Route53 struct {
+	TagStore
+	HostedZones []*route53.HostedZone
+	// By hosted zone id.
+	ResourceRecordSets map[string][]*route53.ResourceRecordSet
+}
+
+func (fake *Route53) ListHostedZonesPages(input *route53.ListHostedZonesInput, callback func(*route53.ListHostedZonesOutput, bool) bool) error {
+	paginate(fake.HostedZones, PageSize, func(page []*route53.HostedZone, lastPage bool) bool {
+		return callback(&route53.ListHostedZonesOutput{HostedZones: page, IsTruncated: boolPtr(!lastPage)}, lastPage)
+	})
+	return nil
+}
+
+func (fake *Route53) ListResourceRecordSetsPages(input *route53.ListResourceRecordSetsInput, callback func(*route53.ListResourceRecordSetsOutput, bool) bool) error {
+	paginate(fake.ResourceRecordSets[strValue(input.HostedZoneId)], PageSize, func(page []*route53.ResourceRecordSet, lastPage bool) bool {
+		return callback(&route53.ListResourceRecordSetsOutput{ResourceRecordSets: page, IsTruncated: boolPtr(!lastPage)}, lastPage)
+	})
+	return nil
+}
+
+func (fake *Route53) ListTagsForResource(input *route53.ListTagsForResourceInput) (*route53.ListTagsForResourceOutput, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+
+	tags := fake.mergedTags(strValue(input.ResourceId), nil)
+	tagSet := &route53.ResourceTagSet{ResourceId: input.ResourceId, ResourceType: input.ResourceType, Tags: []*route53.Tag{}}
+	for _, key := range sortedKeys(tags) {
+		tagSet.Tags = append(tagSet.Tags, &route53.Tag{Key: strPtr(key), Value: strPtr(tags[key])})
+	}
+	return &route53.ListTagsForResourceOutput{ResourceTagSet: tagSet}, nil
+}
+
+func boolPtr(src bool) *bool {
+	return &src
+}
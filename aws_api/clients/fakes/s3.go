@@ -0,0 +1,74 @@
+package fakes
+
+import (
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// Bucket tags are seeded through TagStore.Tags, by bucket name.
+type S3 struct {
+	TagStore
+	Buckets []*s3.Bucket
+	// By bucket name, buckets without an entry are in us-east-1.
+	BucketRegions map[string]string
+}
+
+func (fake *S3) GetBucketLocation(input *s3.GetBucketLocationInput) (*s3.GetBucketLocationOutput, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+
+	region, found := fake.BucketRegions[strValue(input.Bucket)]
+	if !found || region == "us-east-1" {
+		return &s3.GetBucketLocationOutput{}, nil
+	}
+	return &s3.GetBucketLocationOutput{LocationConstraint: &region}, nil
+}
+
+func (fake *S3) GetBucketTagging(input *s3.GetBucketTaggingInput) (*s3.GetBucketTaggingOutput, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+
+	tags := fake.Tags[strValue(input.Bucket)]
+	if len(tags) == 0 {
+		return nil, awserr.New("NoSuchTagSet", "The TagSet does not exist", nil)
+	}
+
+	ret := &s3.GetBucketTaggingOutput{TagSet: []*s3.Tag{}}
+	for _, key := range sortedKeys(tags) {
+		ret.TagSet = append(ret.TagSet, &s3.Tag{Key: strPtr(key), Value: strPtr(tags[key])})
+	}
+	return ret, nil
+}
+
+func (fake *S3) ListBuckets(input *s3.ListBucketsInput) (*s3.ListBucketsOutput, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+
+	return &s3.ListBucketsOutput{Buckets: fake.Buckets}, nil
+}
+
+// Replaces the whole tag set, as the service does.
+func (fake *S3) PutBucketTagging(input *s3.PutBucketTaggingInput) (*s3.PutBucketTaggingOutput, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+
+	fake.TagRequests++
+	tags := map[string]string{}
+	for _, tag := range input.Tagging.TagSet {
+		tags[strValue(tag.Key)] = strValue(tag.Value)
+	}
+	if fake.Tags == nil {
+		fake.Tags = map[string]map[string]string{}
+	}
+	fake.Tags[strValue(input.Bucket)] = tags
+	return &s3.PutBucketTaggingOutput{}, nil
+}
+
+func (fake *S3) DeleteBucketTagging(input *s3.DeleteBucketTaggingInput) (*s3.DeleteBucketTaggingOutput, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+
+	fake.TagRequests++
+	delete(fake.Tags, strValue(input.Bucket))
+	return &s3.DeleteBucketTaggingOutput{}, nil
+}
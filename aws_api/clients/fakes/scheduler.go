@@ -0,0 +1,41 @@
+package fakes
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/scheduler"
+)
+
+type Scheduler struct {
+	TagStore
+	Schedules []*scheduler.GetScheduleOutput
+}
+
+func (fake *Scheduler) GetSchedule(input *scheduler.GetScheduleInput) (*scheduler.GetScheduleOutput, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+
+	for _, schedule := range fake.Schedules {
+		if strValue(schedule.Name) == strValue(input.Name) && strValue(schedule.GroupName) == strValue(input.GroupName) {
+			return schedule, nil
+		}
+	}
+	return nil, awserr.New(scheduler.ErrCodeResourceNotFoundException, fmt.Sprintf("Schedule %s does not exist.", strValue(input.Name)), nil)
+}
+
+func (fake *Scheduler) ListSchedulesPages(input *scheduler.ListSchedulesInput, callback func(*scheduler.ListSchedulesOutput, bool) bool) error {
+	fake.lock.Lock()
+	items := []*scheduler.ScheduleSummary{}
+	for _, schedule := range fake.Schedules {
+		if input.GroupName == nil || strValue(schedule.GroupName) == *input.GroupName {
+			items = append(items, &scheduler.ScheduleSummary{Name: schedule.Name, GroupName: schedule.GroupName, Arn: schedule.Arn, State: schedule.State})
+		}
+	}
+	fake.lock.Unlock()
+
+	paginate(items, pageSize(input.MaxResults), func(page []*scheduler.ScheduleSummary, lastPage bool) bool {
+		return callback(&scheduler.ListSchedulesOutput{Schedules: page}, lastPage)
+	})
+	return nil
+}
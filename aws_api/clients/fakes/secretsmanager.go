@@ -0,0 +1,57 @@
+package fakes
+
+import (
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+)
+
+type Secretsmanager struct {
+	TagStore
+	Secrets []*secretsmanager.SecretListEntry
+}
+
+func (fake *Secretsmanager) ListSecretsPages(input *secretsmanager.ListSecretsInput, callback func(*secretsmanager.ListSecretsOutput, bool) bool) error {
+	fake.lock.Lock()
+	items := []*secretsmanager.SecretListEntry{}
+	for _, item := range fake.Secrets {
+		seeded := map[string]string{}
+		for _, tag := range item.Tags {
+			seeded[strValue(tag.Key)] = strValue(tag.Value)
+		}
+		merged := fake.mergedTags(strValue(item.ARN), seeded)
+
+		copied := *item
+		copied.Tags = []*secretsmanager.Tag{}
+		for _, key := range sortedKeys(merged) {
+			copied.Tags = append(copied.Tags, &secretsmanager.Tag{Key: strPtr(key), Value: strPtr(merged[key])})
+		}
+		items = append(items, &copied)
+	}
+	fake.lock.Unlock()
+
+	paginate(items, PageSize, func(page []*secretsmanager.SecretListEntry, lastPage bool) bool {
+		return callback(&secretsmanager.ListSecretsOutput{SecretList: page}, lastPage)
+	})
+	return nil
+}
+
+func (fake *Secretsmanager) TagResource(input *secretsmanager.TagResourceInput) (*secretsmanager.TagResourceOutput, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+
+	fake.TagRequests++
+	tags := map[string]string{}
+	for _, tag := range input.Tags {
+		tags[strValue(tag.Key)] = strValue(tag.Value)
+	}
+	fake.addTags(strValue(input.SecretId), tags)
+	return &secretsmanager.TagResourceOutput{}, nil
+}
+
+func (fake *Secretsmanager) UntagResource(input *secretsmanager.UntagResourceInput) (*secretsmanager.UntagResourceOutput, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+
+	fake.TagRequests++
+	fake.removeTags(strValue(input.SecretId), input.TagKeys)
+	return &secretsmanager.UntagResourceOutput{}, nil
+}
@@ -11,14 +11,16 @@ import (
 
 	replacementEngine "github.com/AlexeyBeley/go_misc/replacement_engine"
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/iam"
 )
 
 type IAMAPI struct {
-	svc         *iam.IAM
+	svc         IAMService
 	DataDirPath *string
 	ProfileName *string
+	stsAPINew   func() *STSAPI
 }
 
 func IAMAPINew(profileName *string, DataDirPath *string) *IAMAPI {
@@ -33,9 +35,15 @@ func IAMAPINew(profileName *string, DataDirPath *string) *IAMAPI {
 	lg.InfoF("AWS profile: %s\n", *profileName)
 	svc := iam.New(sess)
 	ret := IAMAPI{svc: svc, DataDirPath: DataDirPath, ProfileName: profileName}
+	ret.stsAPINew = func() *STSAPI { return STSAPINew(profileName) }
 	return &ret
 }
 
+// The account id of the generated policies is resolved through stsAPI.
+func IAMAPINewWithService(svc IAMService, stsAPI *STSAPI, DataDirPath *string) *IAMAPI {
+	return &IAMAPI{svc: svc, DataDirPath: DataDirPath, stsAPINew: func() *STSAPI { return stsAPI }}
+}
+
 func isValidJSON(s string) bool {
 	var js map[string]interface{}
 	return json.Unmarshal([]byte(s), &js) == nil
@@ -191,8 +199,29 @@ func (api *IAMAPI) UpdateRoleInfo(role *Role) (bool, error) {
 	return true, nil
 }
 
+// DisposeIamRole deletes the role inline policies and the role, an already deleted role is not an error.
+func (api *IAMAPI) DisposeIamRole(role *Role) error {
+	lg.InfoF("Disposing role %s", *role.Name)
+	for _, policy := range role.InlinePolicies {
+		_, err := api.svc.DeleteRolePolicy(&iam.DeleteRolePolicyInput{RoleName: role.Name, PolicyName: policy.Name})
+		if err != nil && !isNoSuchEntity(err) {
+			return err
+		}
+	}
+	_, err := api.svc.DeleteRole(&iam.DeleteRoleInput{RoleName: role.Name})
+	if err != nil && !isNoSuchEntity(err) {
+		return err
+	}
+	return nil
+}
+
+func isNoSuchEntity(err error) bool {
+	aerr, ok := err.(awserr.Error)
+	return ok && aerr.Code() == iam.ErrCodeNoSuchEntityException
+}
+
 func (api *IAMAPI) ProvisionIamCloudwatchWriterRole(region, roleName, strAssumeDocument, path *string) (*Role, error) {
-	stsAPI := STSAPINew(api.ProfileName)
+	stsAPI := api.stsAPINew()
 	accountID, err := stsAPI.GetAccount()
 	if err != nil {
 		return nil, err
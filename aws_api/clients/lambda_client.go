@@ -1,7 +1,8 @@
 package aws_api
 
 import (
-	"fmt"
+	"context"
+	"iter"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
@@ -9,7 +10,7 @@ import (
 )
 
 type LambdaAPI struct {
-	svc *lambda.Lambda
+	svc LambdaService
 }
 
 func LambdaAPINew(region *string, profileName *string) *LambdaAPI {
@@ -20,45 +21,35 @@ func LambdaAPINew(region *string, profileName *string) *LambdaAPI {
 	sess := session.Must(session.NewSessionWithOptions(session.Options{
 		SharedConfigState: session.SharedConfigEnable,
 		Config:            aws.Config{Region: region},
+		Profile:           *profileName,
 	}))
 	ret := LambdaAPI{svc: lambda.New(sess)}
 	return &ret
 }
 
-func (api *LambdaAPI) YieldFunctions(callback GenericCallbackNG, Input *lambda.ListFunctionsInput) error {
-	var callbackErr error
-	pageNum := 0
-	err := api.svc.ListFunctionsPages(Input, func(page *lambda.ListFunctionsOutput, lastPage bool) bool {
-
-		lg.DebugF("ListFunctionsPages page: %d", pageNum)
-		pageNum++
+func LambdaAPINewWithService(svc LambdaService) *LambdaAPI {
+	return &LambdaAPI{svc: svc}
+}
 
-		for _, object := range page.Functions {
+func (api *LambdaAPI) IterFunctions(ctx context.Context, Input *lambda.ListFunctionsInput) iter.Seq2[*lambda.FunctionConfiguration, error] {
+	return Paginate(ctx, func(callback func(*lambda.ListFunctionsOutput, bool) bool) error {
+		return api.svc.ListFunctionsPages(Input, callback)
+	}, Items(func(page *lambda.ListFunctionsOutput) []*lambda.FunctionConfiguration { return page.Functions }))
+}
 
-			if continuePagination, callbackErrTmp := callback(object); !continuePagination {
-				callbackErr = callbackErrTmp
-				return false
-			}
-		}
-		return !lastPage
-	})
-	if callbackErr != nil {
-		return callbackErr
-	}
-	return err
+// Deprecated: use IterFunctions.
+func (api *LambdaAPI) YieldFunctions(callback GenericCallbackNG, Input *lambda.ListFunctionsInput) error {
+	return yieldToCallbackNG(api.IterFunctions(context.Background(), Input), callback)
 }
 
 func (api *LambdaAPI) GetFunctions(Input *lambda.ListFunctionsInput) ([]*lambda.FunctionConfiguration, error) {
-	objects := make([]any, 0)
+	return Collect(api.IterFunctions(context.Background(), Input))
+}
 
-	err := api.YieldFunctions(AggregatorInitializerNG(&objects), nil)
-	ret := []*lambda.FunctionConfiguration{}
-	for _, objAny := range objects {
-		obj, ok := objAny.(*lambda.FunctionConfiguration)
-		if !ok {
-			return nil, fmt.Errorf("casting error: %v ", objAny)
-		}
-		ret = append(ret, obj)
+func (api *LambdaAPI) GetTags(functionArn *string) (map[string]*string, error) {
+	output, err := api.svc.ListTags(&lambda.ListTagsInput{Resource: functionArn})
+	if err != nil {
+		return nil, err
 	}
-	return ret, err
+	return output.Tags, nil
 }
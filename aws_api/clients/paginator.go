@@ -0,0 +1,150 @@
+package aws_api
+
+import (
+	"context"
+	"iter"
+)
+
+// Pages is an SDK *Pages call bound to its input: it calls the callback for every page until the callback returns false.
+// The page size is the MaxResults / Limit / MaxItems of the bound input.
+type Pages[P any] func(callback func(page P, lastPage bool) bool) error
+
+// Paginate yields the items of every page.
+// Breaking out of the range loop stops fetching pages, the context is checked before every page and item.
+// A failed fetch or a done context is yielded as the last element with the zero item.
+func Paginate[P any, T any](ctx context.Context, pages Pages[P], items func(page P) ([]T, error)) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		stopped := false
+		var itemsErr error
+
+		err := ctx.Err()
+		if err == nil {
+			err = pages(func(page P, lastPage bool) bool {
+				if ctx.Err() != nil {
+					return false
+				}
+
+				pageItems, err := items(page)
+				if err != nil {
+					itemsErr = err
+					return false
+				}
+
+				for _, item := range pageItems {
+					if ctx.Err() != nil {
+						return false
+					}
+					if !yield(item, nil) {
+						stopped = true
+						return false
+					}
+				}
+				return !lastPage
+			})
+		}
+
+		if stopped {
+			return
+		}
+		if err == nil {
+			err = itemsErr
+		}
+		if err == nil {
+			err = ctx.Err()
+		}
+		if err != nil {
+			yield(zero, err)
+		}
+	}
+}
+
+// Single is Paginate for the calls without pagination.
+func Single[T any](ctx context.Context, fetch func() ([]T, error)) iter.Seq2[T, error] {
+	return Paginate(ctx, func(callback func(page []T, lastPage bool) bool) error {
+		items, err := fetch()
+		if err != nil {
+			return err
+		}
+		callback(items, true)
+		return nil
+	}, func(page []T) ([]T, error) { return page, nil })
+}
+
+// Items adapts a page field getter to Paginate.
+func Items[P any, T any](getter func(page P) []T) func(page P) ([]T, error) {
+	return func(page P) ([]T, error) {
+		return getter(page), nil
+	}
+}
+
+// Collect drains the iterator, returns the items collected before the first error.
+func Collect[T any](seq iter.Seq2[T, error]) ([]T, error) {
+	ret := []T{}
+	for item, err := range seq {
+		if err != nil {
+			return ret, err
+		}
+		ret = append(ret, item)
+	}
+	return ret, nil
+}
+
+// Take stops the iteration after limit items.
+func Take[T any](seq iter.Seq2[T, error], limit int) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		if limit <= 0 {
+			return
+		}
+		count := 0
+		for item, err := range seq {
+			if !yield(item, err) || err != nil {
+				return
+			}
+			count++
+			if count == limit {
+				return
+			}
+		}
+	}
+}
+
+// Filter yields only the items the predicate accepts, errors are always yielded.
+func Filter[T any](seq iter.Seq2[T, error], predicate func(T) bool) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for item, err := range seq {
+			if err == nil && !predicate(item) {
+				continue
+			}
+			if !yield(item, err) {
+				return
+			}
+		}
+	}
+}
+
+// Bridges the iterators to the callback based methods.
+func yieldToCallback[T any](seq iter.Seq2[T, error], callback GenericCallback) error {
+	for item, err := range seq {
+		if err != nil {
+			return err
+		}
+		if err = callback(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// The callback returns false to stop, with the error to return if any.
+func yieldToCallbackNG[T any](seq iter.Seq2[T, error], callback GenericCallbackNG) error {
+	for item, err := range seq {
+		if err != nil {
+			return err
+		}
+		if continuePagination, err := callback(item); !continuePagination {
+			return err
+		}
+	}
+	return nil
+}
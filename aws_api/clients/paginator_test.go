@@ -0,0 +1,90 @@
+package aws_api
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// Serves the pages in order and counts the fetched ones.
+func stubPages(pages [][]int, fetched *int, failAfter int) Pages[[]int] {
+	return func(callback func(page []int, lastPage bool) bool) error {
+		for index, page := range pages {
+			if failAfter >= 0 && index == failAfter {
+				return errors.New("throttled")
+			}
+			*fetched++
+			if !callback(page, index == len(pages)-1) {
+				return nil
+			}
+		}
+		return nil
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	pages := [][]int{{1, 2}, {3, 4}, {5}}
+	identity := func(page []int) ([]int, error) { return page, nil }
+
+	t.Run("Valid run", func(t *testing.T) {
+		fetched := 0
+		items, err := Collect(Paginate(context.Background(), stubPages(pages, &fetched, -1), identity))
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if len(items) != 5 || items[4] != 5 || fetched != 3 {
+			t.Errorf("items %v, fetched %d pages", items, fetched)
+		}
+	})
+
+	t.Run("Early stop", func(t *testing.T) {
+		fetched := 0
+		items, err := Collect(Take(Paginate(context.Background(), stubPages(pages, &fetched, -1), identity), 3))
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if len(items) != 3 || fetched != 2 {
+			t.Errorf("items %v, fetched %d pages", items, fetched)
+		}
+	})
+
+	t.Run("Context cancellation", func(t *testing.T) {
+		fetched := 0
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		items := []int{}
+		var err error
+		for item, itemErr := range Paginate(ctx, stubPages(pages, &fetched, -1), identity) {
+			if itemErr != nil {
+				err = itemErr
+				break
+			}
+			items = append(items, item)
+			cancel()
+		}
+		if !errors.Is(err, context.Canceled) || len(items) != 1 || fetched != 1 {
+			t.Errorf("items %v, fetched %d pages, error %v", items, fetched, err)
+		}
+	})
+
+	t.Run("Error propagation", func(t *testing.T) {
+		fetched := 0
+		items, err := Collect(Paginate(context.Background(), stubPages(pages, &fetched, 1), identity))
+		if err == nil || err.Error() != "throttled" || len(items) != 2 {
+			t.Errorf("items %v, error %v", items, err)
+		}
+	})
+
+	t.Run("Filter and callback bridge", func(t *testing.T) {
+		fetched := 0
+		even := Filter(Paginate(context.Background(), stubPages(pages, &fetched, -1), identity), func(item int) bool { return item%2 == 0 })
+		seen := []any{}
+		err := yieldToCallbackNG(even, func(item any) (bool, error) {
+			seen = append(seen, item)
+			return true, nil
+		})
+		if err != nil || len(seen) != 2 || seen[1] != 4 {
+			t.Errorf("seen %v, error %v", seen, err)
+		}
+	})
+}
@@ -1,13 +1,15 @@
 package aws_api
 
 import (
+	"context"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/rds"
+	"iter"
 )
 
 type RDSAPI struct {
-	svc *rds.RDS
+	svc RDSService
 }
 
 func RDSAPINew(region *string, profileName *string) *RDSAPI {
@@ -26,40 +28,30 @@ func RDSAPINew(region *string, profileName *string) *RDSAPI {
 	return &ret
 }
 
+func RDSAPINewWithService(svc RDSService) *RDSAPI {
+	return &RDSAPI{svc: svc}
+}
+
+func (api *RDSAPI) IterClusters(ctx context.Context, Input *rds.DescribeDBClustersInput) iter.Seq2[*rds.DBCluster, error] {
+	return Paginate(ctx, func(callback func(*rds.DescribeDBClustersOutput, bool) bool) error {
+		return api.svc.DescribeDBClustersPages(Input, callback)
+	}, Items(func(page *rds.DescribeDBClustersOutput) []*rds.DBCluster { return page.DBClusters }))
+}
+
+// Deprecated: use IterClusters.
 func (api *RDSAPI) DescribeClusters(callback GenericCallback, Input *rds.DescribeDBClustersInput) error {
-	var callbackErr error
-	pageNum := 0
-	err := api.svc.DescribeDBClustersPages(Input, func(page *rds.DescribeDBClustersOutput, notHasNextPage bool) bool {
-		pageNum++
-		for _, obj := range page.DBClusters {
-			if callbackErr = callback(obj); callbackErr != nil {
-				return false
-			}
-		}
-		return !notHasNextPage
-	})
-	if callbackErr != nil {
-		return callbackErr
-	}
-	return err
+	return yieldToCallback(api.IterClusters(context.Background(), Input), callback)
+}
+
+func (api *RDSAPI) IterInstances(ctx context.Context, Input *rds.DescribeDBInstancesInput) iter.Seq2[*rds.DBInstance, error] {
+	return Paginate(ctx, func(callback func(*rds.DescribeDBInstancesOutput, bool) bool) error {
+		return api.svc.DescribeDBInstancesPages(Input, callback)
+	}, Items(func(page *rds.DescribeDBInstancesOutput) []*rds.DBInstance { return page.DBInstances }))
 }
 
+// Deprecated: use IterInstances.
 func (api *RDSAPI) DescribeInstances(callback GenericCallback, Input *rds.DescribeDBInstancesInput) error {
-	var callbackErr error
-	pageNum := 0
-	err := api.svc.DescribeDBInstancesPages(Input, func(page *rds.DescribeDBInstancesOutput, notHasNextPage bool) bool {
-		pageNum++
-		for _, obj := range page.DBInstances {
-			if callbackErr = callback(obj); callbackErr != nil {
-				return false
-			}
-		}
-		return !notHasNextPage
-	})
-	if callbackErr != nil {
-		return callbackErr
-	}
-	return err
+	return yieldToCallback(api.IterInstances(context.Background(), Input), callback)
 }
 
 func (api *RDSAPI) CreateTags(existingTags []*rds.Tag, AddTags map[string]string, resource *string, declarative bool) (*rds.AddTagsToResourceOutput, error) {
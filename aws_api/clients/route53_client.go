@@ -1,13 +1,16 @@
 package aws_api
 
 import (
+	"context"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/route53"
+	"iter"
+	"strings"
 )
 
 type Route53API struct {
-	svc         *route53.Route53
+	svc         Route53Service
 	profileName *string
 }
 
@@ -29,30 +32,30 @@ func Route53APINew(profileName *string) *Route53API {
 	return &ret
 }
 
-// Up to 100 per page
+func Route53APINewWithService(svc Route53Service) *Route53API {
+	return &Route53API{svc: svc}
+}
+
+func (api *Route53API) IterHostedZones(ctx context.Context, Input *route53.ListHostedZonesInput) iter.Seq2[*route53.HostedZone, error] {
+	return Paginate(ctx, func(callback func(*route53.ListHostedZonesOutput, bool) bool) error {
+		return api.svc.ListHostedZonesPages(Input, callback)
+	}, Items(func(page *route53.ListHostedZonesOutput) []*route53.HostedZone { return page.HostedZones }))
+}
+
+// Deprecated: use IterHostedZones.
 func (api *Route53API) YieldHostedZones(Input *route53.ListHostedZonesInput, callbackFilter GenericCallback) ([]*route53.HostedZone, error) {
-	var callbackErr error
 	ret := []*route53.HostedZone{}
-
-	err := api.svc.ListHostedZonesPages(Input, func(page *route53.ListHostedZonesOutput, notHasNextPage bool) bool {
-		for _, hz := range page.HostedZones {
-			if callbackFilter != nil {
-				if callbackErr = callbackFilter(hz); callbackErr != nil {
-					return false
-				}
+	for hz, err := range api.IterHostedZones(context.Background(), Input) {
+		if err != nil {
+			return nil, err
+		}
+		if callbackFilter != nil {
+			if err = callbackFilter(hz); err != nil {
+				return nil, err
 			}
-			ret = append(ret, hz)
 		}
-		return true
-	})
-
-	if callbackErr != nil {
-		return nil, callbackErr
-	}
-	if err != nil {
-		return nil, err
+		ret = append(ret, hz)
 	}
-
 	return ret, nil
 }
 
@@ -83,17 +86,31 @@ func (api *Route53API) YieldResourceRecordSets(Input *route53.ListResourceRecord
 	return ret, nil
 }
 
-func (api *Route53API) YieldHostedZoneResourceRecordSets(Input *route53.ListResourceRecordSetsInput) ([]*route53.ResourceRecordSet, error) {
-	ret := []*route53.ResourceRecordSet{}
-
-	err := api.svc.ListResourceRecordSetsPages(Input, func(page *route53.ListResourceRecordSetsOutput, notHasNextPage bool) bool {
-		ret = append(ret, page.ResourceRecordSets...)
-		return true
-	})
+func (api *Route53API) IterHostedZoneResourceRecordSets(ctx context.Context, Input *route53.ListResourceRecordSetsInput) iter.Seq2[*route53.ResourceRecordSet, error] {
+	return Paginate(ctx, func(callback func(*route53.ListResourceRecordSetsOutput, bool) bool) error {
+		return api.svc.ListResourceRecordSetsPages(Input, callback)
+	}, Items(func(page *route53.ListResourceRecordSetsOutput) []*route53.ResourceRecordSet {
+		return page.ResourceRecordSets
+	}))
+}
 
+func (api *Route53API) YieldHostedZoneResourceRecordSets(Input *route53.ListResourceRecordSetsInput) ([]*route53.ResourceRecordSet, error) {
+	ret, err := Collect(api.IterHostedZoneResourceRecordSets(context.Background(), Input))
 	if err != nil {
 		return nil, err
 	}
-
 	return ret, nil
 }
+
+// The hosted zone id is accepted with or without the "/hostedzone/" prefix.
+func (api *Route53API) GetTags(hostedZoneId *string) ([]*route53.Tag, error) {
+	resourceId := strings.TrimPrefix(*hostedZoneId, "/hostedzone/")
+	output, err := api.svc.ListTagsForResource(&route53.ListTagsForResourceInput{ResourceId: &resourceId, ResourceType: StrPtr("hostedzone")})
+	if err != nil {
+		return nil, err
+	}
+	if output.ResourceTagSet == nil {
+		return []*route53.Tag{}, nil
+	}
+	return output.ResourceTagSet.Tags, nil
+}
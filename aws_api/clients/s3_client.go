@@ -1,6 +1,8 @@
 package aws_api
 
 import (
+	"context"
+	"iter"
 	"strings"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -9,9 +11,10 @@ import (
 )
 
 type S3API struct {
-	svc         *s3.S3
-	region      *string
-	profileName *string
+	svc            S3Service
+	region         *string
+	profileName    *string
+	regionalAPINew func(region *string) *S3API
 }
 
 func S3APINew(region *string, profileName *string) *S3API {
@@ -29,24 +32,31 @@ func S3APINew(region *string, profileName *string) *S3API {
 	lg.InfoF("AWS profile: %s\n", *profileName)
 	svc := s3.New(sess)
 	ret := S3API{svc: svc, region: region, profileName: profileName}
+	ret.regionalAPINew = func(region *string) *S3API { return S3APINew(region, profileName) }
 	return &ret
 }
 
-func (api *S3API) ListBuckets(callback GenericCallback, Input *s3.ListBucketsInput) error {
-	var callbackErr error
-
-	output, err := api.svc.ListBuckets(Input)
-	if err != nil {
-		return err
-	}
+// The service answers for every region, so buckets from other regions are tagged through it as well.
+func S3APINewWithService(svc S3Service, region *string) *S3API {
+	ret := &S3API{svc: svc, region: region}
+	ret.regionalAPINew = func(*string) *S3API { return ret }
+	return ret
+}
 
-	for _, obj := range output.Buckets {
-		if callbackErr = callback(obj); callbackErr != nil {
-			return callbackErr
+// Not paginated by the service.
+func (api *S3API) IterBuckets(ctx context.Context, Input *s3.ListBucketsInput) iter.Seq2[*s3.Bucket, error] {
+	return Single(ctx, func() ([]*s3.Bucket, error) {
+		output, err := api.svc.ListBuckets(Input)
+		if err != nil {
+			return nil, err
 		}
-	}
+		return output.Buckets, nil
+	})
+}
 
-	return nil
+// Deprecated: use IterBuckets.
+func (api *S3API) ListBuckets(callback GenericCallback, Input *s3.ListBucketsInput) error {
+	return yieldToCallback(api.IterBuckets(context.Background(), Input), callback)
 }
 
 func (api *S3API) AddTags(AddTags map[string]string, bucket *s3.Bucket, declarative bool) (*s3.PutBucketTaggingOutput, error) {
@@ -56,7 +66,7 @@ func (api *S3API) AddTags(AddTags map[string]string, bucket *s3.Bucket, declarat
 	}
 
 	if *api.region != *bucket_region {
-		api = S3APINew(bucket_region, api.profileName)
+		api = api.regionalAPINew(bucket_region)
 	}
 
 	existingTags, err := api.GetTags(bucket.Name)
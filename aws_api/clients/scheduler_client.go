@@ -0,0 +1,52 @@
+package aws_api
+
+import (
+	"context"
+	"iter"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/scheduler"
+)
+
+// SchedulerAPI wraps the EventBridge Scheduler, the schedules are not EventBridge rules.
+type SchedulerAPI struct {
+	svc SchedulerService
+}
+
+func SchedulerAPINew(region, profileName *string) *SchedulerAPI {
+	if profileName == nil {
+		profileNameString := "default"
+		profileName = &profileNameString
+	}
+
+	sess := session.Must(session.NewSessionWithOptions(session.Options{
+		SharedConfigState: session.SharedConfigEnable,
+		Config:            aws.Config{Region: region},
+		Profile:           *profileName,
+	}))
+
+	lg.InfoF("AWS profile: %s\n", *profileName)
+	return &SchedulerAPI{svc: scheduler.New(sess)}
+}
+
+func SchedulerAPINewWithService(svc SchedulerService) *SchedulerAPI {
+	return &SchedulerAPI{svc: svc}
+}
+
+// Describes every listed schedule: the summaries have no target parameters.
+func (api *SchedulerAPI) IterSchedules(ctx context.Context, Input *scheduler.ListSchedulesInput) iter.Seq2[*scheduler.GetScheduleOutput, error] {
+	return Paginate(ctx, func(callback func(*scheduler.ListSchedulesOutput, bool) bool) error {
+		return api.svc.ListSchedulesPages(Input, callback)
+	}, func(page *scheduler.ListSchedulesOutput) ([]*scheduler.GetScheduleOutput, error) {
+		ret := []*scheduler.GetScheduleOutput{}
+		for _, summary := range page.Schedules {
+			response, err := api.svc.GetSchedule(&scheduler.GetScheduleInput{GroupName: summary.GroupName, Name: summary.Name})
+			if err != nil {
+				return nil, err
+			}
+			ret = append(ret, response)
+		}
+		return ret, nil
+	})
+}
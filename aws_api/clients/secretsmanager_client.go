@@ -1,13 +1,15 @@
 package aws_api
 
 import (
+	"context"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"iter"
 )
 
 type SecretsmanagerAPI struct {
-	svc         *secretsmanager.SecretsManager
+	svc         SecretsmanagerService
 	profileName *string
 }
 
@@ -29,30 +31,30 @@ func SecretsmanagerAPINew(region, profileName *string) *SecretsmanagerAPI {
 	return &ret
 }
 
-// Up to 100 per page
+func SecretsmanagerAPINewWithService(svc SecretsmanagerService) *SecretsmanagerAPI {
+	return &SecretsmanagerAPI{svc: svc}
+}
+
+func (api *SecretsmanagerAPI) IterSecrets(ctx context.Context, Input *secretsmanager.ListSecretsInput) iter.Seq2[*secretsmanager.SecretListEntry, error] {
+	return Paginate(ctx, func(callback func(*secretsmanager.ListSecretsOutput, bool) bool) error {
+		return api.svc.ListSecretsPages(Input, callback)
+	}, Items(func(page *secretsmanager.ListSecretsOutput) []*secretsmanager.SecretListEntry { return page.SecretList }))
+}
+
+// Deprecated: use IterSecrets.
 func (api *SecretsmanagerAPI) YieldSecrets(Input *secretsmanager.ListSecretsInput, callbackFilter GenericCallback) ([]*secretsmanager.SecretListEntry, error) {
-	var callbackErr error
 	ret := []*secretsmanager.SecretListEntry{}
-
-	err := api.svc.ListSecretsPages(Input, func(page *secretsmanager.ListSecretsOutput, notHasNextPage bool) bool {
-		for _, secret := range page.SecretList {
-			if callbackFilter != nil {
-				if callbackErr = callbackFilter(secret); callbackErr != nil {
-					return false
-				}
+	for secret, err := range api.IterSecrets(context.Background(), Input) {
+		if err != nil {
+			return nil, err
+		}
+		if callbackFilter != nil {
+			if err = callbackFilter(secret); err != nil {
+				return nil, err
 			}
-			ret = append(ret, secret)
 		}
-		return true
-	})
-
-	if callbackErr != nil {
-		return nil, callbackErr
+		ret = append(ret, secret)
 	}
-	if err != nil {
-		return nil, err
-	}
-
 	return ret, nil
 }
 
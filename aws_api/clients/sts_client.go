@@ -6,7 +6,7 @@ import (
 )
 
 type STSAPI struct {
-	svc         *sts.STS
+	svc         STSService
 	ProfileName *string
 }
 
@@ -25,6 +25,10 @@ func STSAPINew(profileName *string) *STSAPI {
 	return &ret
 }
 
+func STSAPINewWithService(svc STSService) *STSAPI {
+	return &STSAPI{svc: svc}
+}
+
 func (api *STSAPI) GetAccount() (*string, error) {
 	Input := sts.GetCallerIdentityInput{}
 	output, err := api.svc.GetCallerIdentity(&Input)
@@ -0,0 +1,238 @@
+package aws_api
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/elasticache"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+)
+
+// Uniform tag writers: SetTags writes exactly the given tags, RemoveTags deletes the given keys.
+// Unlike CreateTags/ProvisionTags they do not compare with the current tags, the caller already did.
+
+func tagList[T any](tags map[string]string, tag func(key, value *string) T) []T {
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	ret := make([]T, 0, len(keys))
+	for _, key := range keys {
+		ret = append(ret, tag(StrPtr(key), StrPtr(tags[key])))
+	}
+	return ret
+}
+
+func strPtrs(values []string) []*string {
+	ret := make([]*string, 0, len(values))
+	for _, value := range values {
+		ret = append(ret, StrPtr(value))
+	}
+	return ret
+}
+
+func (api *EC2API) SetTags(resource *string, tags map[string]string) error {
+	lg.InfoF("Setting tags: resource: %s, tags: %v", *resource, tags)
+	_, err := api.svc.CreateTags(&ec2.CreateTagsInput{Resources: []*string{resource},
+		Tags: tagList(tags, func(key, value *string) *ec2.Tag { return &ec2.Tag{Key: key, Value: value} })})
+	return err
+}
+
+func (api *EC2API) RemoveTags(resource *string, keys []string) error {
+	lg.InfoF("Removing tags: resource: %s, keys: %v", *resource, keys)
+	tags := []*ec2.Tag{}
+	for _, key := range strPtrs(keys) {
+		tags = append(tags, &ec2.Tag{Key: key})
+	}
+	_, err := api.svc.DeleteTags(&ec2.DeleteTagsInput{Resources: []*string{resource}, Tags: tags})
+	return err
+}
+
+func (api *ELBV2API) SetTags(resource *string, tags map[string]string) error {
+	lg.InfoF("Setting tags: resource: %s, tags: %v", *resource, tags)
+	_, err := api.svc.AddTags(&elbv2.AddTagsInput{ResourceArns: []*string{resource},
+		Tags: tagList(tags, func(key, value *string) *elbv2.Tag { return &elbv2.Tag{Key: key, Value: value} })})
+	return err
+}
+
+func (api *ELBV2API) RemoveTags(resource *string, keys []string) error {
+	lg.InfoF("Removing tags: resource: %s, keys: %v", *resource, keys)
+	_, err := api.svc.RemoveTags(&elbv2.RemoveTagsInput{ResourceArns: []*string{resource}, TagKeys: strPtrs(keys)})
+	return err
+}
+
+// The resource is the auto scaling group name, new tags propagate to the launched instances.
+func (api *AutoscalingAPI) SetTags(resource *string, tags map[string]string) error {
+	lg.InfoF("Setting tags: resource: %s, tags: %v", *resource, tags)
+	_, err := api.svc.CreateOrUpdateTags(&autoscaling.CreateOrUpdateTagsInput{
+		Tags: tagList(tags, func(key, value *string) *autoscaling.Tag {
+			return &autoscaling.Tag{Key: key, Value: value, ResourceId: resource, ResourceType: StrPtr("auto-scaling-group"), PropagateAtLaunch: BoolPtr(true)}
+		})})
+	return err
+}
+
+func (api *AutoscalingAPI) RemoveTags(resource *string, keys []string) error {
+	lg.InfoF("Removing tags: resource: %s, keys: %v", *resource, keys)
+	tags := []*autoscaling.Tag{}
+	for _, key := range strPtrs(keys) {
+		tags = append(tags, &autoscaling.Tag{Key: key, ResourceId: resource, ResourceType: StrPtr("auto-scaling-group")})
+	}
+	_, err := api.svc.DeleteTags(&autoscaling.DeleteTagsInput{Tags: tags})
+	return err
+}
+
+func (api *RDSAPI) SetTags(resource *string, tags map[string]string) error {
+	lg.InfoF("Setting tags: resource: %s, tags: %v", *resource, tags)
+	_, err := api.svc.AddTagsToResource(&rds.AddTagsToResourceInput{ResourceName: resource,
+		Tags: tagList(tags, func(key, value *string) *rds.Tag { return &rds.Tag{Key: key, Value: value} })})
+	return err
+}
+
+func (api *RDSAPI) RemoveTags(resource *string, keys []string) error {
+	lg.InfoF("Removing tags: resource: %s, keys: %v", *resource, keys)
+	_, err := api.svc.RemoveTagsFromResource(&rds.RemoveTagsFromResourceInput{ResourceName: resource, TagKeys: strPtrs(keys)})
+	return err
+}
+
+// PutBucketTagging replaces the whole tag set: the current tags are merged in.
+func (api *S3API) currentTags(bucket *string) (map[string]string, error) {
+	ret := map[string]string{}
+	tags, err := api.GetTags(bucket)
+	if err != nil {
+		if strings.Contains(err.Error(), "NoSuchTagSet") {
+			return ret, nil
+		}
+		return nil, err
+	}
+	for _, tag := range tags {
+		ret[*tag.Key] = *tag.Value
+	}
+	return ret, nil
+}
+
+func (api *S3API) putTags(bucket *string, tags map[string]string) error {
+	if len(tags) == 0 {
+		_, err := api.svc.DeleteBucketTagging(&s3.DeleteBucketTaggingInput{Bucket: bucket})
+		return err
+	}
+	_, err := api.svc.PutBucketTagging(&s3.PutBucketTaggingInput{Bucket: bucket,
+		Tagging: &s3.Tagging{TagSet: tagList(tags, func(key, value *string) *s3.Tag { return &s3.Tag{Key: key, Value: value} })}})
+	return err
+}
+
+// The api must be of the bucket region.
+func (api *S3API) SetTags(bucket *string, tags map[string]string) error {
+	current, err := api.currentTags(bucket)
+	if err != nil {
+		return err
+	}
+	lg.InfoF("Setting tags: resource: %s, tags: %v, current tags: %v", *bucket, tags, current)
+	for key, value := range tags {
+		current[key] = value
+	}
+	return api.putTags(bucket, current)
+}
+
+func (api *S3API) RemoveTags(bucket *string, keys []string) error {
+	current, err := api.currentTags(bucket)
+	if err != nil {
+		return err
+	}
+	lg.InfoF("Removing tags: resource: %s, keys: %v, current tags: %v", *bucket, keys, current)
+	for _, key := range keys {
+		delete(current, key)
+	}
+	return api.putTags(bucket, current)
+}
+
+func (api *CloudwatchLogsAPI) SetTags(resource *string, tags map[string]string) error {
+	lg.InfoF("Setting tags: resource: %s, tags: %v", *resource, tags)
+	tagsRequest := map[string]*string{}
+	for key, value := range tags {
+		tagsRequest[key] = StrPtr(value)
+	}
+	_, err := api.svc.TagResource(&cloudwatchlogs.TagResourceInput{ResourceArn: resource, Tags: tagsRequest})
+	return err
+}
+
+func (api *CloudwatchLogsAPI) RemoveTags(resource *string, keys []string) error {
+	lg.InfoF("Removing tags: resource: %s, keys: %v", *resource, keys)
+	_, err := api.svc.UntagResource(&cloudwatchlogs.UntagResourceInput{ResourceArn: resource, TagKeys: strPtrs(keys)})
+	return err
+}
+
+func (api *ECSAPI) SetTags(resource *string, tags map[string]string) error {
+	lg.InfoF("Setting tags: resource: %s, tags: %v", *resource, tags)
+	_, err := api.svc.TagResource(&ecs.TagResourceInput{ResourceArn: resource,
+		Tags: tagList(tags, func(key, value *string) *ecs.Tag { return &ecs.Tag{Key: key, Value: value} })})
+	return err
+}
+
+func (api *ECSAPI) RemoveTags(resource *string, keys []string) error {
+	lg.InfoF("Removing tags: resource: %s, keys: %v", *resource, keys)
+	_, err := api.svc.UntagResource(&ecs.UntagResourceInput{ResourceArn: resource, TagKeys: strPtrs(keys)})
+	return err
+}
+
+func (api *SecretsmanagerAPI) SetTags(resource *string, tags map[string]string) error {
+	lg.InfoF("Setting tags: resource: %s, tags: %v", *resource, tags)
+	_, err := api.svc.TagResource(&secretsmanager.TagResourceInput{SecretId: resource,
+		Tags: tagList(tags, func(key, value *string) *secretsmanager.Tag { return &secretsmanager.Tag{Key: key, Value: value} })})
+	return err
+}
+
+func (api *SecretsmanagerAPI) RemoveTags(resource *string, keys []string) error {
+	lg.InfoF("Removing tags: resource: %s, keys: %v", *resource, keys)
+	_, err := api.svc.UntagResource(&secretsmanager.UntagResourceInput{SecretId: resource, TagKeys: strPtrs(keys)})
+	return err
+}
+
+func (api *CloudwatchAPI) SetTags(resource *string, tags map[string]string) error {
+	lg.InfoF("Setting tags: resource: %s, tags: %v", *resource, tags)
+	_, err := api.svc.TagResource(&cloudwatch.TagResourceInput{ResourceARN: resource,
+		Tags: tagList(tags, func(key, value *string) *cloudwatch.Tag { return &cloudwatch.Tag{Key: key, Value: value} })})
+	return err
+}
+
+func (api *CloudwatchAPI) RemoveTags(resource *string, keys []string) error {
+	lg.InfoF("Removing tags: resource: %s, keys: %v", *resource, keys)
+	_, err := api.svc.UntagResource(&cloudwatch.UntagResourceInput{ResourceARN: resource, TagKeys: strPtrs(keys)})
+	return err
+}
+
+func (api *DynamoDBAPI) SetTags(resource *string, tags map[string]string) error {
+	lg.InfoF("Setting tags: resource: %s, tags: %v", *resource, tags)
+	_, err := api.svc.TagResource(&dynamodb.TagResourceInput{ResourceArn: resource,
+		Tags: tagList(tags, func(key, value *string) *dynamodb.Tag { return &dynamodb.Tag{Key: key, Value: value} })})
+	return err
+}
+
+func (api *DynamoDBAPI) RemoveTags(resource *string, keys []string) error {
+	lg.InfoF("Removing tags: resource: %s, keys: %v", *resource, keys)
+	_, err := api.svc.UntagResource(&dynamodb.UntagResourceInput{ResourceArn: resource, TagKeys: strPtrs(keys)})
+	return err
+}
+
+func (api *ElasticacheAPI) SetTags(resource *string, tags map[string]string) error {
+	lg.InfoF("Setting tags: resource: %s, tags: %v", *resource, tags)
+	_, err := api.svc.AddTagsToResource(&elasticache.AddTagsToResourceInput{ResourceName: resource,
+		Tags: tagList(tags, func(key, value *string) *elasticache.Tag { return &elasticache.Tag{Key: key, Value: value} })})
+	return err
+}
+
+func (api *ElasticacheAPI) RemoveTags(resource *string, keys []string) error {
+	lg.InfoF("Removing tags: resource: %s, keys: %v", *resource, keys)
+	_, err := api.svc.RemoveTagsFromResource(&elasticache.RemoveTagsFromResourceInput{ResourceName: resource, TagKeys: strPtrs(keys)})
+	return err
+}
@@ -0,0 +1,429 @@
+package aws_api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	plotterapi "github.com/AlexeyBeley/go_misc/plotter_api"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"gonum.org/v1/plot/plotter"
+)
+
+const (
+	TrafficFormatJSON  = "json"
+	TrafficFormatTable = "table"
+
+	ScopeInterface = "interface"
+	ScopeSubnet    = "subnet"
+)
+
+var protocolNames = map[string]string{"1": "icmp", "6": "tcp", "17": "udp", "58": "icmpv6"}
+
+func protocolName(protocol string) string {
+	if name, found := protocolNames[protocol]; found {
+		return name
+	}
+	return protocol
+}
+
+// Conversation groups the flows of a source talking to a destination port.
+// The names are the resources of the enriched events.
+type Conversation struct {
+	SrcAddr  string
+	DstAddr  string
+	DstPort  int
+	Protocol string
+	SrcName  string `json:",omitempty"`
+	DstName  string `json:",omitempty"`
+}
+
+func (conversation Conversation) String() string {
+	src, dst := conversation.SrcAddr, conversation.DstAddr
+	if conversation.SrcName != "" {
+		src = conversation.SrcName
+	}
+	if conversation.DstName != "" {
+		dst = conversation.DstName
+	}
+	return fmt.Sprintf("%s -> %s:%d/%s", src, dst, conversation.DstPort, protocolName(conversation.Protocol))
+}
+
+type Port struct {
+	Port     int
+	Protocol string
+}
+
+func (port Port) String() string {
+	return fmt.Sprintf("%d/%s", port.Port, protocolName(port.Protocol))
+}
+
+type TrafficCounters struct {
+	Flows    int64
+	Packets  int64
+	Bytes    int64
+	Rejected int64
+}
+
+func (counters *TrafficCounters) add(event *FlowLogEvent) {
+	counters.Flows++
+	counters.Packets += int64(event.Packets)
+	counters.Bytes += int64(event.Bytes)
+	if event.Action == "REJECT" {
+		counters.Rejected++
+	}
+}
+
+type ConversationCount struct {
+	Conversation
+	TrafficCounters
+}
+
+type PortCount struct {
+	Port
+	TrafficCounters
+}
+
+type BucketCount struct {
+	Start time.Time
+	TrafficCounters
+}
+
+// ScopeReport is the traffic of an interface or a subnet.
+type ScopeReport struct {
+	Kind        string
+	ID          string
+	Totals      TrafficCounters
+	TopTalkers  []*ConversationCount
+	TopPorts    []*PortCount
+	TopRejected []*ConversationCount
+	Series      []*BucketCount
+}
+
+type TrafficReport struct {
+	BucketSeconds int64
+	Scopes        []*ScopeReport
+}
+
+type scopeKey struct {
+	kind string
+	id   string
+}
+
+type scopeTraffic struct {
+	totals        TrafficCounters
+	conversations map[Conversation]*TrafficCounters
+	ports         map[Port]*TrafficCounters
+	buckets       map[int64]*TrafficCounters
+}
+
+// FlowLogAggregator counts the flows per interface and subnet, by conversation, destination port and time bucket.
+// Process is an EventProcessor, it is safe to call from the recording goroutines.
+type FlowLogAggregator struct {
+	Bucket time.Duration
+	// Subnet of the interface for the formats without ${subnet-id}.
+	InterfaceSubnets map[string]string
+
+	lock   sync.Mutex
+	scopes map[scopeKey]*scopeTraffic
+}
+
+// Generator
+func FlowLogAggregatorNew(bucket time.Duration, interfaceSubnets map[string]string) (*FlowLogAggregator, error) {
+	if bucket < time.Second {
+		return nil, fmt.Errorf("[flow_log_aggregator:FlowLogAggregatorNew] bucket must be at least a second: %s", bucket)
+	}
+	if interfaceSubnets == nil {
+		interfaceSubnets = map[string]string{}
+	}
+	return &FlowLogAggregator{Bucket: bucket, InterfaceSubnets: interfaceSubnets, scopes: map[scopeKey]*scopeTraffic{}}, nil
+}
+
+func (aggregator *FlowLogAggregator) scope(kind, id string) *scopeTraffic {
+	key := scopeKey{kind: kind, id: id}
+	traffic, found := aggregator.scopes[key]
+	if !found {
+		traffic = &scopeTraffic{conversations: map[Conversation]*TrafficCounters{}, ports: map[Port]*TrafficCounters{}, buckets: map[int64]*TrafficCounters{}}
+		aggregator.scopes[key] = traffic
+	}
+	return traffic
+}
+
+func counter[K comparable](counters map[K]*TrafficCounters, key K) *TrafficCounters {
+	ret, found := counters[key]
+	if !found {
+		ret = &TrafficCounters{}
+		counters[key] = ret
+	}
+	return ret
+}
+
+// Process counts the event, records without traffic (NODATA, SKIPDATA) are ignored.
+func (aggregator *FlowLogAggregator) Process(event *FlowLogEvent) error {
+	if event.SrcAddr == nil || event.DstAddr == nil {
+		return nil
+	}
+	conversation := Conversation{SrcAddr: event.SrcAddr.String(), DstAddr: event.DstAddr.String(), DstPort: event.DstPort, Protocol: event.Protocol}
+	if event.SrcResource != nil {
+		conversation.SrcName = event.SrcResource.String()
+	}
+	if event.DstResource != nil {
+		conversation.DstName = event.DstResource.String()
+	}
+	port := Port{Port: event.DstPort, Protocol: event.Protocol}
+	bucketSeconds := int64(aggregator.Bucket / time.Second)
+	bucket := int64(event.Start) / bucketSeconds * bucketSeconds
+
+	aggregator.lock.Lock()
+	defer aggregator.lock.Unlock()
+
+	subnetID := event.SubnetID
+	if subnetID == "" {
+		subnetID = aggregator.InterfaceSubnets[event.InterfaceID]
+	}
+
+	scopes := []*scopeTraffic{}
+	if event.InterfaceID != "" {
+		scopes = append(scopes, aggregator.scope(ScopeInterface, event.InterfaceID))
+	}
+	if subnetID != "" {
+		scopes = append(scopes, aggregator.scope(ScopeSubnet, subnetID))
+	}
+	for _, traffic := range scopes {
+		traffic.totals.add(event)
+		counter(traffic.conversations, conversation).add(event)
+		counter(traffic.ports, port).add(event)
+		counter(traffic.buckets, bucket).add(event)
+	}
+	return nil
+}
+
+// AddNetworkInterface maps an interface found while recording to its subnet.
+func (aggregator *FlowLogAggregator) AddNetworkInterface(networkInterface *ec2.NetworkInterface) {
+	if aggregator == nil || networkInterface.NetworkInterfaceId == nil || networkInterface.SubnetId == nil {
+		return
+	}
+	aggregator.lock.Lock()
+	defer aggregator.lock.Unlock()
+	aggregator.InterfaceSubnets[*networkInterface.NetworkInterfaceId] = aws.StringValue(networkInterface.SubnetId)
+}
+
+type reportKey interface {
+	comparable
+	String() string
+}
+
+// topN sorts by the value descending and the key string for stable reports.
+func topN[K reportKey, R any](counters map[K]*TrafficCounters, limit int, value func(*TrafficCounters) int64, build func(K, TrafficCounters) R) []R {
+	keys := []K{}
+	for key, counters := range counters {
+		if value(counters) > 0 {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		left, right := value(counters[keys[i]]), value(counters[keys[j]])
+		if left != right {
+			return left > right
+		}
+		return keys[i].String() < keys[j].String()
+	})
+
+	ret := []R{}
+	for _, key := range keys[:min(limit, len(keys))] {
+		ret = append(ret, build(key, *counters[key]))
+	}
+	return ret
+}
+
+// Report returns the top N talkers, ports and rejected conversations of every interface and subnet.
+func (aggregator *FlowLogAggregator) Report(top int) *TrafficReport {
+	aggregator.lock.Lock()
+	defer aggregator.lock.Unlock()
+
+	bytes := func(counters *TrafficCounters) int64 { return counters.Bytes }
+	rejected := func(counters *TrafficCounters) int64 { return counters.Rejected }
+	conversationCount := func(conversation Conversation, counters TrafficCounters) *ConversationCount {
+		return &ConversationCount{Conversation: conversation, TrafficCounters: counters}
+	}
+
+	report := &TrafficReport{BucketSeconds: int64(aggregator.Bucket / time.Second)}
+	for key, traffic := range aggregator.scopes {
+		scope := &ScopeReport{Kind: key.kind, ID: key.id, Totals: traffic.totals,
+			TopTalkers:  topN(traffic.conversations, top, bytes, conversationCount),
+			TopRejected: topN(traffic.conversations, top, rejected, conversationCount),
+			TopPorts: topN(traffic.ports, top, bytes, func(port Port, counters TrafficCounters) *PortCount {
+				return &PortCount{Port: port, TrafficCounters: counters}
+			})}
+
+		starts := []int64{}
+		for start := range traffic.buckets {
+			starts = append(starts, start)
+		}
+		sort.Slice(starts, func(i, j int) bool { return starts[i] < starts[j] })
+		for _, start := range starts {
+			scope.Series = append(scope.Series, &BucketCount{Start: time.Unix(start, 0).UTC(), TrafficCounters: *traffic.buckets[start]})
+		}
+		report.Scopes = append(report.Scopes, scope)
+	}
+
+	sort.Slice(report.Scopes, func(i, j int) bool {
+		if report.Scopes[i].Kind != report.Scopes[j].Kind {
+			return report.Scopes[i].Kind < report.Scopes[j].Kind
+		}
+		return report.Scopes[i].ID < report.Scopes[j].ID
+	})
+	return report
+}
+
+// Write renders the report in one of the TrafficFormat* formats.
+func (report *TrafficReport) Write(writer io.Writer, format string) error {
+	switch format {
+	case TrafficFormatJSON:
+		encoder := json.NewEncoder(writer)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	case TrafficFormatTable:
+		return report.writeTable(writer)
+	}
+	return fmt.Errorf("[flow_log_aggregator:Write] unknown format: %s", format)
+}
+
+func (report *TrafficReport) writeTable(writer io.Writer) error {
+	tableWriter := tabwriter.NewWriter(writer, 0, 0, 2, ' ', 0)
+	for index, scope := range report.Scopes {
+		if index > 0 {
+			fmt.Fprintln(tableWriter)
+		}
+		fmt.Fprintf(tableWriter, "%s %s: %d flows, %d packets, %d bytes, %d rejected\n", scope.Kind, scope.ID,
+			scope.Totals.Flows, scope.Totals.Packets, scope.Totals.Bytes, scope.Totals.Rejected)
+
+		sections := []struct {
+			title string
+			rows  []string
+		}{{"Top talkers", conversationRows(scope.TopTalkers)}, {"Top ports", portRows(scope.TopPorts)}, {"Top rejected", conversationRows(scope.TopRejected)}}
+		for _, section := range sections {
+			if len(section.rows) == 0 {
+				continue
+			}
+			fmt.Fprintf(tableWriter, "  %s\tFLOWS\tPACKETS\tBYTES\tREJECTED\n", strings.ToUpper(section.title))
+			for _, row := range section.rows {
+				fmt.Fprintln(tableWriter, row)
+			}
+		}
+	}
+	return tableWriter.Flush()
+}
+
+func countersRow(name string, counters TrafficCounters) string {
+	return fmt.Sprintf("  %s\t%d\t%d\t%d\t%d", name, counters.Flows, counters.Packets, counters.Bytes, counters.Rejected)
+}
+
+func conversationRows(conversations []*ConversationCount) []string {
+	ret := []string{}
+	for _, conversation := range conversations {
+		ret = append(ret, countersRow(conversation.Conversation.String(), conversation.TrafficCounters))
+	}
+	return ret
+}
+
+func portRows(ports []*PortCount) []string {
+	ret := []string{}
+	for _, port := range ports {
+		ret = append(ret, countersRow(port.Port.String(), port.TrafficCounters))
+	}
+	return ret
+}
+
+// Plot charts the bytes per bucket, a line per interface or subnet of the kind.
+func (report *TrafficReport) Plot(filePath, kind string) error {
+	series := map[string]plotter.XYs{}
+	for _, scope := range report.Scopes {
+		if scope.Kind != kind {
+			continue
+		}
+		points := plotter.XYs{}
+		for _, bucket := range scope.Series {
+			points = append(points, plotter.XY{X: float64(bucket.Start.Unix()), Y: float64(bucket.Bytes)})
+		}
+		series[scope.ID] = points
+	}
+	return plotterapi.PlotTimeSeries(filePath, fmt.Sprintf("Bytes per %s by %s", time.Duration(report.BucketSeconds)*time.Second, kind), "Bytes", series)
+}
+
+// TrafficAggregatorNew builds an aggregator by the config, the known interfaces are mapped to their subnets.
+func (awsTCPDump *AWSTCPDump) TrafficAggregatorNew() (*FlowLogAggregator, error) {
+	bucket := time.Minute
+	if awsTCPDump.Config.BucketSeconds > 0 {
+		bucket = time.Duration(awsTCPDump.Config.BucketSeconds) * time.Second
+	}
+	interfaceSubnets := map[string]string{}
+	for _, networkInterface := range awsTCPDump.KnownIntefaces {
+		if networkInterface.NetworkInterfaceId != nil && networkInterface.SubnetId != nil {
+			interfaceSubnets[*networkInterface.NetworkInterfaceId] = *networkInterface.SubnetId
+		}
+	}
+	return FlowLogAggregatorNew(bucket, interfaceSubnets)
+}
+
+// StartAggregation wraps the EventProcessor set by the command, the recorded events are counted
+// after it processed them. The report is written by WriteTrafficReport once the recording stops.
+func (awsTCPDump *AWSTCPDump) StartAggregation() (*FlowLogAggregator, error) {
+	aggregator, err := awsTCPDump.TrafficAggregatorNew()
+	if err != nil {
+		return nil, err
+	}
+	awsTCPDump.Aggregator = aggregator
+	processor := awsTCPDump.EventProcessor
+	awsTCPDump.EventProcessor = func(event *FlowLogEvent) error {
+		if processor != nil {
+			if err := processor(event); err != nil {
+				return err
+			}
+		}
+		return aggregator.Process(event)
+	}
+	return aggregator, nil
+}
+
+// WriteTrafficReport prints the report table and writes the JSON report and the chart when configured.
+// The chart has a line per subnet, per interface when the subnets are unknown.
+func (awsTCPDump *AWSTCPDump) WriteTrafficReport(aggregator *FlowLogAggregator, writer io.Writer) error {
+	errorPrefix := "[flow_log_aggregator:WriteTrafficReport]"
+	top := awsTCPDump.Config.TopN
+	if top <= 0 {
+		top = 10
+	}
+	report := aggregator.Report(top)
+	if err := report.Write(writer, TrafficFormatTable); err != nil {
+		return err
+	}
+
+	if awsTCPDump.Config.TrafficReportFilePath != "" {
+		file, err := os.Create(awsTCPDump.Config.TrafficReportFilePath)
+		if err != nil {
+			return fmt.Errorf("%s failed to create %s\n%w", errorPrefix, awsTCPDump.Config.TrafficReportFilePath, err)
+		}
+		defer file.Close()
+		if err := report.Write(file, TrafficFormatJSON); err != nil {
+			return err
+		}
+	}
+	if awsTCPDump.Config.TrafficChartFilePath != "" {
+		kind := ScopeInterface
+		for _, scope := range report.Scopes {
+			if scope.Kind == ScopeSubnet {
+				kind = ScopeSubnet
+			}
+		}
+		return report.Plot(awsTCPDump.Config.TrafficChartFilePath, kind)
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package aws_api
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+func aggregatorEvents(t *testing.T) []*FlowLogEvent {
+	format, err := FlowLogFormatNew(DefaultFlowLogFormat)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	events := []*FlowLogEvent{}
+	for _, message := range []string{
+		"2 123456789012 eni-1 10.0.0.5 203.0.113.7 51000 443 6 10 8000 1735776000 1735776060 ACCEPT OK",
+		"2 123456789012 eni-1 10.0.0.5 203.0.113.7 51002 443 6 5 2000 1735776070 1735776120 ACCEPT OK",
+		"2 123456789012 eni-1 198.51.100.9 10.0.0.5 40000 22 6 1 40 1735776000 1735776060 REJECT OK",
+		"2 123456789012 eni-1 198.51.100.9 10.0.0.5 40001 22 6 1 40 1735776010 1735776060 REJECT OK",
+		"2 123456789012 eni-1 10.0.0.5 10.0.1.9 53000 53 17 2 150 1735776130 1735776180 ACCEPT OK",
+		"2 123456789012 eni-2 203.0.113.8 198.51.100.10 1234 80 6 4 900 1735776000 1735776060 ACCEPT OK",
+		"2 123456789012 eni-3 - - - - - - - 1735776000 1735776060 - NODATA",
+	} {
+		event, err := format.Parse(message)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+func TestFlowLogAggregator(t *testing.T) {
+	t.Run("Valid run", func(t *testing.T) {
+		aggregator, err := FlowLogAggregatorNew(time.Minute, map[string]string{"eni-1": "subnet-1", "eni-2": "subnet-1"})
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		for _, event := range aggregatorEvents(t) {
+			if err := aggregator.Process(event); err != nil {
+				t.Fatalf("%v", err)
+			}
+		}
+
+		report := aggregator.Report(2)
+		if len(report.Scopes) != 3 || report.Scopes[0].ID != "eni-1" || report.Scopes[2].Kind != ScopeSubnet {
+			t.Fatalf("unexpected scopes: %+v", report.Scopes)
+		}
+		eni := report.Scopes[0]
+		if eni.Totals != (TrafficCounters{Flows: 5, Packets: 19, Bytes: 10230, Rejected: 2}) {
+			t.Errorf("unexpected totals: %+v", eni.Totals)
+		}
+		if len(eni.TopTalkers) != 2 || eni.TopTalkers[0].String() != "10.0.0.5 -> 203.0.113.7:443/tcp" || eni.TopTalkers[0].Bytes != 10000 ||
+			eni.TopTalkers[1].String() != "10.0.0.5 -> 10.0.1.9:53/udp" {
+			t.Errorf("unexpected talkers: %v", eni.TopTalkers)
+		}
+		if len(eni.TopRejected) != 1 || eni.TopRejected[0].String() != "198.51.100.9 -> 10.0.0.5:22/tcp" || eni.TopRejected[0].Rejected != 2 {
+			t.Errorf("unexpected rejected: %v", eni.TopRejected)
+		}
+		if len(eni.TopPorts) != 2 || eni.TopPorts[0].String() != "443/tcp" || eni.TopPorts[1].String() != "53/udp" {
+			t.Errorf("unexpected ports: %v", eni.TopPorts)
+		}
+		if len(eni.Series) != 3 || eni.Series[0].Start != time.Unix(1735776000, 0).UTC() || eni.Series[0].Bytes != 8080 || eni.Series[2].Bytes != 150 {
+			t.Errorf("unexpected series: %v", eni.Series)
+		}
+		if subnet := report.Scopes[2]; subnet.ID != "subnet-1" || subnet.Totals.Bytes != 11130 {
+			t.Errorf("public to public traffic must be counted in the subnet: %+v", subnet.Totals)
+		}
+
+		table := &bytes.Buffer{}
+		if err := report.Write(table, TrafficFormatTable); err != nil {
+			t.Fatalf("%v", err)
+		}
+		if !strings.Contains(table.String(), "interface eni-1: 5 flows, 19 packets, 10230 bytes, 2 rejected\n  TOP TALKERS") ||
+			!strings.Contains(table.String(), "  198.51.100.9 -> 10.0.0.5:22/tcp  2      2        80     2") {
+			t.Errorf("unexpected table:\n%s", table)
+		}
+		data := &bytes.Buffer{}
+		if err := report.Write(data, TrafficFormatJSON); err != nil {
+			t.Fatalf("%v", err)
+		}
+		decoded := &TrafficReport{}
+		if err := json.Unmarshal(data.Bytes(), decoded); err != nil || decoded.BucketSeconds != 60 || decoded.Scopes[0].TopTalkers[0].DstPort != 443 {
+			t.Errorf("unexpected JSON report: %v\n%s", err, data)
+		}
+		if err := report.Write(data, "xml"); err == nil {
+			t.Errorf("expected an unknown format error")
+		}
+	})
+
+	t.Run("Report outputs", func(t *testing.T) {
+		dataDir := t.TempDir()
+		awsTCPDump := &AWSTCPDump{Config: &AWSTCPDumpConfig{TopN: 1, BucketSeconds: 120,
+			TrafficReportFilePath: filepath.Join(dataDir, "traffic.json"), TrafficChartFilePath: filepath.Join(dataDir, "traffic.png")},
+			KnownIntefaces: []*ec2.NetworkInterface{{NetworkInterfaceId: aws.String("eni-1"), SubnetId: aws.String("subnet-1")}}}
+		aggregator, err := awsTCPDump.TrafficAggregatorNew()
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		for _, event := range aggregatorEvents(t) {
+			if err := aggregator.Process(event); err != nil {
+				t.Fatalf("%v", err)
+			}
+		}
+		if err := awsTCPDump.WriteTrafficReport(aggregator, &bytes.Buffer{}); err != nil {
+			t.Fatalf("%v", err)
+		}
+		for _, name := range []string{"traffic.json", "traffic.png"} {
+			if info, err := os.Stat(filepath.Join(dataDir, name)); err != nil || info.Size() == 0 {
+				t.Errorf("%s was not written: %v", name, err)
+			}
+		}
+		if aggregator.Report(1).Scopes[2].Series[0].Bytes != 10080 {
+			t.Errorf("unexpected two minutes bucket: %v", aggregator.Report(1).Scopes[2].Series[0])
+		}
+	})
+
+	t.Run("Live recording", func(t *testing.T) {
+		awsTCPDump := &AWSTCPDump{Config: &AWSTCPDumpConfig{TopN: 2}}
+		echoed := 0
+		awsTCPDump.EventProcessor = func(*FlowLogEvent) error {
+			echoed++
+			return nil
+		}
+		aggregator, err := awsTCPDump.StartAggregation()
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		// Found by the subnet recorder after the aggregation started.
+		awsTCPDump.Aggregator.AddNetworkInterface(&ec2.NetworkInterface{NetworkInterfaceId: aws.String("eni-1"), SubnetId: aws.String("subnet-1")})
+		events := aggregatorEvents(t)
+		for _, event := range events {
+			if err := awsTCPDump.EventProcessor(event); err != nil {
+				t.Fatalf("%v", err)
+			}
+		}
+		report := aggregator.Report(2)
+		if echoed != len(events) || len(report.Scopes) != 3 || report.Scopes[2].ID != "subnet-1" || report.Scopes[0].Totals.Flows != 5 {
+			t.Errorf("unexpected live report, %d echoed: %+v", echoed, report.Scopes)
+		}
+	})
+
+	t.Run("Invalid bucket", func(t *testing.T) {
+		if _, err := FlowLogAggregatorNew(time.Millisecond, nil); err == nil {
+			t.Errorf("expected an error")
+		}
+	})
+}
@@ -0,0 +1,379 @@
+package aws_api
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	clients "github.com/AlexeyBeley/go_misc/aws_api/clients"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/route53"
+)
+
+const (
+	ResourceInstance    = "instance"
+	ResourceECSTask     = "ecs-task"
+	ResourceLambda      = "lambda"
+	ResourceELB         = "elb"
+	ResourceNATGateway  = "nat-gateway"
+	ResourceVPCEndpoint = "vpc-endpoint"
+	ResourceRDS         = "rds"
+	ResourceInterface   = "interface"
+	// Address known only by a Route53 private zone record.
+	ResourceDNS = "dns"
+)
+
+// Lambda ENI descriptions end with a uuid: "AWS Lambda VPC ENI-<function name>-<uuid>".
+var lambdaENIUUID = regexp.MustCompile(`-[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+
+// FlowLogResource is the resource owning a network interface or an address.
+type FlowLogResource struct {
+	Kind        string
+	ID          string
+	Name        string `json:",omitempty"`
+	InterfaceID string `json:",omitempty"`
+	DNSName     string `json:",omitempty"`
+}
+
+// String is the name used by the reports: Name tag or service name, DNS name, kind and ID.
+func (resource *FlowLogResource) String() string {
+	if resource.Name != "" {
+		return resource.Name
+	}
+	if resource.DNSName != "" {
+		return resource.DNSName
+	}
+	return resource.Kind + "/" + resource.ID
+}
+
+func tagName(tags []*ec2.Tag) string {
+	for _, tag := range tags {
+		if tag.Key != nil && *tag.Key == "Name" && tag.Value != nil {
+			return *tag.Value
+		}
+	}
+	return ""
+}
+
+// ResourceFromNetworkInterface detects the owner by the interface type, description and attachment.
+// instanceNames maps instance IDs to their Name tags, nil when unknown.
+func ResourceFromNetworkInterface(networkInterface *ec2.NetworkInterface, instanceNames map[string]string) *FlowLogResource {
+	interfaceID := aws.StringValue(networkInterface.NetworkInterfaceId)
+	description := aws.StringValue(networkInterface.Description)
+	resource := &FlowLogResource{Kind: ResourceInterface, ID: interfaceID, InterfaceID: interfaceID, Name: tagName(networkInterface.TagSet)}
+
+	switch {
+	case strings.HasPrefix(description, "arn:aws:ecs:"):
+		resource.Kind = ResourceECSTask
+		resource.ID = description
+	case aws.StringValue(networkInterface.InterfaceType) == "lambda" || strings.HasPrefix(description, "AWS Lambda VPC ENI-"):
+		resource.Kind = ResourceLambda
+		resource.ID = lambdaENIUUID.ReplaceAllString(strings.TrimPrefix(description, "AWS Lambda VPC ENI-"), "")
+		resource.Name = resource.ID
+	case strings.HasPrefix(description, "ELB "):
+		// "ELB app/<name>/<id>", "ELB net/<name>/<id>" or "ELB <classic name>".
+		resource.Kind = ResourceELB
+		resource.ID = strings.TrimPrefix(description, "ELB ")
+		parts := strings.Split(resource.ID, "/")
+		resource.Name = parts[min(1, len(parts)-1)]
+	case strings.HasPrefix(description, "Interface for NAT Gateway "):
+		resource.Kind = ResourceNATGateway
+		resource.ID = strings.TrimPrefix(description, "Interface for NAT Gateway ")
+	case strings.HasPrefix(description, "VPC Endpoint Interface "):
+		resource.Kind = ResourceVPCEndpoint
+		resource.ID = strings.TrimPrefix(description, "VPC Endpoint Interface ")
+	case aws.StringValue(networkInterface.RequesterId) == "amazon-rds" || description == "RDSNetworkInterface":
+		resource.Kind = ResourceRDS
+	case networkInterface.Attachment != nil && networkInterface.Attachment.InstanceId != nil:
+		resource.Kind = ResourceInstance
+		resource.ID = *networkInterface.Attachment.InstanceId
+		resource.Name = instanceNames[resource.ID]
+	default:
+		if resource.Name == "" {
+			resource.Name = description
+		}
+	}
+	return resource
+}
+
+// networkInterfaceAddresses returns the private and the public addresses of the interface.
+func networkInterfaceAddresses(networkInterface *ec2.NetworkInterface) []string {
+	ret := []string{}
+	for _, address := range networkInterface.PrivateIpAddresses {
+		if address.PrivateIpAddress != nil {
+			ret = append(ret, *address.PrivateIpAddress)
+		}
+		if address.Association != nil && address.Association.PublicIp != nil {
+			ret = append(ret, *address.Association.PublicIp)
+		}
+	}
+	if networkInterface.PrivateIpAddress != nil && !slices.Contains(ret, *networkInterface.PrivateIpAddress) {
+		ret = append(ret, *networkInterface.PrivateIpAddress)
+	}
+	if networkInterface.Association != nil && networkInterface.Association.PublicIp != nil && !slices.Contains(ret, *networkInterface.Association.PublicIp) {
+		ret = append(ret, *networkInterface.Association.PublicIp)
+	}
+	for _, address := range networkInterface.Ipv6Addresses {
+		if address.Ipv6Address != nil {
+			ret = append(ret, *address.Ipv6Address)
+		}
+	}
+	return ret
+}
+
+// FlowLogEnricher annotates the events with the resources owning their interface and addresses.
+// Enrich is safe to call from the recording goroutines while interfaces are added.
+type FlowLogEnricher struct {
+	lock          sync.RWMutex
+	instanceNames map[string]string
+	// ECS task resource by the attached network interface.
+	tasks      map[string]*FlowLogResource
+	interfaces map[string]*FlowLogResource
+	addresses  map[string]*FlowLogResource
+}
+
+// Generator
+func FlowLogEnricherNew() *FlowLogEnricher {
+	return &FlowLogEnricher{instanceNames: map[string]string{},
+		tasks:      map[string]*FlowLogResource{},
+		interfaces: map[string]*FlowLogResource{},
+		addresses:  map[string]*FlowLogResource{}}
+}
+
+// AddInstance registers the Name tag of the instance, to be set on its interfaces added after.
+func (enricher *FlowLogEnricher) AddInstance(instance *ec2.Instance) {
+	if instance.InstanceId == nil {
+		return
+	}
+	enricher.lock.Lock()
+	defer enricher.lock.Unlock()
+	enricher.instanceNames[*instance.InstanceId] = tagName(instance.Tags)
+}
+
+// AddECSTask registers the task of the awsvpc interfaces it is attached to, added before or after.
+// The task is named by its service, the task definition family when not run by a service.
+func (enricher *FlowLogEnricher) AddECSTask(task *ecs.Task) {
+	name := aws.StringValue(task.Group)
+	if strings.HasPrefix(name, "service:") {
+		name = strings.TrimPrefix(name, "service:")
+	} else if task.TaskDefinitionArn != nil {
+		name = *task.TaskDefinitionArn
+		name = name[strings.LastIndex(name, "/")+1:]
+		name = strings.Split(name, ":")[0]
+	}
+
+	enricher.lock.Lock()
+	defer enricher.lock.Unlock()
+	for _, attachment := range task.Attachments {
+		for _, detail := range attachment.Details {
+			if aws.StringValue(detail.Name) != "networkInterfaceId" || detail.Value == nil {
+				continue
+			}
+			resource := &FlowLogResource{Kind: ResourceECSTask, ID: aws.StringValue(task.TaskArn), Name: name, InterfaceID: *detail.Value}
+			enricher.tasks[*detail.Value] = resource
+			if known, found := enricher.interfaces[*detail.Value]; found {
+				enricher.replace(known, resource)
+			}
+		}
+	}
+}
+
+// replace points the interface and the addresses of the old resource to the new one, keeping the DNS name.
+// The resources are not modified once added, the enriched events share them.
+func (enricher *FlowLogEnricher) replace(old, resource *FlowLogResource) {
+	if resource.DNSName == "" {
+		resource.DNSName = old.DNSName
+	}
+	if resource.InterfaceID != "" {
+		enricher.interfaces[resource.InterfaceID] = resource
+	}
+	for address, known := range enricher.addresses {
+		if known == old {
+			enricher.addresses[address] = resource
+		}
+	}
+}
+
+// AddNetworkInterface maps the interface and its private and public addresses to the owning resource.
+// A nil enricher does nothing.
+func (enricher *FlowLogEnricher) AddNetworkInterface(networkInterface *ec2.NetworkInterface) *FlowLogResource {
+	if enricher == nil || networkInterface.NetworkInterfaceId == nil {
+		return nil
+	}
+	enricher.lock.Lock()
+	defer enricher.lock.Unlock()
+
+	resource := ResourceFromNetworkInterface(networkInterface, enricher.instanceNames)
+	if task, found := enricher.tasks[resource.InterfaceID]; found {
+		copied := *task
+		resource = &copied
+	}
+	addresses := networkInterfaceAddresses(networkInterface)
+	for _, address := range addresses {
+		if known, found := enricher.addresses[address]; found && resource.DNSName == "" {
+			resource.DNSName = known.DNSName
+		}
+	}
+	if old, found := enricher.interfaces[resource.InterfaceID]; found {
+		enricher.replace(old, resource)
+	}
+	enricher.interfaces[resource.InterfaceID] = resource
+	for _, address := range addresses {
+		enricher.addresses[address] = resource
+	}
+	return resource
+}
+
+// AddRecordSet names the addresses of an A or AAAA record, alias records are skipped.
+// An address with several names keeps the first name in the alphabetical order.
+func (enricher *FlowLogEnricher) AddRecordSet(recordSet *route53.ResourceRecordSet) {
+	recordType := aws.StringValue(recordSet.Type)
+	if recordType != "A" && recordType != "AAAA" {
+		return
+	}
+	name := strings.TrimSuffix(aws.StringValue(recordSet.Name), ".")
+
+	enricher.lock.Lock()
+	defer enricher.lock.Unlock()
+	for _, record := range recordSet.ResourceRecords {
+		address := aws.StringValue(record.Value)
+		if net.ParseIP(address) == nil {
+			continue
+		}
+		known, found := enricher.addresses[address]
+		if !found {
+			enricher.addresses[address] = &FlowLogResource{Kind: ResourceDNS, ID: address, DNSName: name}
+			continue
+		}
+		if known.DNSName != "" && known.DNSName <= name {
+			continue
+		}
+		copied := *known
+		copied.DNSName = name
+		enricher.replace(known, &copied)
+	}
+}
+
+// Resource returns the resource owning the address, nil when unknown.
+func (enricher *FlowLogEnricher) Resource(address string) *FlowLogResource {
+	enricher.lock.RLock()
+	defer enricher.lock.RUnlock()
+	return enricher.addresses[address]
+}
+
+// Enrich sets the resources of the event interface, source and destination. A nil enricher does nothing.
+func (enricher *FlowLogEnricher) Enrich(event *FlowLogEvent) {
+	if enricher == nil {
+		return
+	}
+	enricher.lock.RLock()
+	defer enricher.lock.RUnlock()
+
+	event.Resource = enricher.interfaces[event.InterfaceID]
+	if event.SrcAddr != nil {
+		event.SrcResource = enricher.addresses[event.SrcAddr.String()]
+	}
+	if event.DstAddr != nil {
+		event.DstResource = enricher.addresses[event.DstAddr.String()]
+	}
+}
+
+// Load fetches the region network interfaces and the instance Name tags.
+// ECS tasks and Route53 private zones A records are loaded when their APIs are set.
+func (enricher *FlowLogEnricher) Load(ctx context.Context, ec2API *clients.EC2API, ecsAPI *clients.ECSAPI, route53API *clients.Route53API) error {
+	errorPrefix := "[flow_log_enrichment:Load]"
+	for instance, err := range ec2API.IterInstances(ctx, nil) {
+		if err != nil {
+			return fmt.Errorf("%s failed to describe instances\n%w", errorPrefix, err)
+		}
+		enricher.AddInstance(instance)
+	}
+
+	if ecsAPI != nil {
+		for cluster, err := range ecsAPI.IterClusters(ctx, &ecs.ListClustersInput{}) {
+			if err != nil {
+				return fmt.Errorf("%s failed to list ECS clusters\n%w", errorPrefix, err)
+			}
+			for task, err := range ecsAPI.IterTasks(ctx, &ecs.ListTasksInput{Cluster: cluster.ClusterArn}) {
+				if err != nil {
+					return fmt.Errorf("%s failed to describe %s tasks\n%w", errorPrefix, aws.StringValue(cluster.ClusterArn), err)
+				}
+				enricher.AddECSTask(task)
+			}
+		}
+	}
+
+	for networkInterface, err := range ec2API.IterNetworkInterfaces(ctx, nil) {
+		if err != nil {
+			return fmt.Errorf("%s failed to describe network interfaces\n%w", errorPrefix, err)
+		}
+		enricher.AddNetworkInterface(networkInterface)
+	}
+
+	if route53API == nil {
+		return nil
+	}
+	for hostedZone, err := range route53API.IterHostedZones(ctx, nil) {
+		if err != nil {
+			return fmt.Errorf("%s failed to list hosted zones\n%w", errorPrefix, err)
+		}
+		if hostedZone.Config == nil || hostedZone.Config.PrivateZone == nil || !*hostedZone.Config.PrivateZone {
+			continue
+		}
+		for recordSet, err := range route53API.IterHostedZoneResourceRecordSets(ctx, &route53.ListResourceRecordSetsInput{HostedZoneId: hostedZone.Id}) {
+			if err != nil {
+				return fmt.Errorf("%s failed to list %s records\n%w", errorPrefix, aws.StringValue(hostedZone.Name), err)
+			}
+			enricher.AddRecordSet(recordSet)
+		}
+	}
+	return nil
+}
+
+// String lists the known addresses and their resources.
+func (enricher *FlowLogEnricher) String() string {
+	enricher.lock.RLock()
+	defer enricher.lock.RUnlock()
+
+	addresses := []string{}
+	for address := range enricher.addresses {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+	lines := []string{}
+	for _, address := range addresses {
+		resource := enricher.addresses[address]
+		lines = append(lines, fmt.Sprintf("%s %s %s/%s", address, resource, resource.Kind, resource.ID))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// LoadEnricher sets Enricher by Config.Enrich, Config.EnrichDNS adds the private zones names.
+// The known interfaces are added without fetching them again.
+func (awsTCPDump *AWSTCPDump) LoadEnricher() error {
+	config := awsTCPDump.Config
+	if !(config.Enrich || config.EnrichDNS) || awsTCPDump.Enricher != nil {
+		return nil
+	}
+	enricher := FlowLogEnricherNew()
+	var route53API *clients.Route53API
+	if config.EnrichDNS {
+		route53API = awsTCPDump.getClients().Route53()
+	}
+	err := enricher.Load(context.Background(), awsTCPDump.getClients().EC2(&config.Region), awsTCPDump.getClients().ECS(&config.Region), route53API)
+	if err != nil {
+		return err
+	}
+	for _, networkInterface := range awsTCPDump.KnownIntefaces {
+		enricher.AddNetworkInterface(networkInterface)
+	}
+	awsTCPDump.Enricher = enricher
+	return nil
+}
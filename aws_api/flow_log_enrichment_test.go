@@ -0,0 +1,148 @@
+package aws_api
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AlexeyBeley/go_misc/aws_api/clients/fakes"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/route53"
+)
+
+func enrichmentServices() *fakes.Services {
+	services := fakes.ServicesNew()
+	networkInterface := func(id, address, description string) *ec2.NetworkInterface {
+		return &ec2.NetworkInterface{NetworkInterfaceId: aws.String(id), PrivateIpAddress: aws.String(address), Description: aws.String(description),
+			PrivateIpAddresses: []*ec2.NetworkInterfacePrivateIpAddress{{PrivateIpAddress: aws.String(address)}}}
+	}
+	instance := networkInterface("eni-instance", "10.0.0.10", "")
+	instance.Attachment = &ec2.NetworkInterfaceAttachment{InstanceId: aws.String("i-1")}
+	instance.Association = &ec2.NetworkInterfaceAssociation{PublicIp: aws.String("203.0.113.10")}
+	rds := networkInterface("eni-rds", "10.0.0.20", "RDSNetworkInterface")
+	rds.RequesterId = aws.String("amazon-rds")
+	services.EC2.NetworkInterfaces = []*ec2.NetworkInterface{instance, rds,
+		networkInterface("eni-task", "10.0.0.30", "arn:aws:ecs:us-east-1:123456789012:attachment/0b5d7a4e-1111-2222-3333-444455556666"),
+		networkInterface("eni-lambda", "10.0.0.40", "AWS Lambda VPC ENI-orders-handler-0b5d7a4e-1111-2222-3333-444455556666"),
+		networkInterface("eni-alb", "10.0.0.50", "ELB app/public-alb/50dc6c495c0c9188"),
+		networkInterface("eni-nat", "10.0.0.60", "Interface for NAT Gateway nat-0123"),
+		networkInterface("eni-vpce", "10.0.0.70", "VPC Endpoint Interface vpce-0123"),
+	}
+	services.EC2.Instances = []*ec2.Instance{{InstanceId: aws.String("i-1"), Tags: []*ec2.Tag{{Key: aws.String("Name"), Value: aws.String("bastion")}}}}
+
+	services.ECS.Clusters = []*ecs.Cluster{{ClusterArn: aws.String("arn:aws:ecs:us-east-1:123456789012:cluster/prod")}}
+	services.ECS.Tasks["prod"] = []*ecs.Task{{TaskArn: aws.String("arn:aws:ecs:us-east-1:123456789012:task/prod/1"), Group: aws.String("service:api-service"),
+		Attachments: []*ecs.Attachment{{Details: []*ecs.KeyValuePair{{Name: aws.String("networkInterfaceId"), Value: aws.String("eni-task")}}}}}}
+
+	services.Route53.HostedZones = []*route53.HostedZone{
+		{Id: aws.String("/hostedzone/Z1"), Name: aws.String("internal."), Config: &route53.HostedZoneConfig{PrivateZone: aws.Bool(true)}},
+		{Id: aws.String("/hostedzone/Z2"), Name: aws.String("example.com."), Config: &route53.HostedZoneConfig{PrivateZone: aws.Bool(false)}}}
+	record := func(name, recordType string, values ...string) *route53.ResourceRecordSet {
+		recordSet := &route53.ResourceRecordSet{Name: aws.String(name), Type: aws.String(recordType)}
+		for _, value := range values {
+			recordSet.ResourceRecords = append(recordSet.ResourceRecords, &route53.ResourceRecord{Value: aws.String(value)})
+		}
+		return recordSet
+	}
+	services.Route53.ResourceRecordSets["/hostedzone/Z1"] = []*route53.ResourceRecordSet{
+		record("rds-prod.internal.", "A", "10.0.0.20"),
+		record("db.internal.", "CNAME", "rds-prod.internal"),
+		record("legacy.internal.", "A", "10.0.9.9"),
+		record("bastion.internal.", "A", "10.0.0.10")}
+	services.Route53.ResourceRecordSets["/hostedzone/Z2"] = []*route53.ResourceRecordSet{record("www.example.com.", "A", "10.0.0.50")}
+	return services
+}
+
+func TestFlowLogEnricher(t *testing.T) {
+	t.Run("Valid run", func(t *testing.T) {
+		services := enrichmentServices()
+		awsTCPDump := &AWSTCPDump{Config: &AWSTCPDumpConfig{Region: "us-east-1", EnrichDNS: true}, Clients: services.Factory()}
+		if err := awsTCPDump.LoadEnricher(); err != nil {
+			t.Fatalf("%v", err)
+		}
+
+		expected := map[string]string{
+			"10.0.0.10":    "bastion instance/i-1 bastion.internal",
+			"203.0.113.10": "bastion instance/i-1 bastion.internal",
+			"10.0.0.20":    "rds-prod.internal rds/eni-rds rds-prod.internal",
+			"10.0.0.30":    "api-service ecs-task/arn:aws:ecs:us-east-1:123456789012:task/prod/1 ",
+			"10.0.0.40":    "orders-handler lambda/orders-handler ",
+			"10.0.0.50":    "public-alb elb/app/public-alb/50dc6c495c0c9188 ",
+			"10.0.0.60":    "nat-gateway/nat-0123 nat-gateway/nat-0123 ",
+			"10.0.0.70":    "vpc-endpoint/vpce-0123 vpc-endpoint/vpce-0123 ",
+			"10.0.9.9":     "legacy.internal dns/10.0.9.9 legacy.internal",
+		}
+		for address, description := range expected {
+			resource := awsTCPDump.Enricher.Resource(address)
+			if resource == nil {
+				t.Errorf("%s is not known", address)
+				continue
+			}
+			if got := resource.String() + " " + resource.Kind + "/" + resource.ID + " " + resource.DNSName; got != description {
+				t.Errorf("%s: got %q, expected %q", address, got, description)
+			}
+		}
+
+		format, err := FlowLogFormatNew(DefaultFlowLogFormat)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		aggregator, err := FlowLogAggregatorNew(time.Minute, nil)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		for _, message := range []string{
+			"2 123456789012 eni-task 10.0.0.30 10.0.0.20 51000 5432 6 10 8000 1735776000 1735776060 ACCEPT OK",
+			"2 123456789012 eni-task 10.0.0.30 198.51.100.7 51001 443 6 1 40 1735776000 1735776060 ACCEPT OK",
+		} {
+			event, err := format.Parse(message)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			awsTCPDump.Enricher.Enrich(event)
+			if event.Resource == nil || event.Resource.Name != "api-service" || event.SrcResource != event.Resource {
+				t.Errorf("unexpected interface resource: %+v", event.Resource)
+			}
+			if err := aggregator.Process(event); err != nil {
+				t.Fatalf("%v", err)
+			}
+		}
+		table := &bytes.Buffer{}
+		if err := aggregator.Report(2).Write(table, TrafficFormatTable); err != nil {
+			t.Fatalf("%v", err)
+		}
+		if !strings.Contains(table.String(), "api-service -> rds-prod.internal:5432/tcp") || !strings.Contains(table.String(), "api-service -> 198.51.100.7:443/tcp") {
+			t.Errorf("unexpected table:\n%s", table)
+		}
+	})
+
+	t.Run("Interfaces added while recording", func(t *testing.T) {
+		enricher := FlowLogEnricherNew()
+		enricher.AddRecordSet(&route53.ResourceRecordSet{Name: aws.String("worker.internal."), Type: aws.String("A"),
+			ResourceRecords: []*route53.ResourceRecord{{Value: aws.String("10.0.0.30")}}})
+		enricher.AddECSTask(&ecs.Task{TaskArn: aws.String("task-1"), TaskDefinitionArn: aws.String("arn:aws:ecs:us-east-1:123456789012:task-definition/worker:7"),
+			Attachments: []*ecs.Attachment{{Details: []*ecs.KeyValuePair{{Name: aws.String("networkInterfaceId"), Value: aws.String("eni-task")}}}}})
+		enricher.AddNetworkInterface(&ec2.NetworkInterface{NetworkInterfaceId: aws.String("eni-task"), PrivateIpAddress: aws.String("10.0.0.30"),
+			Description: aws.String("arn:aws:ecs:us-east-1:123456789012:attachment/1")})
+
+		resource := enricher.Resource("10.0.0.30")
+		if resource == nil || resource.Kind != ResourceECSTask || resource.Name != "worker" || resource.DNSName != "worker.internal" {
+			t.Errorf("unexpected resource: %+v", resource)
+		}
+		var nilEnricher *FlowLogEnricher
+		nilEnricher.Enrich(&FlowLogEvent{})
+		if nilEnricher.AddNetworkInterface(&ec2.NetworkInterface{NetworkInterfaceId: aws.String("eni-1")}) != nil {
+			t.Errorf("nil enricher must not add interfaces")
+		}
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		awsTCPDump := &AWSTCPDump{Config: &AWSTCPDumpConfig{}, Clients: enrichmentServices().Factory()}
+		if err := awsTCPDump.LoadEnricher(); err != nil || awsTCPDump.Enricher != nil {
+			t.Errorf("enricher must not be loaded: %v", err)
+		}
+	})
+}
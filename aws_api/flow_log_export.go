@@ -0,0 +1,457 @@
+package aws_api
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ExportFormatZeek     = "zeek"
+	ExportFormatZeekJSON = "zeek-json"
+	ExportFormatPcapNG   = "pcapng"
+)
+
+// FlowLogWriter exports the events, Write is an EventProcessor and Close completes the output.
+// Events without addresses, NODATA and SKIPDATA records, are counted as skipped.
+type FlowLogWriter interface {
+	Write(event *FlowLogEvent) error
+	Close() error
+}
+
+// Generator
+func FlowLogWriterNew(writer io.Writer, format string) (FlowLogWriter, error) {
+	switch format {
+	case ExportFormatZeek:
+		return &ZeekConnWriter{writer: writer}, nil
+	case ExportFormatZeekJSON:
+		return &ZeekConnWriter{writer: writer, JSON: true}, nil
+	case ExportFormatPcapNG:
+		return &PcapNGWriter{writer: writer, interfaces: map[string]uint32{}}, nil
+	}
+	return nil, fmt.Errorf("[flow_log_export:FlowLogWriterNew] unknown export format: %s", format)
+}
+
+// ExportFormatByPath picks the format by the file extension: .pcapng, .json for Zeek JSON, Zeek TSV otherwise.
+func ExportFormatByPath(filePath string) string {
+	switch {
+	case strings.HasSuffix(filePath, ".pcapng"):
+		return ExportFormatPcapNG
+	case strings.HasSuffix(filePath, ".json"):
+		return ExportFormatZeekJSON
+	}
+	return ExportFormatZeek
+}
+
+func exportable(event *FlowLogEvent) bool {
+	return event.SrcAddr != nil && event.DstAddr != nil
+}
+
+// flowUID is a Zeek like connection uid, stable for the same record.
+func flowUID(event *FlowLogEvent) string {
+	hash := fnv.New64a()
+	fmt.Fprintf(hash, "%s %s %s %d %d %s %d %d %d %d", event.InterfaceID, event.SrcAddr, event.DstAddr, event.SrcPort, event.DstPort,
+		event.Protocol, event.Start, event.End, event.Packets, event.Bytes)
+	return "C" + strconv.FormatUint(hash.Sum64(), 36)
+}
+
+var zeekConnFields = []string{"ts", "uid", "id.orig_h", "id.orig_p", "id.resp_h", "id.resp_p", "proto", "service", "duration",
+	"orig_bytes", "resp_bytes", "conn_state", "local_orig", "local_resp", "missed_bytes", "history",
+	"orig_pkts", "orig_ip_bytes", "resp_pkts", "resp_ip_bytes", "tunnel_parents"}
+
+var zeekConnTypes = []string{"time", "string", "addr", "port", "addr", "port", "enum", "string", "interval",
+	"count", "count", "string", "bool", "bool", "count", "string",
+	"count", "count", "count", "count", "set[string]"}
+
+// Zeek has no enum value for the other IP protocols.
+func zeekProto(protocol string) string {
+	switch protocolName(protocol) {
+	case "tcp", "udp":
+		return protocolName(protocol)
+	case "icmp", "icmpv6":
+		return "icmp"
+	}
+	return "unknown_transport"
+}
+
+// zeekHistory maps the flow log tcp-flags, the flags seen during the aggregation interval, to the originator history letters.
+func zeekHistory(tcpFlags int) string {
+	history := ""
+	if tcpFlags&0x12 == 0x12 {
+		history += "H"
+	} else if tcpFlags&0x02 != 0 {
+		history += "S"
+	}
+	if tcpFlags&0x01 != 0 {
+		history += "F"
+	}
+	if tcpFlags&0x04 != 0 {
+		history += "R"
+	}
+	return history
+}
+
+// ZeekConnWriter writes the events as Zeek conn.log records, TSV with the #fields header or JSON lines.
+// A flow log record is one direction of a connection: the source is the originator and the responder counters are 0.
+// A rejected record is an attempt without a reply, S0, an accepted one has no handshake to judge by, OTH.
+type ZeekConnWriter struct {
+	JSON    bool
+	Records int
+	Skipped int
+
+	writer io.Writer
+	lock   sync.Mutex
+	opened bool
+	last   time.Time
+}
+
+func (zeekWriter *ZeekConnWriter) Write(event *FlowLogEvent) error {
+	zeekWriter.lock.Lock()
+	defer zeekWriter.lock.Unlock()
+	if !exportable(event) {
+		zeekWriter.Skipped++
+		return nil
+	}
+	start, end := time.Unix(int64(event.Start), 0), time.Unix(int64(event.End), 0)
+	if !zeekWriter.JSON && !zeekWriter.opened {
+		if err := zeekWriter.writeHeader(start); err != nil {
+			return err
+		}
+	}
+	zeekWriter.opened = true
+	if end.After(zeekWriter.last) {
+		zeekWriter.last = end
+	}
+	zeekWriter.Records++
+
+	history := zeekHistory(event.TCPFlags)
+	connState := "OTH"
+	if event.Action == "REJECT" {
+		connState = "S0"
+	}
+	values := []any{float64(event.Start), flowUID(event), event.SrcAddr.String(), event.SrcPort, event.DstAddr.String(), event.DstPort,
+		zeekProto(event.Protocol), nil, float64(event.End - event.Start), nil, nil, connState, event.SrcAddr.IsPrivate(), event.DstAddr.IsPrivate(), 0,
+		history, event.Packets, event.Bytes, 0, 0, []string{}}
+
+	if zeekWriter.JSON {
+		record := map[string]any{}
+		for index, value := range values {
+			if value != nil && value != "" {
+				record[zeekConnFields[index]] = value
+			}
+		}
+		data, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(zeekWriter.writer, "%s\n", data)
+		return err
+	}
+
+	columns := []string{}
+	for _, value := range values {
+		switch typed := value.(type) {
+		case nil:
+			columns = append(columns, "-")
+		case string:
+			if typed == "" {
+				typed = "-"
+			}
+			columns = append(columns, typed)
+		case float64:
+			columns = append(columns, strconv.FormatFloat(typed, 'f', 6, 64))
+		case bool:
+			columns = append(columns, strings.ToUpper(strconv.FormatBool(typed)[:1]))
+		case []string:
+			columns = append(columns, "(empty)")
+		default:
+			columns = append(columns, fmt.Sprint(typed))
+		}
+	}
+	_, err := fmt.Fprintf(zeekWriter.writer, "%s\n", strings.Join(columns, "\t"))
+	return err
+}
+
+// The log is opened and closed at the first flow start and at the last flow end.
+func (zeekWriter *ZeekConnWriter) writeHeader(open time.Time) error {
+	_, err := fmt.Fprintf(zeekWriter.writer, "#separator \\x09\n#set_separator\t,\n#empty_field\t(empty)\n#unset_field\t-\n#path\tconn\n#open\t%s\n#fields\t%s\n#types\t%s\n",
+		open.UTC().Format("2006-01-02-15-04-05"), strings.Join(zeekConnFields, "\t"), strings.Join(zeekConnTypes, "\t"))
+	return err
+}
+
+func (zeekWriter *ZeekConnWriter) Close() error {
+	zeekWriter.lock.Lock()
+	defer zeekWriter.lock.Unlock()
+	if zeekWriter.JSON {
+		return nil
+	}
+	if !zeekWriter.opened {
+		zeekWriter.last = time.Now()
+		if err := zeekWriter.writeHeader(zeekWriter.last); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(zeekWriter.writer, "#close\t%s\n", zeekWriter.last.UTC().Format("2006-01-02-15-04-05"))
+	return err
+}
+
+const (
+	pcapNGSectionHeaderBlock   = 0x0A0D0D0A
+	pcapNGInterfaceDescription = 0x00000001
+	pcapNGEnhancedPacketBlock  = 0x00000006
+	// Packets start at the IP header, v4 and v6 by the version nibble.
+	pcapNGLinkTypeRaw = 101
+)
+
+// PcapNGWriter writes a synthetic packet per flow: IP and transport headers without payload, timestamped at the flow start.
+// The flow log counters are in the packet comment, an interface is described per flow log ENI.
+type PcapNGWriter struct {
+	Packets int
+	Skipped int
+
+	writer     io.Writer
+	lock       sync.Mutex
+	started    bool
+	interfaces map[string]uint32
+}
+
+// pcapNGOption is an option TLV padded to 32 bits.
+func pcapNGOption(code uint16, value []byte) []byte {
+	ret := binary.LittleEndian.AppendUint16(nil, code)
+	ret = binary.LittleEndian.AppendUint16(ret, uint16(len(value)))
+	ret = append(ret, value...)
+	for len(ret)%4 != 0 {
+		ret = append(ret, 0)
+	}
+	return ret
+}
+
+// writeBlock frames the body with the block type and the total length, repeated at the end.
+func (pcapWriter *PcapNGWriter) writeBlock(blockType uint32, body []byte) error {
+	length := uint32(12 + len(body))
+	block := binary.LittleEndian.AppendUint32(nil, blockType)
+	block = binary.LittleEndian.AppendUint32(block, length)
+	block = append(block, body...)
+	block = binary.LittleEndian.AppendUint32(block, length)
+	_, err := pcapWriter.writer.Write(block)
+	return err
+}
+
+func (pcapWriter *PcapNGWriter) start() error {
+	if pcapWriter.started {
+		return nil
+	}
+	pcapWriter.started = true
+	body := binary.LittleEndian.AppendUint32(nil, 0x1A2B3C4D)
+	body = binary.LittleEndian.AppendUint16(body, 1)
+	body = binary.LittleEndian.AppendUint16(body, 0)
+	// Section length is not known upfront.
+	body = binary.LittleEndian.AppendUint64(body, 0xFFFFFFFFFFFFFFFF)
+	body = append(body, pcapNGOption(4, []byte("aws_tcpdump"))...)
+	body = append(body, pcapNGOption(0, nil)...)
+	return pcapWriter.writeBlock(pcapNGSectionHeaderBlock, body)
+}
+
+func (pcapWriter *PcapNGWriter) interfaceID(name string) (uint32, error) {
+	if id, found := pcapWriter.interfaces[name]; found {
+		return id, nil
+	}
+	id := uint32(len(pcapWriter.interfaces))
+	pcapWriter.interfaces[name] = id
+	body := binary.LittleEndian.AppendUint16(nil, pcapNGLinkTypeRaw)
+	body = binary.LittleEndian.AppendUint16(body, 0)
+	body = binary.LittleEndian.AppendUint32(body, 0)
+	body = append(body, pcapNGOption(2, []byte(name))...)
+	body = append(body, pcapNGOption(3, []byte("VPC flow log "+name))...)
+	body = append(body, pcapNGOption(0, nil)...)
+	return id, pcapWriter.writeBlock(pcapNGInterfaceDescription, body)
+}
+
+func (pcapWriter *PcapNGWriter) Write(event *FlowLogEvent) error {
+	pcapWriter.lock.Lock()
+	defer pcapWriter.lock.Unlock()
+	if !exportable(event) {
+		pcapWriter.Skipped++
+		return nil
+	}
+	packet, err := flowPacket(event)
+	if err != nil {
+		return err
+	}
+	if err := pcapWriter.start(); err != nil {
+		return err
+	}
+	id, err := pcapWriter.interfaceID(event.InterfaceID)
+	if err != nil {
+		return err
+	}
+
+	// Default if_tsresol, microseconds.
+	timestamp := uint64(event.Start) * 1000000
+	body := binary.LittleEndian.AppendUint32(nil, id)
+	body = binary.LittleEndian.AppendUint32(body, uint32(timestamp>>32))
+	body = binary.LittleEndian.AppendUint32(body, uint32(timestamp))
+	body = binary.LittleEndian.AppendUint32(body, uint32(len(packet)))
+	body = binary.LittleEndian.AppendUint32(body, uint32(len(packet)))
+	body = append(body, packet...)
+	for len(body)%4 != 0 {
+		body = append(body, 0)
+	}
+	body = append(body, pcapNGOption(1, []byte(flowComment(event)))...)
+	body = append(body, pcapNGOption(0, nil)...)
+	pcapWriter.Packets++
+	return pcapWriter.writeBlock(pcapNGEnhancedPacketBlock, body)
+}
+
+// Close writes the section header of an empty capture, the blocks are not buffered.
+func (pcapWriter *PcapNGWriter) Close() error {
+	pcapWriter.lock.Lock()
+	defer pcapWriter.lock.Unlock()
+	return pcapWriter.start()
+}
+
+// flowComment carries what the packet can not: the flow counters, action and owners.
+func flowComment(event *FlowLogEvent) string {
+	comment := fmt.Sprintf("flow-log %s %s packets=%d bytes=%d start=%s end=%s", event.InterfaceID, event.Action, event.Packets, event.Bytes,
+		time.Unix(int64(event.Start), 0).UTC().Format(time.RFC3339), time.Unix(int64(event.End), 0).UTC().Format(time.RFC3339))
+	if event.TCPFlags != 0 {
+		comment += fmt.Sprintf(" tcp-flags=%d", event.TCPFlags)
+	}
+	if event.SrcResource != nil {
+		comment += " src=" + event.SrcResource.String()
+	}
+	if event.DstResource != nil {
+		comment += " dst=" + event.DstResource.String()
+	}
+	return comment
+}
+
+// checksum is the internet checksum of the data.
+func checksum(data []byte, sum uint32) uint16 {
+	for index := 0; index+1 < len(data); index += 2 {
+		sum += uint32(binary.BigEndian.Uint16(data[index:]))
+	}
+	if len(data)%2 == 1 {
+		sum += uint32(data[len(data)-1]) << 8
+	}
+	for sum>>16 != 0 {
+		sum = sum&0xFFFF + sum>>16
+	}
+	return ^uint16(sum)
+}
+
+// flowPacket builds the IP packet of the flow: a TCP packet with the flow tcp-flags, an UDP datagram,
+// an echo request for ICMP, a bare IP header for the other protocols.
+func flowPacket(event *FlowLogEvent) ([]byte, error) {
+	errorPrefix := "[flow_log_export:flowPacket]"
+	protocol, err := strconv.Atoi(event.Protocol)
+	if err != nil || protocol < 0 || protocol > 255 {
+		return nil, fmt.Errorf("%s invalid protocol: %s", errorPrefix, event.Protocol)
+	}
+	src4, dst4 := event.SrcAddr.To4(), event.DstAddr.To4()
+	ipv4 := src4 != nil && dst4 != nil
+	if !ipv4 && (src4 != nil || dst4 != nil) {
+		return nil, errors.New(errorPrefix + " mixed address families: " + event.SrcAddr.String() + " " + event.DstAddr.String())
+	}
+
+	var transport []byte
+	switch protocol {
+	case 6:
+		transport = binary.BigEndian.AppendUint16(nil, uint16(event.SrcPort))
+		transport = binary.BigEndian.AppendUint16(transport, uint16(event.DstPort))
+		transport = append(transport, make([]byte, 8)...)
+		transport = append(transport, 5<<4, byte(event.TCPFlags))
+		transport = binary.BigEndian.AppendUint16(transport, 65535)
+		transport = append(transport, 0, 0, 0, 0)
+	case 17:
+		transport = binary.BigEndian.AppendUint16(nil, uint16(event.SrcPort))
+		transport = binary.BigEndian.AppendUint16(transport, uint16(event.DstPort))
+		transport = binary.BigEndian.AppendUint16(transport, 8)
+		transport = append(transport, 0, 0)
+	case 1:
+		transport = []byte{8, 0, 0, 0, 0, 0, 0, 0}
+	case 58:
+		transport = []byte{128, 0, 0, 0, 0, 0, 0, 0}
+	}
+
+	// The transport checksums cover the pseudo header: addresses, protocol and length.
+	checksumOffset := map[int]int{6: 16, 17: 6, 1: 2, 58: 2}
+	if offset, found := checksumOffset[protocol]; found {
+		sum := uint32(protocol) + uint32(len(transport))
+		pseudo := append(append([]byte{}, event.SrcAddr.To16()...), event.DstAddr.To16()...)
+		if ipv4 {
+			pseudo = append(append([]byte{}, src4...), dst4...)
+		}
+		if protocol == 1 {
+			// ICMPv4 has no pseudo header.
+			sum, pseudo = 0, nil
+		}
+		value := checksum(append(pseudo, transport...), sum)
+		if protocol == 17 && value == 0 {
+			value = 0xFFFF
+		}
+		binary.BigEndian.PutUint16(transport[offset:], value)
+	}
+
+	if ipv4 {
+		header := []byte{0x45, 0}
+		header = binary.BigEndian.AppendUint16(header, uint16(20+len(transport)))
+		header = append(header, 0, 0, 0x40, 0, 64, byte(protocol), 0, 0)
+		header = append(append(header, src4...), dst4...)
+		binary.BigEndian.PutUint16(header[10:], checksum(header, 0))
+		return append(header, transport...), nil
+	}
+	header := []byte{0x60, 0, 0, 0}
+	header = binary.BigEndian.AppendUint16(header, uint16(len(transport)))
+	header = append(header, byte(protocol), 64)
+	header = append(append(header, event.SrcAddr.To16()...), event.DstAddr.To16()...)
+	return append(header, transport...), nil
+}
+
+// fileFlowLogWriter closes the export file after the writer.
+type fileFlowLogWriter struct {
+	FlowLogWriter
+	file *os.File
+}
+
+func (fileWriter *fileFlowLogWriter) Close() error {
+	return errors.Join(fileWriter.FlowLogWriter.Close(), fileWriter.file.Close())
+}
+
+// StartExport creates Config.ExportFilePath and exports the events ahead of the EventProcessor.
+// Config.ExportFormat is picked by the file extension when not set. The caller closes the writer.
+func (awsTCPDump *AWSTCPDump) StartExport() (FlowLogWriter, error) {
+	errorPrefix := "[flow_log_export:StartExport]"
+	format := awsTCPDump.Config.ExportFormat
+	if format == "" {
+		format = ExportFormatByPath(awsTCPDump.Config.ExportFilePath)
+	}
+	file, err := os.Create(awsTCPDump.Config.ExportFilePath)
+	if err != nil {
+		return nil, fmt.Errorf("%s failed to create %s\n%w", errorPrefix, awsTCPDump.Config.ExportFilePath, err)
+	}
+	writer, err := FlowLogWriterNew(file, format)
+	if err != nil {
+		return nil, errors.Join(err, file.Close())
+	}
+
+	processor := awsTCPDump.EventProcessor
+	awsTCPDump.EventProcessor = func(event *FlowLogEvent) error {
+		if err := writer.Write(event); err != nil {
+			return err
+		}
+		if processor == nil {
+			return nil
+		}
+		return processor(event)
+	}
+	return &fileFlowLogWriter{FlowLogWriter: writer, file: file}, nil
+}
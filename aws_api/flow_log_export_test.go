@@ -0,0 +1,189 @@
+package aws_api
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func exportEvents(t *testing.T) []*FlowLogEvent {
+	format, err := FlowLogFormatNew("${version} ${account-id} ${interface-id} ${srcaddr} ${dstaddr} ${srcport} ${dstport} ${protocol} ${packets} ${bytes} ${start} ${end} ${action} ${log-status} ${tcp-flags}")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	events := []*FlowLogEvent{}
+	for _, message := range []string{
+		"5 123456789012 eni-web 203.0.113.7 10.0.0.5 51000 443 6 10 8000 1735776000 1735776060 ACCEPT OK 3",
+		"5 123456789012 eni-web 10.0.0.5 10.0.1.9 40000 53 17 2 150 1735776000 1735776060 ACCEPT OK 0",
+		"5 123456789012 eni-db 198.51.100.9 10.0.1.9 0 0 1 1 84 1735776030 1735776090 REJECT OK 0",
+		"5 123456789012 eni-db 2001:db8::1 2001:db8::2 40001 5432 6 1 80 1735776030 1735776090 REJECT OK 2",
+		"5 123456789012 eni-db - - - - - - - 1735776000 1735776060 - NODATA -",
+	} {
+		event, err := format.Parse(message)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+func TestFlowLogWriter(t *testing.T) {
+	t.Run("Zeek conn.log", func(t *testing.T) {
+		output := &bytes.Buffer{}
+		writer, err := FlowLogWriterNew(output, ExportFormatZeek)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		for _, event := range exportEvents(t) {
+			if err := writer.Write(event); err != nil {
+				t.Fatalf("%v", err)
+			}
+		}
+		if err := writer.Close(); err != nil {
+			t.Fatalf("%v", err)
+		}
+
+		lines := strings.Split(strings.TrimSuffix(output.String(), "\n"), "\n")
+		if len(lines) != 13 || lines[5] != "#open\t2025-01-02-00-00-00" || lines[12] != "#close\t2025-01-02-00-01-30" {
+			t.Fatalf("unexpected log:\n%s", output)
+		}
+		fields := strings.Split(lines[6], "\t")[1:]
+		if len(fields) != len(zeekConnFields) || len(strings.Split(lines[7], "\t")[1:]) != len(fields) {
+			t.Fatalf("unexpected header:\n%s\n%s", lines[6], lines[7])
+		}
+		record := strings.Split(lines[8], "\t")
+		if len(record) != len(fields) || record[0] != "1735776000.000000" || !strings.HasPrefix(record[1], "C") ||
+			strings.Join(record[2:9], " ") != "203.0.113.7 51000 10.0.0.5 443 tcp - 60.000000" ||
+			strings.Join(record[11:], " ") != "OTH F T 0 SF 10 8000 0 0 (empty)" {
+			t.Errorf("unexpected record: %v", record)
+		}
+		if !strings.Contains(lines[10], "\ticmp\t") || !strings.Contains(lines[10], "\tS0\t") || !strings.Contains(lines[11], "2001:db8::1\t40001") {
+			t.Errorf("unexpected records:\n%s\n%s", lines[10], lines[11])
+		}
+		if writer.(*ZeekConnWriter).Skipped != 1 || writer.(*ZeekConnWriter).Records != 4 {
+			t.Errorf("unexpected counters: %+v", writer)
+		}
+	})
+
+	t.Run("Zeek JSON", func(t *testing.T) {
+		output := &bytes.Buffer{}
+		writer, err := FlowLogWriterNew(output, ExportFormatZeekJSON)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if err := writer.Write(exportEvents(t)[1]); err != nil {
+			t.Fatalf("%v", err)
+		}
+		record := map[string]any{}
+		if err := json.Unmarshal(output.Bytes(), &record); err != nil {
+			t.Fatalf("%v: %s", err, output)
+		}
+		if record["id.resp_p"] != 53.0 || record["proto"] != "udp" || record["orig_ip_bytes"] != 150.0 || record["local_resp"] != true {
+			t.Errorf("unexpected record: %v", record)
+		}
+		if _, found := record["service"]; found {
+			t.Errorf("unset fields must be omitted: %v", record)
+		}
+	})
+
+	t.Run("pcap-ng", func(t *testing.T) {
+		output := &bytes.Buffer{}
+		writer, err := FlowLogWriterNew(output, ExportFormatPcapNG)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		for _, event := range exportEvents(t) {
+			if err := writer.Write(event); err != nil {
+				t.Fatalf("%v", err)
+			}
+		}
+		if err := writer.Close(); err != nil {
+			t.Fatalf("%v", err)
+		}
+
+		data := output.Bytes()
+		blockTypes := []uint32{}
+		packets := [][]byte{}
+		comments := []string{}
+		for len(data) > 0 {
+			blockType, length := binary.LittleEndian.Uint32(data), binary.LittleEndian.Uint32(data[4:])
+			if length%4 != 0 || int(length) > len(data) || binary.LittleEndian.Uint32(data[length-4:]) != length {
+				t.Fatalf("invalid block %d length %d", blockType, length)
+			}
+			blockTypes = append(blockTypes, blockType)
+			if blockType == pcapNGEnhancedPacketBlock {
+				captured := binary.LittleEndian.Uint32(data[20:])
+				packets = append(packets, data[28:28+captured])
+				option := data[28+(captured+3)/4*4:]
+				comments = append(comments, string(option[4:4+binary.LittleEndian.Uint16(option[2:])]))
+			}
+			data = data[length:]
+		}
+		expectedTypes := []uint32{pcapNGSectionHeaderBlock, pcapNGInterfaceDescription, pcapNGEnhancedPacketBlock, pcapNGEnhancedPacketBlock,
+			pcapNGInterfaceDescription, pcapNGEnhancedPacketBlock, pcapNGEnhancedPacketBlock}
+		if len(blockTypes) != len(expectedTypes) {
+			t.Fatalf("unexpected blocks: %v", blockTypes)
+		}
+		for index := range expectedTypes {
+			if blockTypes[index] != expectedTypes[index] {
+				t.Fatalf("unexpected blocks: %v", blockTypes)
+			}
+		}
+
+		tcp := packets[0]
+		if len(tcp) != 40 || tcp[9] != 6 || checksum(tcp[:20], 0) != 0 || binary.BigEndian.Uint16(tcp[22:]) != 443 || tcp[33] != 3 {
+			t.Errorf("unexpected TCP packet: %x", tcp)
+		}
+		pseudo := append(append([]byte{}, tcp[12:20]...), tcp[20:]...)
+		if checksum(pseudo, 6+20) != 0 {
+			t.Errorf("invalid TCP checksum: %x", tcp)
+		}
+		if udp := packets[1]; len(udp) != 28 || checksum(append(append([]byte{}, udp[12:20]...), udp[20:]...), 17+8) != 0 {
+			t.Errorf("unexpected UDP packet: %x", udp)
+		}
+		if icmp := packets[2]; len(icmp) != 28 || icmp[20] != 8 || checksum(icmp[20:], 0) != 0 {
+			t.Errorf("unexpected ICMP packet: %x", icmp)
+		}
+		if ipv6 := packets[3]; len(ipv6) != 60 || ipv6[0]>>4 != 6 || ipv6[6] != 6 || checksum(append(append([]byte{}, ipv6[8:40]...), ipv6[40:]...), 6+20) != 0 {
+			t.Errorf("unexpected IPv6 packet: %x", ipv6)
+		}
+		if comments[0] != "flow-log eni-web ACCEPT packets=10 bytes=8000 start=2025-01-02T00:00:00Z end=2025-01-02T00:01:00Z tcp-flags=3" {
+			t.Errorf("unexpected comment: %s", comments[0])
+		}
+		if writer.(*PcapNGWriter).Packets != 4 || writer.(*PcapNGWriter).Skipped != 1 {
+			t.Errorf("unexpected counters: %+v", writer)
+		}
+	})
+
+	t.Run("Export", func(t *testing.T) {
+		exportFilePath := filepath.Join(t.TempDir(), "flows.pcapng")
+		processed := 0
+		awsTCPDump := &AWSTCPDump{Config: &AWSTCPDumpConfig{ExportFilePath: exportFilePath},
+			EventProcessor: func(event *FlowLogEvent) error { processed++; return nil }}
+		writer, err := awsTCPDump.StartExport()
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		for _, event := range exportEvents(t) {
+			if err := awsTCPDump.EventProcessor(event); err != nil {
+				t.Fatalf("%v", err)
+			}
+		}
+		if err := writer.Close(); err != nil {
+			t.Fatalf("%v", err)
+		}
+		data, err := os.ReadFile(exportFilePath)
+		if err != nil || processed != 5 || binary.LittleEndian.Uint32(data) != pcapNGSectionHeaderBlock {
+			t.Errorf("unexpected export: %v, %d processed", err, processed)
+		}
+
+		if _, err := FlowLogWriterNew(&bytes.Buffer{}, "pcap"); err == nil {
+			t.Errorf("expected an error for an unknown format")
+		}
+	})
+}
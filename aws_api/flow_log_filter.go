@@ -0,0 +1,377 @@
+package aws_api
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// FlowLogFilter is a compiled tcpdump like filter expression, e.g.
+// "src net 10.0.0.0/8 and dst port 443 and action reject", "not host 1.2.3.4", "tcp and (port 22 or 3389)".
+//
+// Primitives: [src|dst] host ADDR, [src|dst] net CIDR, [src|dst] port N, [src|dst] portrange N-M,
+// proto tcp|udp|icmp|icmp6|N (or tcp, udp, icmp, icmp6), action accept|reject (or accept, reject),
+// interface ID, vpc ID, subnet ID, instance ID, account ID, direction ingress|egress,
+// bytes|packets <|<=|=|!=|>=|> N.
+// Operators: not (!), and (&&), or (||) and parentheses. A bare value repeats the previous primitive: "host a or b".
+type FlowLogFilter struct {
+	Expression string
+	match      flowLogPredicate
+}
+
+type flowLogPredicate func(event *FlowLogEvent) bool
+
+var protocolNumbers = map[string]string{"icmp": "1", "tcp": "6", "udp": "17", "icmp6": "58"}
+
+// attributeFilters are the primitives comparing a FlowLogEvent string field.
+var attributeFilters = map[string]func(event *FlowLogEvent) string{
+	"interface": func(event *FlowLogEvent) string { return event.InterfaceID },
+	"vpc":       func(event *FlowLogEvent) string { return event.VpcID },
+	"subnet":    func(event *FlowLogEvent) string { return event.SubnetID },
+	"instance":  func(event *FlowLogEvent) string { return event.InstanceID },
+	"account":   func(event *FlowLogEvent) string { return event.AccoundID },
+	"direction": func(event *FlowLogEvent) string { return event.FlowDirection },
+	"action":    func(event *FlowLogEvent) string { return event.Action },
+}
+
+var counterFilters = map[string]func(event *FlowLogEvent) int{
+	"bytes":   func(event *FlowLogEvent) int { return event.Bytes },
+	"packets": func(event *FlowLogEvent) int { return event.Packets },
+}
+
+var filterKeywords = map[string]bool{"(": true, ")": true, "!": true, "not": true, "and": true, "&&": true, "or": true, "||": true,
+	"src": true, "dst": true, "host": true, "net": true, "port": true, "portrange": true, "proto": true, "accept": true, "reject": true}
+
+func isFilterKeyword(token string) bool {
+	token = strings.ToLower(token)
+	_, attribute := attributeFilters[token]
+	_, counter := counterFilters[token]
+	_, protocol := protocolNumbers[token]
+	return filterKeywords[token] || attribute || counter || protocol
+}
+
+// FlowLogFilterNew compiles the expression once, an empty expression matches all the events.
+func FlowLogFilterNew(expression string) (*FlowLogFilter, error) {
+	errorPrefix := "[flow_log_filter:FlowLogFilterNew]"
+	tokens, err := tokenizeFilter(expression)
+	if err != nil {
+		return nil, fmt.Errorf("%s %w", errorPrefix, err)
+	}
+	if len(tokens) == 0 {
+		return &FlowLogFilter{Expression: expression, match: func(*FlowLogEvent) bool { return true }}, nil
+	}
+
+	parser := &filterParser{tokens: tokens}
+	match, err := parser.parseOr()
+	if err == nil && parser.position < len(tokens) {
+		err = parser.errorf("unexpected '%s'", tokens[parser.position])
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", errorPrefix, expression, err)
+	}
+	return &FlowLogFilter{Expression: expression, match: match}, nil
+}
+
+func (filter *FlowLogFilter) Match(event *FlowLogEvent) bool {
+	return filter.match(event)
+}
+
+// EventsFilter is the AWSTCPDump.EventsFilter of the expression: not matching events are dropped.
+func (filter *FlowLogFilter) EventsFilter(event *FlowLogEvent) (*FlowLogEvent, error) {
+	if filter.match(event) {
+		return event, nil
+	}
+	return nil, nil
+}
+
+func tokenizeFilter(expression string) ([]string, error) {
+	tokens := []string{}
+	for index := 0; index < len(expression); {
+		char := expression[index]
+		switch {
+		case char == ' ' || char == '\t' || char == '\n':
+			index++
+		case char == '(' || char == ')':
+			tokens = append(tokens, string(char))
+			index++
+		case strings.HasPrefix(expression[index:], "&&") || strings.HasPrefix(expression[index:], "||") ||
+			strings.HasPrefix(expression[index:], "!=") || strings.HasPrefix(expression[index:], ">=") || strings.HasPrefix(expression[index:], "<="):
+			tokens = append(tokens, expression[index:index+2])
+			index += 2
+		case strings.ContainsRune("!<>=", rune(char)):
+			tokens = append(tokens, string(char))
+			index++
+		case char == '&' || char == '|':
+			return nil, fmt.Errorf("unexpected '%c' at %d", char, index)
+		default:
+			end := index
+			for end < len(expression) && !strings.ContainsRune(" \t\n()!<>=&|", rune(expression[end])) {
+				end++
+			}
+			tokens = append(tokens, expression[index:end])
+			index = end
+		}
+	}
+	return tokens, nil
+}
+
+type filterParser struct {
+	tokens   []string
+	position int
+	// Builds the previous primitive with another value, for the bare values.
+	last func(value string) (flowLogPredicate, error)
+}
+
+func (parser *filterParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("token %d: %s", parser.position+1, fmt.Sprintf(format, args...))
+}
+
+func (parser *filterParser) peek() string {
+	if parser.position < len(parser.tokens) {
+		return strings.ToLower(parser.tokens[parser.position])
+	}
+	return ""
+}
+
+func (parser *filterParser) next() (string, error) {
+	if parser.position >= len(parser.tokens) {
+		return "", parser.errorf("unexpected end of expression")
+	}
+	parser.position++
+	return parser.tokens[parser.position-1], nil
+}
+
+func (parser *filterParser) parseOr() (flowLogPredicate, error) {
+	left, err := parser.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for parser.peek() == "or" || parser.peek() == "||" {
+		parser.position++
+		right, err := parser.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orPredicate(left, right)
+	}
+	return left, nil
+}
+
+func (parser *filterParser) parseAnd() (flowLogPredicate, error) {
+	left, err := parser.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for parser.peek() == "and" || parser.peek() == "&&" {
+		parser.position++
+		right, err := parser.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = andPredicate(left, right)
+	}
+	return left, nil
+}
+
+func orPredicate(left, right flowLogPredicate) flowLogPredicate {
+	return func(event *FlowLogEvent) bool { return left(event) || right(event) }
+}
+
+func andPredicate(left, right flowLogPredicate) flowLogPredicate {
+	return func(event *FlowLogEvent) bool { return left(event) && right(event) }
+}
+
+func (parser *filterParser) parseNot() (flowLogPredicate, error) {
+	if parser.peek() == "not" || parser.peek() == "!" {
+		parser.position++
+		predicate, err := parser.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return func(event *FlowLogEvent) bool { return !predicate(event) }, nil
+	}
+	return parser.parsePrimary()
+}
+
+func (parser *filterParser) parsePrimary() (flowLogPredicate, error) {
+	token := parser.peek()
+	if token == "(" {
+		parser.position++
+		predicate, err := parser.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if parser.peek() != ")" {
+			return nil, parser.errorf("expected ')'")
+		}
+		parser.position++
+		return predicate, nil
+	}
+	if token == "" {
+		return nil, parser.errorf("unexpected end of expression")
+	}
+
+	if !isFilterKeyword(token) {
+		if parser.last == nil {
+			return nil, parser.errorf("unexpected '%s'", parser.tokens[parser.position])
+		}
+		parser.position++
+		return parser.last(parser.tokens[parser.position-1])
+	}
+	parser.position++
+
+	if protocol, found := protocolNumbers[token]; found {
+		return protocolPredicate(protocol), nil
+	}
+	if token == "accept" || token == "reject" {
+		return attributePredicate("action", token), nil
+	}
+
+	var build func(value string) (flowLogPredicate, error)
+	switch {
+	case token == "src" || token == "dst":
+		kind := parser.peek()
+		if kind != "host" && kind != "net" && kind != "port" && kind != "portrange" {
+			return nil, parser.errorf("expected host, net, port or portrange after %s", token)
+		}
+		parser.position++
+		build = addressBuilder(token, kind)
+	case token == "host" || token == "net" || token == "port" || token == "portrange":
+		build = addressBuilder("", token)
+	case token == "proto":
+		build = func(value string) (flowLogPredicate, error) {
+			if protocol, found := protocolNumbers[strings.ToLower(value)]; found {
+				return protocolPredicate(protocol), nil
+			}
+			if _, err := strconv.Atoi(value); err != nil {
+				return nil, fmt.Errorf("invalid protocol: %s", value)
+			}
+			return protocolPredicate(value), nil
+		}
+	case counterFilters[token] != nil:
+		return parser.parseComparison(token)
+	case attributeFilters[token] != nil:
+		build = func(value string) (flowLogPredicate, error) { return attributePredicate(token, value), nil }
+	default:
+		return nil, parser.errorf("unexpected '%s'", parser.tokens[parser.position-1])
+	}
+
+	value, err := parser.next()
+	if err != nil {
+		return nil, err
+	}
+	predicate, err := build(value)
+	if err != nil {
+		return nil, parser.errorf("%v", err)
+	}
+	parser.last = build
+	return predicate, nil
+}
+
+func (parser *filterParser) parseComparison(field string) (flowLogPredicate, error) {
+	operator, err := parser.next()
+	if err != nil {
+		return nil, err
+	}
+	value, err := parser.next()
+	if err != nil {
+		return nil, err
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, parser.errorf("invalid %s value: %s", field, value)
+	}
+
+	comparisons := map[string]func(left, right int) bool{
+		"<":  func(left, right int) bool { return left < right },
+		"<=": func(left, right int) bool { return left <= right },
+		"=":  func(left, right int) bool { return left == right },
+		"!=": func(left, right int) bool { return left != right },
+		">=": func(left, right int) bool { return left >= right },
+		">":  func(left, right int) bool { return left > right },
+	}
+	compare, found := comparisons[operator]
+	if !found {
+		return nil, parser.errorf("invalid comparison: %s", operator)
+	}
+	getter := counterFilters[field]
+	return func(event *FlowLogEvent) bool { return compare(getter(event), number) }, nil
+}
+
+func protocolPredicate(protocol string) flowLogPredicate {
+	return func(event *FlowLogEvent) bool { return event.Protocol == protocol }
+}
+
+func attributePredicate(attribute, value string) flowLogPredicate {
+	getter := attributeFilters[attribute]
+	return func(event *FlowLogEvent) bool { return strings.EqualFold(getter(event), value) }
+}
+
+// addressBuilder builds the host, net, port and portrange primitives, direction is src, dst or empty for either.
+func addressBuilder(direction, kind string) func(value string) (flowLogPredicate, error) {
+	return func(value string) (flowLogPredicate, error) {
+		var matchAddr func(ip net.IP) bool
+		var matchPort func(port int) bool
+
+		switch kind {
+		case "host":
+			host := net.ParseIP(value)
+			if host == nil {
+				return nil, fmt.Errorf("invalid host: %s", value)
+			}
+			matchAddr = func(ip net.IP) bool { return host.Equal(ip) }
+		case "net":
+			_, network, err := net.ParseCIDR(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid net: %s", value)
+			}
+			matchAddr = func(ip net.IP) bool { return ip != nil && network.Contains(ip) }
+		case "port":
+			port, err := strconv.Atoi(value)
+			if err != nil || port < 0 || port > 65535 {
+				return nil, fmt.Errorf("invalid port: %s", value)
+			}
+			matchPort = func(eventPort int) bool { return eventPort == port }
+		case "portrange":
+			bounds := strings.Split(value, "-")
+			if len(bounds) != 2 {
+				return nil, fmt.Errorf("invalid portrange: %s", value)
+			}
+			low, lowErr := strconv.Atoi(bounds[0])
+			high, highErr := strconv.Atoi(bounds[1])
+			if lowErr != nil || highErr != nil || low > high {
+				return nil, fmt.Errorf("invalid portrange: %s", value)
+			}
+			matchPort = func(eventPort int) bool { return eventPort >= low && eventPort <= high }
+		}
+
+		// Records without traffic have no addresses and ports.
+		src := func(event *FlowLogEvent) bool {
+			if event.SrcAddr == nil {
+				return false
+			}
+			if matchAddr != nil {
+				return matchAddr(event.SrcAddr)
+			}
+			return matchPort(event.SrcPort)
+		}
+		dst := func(event *FlowLogEvent) bool {
+			if event.DstAddr == nil {
+				return false
+			}
+			if matchAddr != nil {
+				return matchAddr(event.DstAddr)
+			}
+			return matchPort(event.DstPort)
+		}
+
+		switch direction {
+		case "src":
+			return src, nil
+		case "dst":
+			return dst, nil
+		}
+		return orPredicate(src, dst), nil
+	}
+}
@@ -0,0 +1,112 @@
+package aws_api
+
+import (
+	"testing"
+)
+
+func filterEvents(t *testing.T) map[string]*FlowLogEvent {
+	format, err := FlowLogFormatNew("${interface-id} ${srcaddr} ${dstaddr} ${srcport} ${dstport} ${protocol} ${packets} ${bytes} ${action} ${flow-direction} ${log-status}")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	events := map[string]*FlowLogEvent{}
+	for name, message := range map[string]string{
+		"https":  "eni-1 10.0.0.5 203.0.113.7 51000 443 6 10 8000 ACCEPT egress OK",
+		"ssh":    "eni-1 198.51.100.9 10.0.0.5 40000 22 6 1 40 REJECT ingress OK",
+		"dns":    "eni-2 10.0.1.9 1.2.3.4 53000 53 17 2 150 ACCEPT egress OK",
+		"nodata": "eni-3 - - - - - - - - - NODATA",
+	} {
+		event, err := format.Parse(message)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		events[name] = event
+	}
+	return events
+}
+
+func TestFlowLogFilter(t *testing.T) {
+	events := filterEvents(t)
+	tests := []struct {
+		expression string
+		expected   []string
+	}{
+		{"", []string{"dns", "https", "nodata", "ssh"}},
+		{"src net 10.0.0.0/8 and dst port 443 and action REJECT", []string{}},
+		{"src net 10.0.0.0/8 and dst port 443 and action accept", []string{"https"}},
+		{"not host 1.2.3.4", []string{"https", "nodata", "ssh"}},
+		{"!host 1.2.3.4 && !reject", []string{"https", "nodata"}},
+		{"tcp and (port 22 or 443)", []string{"https", "ssh"}},
+		{"host 203.0.113.7 or 1.2.3.4", []string{"dns", "https"}},
+		{"src portrange 40000-52000 and bytes>=100", []string{"https"}},
+		{"proto 17 || packets = 1", []string{"dns", "ssh"}},
+		{"interface ENI-1 and direction ingress", []string{"ssh"}},
+		{"dst net 10.0.0.0/24 or udp", []string{"dns", "ssh"}},
+		{"not (src host 10.0.0.5 or dst host 10.0.0.5)", []string{"dns", "nodata"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			filter, err := FlowLogFilterNew(tt.expression)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			matched := []string{}
+			for _, name := range []string{"dns", "https", "nodata", "ssh"} {
+				if filter.Match(events[name]) {
+					matched = append(matched, name)
+				}
+			}
+			if len(matched) != len(tt.expected) {
+				t.Fatalf("got %v, expected %v", matched, tt.expected)
+			}
+			for index := range matched {
+				if matched[index] != tt.expected[index] {
+					t.Errorf("got %v, expected %v", matched, tt.expected)
+				}
+			}
+		})
+	}
+
+	t.Run("Invalid expressions", func(t *testing.T) {
+		for _, expression := range []string{"host", "host 10.0.0.300", "net 10.0.0.0", "port 70000", "portrange 10-5", "src tcp",
+			"(port 22", "port 22)", "bytes ~ 10", "bytes > many", "proto smtp", "port 22 or", "10.0.0.5", "port 22 & port 23"} {
+			if _, err := FlowLogFilterNew(expression); err == nil {
+				t.Errorf("expected an error: %s", expression)
+			}
+		}
+	})
+}
+
+func TestCompileEventsFilter(t *testing.T) {
+	t.Run("Valid run", func(t *testing.T) {
+		events := filterEvents(t)
+		awsTCPDump := &AWSTCPDump{Config: &AWSTCPDumpConfig{AddrFilters: []string{"1.2.3.4", "198.51.100.0/24"}, Filter: "not udp"}}
+		if expression := awsTCPDump.FilterExpression(); expression != "(host 1.2.3.4 or net 198.51.100.0/24) and (not udp)" {
+			t.Errorf("unexpected expression: %s", expression)
+		}
+		if err := awsTCPDump.CompileEventsFilter(); err != nil {
+			t.Fatalf("%v", err)
+		}
+		for name, event := range events {
+			filtered, err := awsTCPDump.EventsFilter(event)
+			if err != nil || (filtered != nil) != (name == "ssh") {
+				t.Errorf("unexpected filter result of %s: %v, %v", name, filtered, err)
+			}
+		}
+	})
+
+	t.Run("Subnet filter", func(t *testing.T) {
+		events := filterEvents(t)
+		filter := (&AWSTCPDump{}).GenerateSubnetFilter([]string{"10.0.0.0/24"})
+		if event, _ := filter(events["dns"]); event != nil {
+			t.Errorf("events sent outside the subnet must be dropped")
+		}
+		if event, _ := filter(events["ssh"]); event == nil {
+			t.Errorf("events sent to the subnet must be kept")
+		}
+		if _, err := (&AWSTCPDump{}).GenerateSubnetFilter([]string{"10.0.0.0"})(events["ssh"]); err == nil {
+			t.Errorf("expected an invalid subnet error")
+		}
+	})
+}
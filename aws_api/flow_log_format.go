@@ -0,0 +1,150 @@
+package aws_api
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// DefaultFlowLogFormat is the v2 format AWS uses when a flow log is created without a custom format.
+const DefaultFlowLogFormat = "${version} ${account-id} ${interface-id} ${srcaddr} ${dstaddr} ${srcport} ${dstport} ${protocol} ${packets} ${bytes} ${start} ${end} ${action} ${log-status}"
+
+type flowLogFieldSetter func(event *FlowLogEvent, value string) error
+
+func intSetter(set func(event *FlowLogEvent, value int)) flowLogFieldSetter {
+	return func(event *FlowLogEvent, value string) error {
+		intValue, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		set(event, intValue)
+		return nil
+	}
+}
+
+func ipSetter(set func(event *FlowLogEvent, value net.IP)) flowLogFieldSetter {
+	return func(event *FlowLogEvent, value string) error {
+		ip := net.ParseIP(value)
+		if ip == nil {
+			return fmt.Errorf("invalid address: %s", value)
+		}
+		set(event, ip)
+		return nil
+	}
+}
+
+func stringSetter(set func(event *FlowLogEvent, value string)) flowLogFieldSetter {
+	return func(event *FlowLogEvent, value string) error {
+		set(event, value)
+		return nil
+	}
+}
+
+// flowLogFields maps the v2-v5 flow log fields to the FlowLogEvent setters.
+var flowLogFields = map[string]flowLogFieldSetter{
+	"version":      stringSetter(func(event *FlowLogEvent, value string) { event.Version = value }),
+	"account-id":   stringSetter(func(event *FlowLogEvent, value string) { event.AccoundID = value }),
+	"interface-id": stringSetter(func(event *FlowLogEvent, value string) { event.InterfaceID = value }),
+	"srcaddr":      ipSetter(func(event *FlowLogEvent, value net.IP) { event.SrcAddr = value }),
+	"dstaddr":      ipSetter(func(event *FlowLogEvent, value net.IP) { event.DstAddr = value }),
+	"srcport":      intSetter(func(event *FlowLogEvent, value int) { event.SrcPort = value }),
+	"dstport":      intSetter(func(event *FlowLogEvent, value int) { event.DstPort = value }),
+	"protocol":     stringSetter(func(event *FlowLogEvent, value string) { event.Protocol = value }),
+	"packets":      intSetter(func(event *FlowLogEvent, value int) { event.Packets = value }),
+	"bytes":        intSetter(func(event *FlowLogEvent, value int) { event.Bytes = value }),
+	"start":        intSetter(func(event *FlowLogEvent, value int) { event.Start = value }),
+	"end":          intSetter(func(event *FlowLogEvent, value int) { event.End = value }),
+	"action":       stringSetter(func(event *FlowLogEvent, value string) { event.Action = value }),
+	"log-status":   stringSetter(func(event *FlowLogEvent, value string) { event.LogStatus = value }),
+
+	"vpc-id":      stringSetter(func(event *FlowLogEvent, value string) { event.VpcID = value }),
+	"subnet-id":   stringSetter(func(event *FlowLogEvent, value string) { event.SubnetID = value }),
+	"instance-id": stringSetter(func(event *FlowLogEvent, value string) { event.InstanceID = value }),
+	"tcp-flags":   intSetter(func(event *FlowLogEvent, value int) { event.TCPFlags = value }),
+	"type":        stringSetter(func(event *FlowLogEvent, value string) { event.Type = value }),
+	"pkt-srcaddr": ipSetter(func(event *FlowLogEvent, value net.IP) { event.PktSrcAddr = value }),
+	"pkt-dstaddr": ipSetter(func(event *FlowLogEvent, value net.IP) { event.PktDstAddr = value }),
+
+	"region":           stringSetter(func(event *FlowLogEvent, value string) { event.Region = value }),
+	"az-id":            stringSetter(func(event *FlowLogEvent, value string) { event.AzID = value }),
+	"sublocation-type": stringSetter(func(event *FlowLogEvent, value string) { event.SublocationType = value }),
+	"sublocation-id":   stringSetter(func(event *FlowLogEvent, value string) { event.SublocationID = value }),
+
+	"pkt-src-aws-service": stringSetter(func(event *FlowLogEvent, value string) { event.PktSrcAWSService = value }),
+	"pkt-dst-aws-service": stringSetter(func(event *FlowLogEvent, value string) { event.PktDstAWSService = value }),
+	"flow-direction":      stringSetter(func(event *FlowLogEvent, value string) { event.FlowDirection = value }),
+	"traffic-path":        intSetter(func(event *FlowLogEvent, value int) { event.TrafficPath = value }),
+}
+
+// FlowLogFormat parses the records of a flow log by its log format string.
+type FlowLogFormat struct {
+	Fields  []string
+	setters []flowLogFieldSetter
+}
+
+// FlowLogFormatNew parses a log format string, e.g. "${version} ${vpc-id} ${srcaddr} ${flow-direction}".
+func FlowLogFormatNew(format string) (*FlowLogFormat, error) {
+	errorPrefix := "[flow_log_format:FlowLogFormatNew]"
+	fields := []string{}
+	for _, token := range strings.Fields(format) {
+		if !strings.HasPrefix(token, "${") || !strings.HasSuffix(token, "}") {
+			return nil, fmt.Errorf("%s expected ${field}, found '%s' in: %s", errorPrefix, token, format)
+		}
+		fields = append(fields, token[2:len(token)-1])
+	}
+	return flowLogFormatNewWithFields(fields, errorPrefix)
+}
+
+// FlowLogFormatFromHeader parses the field names line S3 exports start with.
+func FlowLogFormatFromHeader(header string) (*FlowLogFormat, error) {
+	return flowLogFormatNewWithFields(strings.Fields(header), "[flow_log_format:FlowLogFormatFromHeader]")
+}
+
+func flowLogFormatNewWithFields(fields []string, errorPrefix string) (*FlowLogFormat, error) {
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("%s empty flow log format", errorPrefix)
+	}
+	ret := &FlowLogFormat{Fields: fields}
+	seen := map[string]bool{}
+	for _, field := range fields {
+		setter, found := flowLogFields[field]
+		if !found {
+			return nil, fmt.Errorf("%s unsupported flow log field: %s", errorPrefix, field)
+		}
+		if seen[field] {
+			return nil, fmt.Errorf("%s duplicate flow log field: %s", errorPrefix, field)
+		}
+		seen[field] = true
+		ret.setters = append(ret.setters, setter)
+	}
+	return ret, nil
+}
+
+// String returns the log format string to create a flow log with.
+func (format *FlowLogFormat) String() string {
+	tokens := []string{}
+	for _, field := range format.Fields {
+		tokens = append(tokens, "${"+field+"}")
+	}
+	return strings.Join(tokens, " ")
+}
+
+// Parse fills a FlowLogEvent from a record, "-" values are left unset.
+func (format *FlowLogFormat) Parse(message string) (*FlowLogEvent, error) {
+	values := strings.Fields(message)
+	if len(values) != len(format.Fields) {
+		return nil, fmt.Errorf("expected %d flow log fields, found %d: %s", len(format.Fields), len(values), message)
+	}
+
+	ret := &FlowLogEvent{}
+	for index, value := range values {
+		if value == "-" {
+			continue
+		}
+		if err := format.setters[index](ret, value); err != nil {
+			return nil, fmt.Errorf("field %s: %w", format.Fields[index], err)
+		}
+	}
+	return ret, nil
+}
@@ -0,0 +1,96 @@
+package aws_api
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+)
+
+func TestFlowLogFormatParse(t *testing.T) {
+	t.Run("Default format", func(t *testing.T) {
+		format, err := FlowLogFormatNew(DefaultFlowLogFormat)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		event, err := format.Parse("2 123456789012 eni-1 10.0.0.5 203.0.113.7 443 51000 6 10 8000 1735776000 1735776060 ACCEPT OK")
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if event.InterfaceID != "eni-1" || event.SrcAddr.String() != "10.0.0.5" || event.DstPort != 51000 || event.Bytes != 8000 || event.Action != "ACCEPT" {
+			t.Errorf("unexpected event: %+v", event)
+		}
+		if format.String() != DefaultFlowLogFormat {
+			t.Errorf("unexpected format: %s", format)
+		}
+	})
+
+	t.Run("Custom v5 format", func(t *testing.T) {
+		format, err := FlowLogFormatNew("${vpc-id} ${subnet-id} ${instance-id} ${interface-id} ${srcaddr} ${pkt-srcaddr} ${dstaddr} ${pkt-dstaddr} " +
+			"${flow-direction} ${tcp-flags} ${type} ${region} ${az-id} ${sublocation-type} ${sublocation-id} ${pkt-src-aws-service} ${pkt-dst-aws-service} ${traffic-path}")
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		event, err := format.Parse("vpc-1 subnet-1 i-1 eni-1 10.0.0.5 10.0.0.5 10.0.1.9 52.94.5.1 egress 19 IPv4 us-east-1 use1-az1 - - - DYNAMODB 1")
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if event.VpcID != "vpc-1" || event.SubnetID != "subnet-1" || event.InstanceID != "i-1" || event.PktDstAddr.String() != "52.94.5.1" ||
+			event.FlowDirection != "egress" || event.TCPFlags != 19 || event.Type != "IPv4" || event.AzID != "use1-az1" ||
+			event.SublocationType != "" || event.PktDstAWSService != "DYNAMODB" || event.TrafficPath != 1 {
+			t.Errorf("unexpected event: %+v", event)
+		}
+	})
+
+	t.Run("Invalid records", func(t *testing.T) {
+		format, err := FlowLogFormatNew("${srcaddr} ${dstport}")
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		for _, message := range []string{"10.0.0.5", "10.0.0.300 22", "10.0.0.5 ssh"} {
+			if _, err := format.Parse(message); err == nil {
+				t.Errorf("expected an error: %s", message)
+			}
+		}
+	})
+
+	t.Run("Invalid formats", func(t *testing.T) {
+		for _, logFormat := range []string{"", "${version} account-id", "${version} ${ecs-cluster-arn}", "${srcaddr} ${srcaddr}"} {
+			if _, err := FlowLogFormatNew(logFormat); err == nil {
+				t.Errorf("expected an error: %s", logFormat)
+			}
+		}
+	})
+}
+
+func TestParseEventLogFormat(t *testing.T) {
+	t.Run("Valid run", func(t *testing.T) {
+		awsTCPDump := &AWSTCPDump{Config: &AWSTCPDumpConfig{LogFormat: "${interface-id} ${flow-direction} ${bytes}"}}
+		message := "eni-1 ingress 1200"
+		event, err := awsTCPDump.ParseEvent(&cloudwatchlogs.OutputLogEvent{Message: &message})
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if event.InterfaceID != "eni-1" || event.FlowDirection != "ingress" || event.Bytes != 1200 {
+			t.Errorf("unexpected event: %+v", event)
+		}
+	})
+
+	t.Run("Offline header", func(t *testing.T) {
+		filePath := filepath.Join(t.TempDir(), "custom.log")
+		writeFlowLogFile(t, filePath, false, "interface-id flow-direction pkt-srcaddr bytes", "eni-1 ingress 198.51.100.9 1200")
+
+		events := []*FlowLogEvent{}
+		awsTCPDump := &AWSTCPDump{Config: &AWSTCPDumpConfig{}, EventProcessor: func(event *FlowLogEvent) error {
+			events = append(events, event)
+			return nil
+		}}
+		report, err := awsTCPDump.AnalyzeFlowLogFiles([]string{filePath})
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if report.Headers != 1 || len(events) != 1 || events[0].PktSrcAddr.String() != "198.51.100.9" {
+			t.Errorf("file must be parsed by its header: %s, %+v", report, events)
+		}
+	})
+}
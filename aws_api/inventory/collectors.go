@@ -0,0 +1,697 @@
+package aws_api
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	clients "github.com/AlexeyBeley/go_misc/aws_api/clients"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/elasticache"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+)
+
+// Collector lists one resource type of a scope and normalizes it to Resources.
+type Collector struct {
+	Type    string
+	Collect func(ctx context.Context, scope *Scope, add func(*Resource)) error
+}
+
+// Collectors in the snapshot order.
+var Collectors = []Collector{
+	{Type: "ec2:network-interface", Collect: collectNetworkInterfaces},
+	{Type: "ec2:instance", Collect: collectInstances},
+	{Type: "ec2:natgateway", Collect: collectNatGateways},
+	{Type: "ec2:elastic-ip", Collect: collectAddresses},
+	{Type: "ec2:volume", Collect: collectVolumes},
+	{Type: "ec2:snapshot", Collect: collectSnapshots},
+	{Type: "ec2:image", Collect: collectImages},
+	{Type: "ec2:security-group", Collect: collectSecurityGroups},
+	{Type: "ec2:launch-template", Collect: collectLaunchTemplates},
+	{Type: "ec2:key-pair", Collect: collectKeyPairs},
+	{Type: "elasticloadbalancing:loadbalancer", Collect: collectLoadBalancers},
+	{Type: "elasticloadbalancing:targetgroup", Collect: collectTargetGroups},
+	{Type: "autoscaling:autoScalingGroup", Collect: collectAutoScalingGroups},
+	{Type: "rds:cluster", Collect: collectRDSClusters},
+	{Type: "rds:db", Collect: collectRDSInstances},
+	{Type: "s3:bucket", Collect: collectBuckets},
+	{Type: "logs:log-group", Collect: collectLogGroups},
+	{Type: "ecs:cluster", Collect: collectECSClusters},
+	{Type: "ecs:task", Collect: collectECSTasks},
+	{Type: "ecs:task-definition", Collect: collectTaskDefinitions},
+	{Type: "lambda:function", Collect: collectFunctions},
+	{Type: "dynamodb:table", Collect: collectTables},
+	{Type: "elasticache:cluster", Collect: collectCacheClusters},
+	{Type: "secretsmanager:secret", Collect: collectSecrets},
+	{Type: "route53:hostedzone", Collect: collectHostedZones},
+	{Type: "cloudwatch:alarm", Collect: collectAlarms},
+}
+
+func FindCollector(resourceType string) (*Collector, error) {
+	for index := range Collectors {
+		if Collectors[index].Type == resourceType {
+			return &Collectors[index], nil
+		}
+	}
+	return nil, fmt.Errorf("[inventory:FindCollector] unknown resource type: %s", resourceType)
+}
+
+func str(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
+
+func int64Str(value *int64) *string {
+	if value == nil {
+		return nil
+	}
+	ret := strconv.FormatInt(*value, 10)
+	return &ret
+}
+
+func boolStr(value *bool) *string {
+	if value == nil {
+		return nil
+	}
+	ret := strconv.FormatBool(*value)
+	return &ret
+}
+
+// Nil values are left out.
+func attributes(values map[string]*string) map[string]string {
+	ret := map[string]string{}
+	for key, value := range values {
+		if value != nil && *value != "" {
+			ret[key] = *value
+		}
+	}
+	return ret
+}
+
+func tagsFrom[T any](tags []T, keyValue func(tag T) (*string, *string)) map[string]string {
+	ret := map[string]string{}
+	for _, tag := range tags {
+		key, value := keyValue(tag)
+		if key != nil {
+			ret[*key] = str(value)
+		}
+	}
+	return ret
+}
+
+func tagsFromMap(tags map[string]*string) map[string]string {
+	ret := map[string]string{}
+	for key, value := range tags {
+		ret[key] = str(value)
+	}
+	return ret
+}
+
+func ec2Tags(tags []*ec2.Tag) map[string]string {
+	return tagsFrom(tags, func(tag *ec2.Tag) (*string, *string) { return tag.Key, tag.Value })
+}
+
+func collectNetworkInterfaces(ctx context.Context, scope *Scope, add func(*Resource)) error {
+	api := scope.Clients.EC2(&scope.Region)
+	for obj, err := range api.IterNetworkInterfaces(ctx, &ec2.DescribeNetworkInterfacesInput{}) {
+		if err != nil {
+			return err
+		}
+		resource := scope.resource("ec2:network-interface", str(obj.NetworkInterfaceId), scope.arn("ec2", "network-interface/"+str(obj.NetworkInterfaceId)))
+		resource.Tags = ec2Tags(obj.TagSet)
+		resource.Attributes = attributes(map[string]*string{"VpcId": obj.VpcId,
+			"SubnetId":         obj.SubnetId,
+			"Status":           obj.Status,
+			"InterfaceType":    obj.InterfaceType,
+			"PrivateIpAddress": obj.PrivateIpAddress})
+		if obj.Attachment != nil && obj.Attachment.InstanceId != nil {
+			resource.Attributes["InstanceId"] = *obj.Attachment.InstanceId
+		}
+		add(resource)
+	}
+	return nil
+}
+
+func collectInstances(ctx context.Context, scope *Scope, add func(*Resource)) error {
+	api := scope.Clients.EC2(&scope.Region)
+	for obj, err := range api.IterInstances(ctx, &ec2.DescribeInstancesInput{}) {
+		if err != nil {
+			return err
+		}
+		resource := scope.resource("ec2:instance", str(obj.InstanceId), scope.arn("ec2", "instance/"+str(obj.InstanceId)))
+		resource.Tags = ec2Tags(obj.Tags)
+		resource.CreatedAt = obj.LaunchTime
+		resource.Attributes = attributes(map[string]*string{"InstanceType": obj.InstanceType,
+			"VpcId":            obj.VpcId,
+			"SubnetId":         obj.SubnetId,
+			"PrivateIpAddress": obj.PrivateIpAddress})
+		if obj.State != nil && obj.State.Name != nil {
+			resource.Attributes["State"] = *obj.State.Name
+		}
+		add(resource)
+	}
+	return nil
+}
+
+func collectNatGateways(ctx context.Context, scope *Scope, add func(*Resource)) error {
+	api := scope.Clients.EC2(&scope.Region)
+	for obj, err := range api.IterNatGateways(ctx, &ec2.DescribeNatGatewaysInput{}) {
+		if err != nil {
+			return err
+		}
+		resource := scope.resource("ec2:natgateway", str(obj.NatGatewayId), scope.arn("ec2", "natgateway/"+str(obj.NatGatewayId)))
+		resource.Tags = ec2Tags(obj.Tags)
+		resource.CreatedAt = obj.CreateTime
+		resource.Attributes = attributes(map[string]*string{"State": obj.State,
+			"VpcId":            obj.VpcId,
+			"SubnetId":         obj.SubnetId,
+			"ConnectivityType": obj.ConnectivityType})
+		add(resource)
+	}
+	return nil
+}
+
+func collectAddresses(ctx context.Context, scope *Scope, add func(*Resource)) error {
+	api := scope.Clients.EC2(&scope.Region)
+	for obj, err := range api.IterAddresses(ctx, &ec2.DescribeAddressesInput{}) {
+		if err != nil {
+			return err
+		}
+		id := str(obj.AllocationId)
+		if id == "" {
+			id = str(obj.PublicIp)
+		}
+		resource := scope.resource("ec2:elastic-ip", id, scope.arn("ec2", "elastic-ip/"+id))
+		resource.Tags = ec2Tags(obj.Tags)
+		resource.Attributes = attributes(map[string]*string{"PublicIp": obj.PublicIp,
+			"AssociationId":      obj.AssociationId,
+			"InstanceId":         obj.InstanceId,
+			"NetworkInterfaceId": obj.NetworkInterfaceId,
+			"Domain":             obj.Domain})
+		add(resource)
+	}
+	return nil
+}
+
+func collectVolumes(ctx context.Context, scope *Scope, add func(*Resource)) error {
+	api := scope.Clients.EC2(&scope.Region)
+	for obj, err := range api.IterVolumes(ctx, &ec2.DescribeVolumesInput{}) {
+		if err != nil {
+			return err
+		}
+		resource := scope.resource("ec2:volume", str(obj.VolumeId), scope.arn("ec2", "volume/"+str(obj.VolumeId)))
+		resource.Tags = ec2Tags(obj.Tags)
+		resource.CreatedAt = obj.CreateTime
+		resource.Attributes = attributes(map[string]*string{"State": obj.State,
+			"VolumeType":       obj.VolumeType,
+			"Size":             int64Str(obj.Size),
+			"AvailabilityZone": obj.AvailabilityZone,
+			"Encrypted":        boolStr(obj.Encrypted)})
+		if len(obj.Attachments) > 0 {
+			resource.Attributes["InstanceId"] = str(obj.Attachments[0].InstanceId)
+		}
+		add(resource)
+	}
+	return nil
+}
+
+func collectSnapshots(ctx context.Context, scope *Scope, add func(*Resource)) error {
+	api := scope.Clients.EC2(&scope.Region)
+	for obj, err := range api.IterSnapshots(ctx, &ec2.DescribeSnapshotsInput{OwnerIds: []*string{strPtr("self")}}) {
+		if err != nil {
+			return err
+		}
+		resource := scope.resource("ec2:snapshot", str(obj.SnapshotId), fmt.Sprintf("arn:aws:ec2:%s::snapshot/%s", scope.Region, str(obj.SnapshotId)))
+		resource.Tags = ec2Tags(obj.Tags)
+		resource.CreatedAt = obj.StartTime
+		resource.Attributes = attributes(map[string]*string{"State": obj.State,
+			"VolumeId":   obj.VolumeId,
+			"VolumeSize": int64Str(obj.VolumeSize),
+			"Encrypted":  boolStr(obj.Encrypted)})
+		add(resource)
+	}
+	return nil
+}
+
+func collectImages(ctx context.Context, scope *Scope, add func(*Resource)) error {
+	api := scope.Clients.EC2(&scope.Region)
+	for obj, err := range api.IterImages(ctx, &ec2.DescribeImagesInput{Owners: []*string{strPtr("self")}}) {
+		if err != nil {
+			return err
+		}
+		resource := scope.resource("ec2:image", str(obj.ImageId), fmt.Sprintf("arn:aws:ec2:%s::image/%s", scope.Region, str(obj.ImageId)))
+		resource.Tags = ec2Tags(obj.Tags)
+		if createdAt, err := time.Parse(time.RFC3339, str(obj.CreationDate)); err == nil {
+			resource.CreatedAt = &createdAt
+		}
+		resource.Attributes = attributes(map[string]*string{"Name": obj.Name,
+			"State":        obj.State,
+			"Architecture": obj.Architecture})
+		add(resource)
+	}
+	return nil
+}
+
+func collectSecurityGroups(ctx context.Context, scope *Scope, add func(*Resource)) error {
+	api := scope.Clients.EC2(&scope.Region)
+	for obj, err := range api.IterSecurityGroups(ctx, &ec2.DescribeSecurityGroupsInput{}) {
+		if err != nil {
+			return err
+		}
+		resource := scope.resource("ec2:security-group", str(obj.GroupId), scope.arn("ec2", "security-group/"+str(obj.GroupId)))
+		resource.Tags = ec2Tags(obj.Tags)
+		resource.Attributes = attributes(map[string]*string{"GroupName": obj.GroupName,
+			"VpcId":        obj.VpcId,
+			"IngressRules": strPtr(strconv.Itoa(len(obj.IpPermissions))),
+			"EgressRules":  strPtr(strconv.Itoa(len(obj.IpPermissionsEgress)))})
+		add(resource)
+	}
+	return nil
+}
+
+func collectLaunchTemplates(ctx context.Context, scope *Scope, add func(*Resource)) error {
+	api := scope.Clients.EC2(&scope.Region)
+	for obj, err := range api.IterLaunchTemplates(ctx, &ec2.DescribeLaunchTemplatesInput{}) {
+		if err != nil {
+			return err
+		}
+		resource := scope.resource("ec2:launch-template", str(obj.LaunchTemplateId), scope.arn("ec2", "launch-template/"+str(obj.LaunchTemplateId)))
+		resource.Tags = ec2Tags(obj.Tags)
+		resource.CreatedAt = obj.CreateTime
+		resource.Attributes = attributes(map[string]*string{"Name": obj.LaunchTemplateName,
+			"LatestVersion": int64Str(obj.LatestVersionNumber)})
+		add(resource)
+	}
+	return nil
+}
+
+func collectKeyPairs(ctx context.Context, scope *Scope, add func(*Resource)) error {
+	api := scope.Clients.EC2(&scope.Region)
+	for obj, err := range api.IterKeyPairs(ctx, &ec2.DescribeKeyPairsInput{}) {
+		if err != nil {
+			return err
+		}
+		resource := scope.resource("ec2:key-pair", str(obj.KeyPairId), scope.arn("ec2", "key-pair/"+str(obj.KeyName)))
+		resource.Tags = ec2Tags(obj.Tags)
+		resource.CreatedAt = obj.CreateTime
+		resource.Attributes = attributes(map[string]*string{"Name": obj.KeyName,
+			"KeyType": obj.KeyType})
+		add(resource)
+	}
+	return nil
+}
+
+func collectLoadBalancers(ctx context.Context, scope *Scope, add func(*Resource)) error {
+	api := scope.Clients.ELBV2(&scope.Region)
+	for obj, err := range api.IterLoadBalancers(ctx, &elbv2.DescribeLoadBalancersInput{}) {
+		if err != nil {
+			return err
+		}
+		tags, err := api.GetTags(obj.LoadBalancerArn)
+		if err != nil {
+			return err
+		}
+		resource := scope.resource("elasticloadbalancing:loadbalancer", str(obj.LoadBalancerName), str(obj.LoadBalancerArn))
+		resource.Tags = tagsFrom(tags, func(tag *elbv2.Tag) (*string, *string) { return tag.Key, tag.Value })
+		resource.CreatedAt = obj.CreatedTime
+		resource.Attributes = attributes(map[string]*string{"Type": obj.Type,
+			"Scheme":  obj.Scheme,
+			"VpcId":   obj.VpcId,
+			"DNSName": obj.DNSName})
+		if obj.State != nil && obj.State.Code != nil {
+			resource.Attributes["State"] = *obj.State.Code
+		}
+		add(resource)
+	}
+	return nil
+}
+
+func collectTargetGroups(ctx context.Context, scope *Scope, add func(*Resource)) error {
+	api := scope.Clients.ELBV2(&scope.Region)
+	for obj, err := range api.IterTargetGroups(ctx, &elbv2.DescribeTargetGroupsInput{}) {
+		if err != nil {
+			return err
+		}
+		tags, err := api.GetTags(obj.TargetGroupArn)
+		if err != nil {
+			return err
+		}
+		resource := scope.resource("elasticloadbalancing:targetgroup", str(obj.TargetGroupName), str(obj.TargetGroupArn))
+		resource.Tags = tagsFrom(tags, func(tag *elbv2.Tag) (*string, *string) { return tag.Key, tag.Value })
+		resource.Attributes = attributes(map[string]*string{"TargetType": obj.TargetType,
+			"Protocol":      obj.Protocol,
+			"Port":          int64Str(obj.Port),
+			"VpcId":         obj.VpcId,
+			"LoadBalancers": strPtr(joinStrs(obj.LoadBalancerArns))})
+		add(resource)
+	}
+	return nil
+}
+
+func collectAutoScalingGroups(ctx context.Context, scope *Scope, add func(*Resource)) error {
+	api := scope.Clients.Autoscaling(&scope.Region)
+	for obj, err := range api.IterAutoScalingGroups(ctx, &autoscaling.DescribeAutoScalingGroupsInput{}) {
+		if err != nil {
+			return err
+		}
+		resource := scope.resource("autoscaling:autoScalingGroup", str(obj.AutoScalingGroupName), str(obj.AutoScalingGroupARN))
+		resource.Tags = tagsFrom(obj.Tags, func(tag *autoscaling.TagDescription) (*string, *string) { return tag.Key, tag.Value })
+		resource.CreatedAt = obj.CreatedTime
+		resource.Attributes = attributes(map[string]*string{"MinSize": int64Str(obj.MinSize),
+			"MaxSize":         int64Str(obj.MaxSize),
+			"DesiredCapacity": int64Str(obj.DesiredCapacity),
+			"Instances":       strPtr(strconv.Itoa(len(obj.Instances)))})
+		add(resource)
+	}
+	return nil
+}
+
+func collectRDSClusters(ctx context.Context, scope *Scope, add func(*Resource)) error {
+	api := scope.Clients.RDS(&scope.Region)
+	for obj, err := range api.IterClusters(ctx, &rds.DescribeDBClustersInput{}) {
+		if err != nil {
+			return err
+		}
+		resource := scope.resource("rds:cluster", str(obj.DBClusterIdentifier), str(obj.DBClusterArn))
+		resource.Tags = tagsFrom(obj.TagList, func(tag *rds.Tag) (*string, *string) { return tag.Key, tag.Value })
+		resource.CreatedAt = obj.ClusterCreateTime
+		resource.Attributes = attributes(map[string]*string{"Engine": obj.Engine,
+			"EngineVersion": obj.EngineVersion,
+			"Status":        obj.Status})
+		add(resource)
+	}
+	return nil
+}
+
+func collectRDSInstances(ctx context.Context, scope *Scope, add func(*Resource)) error {
+	api := scope.Clients.RDS(&scope.Region)
+	for obj, err := range api.IterInstances(ctx, &rds.DescribeDBInstancesInput{}) {
+		if err != nil {
+			return err
+		}
+		resource := scope.resource("rds:db", str(obj.DBInstanceIdentifier), str(obj.DBInstanceArn))
+		resource.Tags = tagsFrom(obj.TagList, func(tag *rds.Tag) (*string, *string) { return tag.Key, tag.Value })
+		resource.CreatedAt = obj.InstanceCreateTime
+		resource.Attributes = attributes(map[string]*string{"Engine": obj.Engine,
+			"EngineVersion":   obj.EngineVersion,
+			"DBInstanceClass": obj.DBInstanceClass,
+			"Status":          obj.DBInstanceStatus,
+			"DBClusterId":     obj.DBClusterIdentifier})
+		add(resource)
+	}
+	return nil
+}
+
+// collectBuckets adds the buckets located in the scope region, ListBuckets returns the buckets of all the regions.
+func collectBuckets(ctx context.Context, scope *Scope, add func(*Resource)) error {
+	api := scope.Clients.S3(&scope.Region)
+	for obj, err := range api.IterBuckets(ctx, &s3.ListBucketsInput{}) {
+		if err != nil {
+			return err
+		}
+		region, err := api.GetBucketRegion(obj)
+		if err != nil {
+			return err
+		}
+		if *region != scope.Region {
+			continue
+		}
+
+		tags, err := api.GetTags(obj.Name)
+		if err != nil && !strings.Contains(err.Error(), "NoSuchTagSet") {
+			return err
+		}
+		resource := scope.resource("s3:bucket", str(obj.Name), "arn:aws:s3:::"+str(obj.Name))
+		resource.Tags = tagsFrom(tags, func(tag *s3.Tag) (*string, *string) { return tag.Key, tag.Value })
+		resource.CreatedAt = obj.CreationDate
+		add(resource)
+	}
+	return nil
+}
+
+func collectLogGroups(ctx context.Context, scope *Scope, add func(*Resource)) error {
+	api := scope.Clients.CloudwatchLogs(&scope.Region)
+	for obj, err := range api.IterLogGroups(ctx, &cloudwatchlogs.DescribeLogGroupsInput{}) {
+		if err != nil {
+			return err
+		}
+		arn := str(obj.LogGroupArn)
+		if arn == "" {
+			arn = scope.arn("logs", "log-group:"+str(obj.LogGroupName))
+		}
+		tags, err := api.GetTags(&cloudwatchlogs.ListTagsForResourceInput{ResourceArn: &arn})
+		if err != nil {
+			return err
+		}
+		resource := scope.resource("logs:log-group", str(obj.LogGroupName), arn)
+		resource.Tags = tagsFromMap(tags)
+		if obj.CreationTime != nil {
+			createdAt := time.UnixMilli(*obj.CreationTime).UTC()
+			resource.CreatedAt = &createdAt
+		}
+		resource.Attributes = attributes(map[string]*string{"RetentionInDays": int64Str(obj.RetentionInDays),
+			"StoredBytes": int64Str(obj.StoredBytes)})
+		add(resource)
+	}
+	return nil
+}
+
+func collectECSClusters(ctx context.Context, scope *Scope, add func(*Resource)) error {
+	api := scope.Clients.ECS(&scope.Region)
+	clusters, err := api.GetClusters(&ecs.ListClustersInput{})
+	if err != nil {
+		return err
+	}
+	for _, obj := range clusters {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		tags, err := api.GetTags(obj.ClusterArn)
+		if err != nil {
+			return err
+		}
+		resource := scope.resource("ecs:cluster", str(obj.ClusterName), str(obj.ClusterArn))
+		resource.Tags = tagsFrom(tags, func(tag *ecs.Tag) (*string, *string) { return tag.Key, tag.Value })
+		resource.Attributes = attributes(map[string]*string{"Status": obj.Status,
+			"RunningTasks":   int64Str(obj.RunningTasksCount),
+			"ActiveServices": int64Str(obj.ActiveServicesCount)})
+		add(resource)
+	}
+	return nil
+}
+
+func collectECSTasks(ctx context.Context, scope *Scope, add func(*Resource)) error {
+	api := scope.Clients.ECS(&scope.Region)
+	clusters, err := api.GetClusters(&ecs.ListClustersInput{})
+	if err != nil {
+		return err
+	}
+	for _, cluster := range clusters {
+		for obj, err := range api.IterTasks(ctx, &ecs.ListTasksInput{Cluster: cluster.ClusterArn}) {
+			if err != nil {
+				return err
+			}
+			tags, err := api.GetTags(obj.TaskArn)
+			if err != nil {
+				return err
+			}
+			arn := str(obj.TaskArn)
+			resource := scope.resource("ecs:task", arn[strings.LastIndex(arn, "/")+1:], arn)
+			resource.Tags = tagsFrom(tags, func(tag *ecs.Tag) (*string, *string) { return tag.Key, tag.Value })
+			resource.CreatedAt = obj.CreatedAt
+			resource.Attributes = attributes(map[string]*string{"ClusterName": cluster.ClusterName,
+				"TaskDefinitionArn": obj.TaskDefinitionArn,
+				"Group":             obj.Group,
+				"LastStatus":        obj.LastStatus})
+			add(resource)
+		}
+	}
+	return nil
+}
+
+// Only the latest revision of every family, the older ones are replaced on deploy.
+func collectTaskDefinitions(ctx context.Context, scope *Scope, add func(*Resource)) error {
+	api := scope.Clients.ECS(&scope.Region)
+	for family, err := range api.IterTaskDefinitionFamilies(ctx, &ecs.ListTaskDefinitionFamiliesInput{Status: strPtr(ecs.TaskDefinitionFamilyStatusActive)}) {
+		if err != nil {
+			return err
+		}
+		latest, err := clients.Collect(clients.Take(api.IterTaskDefinitions(ctx, &ecs.ListTaskDefinitionsInput{FamilyPrefix: family, Sort: strPtr(ecs.SortOrderDesc), MaxResults: clients.Int64Ptr(1)}), 1))
+		if err != nil {
+			return err
+		}
+		if len(latest) == 0 {
+			continue
+		}
+		obj := latest[0]
+		tags, err := api.GetTags(obj.TaskDefinitionArn)
+		if err != nil {
+			return err
+		}
+		resource := scope.resource("ecs:task-definition", str(obj.Family), str(obj.TaskDefinitionArn))
+		resource.Tags = tagsFrom(tags, func(tag *ecs.Tag) (*string, *string) { return tag.Key, tag.Value })
+		resource.CreatedAt = obj.RegisteredAt
+		resource.Attributes = attributes(map[string]*string{"Revision": int64Str(obj.Revision),
+			"Status": obj.Status})
+		add(resource)
+	}
+	return nil
+}
+
+func collectFunctions(ctx context.Context, scope *Scope, add func(*Resource)) error {
+	api := scope.Clients.Lambda(&scope.Region)
+	for obj, err := range api.IterFunctions(ctx, &lambda.ListFunctionsInput{}) {
+		if err != nil {
+			return err
+		}
+		tags, err := api.GetTags(obj.FunctionArn)
+		if err != nil {
+			return err
+		}
+		resource := scope.resource("lambda:function", str(obj.FunctionName), str(obj.FunctionArn))
+		resource.Tags = tagsFromMap(tags)
+		resource.Attributes = attributes(map[string]*string{"Runtime": obj.Runtime,
+			"MemorySize":   int64Str(obj.MemorySize),
+			"PackageType":  obj.PackageType,
+			"LastModified": obj.LastModified})
+		add(resource)
+	}
+	return nil
+}
+
+func collectTables(ctx context.Context, scope *Scope, add func(*Resource)) error {
+	api := scope.Clients.DynamoDB(&scope.Region)
+	for obj, err := range api.IterTables(ctx, &dynamodb.ListTablesInput{}) {
+		if err != nil {
+			return err
+		}
+		tags, err := api.GetTags(obj)
+		if err != nil {
+			return err
+		}
+		resource := scope.resource("dynamodb:table", str(obj.TableName), str(obj.TableArn))
+		resource.Tags = tagsFromMap(tags)
+		resource.CreatedAt = obj.CreationDateTime
+		resource.Attributes = attributes(map[string]*string{"Status": obj.TableStatus,
+			"ItemCount": int64Str(obj.ItemCount)})
+		if obj.BillingModeSummary != nil {
+			resource.Attributes["BillingMode"] = str(obj.BillingModeSummary.BillingMode)
+		}
+		add(resource)
+	}
+	return nil
+}
+
+func collectCacheClusters(ctx context.Context, scope *Scope, add func(*Resource)) error {
+	api := scope.Clients.Elasticache(&scope.Region)
+	for obj, err := range api.IterCacheClusters(ctx, &elasticache.DescribeCacheClustersInput{}) {
+		if err != nil {
+			return err
+		}
+		tags, err := api.GetTags(obj)
+		if err != nil {
+			return err
+		}
+		resource := scope.resource("elasticache:cluster", str(obj.CacheClusterId), str(obj.ARN))
+		resource.Tags = tagsFromMap(tags)
+		resource.CreatedAt = obj.CacheClusterCreateTime
+		resource.Attributes = attributes(map[string]*string{"Engine": obj.Engine,
+			"EngineVersion": obj.EngineVersion,
+			"CacheNodeType": obj.CacheNodeType,
+			"Status":        obj.CacheClusterStatus})
+		add(resource)
+	}
+	return nil
+}
+
+func collectSecrets(ctx context.Context, scope *Scope, add func(*Resource)) error {
+	api := scope.Clients.Secretsmanager(&scope.Region)
+	for obj, err := range api.IterSecrets(ctx, &secretsmanager.ListSecretsInput{}) {
+		if err != nil {
+			return err
+		}
+		resource := scope.resource("secretsmanager:secret", str(obj.Name), str(obj.ARN))
+		resource.Tags = tagsFrom(obj.Tags, func(tag *secretsmanager.Tag) (*string, *string) { return tag.Key, tag.Value })
+		resource.CreatedAt = obj.CreatedDate
+		resource.Attributes = attributes(map[string]*string{"RotationEnabled": boolStr(obj.RotationEnabled)})
+		if obj.LastAccessedDate != nil {
+			resource.Attributes["LastAccessedDate"] = obj.LastAccessedDate.UTC().Format(time.DateOnly)
+		}
+		add(resource)
+	}
+	return nil
+}
+
+// Route53 is global, collected in GlobalRegion only.
+func collectHostedZones(ctx context.Context, scope *Scope, add func(*Resource)) error {
+	if scope.Region != GlobalRegion {
+		return nil
+	}
+
+	api := scope.Clients.Route53()
+	for obj, err := range api.IterHostedZones(ctx, &route53.ListHostedZonesInput{}) {
+		if err != nil {
+			return err
+		}
+		id := strings.TrimPrefix(str(obj.Id), "/hostedzone/")
+		tags, err := api.GetTags(obj.Id)
+		if err != nil {
+			return err
+		}
+		resource := scope.resource("route53:hostedzone", id, "arn:aws:route53:::hostedzone/"+id)
+		resource.Tags = tagsFrom(tags, func(tag *route53.Tag) (*string, *string) { return tag.Key, tag.Value })
+		resource.Attributes = attributes(map[string]*string{"Name": obj.Name,
+			"RecordSets": int64Str(obj.ResourceRecordSetCount)})
+		if obj.Config != nil {
+			resource.Attributes["PrivateZone"] = str(boolStr(obj.Config.PrivateZone))
+		}
+		add(resource)
+	}
+	return nil
+}
+
+func joinStrs(values []*string) string {
+	ret := make([]string, 0, len(values))
+	for _, value := range values {
+		ret = append(ret, str(value))
+	}
+	return strings.Join(ret, ",")
+}
+
+func strPtr(src string) *string {
+	return &src
+}
+
+func collectAlarms(ctx context.Context, scope *Scope, add func(*Resource)) error {
+	api := scope.Clients.Cloudwatch(&scope.Region)
+	for obj, err := range api.IterMetricAlarms(ctx, &cloudwatch.DescribeAlarmsInput{}) {
+		if err != nil {
+			return err
+		}
+		tags, err := api.GetTags(&cloudwatch.ListTagsForResourceInput{ResourceARN: obj.AlarmArn})
+		if err != nil {
+			return err
+		}
+		resource := scope.resource("cloudwatch:alarm", str(obj.AlarmName), str(obj.AlarmArn))
+		resource.Tags = tagsFromMap(tags)
+		resource.Attributes = attributes(map[string]*string{"Namespace": obj.Namespace,
+			"MetricName": obj.MetricName,
+			"State":      obj.StateValue})
+		add(resource)
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package aws_api
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+const (
+	ChangeAdded       = "added"
+	ChangeRemoved     = "removed"
+	ChangeTagsChanged = "tags changed"
+)
+
+type Change struct {
+	Resource string            `json:"Resource"`
+	Type     string            `json:"Type"`
+	Account  string            `json:"Account"`
+	Region   string            `json:"Region"`
+	Change   string            `json:"Change"`
+	OldTags  map[string]string `json:"OldTags,omitempty"`
+	NewTags  map[string]string `json:"NewTags,omitempty"`
+}
+
+func (change Change) String() string {
+	if change.Change != ChangeTagsChanged {
+		return fmt.Sprintf("%s/%s %s %s: %s", change.Account, change.Region, change.Type, change.Resource, change.Change)
+	}
+	return fmt.Sprintf("%s/%s %s %s: %s: %s", change.Account, change.Region, change.Type, change.Resource, change.Change, strings.Join(change.TagChanges(), ", "))
+}
+
+// TagChanges lists the tag differences as +Key=value, -Key=value and Key: old -> new.
+func (change Change) TagChanges() []string {
+	ret := []string{}
+	for key, newValue := range change.NewTags {
+		oldValue, ok := change.OldTags[key]
+		if !ok {
+			ret = append(ret, fmt.Sprintf("+%s=%s", key, newValue))
+		} else if oldValue != newValue {
+			ret = append(ret, fmt.Sprintf("%s: %s -> %s", key, oldValue, newValue))
+		}
+	}
+	for key, oldValue := range change.OldTags {
+		if _, ok := change.NewTags[key]; !ok {
+			ret = append(ret, fmt.Sprintf("-%s=%s", key, oldValue))
+		}
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func tagsEqual(first, second map[string]string) bool {
+	if len(first) != len(second) {
+		return false
+	}
+	for key, value := range first {
+		if otherValue, ok := second[key]; !ok || otherValue != value {
+			return false
+		}
+	}
+	return true
+}
+
+// Diff compares two snapshots by resource Key, the changes are sorted by type and key.
+func Diff(oldResources, newResources []*Resource) []Change {
+	oldByKey := map[string]*Resource{}
+	for _, resource := range oldResources {
+		oldByKey[resource.Key()] = resource
+	}
+	newByKey := map[string]*Resource{}
+	for _, resource := range newResources {
+		newByKey[resource.Key()] = resource
+	}
+
+	changed := []*Resource{}
+	changes := map[string]Change{}
+	for key, newResource := range newByKey {
+		oldResource, ok := oldByKey[key]
+		if !ok {
+			changes[key] = changeNew(newResource, ChangeAdded)
+		} else if !tagsEqual(oldResource.Tags, newResource.Tags) {
+			change := changeNew(newResource, ChangeTagsChanged)
+			change.OldTags = oldResource.Tags
+			change.NewTags = newResource.Tags
+			changes[key] = change
+		} else {
+			continue
+		}
+		changed = append(changed, newResource)
+	}
+	for key, oldResource := range oldByKey {
+		if _, ok := newByKey[key]; !ok {
+			changes[key] = changeNew(oldResource, ChangeRemoved)
+			changed = append(changed, oldResource)
+		}
+	}
+
+	SortResources(changed)
+	ret := make([]Change, 0, len(changed))
+	for _, resource := range changed {
+		ret = append(ret, changes[resource.Key()])
+	}
+	return ret
+}
+
+func changeNew(resource *Resource, change string) Change {
+	return Change{Resource: resource.Key(), Type: resource.Type, Account: resource.Account, Region: resource.Region, Change: change}
+}
+
+// DiffPaths compares two snapshot files or two snapshot directories.
+func DiffPaths(oldPath, newPath string) ([]Change, error) {
+	oldResources, err := readSnapshotPath(oldPath)
+	if err != nil {
+		return nil, err
+	}
+	newResources, err := readSnapshotPath(newPath)
+	if err != nil {
+		return nil, err
+	}
+	return Diff(oldResources, newResources), nil
+}
+
+func readSnapshotPath(path string) ([]*Resource, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("[inventory:DiffPaths] %w", err)
+	}
+	if info.IsDir() {
+		return ReadSnapshotDir(path)
+	}
+	return ReadSnapshot(path)
+}
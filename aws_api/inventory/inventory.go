@@ -0,0 +1,226 @@
+package aws_api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	clients "github.com/AlexeyBeley/go_misc/aws_api/clients"
+	"github.com/AlexeyBeley/go_misc/logger"
+)
+
+var lg = &(logger.Logger{AddDateTime: true})
+
+// Global services are snapshotted with this region only, so they appear once per account.
+const GlobalRegion = "us-east-1"
+
+const SnapshotFileExtension = ".jsonl"
+
+type InventoryConfig struct {
+	Executor  clients.ExecutorConfig `json:"Executor"`
+	OutputDir string                 `json:"OutputDir"`
+	// Resource types to collect, all the Collectors when empty.
+	Types []string `json:"Types"`
+}
+
+// Resource is the normalized line of a snapshot, the same shape for all the resource types.
+type Resource struct {
+	Account    string            `json:"Account"`
+	Region     string            `json:"Region"`
+	Type       string            `json:"Type"`
+	Id         string            `json:"Id"`
+	Arn        string            `json:"Arn"`
+	Tags       map[string]string `json:"Tags"`
+	CreatedAt  *time.Time        `json:"CreatedAt,omitempty"`
+	Attributes map[string]string `json:"Attributes,omitempty"`
+}
+
+// Key identifies the resource across snapshots.
+func (resource *Resource) Key() string {
+	if resource.Arn != "" {
+		return resource.Arn
+	}
+	return strings.Join([]string{resource.Account, resource.Region, resource.Type, resource.Id}, "/")
+}
+
+// Scope is a single account and region being snapshotted.
+type Scope struct {
+	Account string
+	Region  string
+	Clients *clients.Factory
+}
+
+func (scope *Scope) resource(resourceType, id, arn string) *Resource {
+	return &Resource{Account: scope.Account,
+		Region:     scope.Region,
+		Type:       resourceType,
+		Id:         id,
+		Arn:        arn,
+		Tags:       map[string]string{},
+		Attributes: map[string]string{}}
+}
+
+// Arn of the resource types the describe calls do not return the arn for.
+func (scope *Scope) arn(service, resource string) string {
+	return fmt.Sprintf("arn:aws:%s:%s:%s:%s", service, scope.Region, scope.Account, resource)
+}
+
+// Snapshot collects the requested resource types, all of them when types is empty.
+// A failing collector fails the snapshot: a partial snapshot would show up as removed resources in the diff.
+func Snapshot(ctx context.Context, scope *Scope, types []string) ([]*Resource, error) {
+	errorPrefix := "[inventory:Snapshot]"
+
+	for _, resourceType := range types {
+		if _, err := FindCollector(resourceType); err != nil {
+			return nil, fmt.Errorf("%s\n%w", errorPrefix, err)
+		}
+	}
+
+	ret := []*Resource{}
+	errs := []error{}
+	for _, collector := range Collectors {
+		if len(types) > 0 && !slices.Contains(types, collector.Type) {
+			continue
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		count := len(ret)
+		err := collector.Collect(ctx, scope, func(resource *Resource) { ret = append(ret, resource) })
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s %s\n%w", errorPrefix, collector.Type, err))
+			continue
+		}
+		lg.InfoF("%s/%s: collected %d %s", scope.Account, scope.Region, len(ret)-count, collector.Type)
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	SortResources(ret)
+	return ret, nil
+}
+
+func SortResources(resources []*Resource) {
+	sort.SliceStable(resources, func(i, j int) bool {
+		if resources[i].Type != resources[j].Type {
+			return resources[i].Type < resources[j].Type
+		}
+		return resources[i].Key() < resources[j].Key()
+	})
+}
+
+func SnapshotFileName(account, region string) string {
+	return account + "_" + region + SnapshotFileExtension
+}
+
+func WriteSnapshot(filePath string, resources []*Resource) error {
+	errorPrefix := "[inventory:WriteSnapshot]"
+
+	file, err := os.Create(filePath)
+	if err != nil {
+		return fmt.Errorf("%s Creating %s\n%w", errorPrefix, filePath, err)
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	for _, resource := range resources {
+		err = encoder.Encode(resource)
+		if err != nil {
+			return fmt.Errorf("%s Encoding %s\n%w", errorPrefix, resource.Key(), err)
+		}
+	}
+	return writer.Flush()
+}
+
+func ReadSnapshot(filePath string) ([]*Resource, error) {
+	errorPrefix := "[inventory:ReadSnapshot]"
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("%s Opening %s\n%w", errorPrefix, filePath, err)
+	}
+	defer file.Close()
+
+	ret := []*Resource{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		resource := &Resource{}
+		err = json.Unmarshal(scanner.Bytes(), resource)
+		if err != nil {
+			return nil, fmt.Errorf("%s %s:%d\n%w", errorPrefix, filePath, lineNumber, err)
+		}
+		ret = append(ret, resource)
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s Reading %s\n%w", errorPrefix, filePath, err)
+	}
+	return ret, nil
+}
+
+// ReadSnapshotDir reads all the snapshot files of a directory.
+func ReadSnapshotDir(dirPath string) ([]*Resource, error) {
+	filePaths, err := filepath.Glob(filepath.Join(dirPath, "*"+SnapshotFileExtension))
+	if err != nil {
+		return nil, err
+	}
+	ret := []*Resource{}
+	for _, filePath := range filePaths {
+		resources, err := ReadSnapshot(filePath)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, resources...)
+	}
+	return ret, nil
+}
+
+// SnapshotTarget writes the snapshot of a single account and region, returns the file path.
+func SnapshotTarget(ctx context.Context, factory *clients.Factory, region string, config *InventoryConfig) (string, error) {
+	errorPrefix := "[inventory:SnapshotTarget]"
+
+	account, err := factory.STS().GetAccount()
+	if err != nil {
+		return "", fmt.Errorf("%s Resolving the account id\n%w", errorPrefix, err)
+	}
+
+	resources, err := Snapshot(ctx, &Scope{Account: *account, Region: region, Clients: factory}, config.Types)
+	if err != nil {
+		return "", err
+	}
+
+	err = os.MkdirAll(config.OutputDir, 0755)
+	if err != nil {
+		return "", fmt.Errorf("%s Creating %s\n%w", errorPrefix, config.OutputDir, err)
+	}
+	filePath := filepath.Join(config.OutputDir, SnapshotFileName(*account, region))
+	err = WriteSnapshot(filePath, resources)
+	if err != nil {
+		return "", err
+	}
+	lg.InfoF("Wrote %d resources to %s", len(resources), filePath)
+	return filePath, nil
+}
+
+// SnapshotAllTargets writes a snapshot per executor target, the report values are the file paths.
+func SnapshotAllTargets(ctx context.Context, executor *clients.Executor, config *InventoryConfig) *clients.Report[string] {
+	report := clients.Execute(ctx, executor, func(ctx context.Context, target clients.Target, factory *clients.Factory) (string, error) {
+		return SnapshotTarget(ctx, factory, target.Region, config)
+	})
+	lg.InfoF("Inventory report: %s", report)
+	return report
+}
@@ -0,0 +1,142 @@
+package aws_api
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	clients "github.com/AlexeyBeley/go_misc/aws_api/clients"
+	"github.com/AlexeyBeley/go_misc/aws_api/clients/fakes"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+func seededServices() *fakes.Services {
+	services := fakes.ServicesNew()
+	services.EC2.Instances = []*ec2.Instance{
+		{InstanceId: strPtr("i-1"), InstanceType: strPtr("t3.micro"), Tags: []*ec2.Tag{{Key: strPtr("Team"), Value: strPtr("infra")}}},
+		{InstanceId: strPtr("i-2"), InstanceType: strPtr("m5.large")},
+	}
+	services.EC2.Volumes = []*ec2.Volume{{VolumeId: strPtr("vol-1"), Size: clients.Int64Ptr(8), State: strPtr("available")}}
+	services.S3.Buckets = []*s3.Bucket{{Name: strPtr("bucket-east")}, {Name: strPtr("bucket-west")}}
+	services.S3.BucketRegions = map[string]string{"bucket-west": "eu-west-1"}
+	services.S3.Tags = map[string]map[string]string{"bucket-west": {"Owner": "alice"}}
+	services.Lambda.Functions = []*lambda.FunctionConfiguration{{FunctionName: strPtr("handler"), FunctionArn: strPtr("arn:aws:lambda:us-east-1:123456789012:function:handler"), Runtime: strPtr("go1.x")}}
+	services.Lambda.Tags = map[string]map[string]string{"arn:aws:lambda:us-east-1:123456789012:function:handler": {"Env": "prod"}}
+	services.Route53.HostedZones = []*route53.HostedZone{{Id: strPtr("/hostedzone/Z1"), Name: strPtr("example.com.")}}
+	services.Route53.Tags = map[string]map[string]string{"Z1": {"Team": "dns"}}
+	return services
+}
+
+func resourcesByKey(resources []*Resource) map[string]*Resource {
+	ret := map[string]*Resource{}
+	for _, resource := range resources {
+		ret[resource.Key()] = resource
+	}
+	return ret
+}
+
+func TestSnapshotAllTargets(t *testing.T) {
+	t.Run("Valid run", func(t *testing.T) {
+		services := seededServices()
+		executor := clients.ExecutorNewWithTargets([]clients.Target{{Region: "us-east-1"}, {Region: "eu-west-1"}}, 2)
+		executor.FactoryNew = func(clients.Target) (*clients.Factory, error) { return services.Factory(), nil }
+		config := &InventoryConfig{OutputDir: t.TempDir()}
+
+		report := SnapshotAllTargets(context.Background(), executor, config)
+		if err := report.Err(); err != nil {
+			t.Fatalf("%v", err)
+		}
+		if report.Results[0].Value != filepath.Join(config.OutputDir, "123456789012_us-east-1.jsonl") {
+			t.Errorf("unexpected snapshot path: %s", report.Results[0].Value)
+		}
+
+		east, err := ReadSnapshot(report.Results[0].Value)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		byKey := resourcesByKey(east)
+		instance := byKey["arn:aws:ec2:us-east-1:123456789012:instance/i-1"]
+		if instance == nil || instance.Tags["Team"] != "infra" || instance.Attributes["InstanceType"] != "t3.micro" {
+			t.Errorf("unexpected instance: %+v", instance)
+		}
+		if byKey["arn:aws:s3:::bucket-west"] != nil || byKey["arn:aws:s3:::bucket-east"] == nil {
+			t.Errorf("buckets must be in their own region snapshot")
+		}
+		if zone := byKey["arn:aws:route53:::hostedzone/Z1"]; zone == nil || zone.Tags["Team"] != "dns" {
+			t.Errorf("unexpected hosted zone: %+v", zone)
+		}
+		if function := byKey["arn:aws:lambda:us-east-1:123456789012:function:handler"]; function == nil || function.Tags["Env"] != "prod" {
+			t.Errorf("unexpected function: %+v", function)
+		}
+
+		west, err := ReadSnapshot(report.Results[1].Value)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		byKey = resourcesByKey(west)
+		if bucket := byKey["arn:aws:s3:::bucket-west"]; bucket == nil || bucket.Tags["Owner"] != "alice" {
+			t.Errorf("unexpected bucket: %+v", bucket)
+		}
+		if byKey["arn:aws:route53:::hostedzone/Z1"] != nil {
+			t.Errorf("hosted zones must be snapshotted once per account")
+		}
+	})
+
+	t.Run("Unknown type", func(t *testing.T) {
+		services := seededServices()
+		_, err := Snapshot(context.Background(), &Scope{Account: "1", Region: "us-east-1", Clients: services.Factory()}, []string{"ec2:unknown"})
+		if err == nil {
+			t.Errorf("expected an error")
+		}
+	})
+}
+
+func TestDiff(t *testing.T) {
+	t.Run("Valid run", func(t *testing.T) {
+		services := seededServices()
+		scope := &Scope{Account: "123456789012", Region: "us-east-1", Clients: services.Factory()}
+		oldResources, err := Snapshot(context.Background(), scope, []string{"ec2:instance", "ec2:volume"})
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		oldPath := filepath.Join(t.TempDir(), "old.jsonl")
+		err = WriteSnapshot(oldPath, oldResources)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+
+		services.EC2.Instances = append(services.EC2.Instances[1:], &ec2.Instance{InstanceId: strPtr("i-3")})
+		services.EC2.Tags = map[string]map[string]string{"i-2": {"Team": "data"}, "vol-1": {"Owner": "bob"}}
+		newResources, err := Snapshot(context.Background(), scope, []string{"ec2:instance", "ec2:volume"})
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		newPath := filepath.Join(t.TempDir(), "new.jsonl")
+		err = WriteSnapshot(newPath, newResources)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+
+		changes, err := DiffPaths(oldPath, newPath)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		expected := []string{
+			"123456789012/us-east-1 ec2:instance arn:aws:ec2:us-east-1:123456789012:instance/i-1: removed",
+			"123456789012/us-east-1 ec2:instance arn:aws:ec2:us-east-1:123456789012:instance/i-2: tags changed: +Team=data",
+			"123456789012/us-east-1 ec2:instance arn:aws:ec2:us-east-1:123456789012:instance/i-3: added",
+			"123456789012/us-east-1 ec2:volume arn:aws:ec2:us-east-1:123456789012:volume/vol-1: tags changed: +Owner=bob",
+		}
+		if len(changes) != len(expected) {
+			t.Fatalf("unexpected changes: %v", changes)
+		}
+		for index, change := range changes {
+			if change.String() != expected[index] {
+				t.Errorf("change %d: %s, expected %s", index, change, expected[index])
+			}
+		}
+	})
+}
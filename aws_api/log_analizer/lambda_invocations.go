@@ -0,0 +1,363 @@
+package aws_api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+)
+
+const (
+	InvocationStatusSuccess = "success"
+	InvocationStatusError   = "error"
+	InvocationStatusTimeout = "timeout"
+)
+
+// The runtimes write the request ID before the message: "<time>\t<request ID>\tINFO\t<message>".
+var requestIDRegex = regexp.MustCompile(`[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`)
+
+// LambdaReport is the REPORT line of an invocation, the platform.report record in the JSON log format.
+type LambdaReport struct {
+	RequestID        string
+	DurationMs       float64
+	BilledDurationMs float64
+	MemorySizeMB     int
+	MaxMemoryUsedMB  int
+	InitDurationMs   float64 `json:",omitempty"`
+	// Set by the runtime for failed invocations only.
+	Status    string `json:",omitempty"`
+	ErrorType string `json:",omitempty"`
+}
+
+// ParseReportLine parses
+// "REPORT RequestId: <id>\tDuration: 2.50 ms\tBilled Duration: 3 ms\tMemory Size: 128 MB\tMax Memory Used: 70 MB\tInit Duration: 150.12 ms".
+func ParseReportLine(message string) (*LambdaReport, error) {
+	errorPrefix := "[lambda_invocations:ParseReportLine]"
+	if !strings.HasPrefix(message, "REPORT RequestId: ") {
+		return nil, fmt.Errorf("%s not a REPORT line: %s", errorPrefix, message)
+	}
+	report := &LambdaReport{}
+	for _, field := range strings.Split(strings.TrimSpace(message), "\t") {
+		key, value, found := strings.Cut(field, ": ")
+		if !found {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		number := strings.TrimSpace(strings.TrimSuffix(strings.TrimSuffix(value, "ms"), "MB"))
+		var err error
+		switch key {
+		case "REPORT RequestId":
+			report.RequestID = value
+		case "Duration":
+			report.DurationMs, err = strconv.ParseFloat(number, 64)
+		case "Billed Duration":
+			report.BilledDurationMs, err = strconv.ParseFloat(number, 64)
+		case "Memory Size":
+			report.MemorySizeMB, err = strconv.Atoi(number)
+		case "Max Memory Used":
+			report.MaxMemoryUsedMB, err = strconv.Atoi(number)
+		case "Init Duration":
+			report.InitDurationMs, err = strconv.ParseFloat(number, 64)
+		case "Status":
+			report.Status = value
+		case "Error Type":
+			report.ErrorType = value
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s %s\n%w", errorPrefix, key, err)
+		}
+	}
+	if report.RequestID == "" {
+		return nil, fmt.Errorf("%s request ID is missing: %s", errorPrefix, message)
+	}
+	return report, nil
+}
+
+// jsonLogRecord is a line of the JSON log format: platform events by Type, application logs by RequestID.
+type jsonLogRecord struct {
+	Type      string `json:"type"`
+	RequestID string `json:"requestId"`
+	Record    struct {
+		RequestID string `json:"requestId"`
+		Status    string `json:"status"`
+		ErrorType string `json:"errorType"`
+		Metrics   struct {
+			DurationMs       float64 `json:"durationMs"`
+			BilledDurationMs float64 `json:"billedDurationMs"`
+			MemorySizeMB     int     `json:"memorySizeMB"`
+			MaxMemoryUsedMB  int     `json:"maxMemoryUsedMB"`
+			InitDurationMs   float64 `json:"initDurationMs"`
+		} `json:"metrics"`
+	} `json:"record"`
+}
+
+// LambdaInvocation is an invocation reconstructed from its log events.
+// ColdStart is set by the Init Duration of the report, or an INIT_START seen in the stream before the invocation.
+type LambdaInvocation struct {
+	LambdaReport
+	FunctionName  string `json:",omitempty"`
+	LogStreamName string
+	Start         time.Time
+	End           time.Time
+	ColdStart     bool
+	// The report was seen, incomplete invocations are flushed at the end of the logs.
+	Complete bool
+	Events   []*cloudwatchlogs.OutputLogEvent `json:",omitempty"`
+}
+
+// Failed by the report status, or by the timeout and invoke error messages of the runtimes without one.
+func (invocation *LambdaInvocation) Failed() bool {
+	return invocation.Status != "" && invocation.Status != InvocationStatusSuccess
+}
+
+func (invocation *LambdaInvocation) String() string {
+	status := invocation.Status
+	if status == "" {
+		status = InvocationStatusSuccess
+	}
+	return fmt.Sprintf("%s %s %.2f ms, %d/%d MB, cold start: %t, %s", invocation.RequestID, invocation.Start.UTC().Format(time.RFC3339Nano),
+		invocation.DurationMs, invocation.MaxMemoryUsedMB, invocation.MemorySizeMB, invocation.ColdStart, status)
+}
+
+// LambdaInvocationReconstructor groups the log events by RequestId, invocations of concurrent
+// execution environments may interleave when streams are merged.
+// Lines without a request ID belong to the only open invocation of their stream, Unattributed otherwise.
+// Lines of an invocation after its report, the text format timeout is one, update the closed invocation status.
+type LambdaInvocationReconstructor struct {
+	FunctionName string
+	// Keep the events in the invocations, only the metrics otherwise.
+	KeepEvents   bool
+	Unattributed int
+
+	open       map[string]*LambdaInvocation
+	streamOpen map[string][]string
+	closed     map[string]*LambdaInvocation
+	// INIT_START was seen in the stream, the next invocation is a cold start.
+	streamInit map[string]bool
+}
+
+// Generator
+func LambdaInvocationReconstructorNew(functionName string) *LambdaInvocationReconstructor {
+	return &LambdaInvocationReconstructor{FunctionName: functionName, KeepEvents: true,
+		open: map[string]*LambdaInvocation{}, streamOpen: map[string][]string{},
+		closed: map[string]*LambdaInvocation{}, streamInit: map[string]bool{}}
+}
+
+func (reconstructor *LambdaInvocationReconstructor) invocation(logStreamName, requestID string, timestamp time.Time) *LambdaInvocation {
+	if invocation, found := reconstructor.open[requestID]; found {
+		return invocation
+	}
+	invocation := &LambdaInvocation{LambdaReport: LambdaReport{RequestID: requestID}, FunctionName: reconstructor.FunctionName,
+		LogStreamName: logStreamName, Start: timestamp, ColdStart: reconstructor.streamInit[logStreamName]}
+	reconstructor.streamInit[logStreamName] = false
+	reconstructor.open[requestID] = invocation
+	reconstructor.streamOpen[logStreamName] = append(reconstructor.streamOpen[logStreamName], requestID)
+	return invocation
+}
+
+func (reconstructor *LambdaInvocationReconstructor) close(invocation *LambdaInvocation, timestamp time.Time) *LambdaInvocation {
+	delete(reconstructor.open, invocation.RequestID)
+	reconstructor.closed[invocation.RequestID] = invocation
+	reconstructor.streamOpen[invocation.LogStreamName] = slices.DeleteFunc(reconstructor.streamOpen[invocation.LogStreamName],
+		func(requestID string) bool { return requestID == invocation.RequestID })
+	invocation.End = timestamp
+	invocation.Complete = true
+	if invocation.InitDurationMs > 0 {
+		invocation.ColdStart = true
+	}
+	return invocation
+}
+
+// Process adds the event of the stream, the invocation is returned when its report completes it.
+func (reconstructor *LambdaInvocationReconstructor) Process(logStreamName string, event *cloudwatchlogs.OutputLogEvent) (*LambdaInvocation, error) {
+	message := strings.TrimSpace(aws.StringValue(event.Message))
+	timestamp := time.UnixMilli(aws.Int64Value(event.Timestamp))
+
+	var record *jsonLogRecord
+	if strings.HasPrefix(message, "{") {
+		record = &jsonLogRecord{}
+		if err := json.Unmarshal([]byte(message), record); err != nil {
+			record = nil
+		}
+	}
+
+	if strings.HasPrefix(message, "INIT_START") || record != nil && record.Type == "platform.initStart" {
+		reconstructor.streamInit[logStreamName] = true
+		return nil, nil
+	}
+
+	requestID := ""
+	boundary := false
+	switch {
+	case record != nil:
+		requestID = record.RequestID
+		if strings.HasPrefix(record.Type, "platform.") {
+			requestID, boundary = record.Record.RequestID, true
+		}
+	default:
+		requestID = requestIDRegex.FindString(message)
+		boundary = strings.HasPrefix(message, "START RequestId") || strings.HasPrefix(message, "REPORT RequestId")
+	}
+	if closed := reconstructor.closed[requestID]; !boundary && closed != nil {
+		// The invocation was already returned, only its status is updated.
+		if strings.Contains(message, "Task timed out after") {
+			closed.Status = InvocationStatusTimeout
+		} else if (closed.Status == "" || closed.Status == InvocationStatusSuccess) && strings.Contains(message, "Invoke Error") {
+			closed.Status = InvocationStatusError
+		}
+		return nil, nil
+	}
+	if !boundary && reconstructor.open[requestID] == nil {
+		// A line without a request ID belongs to the only open invocation of the stream.
+		// An unknown ID is a new invocation only when nothing is open, its START is before the fetched events.
+		streamOpen := reconstructor.streamOpen[logStreamName]
+		switch {
+		case requestID == "" && len(streamOpen) == 1:
+			requestID = streamOpen[0]
+		case len(streamOpen) > 0:
+			requestID = ""
+		}
+	}
+	if requestID == "" {
+		reconstructor.Unattributed++
+		return nil, nil
+	}
+
+	invocation := reconstructor.invocation(logStreamName, requestID, timestamp)
+	if reconstructor.KeepEvents {
+		invocation.Events = append(invocation.Events, event)
+	}
+	if strings.Contains(message, "Task timed out after") {
+		invocation.Status = InvocationStatusTimeout
+	} else if invocation.Status == "" && strings.Contains(message, "Invoke Error") {
+		invocation.Status = InvocationStatusError
+	}
+
+	var report *LambdaReport
+	switch {
+	case record != nil && record.Type == "platform.report":
+		metrics := record.Record.Metrics
+		report = &LambdaReport{RequestID: requestID, DurationMs: metrics.DurationMs, BilledDurationMs: metrics.BilledDurationMs,
+			MemorySizeMB: metrics.MemorySizeMB, MaxMemoryUsedMB: metrics.MaxMemoryUsedMB, InitDurationMs: metrics.InitDurationMs,
+			Status: record.Record.Status, ErrorType: record.Record.ErrorType}
+	case record == nil && boundary && strings.HasPrefix(message, "REPORT"):
+		var err error
+		if report, err = ParseReportLine(message); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, nil
+	}
+	if report.Status == "" {
+		report.Status = invocation.Status
+	}
+	invocation.LambdaReport = *report
+	return reconstructor.close(invocation, timestamp), nil
+}
+
+// Flush returns the invocations without a report, by their start.
+func (reconstructor *LambdaInvocationReconstructor) Flush() []*LambdaInvocation {
+	ret := []*LambdaInvocation{}
+	for _, invocation := range reconstructor.open {
+		if len(invocation.Events) > 0 {
+			invocation.End = time.UnixMilli(aws.Int64Value(invocation.Events[len(invocation.Events)-1].Timestamp))
+		}
+		ret = append(ret, invocation)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Start.Before(ret[j].Start) })
+	reconstructor.open = map[string]*LambdaInvocation{}
+	reconstructor.streamOpen = map[string][]string{}
+	return ret
+}
+
+// LambdaFunctionStats summarizes the complete invocations of a function, the rates are fractions of them.
+type LambdaFunctionStats struct {
+	FunctionName      string
+	Invocations       int
+	Incomplete        int
+	ColdStarts        int
+	Errors            int
+	ColdStartRate     float64
+	ErrorRate         float64
+	DurationP50Ms     float64
+	DurationP95Ms     float64
+	DurationP99Ms     float64
+	InitDurationP50Ms float64 `json:",omitempty"`
+	MaxMemoryUsedMB   int
+	MemorySizeMB      int
+}
+
+// percentile is the nearest rank percentile of the sorted values.
+func percentile(sorted []float64, percent float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(percent / 100 * float64(len(sorted))))
+	return sorted[max(rank, 1)-1]
+}
+
+// LambdaInvocationsStats groups the invocations by function, sorted by the function name.
+func LambdaInvocationsStats(invocations []*LambdaInvocation) []*LambdaFunctionStats {
+	byFunction := map[string][]*LambdaInvocation{}
+	for _, invocation := range invocations {
+		byFunction[invocation.FunctionName] = append(byFunction[invocation.FunctionName], invocation)
+	}
+
+	ret := []*LambdaFunctionStats{}
+	for functionName, functionInvocations := range byFunction {
+		stats := &LambdaFunctionStats{FunctionName: functionName}
+		durations, initDurations := []float64{}, []float64{}
+		for _, invocation := range functionInvocations {
+			if !invocation.Complete {
+				stats.Incomplete++
+				continue
+			}
+			stats.Invocations++
+			durations = append(durations, invocation.DurationMs)
+			if invocation.ColdStart {
+				stats.ColdStarts++
+			}
+			if invocation.InitDurationMs > 0 {
+				initDurations = append(initDurations, invocation.InitDurationMs)
+			}
+			if invocation.Failed() {
+				stats.Errors++
+			}
+			stats.MaxMemoryUsedMB = max(stats.MaxMemoryUsedMB, invocation.MaxMemoryUsedMB)
+			stats.MemorySizeMB = max(stats.MemorySizeMB, invocation.MemorySizeMB)
+		}
+		if stats.Invocations > 0 {
+			stats.ColdStartRate = float64(stats.ColdStarts) / float64(stats.Invocations)
+			stats.ErrorRate = float64(stats.Errors) / float64(stats.Invocations)
+		}
+		sort.Float64s(durations)
+		sort.Float64s(initDurations)
+		stats.DurationP50Ms, stats.DurationP95Ms, stats.DurationP99Ms = percentile(durations, 50), percentile(durations, 95), percentile(durations, 99)
+		stats.InitDurationP50Ms = percentile(initDurations, 50)
+		ret = append(ret, stats)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].FunctionName < ret[j].FunctionName })
+	return ret
+}
+
+// WriteLambdaStats prints the functions stats table.
+func WriteLambdaStats(writer io.Writer, stats []*LambdaFunctionStats) error {
+	table := tabwriter.NewWriter(writer, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "FUNCTION\tINVOCATIONS\tINCOMPLETE\tP50 MS\tP95 MS\tP99 MS\tCOLD STARTS\tERRORS\tMAX MEMORY MB")
+	for _, current := range stats {
+		fmt.Fprintf(table, "%s\t%d\t%d\t%.2f\t%.2f\t%.2f\t%.1f%%\t%.1f%%\t%d/%d\n", current.FunctionName, current.Invocations, current.Incomplete,
+			current.DurationP50Ms, current.DurationP95Ms, current.DurationP99Ms, current.ColdStartRate*100, current.ErrorRate*100,
+			current.MaxMemoryUsedMB, current.MemorySizeMB)
+	}
+	return table.Flush()
+}
@@ -0,0 +1,253 @@
+package aws_api
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	clients "github.com/AlexeyBeley/go_misc/aws_api/clients"
+	"github.com/AlexeyBeley/go_misc/aws_api/clients/fakes"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+)
+
+const (
+	firstRequestID  = "11111111-1111-1111-1111-111111111111"
+	secondRequestID = "22222222-2222-2222-2222-222222222222"
+	thirdRequestID  = "33333333-3333-3333-3333-333333333333"
+)
+
+// lambdaEvents are two environments streams: a cold start interleaved with a timeout, then a JSON format error.
+// The text format timeout line follows the REPORT of its invocation.
+func lambdaEvents(start int64) map[string][]*cloudwatchlogs.OutputLogEvent {
+	event := func(offset int64, message string) *cloudwatchlogs.OutputLogEvent {
+		return &cloudwatchlogs.OutputLogEvent{Timestamp: aws.Int64(start + offset), Message: aws.String(message)}
+	}
+	return map[string][]*cloudwatchlogs.OutputLogEvent{
+		"2025/01/01/[$LATEST]first": {
+			event(0, "INIT_START Runtime Version: python:3.12.v40"),
+			event(10, "START RequestId: "+firstRequestID+" Version: $LATEST"),
+			event(20, "handling the first request"),
+			event(30, "REPORT RequestId: "+firstRequestID+"\tDuration: 12.50 ms\tBilled Duration: 13 ms\tMemory Size: 128 MB\tMax Memory Used: 70 MB\tInit Duration: 150.12 ms\t"),
+			event(40, "START RequestId: "+secondRequestID+" Version: $LATEST"),
+			event(3040, "REPORT RequestId: "+secondRequestID+"\tDuration: 3000.00 ms\tBilled Duration: 3000 ms\tMemory Size: 128 MB\tMax Memory Used: 90 MB\t"),
+			event(3050, "2025-01-01T00:00:03.050Z "+secondRequestID+" Task timed out after 3.00 seconds"),
+			event(3060, "a line after the report"),
+		},
+		"2025/01/01/[$LATEST]second": {
+			event(100, `{"time":"2025-01-01T00:00:00.100Z","type":"platform.start","record":{"requestId":"`+thirdRequestID+`","version":"$LATEST"}}`),
+			event(110, `{"timestamp":"2025-01-01T00:00:00.110Z","level":"ERROR","message":"Invoke Error","requestId":"`+thirdRequestID+`"}`),
+			event(120, `{"time":"2025-01-01T00:00:00.120Z","type":"platform.report","record":{"requestId":"`+thirdRequestID+`","status":"error","errorType":"Runtime.Unknown",`+
+				`"metrics":{"durationMs":40.5,"billedDurationMs":41,"memorySizeMB":256,"maxMemoryUsedMB":100}}}`),
+			event(130, "START RequestId: 44444444-4444-4444-4444-444444444444 Version: $LATEST"),
+			event(140, "still running"),
+		},
+	}
+}
+
+func TestLambdaInvocationReconstructor(t *testing.T) {
+	t.Run("Valid run", func(t *testing.T) {
+		report, err := ParseReportLine("REPORT RequestId: " + firstRequestID + "\tDuration: 2.50 ms\tBilled Duration: 3 ms\tMemory Size: 128 MB\tMax Memory Used: 70 MB\tInit Duration: 150.12 ms")
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if *report != (LambdaReport{RequestID: firstRequestID, DurationMs: 2.5, BilledDurationMs: 3, MemorySizeMB: 128, MaxMemoryUsedMB: 70, InitDurationMs: 150.12}) {
+			t.Errorf("unexpected report: %+v", report)
+		}
+		if _, err := ParseReportLine("START RequestId: " + firstRequestID); err == nil {
+			t.Errorf("expected an error")
+		}
+	})
+
+	t.Run("Interleaved streams", func(t *testing.T) {
+		reconstructor := LambdaInvocationReconstructorNew("handler")
+		events := lambdaEvents(1735689600000)
+		first, second := events["2025/01/01/[$LATEST]first"], events["2025/01/01/[$LATEST]second"]
+		merged := []*LambdaInvocation{}
+		process := func(logStreamName string, event *cloudwatchlogs.OutputLogEvent) {
+			invocation, err := reconstructor.Process(logStreamName, event)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if invocation != nil {
+				merged = append(merged, invocation)
+			}
+		}
+		for index := range max(len(first), len(second)) {
+			if index < len(first) {
+				process("2025/01/01/[$LATEST]first", first[index])
+			}
+			if index < len(second) {
+				process("2025/01/01/[$LATEST]second", second[index])
+			}
+		}
+		merged = append(merged, reconstructor.Flush()...)
+
+		if len(merged) != 4 {
+			t.Fatalf("unexpected invocations: %v", merged)
+		}
+		failed, cold, timeout, incomplete := merged[0], merged[1], merged[2], merged[3]
+		if cold.RequestID != firstRequestID || !cold.ColdStart || len(cold.Events) != 3 || cold.End.Sub(cold.Start) != 20*time.Millisecond {
+			t.Errorf("unexpected cold start invocation: %s, %d events", cold, len(cold.Events))
+		}
+		if timeout.RequestID != secondRequestID || timeout.ColdStart || timeout.Status != InvocationStatusTimeout || !timeout.Failed() {
+			t.Errorf("unexpected timed out invocation: %s", timeout)
+		}
+		if failed.RequestID != thirdRequestID || failed.Status != InvocationStatusError || failed.ErrorType != "Runtime.Unknown" || failed.MemorySizeMB != 256 {
+			t.Errorf("unexpected failed invocation: %s", failed)
+		}
+		if incomplete.Complete || len(incomplete.Events) != 2 || incomplete.End.Sub(incomplete.Start) != 10*time.Millisecond {
+			t.Errorf("unexpected incomplete invocation: %s", incomplete)
+		}
+		if reconstructor.Unattributed != 1 {
+			t.Errorf("unexpected unattributed events: %d", reconstructor.Unattributed)
+		}
+	})
+
+	t.Run("Lines after the report", func(t *testing.T) {
+		reconstructor := LambdaInvocationReconstructorNew("handler")
+		start := int64(1735689600000)
+		messages := []string{
+			"START RequestId: " + firstRequestID + " Version: $LATEST",
+			"REPORT RequestId: " + firstRequestID + "\tDuration: 3000.00 ms\tBilled Duration: 3000 ms\tMemory Size: 128 MB\tMax Memory Used: 90 MB\t",
+			"START RequestId: " + secondRequestID + " Version: $LATEST",
+			"2025-01-01T00:00:00.030Z " + firstRequestID + " Task timed out after 3.00 seconds",
+			"2025-01-01T00:00:00.040Z " + thirdRequestID + " INFO an unknown request",
+			"REPORT RequestId: " + secondRequestID + "\tDuration: 10.00 ms\tBilled Duration: 10 ms\tMemory Size: 128 MB\tMax Memory Used: 90 MB\t",
+		}
+		invocations := []*LambdaInvocation{}
+		for index, message := range messages {
+			invocation, err := reconstructor.Process("2025/01/01/[$LATEST]first", &cloudwatchlogs.OutputLogEvent{Timestamp: aws.Int64(start + int64(index)*10), Message: aws.String(message)})
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if invocation != nil {
+				invocations = append(invocations, invocation)
+			}
+		}
+		if len(invocations) != 2 || len(reconstructor.Flush()) != 0 {
+			t.Fatalf("unexpected invocations: %v", invocations)
+		}
+		if invocations[0].Status != InvocationStatusTimeout || invocations[1].Failed() || len(invocations[1].Events) != 2 {
+			t.Errorf("unexpected invocations status: %v", invocations)
+		}
+		if reconstructor.Unattributed != 1 {
+			t.Errorf("unexpected unattributed events: %d", reconstructor.Unattributed)
+		}
+	})
+
+	t.Run("Stats", func(t *testing.T) {
+		invocations := []*LambdaInvocation{}
+		for index := range 20 {
+			invocation := &LambdaInvocation{FunctionName: "handler", Complete: true,
+				LambdaReport: LambdaReport{DurationMs: float64(index + 1), MemorySizeMB: 128, MaxMemoryUsedMB: 60 + index}}
+			if index < 2 {
+				invocation.ColdStart, invocation.InitDurationMs = true, 200
+			}
+			if index == 19 {
+				invocation.Status = InvocationStatusTimeout
+			}
+			invocations = append(invocations, invocation)
+		}
+		invocations = append(invocations, &LambdaInvocation{FunctionName: "handler"}, &LambdaInvocation{FunctionName: "another", Complete: true})
+
+		stats := LambdaInvocationsStats(invocations)
+		if len(stats) != 2 || stats[0].FunctionName != "another" {
+			t.Fatalf("unexpected stats: %+v", stats)
+		}
+		handler := stats[1]
+		if handler.Invocations != 20 || handler.Incomplete != 1 || handler.ColdStartRate != 0.1 || handler.ErrorRate != 0.05 {
+			t.Errorf("unexpected counts: %+v", handler)
+		}
+		if handler.DurationP50Ms != 10 || handler.DurationP95Ms != 19 || handler.DurationP99Ms != 20 || handler.InitDurationP50Ms != 200 || handler.MaxMemoryUsedMB != 79 {
+			t.Errorf("unexpected percentiles: %+v", handler)
+		}
+
+		table := &bytes.Buffer{}
+		if err := WriteLambdaStats(table, stats); err != nil {
+			t.Fatalf("%v", err)
+		}
+		if !strings.Contains(table.String(), "handler   20           1           10.00   19.00   20.00   10.0%        5.0%    79/128") {
+			t.Errorf("unexpected table:\n%s", table)
+		}
+	})
+
+	t.Run("Save all logs", func(t *testing.T) {
+		start := time.Now().UTC().Add(-time.Hour).UnixMilli()
+		services := fakes.ServicesNew()
+		for logStreamName, events := range lambdaEvents(start) {
+			services.CloudwatchLogs.LogStreams["/aws/lambda/handler"] = append(services.CloudwatchLogs.LogStreams["/aws/lambda/handler"],
+				&cloudwatchlogs.LogStream{LogStreamName: aws.String(logStreamName), LastEventTimestamp: events[len(events)-1].Timestamp})
+			services.CloudwatchLogs.Events[fakes.EventsKey("/aws/lambda/handler", logStreamName)] = events
+		}
+		services.CloudwatchLogs.LogStreams["/aws/lambda/handler"] = append(services.CloudwatchLogs.LogStreams["/aws/lambda/handler"],
+			&cloudwatchlogs.LogStream{LogStreamName: aws.String("expired"), LastEventTimestamp: aws.Int64(start - 8*24*time.Hour.Milliseconds())})
+		services.CloudwatchLogs.Events[fakes.EventsKey("/aws/lambda/handler", "expired")] = []*cloudwatchlogs.OutputLogEvent{
+			{Timestamp: aws.Int64(start - 8*24*time.Hour.Milliseconds()), Message: aws.String("START RequestId: 55555555-5555-5555-5555-555555555555 Version: $LATEST")}}
+
+		outputFolderPath := t.TempDir()
+		input := &LambdaLogsActionInput{LambdaName: aws.String("handler"), LogGroup: aws.String("/aws/lambda/handler"),
+			OutputFolderPath: aws.String(outputFolderPath), GroupRetentionInDays: aws.Int64(7)}
+		table := &bytes.Buffer{}
+		filePaths, err := (&AWSLogAnalizer{}).SaveAllLambdasLogs(clients.CloudwatchLogsAPINewWithService(services.CloudwatchLogs), input, table)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if len(filePaths) != 4 {
+			t.Fatalf("unexpected files: %v", aws.StringValueSlice(filePaths))
+		}
+		data, err := os.ReadFile(filepath.Join(outputFolderPath, strconv.FormatInt(start+10, 10)+"-"+firstRequestID+".json"))
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		saved := &LambdaInvocation{}
+		if err := json.Unmarshal(data, saved); err != nil || !saved.ColdStart || len(saved.Events) != 3 {
+			t.Errorf("unexpected saved invocation: %v\n%s", err, data)
+		}
+		// The timeout line follows the report.
+		data, err = os.ReadFile(filepath.Join(outputFolderPath, strconv.FormatInt(start+40, 10)+"-"+secondRequestID+".json"))
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		saved = &LambdaInvocation{}
+		if err := json.Unmarshal(data, saved); err != nil || saved.Status != InvocationStatusTimeout {
+			t.Errorf("the saved invocation must be timed out: %v\n%s", err, data)
+		}
+
+		file, err := os.Open(filepath.Join(outputFolderPath, "invocations.jsonl"))
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		defer file.Close()
+		records := 0
+		for scanner := bufio.NewScanner(file); scanner.Scan(); records++ {
+			record := &LambdaInvocation{}
+			if err := json.Unmarshal(scanner.Bytes(), record); err != nil || len(record.Events) != 0 {
+				t.Errorf("unexpected record: %v\n%s", err, scanner.Text())
+			}
+		}
+		if records != 4 {
+			t.Errorf("unexpected records: %d", records)
+		}
+		stats := []*LambdaFunctionStats{}
+		data, err = os.ReadFile(filepath.Join(outputFolderPath, "stats.json"))
+		if err != nil || json.Unmarshal(data, &stats) != nil || len(stats) != 1 || stats[0].Invocations != 3 || stats[0].Incomplete != 1 {
+			t.Errorf("unexpected stats: %v\n%s", err, data)
+		}
+		if !strings.HasPrefix(table.String(), "FUNCTION") {
+			t.Errorf("unexpected table:\n%s", table)
+		}
+
+		services.CloudwatchLogs.EventsErrors = map[string]error{fakes.EventsKey("/aws/lambda/handler", "2025/01/01/[$LATEST]second"): errors.New("throttled")}
+		if _, err := (&AWSLogAnalizer{}).SaveAllLambdasLogs(clients.CloudwatchLogsAPINewWithService(services.CloudwatchLogs), input, &bytes.Buffer{}); err == nil {
+			t.Errorf("expected an error for a stream that can not be fetched")
+		}
+	})
+}
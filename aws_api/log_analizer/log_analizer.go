@@ -1,13 +1,16 @@
 package aws_api
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"path/filepath"
+	"slices"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/AlexeyBeley/go_misc/logger"
@@ -116,22 +119,19 @@ func (AwsLogAnalizer *AWSLogAnalizer) LambdaLogsAction() error {
 func (AwsLogAnalizer *AWSLogAnalizer) InitLogGroup(input *LambdaLogsActionInput) (*string, error) {
 	lambda_api := clients.LambdaAPINew(input.Region, input.AWSProfile)
 	var foundLambda *lambda.FunctionConfiguration
-	Finder := func(lammbdaAny any) (continuePagination bool, err error) {
-		lambda, ok := lammbdaAny.(*lambda.FunctionConfiguration)
-		if !ok {
-			return false, fmt.Errorf("cast error %v ", lammbdaAny)
+	for function, err := range lambda_api.IterFunctions(context.Background(), &lambda.ListFunctionsInput{}) {
+		if err != nil {
+			return nil, err
 		}
 
-		if *lambda.FunctionName == *input.LambdaName {
-			foundLambda = lambda
-			return false, nil
+		if *function.FunctionName == *input.LambdaName {
+			foundLambda = function
+			break
 		}
-		return true, nil
 	}
 
-	err := lambda_api.YieldFunctions(Finder, &lambda.ListFunctionsInput{})
-	if err != nil {
-		return nil, err
+	if foundLambda == nil {
+		return nil, fmt.Errorf("lambda was not found: %s", *input.LambdaName)
 	}
 
 	logs_api := clients.CloudwatchLogsAPINew(input.Region, input.AWSProfile)
@@ -150,48 +150,44 @@ func (AwsLogAnalizer *AWSLogAnalizer) InitLogGroup(input *LambdaLogsActionInput)
 	return foundLambda.LoggingConfig.LogGroup, nil
 }
 
-func GetSingleLambdaRunLogsInitializer(objects *[]any, input *LambdaLogsActionInput) func(any) (bool, error) {
-
-	return func(streamAny any) (bool, error) {
-		stream, ok := streamAny.(*cloudwatchlogs.LogStream)
-		if !ok {
-			return false, fmt.Errorf("cast error: %v", streamAny)
-		}
+func GetSingleLambdaRunLogsInitializer(objects *[]*cloudwatchlogs.LogStream, input *LambdaLogsActionInput) func(*cloudwatchlogs.LogStream) bool {
 
+	return func(stream *cloudwatchlogs.LogStream) bool {
 		//descending means when seeing old stream - stop the pagination.
 		if *stream.LastEventTimestamp < *input.PointInTime {
-			return false, nil
+			return false
 		}
 
 		//descending means new streams should be ignored
 		if *stream.FirstEventTimestamp > *input.PointInTime {
-			return true, nil
+			return true
 		}
 
 		*objects = append(*objects, stream)
 
-		return true, nil
+		return true
 	}
 }
 
 func (awsLogAnalizer *AWSLogAnalizer) GetLambdaPointInTimeLogs(input *LambdaLogsActionInput) ([]*string, error) {
 	logs_api := clients.CloudwatchLogsAPINew(input.Region, input.AWSProfile)
-	objects := make([]any, 0)
+	objects := make([]*cloudwatchlogs.LogStream, 0)
+	collector := GetSingleLambdaRunLogsInitializer(&objects, input)
 
-	err := logs_api.YieldCloudwatchLogStreams(&cloudwatchlogs.DescribeLogStreamsInput{
+	for stream, err := range logs_api.IterLogStreams(context.Background(), &cloudwatchlogs.DescribeLogStreamsInput{
 		LogGroupName: input.LogGroup,
 		OrderBy:      clients.StrPtr("LastEventTime"),
 		Descending:   clients.BoolPtr(true),
-	}, GetSingleLambdaRunLogsInitializer(&objects, input))
-	if err != nil {
-		return nil, err
+	}) {
+		if err != nil {
+			return nil, err
+		}
+		if !collector(stream) {
+			break
+		}
 	}
 
-	for _, logStreamAny := range objects {
-		logStream, ok := logStreamAny.(*cloudwatchlogs.LogStream)
-		if !ok {
-			return nil, fmt.Errorf("cast error: %v", logStreamAny)
-		}
+	for _, logStream := range objects {
 		output, err := awsLogAnalizer.GetLambdaPointInTimeLogsFromLogStream(logs_api, logStream, input)
 		if err != nil {
 			return nil, err
@@ -204,56 +200,42 @@ func (awsLogAnalizer *AWSLogAnalizer) GetLambdaPointInTimeLogs(input *LambdaLogs
 }
 
 func (awsLogAnalizer *AWSLogAnalizer) GetLambdaPointInTimeLogsFromLogStream(logs_api *clients.CloudwatchLogsAPI, logStream *cloudwatchlogs.LogStream, input *LambdaLogsActionInput) ([]*string, error) {
-	objects := []any{}
-
-	logs_api.YieldStreamEvents(&cloudwatchlogs.GetLogEventsInput{
+	objects, err := clients.Collect(logs_api.IterStreamEvents(context.Background(), &cloudwatchlogs.GetLogEventsInput{
 		StartFromHead: clients.BoolPtr(true),
 		LogGroupName:  input.LogGroup,
 		LogStreamName: logStream.LogStreamName,
 		EndTime:       clients.Int64Ptr(*input.PointInTime + 15*1000*60),
-		StartTime:     clients.Int64Ptr(*input.PointInTime - 15*1000*60)}, clients.AggregatorInitializerNG(&objects))
-
-	lambdaStarted := false
-	lambdaEvents := []*cloudwatchlogs.OutputLogEvent{}
-	for _, eventAny := range objects {
-		event, ok := eventAny.(*cloudwatchlogs.OutputLogEvent)
-		if !ok {
-			return nil, fmt.Errorf("cast error: %v", eventAny)
-		}
-
-		if strings.Contains(*event.Message, "START RequestId") {
-			if lambdaStarted {
-				return nil, fmt.Errorf("unsupported state: recording started lambda received a start lambda line: %s", *event.Message)
-			}
-			lambdaEvents = lambdaEvents[:0]
-		}
-
-		lambdaEvents = append(lambdaEvents, event)
+		StartTime:     clients.Int64Ptr(*input.PointInTime - 15*1000*60)}))
+	if err != nil {
+		return nil, err
+	}
 
-		if lambdaStarted {
-			if strings.Contains(*event.Message, "REPORT RequestId") {
-				break
-			}
-			continue
+	// Invocations of the stream are keyed by RequestId, the one running at the point in time, or the next one, is saved.
+	reconstructor := LambdaInvocationReconstructorNew(*input.LambdaName)
+	invocations := []*LambdaInvocation{}
+	for _, event := range objects {
+		invocation, err := reconstructor.Process(*logStream.LogStreamName, event)
+		if err != nil {
+			return nil, err
 		}
-
-		if *event.Timestamp >= *input.PointInTime {
-			lambdaStarted = true
+		if invocation != nil {
+			invocations = append(invocations, invocation)
 		}
 	}
-
-	//*"START RequestId: f875b1f4-0646-46f5-93d6-a16d8d96b092 Version: $LATEST\n"
-	startRequestId := strings.Split((*lambdaEvents[0].Message), " ")[2]
-
-	//Why? That's WHY!!!
-	// lambda start request 'f875b1f4-0646-46f5-93d6-a16d8d96b092' does not match the end request 'f875b1f4-0646-46f5-93d6-a16d8d96b092	Duration:'
-	newLastEventString := strings.ReplaceAll(*lambdaEvents[len(lambdaEvents)-1].Message, "\t", " ")
-
-	endRequestId := strings.Split(newLastEventString, " ")[2]
-
-	if startRequestId != endRequestId {
-		return nil, fmt.Errorf("lambda start request '%s' does not match the end request '%s'", startRequestId, endRequestId)
+	invocations = append(invocations, reconstructor.Flush()...)
+
+	pointInTime := time.UnixMilli(*input.PointInTime)
+	index := slices.IndexFunc(invocations, func(invocation *LambdaInvocation) bool {
+		return !invocation.Start.After(pointInTime) && !invocation.End.Before(pointInTime)
+	})
+	if index < 0 {
+		// The next invocation after the point in time.
+		index = slices.IndexFunc(invocations, func(invocation *LambdaInvocation) bool { return invocation.Start.After(pointInTime) })
+	}
+	if index < 0 {
+		return nil, fmt.Errorf("no lambda invocation at %s in stream %s", pointInTime.UTC().Format(time.RFC3339Nano), *logStream.LogStreamName)
 	}
+	lambdaEvents := invocations[index].Events
 	lg.InfoF("Fetched %d events", len(lambdaEvents))
 
 	jsonData, err := json.MarshalIndent(lambdaEvents, "", "  ")
@@ -305,19 +287,14 @@ func (AwsLogAnalizer *AWSLogAnalizer) GetAllLamdasLogsAction() error {
 	return nil
 }
 
-func SaveAllLamdasLogs(logs_api *clients.CloudwatchLogsAPI, input *LambdaLogsActionInput) func(any) (bool, error) {
-	var lambdaStarted = false
-	lambdaEvents := []*cloudwatchlogs.OutputLogEvent{}
+// SaveAllLamdasLogs reconstructs the invocations of the streams, each complete invocation is appended to invocations.
+// The files are written by SaveAllLambdasLogs once all the streams are read, lines after a report still update its status.
+func SaveAllLamdasLogs(logs_api *clients.CloudwatchLogsAPI, input *LambdaLogsActionInput, reconstructor *LambdaInvocationReconstructor, invocations *[]*LambdaInvocation) func(*cloudwatchlogs.LogStream) (bool, error) {
 	StreamsCounter := 0
-	return func(streamAny any) (bool, error) {
-		logStream, ok := streamAny.(*cloudwatchlogs.LogStream)
-		if !ok {
-			return false, fmt.Errorf("cast error: %v", streamAny)
-		}
-
-		// Todo: remove
-		// Skip old streams
-		if *logStream.FirstEventTimestamp < 1754334000000-30*60*1000 {
+	startTime := (time.Now().UTC().Unix() - *input.GroupRetentionInDays*24*60*60) * 1000
+	return func(logStream *cloudwatchlogs.LogStream) (bool, error) {
+		// Streams older than the retention have no events left.
+		if logStream.LastEventTimestamp != nil && *logStream.LastEventTimestamp < startTime {
 			return true, nil
 		}
 
@@ -325,68 +302,114 @@ func SaveAllLamdasLogs(logs_api *clients.CloudwatchLogsAPI, input *LambdaLogsAct
 
 		StreamsCounter++
 
-		err := logs_api.YieldStreamEvents(&cloudwatchlogs.GetLogEventsInput{
+		for event, err := range logs_api.IterStreamEvents(context.Background(), &cloudwatchlogs.GetLogEventsInput{
 			StartFromHead: clients.BoolPtr(true),
 			LogGroupName:  input.LogGroup,
 			LogStreamName: logStream.LogStreamName,
-			StartTime:     clients.Int64Ptr((time.Now().UTC().Unix() - *input.GroupRetentionInDays*24*60*60) * 1000),
-		},
-			func(eventAny any) (bool, error) {
-				event, ok := eventAny.(*cloudwatchlogs.OutputLogEvent)
-				if !ok {
-					return false, fmt.Errorf("cast error: %v", eventAny)
-				}
-
-				if strings.Contains(*event.Message, "START RequestId") {
-					if lambdaStarted {
-						if *lambdaEvents[len(lambdaEvents)-1].Message != *event.Message {
-							return false, fmt.Errorf("unsupported state: recording started lambda received a start lambda line: %s", *event.Message)
-						}
-					}
-
-					lambdaStarted = true
-				} else if !lambdaStarted {
-					return true, nil
-				}
-
-				lambdaEvents = append(lambdaEvents, event)
-
-				// end
-				if strings.Contains(*event.Message, "REPORT RequestId") {
-					filePath := *input.OutputFolderPath + "/" + strconv.Itoa(int(*lambdaEvents[0].Timestamp)) + ".json"
-					jsonData, err := json.MarshalIndent(lambdaEvents, "", "  ")
-					if err != nil {
-						return false, err
-					}
-
-					err = os.WriteFile(filePath, jsonData, 0644)
-					if err != nil {
-						return false, err
-					}
-
-					lambdaEvents = lambdaEvents[:0]
-					lambdaStarted = false
-				}
-				return true, nil
-
-			})
-		if err != nil {
-			return false, nil
+			StartTime:     clients.Int64Ptr(startTime),
+		}) {
+			if err != nil {
+				return false, fmt.Errorf("[log_analizer:SaveAllLamdasLogs] Fetching events from stream %s\n%w", *logStream.LogStreamName, err)
+			}
+
+			invocation, err := reconstructor.Process(*logStream.LogStreamName, event)
+			if err != nil {
+				lg.WarningF("Stream %s: %v", *logStream.LogStreamName, err)
+				continue
+			}
+			if invocation == nil {
+				continue
+			}
+			*invocations = append(*invocations, invocation)
 		}
 
 		return true, nil
 	}
 }
+
+func lambdaInvocationFilePath(outputFolderPath string, invocation *LambdaInvocation) string {
+	return filepath.Join(outputFolderPath, strconv.FormatInt(invocation.Start.UnixMilli(), 10)+"-"+invocation.RequestID+".json")
+}
+
+func SaveLambdaInvocation(outputFolderPath string, invocation *LambdaInvocation) error {
+	jsonData, err := json.MarshalIndent(invocation, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(lambdaInvocationFilePath(outputFolderPath, invocation), jsonData, 0644)
+}
+
+// WriteLambdaInvocations writes the invocations records as JSON lines and the functions stats as JSON,
+// the stats table is printed to the writer.
+func WriteLambdaInvocations(outputFolderPath string, invocations []*LambdaInvocation, writer io.Writer) error {
+	file, err := os.Create(filepath.Join(outputFolderPath, "invocations.jsonl"))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	encoder := json.NewEncoder(file)
+	for _, invocation := range invocations {
+		if err := encoder.Encode(invocation); err != nil {
+			return err
+		}
+	}
+
+	stats := LambdaInvocationsStats(invocations)
+	jsonData, err := json.MarshalIndent(stats, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile(filepath.Join(outputFolderPath, "stats.json"), jsonData, 0644)
+	if err != nil {
+		return err
+	}
+	return WriteLambdaStats(writer, stats)
+}
+
 func (awsLogAnalizer *AWSLogAnalizer) GetAllLambdasLogs(input *LambdaLogsActionInput) ([]*string, error) {
 	logs_api := clients.CloudwatchLogsAPINew(input.Region, input.AWSProfile)
+	return awsLogAnalizer.SaveAllLambdasLogs(logs_api, input, os.Stdout)
+}
+
+// SaveAllLambdasLogs saves the invocations of all the streams, the incomplete ones are kept in the records and stats.
+// Returns the saved invocations files.
+func (awsLogAnalizer *AWSLogAnalizer) SaveAllLambdasLogs(logs_api *clients.CloudwatchLogsAPI, input *LambdaLogsActionInput, writer io.Writer) ([]*string, error) {
+	reconstructor := LambdaInvocationReconstructorNew(*input.LambdaName)
+	invocations := []*LambdaInvocation{}
+	saver := SaveAllLamdasLogs(logs_api, input, reconstructor, &invocations)
 
-	err := logs_api.YieldCloudwatchLogStreams(&cloudwatchlogs.DescribeLogStreamsInput{
+	for stream, err := range logs_api.IterLogStreams(context.Background(), &cloudwatchlogs.DescribeLogStreamsInput{
 		LogGroupName: input.LogGroup,
 		OrderBy:      clients.StrPtr("LastEventTime"),
 		Descending:   clients.BoolPtr(false),
-	}, SaveAllLamdasLogs(logs_api, input))
-	if err != nil {
-		return nil, err
+	}) {
+		if err != nil {
+			return nil, err
+		}
+
+		continuePagination, err := saver(stream)
+		if err != nil {
+			return nil, err
+		}
+		if !continuePagination {
+			break
+		}
 	}
-	return nil, nil
+
+	invocations = append(invocations, reconstructor.Flush()...)
+	if reconstructor.Unattributed > 0 {
+		lg.WarningF("%d log events were not attributed to an invocation", reconstructor.Unattributed)
+	}
+
+	// Written at the end, the status of a complete invocation can change after its report.
+	ret := []*string{}
+	for _, invocation := range invocations {
+		if err := SaveLambdaInvocation(*input.OutputFolderPath, invocation); err != nil {
+			return nil, err
+		}
+		invocation.Events = nil
+		filePath := lambdaInvocationFilePath(*input.OutputFolderPath, invocation)
+		ret = append(ret, &filePath)
+	}
+	return ret, WriteLambdaInvocations(*input.OutputFolderPath, invocations, writer)
 }
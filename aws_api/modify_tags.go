@@ -1,484 +1,205 @@
 package aws_api
 
 import (
-	"strings"
+	"context"
+	"fmt"
+	"slices"
 
 	clients "github.com/AlexeyBeley/go_misc/aws_api/clients"
-	"github.com/aws/aws-sdk-go/service/autoscaling"
-	"github.com/aws/aws-sdk-go/service/cloudwatch"
-	"github.com/aws/aws-sdk-go/service/ec2"
+	inventory "github.com/AlexeyBeley/go_misc/aws_api/inventory"
 	"github.com/aws/aws-sdk-go/service/ecs"
-	"github.com/aws/aws-sdk-go/service/elbv2"
-	"github.com/aws/aws-sdk-go/service/rds"
-	"github.com/aws/aws-sdk-go/service/s3"
-	"github.com/aws/aws-sdk-go/service/secretsmanager"
 )
 
 type ModifyTagsConfig struct {
 	Region    string                       `json:"Region"`
 	AddTags   map[string]string            `json:"AddTags"`
 	PerRegion map[string]map[string]string `json:"PerRegion"`
+	// Tagging calls per second, DefaultTagRequestsPerSecond when not set.
+	RequestsPerSecond float64 `json:"RequestsPerSecond"`
+	// Builds the API clients, SDK backed when not set.
+	Clients *clients.Factory `json:"-"`
 }
 
-func (config *ModifyTagsConfig) InitFromM(source any) error {
-	mapValues, sucess := source.(map[string]any)
-	if !sucess {
-		panic(source)
+func (config *ModifyTagsConfig) getClients() *clients.Factory {
+	if config.Clients == nil {
+		return clients.FactoryNew(nil)
 	}
+	return config.Clients
+}
+
+// ForTarget narrows the config to the target region and account clients.
+// PerRegion tags of the target region are used, AddTags when the region has none.
+func (config ModifyTagsConfig) ForTarget(target clients.Target, factory *clients.Factory) ModifyTagsConfig {
+	ret := config
+	ret.Region = target.Region
+	ret.Clients = factory
 
-	region, sucess := mapValues["Region"].(string)
-	if !sucess {
-		panic(source)
+	tags, ok := config.PerRegion[target.Region]
+	if !ok {
+		tags = config.AddTags
 	}
+	ret.AddTags = tags
+	ret.PerRegion = map[string]map[string]string{target.Region: tags}
+	return ret
+}
 
-	(*config).Region = region
+// AddTagsAllTargets runs a tagging function, e.g. AddTagsInstances, in every executor target.
+func AddTagsAllTargets(ctx context.Context, executor *clients.Executor, config ModifyTagsConfig, addTags func(ModifyTagsConfig) error) *clients.Report[struct{}] {
+	report := clients.Execute(ctx, executor, func(ctx context.Context, target clients.Target, factory *clients.Factory) (struct{}, error) {
+		return struct{}{}, addTags(config.ForTarget(target, factory))
+	})
+	lg.InfoF("Tagging report: %s", report)
+	return report
+}
 
-	addTagsInterface, sucess := mapValues["AddTags"].(map[string]any)
-	if !sucess {
-		panic(source)
+func (config *ModifyTagsConfig) InitFromM(source any) error {
+	errorPrefix := "[modify_tags:InitFromM]"
+
+	mapValues, ok := source.(map[string]any)
+	if !ok {
+		return fmt.Errorf("%s expected an object, got: %v", errorPrefix, source)
 	}
 
-	(*config).AddTags = map[string]string{}
+	region, ok := mapValues["Region"].(string)
+	if !ok {
+		return fmt.Errorf("%s Region: expected a string, got: %v", errorPrefix, mapValues["Region"])
+	}
+
+	addTagsInterface, ok := mapValues["AddTags"].(map[string]any)
+	if !ok {
+		return fmt.Errorf("%s AddTags: expected an object, got: %v", errorPrefix, mapValues["AddTags"])
+	}
 
+	addTags := map[string]string{}
 	for key, value := range addTagsInterface {
-		valueString, sucess := value.(string)
-		if !sucess {
-			panic(source)
+		valueString, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%s AddTags.%s: expected a string, got: %v", errorPrefix, key, value)
 		}
-		(*config).AddTags[key] = valueString
+		addTags[key] = valueString
 	}
 
 	(*config).Region = region
-	// = addTags
+	(*config).AddTags = addTags
 	return nil
 }
 
-func AddTagsNetworkInterfaces(config ModifyTagsConfig) error {
-	for region, PerRegionTags := range config.PerRegion {
-		client := clients.GetEC2Client(&region)
-		api := clients.EC2APINew(&region, nil)
-		objects := make([]any, 0)
-		err := clients.DescribeNetworkInterfaces(client, clients.AggregatorInitializer(&objects), nil)
-		if err != nil {
-			return err
-		}
-
-		for _, anyObject := range objects {
-			nInt, ok := anyObject.(*ec2.NetworkInterface)
-			if !ok {
-				panic(anyObject)
-			}
-
-			createTagsOutput, err := api.CreateTags(nInt.TagSet, PerRegionTags, nInt.NetworkInterfaceId, false)
-			if err != nil {
-				ret := err.Error()
-				if strings.Contains(ret, "does not exist") {
-					continue
-				}
-				return err
-			}
-			lg.InfoF("%s", createTagsOutput)
-
-		}
-	}
-	return nil
+// The AddTags* functions apply a single rule tag policy to one resource type.
+// Not declarative ones keep the existing values: the tags are required with the configured value as default.
+// Declarative ones overwrite the differing values.
+func addTagsPolicy(resourceType string, tags map[string]string, declarative bool, requestsPerSecond float64) *TagPolicy {
+	rule := &TagRule{Name: "AddTags", Select: TagSelector{Types: []string{resourceType}}}
+	if declarative {
+		rule.Set = tags
+	} else {
+		rule.Require = tags
+	}
+	return &TagPolicy{Rules: []*TagRule{rule}, RequestsPerSecond: requestsPerSecond}
 }
 
-func AddTagsNatGateways(config ModifyTagsConfig) error {
-	client := clients.GetEC2Client(&config.Region)
-	api := clients.EC2APINew(&config.Region, nil)
-	objects := make([]any, 0)
-	err := clients.DescribeNatGateways(client, clients.AggregatorInitializer(&objects), nil)
+// The account is not resolved: the resources are tagged by the ids and arns the services return.
+func addTags(config ModifyTagsConfig, region string, tags map[string]string, resourceType string, declarative bool) error {
+	scope := &inventory.Scope{Region: region, Clients: config.getClients()}
+	plan, err := ApplyTagPolicy(context.Background(), scope, addTagsPolicy(resourceType, tags, declarative, config.RequestsPerSecond))
 	if err != nil {
 		return err
 	}
-
-	for _, anyObject := range objects {
-		obj, ok := anyObject.(*ec2.NatGateway)
-		if !ok {
-			panic(anyObject)
-		}
-
-		createTagsOutput, err := api.CreateTags(obj.Tags, config.AddTags, obj.NatGatewayId, false)
-		if err != nil {
-			ret := err.Error()
-			lg.InfoF("%s", ret)
-			return err
-		}
-		lg.InfoF("Create tags response: %s", createTagsOutput)
-
-	}
+	lg.InfoF("%s %s: applied %d tag changes", region, resourceType, len(plan.Changes))
 	return nil
 }
 
-func AddTagsInstances(config ModifyTagsConfig) error {
-	client := clients.GetEC2Client(&config.Region)
-	api := clients.EC2APINew(&config.Region, nil)
-	objects := make([]any, 0)
-	err := clients.DescribeInstances(client, clients.AggregatorInitializer(&objects), nil)
-	if err != nil {
-		return err
-	}
-
-	for _, anyObject := range objects {
-		obj, ok := anyObject.(*ec2.Instance)
-		if !ok {
-			panic(anyObject)
-		}
-
-		createTagsOutput, err := api.CreateTags(obj.Tags, config.AddTags, obj.InstanceId, false)
+func addTagsPerRegion(config ModifyTagsConfig, resourceType string, declarative bool) error {
+	for region, perRegionTags := range config.PerRegion {
+		err := addTags(config, region, perRegionTags, resourceType, declarative)
 		if err != nil {
-			ret := err.Error()
-			lg.InfoF("%s", ret)
 			return err
 		}
-		lg.InfoF("Create tags response: %s", createTagsOutput)
-
 	}
 	return nil
 }
 
-func AddTagsElasticIps(config ModifyTagsConfig) error {
-	api := clients.EC2APINew(&config.Region, nil)
-	objects := make([]any, 0)
-	err := api.DescribeAddresses(clients.AggregatorInitializer(&objects), nil)
-	if err != nil {
-		return err
-	}
+func AddTagsNetworkInterfaces(config ModifyTagsConfig) error {
+	return addTagsPerRegion(config, "ec2:network-interface", false)
+}
 
-	for _, anyObject := range objects {
-		obj, ok := anyObject.(*ec2.Address)
-		if !ok {
-			panic(anyObject)
-		}
+func AddTagsNatGateways(config ModifyTagsConfig) error {
+	return addTags(config, config.Region, config.AddTags, "ec2:natgateway", false)
+}
 
-		createTagsOutput, err := api.CreateTags(obj.Tags, config.AddTags, obj.AllocationId, false)
-		if err != nil {
-			ret := err.Error()
-			lg.InfoF("%s", ret)
-			return err
-		}
-		lg.InfoF("Create tags response: %s", createTagsOutput)
+func AddTagsInstances(config ModifyTagsConfig) error {
+	return addTags(config, config.Region, config.AddTags, "ec2:instance", false)
+}
 
-	}
-	return nil
+func AddTagsElasticIps(config ModifyTagsConfig) error {
+	return addTags(config, config.Region, config.AddTags, "ec2:elastic-ip", false)
 }
 
 func AddTagsVolumes(config ModifyTagsConfig) error {
-	api := clients.EC2APINew(&config.Region, nil)
-	objects := make([]any, 0)
-	err := api.DescribeVolumes(clients.AggregatorInitializer(&objects), nil)
-	if err != nil {
-		return err
-	}
-
-	for _, anyObject := range objects {
-		obj, ok := anyObject.(*ec2.Volume)
-		if !ok {
-			panic(anyObject)
-		}
-
-		createTagsOutput, err := api.CreateTags(obj.Tags, config.AddTags, obj.VolumeId, false)
-		if err != nil {
-			ret := err.Error()
-			lg.InfoF("%s", ret)
-			return err
-		}
-		lg.InfoF("Create tags response: %s", createTagsOutput)
-
-	}
-	return nil
+	return addTags(config, config.Region, config.AddTags, "ec2:volume", false)
 }
 
 func AddTagsLaunchTemplates(config ModifyTagsConfig) error {
-	api := clients.EC2APINew(&config.Region, nil)
-	objects := make([]any, 0)
-	err := api.DescribeLaunchTemplates(clients.AggregatorInitializer(&objects), nil)
-	if err != nil {
-		return err
-	}
-
-	for _, anyObject := range objects {
-		obj, ok := anyObject.(*ec2.LaunchTemplate)
-		if !ok {
-			panic(anyObject)
-		}
-
-		createTagsOutput, err := api.CreateTags(obj.Tags, config.AddTags, obj.LaunchTemplateId, false)
-		if err != nil {
-			ret := err.Error()
-			lg.InfoF("%s", ret)
-			return err
-		}
-		lg.InfoF("Create tags response: %s", createTagsOutput)
-
-	}
-	return nil
+	return addTags(config, config.Region, config.AddTags, "ec2:launch-template", false)
 }
 
 func AddTagsImages(config ModifyTagsConfig) error {
-	api := clients.EC2APINew(&config.Region, nil)
-	objects := make([]any, 0)
-	self := "self"
-	input := ec2.DescribeImagesInput{Owners: []*string{&self}}
-	err := api.DescribeImages(clients.AggregatorInitializer(&objects), &input)
-	if err != nil {
-		return err
-	}
-
-	for _, anyObject := range objects {
-		obj, ok := anyObject.(*ec2.Image)
-		if !ok {
-			panic(anyObject)
-		}
-
-		createTagsOutput, err := api.CreateTags(obj.Tags, config.AddTags, obj.ImageId, false)
-		if err != nil {
-			ret := err.Error()
-			lg.InfoF("%s", ret)
-			return err
-		}
-		lg.InfoF("Create tags response: %s", createTagsOutput)
-
-	}
-	return nil
+	return addTags(config, config.Region, config.AddTags, "ec2:image", false)
 }
 
 func AddTagsSnapshots(config ModifyTagsConfig) error {
-	api := clients.EC2APINew(&config.Region, nil)
-	objects := make([]any, 0)
-	self := "self"
-	input := ec2.DescribeSnapshotsInput{OwnerIds: []*string{&self}}
-	err := api.DescribeSnapshots(clients.AggregatorInitializer(&objects), &input)
-	if err != nil {
-		return err
-	}
-
-	for _, anyObject := range objects {
-		obj, ok := anyObject.(*ec2.Snapshot)
-		if !ok {
-			panic(anyObject)
-		}
-
-		createTagsOutput, err := api.CreateTags(obj.Tags, config.AddTags, obj.SnapshotId, false)
-		if err != nil {
-			ret := err.Error()
-			lg.InfoF("%s", ret)
-			return err
-		}
-		lg.InfoF("Create tags response: %s", createTagsOutput)
-
-	}
-	return nil
+	return addTags(config, config.Region, config.AddTags, "ec2:snapshot", false)
 }
 
 func AddTagsKeyPairs(config ModifyTagsConfig) error {
-	api := clients.EC2APINew(&config.Region, nil)
-	objects := make([]any, 0)
-	err := api.DescribeKeyPairs(clients.AggregatorInitializer(&objects), nil)
-	if err != nil {
-		return err
-	}
-
-	for _, anyObject := range objects {
-		obj, ok := anyObject.(*ec2.KeyPairInfo)
-		if !ok {
-			panic(anyObject)
-		}
-
-		createTagsOutput, err := api.CreateTags(obj.Tags, config.AddTags, obj.KeyPairId, false)
-		if err != nil {
-			ret := err.Error()
-			lg.InfoF("%s", ret)
-			return err
-		}
-		lg.InfoF("Create tags response: %s", createTagsOutput)
-
-	}
-	return nil
+	return addTags(config, config.Region, config.AddTags, "ec2:key-pair", false)
 }
 
 func AddTagsSecurityGroups(config ModifyTagsConfig) error {
-	api := clients.EC2APINew(&config.Region, nil)
-	objects := make([]any, 0)
-	err := api.DescribeSecurityGroups(clients.AggregatorInitializer(&objects), nil)
-	if err != nil {
-		return err
-	}
-
-	for _, anyObject := range objects {
-		obj, ok := anyObject.(*ec2.SecurityGroup)
-		if !ok {
-			panic(anyObject)
-		}
-
-		createTagsOutput, err := api.CreateTags(obj.Tags, config.AddTags, obj.GroupId, false)
-		if err != nil {
-			ret := err.Error()
-			lg.InfoF("%s", ret)
-			return err
-		}
-		lg.InfoF("Create tags response: %s", createTagsOutput)
-
-	}
-	return nil
+	return addTags(config, config.Region, config.AddTags, "ec2:security-group", false)
 }
 
 func AddTagsLoadBalancers(config ModifyTagsConfig) error {
-	api := clients.ELBV2APINew(&config.Region, nil)
-	objects := make([]any, 0)
-	err := api.DescribeLoadBalancers(clients.AggregatorInitializer(&objects), nil)
-	if err != nil {
-		return err
-	}
-
-	for _, anyObject := range objects {
-		obj, ok := anyObject.(*elbv2.LoadBalancer)
-		if !ok {
-			panic(anyObject)
-		}
-
-		createTagsOutput, err := api.AddTags(config.AddTags, obj.LoadBalancerArn, false)
-		if err != nil {
-			ret := err.Error()
-			lg.InfoF("%s", ret)
-			return err
-		}
-		lg.InfoF("Create tags response: %s", createTagsOutput)
-
-	}
-	return nil
+	return addTags(config, config.Region, config.AddTags, "elasticloadbalancing:loadbalancer", false)
 }
 
 func AddTagsTargetGroups(config ModifyTagsConfig) error {
-	api := clients.ELBV2APINew(&config.Region, nil)
-	objects := make([]any, 0)
-	err := api.DescribeTargetGroups(clients.AggregatorInitializer(&objects), nil)
-	if err != nil {
-		return err
-	}
-
-	for _, anyObject := range objects {
-		obj, ok := anyObject.(*elbv2.TargetGroup)
-		if !ok {
-			panic(anyObject)
-		}
-
-		createTagsOutput, err := api.AddTags(config.AddTags, obj.TargetGroupArn, false)
-		if err != nil {
-			ret := err.Error()
-			lg.InfoF("%s", ret)
-			return err
-		}
-		lg.InfoF("Create tags response: %s", createTagsOutput)
-
-	}
-	return nil
+	return addTags(config, config.Region, config.AddTags, "elasticloadbalancing:targetgroup", false)
 }
 
 func AddTagsAutoScalingGroups(config ModifyTagsConfig) error {
-	api := clients.GetAutoscalingAPI(&config.Region, nil)
-	objects := make([]any, 0)
-	err := api.DescribeAutoScalingGroups(clients.AggregatorInitializer(&objects), nil)
-	if err != nil {
-		return err
-	}
-
-	for _, anyObject := range objects {
-		obj, ok := anyObject.(*autoscaling.Group)
-		if !ok {
-			panic(anyObject)
-		}
-
-		//todo: refactor to transform obj.Tags (TagDescription) -> []*autoscaling.Tag{}
-
-		objTags := []*autoscaling.Tag{}
-		resourceType := "auto-scaling-group"
-		err := api.CreateOrUpdateTags(objTags, config.AddTags, obj.AutoScalingGroupName, &resourceType, false)
-		if err != nil {
-			ret := err.Error()
-			lg.InfoF("%s", ret)
-			return err
-		}
-
-	}
-	return nil
+	return addTags(config, config.Region, config.AddTags, "autoscaling:autoScalingGroup", false)
 }
 
 func AddTagsRDSClusters(config ModifyTagsConfig) error {
-	api := clients.RDSAPINew(&config.Region, nil)
-	objects := make([]any, 0)
-	err := api.DescribeClusters(clients.AggregatorInitializer(&objects), nil)
-	if err != nil {
-		return err
-	}
-
-	for _, anyObject := range objects {
-		obj, ok := anyObject.(*rds.DBCluster)
-		if !ok {
-			panic(anyObject)
-		}
-
-		createTagsOutput, err := api.CreateTags(obj.TagList, config.AddTags, obj.DBClusterArn, false)
-		if err != nil {
-			ret := err.Error()
-			lg.InfoF("%s", ret)
-			return err
-		}
-		lg.InfoF("Create tags response: %s", createTagsOutput)
-
-	}
-	return nil
+	return addTags(config, config.Region, config.AddTags, "rds:cluster", false)
 }
 
 func AddTagsRDSInstances(config ModifyTagsConfig) error {
-	api := clients.RDSAPINew(&config.Region, nil)
-	objects := make([]any, 0)
-	err := api.DescribeInstances(clients.AggregatorInitializer(&objects), nil)
-	if err != nil {
-		return err
-	}
+	return addTags(config, config.Region, config.AddTags, "rds:db", false)
+}
 
-	for _, anyObject := range objects {
-		obj, ok := anyObject.(*rds.DBInstance)
-		if !ok {
-			panic(anyObject)
+// ListBuckets returns the buckets of all the regions, every bucket region is tagged.
+func AddTagsS3Buckets(config ModifyTagsConfig) error {
+	api := config.getClients().S3(&config.Region)
+	regions := []string{}
+	for obj, err := range api.IterBuckets(context.Background(), nil) {
+		if err != nil {
+			return err
 		}
-
-		createTagsOutput, err := api.CreateTags(obj.TagList, config.AddTags, obj.DBInstanceArn, false)
+		region, err := api.GetBucketRegion(obj)
 		if err != nil {
-			ret := err.Error()
-			lg.InfoF("%s", ret)
 			return err
 		}
-		lg.InfoF("Create tags response: %s", createTagsOutput)
-
-	}
-	return nil
-}
-
-func AddTagsS3Buckets(config ModifyTagsConfig) error {
-	api := clients.S3APINew(&config.Region, nil)
-	objects := make([]any, 0)
-	err := api.ListBuckets(clients.AggregatorInitializer(&objects), nil)
-	if err != nil {
-		return err
-	}
-
-	for _, anyObject := range objects {
-		obj, ok := anyObject.(*s3.Bucket)
-		if !ok {
-			panic(anyObject)
+		if !slices.Contains(regions, *region) {
+			regions = append(regions, *region)
 		}
+	}
 
-		createTagsOutput, err := api.AddTags(config.AddTags, obj, false)
+	for _, region := range regions {
+		err := addTags(config, region, config.AddTags, "s3:bucket", false)
 		if err != nil {
-			ret := err.Error()
-			lg.InfoF("%s", ret)
 			return err
 		}
-		lg.InfoF("Create tags response: %s", createTagsOutput)
-
 	}
 	return nil
 }
@@ -491,12 +212,7 @@ func AnyToStrings(src []any) (dst []*string, err error) {
 	return dst, err
 }
 
-func CheckTaskDefinitionHasTags(api *clients.ECSAPI, anyObject any) (*ecs.TaskDefinition, error) {
-	obj, ok := anyObject.(*ecs.TaskDefinition)
-	if !ok {
-		panic(anyObject)
-	}
-
+func CheckTaskDefinitionHasTags(api *clients.ECSAPI, obj *ecs.TaskDefinition) (*ecs.TaskDefinition, error) {
 	ret, err := api.GetTags(obj.TaskDefinitionArn)
 	if err != nil {
 		return nil, err
@@ -508,14 +224,8 @@ func CheckTaskDefinitionHasTags(api *clients.ECSAPI, anyObject any) (*ecs.TaskDe
 }
 
 func CheckTagsECSTaskdefinitions(config ModifyTagsConfig) error {
-	api := clients.ECSAPINew(&config.Region, nil)
-	objects := make([]any, 0)
-	err := api.GetTaskDefinitionFamilies(clients.AggregatorInitializer(&objects), nil)
-	if err != nil {
-		return err
-	}
-
-	families, err := AnyToStrings(objects)
+	api := config.getClients().ECS(&config.Region)
+	families, err := clients.Collect(api.IterTaskDefinitionFamilies(context.Background(), nil))
 	if err != nil {
 		return err
 	}
@@ -523,14 +233,16 @@ func CheckTagsECSTaskdefinitions(config ModifyTagsConfig) error {
 	//lg.InfoF("Checking %d families", len(families))
 	for i, familyName := range families {
 		lg.InfoF("Checked %d/%d families", i, len(families))
-		objects = objects[:0]
 
-		api.GetTaskDefinitions(clients.AggregatorInitializer(&objects), &ecs.ListTaskDefinitionsInput{FamilyPrefix: familyName, MaxResults: clients.Int64Ptr(int64(1)), Sort: clients.StrPtr("DESC")})
-		//lg.InfoF("Fetched %d task definitions family: %s", len(objects), *familyName)
-		if len(objects) == 0 {
+		// Only the latest revision.
+		latest, err := clients.Collect(clients.Take(api.IterTaskDefinitions(context.Background(), &ecs.ListTaskDefinitionsInput{FamilyPrefix: familyName, MaxResults: clients.Int64Ptr(int64(1)), Sort: clients.StrPtr("DESC")}), 1))
+		if err != nil {
+			return err
+		}
+		if len(latest) == 0 {
 			continue
 		}
-		td, err := CheckTaskDefinitionHasTags(api, objects[0])
+		td, err := CheckTaskDefinitionHasTags(api, latest[0])
 		if err != nil {
 			return err
 		}
@@ -543,179 +255,25 @@ func CheckTagsECSTaskdefinitions(config ModifyTagsConfig) error {
 }
 
 func AddTagsCloudwatchLogGroups(config ModifyTagsConfig) error {
-	api := clients.CloudwatchLogsAPINew(&config.Region, nil)
-
-	objects, err := api.GetLogGroups(nil)
-	if err != nil {
-		return err
-	}
-
-	tagsRequest := map[string]*string{}
-	for keySrc, valueSrc := range config.AddTags {
-		tagsRequest[keySrc] = &valueSrc
-	}
-
-	//lg.InfoF("Checking %d families", len(families))
-	for i, logGroup := range objects {
-
-		lg.InfoF("Updated %d/%d log groups", i, len(objects))
-
-		err := api.ProvisionTags(logGroup, tagsRequest)
-		if err != nil {
-			panic("Was not able to tag log group")
-		}
-	}
-	return nil
+	return addTags(config, config.Region, config.AddTags, "logs:log-group", true)
 }
 
 func AddTagsECSTasks(config ModifyTagsConfig) error {
-	for region, perRegionTags := range config.PerRegion {
-		api := clients.ECSAPINew(&region, nil)
-
-		clusters, err := api.IterClusters(&ecs.ListClustersInput{})
-		if err != nil {
-			return err
-		}
-		for _, cluster := range clusters {
-			tasks, err := api.GetTasks(&ecs.ListTasksInput{Cluster: cluster.ClusterArn})
-			if err != nil {
-				return err
-			}
-
-			tagsRequest := map[string]*string{}
-			for keySrc, valueSrc := range perRegionTags {
-				tagsRequest[keySrc] = &valueSrc
-			}
-
-			//lg.InfoF("Checking %d families", len(families))
-			for i, task := range tasks {
-				err := api.ProvisionTags(task, tagsRequest)
-				if err != nil {
-					return err
-				}
-
-				lg.InfoF("Updated %d/%d ECS Tasks", i, len(tasks))
-
-				if err != nil {
-					panic("Was not able to tag log group")
-				}
-			}
-		}
-	}
-	return nil
+	return addTagsPerRegion(config, "ecs:task", true)
 }
 
 func AddTagsSecrets(config ModifyTagsConfig) error {
-	for region, perRegionTags := range config.PerRegion {
-
-		api := clients.SecretsmanagerAPINew(&region, nil)
-
-		secrets, err := api.YieldSecrets(&secretsmanager.ListSecretsInput{}, func(a any) error { return nil })
-		if err != nil {
-			return err
-		}
-		for i, secret := range secrets {
-
-			tagsMap := map[string]*string{}
-			for keySrc, valueSrc := range perRegionTags {
-				tagsMap[keySrc] = &valueSrc
-			}
-			err := api.ProvisionTags(secret, tagsMap)
-			if err != nil {
-				return err
-			}
-
-			lg.InfoF("Updated %d/%d resources", i, len(secrets))
-
-			if err != nil {
-				panic("Was not able to tag resource")
-			}
-
-		}
-	}
-	return nil
+	return addTagsPerRegion(config, "secretsmanager:secret", true)
 }
 
 func AddTagsCloudwatchAlarms(config ModifyTagsConfig) error {
-	api := clients.CloudwatchAPINew(&config.Region, nil)
-	objects := make([]any, 0)
-	err := api.GetMetricAlarms(clients.AggregatorInitializer(&objects), nil)
-	if err != nil {
-		return err
-	}
-
-	tagsRequest := map[string]*string{}
-	for keySrc, valueSrc := range config.AddTags {
-		tagsRequest[keySrc] = &valueSrc
-	}
-
-	//lg.InfoF("Checking %d families", len(families))
-	for i, anyObject := range objects {
-		Object, ok := anyObject.(*cloudwatch.MetricAlarm)
-		if !ok {
-			panic("Wrong cast")
-		}
-
-		lg.InfoF("Updated %d/%d alarms", i, len(objects))
-
-		err := api.ProvisionTags(Object, tagsRequest)
-		if err != nil {
-			panic("Was not able to tag alarm")
-		}
-	}
-	return nil
+	return addTags(config, config.Region, config.AddTags, "cloudwatch:alarm", true)
 }
 
 func AddTagsDynamoDBTables(config ModifyTagsConfig) error {
-	for region, perRegionTags := range config.PerRegion {
-		api := clients.DynamoDBAPINew(&region, nil)
-
-		tables, err := api.GetTables(nil)
-		if err != nil {
-			return err
-		}
-
-		tagsRequest := map[string]*string{}
-		for keySrc, valueSrc := range perRegionTags {
-			tagsRequest[keySrc] = &valueSrc
-		}
-
-		//lg.InfoF("Checking %d families", len(families))
-		for i, table := range tables {
-			lg.InfoF("Updated %d/%d DynamoDB Tables", i, len(tables))
-
-			err := api.ProvisionTags(table, tagsRequest)
-			if err != nil {
-				panic("Was not able to tag DynamoDB Table")
-			}
-		}
-	}
-	return nil
+	return addTagsPerRegion(config, "dynamodb:table", true)
 }
 
 func AddTagsElasticacheClusters(config ModifyTagsConfig) error {
-	for region, perRegionTags := range config.PerRegion {
-		api := clients.ElasticacheAPINew(&region, nil)
-
-		clusters, err := api.GetCacheClusters(nil)
-		if err != nil {
-			return err
-		}
-
-		tagsRequest := map[string]*string{}
-		for keySrc, valueSrc := range perRegionTags {
-			tagsRequest[keySrc] = &valueSrc
-		}
-
-		//lg.InfoF("Checking %d families", len(families))
-		for i, cluster := range clusters {
-			lg.InfoF("Updated %d/%d Elasticache Clusters", i, len(clusters))
-
-			err := api.ProvisionTags(cluster, tagsRequest)
-			if err != nil {
-				panic("Was not able to tag DynamoDB Table")
-			}
-		}
-	}
-	return nil
+	return addTagsPerRegion(config, "elasticache:cluster", true)
 }
@@ -1,9 +1,20 @@
 package aws_api
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"os"
 	"testing"
+
+	clients "github.com/AlexeyBeley/go_misc/aws_api/clients"
+	"github.com/AlexeyBeley/go_misc/aws_api/clients/fakes"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
 )
 
 func LoadDynamicConfig(configFilePath string) (config any, err error) {
@@ -266,3 +277,204 @@ func TestAddTagsElasticacheClusters(t *testing.T) {
 	})
 }
 
+
+func fakeConfig(services *fakes.Services) ModifyTagsConfig {
+	tags := map[string]string{"Team": "infra", "Env": "test"}
+	return ModifyTagsConfig{Region: "us-east-1",
+		AddTags:   tags,
+		PerRegion: map[string]map[string]string{"us-east-1": tags},
+		Clients:   services.Factory()}
+}
+
+func strPtr(src string) *string {
+	return &src
+}
+
+func TestAddTagsNetworkInterfacesFake(t *testing.T) {
+	t.Run("Valid run", func(t *testing.T) {
+		services := fakes.ServicesNew()
+		services.EC2.NetworkInterfaces = []*ec2.NetworkInterface{
+			{NetworkInterfaceId: strPtr("eni-1")},
+			{NetworkInterfaceId: strPtr("eni-2"), TagSet: []*ec2.Tag{{Key: strPtr("Team"), Value: strPtr("data")}}},
+			{NetworkInterfaceId: strPtr("eni-3"), TagSet: []*ec2.Tag{{Key: strPtr("Team"), Value: strPtr("infra")}, {Key: strPtr("Env"), Value: strPtr("test")}}},
+		}
+		config := fakeConfig(services)
+
+		err := AddTagsNetworkInterfaces(config)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if services.EC2.TagRequests != 2 {
+			t.Errorf("expected 2 tag requests, got %d", services.EC2.TagRequests)
+		}
+		if services.EC2.Tags["eni-1"]["Team"] != "infra" || services.EC2.Tags["eni-2"]["Env"] != "test" {
+			t.Errorf("unexpected tags: %v", services.EC2.Tags)
+		}
+		// Not declarative: existing values are kept.
+		if _, found := services.EC2.Tags["eni-2"]["Team"]; found {
+			t.Errorf("existing tag was overwritten: %v", services.EC2.Tags["eni-2"])
+		}
+
+		err = AddTagsNetworkInterfaces(config)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if services.EC2.TagRequests != 2 {
+			t.Errorf("second run should not tag, got %d requests", services.EC2.TagRequests)
+		}
+	})
+}
+
+func TestAddTagsInstancesFake(t *testing.T) {
+	t.Run("Valid run", func(t *testing.T) {
+		services := fakes.ServicesNew()
+		for _, instanceId := range []string{"i-1", "i-2", "i-3"} {
+			services.EC2.Instances = append(services.EC2.Instances, &ec2.Instance{InstanceId: strPtr(instanceId)})
+		}
+
+		err := AddTagsInstances(fakeConfig(services))
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if len(services.EC2.Tags) != 3 || services.EC2.Tags["i-3"]["Env"] != "test" {
+			t.Errorf("unexpected tags: %v", services.EC2.Tags)
+		}
+	})
+}
+
+func TestAddTagsAutoScalingGroupsFake(t *testing.T) {
+	t.Run("Valid run", func(t *testing.T) {
+		services := fakes.ServicesNew()
+		services.Autoscaling.AutoScalingGroups = []*autoscaling.Group{
+			{AutoScalingGroupName: strPtr("asg-1")},
+			{AutoScalingGroupName: strPtr("asg-2"), Tags: []*autoscaling.TagDescription{{Key: strPtr("Team"), Value: strPtr("infra")}, {Key: strPtr("Env"), Value: strPtr("test")}}},
+		}
+		config := fakeConfig(services)
+
+		for range 2 {
+			err := AddTagsAutoScalingGroups(config)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+		}
+		if services.Autoscaling.TagRequests != 1 || services.Autoscaling.Tags["asg-1"]["Team"] != "infra" {
+			t.Errorf("unexpected tagging: %d requests, tags: %v", services.Autoscaling.TagRequests, services.Autoscaling.Tags)
+		}
+	})
+}
+
+func TestAddTagsS3BucketsFake(t *testing.T) {
+	t.Run("Valid run", func(t *testing.T) {
+		services := fakes.ServicesNew()
+		services.S3.Buckets = []*s3.Bucket{{Name: strPtr("bucket-local")}, {Name: strPtr("bucket-remote")}}
+		services.S3.BucketRegions = map[string]string{"bucket-remote": "eu-west-1"}
+		services.S3.Tags = map[string]map[string]string{"bucket-remote": {"Owner": "alice"}}
+
+		err := AddTagsS3Buckets(fakeConfig(services))
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if services.S3.Tags["bucket-local"]["Team"] != "infra" {
+			t.Errorf("bucket without tags was not tagged: %v", services.S3.Tags)
+		}
+		remote := services.S3.Tags["bucket-remote"]
+		if remote["Owner"] != "alice" || remote["Env"] != "test" {
+			t.Errorf("existing tags must be kept: %v", remote)
+		}
+	})
+}
+
+func TestAddTagsCloudwatchLogGroupsFake(t *testing.T) {
+	t.Run("Valid run", func(t *testing.T) {
+		services := fakes.ServicesNew()
+		for _, name := range []string{"group-1", "group-2", "group-3"} {
+			arn := "arn:aws:logs:us-east-1:123456789012:log-group:" + name
+			services.CloudwatchLogs.LogGroups = append(services.CloudwatchLogs.LogGroups, &cloudwatchlogs.LogGroup{LogGroupName: strPtr(name), LogGroupArn: &arn})
+		}
+		services.CloudwatchLogs.Tags = map[string]map[string]string{"arn:aws:logs:us-east-1:123456789012:log-group:group-2": {"Team": "infra", "Env": "test"}}
+
+		err := AddTagsCloudwatchLogGroups(fakeConfig(services))
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if services.CloudwatchLogs.TagRequests != 2 {
+			t.Errorf("expected 2 tag requests, got %d", services.CloudwatchLogs.TagRequests)
+		}
+	})
+}
+
+func TestAddTagsECSTasksFake(t *testing.T) {
+	t.Run("Valid run", func(t *testing.T) {
+		services := fakes.ServicesNew()
+		services.ECS.Clusters = []*ecs.Cluster{{ClusterName: strPtr("main"), ClusterArn: strPtr("arn:aws:ecs:us-east-1:123456789012:cluster/main")}}
+		services.ECS.Tasks["main"] = []*ecs.Task{
+			{TaskArn: strPtr("arn:aws:ecs:us-east-1:123456789012:task/main/1")},
+			{TaskArn: strPtr("arn:aws:ecs:us-east-1:123456789012:task/main/2"), Tags: []*ecs.Tag{{Key: strPtr("Team"), Value: strPtr("data")}}},
+		}
+
+		err := AddTagsECSTasks(fakeConfig(services))
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		// ProvisionTags is declarative: the differing value is replaced.
+		if services.ECS.TagRequests != 2 || services.ECS.Tags["arn:aws:ecs:us-east-1:123456789012:task/main/2"]["Team"] != "infra" {
+			t.Errorf("unexpected tagging: %d requests, tags: %v", services.ECS.TagRequests, services.ECS.Tags)
+		}
+	})
+}
+
+func TestAddTagsSecretsFake(t *testing.T) {
+	t.Run("Valid run", func(t *testing.T) {
+		services := fakes.ServicesNew()
+		services.Secretsmanager.Secrets = []*secretsmanager.SecretListEntry{
+			{ARN: strPtr("arn:secret:1"), Name: strPtr("secret-1")},
+			{ARN: strPtr("arn:secret:2"), Name: strPtr("secret-2"), Tags: []*secretsmanager.Tag{{Key: strPtr("Team"), Value: strPtr("infra")}, {Key: strPtr("Env"), Value: strPtr("test")}}},
+		}
+		config := fakeConfig(services)
+
+		for range 2 {
+			err := AddTagsSecrets(config)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+		}
+		if services.Secretsmanager.TagRequests != 1 || services.Secretsmanager.Tags["arn:secret:1"]["Env"] != "test" {
+			t.Errorf("unexpected tagging: %d requests, tags: %v", services.Secretsmanager.TagRequests, services.Secretsmanager.Tags)
+		}
+	})
+}
+
+func TestAddTagsAllTargetsFake(t *testing.T) {
+	t.Run("Valid run", func(t *testing.T) {
+		accounts := map[string]*fakes.Services{"prod": fakes.ServicesNew(), "staging": fakes.ServicesNew()}
+		for _, services := range accounts {
+			services.EC2.Instances = []*ec2.Instance{{InstanceId: strPtr("i-1")}}
+		}
+		executor, err := clients.ExecutorNew(&clients.ExecutorConfig{
+			Accounts: []clients.Account{{Profile: "prod"}, {Profile: "staging"}, {Profile: "missing"}},
+			Regions:  []string{"us-east-1", "eu-west-1"}})
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		executor.FactoryNew = func(target clients.Target) (*clients.Factory, error) {
+			services, ok := accounts[target.Profile]
+			if !ok {
+				return nil, fmt.Errorf("profile not found: %s", target.Profile)
+			}
+			return services.Factory(), nil
+		}
+		config := ModifyTagsConfig{AddTags: map[string]string{"Env": "test"},
+			PerRegion: map[string]map[string]string{"us-east-1": {"Team": "infra"}}}
+
+		report := AddTagsAllTargets(context.Background(), executor, config, AddTagsInstances)
+		if len(report.Succeeded()) != 4 || len(report.Failed()) != 2 {
+			t.Fatalf("unexpected report: %s", report)
+		}
+		for profile, services := range accounts {
+			tags := services.EC2.Tags["i-1"]
+			if tags["Team"] != "infra" || tags["Env"] != "test" {
+				t.Errorf("%s: unexpected tags %v", profile, tags)
+			}
+		}
+	})
+}
@@ -0,0 +1,525 @@
+package aws_api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	clients "github.com/AlexeyBeley/go_misc/aws_api/clients"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+const (
+	DirectionIngress = "ingress"
+	DirectionEgress  = "egress"
+
+	// Flows from a service port to a port in the ephemeral range are the responses of connections
+	// opened in the other direction, security groups are stateful and do not evaluate them.
+	ephemeralPortStart = 32768
+)
+
+// normalizeProtocol returns the protocol number as written by the flow logs, "-1" for all the protocols.
+// Security groups name ICMPv6 "icmpv6".
+func normalizeProtocol(protocol string) string {
+	protocol = strings.ToLower(protocol)
+	switch protocol {
+	case "all":
+		return "-1"
+	case "icmpv6":
+		return "58"
+	}
+	if number, found := protocolNumbers[protocol]; found {
+		return number
+	}
+	return protocol
+}
+
+func hasPorts(protocol string) bool {
+	return protocol == "6" || protocol == "17"
+}
+
+// SecurityGroupRule is a single source of a security group permission.
+// The ports are -1 for the protocols without ports and for all the protocols.
+type SecurityGroupRule struct {
+	Direction     string
+	Protocol      string
+	FromPort      int
+	ToPort        int
+	CIDR          string `json:",omitempty"`
+	SourceGroupID string `json:",omitempty"`
+	// Prefix lists are not resolved, their rules never match.
+	PrefixListID string `json:",omitempty"`
+	Description  string `json:",omitempty"`
+}
+
+func (rule SecurityGroupRule) String() string {
+	ret := rule.Direction + " "
+	if rule.Protocol == "-1" {
+		ret += "all"
+	} else {
+		ret += protocolName(rule.Protocol)
+	}
+	if hasPorts(rule.Protocol) && rule.FromPort != -1 {
+		if rule.FromPort == rule.ToPort {
+			ret += fmt.Sprintf(" %d", rule.FromPort)
+		} else {
+			ret += fmt.Sprintf(" %d-%d", rule.FromPort, rule.ToPort)
+		}
+	}
+	peer := rule.CIDR + rule.SourceGroupID + rule.PrefixListID
+	if rule.Direction == DirectionEgress {
+		return ret + " to " + peer
+	}
+	return ret + " from " + peer
+}
+
+// compile validates the rule and parses its CIDR.
+func (rule SecurityGroupRule) compile() (*net.IPNet, error) {
+	if rule.Direction != DirectionIngress && rule.Direction != DirectionEgress {
+		return nil, fmt.Errorf("unknown direction %q: %s", rule.Direction, rule)
+	}
+	if rule.CIDR == "" {
+		return nil, nil
+	}
+	_, network, err := net.ParseCIDR(rule.CIDR)
+	return network, err
+}
+
+// matches checks the flow protocol, destination port and peer, the peer groups are the groups of its interface.
+func (rule SecurityGroupRule) matches(network *net.IPNet, protocol string, port int, peer net.IP, peerGroups []string) bool {
+	if rule.Protocol != "-1" && rule.Protocol != protocol {
+		return false
+	}
+	if hasPorts(protocol) && rule.FromPort != -1 && (port < rule.FromPort || port > rule.ToPort) {
+		return false
+	}
+	switch {
+	case network != nil:
+		return network.Contains(peer)
+	case rule.SourceGroupID != "":
+		return slices.Contains(peerGroups, rule.SourceGroupID)
+	}
+	return false
+}
+
+// SecurityGroupRules flattens the ingress and egress permissions to a rule per source.
+func SecurityGroupRules(group *ec2.SecurityGroup) []SecurityGroupRule {
+	ret := []SecurityGroupRule{}
+	for _, directionPermissions := range []struct {
+		direction   string
+		permissions []*ec2.IpPermission
+	}{{DirectionIngress, group.IpPermissions}, {DirectionEgress, group.IpPermissionsEgress}} {
+		for _, permission := range directionPermissions.permissions {
+			rule := SecurityGroupRule{Direction: directionPermissions.direction, Protocol: normalizeProtocol(aws.StringValue(permission.IpProtocol)), FromPort: -1, ToPort: -1}
+			if rule.Protocol != "-1" && permission.FromPort != nil && permission.ToPort != nil {
+				rule.FromPort, rule.ToPort = int(*permission.FromPort), int(*permission.ToPort)
+			}
+			for _, ipRange := range permission.IpRanges {
+				source := rule
+				source.CIDR, source.Description = aws.StringValue(ipRange.CidrIp), aws.StringValue(ipRange.Description)
+				ret = append(ret, source)
+			}
+			for _, ipRange := range permission.Ipv6Ranges {
+				source := rule
+				source.CIDR, source.Description = aws.StringValue(ipRange.CidrIpv6), aws.StringValue(ipRange.Description)
+				ret = append(ret, source)
+			}
+			for _, pair := range permission.UserIdGroupPairs {
+				source := rule
+				source.SourceGroupID, source.Description = aws.StringValue(pair.GroupId), aws.StringValue(pair.Description)
+				ret = append(ret, source)
+			}
+			for _, prefixList := range permission.PrefixListIds {
+				source := rule
+				source.PrefixListID, source.Description = aws.StringValue(prefixList.PrefixListId), aws.StringValue(prefixList.Description)
+				ret = append(ret, source)
+			}
+		}
+	}
+	return ret
+}
+
+// ProposedSecurityGroupRule is a rule under review, the report lists the rejected flows it would allow.
+type ProposedSecurityGroupRule struct {
+	GroupID string
+	SecurityGroupRule
+}
+
+// SecurityGroupRuleUsage counts the accepted flows a rule allows.
+// An unused rule is a candidate for tightening when all the group interfaces are recorded.
+type SecurityGroupRuleUsage struct {
+	SecurityGroupRule
+	TrafficCounters
+	LastSeen *time.Time `json:",omitempty"`
+	Unused   bool
+}
+
+// SecurityGroupSuggestion is a least privilege rule: the observed peer and destination port.
+// The peer is kept as a group when the flows were allowed by a group rule.
+type SecurityGroupSuggestion struct {
+	SecurityGroupRule
+	TrafficCounters
+}
+
+type ProposedRuleReport struct {
+	ProposedSecurityGroupRule
+	// Rejected flows the rule would allow.
+	Allowed []*ConversationCount
+}
+
+type SecurityGroupReport struct {
+	GroupID   string
+	GroupName string
+	VpcID     string
+	// The group interfaces and the ones seen in the flow logs.
+	Interfaces []string
+	Recorded   []string
+	Rules      []*SecurityGroupRuleUsage
+	Unused     int
+	Suggested  []*SecurityGroupSuggestion
+	Proposed   []*ProposedRuleReport `json:",omitempty"`
+	// Accepted flows none of the interface groups rules allow, e.g. by unresolved prefix lists.
+	Unexplained TrafficCounters
+}
+
+// SecurityGroupsPlan is the reviewable analysis of the groups attached to the recorded interfaces.
+type SecurityGroupsPlan struct {
+	// Window of the analyzed flows.
+	First *time.Time `json:",omitempty"`
+	Last  *time.Time `json:",omitempty"`
+	// Flows analyzed, skipped as responses, skipped by the window or as unknown interfaces.
+	Flows     int64
+	Responses int64
+	Skipped   int64
+	Groups    []*SecurityGroupReport
+}
+
+func (plan *SecurityGroupsPlan) String() string {
+	lines := []string{}
+	for _, group := range plan.Groups {
+		lines = append(lines, fmt.Sprintf("%s (%s): %d/%d interfaces recorded, %d rules, %d unused, %d suggested, %d unexplained flows",
+			group.GroupID, group.GroupName, len(group.Recorded), len(group.Interfaces), len(group.Rules), group.Unused, len(group.Suggested), group.Unexplained.Flows))
+		for _, rule := range group.Rules {
+			if rule.Unused {
+				lines = append(lines, "  unused "+rule.SecurityGroupRule.String())
+			}
+		}
+		for _, proposed := range group.Proposed {
+			lines = append(lines, fmt.Sprintf("  proposed %s allows %d rejected conversations", proposed.SecurityGroupRule, len(proposed.Allowed)))
+		}
+	}
+	lines = append(lines, fmt.Sprintf("%d flows, %d responses, %d skipped", plan.Flows, plan.Responses, plan.Skipped))
+	return strings.Join(lines, "\n")
+}
+
+type ruleState struct {
+	usage   *SecurityGroupRuleUsage
+	network *net.IPNet
+}
+
+type proposedState struct {
+	rule          *ProposedSecurityGroupRule
+	network       *net.IPNet
+	conversations map[Conversation]*TrafficCounters
+}
+
+type securityGroupState struct {
+	group       *ec2.SecurityGroup
+	interfaces  []string
+	recorded    map[string]bool
+	rules       []*ruleState
+	proposed    []*proposedState
+	suggested   map[SecurityGroupRule]*TrafficCounters
+	unexplained TrafficCounters
+}
+
+// SecurityGroupAnalyzer matches the flows of the interfaces with their security groups rules.
+// Process is an EventProcessor, it is safe to call from the recording goroutines.
+type SecurityGroupAnalyzer struct {
+	// Flows outside the window are skipped, zero values are unbounded.
+	Since time.Time
+	Until time.Time
+
+	lock       sync.Mutex
+	groups     map[string]*securityGroupState
+	interfaces map[string]*ec2.NetworkInterface
+	// Interface addresses, to find the direction and the peer groups.
+	addressGroups    map[string][]string
+	interfaceAddrs   map[string][]string
+	flows, responses int64
+	skipped          int64
+	first, last      int64
+}
+
+// Generator
+func SecurityGroupAnalyzerNew(groups []*ec2.SecurityGroup, networkInterfaces []*ec2.NetworkInterface, proposed []*ProposedSecurityGroupRule) (*SecurityGroupAnalyzer, error) {
+	errorPrefix := "[security_group_analysis:SecurityGroupAnalyzerNew]"
+	analyzer := &SecurityGroupAnalyzer{groups: map[string]*securityGroupState{}, interfaces: map[string]*ec2.NetworkInterface{},
+		addressGroups: map[string][]string{}, interfaceAddrs: map[string][]string{}}
+
+	for _, group := range groups {
+		state := &securityGroupState{group: group, recorded: map[string]bool{}, suggested: map[SecurityGroupRule]*TrafficCounters{}}
+		for _, rule := range SecurityGroupRules(group) {
+			network, err := rule.compile()
+			if err != nil {
+				return nil, fmt.Errorf("%s %s\n%w", errorPrefix, aws.StringValue(group.GroupId), err)
+			}
+			state.rules = append(state.rules, &ruleState{usage: &SecurityGroupRuleUsage{SecurityGroupRule: rule}, network: network})
+		}
+		analyzer.groups[aws.StringValue(group.GroupId)] = state
+	}
+
+	for _, rule := range proposed {
+		state, found := analyzer.groups[rule.GroupID]
+		if !found {
+			return nil, fmt.Errorf("%s proposed rule of unknown group %s: %s", errorPrefix, rule.GroupID, rule.SecurityGroupRule)
+		}
+		normalized := *rule
+		normalized.Protocol = normalizeProtocol(rule.Protocol)
+		network, err := normalized.compile()
+		if err != nil {
+			return nil, fmt.Errorf("%s proposed rule of %s\n%w", errorPrefix, rule.GroupID, err)
+		}
+		state.proposed = append(state.proposed, &proposedState{rule: &normalized, network: network, conversations: map[Conversation]*TrafficCounters{}})
+	}
+
+	for _, networkInterface := range networkInterfaces {
+		interfaceID := aws.StringValue(networkInterface.NetworkInterfaceId)
+		analyzer.interfaces[interfaceID] = networkInterface
+		groupIDs := []string{}
+		for _, group := range networkInterface.Groups {
+			groupIDs = append(groupIDs, aws.StringValue(group.GroupId))
+			if state, found := analyzer.groups[aws.StringValue(group.GroupId)]; found {
+				state.interfaces = append(state.interfaces, interfaceID)
+			}
+		}
+		addresses := networkInterfaceAddresses(networkInterface)
+		analyzer.interfaceAddrs[interfaceID] = addresses
+		for _, address := range addresses {
+			analyzer.addressGroups[address] = groupIDs
+		}
+	}
+	return analyzer, nil
+}
+
+// direction of the flow relative to the interface, empty when neither address is the interface address.
+func (analyzer *SecurityGroupAnalyzer) direction(event *FlowLogEvent) string {
+	if event.FlowDirection == DirectionIngress || event.FlowDirection == DirectionEgress {
+		return event.FlowDirection
+	}
+	addresses := analyzer.interfaceAddrs[event.InterfaceID]
+	if slices.Contains(addresses, event.DstAddr.String()) {
+		return DirectionIngress
+	}
+	if slices.Contains(addresses, event.SrcAddr.String()) {
+		return DirectionEgress
+	}
+	return ""
+}
+
+func hostCIDR(address net.IP) string {
+	if address.To4() != nil {
+		return address.String() + "/32"
+	}
+	return address.String() + "/128"
+}
+
+// Process matches an ACCEPTed flow with the rules allowing it and a REJECTed one with the proposed rules.
+// Records without traffic (NODATA, SKIPDATA) are ignored.
+func (analyzer *SecurityGroupAnalyzer) Process(event *FlowLogEvent) error {
+	if event.SrcAddr == nil || event.DstAddr == nil {
+		return nil
+	}
+	analyzer.lock.Lock()
+	defer analyzer.lock.Unlock()
+
+	networkInterface, found := analyzer.interfaces[event.InterfaceID]
+	direction := analyzer.direction(event)
+	if !found || direction == "" || (!analyzer.Since.IsZero() && int64(event.End) < analyzer.Since.Unix()) ||
+		(!analyzer.Until.IsZero() && int64(event.Start) > analyzer.Until.Unix()) {
+		analyzer.skipped++
+		return nil
+	}
+	if hasPorts(event.Protocol) && event.DstPort >= ephemeralPortStart && event.SrcPort < ephemeralPortStart {
+		analyzer.responses++
+		return nil
+	}
+	analyzer.flows++
+	if analyzer.first == 0 || int64(event.Start) < analyzer.first {
+		analyzer.first = int64(event.Start)
+	}
+	analyzer.last = max(analyzer.last, int64(event.End))
+
+	peer := event.SrcAddr
+	if direction == DirectionEgress {
+		peer = event.DstAddr
+	}
+	peerGroups := analyzer.addressGroups[peer.String()]
+	seen := time.Unix(int64(event.End), 0).UTC()
+
+	states := []*securityGroupState{}
+	for _, group := range networkInterface.Groups {
+		if state, found := analyzer.groups[aws.StringValue(group.GroupId)]; found {
+			state.recorded[event.InterfaceID] = true
+			states = append(states, state)
+		}
+	}
+
+	if event.Action == "REJECT" {
+		conversation := Conversation{SrcAddr: event.SrcAddr.String(), DstAddr: event.DstAddr.String(), DstPort: event.DstPort, Protocol: event.Protocol}
+		for _, state := range states {
+			for _, proposed := range state.proposed {
+				if proposed.rule.Direction == direction && proposed.rule.matches(proposed.network, event.Protocol, event.DstPort, peer, peerGroups) {
+					counter(proposed.conversations, conversation).add(event)
+				}
+			}
+		}
+		return nil
+	}
+
+	explained := false
+	for _, state := range states {
+		suggestion := SecurityGroupRule{}
+		for _, rule := range state.rules {
+			if rule.usage.Direction != direction || !rule.usage.matches(rule.network, event.Protocol, event.DstPort, peer, peerGroups) {
+				continue
+			}
+			rule.usage.add(event)
+			if rule.usage.LastSeen == nil || rule.usage.LastSeen.Before(seen) {
+				rule.usage.LastSeen = &seen
+			}
+			if suggestion.Direction == "" || rule.usage.SourceGroupID != "" {
+				suggestion = SecurityGroupRule{Direction: direction, Protocol: event.Protocol, FromPort: -1, ToPort: -1, SourceGroupID: rule.usage.SourceGroupID}
+			}
+		}
+		if suggestion.Direction == "" {
+			continue
+		}
+		explained = true
+		if hasPorts(event.Protocol) {
+			suggestion.FromPort, suggestion.ToPort = event.DstPort, event.DstPort
+		}
+		if suggestion.SourceGroupID == "" {
+			suggestion.CIDR = hostCIDR(peer)
+		}
+		counter(state.suggested, suggestion).add(event)
+	}
+	if !explained {
+		for _, state := range states {
+			state.unexplained.add(event)
+		}
+	}
+	return nil
+}
+
+// Plan reports the groups attached to the recorded interfaces, by group ID.
+func (analyzer *SecurityGroupAnalyzer) Plan() *SecurityGroupsPlan {
+	analyzer.lock.Lock()
+	defer analyzer.lock.Unlock()
+
+	plan := &SecurityGroupsPlan{Flows: analyzer.flows, Responses: analyzer.responses, Skipped: analyzer.skipped}
+	if analyzer.flows > 0 {
+		first, last := time.Unix(analyzer.first, 0).UTC(), time.Unix(analyzer.last, 0).UTC()
+		plan.First, plan.Last = &first, &last
+	}
+	for groupID, state := range analyzer.groups {
+		if len(state.recorded) == 0 {
+			continue
+		}
+		report := &SecurityGroupReport{GroupID: groupID, GroupName: aws.StringValue(state.group.GroupName), VpcID: aws.StringValue(state.group.VpcId),
+			Interfaces: slices.Sorted(slices.Values(state.interfaces)), Rules: []*SecurityGroupRuleUsage{}, Suggested: []*SecurityGroupSuggestion{},
+			Unexplained: state.unexplained}
+		for interfaceID := range state.recorded {
+			report.Recorded = append(report.Recorded, interfaceID)
+		}
+		sort.Strings(report.Recorded)
+
+		for _, rule := range state.rules {
+			usage := *rule.usage
+			usage.Unused = usage.Flows == 0
+			if usage.Unused {
+				report.Unused++
+			}
+			report.Rules = append(report.Rules, &usage)
+		}
+		for rule, counters := range state.suggested {
+			report.Suggested = append(report.Suggested, &SecurityGroupSuggestion{SecurityGroupRule: rule, TrafficCounters: *counters})
+		}
+		sort.Slice(report.Suggested, func(i, j int) bool {
+			return suggestionKey(report.Suggested[i].SecurityGroupRule) < suggestionKey(report.Suggested[j].SecurityGroupRule)
+		})
+		for _, proposed := range state.proposed {
+			report.Proposed = append(report.Proposed, &ProposedRuleReport{ProposedSecurityGroupRule: *proposed.rule,
+				Allowed: topN(proposed.conversations, len(proposed.conversations), func(counters *TrafficCounters) int64 { return counters.Flows },
+					func(conversation Conversation, counters TrafficCounters) *ConversationCount {
+						return &ConversationCount{Conversation: conversation, TrafficCounters: counters}
+					})})
+		}
+		plan.Groups = append(plan.Groups, report)
+	}
+	sort.Slice(plan.Groups, func(i, j int) bool { return plan.Groups[i].GroupID < plan.Groups[j].GroupID })
+	return plan
+}
+
+// suggestionKey orders the suggestions by direction, protocol, port and peer.
+func suggestionKey(rule SecurityGroupRule) string {
+	return fmt.Sprintf("%s %3s %05d %s%s", rule.Direction, rule.Protocol, rule.FromPort+1, rule.SourceGroupID, rule.CIDR)
+}
+
+// Write encodes the plan as indented JSON.
+func (plan *SecurityGroupsPlan) Write(writer io.Writer) error {
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(plan)
+}
+
+// LoadSecurityGroupAnalyzer builds an analyzer of the region groups and interfaces with the Config.ProposedRules.
+// Config.SecurityGroupsWindowHours limits the flows to the last hours, all the flows when not set.
+func (awsTCPDump *AWSTCPDump) LoadSecurityGroupAnalyzer(ctx context.Context) (*SecurityGroupAnalyzer, error) {
+	errorPrefix := "[security_group_analysis:LoadSecurityGroupAnalyzer]"
+	api := awsTCPDump.getClients().EC2(&awsTCPDump.Config.Region)
+	groups, err := clients.Collect(api.IterSecurityGroups(ctx, nil))
+	if err != nil {
+		return nil, fmt.Errorf("%s failed to describe security groups\n%w", errorPrefix, err)
+	}
+	networkInterfaces, err := clients.Collect(api.IterNetworkInterfaces(ctx, nil))
+	if err != nil {
+		return nil, fmt.Errorf("%s failed to describe network interfaces\n%w", errorPrefix, err)
+	}
+
+	analyzer, err := SecurityGroupAnalyzerNew(groups, networkInterfaces, awsTCPDump.Config.ProposedRules)
+	if err != nil {
+		return nil, err
+	}
+	if awsTCPDump.Config.SecurityGroupsWindowHours > 0 {
+		analyzer.Since = time.Now().Add(-time.Duration(awsTCPDump.Config.SecurityGroupsWindowHours) * time.Hour)
+	}
+	return analyzer, nil
+}
+
+// WriteSecurityGroupsPlan prints the plan summary and writes the JSON plan to Config.SecurityGroupsPlanFilePath.
+func (awsTCPDump *AWSTCPDump) WriteSecurityGroupsPlan(analyzer *SecurityGroupAnalyzer, writer io.Writer) error {
+	errorPrefix := "[security_group_analysis:WriteSecurityGroupsPlan]"
+	plan := analyzer.Plan()
+	if _, err := fmt.Fprintln(writer, plan); err != nil {
+		return err
+	}
+	if awsTCPDump.Config.SecurityGroupsPlanFilePath == "" {
+		return nil
+	}
+	file, err := os.Create(awsTCPDump.Config.SecurityGroupsPlanFilePath)
+	if err != nil {
+		return fmt.Errorf("%s failed to create %s\n%w", errorPrefix, awsTCPDump.Config.SecurityGroupsPlanFilePath, err)
+	}
+	defer file.Close()
+	return plan.Write(file)
+}
@@ -0,0 +1,172 @@
+package aws_api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AlexeyBeley/go_misc/aws_api/clients/fakes"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+func securityGroupServices() *fakes.Services {
+	services := fakes.ServicesNew()
+	permission := func(protocol string, port int64, cidr, groupID string) *ec2.IpPermission {
+		ret := &ec2.IpPermission{IpProtocol: aws.String(protocol)}
+		if port > 0 {
+			ret.FromPort, ret.ToPort = aws.Int64(port), aws.Int64(port)
+		}
+		if cidr != "" {
+			ret.IpRanges = []*ec2.IpRange{{CidrIp: aws.String(cidr)}}
+		}
+		if groupID != "" {
+			ret.UserIdGroupPairs = []*ec2.UserIdGroupPair{{GroupId: aws.String(groupID)}}
+		}
+		return ret
+	}
+	services.EC2.SecurityGroups = []*ec2.SecurityGroup{
+		{GroupId: aws.String("sg-web"), GroupName: aws.String("web"),
+			IpPermissions:       []*ec2.IpPermission{permission("tcp", 443, "0.0.0.0/0", ""), permission("tcp", 22, "10.0.0.0/8", "")},
+			IpPermissionsEgress: []*ec2.IpPermission{permission("-1", 0, "0.0.0.0/0", "")}},
+		{GroupId: aws.String("sg-db"), GroupName: aws.String("db"),
+			IpPermissions: []*ec2.IpPermission{permission("tcp", 5432, "", "sg-web"), permission("tcp", 3306, "", "sg-web")}},
+		{GroupId: aws.String("sg-idle"), GroupName: aws.String("idle")},
+	}
+	networkInterface := func(id, address, groupID string) *ec2.NetworkInterface {
+		return &ec2.NetworkInterface{NetworkInterfaceId: aws.String(id), PrivateIpAddress: aws.String(address),
+			Groups: []*ec2.GroupIdentifier{{GroupId: aws.String(groupID)}}}
+	}
+	services.EC2.NetworkInterfaces = []*ec2.NetworkInterface{networkInterface("eni-web", "10.0.0.5", "sg-web"), networkInterface("eni-db", "10.0.1.9", "sg-db"),
+		networkInterface("eni-web-2", "10.0.0.6", "sg-web")}
+	return services
+}
+
+func securityGroupEvents(t *testing.T) []*FlowLogEvent {
+	format, err := FlowLogFormatNew(DefaultFlowLogFormat)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	events := []*FlowLogEvent{}
+	for _, message := range []string{
+		"2 123456789012 eni-web 203.0.113.7 10.0.0.5 51000 443 6 10 8000 1735776000 1735776060 ACCEPT OK",
+		"2 123456789012 eni-web 10.0.0.5 203.0.113.7 443 51000 6 10 9000 1735776000 1735776060 ACCEPT OK",
+		"2 123456789012 eni-web 10.0.0.5 10.0.1.9 40000 5432 6 4 900 1735776000 1735776060 ACCEPT OK",
+		"2 123456789012 eni-db 10.0.0.5 10.0.1.9 40000 5432 6 4 900 1735776000 1735776120 ACCEPT OK",
+		"2 123456789012 eni-web 198.51.100.9 10.0.0.5 40001 8080 6 1 40 1735776000 1735776060 REJECT OK",
+		"2 123456789012 eni-web 198.51.100.9 10.0.0.5 40002 25 6 1 40 1735776000 1735776060 REJECT OK",
+		"2 123456789012 eni-other 198.51.100.9 10.0.9.9 40002 25 6 1 40 1735776000 1735776060 REJECT OK",
+		"2 123456789012 eni-web - - - - - - - 1735776000 1735776060 - NODATA",
+	} {
+		event, err := format.Parse(message)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+func TestSecurityGroupAnalyzer(t *testing.T) {
+	t.Run("Valid run", func(t *testing.T) {
+		dataDir := t.TempDir()
+		awsTCPDump := &AWSTCPDump{Config: &AWSTCPDumpConfig{Region: "us-east-1", SecurityGroupsPlanFilePath: filepath.Join(dataDir, "plan.json"),
+			ProposedRules: []*ProposedSecurityGroupRule{{GroupID: "sg-web",
+				SecurityGroupRule: SecurityGroupRule{Direction: DirectionIngress, Protocol: "tcp", FromPort: 8000, ToPort: 8100, CIDR: "198.51.100.0/24"}}}},
+			Clients: securityGroupServices().Factory()}
+		analyzer, err := awsTCPDump.LoadSecurityGroupAnalyzer(context.Background())
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		for _, event := range securityGroupEvents(t) {
+			if err := analyzer.Process(event); err != nil {
+				t.Fatalf("%v", err)
+			}
+		}
+
+		plan := analyzer.Plan()
+		if plan.Flows != 5 || plan.Responses != 1 || plan.Skipped != 1 || !plan.Last.Equal(time.Unix(1735776120, 0)) {
+			t.Errorf("unexpected counts: %d flows, %d responses, %d skipped, last %v", plan.Flows, plan.Responses, plan.Skipped, plan.Last)
+		}
+		if len(plan.Groups) != 2 || plan.Groups[0].GroupID != "sg-db" || plan.Groups[1].GroupID != "sg-web" {
+			t.Fatalf("unexpected groups: %v", plan.Groups)
+		}
+
+		db, web := plan.Groups[0], plan.Groups[1]
+		if db.Unused != 1 || !db.Rules[1].Unused || db.Rules[1].String() != "ingress tcp 3306 from sg-web" || db.Rules[0].Flows != 1 {
+			t.Errorf("unexpected db rules: %+v", db.Rules)
+		}
+		if len(db.Suggested) != 1 || db.Suggested[0].SecurityGroupRule.String() != "ingress tcp 5432 from sg-web" {
+			t.Errorf("unexpected db suggestions: %+v", db.Suggested)
+		}
+		if web.Unused != 1 || web.Rules[1].String() != "ingress tcp 22 from 10.0.0.0/8" || web.Rules[0].Bytes != 8000 || web.Rules[2].Bytes != 900 {
+			t.Errorf("unexpected web rules: %+v", web.Rules)
+		}
+		if len(web.Interfaces) != 2 || len(web.Recorded) != 1 {
+			t.Errorf("unexpected web interfaces: %v, recorded %v", web.Interfaces, web.Recorded)
+		}
+		suggested := []string{}
+		for _, suggestion := range web.Suggested {
+			suggested = append(suggested, suggestion.SecurityGroupRule.String())
+		}
+		if strings.Join(suggested, ", ") != "egress tcp 5432 to 10.0.1.9/32, ingress tcp 443 from 203.0.113.7/32" {
+			t.Errorf("unexpected web suggestions: %v", suggested)
+		}
+		if len(web.Proposed) != 1 || len(web.Proposed[0].Allowed) != 1 || web.Proposed[0].Allowed[0].String() != "198.51.100.9 -> 10.0.0.5:8080/tcp" {
+			t.Errorf("unexpected proposed: %+v", web.Proposed)
+		}
+
+		summary := &bytes.Buffer{}
+		if err := awsTCPDump.WriteSecurityGroupsPlan(analyzer, summary); err != nil {
+			t.Fatalf("%v", err)
+		}
+		if !strings.Contains(summary.String(), "sg-web (web): 1/2 interfaces recorded, 3 rules, 1 unused, 2 suggested, 0 unexplained flows\n  unused ingress tcp 22 from 10.0.0.0/8\n"+
+			"  proposed ingress tcp 8000-8100 from 198.51.100.0/24 allows 1 rejected conversations") {
+			t.Errorf("unexpected summary:\n%s", summary)
+		}
+		data, err := os.ReadFile(awsTCPDump.Config.SecurityGroupsPlanFilePath)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		decoded := &SecurityGroupsPlan{}
+		if err := json.Unmarshal(data, decoded); err != nil || len(decoded.Groups) != 2 || decoded.Groups[1].Suggested[1].CIDR != "203.0.113.7/32" {
+			t.Errorf("unexpected JSON plan: %v\n%s", err, data)
+		}
+	})
+
+	t.Run("Window", func(t *testing.T) {
+		services := securityGroupServices()
+		analyzer, err := SecurityGroupAnalyzerNew(services.EC2.SecurityGroups, services.EC2.NetworkInterfaces, nil)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		analyzer.Since = time.Unix(1735776100, 0)
+		for _, event := range securityGroupEvents(t) {
+			if err := analyzer.Process(event); err != nil {
+				t.Fatalf("%v", err)
+			}
+		}
+		plan := analyzer.Plan()
+		if plan.Flows != 1 || len(plan.Groups) != 1 || plan.Groups[0].GroupID != "sg-db" {
+			t.Errorf("unexpected plan: %s", plan)
+		}
+	})
+
+	t.Run("Invalid proposed rules", func(t *testing.T) {
+		services := securityGroupServices()
+		for _, rule := range []*ProposedSecurityGroupRule{
+			{GroupID: "sg-missing", SecurityGroupRule: SecurityGroupRule{Direction: DirectionIngress, Protocol: "tcp", FromPort: 80, ToPort: 80, CIDR: "10.0.0.0/8"}},
+			{GroupID: "sg-web", SecurityGroupRule: SecurityGroupRule{Direction: "inbound", Protocol: "tcp", FromPort: 80, ToPort: 80, CIDR: "10.0.0.0/8"}},
+			{GroupID: "sg-web", SecurityGroupRule: SecurityGroupRule{Direction: DirectionIngress, Protocol: "tcp", FromPort: 80, ToPort: 80, CIDR: "10.0.0.0"}},
+		} {
+			if _, err := SecurityGroupAnalyzerNew(services.EC2.SecurityGroups, services.EC2.NetworkInterfaces, []*ProposedSecurityGroupRule{rule}); err == nil {
+				t.Errorf("expected an error: %+v", rule)
+			}
+		}
+	})
+}
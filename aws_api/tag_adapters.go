@@ -0,0 +1,168 @@
+package aws_api
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	inventory "github.com/AlexeyBeley/go_misc/aws_api/inventory"
+)
+
+// TagAdapter lists and tags one resource type. Resources are listed by the inventory
+// collector of the same type, so the tag policies and the snapshots see the same Resources.
+type TagAdapter struct {
+	Type string
+	// Writes the given tags, the other tags are kept.
+	Tag   func(scope *inventory.Scope, resource *inventory.Resource, tags map[string]string) error
+	Untag func(scope *inventory.Scope, resource *inventory.Resource, keys []string) error
+	// Resource type the tags are copied from by CopyFromParent rules.
+	ParentType string
+	// Id of the parent resource, empty when there is none.
+	ParentId func(resource *inventory.Resource) string
+}
+
+// List collects the adapter resource type in the scope.
+func (adapter *TagAdapter) List(ctx context.Context, scope *inventory.Scope, add func(*inventory.Resource)) error {
+	collector, err := inventory.FindCollector(adapter.Type)
+	if err != nil {
+		return err
+	}
+	return collector.Collect(ctx, scope, add)
+}
+
+// TagAdapters of all the taggable resource types.
+// Lambda functions and Route53 hosted zones are inventory only: their clients have no tag writers.
+var TagAdapters = []TagAdapter{
+	ec2TagAdapter("ec2:network-interface", "ec2:instance", attributeParentId("InstanceId")),
+	ec2TagAdapter("ec2:instance", "autoscaling:autoScalingGroup", autoScalingGroupParentId),
+	ec2TagAdapter("ec2:natgateway", "", nil),
+	ec2TagAdapter("ec2:elastic-ip", "", nil),
+	ec2TagAdapter("ec2:volume", "ec2:instance", attributeParentId("InstanceId")),
+	ec2TagAdapter("ec2:snapshot", "ec2:volume", attributeParentId("VolumeId")),
+	ec2TagAdapter("ec2:image", "", nil),
+	ec2TagAdapter("ec2:security-group", "", nil),
+	ec2TagAdapter("ec2:launch-template", "", nil),
+	ec2TagAdapter("ec2:key-pair", "", nil),
+	{Type: "elasticloadbalancing:loadbalancer", Tag: elbv2Tag, Untag: elbv2Untag},
+	{Type: "elasticloadbalancing:targetgroup", Tag: elbv2Tag, Untag: elbv2Untag},
+	{Type: "autoscaling:autoScalingGroup",
+		Tag: func(scope *inventory.Scope, resource *inventory.Resource, tags map[string]string) error {
+			return scope.Clients.Autoscaling(&scope.Region).SetTags(&resource.Id, tags)
+		},
+		Untag: func(scope *inventory.Scope, resource *inventory.Resource, keys []string) error {
+			return scope.Clients.Autoscaling(&scope.Region).RemoveTags(&resource.Id, keys)
+		}},
+	{Type: "rds:cluster", Tag: rdsTag, Untag: rdsUntag},
+	{Type: "rds:db", Tag: rdsTag, Untag: rdsUntag, ParentType: "rds:cluster", ParentId: attributeParentId("DBClusterId")},
+	{Type: "s3:bucket",
+		Tag: func(scope *inventory.Scope, resource *inventory.Resource, tags map[string]string) error {
+			return scope.Clients.S3(&resource.Region).SetTags(&resource.Id, tags)
+		},
+		Untag: func(scope *inventory.Scope, resource *inventory.Resource, keys []string) error {
+			return scope.Clients.S3(&resource.Region).RemoveTags(&resource.Id, keys)
+		}},
+	{Type: "logs:log-group",
+		Tag: func(scope *inventory.Scope, resource *inventory.Resource, tags map[string]string) error {
+			return scope.Clients.CloudwatchLogs(&scope.Region).SetTags(&resource.Arn, tags)
+		},
+		Untag: func(scope *inventory.Scope, resource *inventory.Resource, keys []string) error {
+			return scope.Clients.CloudwatchLogs(&scope.Region).RemoveTags(&resource.Arn, keys)
+		}},
+	{Type: "ecs:cluster", Tag: ecsTag, Untag: ecsUntag},
+	{Type: "ecs:task", Tag: ecsTag, Untag: ecsUntag, ParentType: "ecs:cluster", ParentId: attributeParentId("ClusterName")},
+	{Type: "ecs:task-definition", Tag: ecsTag, Untag: ecsUntag},
+	{Type: "dynamodb:table",
+		Tag: func(scope *inventory.Scope, resource *inventory.Resource, tags map[string]string) error {
+			return scope.Clients.DynamoDB(&scope.Region).SetTags(&resource.Arn, tags)
+		},
+		Untag: func(scope *inventory.Scope, resource *inventory.Resource, keys []string) error {
+			return scope.Clients.DynamoDB(&scope.Region).RemoveTags(&resource.Arn, keys)
+		}},
+	{Type: "elasticache:cluster",
+		Tag: func(scope *inventory.Scope, resource *inventory.Resource, tags map[string]string) error {
+			return scope.Clients.Elasticache(&scope.Region).SetTags(&resource.Arn, tags)
+		},
+		Untag: func(scope *inventory.Scope, resource *inventory.Resource, keys []string) error {
+			return scope.Clients.Elasticache(&scope.Region).RemoveTags(&resource.Arn, keys)
+		}},
+	{Type: "secretsmanager:secret",
+		Tag: func(scope *inventory.Scope, resource *inventory.Resource, tags map[string]string) error {
+			return scope.Clients.Secretsmanager(&scope.Region).SetTags(&resource.Arn, tags)
+		},
+		Untag: func(scope *inventory.Scope, resource *inventory.Resource, keys []string) error {
+			return scope.Clients.Secretsmanager(&scope.Region).RemoveTags(&resource.Arn, keys)
+		}},
+	{Type: "cloudwatch:alarm",
+		Tag: func(scope *inventory.Scope, resource *inventory.Resource, tags map[string]string) error {
+			return scope.Clients.Cloudwatch(&scope.Region).SetTags(&resource.Arn, tags)
+		},
+		Untag: func(scope *inventory.Scope, resource *inventory.Resource, keys []string) error {
+			return scope.Clients.Cloudwatch(&scope.Region).RemoveTags(&resource.Arn, keys)
+		}},
+}
+
+func FindTagAdapter(resourceType string) (*TagAdapter, error) {
+	for index := range TagAdapters {
+		if TagAdapters[index].Type == resourceType {
+			return &TagAdapters[index], nil
+		}
+	}
+	return nil, fmt.Errorf("[tag_adapters:FindTagAdapter] resource type can not be tagged: %s", resourceType)
+}
+
+// All the EC2 resource types are tagged by id with the same calls.
+// Network interfaces come and go with the instances and tasks: the deleted ones are skipped.
+func ec2TagAdapter(resourceType, parentType string, parentId func(resource *inventory.Resource) string) TagAdapter {
+	ignoreDeleted := func(err error) error {
+		if err != nil && resourceType == "ec2:network-interface" && strings.Contains(err.Error(), "does not exist") {
+			lg.WarningF("Network interface was deleted: %v", err)
+			return nil
+		}
+		return err
+	}
+	return TagAdapter{Type: resourceType,
+		Tag: func(scope *inventory.Scope, resource *inventory.Resource, tags map[string]string) error {
+			return ignoreDeleted(scope.Clients.EC2(&scope.Region).SetTags(&resource.Id, tags))
+		},
+		Untag: func(scope *inventory.Scope, resource *inventory.Resource, keys []string) error {
+			return ignoreDeleted(scope.Clients.EC2(&scope.Region).RemoveTags(&resource.Id, keys))
+		},
+		ParentType: parentType,
+		ParentId:   parentId}
+}
+
+func elbv2Tag(scope *inventory.Scope, resource *inventory.Resource, tags map[string]string) error {
+	return scope.Clients.ELBV2(&scope.Region).SetTags(&resource.Arn, tags)
+}
+
+func elbv2Untag(scope *inventory.Scope, resource *inventory.Resource, keys []string) error {
+	return scope.Clients.ELBV2(&scope.Region).RemoveTags(&resource.Arn, keys)
+}
+
+func rdsTag(scope *inventory.Scope, resource *inventory.Resource, tags map[string]string) error {
+	return scope.Clients.RDS(&scope.Region).SetTags(&resource.Arn, tags)
+}
+
+func rdsUntag(scope *inventory.Scope, resource *inventory.Resource, keys []string) error {
+	return scope.Clients.RDS(&scope.Region).RemoveTags(&resource.Arn, keys)
+}
+
+func ecsTag(scope *inventory.Scope, resource *inventory.Resource, tags map[string]string) error {
+	return scope.Clients.ECS(&scope.Region).SetTags(&resource.Arn, tags)
+}
+
+func ecsUntag(scope *inventory.Scope, resource *inventory.Resource, keys []string) error {
+	return scope.Clients.ECS(&scope.Region).RemoveTags(&resource.Arn, keys)
+}
+
+// Parent id kept in a resource attribute by the inventory collector.
+func attributeParentId(attribute string) func(resource *inventory.Resource) string {
+	return func(resource *inventory.Resource) string {
+		return resource.Attributes[attribute]
+	}
+}
+
+// The auto scaling group tags its instances with its name.
+func autoScalingGroupParentId(resource *inventory.Resource) string {
+	return resource.Tags["aws:autoscaling:groupName"]
+}
@@ -0,0 +1,371 @@
+package aws_api
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	clients "github.com/AlexeyBeley/go_misc/aws_api/clients"
+	inventory "github.com/AlexeyBeley/go_misc/aws_api/inventory"
+)
+
+const (
+	ComplianceFormatJSON     = "json"
+	ComplianceFormatCSV      = "csv"
+	ComplianceFormatMarkdown = "markdown"
+)
+
+// Region of the per account summaries.
+const ComplianceAllRegions = "*"
+
+// Owner hint tags when TagSchema.OwnerTags is not set, checked in order.
+var DefaultOwnerTags = []string{"Owner", "owner", "Team", "team", "CreatedBy", "aws:cloudformation:stack-name", "aws:autoscaling:groupName"}
+
+// RequiredTag is a key every selected resource must have.
+type RequiredTag struct {
+	Key string `json:"Key"`
+	// Any value when empty.
+	AllowedValues []string `json:"AllowedValues"`
+	Regex         string   `json:"Regex"`
+	// Resource types the key is required on, all the checked types when empty.
+	Types []string `json:"Types"`
+
+	regex *regexp.Regexp
+}
+
+type TagSchema struct {
+	Required []*RequiredTag `json:"Required"`
+	// Resource types to check, all the TagAdapters types when empty.
+	Types []string `json:"Types"`
+	// Tags hinting who to ask about an offender, DefaultOwnerTags when not set.
+	OwnerTags []string `json:"OwnerTags"`
+}
+
+type ComplianceConfig struct {
+	Executor clients.ExecutorConfig `json:"Executor"`
+	Schema   TagSchema              `json:"Schema"`
+}
+
+func LoadComplianceConfig(filePath string) (*ComplianceConfig, error) {
+	errorPrefix := "[tag_compliance:LoadComplianceConfig]"
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("%s Reading %s\n%w", errorPrefix, filePath, err)
+	}
+	config := &ComplianceConfig{}
+	err = json.Unmarshal(data, config)
+	if err != nil {
+		return nil, fmt.Errorf("%s Parsing %s\n%w", errorPrefix, filePath, err)
+	}
+	return config, config.Schema.Validate()
+}
+
+// Validate compiles the schema, it is called by CheckCompliance and once before the CheckComplianceAllTargets fan-out.
+func (schema *TagSchema) Validate() error {
+	errorPrefix := "[tag_compliance:Validate]"
+
+	for _, resourceType := range schema.types() {
+		if _, err := FindTagAdapter(resourceType); err != nil {
+			return fmt.Errorf("%s\n%w", errorPrefix, err)
+		}
+	}
+	for _, required := range schema.Required {
+		if required.Key == "" {
+			return fmt.Errorf("%s required tag without a key", errorPrefix)
+		}
+		for _, resourceType := range required.Types {
+			if _, err := FindTagAdapter(resourceType); err != nil {
+				return fmt.Errorf("%s %s\n%w", errorPrefix, required.Key, err)
+			}
+		}
+		if required.Regex != "" {
+			regex, err := regexp.Compile(required.Regex)
+			if err != nil {
+				return fmt.Errorf("%s %s\n%w", errorPrefix, required.Key, err)
+			}
+			required.regex = regex
+		}
+	}
+	return nil
+}
+
+func (schema *TagSchema) types() []string {
+	if len(schema.Types) > 0 {
+		return schema.Types
+	}
+	ret := []string{}
+	for _, adapter := range TagAdapters {
+		ret = append(ret, adapter.Type)
+	}
+	return ret
+}
+
+// Violation of a single required key, value is empty for the missing ones.
+type TagViolation struct {
+	Key    string `json:"Key"`
+	Value  string `json:"Value,omitempty"`
+	Reason string `json:"Reason"`
+}
+
+func (violation TagViolation) String() string {
+	if violation.Value == "" {
+		return fmt.Sprintf("%s: %s", violation.Key, violation.Reason)
+	}
+	return fmt.Sprintf("%s=%s: %s", violation.Key, violation.Value, violation.Reason)
+}
+
+type ComplianceResult struct {
+	Resource   *inventory.Resource `json:"Resource"`
+	Violations []TagViolation      `json:"Violations,omitempty"`
+	// Value of the first owner tag found, "key=value".
+	OwnerHint string `json:"OwnerHint,omitempty"`
+}
+
+func (result *ComplianceResult) Compliant() bool {
+	return len(result.Violations) == 0
+}
+
+// Check lists the schema violations of a single resource.
+func (schema *TagSchema) Check(resource *inventory.Resource) *ComplianceResult {
+	ret := &ComplianceResult{Resource: resource, Violations: []TagViolation{}}
+	for _, required := range schema.Required {
+		if len(required.Types) > 0 && !slices.Contains(required.Types, resource.Type) {
+			continue
+		}
+		value, found := resource.Tags[required.Key]
+		switch {
+		case !found:
+			ret.Violations = append(ret.Violations, TagViolation{Key: required.Key, Reason: "missing"})
+		case len(required.AllowedValues) > 0 && !slices.Contains(required.AllowedValues, value):
+			ret.Violations = append(ret.Violations, TagViolation{Key: required.Key, Value: value, Reason: "not one of " + strings.Join(required.AllowedValues, "|")})
+		case required.regex != nil && !required.regex.MatchString(value):
+			ret.Violations = append(ret.Violations, TagViolation{Key: required.Key, Value: value, Reason: "does not match " + required.Regex})
+		}
+	}
+
+	ownerTags := schema.OwnerTags
+	if len(ownerTags) == 0 {
+		ownerTags = DefaultOwnerTags
+	}
+	for _, key := range ownerTags {
+		if value := resource.Tags[key]; value != "" {
+			ret.OwnerHint = key + "=" + value
+			break
+		}
+	}
+	return ret
+}
+
+// CheckCompliance lists the schema resource types of the scope and checks every resource.
+func CheckCompliance(ctx context.Context, scope *inventory.Scope, schema *TagSchema) ([]*ComplianceResult, error) {
+	err := schema.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return checkCompliance(ctx, scope, schema)
+}
+
+// The schema must be validated, it is only read and can be shared by concurrent targets.
+func checkCompliance(ctx context.Context, scope *inventory.Scope, schema *TagSchema) ([]*ComplianceResult, error) {
+	errorPrefix := "[tag_compliance:checkCompliance]"
+
+	resources := []*inventory.Resource{}
+	for _, resourceType := range schema.types() {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		adapter, err := FindTagAdapter(resourceType)
+		if err != nil {
+			return nil, err
+		}
+		err = adapter.List(ctx, scope, func(resource *inventory.Resource) { resources = append(resources, resource) })
+		if err != nil {
+			return nil, fmt.Errorf("%s %s\n%w", errorPrefix, resourceType, err)
+		}
+	}
+	inventory.SortResources(resources)
+
+	ret := make([]*ComplianceResult, 0, len(resources))
+	for _, resource := range resources {
+		ret = append(ret, schema.Check(resource))
+	}
+	lg.InfoF("%s/%s: checked %d resources", scope.Account, scope.Region, len(ret))
+	return ret, nil
+}
+
+// CheckComplianceTarget checks a single account and region, the account id is resolved with STS.
+func CheckComplianceTarget(ctx context.Context, factory *clients.Factory, region string, schema *TagSchema) ([]*ComplianceResult, error) {
+	err := schema.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return checkComplianceTarget(ctx, factory, region, schema)
+}
+
+func checkComplianceTarget(ctx context.Context, factory *clients.Factory, region string, schema *TagSchema) ([]*ComplianceResult, error) {
+	account, err := factory.STS().GetAccount()
+	if err != nil {
+		return nil, fmt.Errorf("[tag_compliance:CheckComplianceTarget] Resolving the account id\n%w", err)
+	}
+	return checkCompliance(ctx, &inventory.Scope{Account: *account, Region: region, Clients: factory}, schema)
+}
+
+// ComplianceReport holds the results of all the checked targets.
+type ComplianceReport struct {
+	Results []*ComplianceResult `json:"-"`
+}
+
+// CheckComplianceAllTargets checks every executor target. The failed targets are left out of
+// the ComplianceReport, the executor report lists them. The schema is validated before the
+// targets run, an invalid schema fails the whole check.
+func CheckComplianceAllTargets(ctx context.Context, executor *clients.Executor, schema *TagSchema) (*ComplianceReport, *clients.Report[[]*ComplianceResult], error) {
+	err := schema.Validate()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	report := clients.Execute(ctx, executor, func(ctx context.Context, target clients.Target, factory *clients.Factory) ([]*ComplianceResult, error) {
+		return checkComplianceTarget(ctx, factory, target.Region, schema)
+	})
+	lg.InfoF("Compliance report: %s", report)
+
+	ret := &ComplianceReport{Results: []*ComplianceResult{}}
+	for _, result := range report.Succeeded() {
+		ret.Results = append(ret.Results, result.Value...)
+	}
+	return ret, report, nil
+}
+
+type ComplianceSummary struct {
+	Account   string  `json:"Account"`
+	Region    string  `json:"Region"`
+	Resources int     `json:"Resources"`
+	Compliant int     `json:"Compliant"`
+	Percent   float64 `json:"Percent"`
+}
+
+// Summaries per account and region, followed by the per account ones with ComplianceAllRegions.
+func (report *ComplianceReport) Summaries() []*ComplianceSummary {
+	byKey := map[string]*ComplianceSummary{}
+	for _, result := range report.Results {
+		for _, region := range []string{result.Resource.Region, ComplianceAllRegions} {
+			key := result.Resource.Account + "/" + region
+			summary, found := byKey[key]
+			if !found {
+				summary = &ComplianceSummary{Account: result.Resource.Account, Region: region}
+				byKey[key] = summary
+			}
+			summary.Resources++
+			if result.Compliant() {
+				summary.Compliant++
+			}
+		}
+	}
+
+	ret := []*ComplianceSummary{}
+	for _, summary := range byKey {
+		summary.Percent = float64(summary.Compliant) * 100 / float64(summary.Resources)
+		ret = append(ret, summary)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if (ret[i].Region == ComplianceAllRegions) != (ret[j].Region == ComplianceAllRegions) {
+			return ret[j].Region == ComplianceAllRegions
+		}
+		if ret[i].Account != ret[j].Account {
+			return ret[i].Account < ret[j].Account
+		}
+		return ret[i].Region < ret[j].Region
+	})
+	return ret
+}
+
+func (report *ComplianceReport) Offenders() []*ComplianceResult {
+	ret := []*ComplianceResult{}
+	for _, result := range report.Results {
+		if !result.Compliant() {
+			ret = append(ret, result)
+		}
+	}
+	return ret
+}
+
+func violationsString(violations []TagViolation) string {
+	ret := []string{}
+	for _, violation := range violations {
+		ret = append(ret, violation.String())
+	}
+	return strings.Join(ret, "; ")
+}
+
+// Write renders the summaries and the offenders in one of the ComplianceFormat* formats.
+func (report *ComplianceReport) Write(writer io.Writer, format string) error {
+	switch format {
+	case ComplianceFormatJSON:
+		return report.writeJSON(writer)
+	case ComplianceFormatCSV:
+		return report.writeCSV(writer)
+	case ComplianceFormatMarkdown:
+		return report.writeMarkdown(writer)
+	}
+	return fmt.Errorf("[tag_compliance:Write] unknown format: %s", format)
+}
+
+func (report *ComplianceReport) writeJSON(writer io.Writer) error {
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(struct {
+		Summaries []*ComplianceSummary `json:"Summaries"`
+		Offenders []*ComplianceResult  `json:"Offenders"`
+	}{report.Summaries(), report.Offenders()})
+}
+
+// A single table: the summary rows have an empty resource.
+func (report *ComplianceReport) writeCSV(writer io.Writer) error {
+	csvWriter := csv.NewWriter(writer)
+	rows := [][]string{{"Account", "Region", "Type", "Resource", "Violations", "OwnerHint", "Resources", "Compliant", "Percent"}}
+	for _, summary := range report.Summaries() {
+		rows = append(rows, []string{summary.Account, summary.Region, "", "", "", "",
+			fmt.Sprint(summary.Resources), fmt.Sprint(summary.Compliant), fmt.Sprintf("%.1f", summary.Percent)})
+	}
+	for _, offender := range report.Offenders() {
+		resource := offender.Resource
+		rows = append(rows, []string{resource.Account, resource.Region, resource.Type, resource.Key(), violationsString(offender.Violations), offender.OwnerHint, "", "", ""})
+	}
+	err := csvWriter.WriteAll(rows)
+	if err != nil {
+		return fmt.Errorf("[tag_compliance:writeCSV] %w", err)
+	}
+	return nil
+}
+
+func markdownCell(value string) string {
+	return strings.ReplaceAll(value, "|", "\\|")
+}
+
+func (report *ComplianceReport) writeMarkdown(writer io.Writer) error {
+	lines := []string{"# Tag compliance", "", "| Account | Region | Resources | Compliant | Percent |", "|---|---|---:|---:|---:|"}
+	for _, summary := range report.Summaries() {
+		lines = append(lines, fmt.Sprintf("| %s | %s | %d | %d | %.1f%% |", summary.Account, markdownCell(summary.Region), summary.Resources, summary.Compliant, summary.Percent))
+	}
+
+	offenders := report.Offenders()
+	lines = append(lines, "", fmt.Sprintf("## Offenders (%d)", len(offenders)), "")
+	if len(offenders) > 0 {
+		lines = append(lines, "| Account | Region | Type | Resource | Violations | Owner hint |", "|---|---|---|---|---|---|")
+		for _, offender := range offenders {
+			resource := offender.Resource
+			lines = append(lines, fmt.Sprintf("| %s | %s | %s | %s | %s | %s |", resource.Account, resource.Region, resource.Type,
+				markdownCell(resource.Key()), markdownCell(violationsString(offender.Violations)), markdownCell(offender.OwnerHint)))
+		}
+	}
+	_, err := io.WriteString(writer, strings.Join(lines, "\n")+"\n")
+	return err
+}
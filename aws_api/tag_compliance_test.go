@@ -0,0 +1,186 @@
+package aws_api
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	clients "github.com/AlexeyBeley/go_misc/aws_api/clients"
+	"github.com/AlexeyBeley/go_misc/aws_api/clients/fakes"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+func complianceSchema() *TagSchema {
+	return &TagSchema{Types: []string{"ec2:instance", "s3:bucket", "ecs:task-definition"},
+		Required: []*RequiredTag{
+			{Key: "Env", AllowedValues: []string{"prod", "staging"}},
+			{Key: "Owner", Regex: "^[a-z]+@example\\.com$", Types: []string{"ec2:instance"}},
+		}}
+}
+
+func TestCheckComplianceAllTargetsFake(t *testing.T) {
+	t.Run("Valid run", func(t *testing.T) {
+		services := fakes.ServicesNew()
+		services.EC2.Instances = []*ec2.Instance{
+			{InstanceId: strPtr("i-1"), Tags: ec2Tags(map[string]string{"Env": "prod", "Owner": "alice@example.com"})},
+			{InstanceId: strPtr("i-2"), Tags: ec2Tags(map[string]string{"Env": "dev", "Owner": "bob", "Team": "data"})},
+		}
+		services.S3.Buckets = []*s3.Bucket{{Name: strPtr("bucket-west")}}
+		services.S3.BucketRegions = map[string]string{"bucket-west": "eu-west-1"}
+		services.S3.Tags = map[string]map[string]string{"bucket-west": {"Env": "staging"}}
+		services.ECS.TaskDefinitions = []*ecs.TaskDefinition{
+			{Family: strPtr("web"), Revision: clients.Int64Ptr(1), TaskDefinitionArn: strPtr("arn:aws:ecs:us-east-1:123456789012:task-definition/web:1")},
+			{Family: strPtr("web"), Revision: clients.Int64Ptr(2), TaskDefinitionArn: strPtr("arn:aws:ecs:us-east-1:123456789012:task-definition/web:2")},
+		}
+		services.ECS.Tags = map[string]map[string]string{"arn:aws:ecs:us-east-1:123456789012:task-definition/web:1": {"Env": "prod"}}
+
+		// The fakes are not regional: the task definitions are checked in both regions.
+		executor := clients.ExecutorNewWithTargets([]clients.Target{{Region: "us-east-1"}, {Region: "eu-west-1"}}, 2)
+		executor.FactoryNew = func(clients.Target) (*clients.Factory, error) { return services.Factory(), nil }
+
+		report, executorReport, err := CheckComplianceAllTargets(context.Background(), executor, complianceSchema())
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if err := executorReport.Err(); err != nil {
+			t.Fatalf("%v", err)
+		}
+		offenders := report.Offenders()
+		if len(report.Results) != 7 || len(offenders) != 4 {
+			t.Fatalf("unexpected results: %d, offenders: %d", len(report.Results), len(offenders))
+		}
+		instance := offenders[0]
+		if instance.Resource.Id != "i-2" || instance.OwnerHint != "Owner=bob" ||
+			violationsString(instance.Violations) != "Env=dev: not one of prod|staging; Owner=bob: does not match ^[a-z]+@example\\.com$" {
+			t.Errorf("unexpected offender: %s %s %s", instance.Resource.Id, instance.OwnerHint, violationsString(instance.Violations))
+		}
+		if offenders[1].Resource.Key() != "arn:aws:ecs:us-east-1:123456789012:task-definition/web:2" || violationsString(offenders[1].Violations) != "Env: missing" {
+			t.Errorf("only the latest task definition revision must be checked: %s", offenders[1].Resource.Key())
+		}
+
+		expected := []ComplianceSummary{
+			{Account: "123456789012", Region: "eu-west-1", Resources: 4, Compliant: 2, Percent: 50},
+			{Account: "123456789012", Region: "us-east-1", Resources: 3, Compliant: 1, Percent: 100.0 / 3},
+			{Account: "123456789012", Region: ComplianceAllRegions, Resources: 7, Compliant: 3, Percent: 300.0 / 7},
+		}
+		summaries := report.Summaries()
+		if len(summaries) != len(expected) {
+			t.Fatalf("unexpected summaries: %v", summaries)
+		}
+		for index, summary := range summaries {
+			if *summary != expected[index] {
+				t.Errorf("summary %d: %+v, expected %+v", index, *summary, expected[index])
+			}
+		}
+	})
+}
+
+// The targets share the schema, run with -race.
+func TestCheckComplianceConcurrentTargetsFake(t *testing.T) {
+	t.Run("Valid run", func(t *testing.T) {
+		targets := []clients.Target{}
+		for _, region := range []string{"us-east-1", "us-east-2", "us-west-1", "us-west-2", "eu-west-1", "eu-central-1"} {
+			targets = append(targets, clients.Target{Region: region})
+		}
+		executor := clients.ExecutorNewWithTargets(targets, len(targets))
+		executor.FactoryNew = func(clients.Target) (*clients.Factory, error) {
+			services := fakes.ServicesNew()
+			services.EC2.Instances = []*ec2.Instance{
+				{InstanceId: strPtr("i-1"), Tags: ec2Tags(map[string]string{"Env": "prod", "Owner": "alice@example.com"})},
+				{InstanceId: strPtr("i-2"), Tags: ec2Tags(map[string]string{"Env": "prod", "Owner": "bob"})},
+			}
+			return services.Factory(), nil
+		}
+
+		schema := &TagSchema{Types: []string{"ec2:instance"}, Required: complianceSchema().Required}
+		report, executorReport, err := CheckComplianceAllTargets(context.Background(), executor, schema)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if err := executorReport.Err(); err != nil {
+			t.Fatalf("%v", err)
+		}
+		if len(report.Results) != 2*len(targets) || len(report.Offenders()) != len(targets) {
+			t.Errorf("unexpected results: %d, offenders: %d", len(report.Results), len(report.Offenders()))
+		}
+	})
+
+	t.Run("Invalid schema", func(t *testing.T) {
+		executor := clients.ExecutorNewWithTargets([]clients.Target{{Region: "us-east-1"}, {Region: "eu-west-1"}}, 2)
+		executor.FactoryNew = func(clients.Target) (*clients.Factory, error) {
+			t.Errorf("no target must run with an invalid schema")
+			return fakes.ServicesNew().Factory(), nil
+		}
+		schema := &TagSchema{Types: []string{"ec2:instance"}, Required: []*RequiredTag{{Key: "Owner", Regex: "("}}}
+		if _, _, err := CheckComplianceAllTargets(context.Background(), executor, schema); err == nil {
+			t.Errorf("expected an error")
+		}
+	})
+}
+
+func TestComplianceReportWriteFake(t *testing.T) {
+	services := fakes.ServicesNew()
+	services.EC2.Instances = []*ec2.Instance{
+		{InstanceId: strPtr("i-1"), Tags: ec2Tags(map[string]string{"Env": "prod", "Owner": "alice@example.com"})},
+		{InstanceId: strPtr("i-2"), Tags: ec2Tags(map[string]string{"Team": "data"})},
+	}
+	executor := clients.ExecutorNewWithTargets([]clients.Target{{Region: "us-east-1"}}, 1)
+	executor.FactoryNew = func(clients.Target) (*clients.Factory, error) { return services.Factory(), nil }
+	report, _, err := CheckComplianceAllTargets(context.Background(), executor, complianceSchema())
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	t.Run("JSON", func(t *testing.T) {
+		buffer := &bytes.Buffer{}
+		if err := report.Write(buffer, ComplianceFormatJSON); err != nil {
+			t.Fatalf("%v", err)
+		}
+		decoded := struct {
+			Summaries []ComplianceSummary
+			Offenders []ComplianceResult
+		}{}
+		if err := json.Unmarshal(buffer.Bytes(), &decoded); err != nil {
+			t.Fatalf("%v", err)
+		}
+		if len(decoded.Summaries) != 2 || decoded.Summaries[0].Percent != 50 || len(decoded.Offenders) != 1 || decoded.Offenders[0].OwnerHint != "Team=data" {
+			t.Errorf("unexpected report: %s", buffer)
+		}
+	})
+
+	t.Run("CSV", func(t *testing.T) {
+		buffer := &bytes.Buffer{}
+		if err := report.Write(buffer, ComplianceFormatCSV); err != nil {
+			t.Fatalf("%v", err)
+		}
+		rows, err := csv.NewReader(buffer).ReadAll()
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if len(rows) != 4 || rows[1][8] != "50.0" || rows[3][4] != "Env: missing; Owner: missing" {
+			t.Errorf("unexpected rows: %v", rows)
+		}
+	})
+
+	t.Run("Markdown", func(t *testing.T) {
+		buffer := &bytes.Buffer{}
+		if err := report.Write(buffer, ComplianceFormatMarkdown); err != nil {
+			t.Fatalf("%v", err)
+		}
+		if !strings.Contains(buffer.String(), "| 123456789012 | us-east-1 | 2 | 1 | 50.0% |") ||
+			!strings.Contains(buffer.String(), "## Offenders (1)") {
+			t.Errorf("unexpected report:\n%s", buffer)
+		}
+	})
+
+	t.Run("Unknown format", func(t *testing.T) {
+		if err := report.Write(&bytes.Buffer{}, "xml"); err == nil {
+			t.Errorf("expected an error")
+		}
+	})
+}
@@ -0,0 +1,412 @@
+package aws_api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	inventory "github.com/AlexeyBeley/go_misc/aws_api/inventory"
+	"golang.org/x/time/rate"
+)
+
+// Tagging APIs throttle at a few calls per second per account and region.
+const DefaultTagRequestsPerSecond = 5
+
+// Keys of this prefix are reserved by AWS, policies never write or remove them.
+const reservedTagPrefix = "aws:"
+
+// TagSelector matches resources, all the set fields must match.
+type TagSelector struct {
+	// Resource types, e.g. ec2:instance, all the TagAdapters types when empty.
+	Types []string `json:"Types"`
+	// Matched against the resource id and its Name tag.
+	NameRegex string `json:"NameRegex"`
+	// Existing tags, "*" matches any value of the key.
+	Tags   map[string]string `json:"Tags"`
+	VpcIds []string          `json:"VpcIds"`
+
+	nameRegex *regexp.Regexp
+}
+
+// TagRule changes the tags of the selected resources.
+// The changes are applied in the fields order: Rename, Remove, Set, Require, CopyFromParent.
+type TagRule struct {
+	Name   string      `json:"Name"`
+	Select TagSelector `json:"Select"`
+	// Missing keys get the default value, a missing key with an empty default is reported as a violation.
+	Require map[string]string `json:"Require"`
+	// Overwrites the current values.
+	Set map[string]string `json:"Set"`
+	// Old key to new key, the value is kept. An existing new key is not overwritten.
+	Rename map[string]string `json:"Rename"`
+	Remove []string          `json:"Remove"`
+	// Keys copied from the parent resource desired tags, e.g. ASG -> instance -> volume.
+	CopyFromParent []string `json:"CopyFromParent"`
+}
+
+type TagPolicy struct {
+	Rules []*TagRule `json:"Rules"`
+	// Tagging calls per second, DefaultTagRequestsPerSecond when not set.
+	RequestsPerSecond float64 `json:"RequestsPerSecond"`
+}
+
+func LoadTagPolicy(filePath string) (*TagPolicy, error) {
+	errorPrefix := "[tag_policy:LoadTagPolicy]"
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("%s Reading %s\n%w", errorPrefix, filePath, err)
+	}
+	policy := &TagPolicy{}
+	err = json.Unmarshal(data, policy)
+	if err != nil {
+		return nil, fmt.Errorf("%s Parsing %s\n%w", errorPrefix, filePath, err)
+	}
+	return policy, policy.Validate()
+}
+
+// Validate compiles the rules, it is called by PlanTagPolicy.
+func (policy *TagPolicy) Validate() error {
+	errorPrefix := "[tag_policy:Validate]"
+
+	for index, rule := range policy.Rules {
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("rule-%d", index)
+		}
+		for _, resourceType := range rule.Select.Types {
+			if _, err := FindTagAdapter(resourceType); err != nil {
+				return fmt.Errorf("%s %s\n%w", errorPrefix, rule.Name, err)
+			}
+		}
+		if rule.Select.NameRegex != "" {
+			nameRegex, err := regexp.Compile(rule.Select.NameRegex)
+			if err != nil {
+				return fmt.Errorf("%s %s\n%w", errorPrefix, rule.Name, err)
+			}
+			rule.Select.nameRegex = nameRegex
+		}
+
+		keys := slices.Concat(slices.Collect(maps.Keys(rule.Require)), slices.Collect(maps.Keys(rule.Set)), rule.Remove, rule.CopyFromParent)
+		for oldKey, newKey := range rule.Rename {
+			keys = append(keys, oldKey, newKey)
+		}
+		for _, key := range keys {
+			if key == "" || strings.HasPrefix(key, reservedTagPrefix) {
+				return fmt.Errorf("%s %s: invalid tag key: '%s'", errorPrefix, rule.Name, key)
+			}
+		}
+	}
+	return nil
+}
+
+func (selector *TagSelector) Match(resource *inventory.Resource) bool {
+	if len(selector.Types) > 0 && !slices.Contains(selector.Types, resource.Type) {
+		return false
+	}
+	if selector.nameRegex != nil && !selector.nameRegex.MatchString(resource.Id) && !selector.nameRegex.MatchString(resource.Tags["Name"]) {
+		return false
+	}
+	for key, value := range selector.Tags {
+		current, found := resource.Tags[key]
+		if !found || (value != "*" && value != current) {
+			return false
+		}
+	}
+	if len(selector.VpcIds) > 0 && !slices.Contains(selector.VpcIds, resource.Attributes["VpcId"]) {
+		return false
+	}
+	return true
+}
+
+// TagChange converges a single resource to the policy.
+type TagChange struct {
+	Resource *inventory.Resource `json:"Resource"`
+	Set      map[string]string   `json:"Set,omitempty"`
+	Remove   []string            `json:"Remove,omitempty"`
+	// Required keys without a default value.
+	Violations []string `json:"Violations,omitempty"`
+	// Names of the matching rules.
+	Rules []string `json:"Rules"`
+}
+
+func (change *TagChange) Empty() bool {
+	return len(change.Set) == 0 && len(change.Remove) == 0
+}
+
+func (change *TagChange) String() string {
+	ret := []string{}
+	for _, key := range slices.Sorted(maps.Keys(change.Set)) {
+		if current, found := change.Resource.Tags[key]; found {
+			ret = append(ret, fmt.Sprintf("%s: %s -> %s", key, current, change.Set[key]))
+		} else {
+			ret = append(ret, fmt.Sprintf("+%s=%s", key, change.Set[key]))
+		}
+	}
+	for _, key := range change.Remove {
+		ret = append(ret, fmt.Sprintf("-%s=%s", key, change.Resource.Tags[key]))
+	}
+	for _, key := range change.Violations {
+		ret = append(ret, fmt.Sprintf("missing %s", key))
+	}
+	return fmt.Sprintf("%s %s: %s", change.Resource.Type, change.Resource.Key(), strings.Join(ret, ", "))
+}
+
+// TagPlan holds the changes of a single scope, resources that already comply are left out.
+type TagPlan struct {
+	Scope   *inventory.Scope `json:"-"`
+	Changes []*TagChange     `json:"Changes"`
+}
+
+func (plan *TagPlan) String() string {
+	lines := []string{}
+	for _, change := range plan.Changes {
+		lines = append(lines, change.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Violations counts the required keys that could not be planned.
+func (plan *TagPlan) Violations() int {
+	ret := 0
+	for _, change := range plan.Changes {
+		ret += len(change.Violations)
+	}
+	return ret
+}
+
+// Types the policy reads: the selected ones and their parents.
+func (policy *TagPolicy) types() []string {
+	ret := []string{}
+	for _, rule := range policy.Rules {
+		if len(rule.Select.Types) == 0 {
+			ret = []string{}
+			for _, adapter := range TagAdapters {
+				ret = append(ret, adapter.Type)
+			}
+			return ret
+		}
+		ret = append(ret, rule.Select.Types...)
+	}
+
+	for index := 0; index < len(ret); index++ {
+		adapter, err := FindTagAdapter(ret[index])
+		if err == nil && adapter.ParentType != "" && !slices.Contains(ret, adapter.ParentType) {
+			ret = append(ret, adapter.ParentType)
+		}
+	}
+	sort.Strings(ret)
+	return slices.Compact(ret)
+}
+
+// PlanTagPolicy lists the scope resources and computes the changes the policy requires. Nothing is written.
+func PlanTagPolicy(ctx context.Context, scope *inventory.Scope, policy *TagPolicy) (*TagPlan, error) {
+	errorPrefix := "[tag_policy:PlanTagPolicy]"
+
+	err := policy.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	resources := []*inventory.Resource{}
+	for _, resourceType := range policy.types() {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		adapter, err := FindTagAdapter(resourceType)
+		if err != nil {
+			return nil, err
+		}
+		err = adapter.List(ctx, scope, func(resource *inventory.Resource) { resources = append(resources, resource) })
+		if err != nil {
+			return nil, fmt.Errorf("%s %s\n%w", errorPrefix, resourceType, err)
+		}
+	}
+	inventory.SortResources(resources)
+
+	planner := &tagPlanner{policy: policy,
+		byId:    map[string]*inventory.Resource{},
+		desired: map[*inventory.Resource]map[string]string{},
+		rules:   map[*inventory.Resource][]string{},
+		missing: map[*inventory.Resource][]string{}}
+	for _, resource := range resources {
+		planner.byId[resource.Type+"/"+resource.Id] = resource
+	}
+
+	plan := &TagPlan{Scope: scope, Changes: []*TagChange{}}
+	for _, resource := range resources {
+		desired := planner.desiredTags(resource, map[*inventory.Resource]bool{})
+		if len(planner.rules[resource]) == 0 {
+			continue
+		}
+		change := &TagChange{Resource: resource, Set: map[string]string{}, Remove: []string{}, Violations: planner.missing[resource], Rules: planner.rules[resource]}
+		for key, value := range desired {
+			if current, found := resource.Tags[key]; !found || current != value {
+				change.Set[key] = value
+			}
+		}
+		for key := range resource.Tags {
+			if _, found := desired[key]; !found && !strings.HasPrefix(key, reservedTagPrefix) {
+				change.Remove = append(change.Remove, key)
+			}
+		}
+		sort.Strings(change.Remove)
+		if change.Empty() && len(change.Violations) == 0 {
+			continue
+		}
+		plan.Changes = append(plan.Changes, change)
+	}
+	return plan, nil
+}
+
+type tagPlanner struct {
+	policy *TagPolicy
+	// By type/id, parents are looked up by id.
+	byId    map[string]*inventory.Resource
+	desired map[*inventory.Resource]map[string]string
+	rules   map[*inventory.Resource][]string
+	missing map[*inventory.Resource][]string
+}
+
+// Tags the resource has once the matching rules are applied, the rules select by the current tags.
+// Parents are planned first so the copied values are the parents desired ones.
+func (planner *tagPlanner) desiredTags(resource *inventory.Resource, visiting map[*inventory.Resource]bool) map[string]string {
+	if desired, found := planner.desired[resource]; found {
+		return desired
+	}
+
+	desired := map[string]string{}
+	for key, value := range resource.Tags {
+		desired[key] = value
+	}
+	if visiting[resource] {
+		return desired
+	}
+	visiting[resource] = true
+
+	missing := []string{}
+	for _, rule := range planner.policy.Rules {
+		if !rule.Select.Match(resource) {
+			continue
+		}
+		planner.rules[resource] = append(planner.rules[resource], rule.Name)
+
+		for oldKey, newKey := range rule.Rename {
+			value, found := desired[oldKey]
+			if !found {
+				continue
+			}
+			if _, found := desired[newKey]; !found {
+				desired[newKey] = value
+			}
+			delete(desired, oldKey)
+		}
+		for _, key := range rule.Remove {
+			delete(desired, key)
+		}
+		for key, value := range rule.Set {
+			desired[key] = value
+		}
+		for key, defaultValue := range rule.Require {
+			if _, found := desired[key]; found {
+				continue
+			}
+			if defaultValue == "" {
+				missing = append(missing, key)
+				continue
+			}
+			desired[key] = defaultValue
+		}
+		if len(rule.CopyFromParent) > 0 {
+			parent := planner.parent(resource)
+			if parent == nil {
+				continue
+			}
+			parentTags := planner.desiredTags(parent, visiting)
+			for _, key := range rule.CopyFromParent {
+				if value, found := parentTags[key]; found {
+					desired[key] = value
+				}
+			}
+		}
+	}
+
+	// A later rule or the parent may have provided the required key.
+	planner.missing[resource] = slices.DeleteFunc(slices.Compact(slices.Sorted(slices.Values(missing))), func(key string) bool {
+		_, found := desired[key]
+		return found
+	})
+	planner.desired[resource] = desired
+	return desired
+}
+
+func (planner *tagPlanner) parent(resource *inventory.Resource) *inventory.Resource {
+	adapter, err := FindTagAdapter(resource.Type)
+	if err != nil || adapter.ParentId == nil {
+		return nil
+	}
+	parentId := adapter.ParentId(resource)
+	if parentId == "" {
+		return nil
+	}
+	return planner.byId[adapter.ParentType+"/"+parentId]
+}
+
+// ApplyTagPlan writes the plan changes, rate limited to policy RequestsPerSecond.
+// A failing resource does not stop the others, the errors are joined.
+func ApplyTagPlan(ctx context.Context, plan *TagPlan, requestsPerSecond float64) error {
+	errorPrefix := "[tag_policy:ApplyTagPlan]"
+
+	if requestsPerSecond <= 0 {
+		requestsPerSecond = DefaultTagRequestsPerSecond
+	}
+	limiter := rate.NewLimiter(rate.Limit(requestsPerSecond), 1)
+
+	errs := []error{}
+	for index, change := range plan.Changes {
+		if change.Empty() {
+			continue
+		}
+		adapter, err := FindTagAdapter(change.Resource.Type)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s %s\n%w", errorPrefix, change.Resource.Key(), err))
+			continue
+		}
+
+		if len(change.Set) > 0 {
+			if err = limiter.Wait(ctx); err != nil {
+				return errors.Join(append(errs, err)...)
+			}
+			if err = adapter.Tag(plan.Scope, change.Resource, change.Set); err != nil {
+				errs = append(errs, fmt.Errorf("%s %s\n%w", errorPrefix, change.Resource.Key(), err))
+				continue
+			}
+		}
+		if len(change.Remove) > 0 {
+			if err = limiter.Wait(ctx); err != nil {
+				return errors.Join(append(errs, err)...)
+			}
+			if err = adapter.Untag(plan.Scope, change.Resource, change.Remove); err != nil {
+				errs = append(errs, fmt.Errorf("%s %s\n%w", errorPrefix, change.Resource.Key(), err))
+				continue
+			}
+		}
+		lg.InfoF("Applied %d/%d tag changes: %s", index+1, len(plan.Changes), change)
+	}
+	return errors.Join(errs...)
+}
+
+// ApplyTagPolicy plans and applies the policy in a single scope.
+func ApplyTagPolicy(ctx context.Context, scope *inventory.Scope, policy *TagPolicy) (*TagPlan, error) {
+	plan, err := PlanTagPolicy(ctx, scope, policy)
+	if err != nil {
+		return nil, err
+	}
+	return plan, ApplyTagPlan(ctx, plan, policy.RequestsPerSecond)
+}
@@ -0,0 +1,183 @@
+package aws_api
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AlexeyBeley/go_misc/aws_api/clients/fakes"
+	inventory "github.com/AlexeyBeley/go_misc/aws_api/inventory"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+func ec2Tags(tags map[string]string) []*ec2.Tag {
+	ret := []*ec2.Tag{}
+	for key, value := range tags {
+		ret = append(ret, &ec2.Tag{Key: strPtr(key), Value: strPtr(value)})
+	}
+	return ret
+}
+
+func taggedServices() *fakes.Services {
+	services := fakes.ServicesNew()
+	services.Autoscaling.AutoScalingGroups = []*autoscaling.Group{
+		{AutoScalingGroupName: strPtr("web"), Tags: []*autoscaling.TagDescription{{Key: strPtr("Team"), Value: strPtr("web")}, {Key: strPtr("CostCenter"), Value: strPtr("42")}}},
+	}
+	services.EC2.Instances = []*ec2.Instance{
+		{InstanceId: strPtr("i-1"), VpcId: strPtr("vpc-1"), Tags: ec2Tags(map[string]string{"aws:autoscaling:groupName": "web", "Name": "web-1", "owner": "alice"})},
+		{InstanceId: strPtr("i-2"), VpcId: strPtr("vpc-2"), Tags: ec2Tags(map[string]string{"Name": "batch-1", "Temporary": "yes"})},
+	}
+	services.EC2.Volumes = []*ec2.Volume{
+		{VolumeId: strPtr("vol-1"), Attachments: []*ec2.VolumeAttachment{{InstanceId: strPtr("i-1")}}},
+		{VolumeId: strPtr("vol-2")},
+	}
+	return services
+}
+
+func TestPlanTagPolicyFake(t *testing.T) {
+	t.Run("Valid run", func(t *testing.T) {
+		services := taggedServices()
+		scope := &inventory.Scope{Account: "123456789012", Region: "us-east-1", Clients: services.Factory()}
+		policy := &TagPolicy{Rules: []*TagRule{
+			{Name: "owner", Select: TagSelector{Types: []string{"ec2:instance"}}, Rename: map[string]string{"owner": "Owner"}, Remove: []string{"Temporary"}},
+			{Name: "cost", Select: TagSelector{Types: []string{"ec2:instance", "ec2:volume"}}, CopyFromParent: []string{"Team", "CostCenter"}},
+			{Name: "vpc-2", Select: TagSelector{Types: []string{"ec2:instance"}, VpcIds: []string{"vpc-2"}}, Require: map[string]string{"Owner": ""}},
+		}}
+
+		plan, err := PlanTagPolicy(context.Background(), scope, policy)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		expected := []string{
+			"ec2:instance arn:aws:ec2:us-east-1:123456789012:instance/i-1: +CostCenter=42, +Owner=alice, +Team=web, -owner=alice",
+			"ec2:instance arn:aws:ec2:us-east-1:123456789012:instance/i-2: -Temporary=yes, missing Owner",
+			"ec2:volume arn:aws:ec2:us-east-1:123456789012:volume/vol-1: +CostCenter=42, +Team=web",
+		}
+		if len(plan.Changes) != len(expected) {
+			t.Fatalf("unexpected plan:\n%s", plan)
+		}
+		for index, change := range plan.Changes {
+			if change.String() != expected[index] {
+				t.Errorf("change %d: %s, expected %s", index, change, expected[index])
+			}
+		}
+		if plan.Violations() != 1 || services.EC2.TagRequests != 0 {
+			t.Errorf("planning must not tag: %d violations, %d requests", plan.Violations(), services.EC2.TagRequests)
+		}
+
+		err = ApplyTagPlan(context.Background(), plan, 100)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		instance := services.EC2.Tags["i-1"]
+		if instance["Owner"] != "alice" || instance["Team"] != "web" || services.EC2.Tags["vol-1"]["CostCenter"] != "42" {
+			t.Errorf("unexpected tags: %v", services.EC2.Tags)
+		}
+
+		plan, err = PlanTagPolicy(context.Background(), scope, policy)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if len(plan.Changes) != 1 || !plan.Changes[0].Empty() {
+			t.Errorf("applied policy must only report the violation:\n%s", plan)
+		}
+	})
+
+	t.Run("Name regex and tags selector", func(t *testing.T) {
+		services := taggedServices()
+		scope := &inventory.Scope{Region: "us-east-1", Clients: services.Factory()}
+		policy := &TagPolicy{Rules: []*TagRule{
+			{Select: TagSelector{Types: []string{"ec2:instance"}, NameRegex: "^web-", Tags: map[string]string{"owner": "*"}}, Set: map[string]string{"Tier": "frontend"}},
+		}}
+
+		plan, err := PlanTagPolicy(context.Background(), scope, policy)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if len(plan.Changes) != 1 || plan.Changes[0].Resource.Id != "i-1" || plan.Changes[0].Rules[0] != "rule-0" {
+			t.Errorf("unexpected plan:\n%s", plan)
+		}
+	})
+
+	t.Run("Invalid policy", func(t *testing.T) {
+		scope := &inventory.Scope{Region: "us-east-1", Clients: fakes.ServicesNew().Factory()}
+		for _, policy := range []*TagPolicy{
+			{Rules: []*TagRule{{Select: TagSelector{Types: []string{"lambda:function"}}}}},
+			{Rules: []*TagRule{{Select: TagSelector{NameRegex: "("}}}},
+			{Rules: []*TagRule{{Remove: []string{"aws:cloudformation:stack-name"}}}},
+		} {
+			if _, err := PlanTagPolicy(context.Background(), scope, policy); err == nil {
+				t.Errorf("expected an error: %+v", policy.Rules[0])
+			}
+		}
+	})
+}
+
+func TestApplyTagPlanFake(t *testing.T) {
+	t.Run("Rate limit", func(t *testing.T) {
+		services := fakes.ServicesNew()
+		for _, instanceId := range []string{"i-1", "i-2", "i-3"} {
+			services.EC2.Instances = append(services.EC2.Instances, &ec2.Instance{InstanceId: strPtr(instanceId)})
+		}
+		scope := &inventory.Scope{Region: "us-east-1", Clients: services.Factory()}
+		policy := &TagPolicy{Rules: []*TagRule{{Set: map[string]string{"Env": "test"}}}, RequestsPerSecond: 20}
+
+		start := time.Now()
+		plan, err := ApplyTagPolicy(context.Background(), scope, policy)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if len(plan.Changes) != 3 || services.EC2.TagRequests != 3 {
+			t.Errorf("unexpected tagging: %d changes, %d requests", len(plan.Changes), services.EC2.TagRequests)
+		}
+		if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+			t.Errorf("3 requests at 20/s took %s", elapsed)
+		}
+	})
+
+	t.Run("Unsupported resource", func(t *testing.T) {
+		services := fakes.ServicesNew()
+		services.EC2.Instances = []*ec2.Instance{{InstanceId: strPtr("i-1")}, {InstanceId: strPtr("i-2")}}
+		scope := &inventory.Scope{Region: "us-east-1", Clients: services.Factory()}
+		plan, err := PlanTagPolicy(context.Background(), scope, &TagPolicy{Rules: []*TagRule{{Set: map[string]string{"Env": "test"}}}})
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		plan.Changes = append([]*TagChange{{Resource: &inventory.Resource{Type: "lambda:function", Id: "handler"}, Set: map[string]string{"Env": "test"}}}, plan.Changes...)
+		plan.Changes[2].Resource.Type = "lambda:layer"
+
+		err = ApplyTagPlan(context.Background(), plan, 100)
+		if err == nil || !strings.Contains(err.Error(), "lambda:function") || !strings.Contains(err.Error(), "lambda:layer") || services.EC2.TagRequests != 1 {
+			t.Errorf("expected both errors and the supported change applied, got %v with %d requests", err, services.EC2.TagRequests)
+		}
+	})
+
+	t.Run("Context cancellation", func(t *testing.T) {
+		services := fakes.ServicesNew()
+		services.EC2.Instances = []*ec2.Instance{{InstanceId: strPtr("i-1")}, {InstanceId: strPtr("i-2")}}
+		scope := &inventory.Scope{Region: "us-east-1", Clients: services.Factory()}
+		plan, err := PlanTagPolicy(context.Background(), scope, &TagPolicy{Rules: []*TagRule{{Set: map[string]string{"Env": "test"}}}})
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if err = ApplyTagPlan(ctx, plan, 1); err == nil || services.EC2.TagRequests != 0 {
+			t.Errorf("expected cancellation, got %v with %d requests", err, services.EC2.TagRequests)
+		}
+	})
+}
+
+func TestInitFromMFake(t *testing.T) {
+	t.Run("Invalid input", func(t *testing.T) {
+		for _, source := range []any{"text", map[string]any{"AddTags": map[string]any{}}, map[string]any{"Region": "us-east-1", "AddTags": map[string]any{"Env": 1}}} {
+			config := &ModifyTagsConfig{}
+			if err := config.InitFromM(source); err == nil {
+				t.Errorf("expected an error: %v", source)
+			}
+		}
+	})
+}
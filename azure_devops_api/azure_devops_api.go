@@ -35,10 +35,16 @@ var lg = logger.Logger{Level: logger.INFO}
 
 type Configuration struct {
 	PersonalAccessToken    string                       `json:"PersonalAccessToken"`
+	OAuthToken             string                       `json:"OAuthToken"`
+	BaseURL                string                       `json:"BaseURL"`
+	RequestTimeoutSeconds  int                          `json:"RequestTimeoutSeconds"`
+	MaxRetries             int                          `json:"MaxRetries"`
+	DebugHTTP              bool                         `json:"DebugHTTP"`
 	OrganizationName       string                       `json:"OrganizationName"`
 	TeamName               string                       `json:"TeamName"`
 	ProjectName            string                       `json:"ProjectName"`
 	SprintName             string                       `json:"SprintName"`
+	SprintsParentPath      string                       `json:"SprintsParentPath"`
 	AreaPath               string                       `json:"AreaPath"`
 	SystemAreaID           string                       `json:"SystemAreaID"`
 	AreaPathByUserId       map[string]string            `json:"AreaPathByUserId"`
@@ -99,28 +105,31 @@ func GetAllWits(config Configuration) error {
 	// Fetch work item IDs in batches using WIQL
 	ids, err := getWorkItemIDs(config, ctx)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 	fmt.Printf("fetched %d\n", len(ids))
 	return nil
 }
 
-func GetCoreClientAndCtx(config Configuration) (core.Client, context.Context, error) {
-	organizationUrl := "https://dev.azure.com/" + config.OrganizationName // todo: replace value with your organization url
-
-	// Create a connection to your organization
-	connection := azuredevops.NewPatConnection(organizationUrl, config.PersonalAccessToken)
+// Creates sdk client of the resource area, sending through the shared Transport.
+func getSDKClient(config Configuration, ctx context.Context, resourceAreaId uuid.UUID) (*azuredevops.Client, error) {
+	transport, err := TransportNew(&config)
+	if err != nil {
+		return nil, err
+	}
+	return transport.SDKClientNew(ctx, resourceAreaId)
+}
 
+func GetCoreClientAndCtx(config Configuration) (core.Client, context.Context, error) {
 	ctx := context.Background()
 
 	// Create a client to interact with the Core area
-	coreClient, err := core.NewClient(ctx, connection)
-
+	client, err := getSDKClient(config, ctx, core.ResourceAreaId)
 	if err != nil {
-		return coreClient, ctx, err
+		return nil, ctx, err
 	}
 
-	return coreClient, ctx, nil
+	return &core.ClientImpl{Client: *client}, ctx, nil
 }
 
 // Helper function to create basic authentication header
@@ -129,21 +138,15 @@ func basicAuth(pat string) string {
 }
 
 func GetWorkClientAndCtx(config Configuration) (work.Client, context.Context, error) {
-	organizationUrl := "https://dev.azure.com/" + config.OrganizationName
-
-	// Create a connection to your organization
-	connection := azuredevops.NewPatConnection(organizationUrl, config.PersonalAccessToken)
-
 	ctx := context.Background()
 
 	// Create a client to interact with the Work area
-	Client, err := work.NewClient(ctx, connection)
-
+	client, err := getSDKClient(config, ctx, work.ResourceAreaId)
 	if err != nil {
-		return Client, ctx, err
+		return nil, ctx, err
 	}
 
-	return Client, ctx, nil
+	return &work.ClientImpl{Client: *client}, ctx, nil
 }
 
 func ValidateConfig(config Configuration) error {
@@ -158,25 +161,16 @@ func GetWorkItemTrackingClientAndCtx(config Configuration) (workitemtracking.Cli
 	if err != nil {
 		return nil, nil, err
 	}
-	organizationUrl := "https://dev.azure.com/" + config.OrganizationName
-
-	// Create a connection to your organization
-	connection := azuredevops.NewPatConnection(organizationUrl, config.PersonalAccessToken)
-
 	ctx := context.Background()
-	if ctx == nil {
-		log.Fatal("Can not allocate context.Background")
-	}
-
-	// Create a client to interact with the Work area
-	Client, err := workitemtracking.NewClient(ctx, connection)
 
+	// Create a client to interact with the Work Item Tracking area
+	client, err := getSDKClient(config, ctx, workitemtracking.ResourceAreaId)
 	if err != nil {
 		log.Printf("was not able to create new workitemtracking client")
-		return Client, ctx, err
+		return nil, ctx, err
 	}
 
-	return Client, ctx, nil
+	return &workitemtracking.ClientImpl{Client: *client}, ctx, nil
 }
 
 func GetTeamUuid(config Configuration) (id uuid.UUID, err error) {
@@ -279,25 +273,18 @@ func LoadConfig(configFilePath string) (config Configuration, err error) {
 }
 
 func getWorkItemIDs(config Configuration, ctx context.Context) ([]int, error) {
-	client := http.Client{Timeout: 10 * time.Second}
-	requestUrl := "https://dev.azure.com/" + config.OrganizationName + "/" + config.ProjectName + "/_apis/wit/wiql?api-version=7.0"
+	client, err := getClient(config)
+	if err != nil {
+		return nil, err
+	}
 	wiqlData := fmt.Sprintf(`{"query": "SELECT [System.Id] FROM WorkItems Where [System.TeamProject] = '%s' AND [System.AreaId] = %s"}`, config.ProjectName, config.SystemAreaID)
-	AuthHeaderValue := "Basic " + basicAuth(config.PersonalAccessToken)
-
-	jsonBody := []byte(wiqlData)
-	bodyReader := bytes.NewReader(jsonBody)
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, requestUrl, bodyReader)
+	req, err := createRequest(config, ctx, "wit/wiql?api-version=7.0", http.MethodPost, bytes.NewReader([]byte(wiqlData)), "application/json")
 	if err != nil {
 		return []int{}, err
 	}
 
-	// Set the Authorization header
-	req.Header.Set("Authorization", AuthHeaderValue)
-	req.Header.Set("Content-Type", "application/json")
-
 	// Send the request
-
 	resp, err := client.Do(req)
 	if err != nil {
 
@@ -306,8 +293,9 @@ func getWorkItemIDs(config Configuration, ctx context.Context) ([]int, error) {
 	defer resp.Body.Close()
 
 	// Check the status code
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("HTTP status error: %d %s", resp.StatusCode, resp.Status)
+	err = CheckResponse(resp)
+	if err != nil {
+		return nil, err
 	}
 
 	// Decode the JSON response
@@ -319,44 +307,41 @@ func getWorkItemIDs(config Configuration, ctx context.Context) ([]int, error) {
 	}
 
 	// Extract work item IDs
-	lenIds := len(*queryResult.WorkItems)
-	if lenIds == 0 {
-		return []int{}, fmt.Errorf("was not able to fetch Work Item Ids, check the quert: %v", wiqlData)
-	}
-
-	//allIDs := [lenIds]int[]{}
-	var allIDs [20000]int
-	if queryResult.WorkItems != nil {
-		for i, workItem := range *queryResult.WorkItems {
-			allIDs[i] = *workItem.Id
-		}
-	} else {
-		log.Fatal("Can not fetch work item ids")
+	if queryResult.WorkItems == nil || len(*queryResult.WorkItems) == 0 {
+		return []int{}, fmt.Errorf("%w: was not able to fetch Work Item Ids, check the quert: %v", ErrNotFound, wiqlData)
 	}
 
 	// Check if there are more results
 	if queryResult.WorkItemRelations != nil && len(*queryResult.WorkItemRelations) != 0 {
-		log.Fatal("Unexpected status: Length of the WorkItemRelations is not 0")
+		return nil, fmt.Errorf("unexpected status: Length of the WorkItemRelations is not 0")
 	}
 
-	return allIDs[0:lenIds], nil
+	allIDs := []int{}
+	for _, workItem := range *queryResult.WorkItems {
+		allIDs = append(allIDs, *workItem.Id)
+	}
+
+	return allIDs, nil
 }
-func getClient() http.Client {
-	return http.Client{Timeout: 10 * time.Second}
+
+// The Transport sets the Authorization header, retries and applies the deadline.
+func getClient(config Configuration) (*http.Client, error) {
+	transport, err := TransportNew(&config)
+	if err != nil {
+		return nil, err
+	}
+	return transport.HTTPClient(), nil
 }
 
 func createRequest(config Configuration, ctx context.Context, RequestPath string, httpMethod string, body io.Reader, contentType string) (*http.Request, error) {
 
-	requestUrl := "https://dev.azure.com/" + config.OrganizationName + "/" + config.ProjectName + "/_apis/" + RequestPath
-	AuthHeaderValue := "Basic " + basicAuth(config.PersonalAccessToken)
+	requestUrl := organizationURL(config) + "/" + config.ProjectName + "/_apis/" + RequestPath
 
 	req, err := http.NewRequestWithContext(ctx, httpMethod, requestUrl, body)
 	if err != nil {
 		return req, err
 	}
 
-	// Set the Authorization header
-	req.Header.Set("Authorization", AuthHeaderValue)
 	req.Header.Set("Content-Type", contentType)
 	return req, nil
 }
@@ -378,8 +363,9 @@ func DownloadAllWits(config Configuration, dstFilePath string) error {
 	}
 
 	channels := make([]chan *[]workitemtracking.WorkItem, channelsCount)
+	errs := make([]error, channelsCount)
 	for chanIndex := range channels {
-		channels[chanIndex] = make(chan *[]workitemtracking.WorkItem)
+		channels[chanIndex] = make(chan *[]workitemtracking.WorkItem, 1)
 	}
 
 	i := 0
@@ -400,7 +386,11 @@ func DownloadAllWits(config Configuration, dstFilePath string) error {
 		WitIdsSlice := WitIds[i:endIndex]
 		chanIndex := i / BulckSize
 		go func() {
-			GetWorkItemsBySlice(config, ctx, WitIdsSlice, channels[chanIndex])
+			err := GetWorkItemsBySlice(config, ctx, WitIdsSlice, channels[chanIndex])
+			if err != nil {
+				errs[chanIndex] = err
+				channels[chanIndex] <- nil
+			}
 		}()
 
 		i += BulckSize
@@ -413,6 +403,9 @@ func DownloadAllWits(config Configuration, dstFilePath string) error {
 	for j, ch := range channels {
 		fmt.Printf("fetched from chanel %d out of %d channels\n", j, len(channels))
 		IterationWorkItems := <-ch
+		if IterationWorkItems == nil {
+			return errs[j]
+		}
 		AllWits = append(AllWits, *IterationWorkItems...)
 
 	}
@@ -426,6 +419,10 @@ func DownloadAllWits(config Configuration, dstFilePath string) error {
 
 func GetWorkItemsBySlice(config Configuration, ctx context.Context, WitIds []int, ch chan *[]workitemtracking.WorkItem) error {
 	retWorkItems := []workitemtracking.WorkItem{}
+	client, err := getClient(config)
+	if err != nil {
+		return err
+	}
 
 	for i, WitId := range WitIds {
 		fmt.Printf("fetched witid  : %d/%d\n", i, len(WitIds))
@@ -433,7 +430,6 @@ func GetWorkItemsBySlice(config Configuration, ctx context.Context, WitIds []int
 		if err != nil {
 			return err
 		}
-		client := getClient()
 
 		resp, err := client.Do(req)
 		if err != nil {
@@ -443,8 +439,9 @@ func GetWorkItemsBySlice(config Configuration, ctx context.Context, WitIds []int
 		defer resp.Body.Close()
 
 		// Check the status code
-		if resp.StatusCode != http.StatusOK {
-			return fmt.Errorf("HTTP status error: %d %s", resp.StatusCode, resp.Status)
+		err = CheckResponse(resp)
+		if err != nil {
+			return err
 		}
 
 		// Decode the JSON response
@@ -552,7 +549,10 @@ func CreateWit(config Configuration, requestDict *(map[string]string)) error {
 		log.Printf("received error in Generate Create Wit Request: %v", err)
 		return err
 	}
-	client := getClient()
+	client, err := getClient(config)
+	if err != nil {
+		return err
+	}
 
 	resp, err := client.Do(req)
 	if err != nil {
@@ -562,8 +562,9 @@ func CreateWit(config Configuration, requestDict *(map[string]string)) error {
 	defer resp.Body.Close()
 
 	// Check the status code
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("HTTP status error: %d %s", resp.StatusCode, resp.Status)
+	err = CheckResponse(resp)
+	if err != nil {
+		return err
 	}
 
 	// Decode the JSON response
@@ -599,7 +600,7 @@ func GenerateCreateWitRequest(config Configuration, requestDict *(map[string]str
 	}
 
 	if value, err := strconv.Atoi((*requestDict)["Priority"]); err != nil || value == -1 {
-		return nil, fmt.Errorf("creating Wobject has malformed Prioriy: %v, %v", value, err)
+		return nil, fmt.Errorf("creating Wobject has malformed Prioriy: %v, %w", value, err)
 	}
 
 	ctx := context.Background()
@@ -665,7 +666,7 @@ func GenerateCreateWitRequest(config Configuration, requestDict *(map[string]str
 
 	postData, err := json.Marshal(postList)
 	if err != nil {
-		return nil, fmt.Errorf("error marshaling JSON: %v", err)
+		return nil, fmt.Errorf("error marshaling JSON: %w", err)
 	}
 	req, err := createRequest(config, ctx, fmt.Sprintf("wit/workitems/%s?api-version=7.0", witUrlType), http.MethodPost, bytes.NewBuffer(postData), "application/json-patch+json")
 
@@ -715,7 +716,11 @@ func UpdateWit(config Configuration, requestDict map[string]string) error {
 		log.Printf("received error in Generate Create Wit Request: %v", err)
 		return err
 	}
-	return Patch(req)
+	client, err := getClient(config)
+	if err != nil {
+		return err
+	}
+	return Patch(client, req)
 
 }
 
@@ -736,13 +741,13 @@ func setWitParentFromWit(config Configuration, requestDict *map[string]string) e
 		"path": "/relations/-",
 		"value": map[string]string{
 			"rel": "System.LinkTypes.Hierarchy-Reverse",
-			"url": fmt.Sprintf("https://dev.azure.com/%s/%s/_apis/wit/workItems/%s", config.OrganizationName, config.ProjectName, (*requestDict)["ParentID"]),
+			"url": fmt.Sprintf("%s/%s/_apis/wit/workItems/%s", organizationURL(config), config.ProjectName, (*requestDict)["ParentID"]),
 		},
 	})
 
 	postData, err := json.Marshal(postList)
 	if err != nil {
-		return fmt.Errorf("error marshaling JSON: %v", err)
+		return fmt.Errorf("error marshaling JSON: %w", err)
 	}
 	fmt.Printf("Updating Azure Devops WorkITem  : %v\n", requestDict)
 
@@ -752,12 +757,14 @@ func setWitParentFromWit(config Configuration, requestDict *map[string]string) e
 		log.Printf("received error in Generate Create Wit Request: %v", err)
 		return err
 	}
-	return Patch(req)
+	client, err := getClient(config)
+	if err != nil {
+		return err
+	}
+	return Patch(client, req)
 
 }
-func Patch(req *http.Request) error {
-
-	client := getClient()
+func Patch(client *http.Client, req *http.Request) error {
 
 	resp, err := client.Do(req)
 	if err != nil {
@@ -767,8 +774,9 @@ func Patch(req *http.Request) error {
 	defer resp.Body.Close()
 
 	// Check the status code
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("HTTP status error: %d %s", resp.StatusCode, resp.Status)
+	err = CheckResponse(resp)
+	if err != nil {
+		return err
 	}
 
 	// Decode the JSON response
@@ -849,7 +857,7 @@ func GenerateUpdateWitRequest(config Configuration, requestDict map[string]strin
 
 	postData, err := json.Marshal(postList)
 	if err != nil {
-		return nil, fmt.Errorf("error marshaling JSON: %v", err)
+		return nil, fmt.Errorf("error marshaling JSON: %w", err)
 	}
 	fmt.Printf("Updating Azure Devops WorkITem  : %v\n", requestDict)
 
@@ -903,6 +911,7 @@ func getWorker(uniqueNamePart string) string {
 
 type AzureDevopsAPI struct {
 	Configuration          *Configuration
+	Transport              *Transport
 	GitClient              GitClient
 	BuildClient            BuildClient
 	GraphClient            GraphClient
@@ -916,8 +925,8 @@ func validateConfig(config *Configuration) error {
 	if config.OrganizationName == "" {
 		errors = append(errors, fmt.Sprintf("OrganizationName was not set"))
 	}
-	if config.PersonalAccessToken == "" {
-		errors = append(errors, fmt.Sprintf("PersonalAccessToken was not set"))
+	if config.PersonalAccessToken == "" && config.OAuthToken == "" {
+		errors = append(errors, fmt.Sprintf("PersonalAccessToken or OAuthToken was not set"))
 	}
 	if len(errors) == 0 {
 		return nil
@@ -941,45 +950,46 @@ func AzureDevopsAPINew(options ...config_pol.Option) (*AzureDevopsAPI, error) {
 		return nil, err
 	}
 
-	organizationUrl := "https://dev.azure.com/" + config.OrganizationName // todo: replace value with your organization url
-
-	// Create a connection to your organization
-	connection := azuredevops.NewPatConnection(organizationUrl, config.PersonalAccessToken)
+	transport, err := TransportNew(config)
+	if err != nil {
+		return nil, err
+	}
+	retAPI.Transport = transport
 
 	ctx := context.Background()
 
-	gitClient, err := GitClientNew(config, ctx, connection)
+	gitClient, err := GitClientNew(config, ctx, transport)
 	if err != nil {
 		return nil, err
 	}
 	retAPI.GitClient = *gitClient
 
-	BuildClient, err := BuildClientNew(config, ctx, connection)
+	BuildClient, err := BuildClientNew(config, ctx, transport)
 	if err != nil {
 		return nil, err
 	}
 	retAPI.BuildClient = *BuildClient
 
-	GraphClient, err := GraphClientNew(config, ctx, connection)
+	GraphClient, err := GraphClientNew(config, ctx, transport)
 	if err != nil {
 		return nil, err
 	}
 	retAPI.GraphClient = *GraphClient
 
-	CoreClient, err := CoreClientNew(config, ctx, connection)
+	CoreClient, err := CoreClientNew(config, ctx, transport)
 	if err != nil {
 		return nil, err
 	}
 
 	retAPI.CoreClient = *CoreClient
-	WorkClient, err := WorkClientNew(config, ctx, connection)
+	WorkClient, err := WorkClientNew(config, ctx, transport)
 	if err != nil {
 		return nil, err
 	}
 
 	retAPI.WorkClient = *WorkClient
 
-	workItemTrackingClientNew, err := WorkItemTrackingClientNew(config, ctx, connection)
+	workItemTrackingClientNew, err := WorkItemTrackingClientNew(config, ctx, transport)
 	if err != nil {
 		return nil, err
 	}
@@ -1041,6 +1051,133 @@ func (azureDevopsAPI *AzureDevopsAPI) GetPipelineDefinition() ([]build.BuildDefi
 	return Definitions, nil
 }
 
+func (azureDevopsAPI *AzureDevopsAPI) GetNormalizedPipelineDefinitions() (map[string]map[string]any, error) {
+	errorPrefix := "[azure_devops_api:GetNormalizedPipelineDefinitions]"
+
+	definitions, err := azureDevopsAPI.BuildClient.GetDefinitions()
+	if err != nil {
+		return nil, fmt.Errorf("%s Fetching definitions\n%w", errorPrefix, err)
+	}
+
+	ret := map[string]map[string]any{}
+	for _, definition := range definitions {
+		definitionFull, err := azureDevopsAPI.BuildClient.GetDefinition(definition.Id)
+		if err != nil {
+			return nil, fmt.Errorf("%s Fetching definition %d\n%w", errorPrefix, *definition.Id, err)
+		}
+
+		normalized, err := NormalizePipelineDefinition(definitionFull)
+		if err != nil {
+			return nil, fmt.Errorf("%s Normalizing definition %d\n%w", errorPrefix, *definition.Id, err)
+		}
+		ret[PipelineDefinitionName(normalized)] = normalized
+	}
+
+	return ret, nil
+}
+
+func (azureDevopsAPI *AzureDevopsAPI) ExportPipelineDefinitions(dstDir string, format string) error {
+	errorPrefix := "[azure_devops_api:ExportPipelineDefinitions]"
+
+	definitions, err := azureDevopsAPI.GetNormalizedPipelineDefinitions()
+	if err != nil {
+		return fmt.Errorf("%s Fetching definitions\n%w", errorPrefix, err)
+	}
+
+	err = os.MkdirAll(dstDir, 0755)
+	if err != nil {
+		return fmt.Errorf("%s Creating directory '%s'\n%w", errorPrefix, dstDir, err)
+	}
+
+	filePaths := []string{}
+	for _, definition := range definitions {
+		filePath, err := WritePipelineDefinition(dstDir, format, definition)
+		if err != nil {
+			return fmt.Errorf("%s Writing definition\n%w", errorPrefix, err)
+		}
+		lg.InfoF("Exported pipeline definition: %s", filePath)
+		filePaths = append(filePaths, filePath)
+	}
+
+	removedFilePaths, err := RemoveStalePipelineDefinitions(dstDir, filePaths)
+	if err != nil {
+		return fmt.Errorf("%s Removing stale definitions\n%w", errorPrefix, err)
+	}
+	for _, filePath := range removedFilePaths {
+		lg.InfoF("Removed stale pipeline definition: %s", filePath)
+	}
+
+	return nil
+}
+
+func (azureDevopsAPI *AzureDevopsAPI) DiffPipelineDefinitions(snapshotDir string) ([]PipelineDefinitionChange, error) {
+	errorPrefix := "[azure_devops_api:DiffPipelineDefinitions]"
+
+	snapshot, err := LoadPipelineSnapshot(snapshotDir)
+	if err != nil {
+		return nil, fmt.Errorf("%s Loading snapshot\n%w", errorPrefix, err)
+	}
+
+	live, err := azureDevopsAPI.GetNormalizedPipelineDefinitions()
+	if err != nil {
+		return nil, fmt.Errorf("%s Fetching live definitions\n%w", errorPrefix, err)
+	}
+
+	return DiffPipelineDefinitions(snapshot, live), nil
+}
+
+func (azureDevopsAPI *AzureDevopsAPI) GetPullRequestInsights(filter *PullRequestFilter) ([]*PullRequestInsight, error) {
+	errorPrefix := "[azure_devops_api:GetPullRequestInsights]"
+
+	searchCriteria, err := filter.GenerateSearchCriteria()
+	if err != nil {
+		return nil, fmt.Errorf("%s Generating search criteria\n%w", errorPrefix, err)
+	}
+
+	repositories, err := azureDevopsAPI.GetRepositories()
+	if err != nil {
+		return nil, fmt.Errorf("%s Fetching repositories\n%w", errorPrefix, err)
+	}
+
+	insights := []*PullRequestInsight{}
+	for _, repository := range repositories {
+		repositoryId := repository.Id.String()
+		pullRequests, err := azureDevopsAPI.GitClient.GetPullRequests(&repositoryId, searchCriteria)
+		if err != nil {
+			return nil, fmt.Errorf("%s Fetching pull requests\n%w", errorPrefix, err)
+		}
+
+		for _, pullRequest := range pullRequests {
+			if !filter.MatchDates(&pullRequest) {
+				continue
+			}
+
+			threads, err := azureDevopsAPI.GitClient.GetPullRequestThreads(&repositoryId, pullRequest.PullRequestId)
+			if err != nil {
+				return nil, fmt.Errorf("%s Fetching pull request threads\n%w", errorPrefix, err)
+			}
+
+			workItemRefs, err := azureDevopsAPI.GitClient.GetPullRequestWorkItemRefs(&repositoryId, pullRequest.PullRequestId)
+			if err != nil {
+				return nil, fmt.Errorf("%s Fetching pull request work items\n%w", errorPrefix, err)
+			}
+
+			insights = append(insights, PullRequestInsightNew(*repository.Name, &pullRequest, threads, workItemRefs))
+		}
+	}
+
+	return insights, nil
+}
+
+func (azureDevopsAPI *AzureDevopsAPI) GetPullRequestReport(filter *PullRequestFilter) (*PullRequestReport, error) {
+	insights, err := azureDevopsAPI.GetPullRequestInsights(filter)
+	if err != nil {
+		return nil, fmt.Errorf("[azure_devops_api:GetPullRequestReport] Fetching pull request insights\n%w", err)
+	}
+
+	return GeneratePullRequestReport(insights), nil
+}
+
 func (azureDevopsAPI *AzureDevopsAPI) ProvisionWitFromDict(requestDict *(map[string]string)) error {
 	// provision_work_item_from_dict
 
@@ -1069,7 +1206,7 @@ func (azureDevopsAPI *AzureDevopsAPI) GenerateCreateWitRequest(requestDict *(map
 	*/
 
 	if value, err := strconv.Atoi((*requestDict)["Priority"]); err != nil || value == -1 {
-		return nil, fmt.Errorf("creating Wobject has malformed Prioriy: %v, %v", value, err)
+		return nil, fmt.Errorf("creating Wobject has malformed Prioriy: %v, %w", value, err)
 	}
 
 	ctx := context.Background()
@@ -1135,7 +1272,7 @@ func (azureDevopsAPI *AzureDevopsAPI) GenerateCreateWitRequest(requestDict *(map
 
 	postData, err := json.Marshal(postList)
 	if err != nil {
-		return nil, fmt.Errorf("error marshaling JSON: %v", err)
+		return nil, fmt.Errorf("error marshaling JSON: %w", err)
 	}
 	req, err := azureDevopsAPI.CreateRequest(ctx, fmt.Sprintf("wit/workitems/%s?api-version=7.0", witUrlType), http.MethodPost, bytes.NewBuffer(postData), "application/json-patch+json")
 
@@ -1163,36 +1300,27 @@ func (azureDevopsAPI *AzureDevopsAPI) GetIteration() (iteration work.TeamSetting
 }
 func (azureDevopsAPI *AzureDevopsAPI) CreateRequest(ctx context.Context, RequestPath string, httpMethod string, body io.Reader, contentType string) (*http.Request, error) {
 
-	requestUrl := "https://dev.azure.com/" + azureDevopsAPI.Configuration.OrganizationName + "/" + azureDevopsAPI.Configuration.ProjectName + "/_apis/" + RequestPath
-	AuthHeaderValue := "Basic " + basicAuth(azureDevopsAPI.Configuration.PersonalAccessToken)
+	requestUrl := azureDevopsAPI.Transport.OrganizationURL + "/" + azureDevopsAPI.Configuration.ProjectName + "/_apis/" + RequestPath
 
 	req, err := http.NewRequestWithContext(ctx, httpMethod, requestUrl, body)
 	if err != nil {
 		return req, err
 	}
 
-	// Set the Authorization header
-	req.Header.Set("Authorization", AuthHeaderValue)
 	req.Header.Set("Content-Type", contentType)
 	return req, nil
 }
 
 func (azureDevopsAPI *AzureDevopsAPI) GetWorkClientAndCtx() (work.Client, context.Context, error) {
-	organizationUrl := "https://dev.azure.com/" + azureDevopsAPI.Configuration.OrganizationName
-
-	// Create a connection to your organization
-	connection := azuredevops.NewPatConnection(organizationUrl, azureDevopsAPI.Configuration.PersonalAccessToken)
-
 	ctx := context.Background()
 
 	// Create a client to interact with the Work area
-	Client, err := work.NewClient(ctx, connection)
-
+	client, err := azureDevopsAPI.Transport.SDKClientNew(ctx, work.ResourceAreaId)
 	if err != nil {
-		return Client, ctx, err
+		return nil, ctx, err
 	}
 
-	return Client, ctx, nil
+	return &work.ClientImpl{Client: *client}, ctx, nil
 }
 
 func (azureDevopsAPI *AzureDevopsAPI) GetWorker(Name *string) (*human_api_types.Worker, error) {
@@ -1200,18 +1328,18 @@ func (azureDevopsAPI *AzureDevopsAPI) GetWorker(Name *string) (*human_api_types.
 
 	NameParts, err := splitWorkerNameToParts(Name, []string{" ", ".", "-", "_", ","})
 	if err != nil {
-		return nil, fmt.Errorf("%s Splitting worker to parts\n%v", errorPrefix, err)
+		return nil, fmt.Errorf("%s Splitting worker to parts\n%w", errorPrefix, err)
 	}
 
 	users, err := azureDevopsAPI.GraphClient.ListUsers()
 	if err != nil {
-		return nil, fmt.Errorf("%s Fetching graph users\n%v", errorPrefix, err)
+		return nil, fmt.Errorf("%s Fetching graph users\n%w", errorPrefix, err)
 	}
 	for _, user := range users {
 		lowerDisplayName := strings.ToLower(*user.DisplayName)
 		match, err := checkWorkerNamePartsMatch(&lowerDisplayName, NameParts)
 		if err != nil {
-			return nil, fmt.Errorf("%s Checking name parts match\n%v", errorPrefix, err)
+			return nil, fmt.Errorf("%s Checking name parts match\n%w", errorPrefix, err)
 		}
 		if match {
 			lg.InfoF("test: %v", user)
@@ -1220,7 +1348,7 @@ func (azureDevopsAPI *AzureDevopsAPI) GetWorker(Name *string) (*human_api_types.
 		}
 	}
 
-	return nil, fmt.Errorf("%s Finding user by name '%s'\n%v", errorPrefix, *Name, err)
+	return nil, fmt.Errorf("%s Finding user by name '%s'\n%w", errorPrefix, *Name, err)
 }
 
 func (azureDevopsAPI *AzureDevopsAPI) GetWorkerSprint(worker *human_api_types.Worker) (*human_api_types.Sprint, error) {
@@ -1228,18 +1356,18 @@ func (azureDevopsAPI *AzureDevopsAPI) GetWorkerSprint(worker *human_api_types.Wo
 	if worker.Id == "" {
 		azureWorker, err := azureDevopsAPI.GetWorkerByName(worker.Name)
 		if err != nil {
-			return nil, fmt.Errorf("%s Error getting worker by name '%s'\n%v", errorBase, worker.Name, err)
+			return nil, fmt.Errorf("%s Error getting worker by name '%s'\n%w", errorBase, worker.Name, err)
 		}
 		worker.Id = azureWorker.Id
 	}
 	teamID, err := azureDevopsAPI.GetWorkerTeamId(worker.Id)
 	if err != nil {
-		return nil, fmt.Errorf("%s Error getting worker team\n%v", errorBase, err)
+		return nil, fmt.Errorf("%s Error getting worker team\n%w", errorBase, err)
 	}
 
 	allSprints, err := azureDevopsAPI.WorkItemTrackingClient.GetTeamSprints(&teamID)
 	if err != nil {
-		return nil, fmt.Errorf("%s Error getting all sprints\n%v", errorBase, err)
+		return nil, fmt.Errorf("%s Error getting all sprints\n%w", errorBase, err)
 	}
 
 	//now := time.Now()
@@ -1397,12 +1525,12 @@ func (azureDevopsAPI *AzureDevopsAPI) UpdateWobject(wobj *human_api_types.Wobjec
 	errorPrefix := "[azure_devops_api:UpdateWobject]"
 	wobjID, err := strconv.Atoi(wobj.Id)
 	if err != nil {
-		return fmt.Errorf("%s Converting string id to int \n%v", errorPrefix, err)
+		return fmt.Errorf("%s Converting string id to int \n%w", errorPrefix, err)
 	}
 
 	currentWobject, err := azureDevopsAPI.GetWobject(wobj.Id)
 	if err != nil {
-		return fmt.Errorf("%s Converting getting current wobject\n%v", errorPrefix, err)
+		return fmt.Errorf("%s Converting getting current wobject\n%w", errorPrefix, err)
 	}
 
 	keyValMap := map[string]string{}
@@ -1429,12 +1557,12 @@ func (azureDevopsAPI *AzureDevopsAPI) UpdateWobject(wobj *human_api_types.Wobjec
 	}
 	_, err = azureDevopsAPI.WorkItemTrackingClient.UpdateWit(&wobjID, &Document)
 	if err != nil {
-		return fmt.Errorf("%s Updating wit\n%v", errorPrefix, err)
+		return fmt.Errorf("%s Updating wit\n%w", errorPrefix, err)
 	}
 
 	err = azureDevopsAPI.WorkItemTrackingClient.AddWitComment(wobjID, wobj.Description)
 	if err != nil {
-		return fmt.Errorf("%s Adding WIT comment\n%v", errorPrefix, err)
+		return fmt.Errorf("%s Adding WIT comment\n%w", errorPrefix, err)
 	}
 
 	return nil
@@ -1541,6 +1669,10 @@ func (azureDevopsAPI *AzureDevopsAPI) GetWorkerTeamId(workerID string) (string,
 		}
 	}
 
+	if len(foundTeams) == 0 {
+		return "", fmt.Errorf("%w: team of worker %s", ErrNotFound, workerID)
+	}
+
 	return foundTeams[0], nil
 }
 
@@ -1550,7 +1682,7 @@ func (azureDevopsAPI *AzureDevopsAPI) UpdateWit(requestDict map[string]string) e
 		log.Printf("received error in Generate Create Wit Request: %v", err)
 		return err
 	}
-	return Patch(req)
+	return Patch(azureDevopsAPI.Transport.HTTPClient(), req)
 
 }
 
@@ -1603,7 +1735,7 @@ func (azureDevopsAPI *AzureDevopsAPI) GenerateUpdateWitRequest(requestDict map[s
 
 	postData, err := json.Marshal(postList)
 	if err != nil {
-		return nil, fmt.Errorf("error marshaling JSON: %v", err)
+		return nil, fmt.Errorf("error marshaling JSON: %w", err)
 	}
 	fmt.Printf("Updating Azure Devops WorkITem  : %v\n", requestDict)
 
@@ -1616,26 +1748,26 @@ func (azureDevopsAPI *AzureDevopsAPI) GetWorkerSprintWobjects(sprint *human_api_
 	errorPrefix := "[azure_devops_api:GetWorkerSprintWobjects]"
 	iteration, err := azureDevopsAPI.WorkItemTrackingClient.GetIterationBySrpint(sprint)
 	if err != nil {
-		return nil, fmt.Errorf("%s getting iteration from sprint\n%v", errorPrefix, err)
+		return nil, fmt.Errorf("%s getting iteration from sprint\n%w", errorPrefix, err)
 	}
 
 	adoWorker, err := azureDevopsAPI.GetWorker(&worker.Name)
 	if err != nil {
-		return nil, fmt.Errorf("%s getting Azure devops worker\n%v", errorPrefix, err)
+		return nil, fmt.Errorf("%s getting Azure devops worker\n%w", errorPrefix, err)
 	}
 
 	//iterationWorkItems, err := azureDevopsAPI.WorkClient.GetIterationWorkItems(&teamId, iteration.Identifier)
 	if iteration.Path == nil {
-		return nil, fmt.Errorf("%s Iteration path is nil\n%v", errorPrefix, err)
+		return nil, fmt.Errorf("%s Iteration path is nil\n%w", errorPrefix, err)
 	}
 	wits, err := azureDevopsAPI.WorkItemTrackingClient.GetWorkerIterationWorkItems(adoWorker.Name, *iteration.Path)
 	if err != nil {
-		return nil, fmt.Errorf("%s Getting worker iteration work items\n%v", errorPrefix, err)
+		return nil, fmt.Errorf("%s Getting worker iteration work items\n%w", errorPrefix, err)
 	}
 
 	wobjects, err := ConvertWitsToWobjects(wits)
 	if err != nil {
-		return nil, fmt.Errorf("%s Converting wits to wobjects\n%v", errorPrefix, err)
+		return nil, fmt.Errorf("%s Converting wits to wobjects\n%w", errorPrefix, err)
 	}
 
 	parentIds := []string{}
@@ -1650,7 +1782,7 @@ func (azureDevopsAPI *AzureDevopsAPI) GetWorkerSprintWobjects(sprint *human_api_
 		if !slices.Contains(allIds, parentId) {
 			parentWobject, err := azureDevopsAPI.GetWobject(parentId)
 			if err != nil {
-				return nil, fmt.Errorf("%s getting wobject's parent\n%v", errorPrefix, err)
+				return nil, fmt.Errorf("%s getting wobject's parent\n%w", errorPrefix, err)
 			}
 			wobjects = append(wobjects, parentWobject)
 		}
@@ -1675,13 +1807,13 @@ func (azureDevopsAPI *AzureDevopsAPI) GetWobject(wobjID string) (*human_api_type
 
 	intID, err := strconv.Atoi(wobjID)
 	if err != nil {
-		return nil, fmt.Errorf("%s converting wobjectId to int\n%v", errorPrefix, err)
+		return nil, fmt.Errorf("%s converting wobjectId to int\n%w", errorPrefix, err)
 	}
 
 	wit := &WorkItem{ID: intID}
 	success, err := azureDevopsAPI.WorkItemTrackingClient.UpdateWitInformation(wit)
 	if err != nil {
-		return nil, fmt.Errorf("%s error getting wit\n%v", errorPrefix, err)
+		return nil, fmt.Errorf("%s error getting wit\n%w", errorPrefix, err)
 	}
 	if !success {
 		return nil, fmt.Errorf("%s failed getting wit \n", errorPrefix)
@@ -1689,7 +1821,7 @@ func (azureDevopsAPI *AzureDevopsAPI) GetWobject(wobjID string) (*human_api_type
 
 	wobject, err := ConvertWitToWobject(wit)
 	if err != nil {
-		return nil, fmt.Errorf("%s converting wit to wobject\n%v", errorPrefix, err)
+		return nil, fmt.Errorf("%s converting wit to wobject\n%w", errorPrefix, err)
 	}
 	return wobject, nil
 }
@@ -1713,77 +1845,68 @@ func (azureDevopsAPI *AzureDevopsAPI) GetTeamSprints(teamId *string) ([]human_ap
 	return nil, fmt.Errorf("was not able to find Iteration by name: %s", azureDevopsAPI.Configuration.SprintName)
 }
 
-func extractFloat64String(workItem WorkItem, FieldKey string) string {
-	var retVal string
+func extractFloat64String(workItem WorkItem, FieldKey string) (string, error) {
 	if workItem.Fields[FieldKey] == nil {
-		return retVal
+		return "", nil
 	}
 
-	value, ok := workItem.Fields[FieldKey]
-	if !ok {
-		check(fmt.Errorf("extractFloat64String: Was not able to Extract %v, %v, %v", FieldKey, value, workItem))
+	retVal, err := extractFloat64Int(workItem, FieldKey)
+	if err != nil {
+		return "", err
 	}
-	retValtmp, err := strconv.Atoi(strconv.FormatFloat(value.(float64), 'f', 0, 64))
-	check(err)
-	retVal = strconv.Itoa(retValtmp)
-	return retVal
+	return strconv.Itoa(retVal), nil
 }
 
-func check(e error) {
-	if e != nil {
-		strErr := fmt.Sprintf("%v", e)
-		data := []byte(strErr)
-		err := os.WriteFile("/tmp/hapi.log", data, 0644) // 0644 are file permissions
-		if err != nil {
-			fmt.Println("Error writing to file:", err)
-			return
-		}
-		panic(e)
-	}
-}
-func extractFloat64Int(workItem WorkItem, FieldKey string) int {
-	var retVal int
+func extractFloat64Int(workItem WorkItem, FieldKey string) (int, error) {
 	if workItem.Fields[FieldKey] == nil {
-		return retVal
+		return 0, nil
 	}
 
-	value, ok := workItem.Fields[FieldKey]
+	value, ok := workItem.Fields[FieldKey].(float64)
 	if !ok {
-		check(fmt.Errorf("extractFloat64Int: Was not able to Extract %v, %v, %v", FieldKey, value, workItem))
+		return 0, fmt.Errorf("%w: extractFloat64Int: field %s of work item %d is not a number: %v", ErrValidation, FieldKey, workItem.ID, workItem.Fields[FieldKey])
 	}
-	retVal, err := strconv.Atoi(strconv.FormatFloat(value.(float64), 'f', 0, 64))
-	check(err)
-	return retVal
+	return int(value), nil
 }
 
-func extractWorkerID(workItem WorkItem) string {
-	var data string
-	if workItem.Fields["System.AssignedTo"] != nil {
-		data = workItem.Fields["System.AssignedTo"].(map[string]interface{})["uniqueName"].(string)
-	} else {
-		data = workItem.Fields["System.CreatedBy"].(map[string]interface{})["uniqueName"].(string)
+func extractWorkerID(workItem WorkItem) (string, error) {
+	identityKey := "System.AssignedTo"
+	if workItem.Fields[identityKey] == nil {
+		identityKey = "System.CreatedBy"
 	}
 
-	return strings.Split(data, "@")[0]
+	identity, ok := workItem.Fields[identityKey].(map[string]interface{})
+	if !ok {
+		return "", fmt.Errorf("%w: extractWorkerID: field %s of work item %d is not an identity", ErrValidation, identityKey, workItem.ID)
+	}
+	data, ok := identity["uniqueName"].(string)
+	if !ok {
+		return "", fmt.Errorf("%w: extractWorkerID: field %s of work item %d has no uniqueName", ErrValidation, identityKey, workItem.ID)
+	}
+
+	return strings.Split(data, "@")[0], nil
 }
-func extractStatus(workItem WorkItem) string {
-	SystemState := workItem.Fields["System.State"].(string)
+func extractStatus(workItem WorkItem) (string, error) {
+	SystemState, ok := workItem.Fields["System.State"].(string)
+	if !ok {
+		return "", fmt.Errorf("%w: extractStatus: work item %d has no System.State", ErrValidation, workItem.ID)
+	}
 	switch SystemState {
 	case "New":
-		return "New"
+		return "New", nil
 	case "Closed":
-		return "Closed"
+		return "Closed", nil
 	case "Resolved":
-		return "Closed"
+		return "Closed", nil
 	case "Removed":
-		return "Closed"
+		return "Closed", nil
 	case "Active":
-		return "Active"
+		return "Active", nil
 	case "Blocked":
-		return "Blocked"
+		return "Blocked", nil
 	default:
 		log.Printf("invalid State: %v, using default\n", SystemState)
-		return "Blocked"
+		return "Blocked", nil
 	}
 }
 
@@ -1805,16 +1928,36 @@ func ConvertWitToWobject(wit *WorkItem) (wobject *human_api_types.Wobject, err e
 	errr := common_utils.ErrorCreator("[azure_devops_api:ConvertWitToWobject]")
 
 	wobject = &human_api_types.Wobject{}
-	wobject.ParentID = extractFloat64String(*wit, "System.Parent")
+	wobject.ParentID, err = extractFloat64String(*wit, "System.Parent")
+	if err != nil {
+		return nil, errr("Extracting parent", err)
+	}
 	wobject.Id = strconv.Itoa(wit.ID)
-	wobject.Title = wit.Fields["System.Title"].(string)
-	wobject.Priority = extractFloat64Int(*wit, "Microsoft.VSTS.Common.Priority")
+	title, ok := wit.Fields["System.Title"].(string)
+	if !ok {
+		return nil, fmt.Errorf("%s %w: work item %d has no System.Title", errorPrefix, ErrValidation, wit.ID)
+	}
+	wobject.Title = title
+	wobject.Priority, err = extractFloat64Int(*wit, "Microsoft.VSTS.Common.Priority")
+	if err != nil {
+		return nil, errr("Extracting priority", err)
+	}
 
-	wobject.WorkerID = extractWorkerID(*wit)
+	wobject.WorkerID, err = extractWorkerID(*wit)
+	if err != nil {
+		return nil, errr("Extracting worker", err)
+	}
 	wobject.ChildrenIDs = &[]string{}
 
-	wobject.Status = extractStatus(*wit)
-	SprintParts := strings.Split(wit.Fields["System.IterationPath"].(string), "\\")
+	wobject.Status, err = extractStatus(*wit)
+	if err != nil {
+		return nil, errr("Extracting status", err)
+	}
+	iterationPath, ok := wit.Fields["System.IterationPath"].(string)
+	if !ok {
+		return nil, fmt.Errorf("%s %w: work item %d has no System.IterationPath", errorPrefix, ErrValidation, wit.ID)
+	}
+	SprintParts := strings.Split(iterationPath, "\\")
 	wobject.Sprint = SprintParts[len(SprintParts)-1]
 
 	wobjType, err := wit.GetWobjectType()
@@ -1823,17 +1966,17 @@ func ConvertWitToWobject(wit *WorkItem) (wobject *human_api_types.Wobject, err e
 	}
 	err = wobject.SetType(strings.Replace(wobjType, " ", "", -1))
 	if err != nil {
-		return nil, fmt.Errorf("%s Setting Wobject type\n%v", errorPrefix, err)
+		return nil, fmt.Errorf("%s Setting Wobject type\n%w", errorPrefix, err)
 	}
 
-	inter, ok := wit.Fields["Microsoft.VSTS.Scheduling.CompletedWork"]
-	if ok {
-		wobject.InvestedTime = int(inter.(float64))
+	wobject.InvestedTime, err = extractFloat64Int(*wit, "Microsoft.VSTS.Scheduling.CompletedWork")
+	if err != nil {
+		return nil, errr("Extracting completed work", err)
 	}
 
-	inter, ok = wit.Fields["Microsoft.VSTS.Scheduling.RemainingWork"]
-	if ok {
-		wobject.LeftTime = int(inter.(float64))
+	wobject.LeftTime, err = extractFloat64Int(*wit, "Microsoft.VSTS.Scheduling.RemainingWork")
+	if err != nil {
+		return nil, errr("Extracting remaining work", err)
 	}
 	return wobject, nil
 }
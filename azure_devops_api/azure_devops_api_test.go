@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strings"
 	"testing"
 
 	config_pol "github.com/AlexeyBeley/go_misc/configuration_policy"
@@ -43,7 +44,26 @@ func TestDownloadAllWits(t *testing.T) {
 
 func TestSubmitSprintStatus(t *testing.T) {
 	t.Run("Valid run", func(t *testing.T) {
-		fmt.Print("todo:\n")
+		server := fixtureServerNew(t, "submit_sprint_status.json")
+		config := fixtureConfiguration(server)
+
+		parent := map[string]string{"Id": "7", "ParentID": "-1", "Priority": "-1", "Title": "Parent", "LeftTime": "-1", "InvestedTime": "-1",
+			"WorkerID": "john.doe", "ChildrenIDs": "CreatePlease:1", "Type": "UserStory"}
+		child := map[string]string{"Id": "CreatePlease:1", "ParentID": "7", "Priority": "2", "Title": "Child", "LeftTime": "3", "InvestedTime": "1",
+			"WorkerID": "john.doe", "ChildrenIDs": "", "Type": "Task"}
+
+		err := SubmitSprintStatus(config, []*map[string]string{&parent, &child})
+		if err != nil {
+			t.Fatalf("SubmitSprintStatus() error = %v", err)
+		}
+
+		if child["Id"] != "42" {
+			t.Errorf("created Id = %s", child["Id"])
+		}
+		parentLink := server.URL + "/org/proj/_apis/wit/workItems/7"
+		if !strings.Contains(string(server.Requests[len(server.Requests)-1].Body), parentLink) {
+			t.Errorf("parent link request = %s", server.Requests[len(server.Requests)-1].Body)
+		}
 	})
 }
 
@@ -3,9 +3,7 @@ package azure_devops_api
 import (
 	"context"
 	"fmt"
-	"log"
 
-	"github.com/microsoft/azure-devops-go-api/azuredevops/v7"
 	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/build"
 )
 
@@ -14,13 +12,14 @@ type BuildClient struct {
 	Configuration *Configuration
 }
 
-func BuildClientNew(Configuration *Configuration, context context.Context, connection *azuredevops.Connection) (*BuildClient, error) {
+func BuildClientNew(Configuration *Configuration, context context.Context, transport *Transport) (*BuildClient, error) {
 
-	buildClient, err := build.NewClient(context, connection)
+	client, err := transport.SDKClientNew(context, build.ResourceAreaId)
 	if err != nil {
-		log.Fatalf("Failed to create Build client: %v", err)
+		lg.InfoF("Failed to create Build client: %v", err)
+		return nil, ConvertSDKError(err)
 	}
-	ret := &BuildClient{Configuration: Configuration, Client: buildClient}
+	ret := &BuildClient{Configuration: Configuration, Client: &build.ClientImpl{Client: *client}}
 
 	return ret, nil
 }
@@ -34,7 +33,7 @@ func (buildClient *BuildClient) GetDefinitions() ([]build.BuildDefinitionReferen
 	// Make the API call to get a page of repositories
 	BuildDefinitionReferences, err := buildClient.Client.GetDefinitions(context.Background(), args)
 	if err != nil {
-		return nil, err
+		return nil, ConvertSDKError(err)
 	}
 
 	// Check if any repositories were returned
@@ -55,7 +54,7 @@ func (buildClient *BuildClient) GetDefinition(DefinitionId *int) (*build.BuildDe
 	// Make the API call to get a page of repositories
 	pipeline, err := buildClient.Client.GetDefinition(context.Background(), args)
 	if err != nil {
-		return nil, err
+		return nil, ConvertSDKError(err)
 	}
 
 	return pipeline, nil
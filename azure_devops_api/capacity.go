@@ -0,0 +1,284 @@
+package azure_devops_api
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	human_api_types "github.com/AlexeyBeley/go_misc/human_api_types/v1"
+	"github.com/google/uuid"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/work"
+)
+
+func convertDateRangesToDaysOff(dateRanges *[]work.DateRange) []human_api_types.DaysOff {
+	ret := []human_api_types.DaysOff{}
+	if dateRanges == nil {
+		return ret
+	}
+	for _, dateRange := range *dateRanges {
+		if dateRange.Start == nil || dateRange.End == nil {
+			continue
+		}
+		ret = append(ret, human_api_types.DaysOff{Start: dateRange.Start.Time, End: dateRange.End.Time})
+	}
+	return ret
+}
+
+func convertDaysOffToDateRanges(daysOff []human_api_types.DaysOff) *[]work.DateRange {
+	ret := []work.DateRange{}
+	for _, daysOffRange := range daysOff {
+		ret = append(ret, work.DateRange{Start: &azuredevops.Time{Time: daysOffRange.Start}, End: &azuredevops.Time{Time: daysOffRange.End}})
+	}
+	return &ret
+}
+
+func convertTeamSettingsIterationToSprint(iteration *work.TeamSettingsIteration) (*human_api_types.Sprint, error) {
+	if iteration.Attributes == nil || iteration.Attributes.StartDate == nil || iteration.Attributes.FinishDate == nil {
+		return nil, fmt.Errorf("iteration '%s' has no dates", *iteration.Name)
+	}
+	return &human_api_types.Sprint{Id: *iteration.Path,
+		Name:      *iteration.Name,
+		DateStart: iteration.Attributes.StartDate.Time,
+		DateEnd:   iteration.Attributes.FinishDate.Time}, nil
+}
+
+// Member capacity per day is the sum of all its activities.
+func ConvertTeamMemberCapacity(memberCapacity *work.TeamMemberCapacityIdentityRef) *human_api_types.WorkerCapacity {
+	ret := &human_api_types.WorkerCapacity{DaysOff: convertDateRangesToDaysOff(memberCapacity.DaysOff)}
+	if memberCapacity.TeamMember != nil {
+		ret.Worker = human_api_types.Worker{Id: derefString(memberCapacity.TeamMember.Id),
+			Name:       derefString(memberCapacity.TeamMember.DisplayName),
+			SystemName: derefString(memberCapacity.TeamMember.UniqueName)}
+	}
+	if memberCapacity.Activities != nil {
+		for _, activity := range *memberCapacity.Activities {
+			if activity.CapacityPerDay != nil {
+				ret.CapacityPerDay += float64(*activity.CapacityPerDay)
+			}
+		}
+	}
+	return ret
+}
+
+func (azureDevopsAPI *AzureDevopsAPI) getTeamName(teamName string) string {
+	if teamName == "" {
+		return azureDevopsAPI.Configuration.TeamName
+	}
+	return teamName
+}
+
+func (azureDevopsAPI *AzureDevopsAPI) GetTeamIterationByDate(teamName string, date time.Time) (*work.TeamSettingsIteration, error) {
+	errorPrefix := "[azure_devops_api:GetTeamIterationByDate]"
+	teamName = azureDevopsAPI.getTeamName(teamName)
+
+	iterations, err := azureDevopsAPI.WorkClient.GetTeamIterations(&teamName)
+	if err != nil {
+		return nil, fmt.Errorf("%s Fetching team '%s' iterations\n%w", errorPrefix, teamName, err)
+	}
+
+	for _, iteration := range iterations {
+		sprint, err := convertTeamSettingsIterationToSprint(&iteration)
+		if err != nil {
+			continue
+		}
+		if !date.Before(sprint.DateStart) && date.Before(sprint.DateEnd.AddDate(0, 0, 1)) {
+			return &iteration, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%s Was not able to find team '%s' iteration at %s", errorPrefix, teamName, date.Format(time.DateOnly))
+}
+
+func (azureDevopsAPI *AzureDevopsAPI) GetTeamIterationByName(teamName string, sprintName string) (*work.TeamSettingsIteration, error) {
+	errorPrefix := "[azure_devops_api:GetTeamIterationByName]"
+	teamName = azureDevopsAPI.getTeamName(teamName)
+
+	iterations, err := azureDevopsAPI.WorkClient.GetTeamIterations(&teamName)
+	if err != nil {
+		return nil, fmt.Errorf("%s Fetching team '%s' iterations\n%w", errorPrefix, teamName, err)
+	}
+
+	for _, iteration := range iterations {
+		if iteration.Name != nil && *iteration.Name == sprintName {
+			return &iteration, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%s Was not able to find team '%s' iteration '%s'", errorPrefix, teamName, sprintName)
+}
+
+func (azureDevopsAPI *AzureDevopsAPI) GetCapacityReport(teamName string) (*human_api_types.CapacityReport, error) {
+	errorPrefix := "[azure_devops_api:GetCapacityReport]"
+	teamName = azureDevopsAPI.getTeamName(teamName)
+	now := time.Now()
+
+	iteration, err := azureDevopsAPI.GetTeamIterationByDate(teamName, now)
+	if err != nil {
+		return nil, fmt.Errorf("%s Fetching current iteration\n%w", errorPrefix, err)
+	}
+
+	sprint, err := convertTeamSettingsIterationToSprint(iteration)
+	if err != nil {
+		return nil, fmt.Errorf("%s Converting iteration to sprint\n%w", errorPrefix, err)
+	}
+
+	capacities, err := azureDevopsAPI.WorkClient.GetIterationCapacities(&teamName, iteration.Id)
+	if err != nil {
+		return nil, fmt.Errorf("%s Fetching capacities\n%w", errorPrefix, err)
+	}
+
+	teamDaysOff, err := azureDevopsAPI.WorkClient.GetTeamDaysOff(&teamName, iteration.Id)
+	if err != nil {
+		return nil, fmt.Errorf("%s Fetching team days off\n%w", errorPrefix, err)
+	}
+
+	report := &human_api_types.CapacityReport{Sprint: *sprint, Date: now,
+		TeamDaysOff: convertDateRangesToDaysOff(teamDaysOff.DaysOff),
+		Workers:     []*human_api_types.WorkerCapacity{}}
+
+	if capacities.TeamMembers != nil {
+		for _, memberCapacity := range *capacities.TeamMembers {
+			workerCapacity := ConvertTeamMemberCapacity(&memberCapacity)
+
+			wits, err := azureDevopsAPI.WorkItemTrackingClient.GetWorkerIterationWorkItems(workerCapacity.Worker.SystemName, *iteration.Path)
+			if err != nil {
+				return nil, fmt.Errorf("%s Fetching worker '%s' work items\n%w", errorPrefix, workerCapacity.Worker.Name, err)
+			}
+
+			wobjects, err := ConvertWitsToWobjects(wits)
+			if err != nil {
+				return nil, fmt.Errorf("%s Converting wits to wobjects\n%w", errorPrefix, err)
+			}
+
+			workerCapacity.Load = human_api_types.SumLeftTime(wobjects)
+			report.Workers = append(report.Workers, workerCapacity)
+		}
+	}
+
+	report.Calculate()
+	return report, nil
+}
+
+// FindTeamMemberId returns the id of the capacity team member by its id, display name or unique name.
+func FindTeamMemberId(capacities *work.TeamCapacity, worker string) (*uuid.UUID, error) {
+	errorPrefix := "[azure_devops_api:FindTeamMemberId]"
+	if capacities != nil && capacities.TeamMembers != nil {
+		for _, memberCapacity := range *capacities.TeamMembers {
+			teamMember := memberCapacity.TeamMember
+			if teamMember == nil || teamMember.Id == nil {
+				continue
+			}
+			for _, name := range []string{*teamMember.Id, derefString(teamMember.DisplayName), derefString(teamMember.UniqueName)} {
+				if strings.EqualFold(name, worker) {
+					teamMemberId, err := uuid.Parse(*teamMember.Id)
+					if err != nil {
+						return nil, fmt.Errorf("%s Parsing team member id '%s'\n%w", errorPrefix, *teamMember.Id, err)
+					}
+					return &teamMemberId, nil
+				}
+			}
+		}
+	}
+	return nil, fmt.Errorf("%s Was not able to find team member '%s'", errorPrefix, worker)
+}
+
+func (azureDevopsAPI *AzureDevopsAPI) getTeamMemberId(teamName string, iterationId *uuid.UUID, worker string) (*uuid.UUID, error) {
+	if teamMemberId, err := uuid.Parse(worker); err == nil {
+		return &teamMemberId, nil
+	}
+	capacities, err := azureDevopsAPI.WorkClient.GetIterationCapacities(&teamName, iterationId)
+	if err != nil {
+		return nil, err
+	}
+	return FindTeamMemberId(capacities, worker)
+}
+
+func (azureDevopsAPI *AzureDevopsAPI) SetWorkerDaysOff(teamName string, sprintName string, worker string, daysOff []human_api_types.DaysOff) error {
+	errorPrefix := "[azure_devops_api:SetWorkerDaysOff]"
+	teamName = azureDevopsAPI.getTeamName(teamName)
+
+	iteration, err := azureDevopsAPI.GetTeamIterationByName(teamName, sprintName)
+	if err != nil {
+		return fmt.Errorf("%s Fetching iteration\n%w", errorPrefix, err)
+	}
+
+	teamMemberId, err := azureDevopsAPI.getTeamMemberId(teamName, iteration.Id, worker)
+	if err != nil {
+		return fmt.Errorf("%s Finding worker '%s'\n%w", errorPrefix, worker, err)
+	}
+
+	_, err = azureDevopsAPI.WorkClient.UpdateMemberCapacity(&teamName, iteration.Id, teamMemberId, &work.CapacityPatch{DaysOff: convertDaysOffToDateRanges(daysOff)})
+	if err != nil {
+		return fmt.Errorf("%s Updating member capacity\n%w", errorPrefix, err)
+	}
+
+	return nil
+}
+
+func (azureDevopsAPI *AzureDevopsAPI) SetWorkerCapacity(teamName string, sprintName string, workerId string, activity string, capacityPerDay float32) error {
+	errorPrefix := "[azure_devops_api:SetWorkerCapacity]"
+	teamName = azureDevopsAPI.getTeamName(teamName)
+
+	iteration, err := azureDevopsAPI.GetTeamIterationByName(teamName, sprintName)
+	if err != nil {
+		return fmt.Errorf("%s Fetching iteration\n%w", errorPrefix, err)
+	}
+
+	teamMemberId, err := uuid.Parse(workerId)
+	if err != nil {
+		return fmt.Errorf("%s Parsing worker id '%s'\n%w", errorPrefix, workerId, err)
+	}
+
+	activities := []work.Activity{{Name: &activity, CapacityPerDay: &capacityPerDay}}
+	_, err = azureDevopsAPI.WorkClient.UpdateMemberCapacity(&teamName, iteration.Id, &teamMemberId, &work.CapacityPatch{Activities: &activities})
+	if err != nil {
+		return fmt.Errorf("%s Updating member capacity\n%w", errorPrefix, err)
+	}
+
+	return nil
+}
+
+func (azureDevopsAPI *AzureDevopsAPI) SetTeamDaysOff(teamName string, sprintName string, daysOff []human_api_types.DaysOff) error {
+	errorPrefix := "[azure_devops_api:SetTeamDaysOff]"
+	teamName = azureDevopsAPI.getTeamName(teamName)
+
+	iteration, err := azureDevopsAPI.GetTeamIterationByName(teamName, sprintName)
+	if err != nil {
+		return fmt.Errorf("%s Fetching iteration\n%w", errorPrefix, err)
+	}
+
+	_, err = azureDevopsAPI.WorkClient.UpdateTeamDaysOff(&teamName, iteration.Id, convertDaysOffToDateRanges(daysOff))
+	if err != nil {
+		return fmt.Errorf("%s Updating team days off\n%w", errorPrefix, err)
+	}
+
+	return nil
+}
+
+// Creates the iteration nodes under Configuration.SprintsParentPath and adds them to the team.
+func (azureDevopsAPI *AzureDevopsAPI) CreateSprints(teamName string, template *human_api_types.SprintTemplate) ([]human_api_types.Sprint, error) {
+	errorPrefix := "[azure_devops_api:CreateSprints]"
+	teamName = azureDevopsAPI.getTeamName(teamName)
+
+	sprints, err := template.GenerateSprints()
+	if err != nil {
+		return nil, fmt.Errorf("%s Generating sprints\n%w", errorPrefix, err)
+	}
+
+	for i, sprint := range sprints {
+		iteration, err := azureDevopsAPI.WorkItemTrackingClient.CreateIteration(azureDevopsAPI.Configuration.SprintsParentPath, &sprint)
+		if err != nil {
+			return nil, fmt.Errorf("%s Creating iteration\n%w", errorPrefix, err)
+		}
+
+		_, err = azureDevopsAPI.WorkClient.AddTeamIteration(&teamName, iteration.Identifier)
+		if err != nil {
+			return nil, fmt.Errorf("%s Adding iteration '%s' to team '%s'\n%w", errorPrefix, sprint.Name, teamName, err)
+		}
+		sprints[i].Id = *iteration.Path
+		lg.InfoF("Created sprint: %s", *iteration.Path)
+	}
+
+	return sprints, nil
+}
@@ -0,0 +1,52 @@
+package azure_devops_api
+
+import (
+	"testing"
+
+	human_api_types "github.com/AlexeyBeley/go_misc/human_api_types/v1"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/work"
+)
+
+func TestConvertTeamMemberCapacity(t *testing.T) {
+	t.Run("Valid run", func(t *testing.T) {
+		var _ human_api_types.CapacityPlanner = &AzureDevopsAPI{}
+
+		development := float32(4)
+		qa := float32(2)
+		memberCapacity := work.TeamMemberCapacityIdentityRef{
+			TeamMember: testIdentity("worker@example.com"),
+			Activities: &[]work.Activity{{CapacityPerDay: &development}, {CapacityPerDay: &qa}},
+			DaysOff:    &[]work.DateRange{{}},
+		}
+
+		workerCapacity := ConvertTeamMemberCapacity(&memberCapacity)
+		if workerCapacity.CapacityPerDay != 6 {
+			t.Errorf("CapacityPerDay = %f, want 6", workerCapacity.CapacityPerDay)
+		}
+		if workerCapacity.Worker.SystemName != "worker@example.com" {
+			t.Errorf("Worker = %+v", workerCapacity.Worker)
+		}
+		if len(workerCapacity.DaysOff) != 0 {
+			t.Errorf("DaysOff = %v", workerCapacity.DaysOff)
+		}
+	})
+}
+
+func TestFindTeamMemberId(t *testing.T) {
+	t.Run("Valid run", func(t *testing.T) {
+		id := "5b9f3c2e-1f7a-4a53-9d3f-6a4c1e2b7d10"
+		teamMember := testIdentity("worker@example.com")
+		teamMember.Id = &id
+		capacities := &work.TeamCapacity{TeamMembers: &[]work.TeamMemberCapacityIdentityRef{{TeamMember: teamMember}}}
+
+		for _, worker := range []string{id, "Worker@Example.com"} {
+			teamMemberId, err := FindTeamMemberId(capacities, worker)
+			if err != nil || teamMemberId.String() != id {
+				t.Errorf("FindTeamMemberId(%s) = %v, %v", worker, teamMemberId, err)
+			}
+		}
+		if _, err := FindTeamMemberId(capacities, "another@example.com"); err == nil {
+			t.Errorf("FindTeamMemberId() expected error")
+		}
+	})
+}
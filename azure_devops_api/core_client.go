@@ -2,9 +2,7 @@ package azure_devops_api
 
 import (
 	"context"
-	"log"
 
-	"github.com/microsoft/azure-devops-go-api/azuredevops/v7"
 	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/core"
 	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/webapi"
 )
@@ -14,14 +12,14 @@ type CoreClient struct {
 	Configuration *Configuration
 }
 
-func CoreClientNew(Configuration *Configuration, context context.Context, connection *azuredevops.Connection) (*CoreClient, error) {
+func CoreClientNew(Configuration *Configuration, context context.Context, transport *Transport) (*CoreClient, error) {
 
-	coreClient, err := core.NewClient(context, connection)
+	client, err := transport.SDKClientNew(context, core.ResourceAreaId)
 	if err != nil {
-		log.Fatalf("Failed to create Git client: %v", err)
-		return nil, err
+		lg.InfoF("Failed to create Core client: %v", err)
+		return nil, ConvertSDKError(err)
 	}
-	ret := &CoreClient{Configuration: Configuration, Client: coreClient}
+	ret := &CoreClient{Configuration: Configuration, Client: &core.ClientImpl{Client: *client}}
 
 	return ret, nil
 }
@@ -33,7 +31,7 @@ func (coreClient *CoreClient) GetTeams() ([]core.WebApiTeam, error) {
 	// Make the API call to get a page of repositories
 	teams, err := coreClient.Client.GetAllTeams(context.Background(), args)
 	if err != nil {
-		return nil, err
+		return nil, ConvertSDKError(err)
 	}
 
 	return *teams, nil
@@ -46,7 +44,7 @@ func (coreClient *CoreClient) GetProjects() ([]core.TeamProjectReference, error)
 	// Make the API call to get a page of repositories
 	GetProjectsResponseValue, err := coreClient.Client.GetProjects(context.Background(), args)
 	if err != nil {
-		return nil, err
+		return nil, ConvertSDKError(err)
 	}
 
 	return GetProjectsResponseValue.Value, nil
@@ -59,7 +57,7 @@ func (coreClient *CoreClient) GetTeamMembers(teamId *string) (*[]webapi.TeamMemb
 		TeamId:    teamId,
 	})
 	if err != nil {
-		return nil, err
+		return nil, ConvertSDKError(err)
 	}
 	return members, err
 
@@ -0,0 +1,116 @@
+package azure_devops_api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7"
+)
+
+var (
+	ErrNotFound     = errors.New("not found")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrRateLimited  = errors.New("rate limited")
+	ErrConflict     = errors.New("conflict")
+	ErrValidation   = errors.New("validation failed")
+)
+
+// Maximal response body length kept in HTTPError.
+const httpErrorBodyLimit = 4096
+
+// HTTPError wraps a non successful Azure Devops response.
+// Kind is one of the Err* sentinels, nil for statuses without a dedicated kind (e.g. 5xx).
+type HTTPError struct {
+	Kind       error
+	StatusCode int
+	Body       string
+	RetryAfter time.Duration
+}
+
+func (httpError *HTTPError) Error() string {
+	kind := "http error"
+	if httpError.Kind != nil {
+		kind = httpError.Kind.Error()
+	}
+	return fmt.Sprintf("azure devops %s: status %d: %s", kind, httpError.StatusCode, httpError.Body)
+}
+
+func (httpError *HTTPError) Unwrap() error {
+	return httpError.Kind
+}
+
+// Rate limits and server side failures can be retried, the rest will fail again.
+func (httpError *HTTPError) Retryable() bool {
+	return httpError.Kind == ErrRateLimited || httpError.StatusCode >= http.StatusInternalServerError
+}
+
+func HTTPErrorNew(statusCode int, body string) *HTTPError {
+	if len(body) > httpErrorBodyLimit {
+		body = body[:httpErrorBodyLimit]
+	}
+	ret := &HTTPError{StatusCode: statusCode, Body: body}
+
+	switch statusCode {
+	case http.StatusNotFound:
+		ret.Kind = ErrNotFound
+	case http.StatusUnauthorized, http.StatusForbidden:
+		ret.Kind = ErrUnauthorized
+	case http.StatusTooManyRequests:
+		ret.Kind = ErrRateLimited
+	// Azure devops answers with 412 when the "test /rev" patch operation fails.
+	case http.StatusConflict, http.StatusPreconditionFailed:
+		ret.Kind = ErrConflict
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		ret.Kind = ErrValidation
+	}
+	return ret
+}
+
+// Returns nil for 2xx responses, HTTPError otherwise. Reads the body on failure.
+func CheckResponse(response *http.Response) error {
+	if response.StatusCode >= 200 && response.StatusCode < 300 {
+		return nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(response.Body, httpErrorBodyLimit))
+	if err != nil {
+		body = []byte(response.Status)
+	}
+
+	ret := HTTPErrorNew(response.StatusCode, string(body))
+	if seconds, err := strconv.Atoi(response.Header.Get("Retry-After")); err == nil {
+		ret.RetryAfter = time.Duration(seconds) * time.Second
+	}
+	return ret
+}
+
+// Converts errors returned by the azure devops sdk clients into HTTPError, other errors are returned as is.
+func ConvertSDKError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var wrappedError azuredevops.WrappedError
+	var wrappedErrorPointer *azuredevops.WrappedError
+	switch {
+	case errors.As(err, &wrappedErrorPointer) && wrappedErrorPointer != nil:
+		wrappedError = *wrappedErrorPointer
+	case errors.As(err, &wrappedError):
+	default:
+		return err
+	}
+
+	if wrappedError.StatusCode == nil {
+		return err
+	}
+
+	message := ""
+	if wrappedError.Message != nil {
+		message = *wrappedError.Message
+	}
+	return HTTPErrorNew(*wrappedError.StatusCode, message)
+}
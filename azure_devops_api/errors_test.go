@@ -0,0 +1,91 @@
+package azure_devops_api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7"
+)
+
+func TestHTTPErrorNew(t *testing.T) {
+	tests := []struct {
+		statusCode int
+		kind       error
+		retryable  bool
+	}{
+		{http.StatusNotFound, ErrNotFound, false},
+		{http.StatusUnauthorized, ErrUnauthorized, false},
+		{http.StatusForbidden, ErrUnauthorized, false},
+		{http.StatusTooManyRequests, ErrRateLimited, true},
+		{http.StatusConflict, ErrConflict, false},
+		{http.StatusPreconditionFailed, ErrConflict, false},
+		{http.StatusBadRequest, ErrValidation, false},
+		{http.StatusServiceUnavailable, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.statusCode), func(t *testing.T) {
+			err := fmt.Errorf("[test] wrapped\n%w", HTTPErrorNew(tt.statusCode, "body"))
+			var httpError *HTTPError
+			if !errors.As(err, &httpError) {
+				t.Fatalf("errors.As() = false")
+			}
+			if httpError.Kind != tt.kind || httpError.Retryable() != tt.retryable {
+				t.Errorf("HTTPErrorNew(%d) = %+v", tt.statusCode, httpError)
+			}
+			if tt.kind != nil && !errors.Is(err, tt.kind) {
+				t.Errorf("errors.Is(%v) = false", tt.kind)
+			}
+		})
+	}
+}
+
+func TestCheckResponse(t *testing.T) {
+	t.Run("Valid run", func(t *testing.T) {
+		response := &http.Response{StatusCode: http.StatusTooManyRequests,
+			Header: http.Header{"Retry-After": []string{"3"}},
+			Body:   io.NopCloser(strings.NewReader("slow down"))}
+		err := CheckResponse(response)
+		var httpError *HTTPError
+		if !errors.As(err, &httpError) || httpError.Body != "slow down" || httpError.RetryAfter != 3*time.Second {
+			t.Errorf("CheckResponse() = %v", err)
+		}
+
+		if CheckResponse(&http.Response{StatusCode: http.StatusCreated}) != nil {
+			t.Errorf("CheckResponse() expected nil for 201")
+		}
+	})
+}
+
+func TestConvertSDKError(t *testing.T) {
+	t.Run("Valid run", func(t *testing.T) {
+		statusCode := http.StatusNotFound
+		message := "TF401232: Work item 1 does not exist"
+		for _, sdkError := range []error{azuredevops.WrappedError{StatusCode: &statusCode, Message: &message},
+			&azuredevops.WrappedError{StatusCode: &statusCode, Message: &message}} {
+			err := ConvertSDKError(sdkError)
+			if !errors.Is(err, ErrNotFound) {
+				t.Errorf("ConvertSDKError(%T) = %v", sdkError, err)
+			}
+		}
+
+		plainError := errors.New("plain")
+		if ConvertSDKError(plainError) != plainError {
+			t.Errorf("ConvertSDKError() changed plain error")
+		}
+	})
+}
+
+func TestConvertWitToWobjectInvalidField(t *testing.T) {
+	t.Run("Invalid priority", func(t *testing.T) {
+		wit := &WorkItem{ID: 1, Fields: map[string]interface{}{"System.Title": "title", "Microsoft.VSTS.Common.Priority": "high"}}
+		_, err := ConvertWitToWobject(wit)
+		if !errors.Is(err, ErrValidation) {
+			t.Errorf("ConvertWitToWobject() = %v, want ErrValidation", err)
+		}
+	})
+}
@@ -4,8 +4,8 @@ import (
 	"context"
 	"fmt"
 
-	"github.com/microsoft/azure-devops-go-api/azuredevops/v7"
 	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/git"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/webapi"
 )
 
 type GitClient struct {
@@ -13,14 +13,14 @@ type GitClient struct {
 	Configuration *Configuration
 }
 
-func GitClientNew(Configuration *Configuration, context context.Context, connection *azuredevops.Connection) (*GitClient, error) {
+func GitClientNew(Configuration *Configuration, context context.Context, transport *Transport) (*GitClient, error) {
 
-	gitClient, err := git.NewClient(context, connection)
+	client, err := transport.SDKClientNew(context, git.ResourceAreaId)
 	if err != nil {
 		lg.InfoF("Failed to create Git client: %v", err)
-		return nil, err
+		return nil, ConvertSDKError(err)
 	}
-	ret := &GitClient{Configuration: Configuration, Client: gitClient}
+	ret := &GitClient{Configuration: Configuration, Client: &git.ClientImpl{Client: *client}}
 
 	return ret, nil
 }
@@ -34,7 +34,7 @@ func (gitClient *GitClient) GetRepositories() ([]git.GitRepository, error) {
 	// Make the API call to get a page of repositories
 	repositories, err := gitClient.Client.GetRepositories(context.Background(), args)
 	if err != nil {
-		return nil, err
+		return nil, ConvertSDKError(err)
 	}
 
 	// Check if any repositories were returned
@@ -44,3 +44,75 @@ func (gitClient *GitClient) GetRepositories() ([]git.GitRepository, error) {
 
 	return *repositories, nil
 }
+
+func (gitClient *GitClient) GetPullRequests(repositoryId *string, searchCriteria *git.GitPullRequestSearchCriteria) ([]git.GitPullRequest, error) {
+	errorPrefix := "[git_client->GetPullRequests]"
+	ret := []git.GitPullRequest{}
+	top := 100
+	skip := 0
+
+	for {
+		args := git.GetPullRequestsArgs{
+			Project:        &gitClient.Configuration.ProjectName,
+			RepositoryId:   repositoryId,
+			SearchCriteria: searchCriteria,
+			Top:            &top,
+			Skip:           &skip,
+		}
+
+		pullRequests, err := gitClient.Client.GetPullRequests(context.Background(), args)
+		if err != nil {
+			return nil, fmt.Errorf("%s Fetching pull requests of repository '%s'\n%w", errorPrefix, *repositoryId, ConvertSDKError(err))
+		}
+
+		if pullRequests == nil || len(*pullRequests) == 0 {
+			break
+		}
+
+		ret = append(ret, *pullRequests...)
+		if len(*pullRequests) < top {
+			break
+		}
+		skip += top
+	}
+
+	return ret, nil
+}
+
+func (gitClient *GitClient) GetPullRequestThreads(repositoryId *string, pullRequestId *int) ([]git.GitPullRequestCommentThread, error) {
+	args := git.GetThreadsArgs{
+		Project:       &gitClient.Configuration.ProjectName,
+		RepositoryId:  repositoryId,
+		PullRequestId: pullRequestId,
+	}
+
+	threads, err := gitClient.Client.GetThreads(context.Background(), args)
+	if err != nil {
+		return nil, fmt.Errorf("[git_client->GetPullRequestThreads] Fetching threads of pull request %d\n%w", *pullRequestId, ConvertSDKError(err))
+	}
+
+	if threads == nil {
+		return []git.GitPullRequestCommentThread{}, nil
+	}
+
+	return *threads, nil
+}
+
+func (gitClient *GitClient) GetPullRequestWorkItemRefs(repositoryId *string, pullRequestId *int) ([]webapi.ResourceRef, error) {
+	args := git.GetPullRequestWorkItemRefsArgs{
+		Project:       &gitClient.Configuration.ProjectName,
+		RepositoryId:  repositoryId,
+		PullRequestId: pullRequestId,
+	}
+
+	refs, err := gitClient.Client.GetPullRequestWorkItemRefs(context.Background(), args)
+	if err != nil {
+		return nil, fmt.Errorf("[git_client->GetPullRequestWorkItemRefs] Fetching work items of pull request %d\n%w", *pullRequestId, ConvertSDKError(err))
+	}
+
+	if refs == nil {
+		return []webapi.ResourceRef{}, nil
+	}
+
+	return *refs, nil
+}
@@ -3,9 +3,7 @@ package azure_devops_api
 import (
 	"context"
 	"fmt"
-	"log"
 
-	"github.com/microsoft/azure-devops-go-api/azuredevops/v7"
 	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/graph"
 )
 
@@ -14,14 +12,14 @@ type GraphClient struct {
 	Configuration *Configuration
 }
 
-func GraphClientNew(Configuration *Configuration, context context.Context, connection *azuredevops.Connection) (*GraphClient, error) {
+func GraphClientNew(Configuration *Configuration, context context.Context, transport *Transport) (*GraphClient, error) {
 
-	graphClient, err := graph.NewClient(context, connection)
+	client, err := transport.SDKClientNew(context, graph.ResourceAreaId)
 	if err != nil {
-		log.Fatalf("Failed to create Git client: %v", err)
-		return nil, err
+		lg.InfoF("Failed to create Graph client: %v", err)
+		return nil, ConvertSDKError(err)
 	}
-	ret := &GraphClient{Configuration: Configuration, Client: graphClient}
+	ret := &GraphClient{Configuration: Configuration, Client: &graph.ClientImpl{Client: *client}}
 
 	return ret, nil
 }
@@ -33,7 +31,7 @@ func (graphClient *GraphClient) ListUsers() ([]graph.GraphUser, error) {
 	// Make the API call to get a page of repositories
 	response, err := graphClient.Client.ListUsers(context.Background(), args)
 	if err != nil {
-		return nil, err
+		return nil, ConvertSDKError(err)
 	}
 
 	// Check if any repositories were returned
@@ -51,7 +49,7 @@ func (graphClient *GraphClient) GetUser(UserDescriptor *string) (*graph.GraphUse
 	// Make the API call to get a page of repositories
 	response, err := graphClient.Client.GetUser(context.Background(), args)
 	if err != nil {
-		return nil, err
+		return nil, ConvertSDKError(err)
 	}
 
 	return response, nil
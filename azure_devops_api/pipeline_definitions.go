@@ -0,0 +1,330 @@
+package azure_devops_api
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/build"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	PipelineExportFormatJSON = "json"
+	PipelineExportFormatYAML = "yaml"
+)
+
+// Keys removed from the top level of an exported definition: they change on each save or build
+// and would make every snapshot look different. Nested keys, like a task input named url, are kept.
+var PipelineVolatileKeys = []string{"_links", "revision", "url", "uri", "createdDate", "authoredBy",
+	"latestBuild", "latestCompletedBuild", "metrics", "drafts", "lastUpdateTime"}
+
+var PipelineDiffSections = []string{"variables", "triggers", "queue", "steps"}
+
+type PipelineDefinitionChange struct {
+	Definition string `json:"Definition"`
+	Section    string `json:"Section"`
+	Key        string `json:"Key"`
+	Change     string `json:"Change"`
+	Old        any    `json:"Old,omitempty"`
+	New        any    `json:"New,omitempty"`
+}
+
+func (change PipelineDefinitionChange) String() string {
+	if change.Section == "" {
+		return fmt.Sprintf("%s: definition %s", change.Definition, change.Change)
+	}
+	return fmt.Sprintf("%s: %s '%s' %s", change.Definition, change.Section, change.Key, change.Change)
+}
+
+func NormalizePipelineDefinition(definition *build.BuildDefinition) (map[string]any, error) {
+	errorPrefix := "[pipeline_definitions->NormalizePipelineDefinition]"
+
+	jsonData, err := json.Marshal(definition)
+	if err != nil {
+		return nil, fmt.Errorf("%s Marshaling definition\n%w", errorPrefix, err)
+	}
+
+	ret := map[string]any{}
+	err = json.Unmarshal(jsonData, &ret)
+	if err != nil {
+		return nil, fmt.Errorf("%s Unmarshaling definition\n%w", errorPrefix, err)
+	}
+
+	for _, key := range PipelineVolatileKeys {
+		delete(ret, key)
+	}
+	return ret, nil
+}
+
+// Unique name used as the snapshot file name and as the diff key.
+func PipelineDefinitionName(definition map[string]any) string {
+	name, _ := definition["name"].(string)
+	path, _ := definition["path"].(string)
+	path = strings.Trim(strings.ReplaceAll(path, "\\", "/"), "/")
+	if path == "" {
+		return name
+	}
+	return path + "/" + name
+}
+
+// The definition ID keeps the file names unique, different names may sanitize to the same one.
+func pipelineDefinitionFileName(definition map[string]any, format string) string {
+	fileName := strings.Map(func(r rune) rune {
+		if strings.ContainsRune("/\\:*?\"<>| ", r) {
+			return '_'
+		}
+		return r
+	}, PipelineDefinitionName(definition))
+	if id, ok := definition["id"].(float64); ok {
+		fileName = fmt.Sprintf("%s_%d", fileName, int(id))
+	}
+	return fileName + "." + format
+}
+
+// The file names written by pipelineDefinitionFileName, "<name>_<id>.<format>".
+var pipelineDefinitionFileNameRegex = regexp.MustCompile(`_\d+\.(json|yaml|yml)$`)
+
+// Only the files following the export naming are owned by the export, other files of the directory are kept.
+func isPipelineDefinitionFile(fileName string) bool {
+	return pipelineDefinitionFileNameRegex.MatchString(fileName)
+}
+
+func WritePipelineDefinition(dstDir string, format string, definition map[string]any) (string, error) {
+	errorPrefix := "[pipeline_definitions->WritePipelineDefinition]"
+
+	var data []byte
+	var err error
+	switch format {
+	case PipelineExportFormatJSON:
+		data, err = json.MarshalIndent(definition, "", "  ")
+	case PipelineExportFormatYAML:
+		data, err = yaml.Marshal(definition)
+	default:
+		return "", fmt.Errorf("%s Unknown format '%s'", errorPrefix, format)
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s Marshaling definition\n%w", errorPrefix, err)
+	}
+
+	filePath := filepath.Join(dstDir, pipelineDefinitionFileName(definition, format))
+	err = os.WriteFile(filePath, data, 0644)
+	if err != nil {
+		return "", fmt.Errorf("%s Writing file '%s'\n%w", errorPrefix, filePath, err)
+	}
+
+	return filePath, nil
+}
+
+// Removes the "<name>_<id>.<format>" definition files of the directory that were not written by the export,
+// pipelines deleted or renamed since the previous export. Other files are kept. Returns the removed files.
+func RemoveStalePipelineDefinitions(dstDir string, writtenFilePaths []string) ([]string, error) {
+	errorPrefix := "[pipeline_definitions->RemoveStalePipelineDefinitions]"
+
+	entries, err := os.ReadDir(dstDir)
+	if err != nil {
+		return nil, fmt.Errorf("%s Reading directory '%s'\n%w", errorPrefix, dstDir, err)
+	}
+
+	ret := []string{}
+	for _, entry := range entries {
+		filePath := filepath.Join(dstDir, entry.Name())
+		if entry.IsDir() || !isPipelineDefinitionFile(entry.Name()) || slices.Contains(writtenFilePaths, filePath) {
+			continue
+		}
+		err = os.Remove(filePath)
+		if err != nil {
+			return nil, fmt.Errorf("%s Removing file '%s'\n%w", errorPrefix, filePath, err)
+		}
+		ret = append(ret, filePath)
+	}
+
+	return ret, nil
+}
+
+func LoadPipelineSnapshot(srcDir string) (map[string]map[string]any, error) {
+	errorPrefix := "[pipeline_definitions->LoadPipelineSnapshot]"
+
+	entries, err := os.ReadDir(srcDir)
+	if err != nil {
+		return nil, fmt.Errorf("%s Reading directory '%s'\n%w", errorPrefix, srcDir, err)
+	}
+
+	ret := map[string]map[string]any{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		filePath := filepath.Join(srcDir, entry.Name())
+		data, err := os.ReadFile(filePath)
+		if err != nil {
+			return nil, fmt.Errorf("%s Reading file '%s'\n%w", errorPrefix, filePath, err)
+		}
+
+		definition := map[string]any{}
+		switch filepath.Ext(entry.Name()) {
+		case "." + PipelineExportFormatJSON:
+			err = json.Unmarshal(data, &definition)
+		case "." + PipelineExportFormatYAML, ".yml":
+			err = yaml.Unmarshal(data, &definition)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s Parsing file '%s'\n%w", errorPrefix, filePath, err)
+		}
+
+		// Round trip through json, so yaml and json snapshots hold the same value types as live definitions.
+		definition, err = normalizeJSONTypes(definition)
+		if err != nil {
+			return nil, fmt.Errorf("%s Normalizing file '%s'\n%w", errorPrefix, filePath, err)
+		}
+		ret[PipelineDefinitionName(definition)] = definition
+	}
+
+	return ret, nil
+}
+
+func normalizeJSONTypes(src map[string]any) (map[string]any, error) {
+	jsonData, err := json.Marshal(src)
+	if err != nil {
+		return nil, err
+	}
+	ret := map[string]any{}
+	err = json.Unmarshal(jsonData, &ret)
+	return ret, err
+}
+
+func DiffPipelineDefinitions(snapshot, live map[string]map[string]any) []PipelineDefinitionChange {
+	ret := []PipelineDefinitionChange{}
+
+	names := []string{}
+	for name := range snapshot {
+		names = append(names, name)
+	}
+	for name := range live {
+		if _, ok := snapshot[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		oldDefinition, oldExists := snapshot[name]
+		newDefinition, newExists := live[name]
+		if !oldExists {
+			ret = append(ret, PipelineDefinitionChange{Definition: name, Change: "added"})
+			continue
+		}
+		if !newExists {
+			ret = append(ret, PipelineDefinitionChange{Definition: name, Change: "removed"})
+			continue
+		}
+
+		for _, section := range PipelineDiffSections {
+			oldItems := extractPipelineSection(oldDefinition, section)
+			newItems := extractPipelineSection(newDefinition, section)
+			ret = append(ret, diffPipelineItems(name, section, oldItems, newItems)...)
+		}
+	}
+
+	return ret
+}
+
+func diffPipelineItems(definitionName, section string, oldItems, newItems map[string]any) []PipelineDefinitionChange {
+	ret := []PipelineDefinitionChange{}
+
+	keys := []string{}
+	for key := range oldItems {
+		keys = append(keys, key)
+	}
+	for key := range newItems {
+		if _, ok := oldItems[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		oldItem, oldExists := oldItems[key]
+		newItem, newExists := newItems[key]
+		change := PipelineDefinitionChange{Definition: definitionName, Section: section, Key: key, Old: oldItem, New: newItem}
+		switch {
+		case !oldExists:
+			change.Change = "added"
+		case !newExists:
+			change.Change = "removed"
+		case !reflect.DeepEqual(oldItem, newItem):
+			change.Change = "changed"
+		default:
+			continue
+		}
+		ret = append(ret, change)
+	}
+	return ret
+}
+
+func extractPipelineSection(definition map[string]any, section string) map[string]any {
+	ret := map[string]any{}
+
+	switch section {
+	case "variables":
+		variables, _ := definition["variables"].(map[string]any)
+		for key, value := range variables {
+			ret[key] = value
+		}
+		groups, _ := definition["variableGroups"].([]any)
+		for _, group := range groups {
+			groupMap, _ := group.(map[string]any)
+			groupName, _ := groupMap["name"].(string)
+			ret["group:"+groupName] = group
+		}
+	case "triggers":
+		triggers, _ := definition["triggers"].([]any)
+		addUniqueItems(ret, triggers, "triggerType")
+	case "queue":
+		if queue, ok := definition["queue"]; ok {
+			ret["queue"] = queue
+		}
+	case "steps":
+		process, _ := definition["process"].(map[string]any)
+		if yamlFilename, ok := process["yamlFilename"]; ok {
+			ret["yamlFilename"] = yamlFilename
+		}
+		phases, _ := process["phases"].([]any)
+		for _, phase := range phases {
+			phaseMap, _ := phase.(map[string]any)
+			phaseName, _ := phaseMap["name"].(string)
+			steps, _ := phaseMap["steps"].([]any)
+			phaseSteps := map[string]any{}
+			addUniqueItems(phaseSteps, steps, "displayName")
+			for key, step := range phaseSteps {
+				ret[phaseName+"/"+key] = step
+			}
+		}
+	}
+
+	return ret
+}
+
+// Keys list items by the given field, adding "#N" to repeated values.
+func addUniqueItems(dst map[string]any, items []any, field string) {
+	for _, item := range items {
+		itemMap, _ := item.(map[string]any)
+		key := fmt.Sprintf("%v", itemMap[field])
+		uniqueKey := key
+		for i := 2; ; i++ {
+			if _, ok := dst[uniqueKey]; !ok {
+				break
+			}
+			uniqueKey = fmt.Sprintf("%s#%d", key, i)
+		}
+		dst[uniqueKey] = item
+	}
+}
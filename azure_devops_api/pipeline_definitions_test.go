@@ -0,0 +1,139 @@
+package azure_devops_api
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/build"
+)
+
+func testBuildDefinition(variableValue string, revision int) *build.BuildDefinition {
+	id := 1
+	name := "deploy"
+	path := "\\infra"
+	queueName := "Azure Pipelines"
+	url := "https://dev.azure.com/org/project/_apis/build/Definitions/1"
+	return &build.BuildDefinition{Id: &id,
+		Name:      &name,
+		Path:      &path,
+		Revision:  &revision,
+		Url:       &url,
+		Queue:     &build.AgentPoolQueue{Name: &queueName, Url: &url},
+		Variables: &map[string]build.BuildDefinitionVariable{"env": {Value: &variableValue}},
+		Triggers:  &[]interface{}{map[string]any{"triggerType": "continuousIntegration"}},
+		Process: map[string]any{"phases": []any{map[string]any{"name": "Job 1",
+			"steps": []any{map[string]any{"displayName": "Build", "inputs": map[string]any{"script": "make " + variableValue}}}}}},
+	}
+}
+
+func TestNormalizePipelineDefinition(t *testing.T) {
+	t.Run("Valid run", func(t *testing.T) {
+		normalized, err := NormalizePipelineDefinition(testBuildDefinition("prod", 7))
+		if err != nil {
+			t.Fatalf("NormalizePipelineDefinition() error = %v", err)
+		}
+		if _, ok := normalized["revision"]; ok {
+			t.Errorf("revision was not removed")
+		}
+		if _, ok := normalized["url"]; ok {
+			t.Errorf("url was not removed")
+		}
+		if _, ok := normalized["queue"].(map[string]any)["url"]; !ok {
+			t.Errorf("nested url was removed")
+		}
+		if PipelineDefinitionName(normalized) != "infra/deploy" {
+			t.Errorf("PipelineDefinitionName() = %s", PipelineDefinitionName(normalized))
+		}
+	})
+}
+
+func TestDiffPipelineDefinitions(t *testing.T) {
+	for _, format := range []string{PipelineExportFormatJSON, PipelineExportFormatYAML} {
+		t.Run(format, func(t *testing.T) {
+			dstDir := t.TempDir()
+			oldDefinition, err := NormalizePipelineDefinition(testBuildDefinition("prod", 1))
+			if err != nil {
+				t.Fatalf("NormalizePipelineDefinition() error = %v", err)
+			}
+			_, err = WritePipelineDefinition(dstDir, format, oldDefinition)
+			if err != nil {
+				t.Fatalf("WritePipelineDefinition() error = %v", err)
+			}
+
+			snapshot, err := LoadPipelineSnapshot(dstDir)
+			if err != nil {
+				t.Fatalf("LoadPipelineSnapshot() error = %v", err)
+			}
+
+			sameDefinition, _ := NormalizePipelineDefinition(testBuildDefinition("prod", 2))
+			changes := DiffPipelineDefinitions(snapshot, map[string]map[string]any{"infra/deploy": sameDefinition})
+			if len(changes) != 0 {
+				t.Errorf("DiffPipelineDefinitions() = %v, want no changes", changes)
+			}
+
+			newDefinition, _ := NormalizePipelineDefinition(testBuildDefinition("dev", 3))
+			changes = DiffPipelineDefinitions(snapshot, map[string]map[string]any{"infra/deploy": newDefinition})
+			if len(changes) != 2 || changes[0].Section != "variables" || changes[1].Section != "steps" {
+				t.Errorf("DiffPipelineDefinitions() = %v", changes)
+			}
+
+			changes = DiffPipelineDefinitions(snapshot, map[string]map[string]any{})
+			if len(changes) != 1 || changes[0].Change != "removed" {
+				t.Errorf("DiffPipelineDefinitions() = %v", changes)
+			}
+		})
+	}
+}
+
+func TestRemoveStalePipelineDefinitions(t *testing.T) {
+	t.Run("Valid run", func(t *testing.T) {
+		dstDir := t.TempDir()
+		definition, err := NormalizePipelineDefinition(testBuildDefinition("prod", 1))
+		if err != nil {
+			t.Fatalf("NormalizePipelineDefinition() error = %v", err)
+		}
+		// Sanitizes to the same name as infra/deploy.
+		collidingDefinition, _ := NormalizePipelineDefinition(testBuildDefinition("prod", 1))
+		collidingDefinition["id"], collidingDefinition["name"], collidingDefinition["path"] = float64(2), "infra_deploy", "\\"
+
+		filePaths := []string{}
+		for _, definition := range []map[string]any{definition, collidingDefinition} {
+			filePath, err := WritePipelineDefinition(dstDir, PipelineExportFormatJSON, definition)
+			if err != nil {
+				t.Fatalf("WritePipelineDefinition() error = %v", err)
+			}
+			filePaths = append(filePaths, filePath)
+		}
+		if filePaths[0] == filePaths[1] {
+			t.Fatalf("WritePipelineDefinition() wrote both definitions to %s", filePaths[0])
+		}
+
+		staleFilePath := filepath.Join(dstDir, "infra_renamed_3.yaml")
+		// Not written by the export, must survive.
+		foreignFilePath := filepath.Join(dstDir, "pipelines_settings.json")
+		for _, filePath := range []string{staleFilePath, foreignFilePath, filepath.Join(dstDir, "README.md")} {
+			if err := os.WriteFile(filePath, []byte("name: renamed\n"), 0644); err != nil {
+				t.Fatalf("%v", err)
+			}
+		}
+
+		removed, err := RemoveStalePipelineDefinitions(dstDir, filePaths)
+		if err != nil {
+			t.Fatalf("RemoveStalePipelineDefinitions() error = %v", err)
+		}
+		if len(removed) != 1 || removed[0] != staleFilePath {
+			t.Errorf("RemoveStalePipelineDefinitions() = %v", removed)
+		}
+		if _, err := os.Stat(foreignFilePath); err != nil {
+			t.Errorf("RemoveStalePipelineDefinitions() removed a file not written by the export: %v", err)
+		}
+		if err := os.Remove(foreignFilePath); err != nil {
+			t.Fatalf("%v", err)
+		}
+		snapshot, err := LoadPipelineSnapshot(dstDir)
+		if err != nil || len(snapshot) != 2 {
+			t.Errorf("LoadPipelineSnapshot() = %v, %v", snapshot, err)
+		}
+	})
+}
@@ -0,0 +1,382 @@
+package azure_devops_api
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	human_api_types "github.com/AlexeyBeley/go_misc/human_api_types/v1"
+	"github.com/google/uuid"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/git"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/webapi"
+)
+
+// Azure devops reviewer vote values.
+const (
+	VoteApproved                = 10
+	VoteApprovedWithSuggestions = 5
+	VoteNoVote                  = 0
+	VoteWaitingForAuthor        = -5
+	VoteRejected                = -10
+)
+
+type PullRequestFilter struct {
+	Status     string    `json:"Status"`
+	AuthorID   string    `json:"AuthorID"`
+	ReviewerID string    `json:"ReviewerID"`
+	From       time.Time `json:"From"`
+	To         time.Time `json:"To"`
+}
+
+type PullRequestReviewer struct {
+	ID         string `json:"ID"`
+	Name       string `json:"Name"`
+	UniqueName string `json:"UniqueName"`
+	Vote       int    `json:"Vote"`
+	IsRequired bool   `json:"IsRequired"`
+}
+
+type PullRequestInsight struct {
+	Repository        string                `json:"Repository"`
+	ID                int                   `json:"ID"`
+	Title             string                `json:"Title"`
+	Status            string                `json:"Status"`
+	AuthorName        string                `json:"AuthorName"`
+	AuthorUniqueName  string                `json:"AuthorUniqueName"`
+	CreationDate      time.Time             `json:"CreationDate"`
+	ClosedDate        time.Time             `json:"ClosedDate"`
+	Reviewers         []PullRequestReviewer `json:"Reviewers"`
+	ThreadsCount      int                   `json:"ThreadsCount"`
+	CommentsCount     int                   `json:"CommentsCount"`
+	CommentsByWorker  map[string]int        `json:"CommentsByWorker"`
+	WorkItemIDs       []string              `json:"WorkItemIDs"`
+	FirstReviewDate   time.Time             `json:"FirstReviewDate"`
+	TimeToFirstReview time.Duration         `json:"TimeToFirstReview"`
+	TimeToMerge       time.Duration         `json:"TimeToMerge"`
+}
+
+type PullRequestRepositoryStats struct {
+	Repository               string        `json:"Repository"`
+	Total                    int           `json:"Total"`
+	Active                   int           `json:"Active"`
+	Completed                int           `json:"Completed"`
+	Abandoned                int           `json:"Abandoned"`
+	Reviewed                 int           `json:"Reviewed"`
+	Merged                   int           `json:"Merged"`
+	AverageTimeToFirstReview time.Duration `json:"AverageTimeToFirstReview"`
+	AverageTimeToMerge       time.Duration `json:"AverageTimeToMerge"`
+}
+
+type PullRequestWorkerStats struct {
+	Worker             string        `json:"Worker"`
+	Authored           int           `json:"Authored"`
+	Merged             int           `json:"Merged"`
+	ReviewsRequested   int           `json:"ReviewsRequested"`
+	ReviewsVoted       int           `json:"ReviewsVoted"`
+	ReviewsPending     int           `json:"ReviewsPending"`
+	Approved           int           `json:"Approved"`
+	Rejected           int           `json:"Rejected"`
+	CommentsWritten    int           `json:"CommentsWritten"`
+	AverageTimeToMerge time.Duration `json:"AverageTimeToMerge"`
+}
+
+type PullRequestReport struct {
+	PerRepository map[string]*PullRequestRepositoryStats `json:"PerRepository"`
+	PerWorker     map[string]*PullRequestWorkerStats     `json:"PerWorker"`
+}
+
+func (filter *PullRequestFilter) GenerateSearchCriteria() (*git.GitPullRequestSearchCriteria, error) {
+	errorPrefix := "[pull_request_report->GenerateSearchCriteria]"
+	criteria := &git.GitPullRequestSearchCriteria{}
+
+	status := git.PullRequestStatusValues.All
+	if filter.Status != "" {
+		status = git.PullRequestStatus(filter.Status)
+	}
+	criteria.Status = &status
+
+	if filter.AuthorID != "" {
+		creatorId, err := uuid.Parse(filter.AuthorID)
+		if err != nil {
+			return nil, fmt.Errorf("%s Parsing author id '%s'\n%w", errorPrefix, filter.AuthorID, err)
+		}
+		criteria.CreatorId = &creatorId
+	}
+
+	if filter.ReviewerID != "" {
+		reviewerId, err := uuid.Parse(filter.ReviewerID)
+		if err != nil {
+			return nil, fmt.Errorf("%s Parsing reviewer id '%s'\n%w", errorPrefix, filter.ReviewerID, err)
+		}
+		criteria.ReviewerId = &reviewerId
+	}
+
+	return criteria, nil
+}
+
+// Date range is not supported by the search criteria, so it is checked locally.
+func (filter *PullRequestFilter) MatchDates(pullRequest *git.GitPullRequest) bool {
+	if pullRequest.CreationDate == nil {
+		return filter.From.IsZero() && filter.To.IsZero()
+	}
+	creationDate := pullRequest.CreationDate.Time
+	if !filter.From.IsZero() && creationDate.Before(filter.From) {
+		return false
+	}
+	if !filter.To.IsZero() && creationDate.After(filter.To) {
+		return false
+	}
+	return true
+}
+
+func PullRequestInsightNew(repository string, pullRequest *git.GitPullRequest, threads []git.GitPullRequestCommentThread, workItemRefs []webapi.ResourceRef) *PullRequestInsight {
+	insight := &PullRequestInsight{Repository: repository,
+		Reviewers:        []PullRequestReviewer{},
+		CommentsByWorker: map[string]int{},
+		WorkItemIDs:      []string{},
+	}
+
+	if pullRequest.PullRequestId != nil {
+		insight.ID = *pullRequest.PullRequestId
+	}
+	if pullRequest.Title != nil {
+		insight.Title = *pullRequest.Title
+	}
+	if pullRequest.Status != nil {
+		insight.Status = string(*pullRequest.Status)
+	}
+	if pullRequest.CreatedBy != nil {
+		insight.AuthorName = derefString(pullRequest.CreatedBy.DisplayName)
+		insight.AuthorUniqueName = strings.ToLower(derefString(pullRequest.CreatedBy.UniqueName))
+	}
+	if pullRequest.CreationDate != nil {
+		insight.CreationDate = pullRequest.CreationDate.Time
+	}
+	if pullRequest.ClosedDate != nil {
+		insight.ClosedDate = pullRequest.ClosedDate.Time
+	}
+
+	if pullRequest.Reviewers != nil {
+		for _, reviewer := range *pullRequest.Reviewers {
+			newReviewer := PullRequestReviewer{ID: derefString(reviewer.Id),
+				Name:       derefString(reviewer.DisplayName),
+				UniqueName: strings.ToLower(derefString(reviewer.UniqueName)),
+			}
+			if reviewer.Vote != nil {
+				newReviewer.Vote = *reviewer.Vote
+			}
+			if reviewer.IsRequired != nil {
+				newReviewer.IsRequired = *reviewer.IsRequired
+			}
+			insight.Reviewers = append(insight.Reviewers, newReviewer)
+		}
+	}
+
+	for _, thread := range threads {
+		if thread.IsDeleted != nil && *thread.IsDeleted {
+			continue
+		}
+		if thread.Comments == nil {
+			continue
+		}
+		threadCounted := false
+		for _, comment := range *thread.Comments {
+			if !isReviewComment(&comment) {
+				continue
+			}
+			if !threadCounted {
+				insight.ThreadsCount++
+				threadCounted = true
+			}
+			insight.CommentsCount++
+
+			commenter := strings.ToLower(derefString(comment.Author.UniqueName))
+			insight.CommentsByWorker[commenter]++
+
+			if commenter == insight.AuthorUniqueName || comment.PublishedDate == nil {
+				continue
+			}
+			if insight.FirstReviewDate.IsZero() || comment.PublishedDate.Time.Before(insight.FirstReviewDate) {
+				insight.FirstReviewDate = comment.PublishedDate.Time
+			}
+		}
+	}
+
+	for _, workItemRef := range workItemRefs {
+		if workItemRef.Id != nil {
+			insight.WorkItemIDs = append(insight.WorkItemIDs, *workItemRef.Id)
+		}
+	}
+
+	if !insight.FirstReviewDate.IsZero() && !insight.CreationDate.IsZero() {
+		insight.TimeToFirstReview = insight.FirstReviewDate.Sub(insight.CreationDate)
+	}
+
+	if insight.Status == string(git.PullRequestStatusValues.Completed) && !insight.ClosedDate.IsZero() && !insight.CreationDate.IsZero() {
+		insight.TimeToMerge = insight.ClosedDate.Sub(insight.CreationDate)
+	}
+
+	return insight
+}
+
+func isReviewComment(comment *git.Comment) bool {
+	if comment.IsDeleted != nil && *comment.IsDeleted {
+		return false
+	}
+	if comment.CommentType != nil && *comment.CommentType == git.CommentTypeValues.System {
+		return false
+	}
+	return comment.Author != nil
+}
+
+func derefString(src *string) string {
+	if src == nil {
+		return ""
+	}
+	return *src
+}
+
+func GeneratePullRequestReport(insights []*PullRequestInsight) *PullRequestReport {
+	report := &PullRequestReport{PerRepository: map[string]*PullRequestRepositoryStats{},
+		PerWorker: map[string]*PullRequestWorkerStats{}}
+
+	repositoryFirstReviewSums := map[string]time.Duration{}
+	repositoryMergeSums := map[string]time.Duration{}
+	workerMergeSums := map[string]time.Duration{}
+
+	getWorkerStats := func(worker string) *PullRequestWorkerStats {
+		stats, ok := report.PerWorker[worker]
+		if !ok {
+			stats = &PullRequestWorkerStats{Worker: worker}
+			report.PerWorker[worker] = stats
+		}
+		return stats
+	}
+
+	for _, insight := range insights {
+		repositoryStats, ok := report.PerRepository[insight.Repository]
+		if !ok {
+			repositoryStats = &PullRequestRepositoryStats{Repository: insight.Repository}
+			report.PerRepository[insight.Repository] = repositoryStats
+		}
+		repositoryStats.Total++
+		switch insight.Status {
+		case string(git.PullRequestStatusValues.Active):
+			repositoryStats.Active++
+		case string(git.PullRequestStatusValues.Completed):
+			repositoryStats.Completed++
+		case string(git.PullRequestStatusValues.Abandoned):
+			repositoryStats.Abandoned++
+		}
+		if !insight.FirstReviewDate.IsZero() {
+			repositoryStats.Reviewed++
+			repositoryFirstReviewSums[insight.Repository] += insight.TimeToFirstReview
+		}
+		if insight.TimeToMerge > 0 {
+			repositoryStats.Merged++
+			repositoryMergeSums[insight.Repository] += insight.TimeToMerge
+		}
+
+		if insight.AuthorUniqueName != "" {
+			authorStats := getWorkerStats(insight.AuthorUniqueName)
+			authorStats.Authored++
+			if insight.TimeToMerge > 0 {
+				authorStats.Merged++
+				workerMergeSums[insight.AuthorUniqueName] += insight.TimeToMerge
+			}
+		}
+
+		for _, reviewer := range insight.Reviewers {
+			if reviewer.UniqueName == "" || reviewer.UniqueName == insight.AuthorUniqueName {
+				continue
+			}
+			reviewerStats := getWorkerStats(reviewer.UniqueName)
+			reviewerStats.ReviewsRequested++
+			switch {
+			case reviewer.Vote >= VoteApprovedWithSuggestions:
+				reviewerStats.ReviewsVoted++
+				reviewerStats.Approved++
+			case reviewer.Vote <= VoteWaitingForAuthor:
+				reviewerStats.ReviewsVoted++
+				reviewerStats.Rejected++
+			case insight.Status == string(git.PullRequestStatusValues.Active):
+				reviewerStats.ReviewsPending++
+			}
+		}
+
+		for worker, count := range insight.CommentsByWorker {
+			if worker == "" || worker == insight.AuthorUniqueName {
+				continue
+			}
+			getWorkerStats(worker).CommentsWritten += count
+		}
+	}
+
+	for repository, stats := range report.PerRepository {
+		if stats.Reviewed > 0 {
+			stats.AverageTimeToFirstReview = repositoryFirstReviewSums[repository] / time.Duration(stats.Reviewed)
+		}
+		if stats.Merged > 0 {
+			stats.AverageTimeToMerge = repositoryMergeSums[repository] / time.Duration(stats.Merged)
+		}
+	}
+
+	for worker, stats := range report.PerWorker {
+		if stats.Merged > 0 {
+			stats.AverageTimeToMerge = workerMergeSums[worker] / time.Duration(stats.Merged)
+		}
+	}
+
+	return report
+}
+
+// Code review load of a single worker, used in the daily and sprint reports.
+// Looked up by the worker Id or SystemName, an empty stats is returned for a worker without pull requests.
+func (report *PullRequestReport) GetWorkerStats(worker *human_api_types.Worker) *PullRequestWorkerStats {
+	for _, key := range []string{worker.Id, worker.SystemName} {
+		stats, ok := report.PerWorker[strings.ToLower(key)]
+		if ok {
+			return stats
+		}
+	}
+	return &PullRequestWorkerStats{Worker: worker.Id}
+}
+
+func (report *PullRequestReport) SortedRepositories() []string {
+	ret := []string{}
+	for repository := range report.PerRepository {
+		ret = append(ret, repository)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func (report *PullRequestReport) SortedWorkers() []string {
+	ret := []string{}
+	for worker := range report.PerWorker {
+		ret = append(ret, worker)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func (report *PullRequestReport) String() string {
+	lines := []string{"Repositories:"}
+	for _, repository := range report.SortedRepositories() {
+		stats := report.PerRepository[repository]
+		lines = append(lines, fmt.Sprintf("%s: total %d, active %d, completed %d, abandoned %d, avg first review %s, avg merge %s",
+			repository, stats.Total, stats.Active, stats.Completed, stats.Abandoned,
+			stats.AverageTimeToFirstReview.Round(time.Minute), stats.AverageTimeToMerge.Round(time.Minute)))
+	}
+	lines = append(lines, "Workers:")
+	for _, worker := range report.SortedWorkers() {
+		lines = append(lines, report.PerWorker[worker].String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (stats *PullRequestWorkerStats) String() string {
+	return fmt.Sprintf("%s: authored %d, merged %d, reviews requested %d, voted %d, pending %d, comments %d",
+		stats.Worker, stats.Authored, stats.Merged, stats.ReviewsRequested, stats.ReviewsVoted, stats.ReviewsPending, stats.CommentsWritten)
+}
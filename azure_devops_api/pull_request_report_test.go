@@ -0,0 +1,119 @@
+package azure_devops_api
+
+import (
+	"testing"
+	"time"
+
+	human_api_types "github.com/AlexeyBeley/go_misc/human_api_types/v1"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/git"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/webapi"
+)
+
+var testPullRequestCreation = time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+
+func testIdentity(uniqueName string) *webapi.IdentityRef {
+	return &webapi.IdentityRef{DisplayName: &uniqueName, UniqueName: &uniqueName}
+}
+
+func testComment(author string, publishedAfter time.Duration, commentType git.CommentType) git.Comment {
+	return git.Comment{Author: testIdentity(author),
+		CommentType:   &commentType,
+		PublishedDate: &azuredevops.Time{Time: testPullRequestCreation.Add(publishedAfter)}}
+}
+
+func testPullRequest(id int, status git.PullRequestStatus, author string, closedAfter time.Duration, reviewers map[string]int) *git.GitPullRequest {
+	title := "test pull request"
+	pullRequestReviewers := []git.IdentityRefWithVote{}
+	for name, vote := range reviewers {
+		pullRequestReviewers = append(pullRequestReviewers, git.IdentityRefWithVote{UniqueName: &name, DisplayName: &name, Vote: &vote})
+	}
+	return &git.GitPullRequest{PullRequestId: &id,
+		Title:        &title,
+		Status:       &status,
+		CreatedBy:    testIdentity(author),
+		CreationDate: &azuredevops.Time{Time: testPullRequestCreation},
+		ClosedDate:   &azuredevops.Time{Time: testPullRequestCreation.Add(closedAfter)},
+		Reviewers:    &pullRequestReviewers,
+	}
+}
+
+func TestPullRequestInsightNew(t *testing.T) {
+	t.Run("Valid run", func(t *testing.T) {
+		pullRequest := testPullRequest(1, git.PullRequestStatusValues.Completed, "Author@Example.com", 5*time.Hour, map[string]int{"reviewer@example.com": VoteApproved})
+		threads := []git.GitPullRequestCommentThread{
+			{Comments: &[]git.Comment{testComment("author@example.com", 10*time.Minute, git.CommentTypeValues.Text)}},
+			{Comments: &[]git.Comment{testComment("reviewer@example.com", 2*time.Hour, git.CommentTypeValues.Text),
+				testComment("author@example.com", 3*time.Hour, git.CommentTypeValues.Text)}},
+			{Comments: &[]git.Comment{testComment("reviewer@example.com", time.Hour, git.CommentTypeValues.System)}},
+		}
+		workItemId := "42"
+
+		insight := PullRequestInsightNew("repo", pullRequest, threads, []webapi.ResourceRef{{Id: &workItemId}})
+
+		if insight.AuthorUniqueName != "author@example.com" {
+			t.Errorf("AuthorUniqueName = %s", insight.AuthorUniqueName)
+		}
+		if insight.ThreadsCount != 2 || insight.CommentsCount != 3 {
+			t.Errorf("ThreadsCount = %d, CommentsCount = %d", insight.ThreadsCount, insight.CommentsCount)
+		}
+		if insight.TimeToFirstReview != 2*time.Hour {
+			t.Errorf("TimeToFirstReview = %s", insight.TimeToFirstReview)
+		}
+		if insight.TimeToMerge != 5*time.Hour {
+			t.Errorf("TimeToMerge = %s", insight.TimeToMerge)
+		}
+		if len(insight.WorkItemIDs) != 1 || insight.WorkItemIDs[0] != "42" {
+			t.Errorf("WorkItemIDs = %v", insight.WorkItemIDs)
+		}
+	})
+}
+
+func TestGeneratePullRequestReport(t *testing.T) {
+	t.Run("Valid run", func(t *testing.T) {
+		insights := []*PullRequestInsight{
+			PullRequestInsightNew("repo", testPullRequest(1, git.PullRequestStatusValues.Completed, "a@example.com", 4*time.Hour,
+				map[string]int{"b@example.com": VoteApproved}), nil, nil),
+			PullRequestInsightNew("repo", testPullRequest(2, git.PullRequestStatusValues.Completed, "a@example.com", 2*time.Hour,
+				map[string]int{"b@example.com": VoteRejected}), nil, nil),
+			PullRequestInsightNew("repo", testPullRequest(3, git.PullRequestStatusValues.Active, "a@example.com", 0,
+				map[string]int{"b@example.com": VoteNoVote}), nil, nil),
+			// Completed without a closed date, not counted in the merge time.
+			PullRequestInsightNew("repo", testPullRequest(4, git.PullRequestStatusValues.Completed, "c@example.com", 0, nil), nil, nil),
+		}
+
+		report := GeneratePullRequestReport(insights)
+
+		repositoryStats := report.PerRepository["repo"]
+		if repositoryStats.Total != 4 || repositoryStats.Completed != 3 || repositoryStats.Merged != 2 || repositoryStats.Active != 1 {
+			t.Errorf("PerRepository = %+v", repositoryStats)
+		}
+		if repositoryStats.AverageTimeToMerge != 3*time.Hour {
+			t.Errorf("AverageTimeToMerge = %s", repositoryStats.AverageTimeToMerge)
+		}
+
+		reviewerStats := report.GetWorkerStats(&human_api_types.Worker{Id: "B@example.com"})
+		if reviewerStats.ReviewsRequested != 3 || reviewerStats.Approved != 1 || reviewerStats.Rejected != 1 || reviewerStats.ReviewsPending != 1 {
+			t.Errorf("PerWorker = %+v", reviewerStats)
+		}
+
+		authorStats := report.PerWorker["a@example.com"]
+		if authorStats.Authored != 3 || authorStats.Merged != 2 {
+			t.Errorf("PerWorker = %+v", authorStats)
+		}
+	})
+}
+
+func TestPullRequestFilterMatchDates(t *testing.T) {
+	t.Run("Valid run", func(t *testing.T) {
+		pullRequest := testPullRequest(1, git.PullRequestStatusValues.Active, "a@example.com", 0, nil)
+		filter := PullRequestFilter{From: testPullRequestCreation.Add(-time.Hour), To: testPullRequestCreation.Add(time.Hour)}
+		if !filter.MatchDates(pullRequest) {
+			t.Errorf("MatchDates() = false, want true")
+		}
+		filter.From = testPullRequestCreation.Add(time.Minute)
+		if filter.MatchDates(pullRequest) {
+			t.Errorf("MatchDates() = true, want false")
+		}
+	})
+}
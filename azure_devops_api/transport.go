@@ -0,0 +1,364 @@
+package azure_devops_api
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AlexeyBeley/go_misc/logger"
+	"github.com/google/uuid"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7"
+)
+
+const (
+	DefaultBaseURL        = "https://dev.azure.com"
+	DefaultRequestTimeout = 30 * time.Second
+	DefaultMaxRetries     = 4
+	DefaultRetryBaseDelay = 500 * time.Millisecond
+	DefaultRetryMaxDelay  = 30 * time.Second
+)
+
+const redactedValue = "[REDACTED]"
+
+// Methods retried on server side failures and transport errors: sending them twice has the effect of once.
+var idempotentMethods = []string{http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete}
+
+// Read only queries sent as POST, retried as the idempotent methods.
+var readOnlyPostPaths = []string{"/_apis/wit/wiql", "/_apis/wit/workitemsbatch"}
+
+// Headers never written to the debug log as is.
+var redactedHeaders = []string{"Authorization", "Cookie", "Set-Cookie", "X-Tfs-Session"}
+
+// Transport is the single http layer shared by the sdk clients and the legacy request functions.
+// It sets the authorization header, applies a per call deadline, retries rate limited and
+// server side failures with exponential backoff and optionally logs every attempt.
+// POST and PATCH are retried only when rate limited: a failure after the server committed
+// the request would create or update the work item twice.
+type Transport struct {
+	Base            http.RoundTripper
+	OrganizationURL string
+	Authorization   string
+	Timeout         time.Duration
+	MaxRetries      int
+	BaseDelay       time.Duration
+	MaxDelay        time.Duration
+	Debug           bool
+	// Replaceable in tests, waits the delay or until the context is done.
+	Sleep func(ctx context.Context, delay time.Duration) error
+
+	secrets           []string
+	resourceAreas     map[uuid.UUID]string
+	resourceAreasLock sync.Mutex
+
+	// Logs the attempts when Debug is set, at debug level regardless of the package logger level.
+	debugLogger logger.Logger
+}
+
+func organizationURL(config Configuration) string {
+	baseURL := config.BaseURL
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	return strings.TrimRight(baseURL, "/") + "/" + config.OrganizationName
+}
+
+// OAuth bearer token takes precedence over the personal access token.
+func authorizationHeaderValue(config *Configuration) (string, error) {
+	if config.OAuthToken != "" {
+		return "Bearer " + config.OAuthToken, nil
+	}
+	if config.PersonalAccessToken != "" {
+		return "Basic " + basicAuth(config.PersonalAccessToken), nil
+	}
+	return "", fmt.Errorf("neither OAuthToken nor PersonalAccessToken was set")
+}
+
+func TransportNew(config *Configuration) (*Transport, error) {
+	errorPrefix := "[transport->TransportNew]"
+
+	authorization, err := authorizationHeaderValue(config)
+	if err != nil {
+		return nil, fmt.Errorf("%s Generating authorization\n%w", errorPrefix, err)
+	}
+
+	ret := &Transport{Base: http.DefaultTransport,
+		OrganizationURL: organizationURL(*config),
+		Authorization:   authorization,
+		Timeout:         DefaultRequestTimeout,
+		MaxRetries:      DefaultMaxRetries,
+		BaseDelay:       DefaultRetryBaseDelay,
+		MaxDelay:        DefaultRetryMaxDelay,
+		Debug:           config.DebugHTTP,
+		secrets:         []string{},
+		debugLogger:     lg,
+	}
+	ret.debugLogger.Level = logger.DEBUG
+
+	if config.RequestTimeoutSeconds > 0 {
+		ret.Timeout = time.Duration(config.RequestTimeoutSeconds) * time.Second
+	}
+
+	// Zero keeps the default, negative value disables the retries.
+	if config.MaxRetries < 0 {
+		ret.MaxRetries = 0
+	} else if config.MaxRetries > 0 {
+		ret.MaxRetries = config.MaxRetries
+	}
+
+	if config.OAuthToken != "" {
+		ret.secrets = append(ret.secrets, config.OAuthToken)
+	}
+	if config.PersonalAccessToken != "" {
+		ret.secrets = append(ret.secrets, config.PersonalAccessToken, basicAuth(config.PersonalAccessToken))
+	}
+
+	return ret, nil
+}
+
+func (transport *Transport) HTTPClient() *http.Client {
+	return &http.Client{Transport: transport}
+}
+
+func (transport *Transport) connection(baseUrl string) *azuredevops.Connection {
+	return &azuredevops.Connection{AuthorizationString: transport.Authorization, BaseUrl: baseUrl}
+}
+
+// Builds an sdk client for the resource area, the way azuredevops.Connection does, but sending through the transport.
+func (transport *Transport) SDKClientNew(ctx context.Context, resourceAreaId uuid.UUID) (*azuredevops.Client, error) {
+	errorPrefix := "[transport->SDKClientNew]"
+
+	locationUrl, err := transport.getResourceAreaLocation(ctx, resourceAreaId)
+	if err != nil {
+		return nil, fmt.Errorf("%s Resolving resource area %s\n%w", errorPrefix, resourceAreaId, err)
+	}
+
+	return azuredevops.NewClientWithOptions(transport.connection(locationUrl), locationUrl, azuredevops.WithHTTPClient(transport.HTTPClient())), nil
+}
+
+func (transport *Transport) getResourceAreaLocation(ctx context.Context, resourceAreaId uuid.UUID) (string, error) {
+	transport.resourceAreasLock.Lock()
+	defer transport.resourceAreasLock.Unlock()
+
+	if transport.resourceAreas == nil {
+		client := azuredevops.NewClientWithOptions(transport.connection(transport.OrganizationURL), transport.OrganizationURL, azuredevops.WithHTTPClient(transport.HTTPClient()))
+		resourceAreaInfos, err := client.GetResourceAreas(ctx)
+		if err != nil {
+			return "", ConvertSDKError(err)
+		}
+
+		transport.resourceAreas = map[uuid.UUID]string{}
+		for _, resourceAreaInfo := range *resourceAreaInfos {
+			if resourceAreaInfo.Id != nil && resourceAreaInfo.LocationUrl != nil {
+				transport.resourceAreas[*resourceAreaInfo.Id] = *resourceAreaInfo.LocationUrl
+			}
+		}
+	}
+
+	// On prem servers return an empty list, everything is served from the organization url.
+	if len(transport.resourceAreas) == 0 {
+		return transport.OrganizationURL, nil
+	}
+
+	locationUrl, ok := transport.resourceAreas[resourceAreaId]
+	if !ok {
+		return "", &azuredevops.ResourceAreaIdNotRegisteredError{ResourceAreaId: resourceAreaId, Url: transport.OrganizationURL}
+	}
+	return locationUrl, nil
+}
+
+func (transport *Transport) RoundTrip(request *http.Request) (*http.Response, error) {
+	ctx := request.Context()
+	cancel := context.CancelFunc(func() {})
+	if _, ok := ctx.Deadline(); !ok && transport.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, transport.Timeout)
+	}
+
+	for attempt := 0; ; attempt++ {
+		attemptRequest, err := transport.prepareRequest(ctx, request, attempt)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+
+		start := time.Now()
+		response, err := transport.base().RoundTrip(attemptRequest)
+		retry := transport.shouldRetry(ctx, request, response, err, attempt)
+		transport.logAttempt(attemptRequest, response, err, attempt, time.Since(start), retry)
+
+		if !retry {
+			if err != nil {
+				cancel()
+				return nil, err
+			}
+			// The deadline must outlive RoundTrip: the caller is still reading the body.
+			response.Body = &cancelOnCloseBody{ReadCloser: response.Body, cancel: cancel}
+			return response, nil
+		}
+
+		delay := transport.retryDelay(response, attempt)
+		if response != nil {
+			_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, httpErrorBodyLimit))
+			response.Body.Close()
+		}
+
+		err = transport.sleep(ctx, delay)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+	}
+}
+
+func (transport *Transport) base() http.RoundTripper {
+	if transport.Base == nil {
+		return http.DefaultTransport
+	}
+	return transport.Base
+}
+
+// RoundTripper must not modify the original request, every attempt gets a clone with a fresh body.
+func (transport *Transport) prepareRequest(ctx context.Context, request *http.Request, attempt int) (*http.Request, error) {
+	ret := request.Clone(ctx)
+	if ret.Header.Get("Authorization") == "" && transport.Authorization != "" {
+		ret.Header.Set("Authorization", transport.Authorization)
+	}
+
+	if attempt > 0 && request.Body != nil && request.Body != http.NoBody {
+		body, err := request.GetBody()
+		if err != nil {
+			return nil, fmt.Errorf("[transport->prepareRequest] Replaying request body\n%w", err)
+		}
+		ret.Body = body
+	}
+	return ret, nil
+}
+
+func (transport *Transport) shouldRetry(ctx context.Context, request *http.Request, response *http.Response, err error, attempt int) bool {
+	if attempt >= transport.MaxRetries || ctx.Err() != nil {
+		return false
+	}
+
+	// Streamed bodies can not be sent twice.
+	if request.Body != nil && request.Body != http.NoBody && request.GetBody == nil {
+		return false
+	}
+
+	if err == nil && response.StatusCode == http.StatusTooManyRequests {
+		return true
+	}
+	if !idempotent(request) {
+		return false
+	}
+	return err != nil || response.StatusCode >= http.StatusInternalServerError
+}
+
+func idempotent(request *http.Request) bool {
+	if slices.Contains(idempotentMethods, request.Method) {
+		return true
+	}
+	if request.Method != http.MethodPost {
+		return false
+	}
+	for _, path := range readOnlyPostPaths {
+		if strings.HasSuffix(strings.TrimRight(request.URL.Path, "/"), path) {
+			return true
+		}
+	}
+	return false
+}
+
+// Retry-After wins over the exponential backoff, both are capped by MaxDelay.
+func (transport *Transport) retryDelay(response *http.Response, attempt int) time.Duration {
+	delay := transport.BaseDelay << attempt
+	if response != nil {
+		if seconds, err := strconv.Atoi(response.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+			delay = time.Duration(seconds) * time.Second
+		}
+	}
+
+	if transport.MaxDelay > 0 && (delay > transport.MaxDelay || delay < 0) {
+		return transport.MaxDelay
+	}
+	return delay
+}
+
+func (transport *Transport) sleep(ctx context.Context, delay time.Duration) error {
+	if transport.Sleep != nil {
+		return transport.Sleep(ctx, delay)
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Every attempt is logged at debug level with the headers, the retries and the failures at info level.
+func (transport *Transport) logAttempt(request *http.Request, response *http.Response, err error, attempt int, duration time.Duration, retry bool) {
+	status := "error: " + transport.redact(fmt.Sprintf("%v", err))
+	if err == nil {
+		status = response.Status
+	}
+	url := transport.redact(request.URL.String())
+	if transport.Debug {
+		transport.debugLogger.DebugF("[azure_devops_api] %s %s attempt %d: %s (%s) headers: %v",
+			request.Method, url, attempt+1, status, duration.Round(time.Millisecond), transport.redactHeaders(request.Header))
+	}
+
+	switch {
+	case retry:
+		lg.InfoF("[azure_devops_api] %s %s attempt %d: %s, retrying", request.Method, url, attempt+1, status)
+	case err != nil || response.StatusCode >= http.StatusInternalServerError || response.StatusCode == http.StatusTooManyRequests:
+		lg.InfoF("[azure_devops_api] %s %s attempt %d failed: %s", request.Method, url, attempt+1, status)
+	}
+}
+
+func (transport *Transport) redact(text string) string {
+	for _, secret := range transport.secrets {
+		text = strings.ReplaceAll(text, secret, redactedValue)
+	}
+	return text
+}
+
+func (transport *Transport) redactHeaders(header http.Header) http.Header {
+	ret := http.Header{}
+	for key, values := range header {
+		for _, value := range values {
+			if containsHeader(redactedHeaders, key) {
+				value = redactedValue
+			}
+			ret.Add(key, transport.redact(value))
+		}
+	}
+	return ret
+}
+
+func containsHeader(headers []string, key string) bool {
+	for _, header := range headers {
+		if strings.EqualFold(header, key) {
+			return true
+		}
+	}
+	return false
+}
+
+type cancelOnCloseBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (body *cancelOnCloseBody) Close() error {
+	err := body.ReadCloser.Close()
+	body.cancel()
+	return err
+}
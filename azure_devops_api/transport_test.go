@@ -0,0 +1,233 @@
+package azure_devops_api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AlexeyBeley/go_misc/logger"
+)
+
+// Recorded request/response pair, replayed by fixtureServer in the file order.
+type fixtureInteraction struct {
+	Request struct {
+		Method string `json:"Method"`
+		Path   string `json:"Path"`
+	} `json:"Request"`
+	Response struct {
+		StatusCode        int               `json:"StatusCode"`
+		Headers           map[string]string `json:"Headers"`
+		Body              json.RawMessage   `json:"Body"`
+		DelayMilliseconds int               `json:"DelayMilliseconds"`
+	} `json:"Response"`
+}
+
+type recordedRequest struct {
+	Method        string
+	Path          string
+	Authorization string
+	Body          []byte
+}
+
+type fixtureServer struct {
+	*httptest.Server
+	interactions []fixtureInteraction
+	Requests     []recordedRequest
+	lock         sync.Mutex
+}
+
+func fixtureServerNew(t *testing.T, fixtureName string) *fixtureServer {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("tests", "fixtures", fixtureName))
+	if err != nil {
+		t.Fatalf("reading fixture: %v", err)
+	}
+
+	ret := &fixtureServer{}
+	err = json.Unmarshal(data, &ret.interactions)
+	if err != nil {
+		t.Fatalf("parsing fixture: %v", err)
+	}
+
+	ret.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		ret.lock.Lock()
+		index := len(ret.Requests)
+		ret.Requests = append(ret.Requests, recordedRequest{Method: r.Method, Path: r.URL.Path, Authorization: r.Header.Get("Authorization"), Body: body})
+		ret.lock.Unlock()
+
+		if index >= len(ret.interactions) {
+			t.Errorf("unexpected request #%d: %s %s", index, r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotImplemented)
+			return
+		}
+
+		interaction := ret.interactions[index]
+		if interaction.Request.Method != r.Method || interaction.Request.Path != r.URL.Path {
+			t.Errorf("request #%d: got %s %s, fixture expects %s %s", index, r.Method, r.URL.Path, interaction.Request.Method, interaction.Request.Path)
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(time.Duration(interaction.Response.DelayMilliseconds) * time.Millisecond):
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		for key, value := range interaction.Response.Headers {
+			w.Header().Set(key, value)
+		}
+		w.WriteHeader(interaction.Response.StatusCode)
+		_, _ = w.Write(interaction.Response.Body)
+	}))
+
+	t.Cleanup(func() {
+		ret.Close()
+		if len(ret.Requests) != len(ret.interactions) {
+			t.Errorf("fixture %s: served %d of %d interactions", fixtureName, len(ret.Requests), len(ret.interactions))
+		}
+	})
+	return ret
+}
+
+func fixtureConfiguration(server *fixtureServer) Configuration {
+	return Configuration{PersonalAccessToken: "test-pat",
+		OrganizationName: "org",
+		ProjectName:      "proj",
+		SprintName:       "Sprint 1",
+		AreaPath:         "proj\\Team",
+		BaseURL:          server.URL}
+}
+
+func TestTransportRoundTrip(t *testing.T) {
+	t.Run("Valid run", func(t *testing.T) {
+		server := fixtureServerNew(t, "retry.json")
+		config := fixtureConfiguration(server)
+		transport, err := TransportNew(&config)
+		if err != nil {
+			t.Fatalf("TransportNew() error = %v", err)
+		}
+		delays := []time.Duration{}
+		transport.Sleep = func(ctx context.Context, delay time.Duration) error {
+			delays = append(delays, delay)
+			return nil
+		}
+
+		request, err := createRequest(config, context.Background(), "wit/wiql?api-version=7.0", http.MethodPost, bytes.NewReader([]byte(`{"query": "SELECT"}`)), "application/json")
+		if err != nil {
+			t.Fatalf("createRequest() error = %v", err)
+		}
+		response, err := transport.HTTPClient().Do(request)
+		if err != nil {
+			t.Fatalf("Do() error = %v", err)
+		}
+		defer response.Body.Close()
+
+		if response.StatusCode != http.StatusOK || len(server.Requests) != 3 {
+			t.Fatalf("status = %d after %d requests", response.StatusCode, len(server.Requests))
+		}
+		for _, request := range server.Requests {
+			if string(request.Body) != `{"query": "SELECT"}` || request.Authorization != "Basic "+basicAuth("test-pat") {
+				t.Errorf("request was not replayed as is: %+v", request)
+			}
+		}
+		if len(delays) != 2 || delays[0] != 2*time.Second || delays[1] != DefaultRetryBaseDelay<<1 {
+			t.Errorf("delays = %v", delays)
+		}
+	})
+
+	t.Run("Work item changes are retried only when rate limited", func(t *testing.T) {
+		server := fixtureServerNew(t, "create_wit_unavailable.json")
+		config := fixtureConfiguration(server)
+		transport, _ := TransportNew(&config)
+		transport.Sleep = func(ctx context.Context, delay time.Duration) error { return nil }
+
+		request, _ := createRequest(config, context.Background(), "wit/workitems/$Task?api-version=7.0", http.MethodPost, bytes.NewReader([]byte(`[]`)), "application/json-patch+json")
+		response, err := transport.HTTPClient().Do(request)
+		if err != nil {
+			t.Fatalf("Do() error = %v", err)
+		}
+		defer response.Body.Close()
+
+		if response.StatusCode != http.StatusServiceUnavailable || len(server.Requests) != 2 {
+			t.Errorf("status = %d after %d requests", response.StatusCode, len(server.Requests))
+		}
+	})
+
+	t.Run("Client errors are not retried", func(t *testing.T) {
+		server := fixtureServerNew(t, "not_found.json")
+		config := fixtureConfiguration(server)
+		config.OAuthToken = "test-token"
+		client, err := getClient(config)
+		if err != nil {
+			t.Fatalf("getClient() error = %v", err)
+		}
+
+		request, _ := createRequest(config, context.Background(), "wit/workitems/404", http.MethodGet, nil, "application/json")
+		response, err := client.Do(request)
+		if err != nil {
+			t.Fatalf("Do() error = %v", err)
+		}
+		defer response.Body.Close()
+
+		if err := CheckResponse(response); !errors.Is(err, ErrNotFound) {
+			t.Errorf("CheckResponse() = %v", err)
+		}
+		if server.Requests[0].Authorization != "Bearer test-token" {
+			t.Errorf("Authorization = %s", server.Requests[0].Authorization)
+		}
+	})
+
+	t.Run("Per call deadline", func(t *testing.T) {
+		server := fixtureServerNew(t, "slow.json")
+		config := fixtureConfiguration(server)
+		transport, _ := TransportNew(&config)
+		transport.Timeout = 50 * time.Millisecond
+
+		request, _ := createRequest(config, context.Background(), "wit/workitems/1", http.MethodGet, nil, "application/json")
+		_, err := transport.HTTPClient().Do(request)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Do() error = %v, expected deadline exceeded", err)
+		}
+	})
+}
+
+func TestTransportRedact(t *testing.T) {
+	t.Run("Valid run", func(t *testing.T) {
+		config := Configuration{PersonalAccessToken: "secret-pat", OrganizationName: "org"}
+		transport, _ := TransportNew(&config)
+
+		header := http.Header{"Authorization": []string{transport.Authorization}, "X-Custom": []string{"pat=secret-pat"}}
+		redacted := transport.redactHeaders(header)
+		if redacted.Get("Authorization") != redactedValue || redacted.Get("X-Custom") != "pat="+redactedValue {
+			t.Errorf("redactHeaders() = %v", redacted)
+		}
+		if strings.Contains(transport.redact("Basic "+basicAuth("secret-pat")), basicAuth("secret-pat")) {
+			t.Errorf("redact() leaked basic auth value")
+		}
+	})
+}
+
+func TestTransportDebug(t *testing.T) {
+	t.Run("Valid run", func(t *testing.T) {
+		level := lg.Level
+		config := Configuration{PersonalAccessToken: "secret-pat", OrganizationName: "org", DebugHTTP: true}
+		transport, err := TransportNew(&config)
+		if err != nil {
+			t.Fatalf("TransportNew() error = %v", err)
+		}
+		if lg.Level != level || transport.debugLogger.Level != logger.DEBUG || !transport.Debug {
+			t.Errorf("DebugHTTP must only change the transport logger: package level %d, transport level %d", lg.Level, transport.debugLogger.Level)
+		}
+	})
+}
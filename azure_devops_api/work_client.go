@@ -2,10 +2,8 @@ package azure_devops_api
 
 import (
 	"context"
-	"log"
 
 	"github.com/google/uuid"
-	"github.com/microsoft/azure-devops-go-api/azuredevops/v7"
 	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/work"
 )
 
@@ -14,14 +12,14 @@ type WorkClient struct {
 	Configuration *Configuration
 }
 
-func WorkClientNew(Configuration *Configuration, context context.Context, connection *azuredevops.Connection) (*WorkClient, error) {
+func WorkClientNew(Configuration *Configuration, context context.Context, transport *Transport) (*WorkClient, error) {
 
-	workClient, err := work.NewClient(context, connection)
+	client, err := transport.SDKClientNew(context, work.ResourceAreaId)
 	if err != nil {
-		log.Fatalf("Failed to create Git client: %v", err)
-		return nil, err
+		lg.InfoF("Failed to create Work client: %v", err)
+		return nil, ConvertSDKError(err)
 	}
-	ret := &WorkClient{Configuration: Configuration, Client: workClient}
+	ret := &WorkClient{Configuration: Configuration, Client: &work.ClientImpl{Client: *client}}
 
 	return ret, nil
 }
@@ -32,7 +30,7 @@ func (workClient *WorkClient) GetTeamIterations(teamId *string) ([]work.TeamSett
 	// Make the API call to get a page of repositories
 	iters, err := workClient.Client.GetTeamIterations(context.Background(), args)
 	if err != nil {
-		return nil, err
+		return nil, ConvertSDKError(err)
 	}
 
 	return *iters, nil
@@ -44,7 +42,7 @@ func (workClient *WorkClient) GetTeamFieldValues(teamName *string) (*work.TeamFi
 
 	response, err := workClient.Client.GetTeamFieldValues(context.Background(), args)
 	if err != nil {
-		return nil, err
+		return nil, ConvertSDKError(err)
 	}
 
 	return response, nil
@@ -61,7 +59,68 @@ func (workClient *WorkClient) GetIterationWorkItems(teamName *string, iterationI
 
 	response, err := workClient.Client.GetIterationWorkItems(context.Background(), args)
 	if err != nil {
-		return nil, err
+		return nil, ConvertSDKError(err)
+	}
+
+	return response, nil
+}
+
+func (workClient *WorkClient) GetIterationCapacities(teamName *string, iterationId *uuid.UUID) (*work.TeamCapacity, error) {
+
+	args := work.GetCapacitiesWithIdentityRefAndTotalsArgs{Project: &workClient.Configuration.ProjectName, Team: teamName, IterationId: iterationId}
+
+	response, err := workClient.Client.GetCapacitiesWithIdentityRefAndTotals(context.Background(), args)
+	if err != nil {
+		return nil, ConvertSDKError(err)
+	}
+
+	return response, nil
+}
+
+func (workClient *WorkClient) UpdateMemberCapacity(teamName *string, iterationId *uuid.UUID, teamMemberId *uuid.UUID, patch *work.CapacityPatch) (*work.TeamMemberCapacityIdentityRef, error) {
+
+	args := work.UpdateCapacityWithIdentityRefArgs{Project: &workClient.Configuration.ProjectName, Team: teamName, IterationId: iterationId, TeamMemberId: teamMemberId, Patch: patch}
+
+	response, err := workClient.Client.UpdateCapacityWithIdentityRef(context.Background(), args)
+	if err != nil {
+		return nil, ConvertSDKError(err)
+	}
+
+	return response, nil
+}
+
+func (workClient *WorkClient) GetTeamDaysOff(teamName *string, iterationId *uuid.UUID) (*work.TeamSettingsDaysOff, error) {
+
+	args := work.GetTeamDaysOffArgs{Project: &workClient.Configuration.ProjectName, Team: teamName, IterationId: iterationId}
+
+	response, err := workClient.Client.GetTeamDaysOff(context.Background(), args)
+	if err != nil {
+		return nil, ConvertSDKError(err)
+	}
+
+	return response, nil
+}
+
+func (workClient *WorkClient) UpdateTeamDaysOff(teamName *string, iterationId *uuid.UUID, daysOff *[]work.DateRange) (*work.TeamSettingsDaysOff, error) {
+
+	args := work.UpdateTeamDaysOffArgs{Project: &workClient.Configuration.ProjectName, Team: teamName, IterationId: iterationId, DaysOffPatch: &work.TeamSettingsDaysOffPatch{DaysOff: daysOff}}
+
+	response, err := workClient.Client.UpdateTeamDaysOff(context.Background(), args)
+	if err != nil {
+		return nil, ConvertSDKError(err)
+	}
+
+	return response, nil
+}
+
+// Subscribes the team to an existing iteration node.
+func (workClient *WorkClient) AddTeamIteration(teamName *string, iterationId *uuid.UUID) (*work.TeamSettingsIteration, error) {
+
+	args := work.PostTeamIterationArgs{Project: &workClient.Configuration.ProjectName, Team: teamName, Iteration: &work.TeamSettingsIteration{Id: iterationId}}
+
+	response, err := workClient.Client.PostTeamIteration(context.Background(), args)
+	if err != nil {
+		return nil, ConvertSDKError(err)
 	}
 
 	return response, nil
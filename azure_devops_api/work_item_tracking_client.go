@@ -3,12 +3,12 @@ package azure_devops_api
 import (
 	"context"
 	"fmt"
-	"log"
 	"strings"
+	"time"
 
 	"github.com/AlexeyBeley/go_misc/common_utils"
 	"github.com/AlexeyBeley/go_misc/human_api_types/v1"
-	"github.com/microsoft/azure-devops-go-api/azuredevops/v7"
+	"github.com/google/uuid"
 	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/webapi"
 	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/workitemtracking"
 )
@@ -18,19 +18,20 @@ type WorkItemTrackingClient struct {
 	Configuration *Configuration
 }
 
-func WorkItemTrackingClientNew(Configuration *Configuration, context context.Context, connection *azuredevops.Connection) (*WorkItemTrackingClient, error) {
+func WorkItemTrackingClientNew(Configuration *Configuration, context context.Context, transport *Transport) (*WorkItemTrackingClient, error) {
 
-	workItemTrackingClient, err := workitemtracking.NewClient(context, connection)
+	client, err := transport.SDKClientNew(context, workitemtracking.ResourceAreaId)
 	if err != nil {
-		log.Fatalf("Failed to create Git client: %v", err)
-		return nil, err
+		lg.InfoF("Failed to create WorkItemTracking client: %v", err)
+		return nil, ConvertSDKError(err)
 	}
-	ret := &WorkItemTrackingClient{Configuration: Configuration, Client: workItemTrackingClient}
+	ret := &WorkItemTrackingClient{Configuration: Configuration, Client: &workitemtracking.ClientImpl{Client: *client}}
 
 	return ret, nil
 }
 
 func (workItemTrackingClient *WorkItemTrackingClient) GetTeamSprints(teamId *string) ([]human_api_types.Sprint, error) {
+	errorPrefix := "[work_item_tracking_client->GetTeamSprints]"
 	ret := []human_api_types.Sprint{}
 
 	depth := 15
@@ -40,7 +41,7 @@ func (workItemTrackingClient *WorkItemTrackingClient) GetTeamSprints(teamId *str
 		Depth:          &depth,
 	})
 	if err != nil {
-		log.Fatalf("Error getting iteration paths: %v", err)
+		return nil, fmt.Errorf("%s Error getting iteration paths: %w", errorPrefix, ConvertSDKError(err))
 	}
 
 	for _, rootChild := range *rootNode.Children {
@@ -66,7 +67,7 @@ func (workItemTrackingClient *WorkItemTrackingClient) GetTeamSprints(teamId *str
 
 			startDate, err := common_utils.StringToDate(startDateString)
 			if err != nil {
-				return nil, err
+				return nil, fmt.Errorf("%s Parsing start date of iteration '%s'\n%w", errorPrefix, *subChild.Name, err)
 			}
 
 			finishDateString, ok := finishDateStringAny.(string)
@@ -76,7 +77,7 @@ func (workItemTrackingClient *WorkItemTrackingClient) GetTeamSprints(teamId *str
 
 			finishDate, err := common_utils.StringToDate(finishDateString)
 			if err != nil {
-				return nil, err
+				return nil, fmt.Errorf("%s Parsing finish date of iteration '%s'\n%w", errorPrefix, *subChild.Name, err)
 			}
 
 			sprint := human_api_types.Sprint{Id: strings.Replace(*subChild.Path, "\\Iteration\\", "\\", 1), Name: *subChild.Name, DateStart: *startDate, DateEnd: *finishDate}
@@ -121,7 +122,7 @@ func (workItemTrackingClient *WorkItemTrackingClient) AddWitComment(workItemID i
 		Id:       &workItemID,
 	})
 	if err != nil {
-		return fmt.Errorf("error updating work item: %v", err)
+		return fmt.Errorf("error updating work item: %w", ConvertSDKError(err))
 	}
 	return nil
 }
@@ -133,13 +134,21 @@ func (workItemTrackingClient *WorkItemTrackingClient) UpdateWit(workItemID *int,
 		Id:       workItemID,
 	})
 	if err != nil {
-		return nil, fmt.Errorf("error updating work item: %v", err)
+		return nil, fmt.Errorf("error updating work item: %w", ConvertSDKError(err))
 	}
 
 	return workItem, err
 
 }
 
+type Iteration struct {
+	Id         *int
+	Identifier *uuid.UUID
+	Path       *string
+	Name       *string
+	Attributes *map[string]interface{}
+}
+
 // todo:
 func (workItemTrackingClient *WorkItemTrackingClient) GetIterationBySrpint(sprint *human_api_types.Sprint) (*Iteration, error) {
 	errorSuffix := "[work_item_tracking_client->GetIterationBySrpint]"
@@ -151,7 +160,7 @@ func (workItemTrackingClient *WorkItemTrackingClient) GetIterationBySrpint(sprin
 		Depth:          &depth,
 	})
 	if err != nil {
-		return nil, fmt.Errorf("%s Error getting iteration paths: %v", errorSuffix, err)
+		return nil, fmt.Errorf("%s Error getting iteration paths: %w", errorSuffix, ConvertSDKError(err))
 	}
 
 	for _, rootChild := range *rootNode.Children {
@@ -172,6 +181,33 @@ func (workItemTrackingClient *WorkItemTrackingClient) GetIterationBySrpint(sprin
 	return nil, fmt.Errorf("%s Was not able to find Iteration by Sprint", errorSuffix)
 }
 
+// Creates iteration node under parentPath, relative to the project's iterations root.
+func (workItemTrackingClient *WorkItemTrackingClient) CreateIteration(parentPath string, sprint *human_api_types.Sprint) (*Iteration, error) {
+	errorSuffix := "[work_item_tracking_client->CreateIteration]"
+
+	attributes := map[string]interface{}{
+		"startDate":  sprint.DateStart.Format(time.RFC3339),
+		"finishDate": sprint.DateEnd.Format(time.RFC3339),
+	}
+	node, err := workItemTrackingClient.Client.CreateOrUpdateClassificationNode(context.Background(), workitemtracking.CreateOrUpdateClassificationNodeArgs{
+		Project:        &workItemTrackingClient.Configuration.ProjectName,
+		StructureGroup: &workitemtracking.TreeStructureGroupValues.Iterations,
+		Path:           &parentPath,
+		PostedNode:     &workitemtracking.WorkItemClassificationNode{Name: &sprint.Name, Attributes: &attributes},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s Error creating iteration '%s'\n%w", errorSuffix, sprint.Name, ConvertSDKError(err))
+	}
+
+	iteration := Iteration{Id: node.Id,
+		Identifier: node.Identifier,
+		Path:       node.Path,
+		Name:       node.Name,
+		Attributes: node.Attributes}
+
+	return &iteration, nil
+}
+
 func (workItemTrackingClient *WorkItemTrackingClient) GetWorkerIterationWorkItems(workerEmail string, iterationPath string) ([]*WorkItem, error) {
 
 	errorSuffix := "[work_item_tracking_client->GetWorkerIterationWorkItems]"
@@ -195,14 +231,17 @@ func (workItemTrackingClient *WorkItemTrackingClient) GetWorkerIterationWorkItem
 
 	res, err := workItemTrackingClient.Client.QueryByWiql(context.Background(), wiql)
 	if err != nil {
-		log.Fatal(err)
+		return nil, fmt.Errorf("%s Querying work items\n%w", errorSuffix, ConvertSDKError(err))
 	}
 	wits := []*WorkItem{}
+	if res.WorkItems == nil {
+		return wits, nil
+	}
 	for _, witResponse := range *res.WorkItems {
 		wit := &WorkItem{ID: *witResponse.Id}
 		updateSucceeded, err := workItemTrackingClient.UpdateWitInformation(wit)
 		if err != nil {
-			return nil, fmt.Errorf("%s was not able to update wits from the Wit Ids\n%v", errorSuffix, err)
+			return nil, fmt.Errorf("%s was not able to update wits from the Wit Ids\n%w", errorSuffix, err)
 		}
 		if !updateSucceeded {
 			return nil, fmt.Errorf("%s was not able to update wits from the Wit Ids", errorSuffix)
@@ -219,7 +258,7 @@ func (workItemTrackingClient *WorkItemTrackingClient) UpdateWitInformation(wit *
 	params := workitemtracking.GetWorkItemArgs{Id: &wit.ID, Expand: &expand}
 	azureDevopsWorkItem, err := workItemTrackingClient.Client.GetWorkItem(context.Background(), params)
 	if err != nil {
-		return false, fmt.Errorf("error updating work item information: %v", err)
+		return false, fmt.Errorf("error updating work item information: %w", ConvertSDKError(err))
 	}
 	wit.Fields = *azureDevopsWorkItem.Fields
 
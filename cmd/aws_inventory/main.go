@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	actionManager "github.com/AlexeyBeley/go_misc/action_manager"
+	clients "github.com/AlexeyBeley/go_misc/aws_api/clients"
+	inventory "github.com/AlexeyBeley/go_misc/aws_api/inventory"
+	"github.com/AlexeyBeley/go_misc/logger"
+)
+
+var lg = &(logger.Logger{})
+
+/*
+go run . --action Snapshot --config /opt/aws_api/inventory/InventoryConfig.json
+go run . --action Diff --old ./snapshots/2025-01-01 --new ./snapshots/2025-02-01
+*/
+func main() {
+	action := flag.String("action", "", "Snapshot or Diff")
+	configFilePath := flag.String("config", "/opt/aws_api/inventory/InventoryConfig.json", "Inventory configuration file")
+	oldPath := flag.String("old", "", "Old snapshot file or directory")
+	newPath := flag.String("new", "", "New snapshot file or directory")
+	flag.Parse()
+
+	actionManager, err := actionManager.ActionManagerNew()
+	if err != nil {
+		panic(err)
+	}
+
+	(*actionManager).ActionMap = map[string]any{
+		"Snapshot": func() error {
+			config, err := loadConfig(*configFilePath)
+			if err != nil {
+				return err
+			}
+			executor, err := clients.ExecutorNew(&config.Executor)
+			if err != nil {
+				return err
+			}
+			return inventory.SnapshotAllTargets(context.Background(), executor, config).Err()
+		},
+		"Diff": func() error {
+			changes, err := inventory.DiffPaths(*oldPath, *newPath)
+			if err != nil {
+				return err
+			}
+			for _, change := range changes {
+				fmt.Println(change.String())
+			}
+			lg.InfoF("Found %d inventory changes", len(changes))
+			if len(changes) > 0 {
+				os.Exit(1)
+			}
+			return nil
+		}}
+
+	err = actionManager.RunAction(action)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func loadConfig(configFilePath string) (*inventory.InventoryConfig, error) {
+	data, err := os.ReadFile(configFilePath)
+	if err != nil {
+		return nil, err
+	}
+	config := &inventory.InventoryConfig{}
+	err = json.Unmarshal(data, config)
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"os"
+
 	"github.com/AlexeyBeley/go_misc/logger"
 	"github.com/AlexeyBeley/go_misc/aws_api"
 )
@@ -21,7 +23,11 @@ func main() {
 
 	//config_file_path := "/opt/aws_api_go/AWSTCPDumpConfig.json"
 	//lg.InfoF("Initializing: %s", config_file_path)
-	awsTCPDumpNew, err := aws_api.AWSTCPDumpNew()
+	command, err := aws_api.ParseCommand(append([]string{"record"}, os.Args[1:]...), os.Getenv)
+	if err != nil {
+		panic(err)
+	}
+	awsTCPDumpNew, err := aws_api.AWSTCPDumpNew(command.Config)
 	if err != nil {
 		panic(err)
 	}
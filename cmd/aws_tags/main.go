@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	actionManager "github.com/AlexeyBeley/go_misc/action_manager"
+	aws_api "github.com/AlexeyBeley/go_misc/aws_api"
+	clients "github.com/AlexeyBeley/go_misc/aws_api/clients"
+	"github.com/AlexeyBeley/go_misc/logger"
+)
+
+var lg = &(logger.Logger{})
+
+/*
+go run . --action Compliance --config /opt/aws_api/tags/ComplianceConfig.json --format markdown --output ./compliance.md
+*/
+func main() {
+	action := flag.String("action", "", "Compliance")
+	configFilePath := flag.String("config", "/opt/aws_api/tags/ComplianceConfig.json", "Compliance configuration file")
+	format := flag.String("format", aws_api.ComplianceFormatJSON, "Report format: json, csv or markdown")
+	outputPath := flag.String("output", "", "Report file, stdout when not set")
+	flag.Parse()
+
+	actionManager, err := actionManager.ActionManagerNew()
+	if err != nil {
+		panic(err)
+	}
+
+	(*actionManager).ActionMap = map[string]any{
+		"Compliance": func() error {
+			config, err := aws_api.LoadComplianceConfig(*configFilePath)
+			if err != nil {
+				return err
+			}
+			executor, err := clients.ExecutorNew(&config.Executor)
+			if err != nil {
+				return err
+			}
+			report, executorReport, err := aws_api.CheckComplianceAllTargets(context.Background(), executor, &config.Schema)
+			if err != nil {
+				return err
+			}
+
+			var writer io.Writer = os.Stdout
+			if *outputPath != "" {
+				file, err := os.Create(*outputPath)
+				if err != nil {
+					return err
+				}
+				defer file.Close()
+				writer = file
+			}
+			err = report.Write(writer, *format)
+			if err != nil {
+				return err
+			}
+			if err = executorReport.Err(); err != nil {
+				return err
+			}
+			lg.InfoF("Found %d offenders in %d resources", len(report.Offenders()), len(report.Results))
+			if len(report.Offenders()) > 0 {
+				// The report file is closed by the deferred Close before main exits.
+				return fmt.Errorf("found %d tag compliance offenders", len(report.Offenders()))
+			}
+			return nil
+		}}
+
+	err = actionManager.RunAction(action)
+	if err != nil {
+		lg.ErrorF("%v", err)
+		os.Exit(1)
+	}
+}
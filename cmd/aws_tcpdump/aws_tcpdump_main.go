@@ -1,36 +1,145 @@
 package main
 
 import (
-	"github.com/AlexeyBeley/go_misc/logger"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
 	"github.com/AlexeyBeley/go_misc/aws_api"
+	"github.com/AlexeyBeley/go_misc/logger"
 )
 
 var lg = &(logger.Logger{})
 
-var actions map[string]any
+var actions map[string]func(*aws_api.AWSTCPDump, *aws_api.AWSTCPDumpCommand) error
 
 func main() {
+	actions = map[string]func(*aws_api.AWSTCPDump, *aws_api.AWSTCPDumpCommand) error{
+		"record":   record,
+		"analyze":  analyze,
+		"sessions": sessions,
+		"cleanup":  cleanup,
+	}
 
-	//addresses := flag.String("address-filter", "", "comma Subnets")
-	// flag.Int(name, defaultValue, usage) returns an *int
-	//age := flag.Int("age", 0, "Your age")
-	// flag.Bool(name, defaultValue, usage) returns a *bool
-	//verbose := flag.Bool("verbose", false, "Enable verbose output")
+	command, err := aws_api.ParseCommand(os.Args[1:], os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		aws_api.WriteCommandsUsage(os.Stderr)
+		os.Exit(2)
+	}
 
-	actions = make(map[string]any, 0)
+	awsTCPDumpNew, err := aws_api.AWSTCPDumpNew(command.Config)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 
-	//config_file_path := "/opt/aws_api_go/AWSTCPDumpConfig.json"
-	//lg.InfoF("Initializing: %s", config_file_path)
-	awsTCPDumpNew, err := aws_api.AWSTCPDumpNew()
+	err = actions[command.Name](awsTCPDumpNew, command)
 	if err != nil {
-		panic(err)
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
+}
 
-	actions["start"] = awsTCPDumpNew.Start
+// export wraps the EventProcessor set by the command, the returned func closes the export file.
+func export(awsTCPDump *aws_api.AWSTCPDump) (func() error, error) {
+	if awsTCPDump.Config.ExportFilePath == "" {
+		return func() error { return nil }, nil
+	}
+	writer, err := awsTCPDump.StartExport()
+	if err != nil {
+		return nil, err
+	}
+	return writer.Close, nil
+}
 
-	err = awsTCPDumpNew.Start()
+// record echoes the events, with -top the traffic report of the recording is written on shutdown.
+func record(awsTCPDump *aws_api.AWSTCPDump, command *aws_api.AWSTCPDumpCommand) error {
+	awsTCPDump.EventProcessor = awsTCPDump.EventsEchoWriterUTCTime
+	var aggregator *aws_api.FlowLogAggregator
+	if awsTCPDump.Config.TopN > 0 {
+		var err error
+		if aggregator, err = awsTCPDump.StartAggregation(); err != nil {
+			return err
+		}
+	}
+	closeExport, err := export(awsTCPDump)
 	if err != nil {
-		panic(err)
+		return err
+	}
+	if err := errors.Join(awsTCPDump.Start(), closeExport()); err != nil {
+		return err
 	}
+	if aggregator == nil {
+		return nil
+	}
+	return awsTCPDump.WriteTrafficReport(aggregator, os.Stdout)
+}
 
+func analyze(awsTCPDump *aws_api.AWSTCPDump, command *aws_api.AWSTCPDumpCommand) error {
+	if awsTCPDump.Config.SecurityGroupsPlanFilePath != "" {
+		analyzer, err := awsTCPDump.LoadSecurityGroupAnalyzer(context.Background())
+		if err != nil {
+			return err
+		}
+		awsTCPDump.EventProcessor = analyzer.Process
+		closeExport, err := export(awsTCPDump)
+		if err != nil {
+			return err
+		}
+		if err := errors.Join(awsTCPDump.StartOffline(), closeExport()); err != nil {
+			return err
+		}
+		return awsTCPDump.WriteSecurityGroupsPlan(analyzer, os.Stdout)
+	}
+
+	awsTCPDump.EventProcessor = awsTCPDump.EventsEchoWriterUTCTime
+	if awsTCPDump.Config.TopN == 0 {
+		closeExport, err := export(awsTCPDump)
+		if err != nil {
+			return err
+		}
+		return errors.Join(awsTCPDump.StartOffline(), closeExport())
+	}
+
+	aggregator, err := awsTCPDump.TrafficAggregatorNew()
+	if err != nil {
+		return err
+	}
+	awsTCPDump.EventProcessor = aggregator.Process
+	closeExport, err := export(awsTCPDump)
+	if err != nil {
+		return err
+	}
+	if err := errors.Join(awsTCPDump.StartOffline(), closeExport()); err != nil {
+		return err
+	}
+	return awsTCPDump.WriteTrafficReport(aggregator, os.Stdout)
+}
+
+func sessions(awsTCPDump *aws_api.AWSTCPDump, command *aws_api.AWSTCPDumpCommand) error {
+	found, err := aws_api.TCPDumpSessionsList(awsTCPDump.Config.SessionsDirPath)
+	if err != nil {
+		return err
+	}
+	for _, session := range found {
+		fmt.Printf("%s, started %s\n", session, session.StartedAt.Format("2006-01-02 15:04:05 MST"))
+	}
+	return nil
+}
+
+// cleanup deletes the leftovers of crashed recording sessions.
+func cleanup(awsTCPDump *aws_api.AWSTCPDump, command *aws_api.AWSTCPDumpCommand) error {
+	errs := []error{}
+	for _, sessionID := range command.Args {
+		if err := awsTCPDump.CleanupSessionByID(sessionID); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
 }
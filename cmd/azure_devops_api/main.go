@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	actionManager "github.com/AlexeyBeley/go_misc/action_manager"
+	"github.com/AlexeyBeley/go_misc/azure_devops_api"
+	config_pol "github.com/AlexeyBeley/go_misc/configuration_policy"
+	"github.com/AlexeyBeley/go_misc/logger"
+)
+
+var lg = &(logger.Logger{})
+
+/*
+go run . --action ExportPipelines --dir ./pipelines --format yaml
+go run . --action DiffPipelines --dir ./pipelines
+*/
+func main() {
+	action := flag.String("action", "", "ExportPipelines or DiffPipelines")
+	configFilePath := flag.String("config", "/opt/azure_devops_api/configuration.json", "Azure devops configuration file")
+	snapshotDir := flag.String("dir", "./pipelines", "Pipeline definitions snapshot directory")
+	format := flag.String("format", azure_devops_api.PipelineExportFormatJSON, "Export format: json or yaml")
+	flag.Parse()
+
+	api, err := azure_devops_api.AzureDevopsAPINew(config_pol.WithConfigurationFile(configFilePath))
+	if err != nil {
+		panic(err)
+	}
+
+	actionManager, err := actionManager.ActionManagerNew()
+	if err != nil {
+		panic(err)
+	}
+
+	(*actionManager).ActionMap = map[string]any{
+		"ExportPipelines": func() error {
+			return api.ExportPipelineDefinitions(*snapshotDir, *format)
+		},
+		"DiffPipelines": func() error {
+			changes, err := api.DiffPipelineDefinitions(*snapshotDir)
+			if err != nil {
+				return err
+			}
+			for _, change := range changes {
+				fmt.Println(change.String())
+			}
+			lg.InfoF("Found %d pipeline changes", len(changes))
+			if len(changes) > 0 {
+				os.Exit(1)
+			}
+			return nil
+		}}
+
+	err = actionManager.RunAction(action)
+	if err != nil {
+		panic(err)
+	}
+}
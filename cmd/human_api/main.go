@@ -1,7 +1,10 @@
 package main
 
 import (
+	"flag"
+
 	actionManager "github.com/AlexeyBeley/go_misc/action_manager"
+	"github.com/AlexeyBeley/go_misc/azure_devops_api"
 	config_pol "github.com/AlexeyBeley/go_misc/configuration_policy"
 	humanAPI "github.com/AlexeyBeley/go_misc/human_api"
 	humanAPISlackServer "github.com/AlexeyBeley/go_misc/human_api/slack_server"
@@ -10,26 +13,72 @@ import (
 
 var lg = &(logger.Logger{})
 var GlobalSlackServerConfigurationFilePath = "/opt/human_api/slack_server_configuration.json"
+var GlobalHumanAPIConfigurationFilePath = "/opt/human_api/human_api_config.json"
+var GlobalAzureDevopsAPIConfigurationFilePath = "/opt/azure_devops_api/configuration.json"
 
+/*
+go run . --action SlackBotServer
+go run . --action CapacityReportAction
+go run . --action CreateSprintsAction --template ./sprint_template.json
+go run . --action WorkerDaysOffAction --sprint "Sprint 7" --worker worker@example.com --days-off 2025-03-03..2025-03-05,2025-03-10
+go run . --action TeamDaysOffAction --sprint "Sprint 7" --days-off 2025-03-17
+go run . --action PullRequestReportAction --from 2025-03-03 --to 2025-03-14
+*/
 func main() {
+	action := flag.String("action", "SlackBotServer", "SlackBotServer, TicketAction, CapacityReportAction, CreateSprintsAction, WorkerDaysOffAction, TeamDaysOffAction or PullRequestReportAction")
+	configFilePath := flag.String("config", GlobalHumanAPIConfigurationFilePath, "HumanAPI configuration file")
+	azureDevopsConfigFilePath := flag.String("azure-devops-config", GlobalAzureDevopsAPIConfigurationFilePath, "Azure DevOps API configuration file")
+	templateFilePath := flag.String("template", "", "Sprint template JSON file, CreateSprintsAction")
+	sprintName := flag.String("sprint", "", "Sprint name, days off actions")
+	worker := flag.String("worker", "", "Team member id, display name or unique name, WorkerDaysOffAction")
+	daysOff := flag.String("days-off", "", "Comma separated days or inclusive ranges: 2025-03-03..2025-03-05,2025-03-10, empty clears them")
+	from := flag.String("from", "", "First day, PullRequestReportAction, 14 days ago by default")
+	to := flag.String("to", "", "Last day, PullRequestReportAction, today by default")
+	flag.Parse()
 
 	actionManager, err := actionManager.ActionManagerNew()
 	if err != nil {
 		panic(err)
 	}
 
-	humanApi, err := humanAPI.HumanAPINew()
-	if err != nil {
-		panic(err)
+	// The Slack server builds its own HumanAPI, the other actions use the configured project manager.
+	humanAPINew := func() (*humanAPI.HumanAPI, error) {
+		projectManagerAPI, err := azure_devops_api.AzureDevopsAPINew(config_pol.WithConfigurationFile(azureDevopsConfigFilePath))
+		if err != nil {
+			return nil, err
+		}
+		return humanAPI.HumanAPINew(config_pol.WithConfigurationFile(configFilePath), humanAPI.WithProjectManagerAPI(projectManagerAPI))
+	}
+	humanAPIAction := func(run func(*humanAPI.HumanAPI) error) func() error {
+		return func() error {
+			api, err := humanAPINew()
+			if err != nil {
+				return err
+			}
+			return run(api)
+		}
 	}
 
-	server := humanAPISlackServer.SlackServerNew(config_pol.WithConfigurationFile(&GlobalSlackServerConfigurationFilePath))
 	(*actionManager).ActionMap = map[string]any{
-		"TicketAction":   humanApi.TicketAction,
-		"SlackBotServer": server.Start}
+		"TicketAction":         humanAPIAction((*humanAPI.HumanAPI).TicketAction),
+		"CapacityReportAction": humanAPIAction((*humanAPI.HumanAPI).CapacityReportAction),
+		"CreateSprintsAction": humanAPIAction(func(api *humanAPI.HumanAPI) error {
+			return api.CreateSprintsAction(*templateFilePath)
+		}),
+		"WorkerDaysOffAction": humanAPIAction(func(api *humanAPI.HumanAPI) error {
+			return api.WorkerDaysOffAction(*sprintName, *worker, *daysOff)
+		}),
+		"TeamDaysOffAction": humanAPIAction(func(api *humanAPI.HumanAPI) error {
+			return api.TeamDaysOffAction(*sprintName, *daysOff)
+		}),
+		"PullRequestReportAction": humanAPIAction(func(api *humanAPI.HumanAPI) error {
+			return api.PullRequestReportAction(*from, *to)
+		}),
+		"SlackBotServer": func() error {
+			return humanAPISlackServer.SlackServerNew(config_pol.WithConfigurationFile(&GlobalSlackServerConfigurationFilePath)).Start()
+		}}
 
-	action := "SlackBotServer"
-	err = actionManager.RunAction(&action)
+	err = actionManager.RunAction(action)
 
 	if err != nil {
 		panic(err)
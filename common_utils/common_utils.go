@@ -26,6 +26,6 @@ func StringToDate(dateString string) (*time.Time, error) {
 
 func ErrorCreator(errorPrefix string) func(string, error) error {
 	return func(line string, err error) error {
-		return fmt.Errorf("%s %s\n%v", errorPrefix, line, err)
+		return fmt.Errorf("%s %s\n%w", errorPrefix, line, err)
 	}
 }
@@ -732,6 +732,7 @@ type HumanAPIConfiguration struct {
 	TicketDefaultValuesFilePath   string `json:"TicketDefaultValuesFilePath,omitempty"`
 	WorkerName                    string `json:"WorkerName,omitempty"`
 	DailiesReportsPath            string `json:"DailiesReportsPath,omitempty"`
+	TeamName                      string `json:"TeamName,omitempty"`
 }
 
 type HumanAPI struct {
@@ -759,7 +760,13 @@ func HumanAPINew(options ...config_pol.Option) (*HumanAPI, error) {
 	humanAPI := &HumanAPI{}
 	config := &HumanAPIConfiguration{}
 	for _, option := range options {
-		option(humanAPI, config)
+		err := option(humanAPI, config)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if humanAPI.Configuration == nil {
+		humanAPI.Configuration = config
 	}
 
 	humanAPI.initConfigDefaults()
@@ -792,6 +799,188 @@ func (humanAPI *HumanAPI) TicketAction() error {
 
 }
 
+func (humanAPI *HumanAPI) getCapacityPlanner() (human_api_types.CapacityPlanner, error) {
+	if humanAPI.ProjectManagerAPI == nil || *humanAPI.ProjectManagerAPI == nil {
+		return nil, fmt.Errorf("[human_api->getCapacityPlanner] ProjectManagerAPI is not configured")
+	}
+	capacityPlanner, ok := (*humanAPI.ProjectManagerAPI).(human_api_types.CapacityPlanner)
+	if !ok {
+		return nil, fmt.Errorf("[human_api->getCapacityPlanner] ProjectManagerAPI does not support capacity planning")
+	}
+	return capacityPlanner, nil
+}
+
+func (humanAPI *HumanAPI) GetCapacityReport(teamName string) (*human_api_types.CapacityReport, error) {
+	baseError := "[human_api->GetCapacityReport]"
+	capacityPlanner, err := humanAPI.getCapacityPlanner()
+	if err != nil {
+		return nil, fmt.Errorf("%s\n%w", baseError, err)
+	}
+
+	report, err := capacityPlanner.GetCapacityReport(teamName)
+	if err != nil {
+		return nil, fmt.Errorf("%s error fetching capacity report from ProjectManagerAPI\n %w", baseError, err)
+	}
+	return report, nil
+}
+
+// Implemented by the project managers tracking pull requests, azure_devops_api.
+type pullRequestReporter interface {
+	GetPullRequestReport(filter *azure_devops_api.PullRequestFilter) (*azure_devops_api.PullRequestReport, error)
+}
+
+// Pull requests created between from and to, nil when the ProjectManagerAPI does not track pull requests.
+func (humanAPI *HumanAPI) GetPullRequestReport(from, to time.Time) (*azure_devops_api.PullRequestReport, error) {
+	baseError := "[human_api->GetPullRequestReport]"
+	if humanAPI.ProjectManagerAPI == nil || *humanAPI.ProjectManagerAPI == nil {
+		return nil, nil
+	}
+	reporter, ok := (*humanAPI.ProjectManagerAPI).(pullRequestReporter)
+	if !ok {
+		return nil, nil
+	}
+
+	report, err := reporter.GetPullRequestReport(&azure_devops_api.PullRequestFilter{From: from, To: to})
+	if err != nil {
+		return nil, fmt.Errorf("%s error fetching pull request report from ProjectManagerAPI\n %w", baseError, err)
+	}
+	return report, nil
+}
+
+// Prints the pull requests per repository and worker, from and to are "2006-01-02" dates, the last 14 days by default.
+func (humanAPI *HumanAPI) PullRequestReportAction(from string, to string) error {
+	errorPrefix := "[human_api->PullRequestReportAction]"
+	toDate := time.Now()
+	fromDate := toDate.AddDate(0, 0, -14)
+	var err error
+	if from != "" {
+		if fromDate, err = time.Parse(time.DateOnly, from); err != nil {
+			return fmt.Errorf("%s Parsing from date\n%w", errorPrefix, err)
+		}
+	}
+	if to != "" {
+		if toDate, err = time.Parse(time.DateOnly, to); err != nil {
+			return fmt.Errorf("%s Parsing to date\n%w", errorPrefix, err)
+		}
+		// The whole last day.
+		toDate = toDate.AddDate(0, 0, 1)
+	}
+
+	report, err := humanAPI.GetPullRequestReport(fromDate, toDate)
+	if err != nil {
+		return err
+	}
+	if report == nil {
+		return fmt.Errorf("%s ProjectManagerAPI does not track pull requests", errorPrefix)
+	}
+	fmt.Println(report.String())
+	return nil
+}
+
+// Prints the team capacity vs load, with the code review load when pull requests are tracked, and fails if anyone is overloaded.
+func (humanAPI *HumanAPI) CapacityReportAction() error {
+	report, err := humanAPI.GetCapacityReport(humanAPI.Configuration.TeamName)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(report.String())
+
+	pullRequestReport, err := humanAPI.GetPullRequestReport(report.Sprint.DateStart, report.Sprint.DateEnd.AddDate(0, 0, 1))
+	if err != nil {
+		return err
+	}
+	if pullRequestReport != nil {
+		fmt.Println("Code review load:")
+		for _, workerCapacity := range report.Workers {
+			fmt.Println(pullRequestReport.GetWorkerStats(&workerCapacity.Worker).String())
+		}
+	}
+
+	overloaded := report.OverloadedWorkers()
+	if len(overloaded) > 0 {
+		names := []string{}
+		for _, workerCapacity := range overloaded {
+			names = append(names, workerCapacity.Worker.Name)
+		}
+		return fmt.Errorf("overloaded workers: %s", strings.Join(names, ", "))
+	}
+	return nil
+}
+
+func (humanAPI *HumanAPI) CreateSprints(teamName string, template *human_api_types.SprintTemplate) ([]human_api_types.Sprint, error) {
+	baseError := "[human_api->CreateSprints]"
+	capacityPlanner, err := humanAPI.getCapacityPlanner()
+	if err != nil {
+		return nil, fmt.Errorf("%s\n%w", baseError, err)
+	}
+
+	sprints, err := capacityPlanner.CreateSprints(teamName, template)
+	if err != nil {
+		return nil, fmt.Errorf("%s error creating sprints in ProjectManagerAPI\n %w", baseError, err)
+	}
+	return sprints, nil
+}
+
+// Creates the sprints of the JSON SprintTemplate file in the configured team.
+func (humanAPI *HumanAPI) CreateSprintsAction(templateFilePath string) error {
+	errorPrefix := "[human_api->CreateSprintsAction]"
+	data, err := os.ReadFile(templateFilePath)
+	if err != nil {
+		return fmt.Errorf("%s Reading template file '%s'\n%w", errorPrefix, templateFilePath, err)
+	}
+	template := &human_api_types.SprintTemplate{}
+	err = json.Unmarshal(data, template)
+	if err != nil {
+		return fmt.Errorf("%s Parsing template file '%s'\n%w", errorPrefix, templateFilePath, err)
+	}
+
+	sprints, err := humanAPI.CreateSprints(humanAPI.Configuration.TeamName, template)
+	if err != nil {
+		return err
+	}
+	for _, sprint := range sprints {
+		fmt.Printf("%s: %s - %s\n", sprint.Name, sprint.DateStart.Format(time.DateOnly), sprint.DateEnd.Format(time.DateOnly))
+	}
+	return nil
+}
+
+// Replaces the worker days off in the sprint of the configured team, daysOff is parsed by ParseDaysOff.
+func (humanAPI *HumanAPI) WorkerDaysOffAction(sprintName string, worker string, daysOff string) error {
+	baseError := "[human_api->WorkerDaysOffAction]"
+	daysOffRanges, err := human_api_types.ParseDaysOff(daysOff)
+	if err != nil {
+		return fmt.Errorf("%s\n%w", baseError, err)
+	}
+	capacityPlanner, err := humanAPI.getCapacityPlanner()
+	if err != nil {
+		return fmt.Errorf("%s\n%w", baseError, err)
+	}
+	err = capacityPlanner.SetWorkerDaysOff(humanAPI.Configuration.TeamName, sprintName, worker, daysOffRanges)
+	if err != nil {
+		return fmt.Errorf("%s error setting worker days off in ProjectManagerAPI\n %w", baseError, err)
+	}
+	return nil
+}
+
+// Replaces the team days off in the sprint of the configured team, daysOff is parsed by ParseDaysOff.
+func (humanAPI *HumanAPI) TeamDaysOffAction(sprintName string, daysOff string) error {
+	baseError := "[human_api->TeamDaysOffAction]"
+	daysOffRanges, err := human_api_types.ParseDaysOff(daysOff)
+	if err != nil {
+		return fmt.Errorf("%s\n%w", baseError, err)
+	}
+	capacityPlanner, err := humanAPI.getCapacityPlanner()
+	if err != nil {
+		return fmt.Errorf("%s\n%w", baseError, err)
+	}
+	err = capacityPlanner.SetTeamDaysOff(humanAPI.Configuration.TeamName, sprintName, daysOffRanges)
+	if err != nil {
+		return fmt.Errorf("%s error setting team days off in ProjectManagerAPI\n %w", baseError, err)
+	}
+	return nil
+}
+
 func (humanAPI *HumanAPI) CreateTicket(Type, Title, Description, WorkerName string, Priority int) error {
 
 	Worker, err := humanAPI.GetWorker(&WorkerName)
@@ -829,7 +1018,7 @@ func (humanAPI *HumanAPI) GetWorkerSprint(Worker *human_api_types.Worker) (*huma
 	baseError := "[human_api->GetWorkerSprint]"
 	sprint, err := (*humanAPI.ProjectManagerAPI).GetWorkerSprint(Worker)
 	if err != nil {
-		return nil, fmt.Errorf("%s error fetching worker sprint from ProjectManagerAPI\n %v", baseError, err)
+		return nil, fmt.Errorf("%s error fetching worker sprint from ProjectManagerAPI\n %w", baseError, err)
 	}
 	return sprint, nil
 }
@@ -860,13 +1049,15 @@ type DailyConfig struct {
 	InputFilePath    string                  `json:"InputFilePath"`
 	OutputFilePath   string                  `json:"OutputFilePath"`
 	WobjectsFilePath string                  `json:"WobjectsFilePath"`
+	// Code review load of the worker in the sprint, written next to the report.
+	CodeReviewFilePath string `json:"CodeReviewFilePath"`
 }
 
 func (humanAPI *HumanAPI) DailyConfigNew(worker *human_api_types.Worker) (*DailyConfig, error) {
 	dailyConfg := &DailyConfig{}
 	sprint, err := humanAPI.GetWorkerSprint(worker)
 	if err != nil {
-		return nil, fmt.Errorf("[humanAPI->DailyConfigNew] Error getting worker sprint\n %v", err)
+		return nil, fmt.Errorf("[humanAPI->DailyConfigNew] Error getting worker sprint\n %w", err)
 	}
 	dailyConfg.Sprint = sprint
 	dailyConfg.Worker = worker
@@ -888,6 +1079,7 @@ func (humanAPI *HumanAPI) DailyConfigNew(worker *human_api_types.Worker) (*Daily
 	dailyConfg.InputFilePath = filepath.Join(dailyConfg.DailyDirectory, "input.hapi")
 	dailyConfg.OutputFilePath = filepath.Join(dailyConfg.DailyDirectory, "YTB.hapi")
 	dailyConfg.WobjectsFilePath = filepath.Join(dailyConfg.DailyDirectory, "wobjects.json")
+	dailyConfg.CodeReviewFilePath = filepath.Join(dailyConfg.DailyDirectory, "code_review.txt")
 
 	return dailyConfg, err
 }
@@ -896,22 +1088,22 @@ func (humanAPI *HumanAPI) FetchDaily(worker *human_api_types.Worker) (err error)
 	errorPrefix := "[human_api:FetchDaily]"
 	dailyConfig, err := humanAPI.DailyConfigNew(worker)
 	if err != nil {
-		return fmt.Errorf("%s Initializing DailyConfigNew\n%v", errorPrefix, err)
+		return fmt.Errorf("%s Initializing DailyConfigNew\n%w", errorPrefix, err)
 	}
 
 	if !checkFileExists(dailyConfig.InputFilePath) {
 		err = humanAPI.GenerateDailyReport(dailyConfig)
 		if err != nil {
-			return fmt.Errorf("%s Generating daily report\n%v", errorPrefix, err)
+			return fmt.Errorf("%s Generating daily report\n%w", errorPrefix, err)
 		}
 		err = copyFile(dailyConfig.ReportFilePath, dailyConfig.InputFilePath)
 		if err != nil {
-			return fmt.Errorf("%s Error Copying report file to input file\n%v", errorPrefix, err)
+			return fmt.Errorf("%s Error Copying report file to input file\n%w", errorPrefix, err)
 		}
 	}
 
 	if !checkFileExists(dailyConfig.WobjectsFilePath) {
-		return fmt.Errorf("%s Wobjects file is missing\n%v", errorPrefix, err)
+		return fmt.Errorf("%s Wobjects file is missing\n%w", errorPrefix, err)
 	}
 
 	fmt.Printf("SUCCESS!! %s\n", dailyConfig.InputFilePath)
@@ -924,13 +1116,13 @@ func (humanAPI *HumanAPI) GenerateDailyReport(dailyConfig *DailyConfig) error {
 	if !checkFileExists(dailyConfig.WobjectsFilePath) {
 		err := humanAPI.DownloadDailySprintWobjects(dailyConfig)
 		if err != nil {
-			return fmt.Errorf("%s Downloading daily sprint Wobjects\n%v", errorPrefix, err)
+			return fmt.Errorf("%s Downloading daily sprint Wobjects\n%w", errorPrefix, err)
 		}
 	}
 
 	wobjects, err := humanAPI.LoadWobjectsFromFile(dailyConfig.WobjectsFilePath)
 	if err != nil {
-		return fmt.Errorf("%s Marshaling wobjects\n%v", errorPrefix, err)
+		return fmt.Errorf("%s Marshaling wobjects\n%w", errorPrefix, err)
 	}
 	wobjects["-1"] = &human_api_types.Wobject{Id: "-1", Title: "AutoGenerated", Type: "UserStory"}
 
@@ -955,7 +1147,7 @@ func (humanAPI *HumanAPI) GenerateDailyReport(dailyConfig *DailyConfig) error {
 
 		parentPointer, childPointer, err = humanAPI.GenerateParentAndChildForReport(wobject, wobjects)
 		if err != nil {
-			return fmt.Errorf("%s Desiding parent and child order\n%v", errorPrefix, err)
+			return fmt.Errorf("%s Desiding parent and child order\n%w", errorPrefix, err)
 		}
 
 		workerID = wobject.WorkerID
@@ -988,6 +1180,34 @@ func (humanAPI *HumanAPI) GenerateDailyReport(dailyConfig *DailyConfig) error {
 	}
 	reports = append(reports, workerDailyReport)
 	WriteDailyToHRFile(reports, dailyConfig.ReportFilePath)
+
+	err = humanAPI.WriteDailyCodeReviewLoad(dailyConfig)
+	if err != nil {
+		return fmt.Errorf("%s Writing code review load\n%w", errorPrefix, err)
+	}
+	return nil
+}
+
+// Writes the worker pull requests stats since the sprint start.
+// Nothing is written when pull requests are not tracked or the daily has no sprint and worker.
+func (humanAPI *HumanAPI) WriteDailyCodeReviewLoad(dailyConfig *DailyConfig) error {
+	errorPrefix := "[human_api:WriteDailyCodeReviewLoad]"
+	if dailyConfig.Sprint == nil || dailyConfig.Worker == nil || dailyConfig.CodeReviewFilePath == "" {
+		return nil
+	}
+
+	report, err := humanAPI.GetPullRequestReport(dailyConfig.Sprint.DateStart, time.Now())
+	if err != nil {
+		return err
+	}
+	if report == nil {
+		return nil
+	}
+
+	err = os.WriteFile(dailyConfig.CodeReviewFilePath, []byte(report.GetWorkerStats(dailyConfig.Worker).String()+"\n"), 0644)
+	if err != nil {
+		return fmt.Errorf("%s Error writing to file\n%w", errorPrefix, err)
+	}
 	return nil
 }
 
@@ -1000,7 +1220,7 @@ func (humanAPI *HumanAPI) DownloadDailySprintWobjects(dailyConfig *DailyConfig)
 
 	wobjectSlice, err := (*humanAPI.ProjectManagerAPI).GetWorkerSprintWobjects(dailyConfig.Sprint, dailyConfig.Worker)
 	if err != nil {
-		return fmt.Errorf("%s Fetching worker sprint wobjects\n%v", errorPrefix, err)
+		return fmt.Errorf("%s Fetching worker sprint wobjects\n%w", errorPrefix, err)
 	}
 	wobjects := map[string]*human_api_types.Wobject{}
 	for _, wobject := range wobjectSlice {
@@ -1009,12 +1229,12 @@ func (humanAPI *HumanAPI) DownloadDailySprintWobjects(dailyConfig *DailyConfig)
 
 	jsonData, err := json.MarshalIndent(wobjects, "", "  ")
 	if err != nil {
-		return fmt.Errorf("%s Marshaling wobjects\n%v", errorPrefix, err)
+		return fmt.Errorf("%s Marshaling wobjects\n%w", errorPrefix, err)
 	}
 
 	err = os.WriteFile(dailyConfig.WobjectsFilePath, jsonData, 0644) // 0644 are file permissions
 	if err != nil {
-		return fmt.Errorf("%s Error writing to file\n%v", errorPrefix, err)
+		return fmt.Errorf("%s Error writing to file\n%w", errorPrefix, err)
 	}
 	return nil
 
@@ -1053,7 +1273,7 @@ func (humanAPI *HumanAPI) PushDaily(worker *human_api_types.Worker) (err error)
 	errorPrefix := "[human_api:PushDaily]"
 	dailyConfig, err := humanAPI.DailyConfigNew(worker)
 	if err != nil {
-		return fmt.Errorf("%s Initializing DailyConfigNew\n%v", errorPrefix, err)
+		return fmt.Errorf("%s Initializing DailyConfigNew\n%w", errorPrefix, err)
 	}
 
 	if !checkFileExists(dailyConfig.ReportFilePath) || !checkFileExists(dailyConfig.InputFilePath) {
@@ -1062,20 +1282,20 @@ func (humanAPI *HumanAPI) PushDaily(worker *human_api_types.Worker) (err error)
 
 	inputWobjects, err := humanAPI.LoadWobjectsFromReport(dailyConfig, dailyConfig.InputFilePath)
 	if err != nil {
-		return fmt.Errorf("%s Loading input wobjects from report \n%v", errorPrefix, err)
+		return fmt.Errorf("%s Loading input wobjects from report \n%w", errorPrefix, err)
 	}
 	baseWobjects, err := humanAPI.LoadWobjectsFromReport(dailyConfig, dailyConfig.ReportFilePath)
 	if err != nil {
-		return fmt.Errorf("%s Loading report wobjects from report \n%v", errorPrefix, err)
+		return fmt.Errorf("%s Loading report wobjects from report \n%w", errorPrefix, err)
 	}
 
 	err = CleanWobjectsUserInput(inputWobjects)
 	if err != nil {
-		return fmt.Errorf("%s Cleaning wobjects input \n%v", errorPrefix, err)
+		return fmt.Errorf("%s Cleaning wobjects input \n%w", errorPrefix, err)
 	}
 	err = ValidateWobjectsUserInput(baseWobjects, inputWobjects)
 	if err != nil {
-		return fmt.Errorf("%s Validating wobjects input\n%v", errorPrefix, err)
+		return fmt.Errorf("%s Validating wobjects input\n%w", errorPrefix, err)
 	}
 
 	wobjects := FilterChangedWobjects(baseWobjects, inputWobjects)
@@ -1090,11 +1310,11 @@ func (humanAPI *HumanAPI) LoadWobjectsFromFile(filePath string) (map[string]*hum
 	wobjects := map[string]*human_api_types.Wobject{}
 	data, err := os.ReadFile(filePath)
 	if err != nil {
-		return nil, fmt.Errorf("%s Opening file\n%v", errorPrefix, err)
+		return nil, fmt.Errorf("%s Opening file\n%w", errorPrefix, err)
 	}
 	err = json.Unmarshal(data, &wobjects)
 	if err != nil {
-		return nil, fmt.Errorf("%s Unmarshallng file\n%v", errorPrefix, err)
+		return nil, fmt.Errorf("%s Unmarshallng file\n%w", errorPrefix, err)
 	}
 
 	return wobjects, nil
@@ -1104,7 +1324,7 @@ func (humanAPI *HumanAPI) ProvisionDailyWobjects(dailyConfig *DailyConfig, wobje
 	errorPrefix := "[human_api:ProvisionDailyWobjects]"
 	currentWobjects, err := humanAPI.LoadWobjectsFromFile(dailyConfig.WobjectsFilePath)
 	if err != nil {
-		return fmt.Errorf("%s Loading cached wobjects from file %s\n%v", errorPrefix, dailyConfig.WobjectsFilePath, err)
+		return fmt.Errorf("%s Loading cached wobjects from file %s\n%w", errorPrefix, dailyConfig.WobjectsFilePath, err)
 
 	}
 
@@ -1112,7 +1332,7 @@ func (humanAPI *HumanAPI) ProvisionDailyWobjects(dailyConfig *DailyConfig, wobje
 		if wobject.Id == "0" {
 			err = (*humanAPI.ProjectManagerAPI).ProvisionWobject(wobject)
 			if err != nil {
-				return fmt.Errorf("%s Creating wobject %s\n%v", errorPrefix, dailyConfig.WobjectsFilePath, err)
+				return fmt.Errorf("%s Creating wobject %s\n%w", errorPrefix, dailyConfig.WobjectsFilePath, err)
 
 			}
 		}
@@ -1140,11 +1360,11 @@ func (humanAPI *HumanAPI) LoadWobjectsFromReport(dailyConfig *DailyConfig, fileP
 	errorPrefix := "[human_api:LoadWobjectsFromReport]"
 	reports, err := ReadDailyFromHRFile(filePath)
 	if err != nil {
-		return nil, fmt.Errorf("%s Reading daily file\n%v", errorPrefix, err)
+		return nil, fmt.Errorf("%s Reading daily file\n%w", errorPrefix, err)
 	}
 	wobjects, err := humanAPI.GenerateWobjectsFromDailyReports(dailyConfig, reports)
 	if err != nil {
-		return nil, fmt.Errorf("%s Converting Report to Wobjects\n%v", errorPrefix, err)
+		return nil, fmt.Errorf("%s Converting Report to Wobjects\n%w", errorPrefix, err)
 	}
 	return wobjects, nil
 }
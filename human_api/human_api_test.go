@@ -5,7 +5,9 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/AlexeyBeley/go_misc/azure_devops_api"
 	config_pol "github.com/AlexeyBeley/go_misc/configuration_policy"
@@ -207,3 +209,150 @@ func TestPushDaily(t *testing.T) {
 		test_check(t, err)
 	})
 }
+
+// fakeCapacityPlanner records the capacity planning calls.
+type fakeCapacityPlanner struct {
+	human_api_types.ProjectManager
+	sprintTemplate *human_api_types.SprintTemplate
+	daysOff        map[string][]human_api_types.DaysOff
+}
+
+func (planner *fakeCapacityPlanner) GetCapacityReport(teamName string) (*human_api_types.CapacityReport, error) {
+	return &human_api_types.CapacityReport{}, nil
+}
+
+func (planner *fakeCapacityPlanner) CreateSprints(teamName string, template *human_api_types.SprintTemplate) ([]human_api_types.Sprint, error) {
+	planner.sprintTemplate = template
+	return template.GenerateSprints()
+}
+
+func (planner *fakeCapacityPlanner) SetWorkerDaysOff(teamName string, sprintName string, worker string, daysOff []human_api_types.DaysOff) error {
+	planner.daysOff[teamName+"/"+sprintName+"/"+worker] = daysOff
+	return nil
+}
+
+func (planner *fakeCapacityPlanner) SetTeamDaysOff(teamName string, sprintName string, daysOff []human_api_types.DaysOff) error {
+	planner.daysOff[teamName+"/"+sprintName] = daysOff
+	return nil
+}
+
+func TestGetCapacityReport(t *testing.T) {
+	t.Run("Project manager not configured", func(t *testing.T) {
+		humanAPI, err := HumanAPINew()
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if _, err := humanAPI.GetCapacityReport("Team"); err == nil {
+			t.Errorf("expected an error without a ProjectManagerAPI")
+		}
+	})
+
+	t.Run("Actions", func(t *testing.T) {
+		planner := &fakeCapacityPlanner{daysOff: map[string][]human_api_types.DaysOff{}}
+		configFilePath := filepath.Join(t.TempDir(), "human_api_config.json")
+		if err := os.WriteFile(configFilePath, []byte(`{"TeamName": "Team"}`), 0644); err != nil {
+			t.Fatalf("%v", err)
+		}
+		humanAPI, err := HumanAPINew(config_pol.WithConfigurationFile(&configFilePath), WithProjectManagerAPI(planner))
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+
+		templateFilePath := filepath.Join(t.TempDir(), "sprint_template.json")
+		template := `{"NameFormat": "Sprint {number}", "FirstNumber": 7, "StartDate": "2025-03-03T00:00:00Z", "LengthDays": 14, "Count": 2}`
+		if err := os.WriteFile(templateFilePath, []byte(template), 0644); err != nil {
+			t.Fatalf("%v", err)
+		}
+		if err := humanAPI.CreateSprintsAction(templateFilePath); err != nil || planner.sprintTemplate.Count != 2 {
+			t.Errorf("unexpected sprint creation: %v, %+v", err, planner.sprintTemplate)
+		}
+		if err := humanAPI.WorkerDaysOffAction("Sprint 7", "worker@example.com", "2025-03-03..2025-03-05"); err != nil {
+			t.Fatalf("%v", err)
+		}
+		if err := humanAPI.TeamDaysOffAction("Sprint 7", "2025-03-10,2025-03-12"); err != nil {
+			t.Fatalf("%v", err)
+		}
+		if len(planner.daysOff["Team/Sprint 7/worker@example.com"]) != 1 || len(planner.daysOff["Team/Sprint 7"]) != 2 {
+			t.Errorf("unexpected days off: %v", planner.daysOff)
+		}
+		if err := humanAPI.TeamDaysOffAction("Sprint 7", "next week"); err == nil {
+			t.Errorf("expected an error for invalid days off")
+		}
+	})
+}
+
+// fakePullRequestsProjectManager tracks pull requests the way azure_devops_api does.
+type fakePullRequestsProjectManager struct {
+	human_api_types.ProjectManager
+	insights []*azure_devops_api.PullRequestInsight
+	filter   *azure_devops_api.PullRequestFilter
+}
+
+func (projectManager *fakePullRequestsProjectManager) GetPullRequestReport(filter *azure_devops_api.PullRequestFilter) (*azure_devops_api.PullRequestReport, error) {
+	projectManager.filter = filter
+	return azure_devops_api.GeneratePullRequestReport(projectManager.insights), nil
+}
+
+func TestPullRequestReportDaily(t *testing.T) {
+	t.Run("Valid run", func(t *testing.T) {
+		projectManager := &fakePullRequestsProjectManager{insights: []*azure_devops_api.PullRequestInsight{
+			{Repository: "infra", ID: 1, Status: "completed", AuthorUniqueName: "author@example.com", TimeToMerge: time.Hour,
+				Reviewers: []azure_devops_api.PullRequestReviewer{{UniqueName: "worker@example.com", Vote: azure_devops_api.VoteApproved}}},
+			{Repository: "infra", ID: 2, Status: "active", AuthorUniqueName: "worker@example.com",
+				CommentsByWorker: map[string]int{"author@example.com": 2}},
+		}}
+		humanAPI, err := HumanAPINew(WithProjectManagerAPI(projectManager))
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+
+		dailyDirectory := t.TempDir()
+		sprintStart := time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC)
+		dailyConfig := &DailyConfig{DailyDirectory: dailyDirectory,
+			Sprint:             &human_api_types.Sprint{Name: "Sprint 7", DateStart: sprintStart},
+			Worker:             &human_api_types.Worker{Id: "worker-id", SystemName: "Worker@example.com"},
+			ReportFilePath:     filepath.Join(dailyDirectory, "report.hapi"),
+			WobjectsFilePath:   filepath.Join(dailyDirectory, "wobjects.json"),
+			CodeReviewFilePath: filepath.Join(dailyDirectory, "code_review.txt"),
+		}
+		wobjects := `{"1": {"Id": "1", "Title": "Story", "Type": "UserStory", "Status": "New", "WorkerID": "worker-id", "ChildrenIDs": []}}`
+		if err := os.WriteFile(dailyConfig.WobjectsFilePath, []byte(wobjects), 0644); err != nil {
+			t.Fatalf("%v", err)
+		}
+
+		if err := humanAPI.GenerateDailyReport(dailyConfig); err != nil {
+			t.Fatalf("%v", err)
+		}
+		if !projectManager.filter.From.Equal(sprintStart) {
+			t.Errorf("expected the sprint start, got: %v", projectManager.filter.From)
+		}
+		codeReview, err := os.ReadFile(dailyConfig.CodeReviewFilePath)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		expected := "worker@example.com: authored 1, merged 0, reviews requested 1, voted 1, pending 0, comments 0"
+		if !strings.Contains(string(codeReview), expected) {
+			t.Errorf("expected %q, got: %q", expected, codeReview)
+		}
+
+		if err := humanAPI.PullRequestReportAction("2025-03-03", "2025-03-14"); err != nil {
+			t.Fatalf("%v", err)
+		}
+		if !projectManager.filter.To.Equal(time.Date(2025, 3, 15, 0, 0, 0, 0, time.UTC)) {
+			t.Errorf("expected the whole last day, got: %v", projectManager.filter.To)
+		}
+		if err := humanAPI.PullRequestReportAction("yesterday", ""); err == nil {
+			t.Errorf("expected an error for an invalid date")
+		}
+	})
+
+	t.Run("Pull requests not tracked", func(t *testing.T) {
+		humanAPI, err := HumanAPINew(WithProjectManagerAPI(&fakeCapacityPlanner{}))
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if err := humanAPI.PullRequestReportAction("", ""); err == nil {
+			t.Errorf("expected an error without pull requests tracking")
+		}
+	})
+}
@@ -3,6 +3,7 @@ package slack_server
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -181,7 +182,11 @@ func (slackServer *SlackServer) handleInteractivePayload(payload string) error {
 	}
 
 	if err != nil {
-		return fmt.Errorf("error handling actionId %s", actionId)
+		sendErr := slackServer.sendResponseUrlMessage(request.ResponseURL, slackServer.GenerateErrorResponse(DescribeError(err)))
+		if sendErr != nil {
+			log.Printf("Error sending error response to url %s: %v", request.ResponseURL, sendErr)
+		}
+		return fmt.Errorf("error handling actionId %s: %w", actionId, err)
 	}
 
 	err = slackServer.sendResponseUrlMessage(request.ResponseURL, response)
@@ -373,12 +378,35 @@ func (slackServer *SlackServer) hapiMain(w http.ResponseWriter, r *http.Request)
 	}
 
 	if err != nil {
-		response = slackServer.GenerateErrorResponse("Error handling request" + fmt.Sprintf("%v", err))
+		log.Printf("Error handling hapi command '%s': %v", text, err)
+		response = slackServer.GenerateErrorResponse(DescribeError(err))
 		statusCode = http.StatusBadRequest
 	}
 	slackServer.SendResponse(w, statusCode, response)
 }
 
+// Turns project manager errors into a message the Slack user can act on.
+func DescribeError(err error) string {
+	var httpError *azure_devops_api.HTTPError
+	switch {
+	case errors.Is(err, azure_devops_api.ErrNotFound):
+		return "Could not find the requested item in Azure Devops, please check the name or the ID."
+	case errors.Is(err, azure_devops_api.ErrUnauthorized):
+		return "Azure Devops rejected the bot credentials, please ask the admin to renew the access token."
+	case errors.Is(err, azure_devops_api.ErrRateLimited):
+		return "Azure Devops is throttling requests, please retry in a minute."
+	case errors.Is(err, azure_devops_api.ErrConflict):
+		return "The item was changed by someone else in the meantime, please reload it and retry."
+	case errors.As(err, &httpError) && httpError.Kind == azure_devops_api.ErrValidation:
+		return fmt.Sprintf("Azure Devops rejected the request: %s", httpError.Body)
+	case errors.Is(err, azure_devops_api.ErrValidation):
+		return fmt.Sprintf("Invalid data: %v", err)
+	case errors.As(err, &httpError) && httpError.Retryable():
+		return "Azure Devops is temporarily unavailable, please retry later."
+	}
+	return fmt.Sprintf("Error handling request: %v", err)
+}
+
 func (slackServer *SlackServer) GenerateErrorResponse(errMessage string) slackBlockKitResponse {
 
 	//ticketLink := fmt.Sprintf("<http://your-ticketing-system.com/tickets/%d|TICKET-%d>", ticketID, ticketID)
@@ -1,12 +1,15 @@
 package slack_server
 
 import (
+	"fmt"
 	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/AlexeyBeley/go_misc/azure_devops_api"
 	common_utils "github.com/AlexeyBeley/go_misc/common_utils"
 	config_pol "github.com/AlexeyBeley/go_misc/configuration_policy"
 	human_api_types "github.com/AlexeyBeley/go_misc/human_api_types/v1"
@@ -385,3 +388,17 @@ func TestProvisionTaskWobject(t *testing.T) {
 		}
 	})
 }
+
+func TestDescribeError(t *testing.T) {
+	t.Run("Valid run", func(t *testing.T) {
+		err := fmt.Errorf("[test] provisioning\n%w", azure_devops_api.HTTPErrorNew(http.StatusUnauthorized, "TF400813"))
+		if !strings.Contains(DescribeError(err), "access token") {
+			t.Errorf("DescribeError() = %s", DescribeError(err))
+		}
+
+		err = azure_devops_api.HTTPErrorNew(http.StatusBadRequest, "Field Title is required")
+		if !strings.Contains(DescribeError(err), "Field Title is required") {
+			t.Errorf("DescribeError() = %s", DescribeError(err))
+		}
+	})
+}
@@ -0,0 +1,182 @@
+package human_api_types
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type DaysOff struct {
+	Start time.Time `json:"Start"`
+	End   time.Time `json:"End"`
+}
+
+func (daysOff DaysOff) Contains(day time.Time) bool {
+	day = truncateToDay(day)
+	return !day.Before(truncateToDay(daysOff.Start)) && !day.After(truncateToDay(daysOff.End))
+}
+
+// ParseDaysOff parses comma separated "2006-01-02" days or "2006-01-02..2006-01-05" inclusive ranges,
+// an empty string is no days off.
+func ParseDaysOff(src string) ([]DaysOff, error) {
+	errorPrefix := "[human_api_types:ParseDaysOff]"
+	ret := []DaysOff{}
+	for _, daysOffRange := range strings.Split(src, ",") {
+		daysOffRange = strings.TrimSpace(daysOffRange)
+		if daysOffRange == "" {
+			continue
+		}
+		start, end, found := strings.Cut(daysOffRange, "..")
+		if !found {
+			end = start
+		}
+		startDate, err := time.Parse(time.DateOnly, start)
+		if err != nil {
+			return nil, fmt.Errorf("%s Parsing '%s'\n%w", errorPrefix, daysOffRange, err)
+		}
+		endDate, err := time.Parse(time.DateOnly, end)
+		if err != nil {
+			return nil, fmt.Errorf("%s Parsing '%s'\n%w", errorPrefix, daysOffRange, err)
+		}
+		if endDate.Before(startDate) {
+			return nil, fmt.Errorf("%s Range '%s' ends before it starts", errorPrefix, daysOffRange)
+		}
+		ret = append(ret, DaysOff{Start: startDate, End: endDate})
+	}
+	return ret, nil
+}
+
+type WorkerCapacity struct {
+	Worker            Worker    `json:"Worker"`
+	CapacityPerDay    float64   `json:"CapacityPerDay"`
+	DaysOff           []DaysOff `json:"DaysOff"`
+	Load              int       `json:"Load"`
+	RemainingDays     int       `json:"RemainingDays"`
+	RemainingCapacity float64   `json:"RemainingCapacity"`
+	Overloaded        bool      `json:"Overloaded"`
+}
+
+type CapacityReport struct {
+	Sprint      Sprint            `json:"Sprint"`
+	Date        time.Time         `json:"Date"`
+	TeamDaysOff []DaysOff         `json:"TeamDaysOff"`
+	Workers     []*WorkerCapacity `json:"Workers"`
+}
+
+type SprintTemplate struct {
+	// Supports {number}, {start} and {end} placeholders, e.g. "Sprint {number}".
+	NameFormat  string    `json:"NameFormat"`
+	FirstNumber int       `json:"FirstNumber"`
+	StartDate   time.Time `json:"StartDate"`
+	LengthDays  int       `json:"LengthDays"`
+	GapDays     int       `json:"GapDays"`
+	Count       int       `json:"Count"`
+}
+
+func truncateToDay(src time.Time) time.Time {
+	return time.Date(src.Year(), src.Month(), src.Day(), 0, 0, 0, 0, src.Location())
+}
+
+// Counts working days between from and to including both ends, skipping weekends and days off.
+func CountWorkingDays(from, to time.Time, daysOff []DaysOff) int {
+	ret := 0
+	for day := truncateToDay(from); !day.After(truncateToDay(to)); day = day.AddDate(0, 0, 1) {
+		if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+			continue
+		}
+		dayOff := false
+		for _, daysOffRange := range daysOff {
+			if daysOffRange.Contains(day) {
+				dayOff = true
+				break
+			}
+		}
+		if !dayOff {
+			ret++
+		}
+	}
+	return ret
+}
+
+// Sums LeftTime of the wobjects, that are not closed yet.
+func SumLeftTime(wobjects []*Wobject) int {
+	ret := 0
+	for _, wobject := range wobjects {
+		if wobject.Status == "Closed" {
+			continue
+		}
+		ret += wobject.LeftTime
+	}
+	return ret
+}
+
+func (report *CapacityReport) Calculate() {
+	from := report.Date
+	if from.Before(report.Sprint.DateStart) {
+		from = report.Sprint.DateStart
+	}
+
+	for _, workerCapacity := range report.Workers {
+		daysOff := append([]DaysOff{}, report.TeamDaysOff...)
+		daysOff = append(daysOff, workerCapacity.DaysOff...)
+		workerCapacity.RemainingDays = CountWorkingDays(from, report.Sprint.DateEnd, daysOff)
+		workerCapacity.RemainingCapacity = float64(workerCapacity.RemainingDays) * workerCapacity.CapacityPerDay
+		workerCapacity.Overloaded = float64(workerCapacity.Load) > workerCapacity.RemainingCapacity
+	}
+
+	sort.Slice(report.Workers, func(i, j int) bool {
+		return report.Workers[i].Worker.Name < report.Workers[j].Worker.Name
+	})
+}
+
+func (report *CapacityReport) OverloadedWorkers() []*WorkerCapacity {
+	ret := []*WorkerCapacity{}
+	for _, workerCapacity := range report.Workers {
+		if workerCapacity.Overloaded {
+			ret = append(ret, workerCapacity)
+		}
+	}
+	return ret
+}
+
+func (report *CapacityReport) String() string {
+	lines := []string{fmt.Sprintf("Sprint %s: %s - %s", report.Sprint.Name,
+		report.Sprint.DateStart.Format(time.DateOnly), report.Sprint.DateEnd.Format(time.DateOnly))}
+	for _, workerCapacity := range report.Workers {
+		status := "ok"
+		if workerCapacity.Overloaded {
+			status = "OVERLOADED"
+		}
+		lines = append(lines, fmt.Sprintf("%s: load %d, remaining capacity %.1f (%d days x %.1f), %s",
+			workerCapacity.Worker.Name, workerCapacity.Load, workerCapacity.RemainingCapacity,
+			workerCapacity.RemainingDays, workerCapacity.CapacityPerDay, status))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (template *SprintTemplate) GenerateSprints() ([]Sprint, error) {
+	errorPrefix := "[human_api_types:GenerateSprints]"
+	if template.NameFormat == "" {
+		return nil, fmt.Errorf("%s NameFormat was not set", errorPrefix)
+	}
+	if template.LengthDays <= 0 {
+		return nil, fmt.Errorf("%s LengthDays must be positive, received %d", errorPrefix, template.LengthDays)
+	}
+	if template.GapDays < 0 {
+		return nil, fmt.Errorf("%s GapDays can not be negative, received %d", errorPrefix, template.GapDays)
+	}
+
+	ret := []Sprint{}
+	start := truncateToDay(template.StartDate)
+	for i := 0; i < template.Count; i++ {
+		end := start.AddDate(0, 0, template.LengthDays-1)
+		name := strings.NewReplacer("{number}", strconv.Itoa(template.FirstNumber+i),
+			"{start}", start.Format(time.DateOnly),
+			"{end}", end.Format(time.DateOnly)).Replace(template.NameFormat)
+		ret = append(ret, Sprint{Name: name, DateStart: start, DateEnd: end})
+		start = end.AddDate(0, 0, 1+template.GapDays)
+	}
+	return ret, nil
+}
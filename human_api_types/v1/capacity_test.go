@@ -0,0 +1,107 @@
+package human_api_types
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCountWorkingDays(t *testing.T) {
+	t.Run("Valid run", func(t *testing.T) {
+		// Monday to Sunday, Wednesday off.
+		from := time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC)
+		to := time.Date(2025, 3, 9, 0, 0, 0, 0, time.UTC)
+		daysOff := []DaysOff{{Start: time.Date(2025, 3, 5, 0, 0, 0, 0, time.UTC), End: time.Date(2025, 3, 5, 0, 0, 0, 0, time.UTC)}}
+
+		got := CountWorkingDays(from, to, daysOff)
+		if got != 4 {
+			t.Errorf("CountWorkingDays() = %d, want 4", got)
+		}
+	})
+}
+
+func TestCapacityReportCalculate(t *testing.T) {
+	t.Run("Valid run", func(t *testing.T) {
+		report := CapacityReport{
+			Sprint: Sprint{Name: "Sprint 1", DateStart: time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC), DateEnd: time.Date(2025, 3, 14, 0, 0, 0, 0, time.UTC)},
+			Date:   time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC),
+			TeamDaysOff: []DaysOff{{Start: time.Date(2025, 3, 14, 0, 0, 0, 0, time.UTC),
+				End: time.Date(2025, 3, 14, 0, 0, 0, 0, time.UTC)}},
+			Workers: []*WorkerCapacity{
+				{Worker: Worker{Name: "b"}, CapacityPerDay: 6, Load: 20},
+				{Worker: Worker{Name: "a"}, CapacityPerDay: 6, Load: 30,
+					DaysOff: []DaysOff{{Start: time.Date(2025, 3, 12, 0, 0, 0, 0, time.UTC), End: time.Date(2025, 3, 13, 0, 0, 0, 0, time.UTC)}}},
+			},
+		}
+
+		report.Calculate()
+
+		if report.Workers[0].Worker.Name != "a" || report.Workers[0].RemainingDays != 2 || !report.Workers[0].Overloaded {
+			t.Errorf("Workers[0] = %+v", report.Workers[0])
+		}
+		if report.Workers[1].RemainingDays != 4 || report.Workers[1].RemainingCapacity != 24 || report.Workers[1].Overloaded {
+			t.Errorf("Workers[1] = %+v", report.Workers[1])
+		}
+		if len(report.OverloadedWorkers()) != 1 {
+			t.Errorf("OverloadedWorkers() = %v", report.OverloadedWorkers())
+		}
+	})
+}
+
+func TestSumLeftTime(t *testing.T) {
+	t.Run("Valid run", func(t *testing.T) {
+		wobjects := []*Wobject{{LeftTime: 3, Status: "Active"}, {LeftTime: 5, Status: "Closed"}, {LeftTime: 2, Status: "New"}}
+		if SumLeftTime(wobjects) != 5 {
+			t.Errorf("SumLeftTime() = %d, want 5", SumLeftTime(wobjects))
+		}
+	})
+}
+
+func TestGenerateSprints(t *testing.T) {
+	t.Run("Valid run", func(t *testing.T) {
+		template := SprintTemplate{NameFormat: "Sprint {number} ({start})", FirstNumber: 7,
+			StartDate: time.Date(2025, 3, 3, 9, 0, 0, 0, time.UTC), LengthDays: 14, GapDays: 0, Count: 2}
+
+		sprints, err := template.GenerateSprints()
+		if err != nil {
+			t.Fatalf("GenerateSprints() error = %v", err)
+		}
+		if len(sprints) != 2 || sprints[0].Name != "Sprint 7 (2025-03-03)" || sprints[1].Name != "Sprint 8 (2025-03-17)" {
+			t.Errorf("GenerateSprints() = %v", sprints)
+		}
+		if !sprints[0].DateEnd.Equal(time.Date(2025, 3, 16, 0, 0, 0, 0, time.UTC)) {
+			t.Errorf("DateEnd = %v", sprints[0].DateEnd)
+		}
+	})
+
+	t.Run("Invalid length", func(t *testing.T) {
+		template := SprintTemplate{NameFormat: "Sprint {number}", Count: 1}
+		_, err := template.GenerateSprints()
+		if err == nil {
+			t.Errorf("GenerateSprints() expected error")
+		}
+	})
+}
+
+func TestParseDaysOff(t *testing.T) {
+	t.Run("Valid run", func(t *testing.T) {
+		daysOff, err := ParseDaysOff("2025-03-03..2025-03-05, 2025-03-10")
+		if err != nil {
+			t.Fatalf("ParseDaysOff() error = %v", err)
+		}
+		if len(daysOff) != 2 || !daysOff[0].Contains(time.Date(2025, 3, 4, 12, 0, 0, 0, time.UTC)) ||
+			!daysOff[1].Start.Equal(daysOff[1].End) {
+			t.Errorf("ParseDaysOff() = %v", daysOff)
+		}
+		if daysOff, err := ParseDaysOff(""); err != nil || len(daysOff) != 0 {
+			t.Errorf("ParseDaysOff() = %v, %v", daysOff, err)
+		}
+	})
+
+	t.Run("Invalid range", func(t *testing.T) {
+		for _, src := range []string{"2025-03-05..2025-03-03", "03/05/2025", "2025-03-03..tomorrow"} {
+			if _, err := ParseDaysOff(src); err == nil {
+				t.Errorf("ParseDaysOff(%s) expected error", src)
+			}
+		}
+	})
+}
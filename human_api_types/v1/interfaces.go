@@ -7,3 +7,11 @@ type ProjectManager interface {
 	GetWorkerSprintWobjects(sprint *Sprint, worker *Worker) ([]*Wobject, error)
 	UpdateWobject(*Wobject) error
 }
+
+type CapacityPlanner interface {
+	GetCapacityReport(teamName string) (*CapacityReport, error)
+	CreateSprints(teamName string, template *SprintTemplate) ([]Sprint, error)
+	// The worker is the team member id, display name or unique name.
+	SetWorkerDaysOff(teamName string, sprintName string, worker string, daysOff []DaysOff) error
+	SetTeamDaysOff(teamName string, sprintName string, daysOff []DaysOff) error
+}
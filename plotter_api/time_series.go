@@ -0,0 +1,50 @@
+package plotterapi
+
+import (
+	"fmt"
+	"sort"
+
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/plotutil"
+	"gonum.org/v1/plot/vg"
+)
+
+// PlotTimeSeries saves a line per series to an image, X values are unix seconds.
+// The image format is taken from the file extension: png, svg, pdf...
+func PlotTimeSeries(filePath, title, yLabel string, series map[string]plotter.XYs) error {
+	errorPrefix := "[plotter_api:PlotTimeSeries]"
+	if len(series) == 0 {
+		return fmt.Errorf("%s no series to plot", errorPrefix)
+	}
+
+	p := plot.New()
+	p.Title.Text = title
+	p.X.Label.Text = "Time (UTC)"
+	p.X.Tick.Marker = plot.TimeTicks{Format: "2006-01-02\n15:04"}
+	p.Y.Label.Text = yLabel
+	p.Add(plotter.NewGrid())
+
+	names := []string{}
+	for name := range series {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for index, name := range names {
+		line, err := plotter.NewLine(series[name])
+		if err != nil {
+			return fmt.Errorf("%s series %s\n%w", errorPrefix, name, err)
+		}
+		line.Color = plotutil.Color(index)
+		line.Dashes = plotutil.Dashes(index / len(plotutil.DefaultColors))
+		p.Add(line)
+		p.Legend.Add(name, line)
+	}
+	p.Legend.Top = true
+
+	if err := p.Save(10*vg.Inch, 5*vg.Inch, filePath); err != nil {
+		return fmt.Errorf("%s failed to save %s\n%w", errorPrefix, filePath, err)
+	}
+	return nil
+}
@@ -0,0 +1,272 @@
+package task_scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var ErrClosed = errors.New("scheduler is closed")
+var ErrDependencyFailed = errors.New("dependency failed")
+
+// Task is a unit of work run by the Scheduler.
+// Result, Error and Attempts are set by the scheduler and are safe to read from OnProgress and after Wait.
+type Task struct {
+	ID         int
+	Name       string
+	Work       func(ctx context.Context) (any, error)
+	Retries    int
+	RetryDelay time.Duration
+	DependsOn  []*Task
+
+	Result   any
+	Error    error
+	Attempts int
+
+	scheduler  *Scheduler
+	waiting    int
+	dependents []*Task
+	finished   bool
+}
+
+func (task *Task) String() string {
+	if task.Name != "" {
+		return fmt.Sprintf("%d %s", task.ID, task.Name)
+	}
+	return fmt.Sprintf("%d", task.ID)
+}
+
+// Progress is reported once per finished task, skipped and cancelled tasks included.
+type Progress struct {
+	Task     *Task
+	Total    int
+	Finished int
+	Failed   int
+}
+
+// Scheduler runs tasks on a fixed number of workers.
+// Tasks run once all their dependencies succeeded, a failed dependency fails its dependents without running them.
+// Tasks can add more tasks while running, Wait returns when all of them finished.
+type Scheduler struct {
+	NumWorkers int
+	// OnProgress is called from the workers, one call at a time.
+	OnProgress func(Progress)
+
+	ctx          context.Context
+	cancel       context.CancelFunc
+	lock         sync.Mutex
+	workCond     *sync.Cond
+	doneCond     *sync.Cond
+	progressLock sync.Mutex
+	workers      sync.WaitGroup
+	ready        []*Task
+	nextID       int
+	pending      int
+	total        int
+	finished     int
+	failed       int
+	errs         []error
+	closed       bool
+}
+
+// Generator
+func SchedulerNew(ctx context.Context, numWorkers int) (*Scheduler, error) {
+	if numWorkers < 1 {
+		return nil, fmt.Errorf("[task_scheduler:SchedulerNew] number of workers must be positive: %d", numWorkers)
+	}
+	scheduler := &Scheduler{NumWorkers: numWorkers}
+	scheduler.ctx, scheduler.cancel = context.WithCancel(ctx)
+	scheduler.workCond = sync.NewCond(&scheduler.lock)
+	scheduler.doneCond = sync.NewCond(&scheduler.lock)
+
+	for range numWorkers {
+		scheduler.workers.Add(1)
+		go scheduler.work()
+	}
+	return scheduler, nil
+}
+
+// Add schedules the task. The dependencies must have been added to the same scheduler before.
+func (scheduler *Scheduler) Add(task *Task) error {
+	errorPrefix := "[task_scheduler:Add]"
+	if task.Work == nil {
+		return fmt.Errorf("%s task has no work: %s", errorPrefix, task.Name)
+	}
+	if task.Retries < 0 {
+		return fmt.Errorf("%s negative retries: %s", errorPrefix, task.Name)
+	}
+
+	scheduler.lock.Lock()
+	if scheduler.closed {
+		scheduler.lock.Unlock()
+		return ErrClosed
+	}
+	if task.scheduler != nil {
+		scheduler.lock.Unlock()
+		return fmt.Errorf("%s task was already added: %s", errorPrefix, task)
+	}
+	for _, dependency := range task.DependsOn {
+		if dependency.scheduler != scheduler {
+			scheduler.lock.Unlock()
+			return fmt.Errorf("%s dependency was not added to the scheduler: %s", errorPrefix, task.Name)
+		}
+	}
+
+	task.scheduler = scheduler
+	task.ID = scheduler.nextID
+	scheduler.nextID++
+	scheduler.pending++
+	scheduler.total++
+
+	var failedDependency *Task
+	for _, dependency := range task.DependsOn {
+		if !dependency.finished {
+			task.waiting++
+			dependency.dependents = append(dependency.dependents, task)
+		} else if dependency.Error != nil && failedDependency == nil {
+			failedDependency = dependency
+		}
+	}
+
+	var reports []Progress
+	if failedDependency != nil {
+		task.Error = fmt.Errorf("%w: %s", ErrDependencyFailed, failedDependency)
+		reports = scheduler.finishLocked(task)
+	} else if task.waiting == 0 {
+		scheduler.pushLocked(task)
+	}
+	scheduler.lock.Unlock()
+
+	scheduler.report(reports)
+	return nil
+}
+
+// Wait blocks until all the tasks finished and closes the scheduler.
+// The returned error joins the errors of all the failed tasks.
+func (scheduler *Scheduler) Wait() error {
+	scheduler.lock.Lock()
+	for scheduler.pending > 0 {
+		scheduler.doneCond.Wait()
+	}
+	scheduler.closed = true
+	scheduler.workCond.Broadcast()
+	errs := scheduler.errs
+	scheduler.lock.Unlock()
+
+	scheduler.workers.Wait()
+	scheduler.cancel()
+	return errors.Join(errs...)
+}
+
+// Cancel cancels the context passed to the tasks, tasks not started yet fail with context.Canceled.
+func (scheduler *Scheduler) Cancel() {
+	scheduler.cancel()
+}
+
+func (scheduler *Scheduler) pushLocked(task *Task) {
+	scheduler.ready = append(scheduler.ready, task)
+	scheduler.workCond.Signal()
+}
+
+// finishLocked marks the task finished and releases or fails its dependents.
+func (scheduler *Scheduler) finishLocked(task *Task) []Progress {
+	task.finished = true
+	scheduler.pending--
+	scheduler.finished++
+	if task.Error != nil {
+		scheduler.failed++
+		scheduler.errs = append(scheduler.errs, fmt.Errorf("task %s: %w", task, task.Error))
+	}
+	reports := []Progress{{Task: task, Total: scheduler.total, Finished: scheduler.finished, Failed: scheduler.failed}}
+
+	for _, dependent := range task.dependents {
+		if dependent.finished {
+			continue
+		}
+		dependent.waiting--
+		if task.Error != nil {
+			dependent.Error = fmt.Errorf("%w: %s", ErrDependencyFailed, task)
+			reports = append(reports, scheduler.finishLocked(dependent)...)
+		} else if dependent.waiting == 0 {
+			scheduler.pushLocked(dependent)
+		}
+	}
+	task.dependents = nil
+
+	if scheduler.pending == 0 {
+		scheduler.doneCond.Broadcast()
+	}
+	return reports
+}
+
+func (scheduler *Scheduler) report(reports []Progress) {
+	if scheduler.OnProgress == nil || len(reports) == 0 {
+		return
+	}
+	scheduler.progressLock.Lock()
+	defer scheduler.progressLock.Unlock()
+	for _, progress := range reports {
+		scheduler.OnProgress(progress)
+	}
+}
+
+// Workers drain the ready tasks after a cancel, each fails without running.
+func (scheduler *Scheduler) work() {
+	defer scheduler.workers.Done()
+	for {
+		scheduler.lock.Lock()
+		for len(scheduler.ready) == 0 && !scheduler.closed {
+			scheduler.workCond.Wait()
+		}
+		if len(scheduler.ready) == 0 {
+			scheduler.lock.Unlock()
+			return
+		}
+		task := scheduler.ready[0]
+		scheduler.ready[0] = nil
+		scheduler.ready = scheduler.ready[1:]
+		scheduler.lock.Unlock()
+
+		scheduler.run(task)
+
+		scheduler.lock.Lock()
+		reports := scheduler.finishLocked(task)
+		scheduler.lock.Unlock()
+		scheduler.report(reports)
+	}
+}
+
+// run calls the task work until it succeeds or runs out of retries.
+func (scheduler *Scheduler) run(task *Task) {
+	for attempt := 0; attempt <= task.Retries; attempt++ {
+		if attempt > 0 && task.RetryDelay > 0 {
+			select {
+			case <-scheduler.ctx.Done():
+			case <-time.After(task.RetryDelay):
+			}
+		}
+		if err := scheduler.ctx.Err(); err != nil {
+			if task.Error == nil {
+				task.Error = err
+			}
+			return
+		}
+		task.Attempts++
+		task.Result, task.Error = call(scheduler.ctx, task)
+		if task.Error == nil {
+			return
+		}
+	}
+}
+
+// A panicking task fails instead of taking the process down.
+func call(ctx context.Context, task *Task) (ret any, err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("[task_scheduler:run] panic: %v", recovered)
+		}
+	}()
+	return task.Work(ctx)
+}
@@ -0,0 +1,240 @@
+package task_scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestScheduler(t *testing.T) {
+	t.Run("Valid run", func(t *testing.T) {
+		scheduler, err := SchedulerNew(context.Background(), 4)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		tasks := []*Task{}
+		for index := range 100 {
+			task := &Task{Work: func(ctx context.Context) (any, error) { return index * 2, nil }}
+			tasks = append(tasks, task)
+			if err := scheduler.Add(task); err != nil {
+				t.Fatalf("%v", err)
+			}
+		}
+		if err := scheduler.Wait(); err != nil {
+			t.Fatalf("%v", err)
+		}
+		for index, task := range tasks {
+			if task.Result != index*2 || task.Attempts != 1 || task.ID != index {
+				t.Errorf("unexpected task %s: %v, attempts %d", task, task.Result, task.Attempts)
+			}
+		}
+		if err := scheduler.Add(&Task{Work: func(ctx context.Context) (any, error) { return nil, nil }}); !errors.Is(err, ErrClosed) {
+			t.Errorf("expected closed scheduler: %v", err)
+		}
+	})
+
+	t.Run("Errors and retries", func(t *testing.T) {
+		scheduler, err := SchedulerNew(context.Background(), 2)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		flaky := &Task{Name: "flaky", Retries: 2}
+		flaky.Work = func(ctx context.Context) (any, error) {
+			if flaky.Attempts < 3 {
+				return nil, errors.New("throttled")
+			}
+			return "done", nil
+		}
+		broken := &Task{Name: "broken", Retries: 1, Work: func(ctx context.Context) (any, error) { return nil, errors.New("broken") }}
+		panicking := &Task{Name: "panicking", Work: func(ctx context.Context) (any, error) { panic("nil map") }}
+		for _, task := range []*Task{flaky, broken, panicking} {
+			if err := scheduler.Add(task); err != nil {
+				t.Fatalf("%v", err)
+			}
+		}
+
+		err = scheduler.Wait()
+		if err == nil || err.Error() != "task 1 broken: broken\ntask 2 panicking: [task_scheduler:run] panic: nil map" &&
+			err.Error() != "task 2 panicking: [task_scheduler:run] panic: nil map\ntask 1 broken: broken" {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if flaky.Result != "done" || flaky.Attempts != 3 || broken.Attempts != 2 {
+			t.Errorf("unexpected attempts: %d, %d", flaky.Attempts, broken.Attempts)
+		}
+	})
+
+	t.Run("Dependencies", func(t *testing.T) {
+		scheduler, err := SchedulerNew(context.Background(), 3)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		order := make(chan string, 10)
+		step := func(name string, err error, dependsOn ...*Task) *Task {
+			task := &Task{Name: name, DependsOn: dependsOn, Work: func(ctx context.Context) (any, error) {
+				order <- name
+				return nil, err
+			}}
+			if err := scheduler.Add(task); err != nil {
+				t.Fatalf("%v", err)
+			}
+			return task
+		}
+		fetch := step("fetch", nil)
+		parse := step("parse", nil, fetch)
+		failing := step("failing", errors.New("no access"), fetch)
+		merge := step("merge", nil, parse, failing)
+		report := step("report", nil, merge)
+
+		err = scheduler.Wait()
+		close(order)
+		if !errors.Is(merge.Error, ErrDependencyFailed) || !errors.Is(report.Error, ErrDependencyFailed) || !errors.Is(err, ErrDependencyFailed) {
+			t.Errorf("dependents of a failed task must fail: %v", err)
+		}
+		ran := []string{}
+		for name := range order {
+			ran = append(ran, name)
+		}
+		if len(ran) != 3 || ran[0] != "fetch" || merge.Attempts != 0 || report.Attempts != 0 {
+			t.Errorf("unexpected run order: %v", ran)
+		}
+		if parse.Error != nil {
+			t.Errorf("unexpected error: %v", parse.Error)
+		}
+	})
+
+	t.Run("Cancel", func(t *testing.T) {
+		scheduler, err := SchedulerNew(context.Background(), 1)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		started := make(chan bool)
+		blocking := &Task{Retries: 5, RetryDelay: time.Hour, Work: func(ctx context.Context) (any, error) {
+			close(started)
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}}
+		queued := &Task{Work: func(ctx context.Context) (any, error) { return "ran", nil }}
+		for _, task := range []*Task{blocking, queued} {
+			if err := scheduler.Add(task); err != nil {
+				t.Fatalf("%v", err)
+			}
+		}
+		<-started
+		scheduler.Cancel()
+
+		if err := scheduler.Wait(); !errors.Is(err, context.Canceled) {
+			t.Errorf("expected cancelled tasks: %v", err)
+		}
+		if blocking.Attempts != 1 || queued.Attempts != 0 || queued.Result != nil {
+			t.Errorf("cancelled tasks must not run: %d, %d", blocking.Attempts, queued.Attempts)
+		}
+	})
+
+	t.Run("Invalid tasks", func(t *testing.T) {
+		if _, err := SchedulerNew(context.Background(), 0); err == nil {
+			t.Errorf("expected an error")
+		}
+		scheduler, err := SchedulerNew(context.Background(), 1)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		work := func(ctx context.Context) (any, error) { return nil, nil }
+		for _, task := range []*Task{{}, {Work: work, Retries: -1}, {Work: work, DependsOn: []*Task{{Work: work}}}} {
+			if err := scheduler.Add(task); err == nil {
+				t.Errorf("expected an error: %+v", task)
+			}
+		}
+		if err := scheduler.Wait(); err != nil {
+			t.Errorf("%v", err)
+		}
+	})
+}
+
+// Run with -race: tasks add tasks and depend on each other from all the workers.
+func TestSchedulerStress(t *testing.T) {
+	t.Run("Valid run", func(t *testing.T) {
+		scheduler, err := SchedulerNew(context.Background(), 16)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		var progressCalls, finishedMax int
+		scheduler.OnProgress = func(progress Progress) {
+			progressCalls++
+			finishedMax = max(finishedMax, progress.Finished)
+		}
+
+		var ran atomic.Int64
+		leaf := func(ctx context.Context) (any, error) {
+			ran.Add(1)
+			return nil, nil
+		}
+		for range 50 {
+			parent := &Task{}
+			parent.Work = func(ctx context.Context) (any, error) {
+				ran.Add(1)
+				previous := parent
+				for index := range 40 {
+					task := &Task{Name: fmt.Sprintf("leaf %d", index), Work: leaf, DependsOn: []*Task{previous}}
+					if index%2 == 0 {
+						task.DependsOn = nil
+					}
+					if err := scheduler.Add(task); err != nil {
+						return nil, err
+					}
+					if index%2 == 0 {
+						previous = task
+					}
+				}
+				return nil, nil
+			}
+			if err := scheduler.Add(parent); err != nil {
+				t.Fatalf("%v", err)
+			}
+		}
+
+		if err := scheduler.Wait(); err != nil {
+			t.Fatalf("%v", err)
+		}
+		if ran.Load() != 50*41 || progressCalls != 50*41 || finishedMax != 50*41 {
+			t.Errorf("unexpected counts: ran %d, progress %d, finished %d", ran.Load(), progressCalls, finishedMax)
+		}
+	})
+
+	t.Run("Cancel while adding", func(t *testing.T) {
+		for range 20 {
+			ctx, cancel := context.WithCancel(context.Background())
+			t.Cleanup(cancel)
+			scheduler, err := SchedulerNew(ctx, 8)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			var failed atomic.Int64
+			scheduler.OnProgress = func(progress Progress) {
+				if progress.Task.Error != nil {
+					failed.Add(1)
+				}
+			}
+			var previous *Task
+			for index := range 200 {
+				task := &Task{Work: func(ctx context.Context) (any, error) { return nil, ctx.Err() }}
+				if previous != nil && index%3 == 0 {
+					task.DependsOn = []*Task{previous}
+				}
+				if err := scheduler.Add(task); err != nil {
+					t.Fatalf("%v", err)
+				}
+				previous = task
+				if index == 100 {
+					cancel()
+				}
+			}
+			err = scheduler.Wait()
+			if failed.Load() > 0 && !errors.Is(err, context.Canceled) {
+				t.Errorf("expected cancelled tasks: %v", err)
+			}
+		}
+	})
+}